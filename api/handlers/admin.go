@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/middleware"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/api/policy"
+	"github.com/btnmasher/shiftr/opaque"
+	"github.com/btnmasher/shiftr/secrets"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+func ListRoutePermissions(reg *policy.Registry) func(echo.Context) error {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, reg.Routes())
+	}
+}
+
+func AccessReport(reg *policy.Registry) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var params struct {
+			UserID string    `query:"user_id"`
+			At     time.Time `query:"at"` // RFC3339, defaults to now
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		if params.UserID == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "user_id required")
+		}
+
+		// The admin routes are not behind the external ID middleware, so the user is resolved here
+		uid, err := opaque.Decode(opaque.User, params.UserID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid user_id")
+		}
+
+		if params.At.IsZero() {
+			params.At = time.Now()
+		}
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		report, err := models.ReportAccess(db, uid, params.At)
+		if err != nil {
+			return err
+		}
+
+		// Deactivated users cannot log in, so their role grants them nothing
+		if report.Active {
+			for _, route := range reg.Allowed(report.Role) {
+				report.Permissions = append(report.Permissions, route.Method+" "+route.Path)
+			}
+		}
+
+		return c.JSON(http.StatusOK, report)
+	}
+}
+
+func DatabaseStats() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		stats, err := models.CollectDatabaseStats(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, stats)
+	}
+}
+
+func AdminStats(weeks models.Weeks) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		stats, err := models.CollectAdminStats(db, weeks, time.Now())
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, stats)
+	}
+}
+
+func RotateEncryption() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to re-encrypt all stale values with the primary key
+		rotated, err := models.RotateEncryption(db)
+		if err != nil {
+			if errors.Is(err, secrets.ErrNoKeyring) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+
+			return err
+		}
+
+		return c.JSON(http.StatusOK, echo.Map{
+			"primary": secrets.CurrentKeyring(db).Primary(),
+			"rotated": rotated,
+		})
+	}
+}
+
+func ListJWTKeys(keys *middleware.KeySet) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		list, err := keys.Keys()
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, list)
+	}
+}
+
+func RotateJWTSecret(keys *middleware.KeySet) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var data struct {
+			Secret string `json:"secret"` // optional, generated when empty
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Attempt to make the new secret current, retiring the previous one for the grace period
+		_, generated, err := keys.Rotate(data.Secret)
+		if err != nil {
+			if errors.Is(err, models.ErrKeyCurrent) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+
+			return err
+		}
+
+		list, err := keys.Keys()
+		if err != nil {
+			return err
+		}
+
+		// A generated secret is only ever revealed here, for the admin to keep
+		return c.JSON(http.StatusOK, struct {
+			Keys   []middleware.KeyInfo `json:"keys"`
+			Secret string               `json:"secret,omitempty"`
+		}{list, generated})
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/jobs"
+	"github.com/btnmasher/shiftr/storage"
+	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+	"path"
+)
+
+func ListAnalyticsExports(store storage.BlobStore) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		objects, err := store.List(c.Request().Context(), jobs.AnalyticsPrefix)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, objects)
+	}
+}
+
+func GetAnalyticsExport(store storage.BlobStore) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters, constrained to the analytics exports
+		key := jobs.AnalyticsPrefix + c.Param("*")
+
+		r, err := store.Get(c.Request().Context(), key)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+		defer r.Close()
+
+		c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+path.Base(key)+`"`)
+		c.Response().WriteHeader(http.StatusOK)
+
+		_, err = io.Copy(c.Response(), r)
+		return err
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/middleware"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/notify"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+// findPendingShift returns the shift in the :id parameter once the current user is found to be allowed
+// to approve it, which its worker's manager, their delegate, and admins are
+func findPendingShift(c echo.Context, db *gorm.DB) (*models.Shift, error) {
+	shift, err := findShift(c, db)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := models.CanApprove(db, c.Get("id").(string), c.Get("role").(string), shift.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+
+	return shift, nil
+}
+
+func ListPendingShifts() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect context values
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		shifts, err := models.ListShifts(db, models.FilterStatus(models.ShiftPending))
+		if err != nil {
+			return err
+		}
+
+		// Constrain the shifts to those the current user may approve
+		approvable := []*models.Shift{}
+		for _, shift := range shifts {
+			ok, err := models.CanApprove(db, uid, role, shift.UserID)
+			if err != nil {
+				return err
+			}
+
+			if ok {
+				approvable = append(approvable, shift)
+			}
+		}
+
+		return c.JSON(http.StatusOK, approvable)
+	}
+}
+
+func ApproveShift(notifier notify.Notifier) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		shift, err := findPendingShift(c, db)
+		if err != nil {
+			return err
+		}
+
+		err = shift.Approve(db)
+		if err != nil {
+			if errors.Is(err, models.ErrNotPending) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+
+			return err
+		}
+
+		middleware.AuditSubjects(c, shift.UserID)
+		notifyDecision(c, db, notifier, shift, "approved")
+
+		return c.JSON(http.StatusOK, shift)
+	}
+}
+
+func RejectShift(notifier notify.Notifier) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var data struct {
+			Reason string `json:"reason"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		shift, err := findPendingShift(c, db)
+		if err != nil {
+			return err
+		}
+
+		err = shift.Reject(db, data.Reason)
+		if err != nil {
+			if errors.Is(err, models.ErrNotPending) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		middleware.AuditSubjects(c, shift.UserID)
+		notifyDecision(c, db, notifier, shift, "rejected: "+data.Reason)
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// notifyApprovers lets the approvers of the user know a request of theirs is awaiting approval.
+// The body is written for each approver, so dates read in their time zone. Delivery failures are logged
+// rather than failing the request which needs approving.
+func notifyApprovers(c echo.Context, db *gorm.DB, notifier notify.Notifier, uid, event, subject string,
+	body func(l *models.Localization) string) {
+	approvers, err := models.Approvers(db, uid)
+	if err != nil {
+		c.Logger().Errorf("approval: %s", err)
+		return
+	}
+
+	for _, approver := range approvers {
+		l := models.FindLocalization(db, approver.ID)
+		err = notifier.Notify(c.Request().Context(), notify.Message{
+			UserID:  approver.ID,
+			To:      approver.Email,
+			Subject: subject,
+			Event:   event,
+			Locale:  l.Locale,
+			Body:    body(l),
+		})
+		if err != nil {
+			c.Logger().Errorf("approval: %s", err)
+		}
+	}
+}
+
+// notifyDecision lets the worker of the shift know the outcome of its approval
+func notifyDecision(c echo.Context, db *gorm.DB, notifier notify.Notifier, shift *models.Shift, outcome string) {
+	user, err := models.FindUserByID(db, shift.UserID)
+	if err == nil {
+		l := models.FindLocalization(db, user.ID)
+		err = notifier.Notify(c.Request().Context(), notify.Message{
+			UserID:  user.ID,
+			To:      user.Email,
+			Subject: "Shift request reviewed",
+			Event:   notify.EventShiftReviewed,
+			Locale:  l.Locale,
+			Body: fmt.Sprintf("Your shift from %s to %s was %s",
+				l.Format(shift.Start), l.Format(shift.End), outcome),
+		})
+	}
+	if err != nil {
+		c.Logger().Errorf("shift approval: %s", err)
+	}
+}
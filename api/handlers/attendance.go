@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// attendanceParams is a temporary struct to hold the user submitted filters shared by the attendance endpoints
+type attendanceParams struct {
+	UserID string    `query:"user_id"`
+	Start  time.Time `query:"filter_start"` // RFC33339
+	End    time.Time `query:"filter_end"`   // RFC33339
+}
+
+// bindAttendanceParams collects the attendance filters, constraining the user to their own attendance if not admin
+func bindAttendanceParams(c echo.Context) (*attendanceParams, error) {
+	params := &attendanceParams{}
+
+	err := c.Bind(params)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+	}
+
+	if c.Get("role").(string) == "user" {
+		params.UserID = c.Get("id").(string)
+	}
+
+	return params, nil
+}
+
+func ClockIn() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var data struct {
+			Latitude  *float64 `json:"latitude"`
+			Longitude *float64 `json:"longitude"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect context values
+		db := c.Get("db").(*gorm.DB)
+		uid := c.Get("id").(string)
+
+		entry, err := models.ClockIn(db, uid, time.Now(), data.Latitude, data.Longitude)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrAlreadyClockedIn):
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			case errors.Is(err, models.ErrNoCoordinates), errors.Is(err, models.ErrOutsideGeofence):
+				return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+			}
+
+			return err
+		}
+
+		return c.JSON(http.StatusCreated, entry)
+	}
+}
+
+func ClockOut() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect context values
+		db := c.Get("db").(*gorm.DB)
+		uid := c.Get("id").(string)
+
+		entry, err := models.ClockOut(db, uid, time.Now())
+		if err != nil {
+			if errors.Is(err, models.ErrNotClockedIn) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+
+			return err
+		}
+
+		return c.JSON(http.StatusOK, entry)
+	}
+}
+
+func ListAttendance() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		params, err := bindAttendanceParams(c)
+		if err != nil {
+			return err
+		}
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		records, err := models.ListAttendance(db, params.Start, params.End, params.UserID)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, records)
+	}
+}
+
+func AttendanceReport() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		params, err := bindAttendanceParams(c)
+		if err != nil {
+			return err
+		}
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		totals, err := models.SummarizeAttendance(db, params.Start, params.End, params.UserID)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, totals)
+	}
+}
+
+func ListTimesheets() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		params, err := bindAttendanceParams(c)
+		if err != nil {
+			return err
+		}
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		timesheets, err := models.ListTimesheets(db, params.Start, params.End, params.UserID)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, timesheets)
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+func ExportAudit() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var params struct {
+			After uint64 `query:"after"` // sequence number of the last entry already exported
+			Limit int    `query:"limit"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		entries, err := models.ListAudit(db, params.After, params.Limit)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, entries)
+	}
+}
+
+func VerifyAudit() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		result, err := models.VerifyAudit(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, result)
+	}
+}
+
+func ListUserActivity() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var params struct {
+			Before uint64 `query:"before"` // sequence number of the oldest entry already listed
+			Limit  int    `query:"limit"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		// Constrain the user to their own activity if not admin
+		if role == "user" && id != uid {
+			return echo.ErrUnauthorized
+		}
+
+		// Deactivated users keep their activity
+		_, err = models.FindUserByID(db.Unscoped(), id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		entries, err := models.ListUserActivity(db, id, params.Before, params.Limit)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, entries)
+	}
+}
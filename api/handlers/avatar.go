@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/storage"
+	"github.com/jkomyno/nanoid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// avatarUpload returns the uploaded image, either the "avatar" file of a multipart form or the request body
+func avatarUpload(c echo.Context, maxSize int64) ([]byte, error) {
+	var r io.Reader = c.Request().Body
+
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		file, err := c.FormFile("avatar")
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "avatar file required")
+		}
+
+		if file.Size > maxSize {
+			return nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("avatar must be at most %d bytes", maxSize))
+		}
+
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	// Read one byte past the limit to tell a full sized image from one which is too large
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > maxSize {
+		return nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("avatar must be at most %d bytes", maxSize))
+	}
+
+	if len(data) == 0 {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "avatar file required")
+	}
+
+	return data, nil
+}
+
+func UploadAvatar(store storage.BlobStore, maxSize int64) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+		db := c.Get("db").(*gorm.DB)
+
+		// Constrain the user to their own avatar if not admin
+		if role == "user" && id != uid {
+			return echo.ErrUnauthorized
+		}
+
+		user, err := models.FindUserByID(db, id)
+		if err != nil {
+			return echo.ErrNotFound
+		}
+
+		data, err := avatarUpload(c, maxSize)
+		if err != nil {
+			return err
+		}
+
+		// The type is sniffed from the content, the declared type is not trusted
+		contentType := http.DetectContentType(data)
+		ext, ok := models.AvatarTypes[contentType]
+		if !ok {
+			return echo.NewHTTPError(http.StatusUnsupportedMediaType, "avatar must be a PNG, JPEG, GIF or WebP image")
+		}
+
+		// Each upload is stored under a new unguessable name, so cached copies of the old avatar are never served
+		name, err := nanoid.Nanoid(21)
+		if err != nil {
+			return err
+		}
+
+		key := models.AvatarPrefix + name + ext
+		ctx := c.Request().Context()
+
+		err = store.Put(ctx, key, bytes.NewReader(data), contentType)
+		if err != nil {
+			return err
+		}
+
+		old, err := user.SetAvatar(db, key)
+		if err != nil {
+			store.Delete(ctx, key)
+			return err
+		}
+
+		if old != "" {
+			err = store.Delete(ctx, old)
+			if err != nil && !errors.Is(err, storage.ErrNotFound) {
+				c.Logger().Errorf("removing replaced avatar %s: %s", old, err)
+			}
+		}
+
+		return c.JSON(http.StatusOK, user)
+	}
+}
+
+func DeleteAvatar(store storage.BlobStore) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+		db := c.Get("db").(*gorm.DB)
+
+		// Constrain the user to their own avatar if not admin
+		if role == "user" && id != uid {
+			return echo.ErrUnauthorized
+		}
+
+		user, err := models.FindUserByID(db, id)
+		if err != nil {
+			return echo.ErrNotFound
+		}
+
+		if user.Avatar == "" {
+			return echo.ErrNotFound
+		}
+
+		old, err := user.SetAvatar(db, "")
+		if err != nil {
+			return err
+		}
+
+		err = store.Delete(c.Request().Context(), old)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			c.Logger().Errorf("removing avatar %s: %s", old, err)
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+func GetAvatar(store storage.BlobStore) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters, constrained to the avatars
+		name := c.Param("name")
+		if strings.ContainsAny(name, "/\\%") || strings.Contains(name, "..") {
+			return echo.ErrNotFound
+		}
+
+		key := models.AvatarPrefix + name
+
+		r, err := store.Get(c.Request().Context(), key)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+		defer r.Close()
+
+		// Names are never reused, so the avatar can be cached indefinitely
+		c.Response().Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		c.Response().Header().Set("X-Content-Type-Options", "nosniff")
+
+		return c.Stream(http.StatusOK, mime.TypeByExtension(path.Ext(name)), r)
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/middleware"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/api/service"
+	"github.com/btnmasher/shiftr/opaque"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+func RunBatch(shifts *service.Shifts) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &struct {
+			Operations []*models.ShiftOperation `json:"operations"`
+		}{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		if len(data.Operations) == 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "operations required")
+		}
+
+		if len(data.Operations) > models.MaxBatchOperations {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				fmt.Sprintf("a batch cannot hold more than %d operations", models.MaxBatchOperations))
+		}
+
+		// Resolve the submitted shift IDs, reporting results under the IDs as submitted
+		submitted := make([]string, len(data.Operations))
+		for i, op := range data.Operations {
+			submitted[i] = op.ID
+
+			// IDs which do not resolve are left as submitted and will not be found
+			id, err := opaque.Decode(opaque.Shift, op.ID)
+			if err == nil {
+				op.ID = id
+			}
+		}
+
+		// Collect the database reference and context values
+		db := c.Get("db").(*gorm.DB)
+		uid := c.Get("id").(string)
+
+		results, err := models.RunShiftBatch(db, data.Operations)
+		for i, result := range results {
+			if data.Operations[i].Op != models.BatchCreate {
+				result.ID = submitted[i]
+				continue
+			}
+
+			id, encodeErr := opaque.Encode(opaque.Shift, result.ID)
+			if encodeErr != nil {
+				return encodeErr
+			}
+
+			result.ID = id
+		}
+
+		if err != nil {
+			if errors.Is(err, models.ErrBatchFailed) {
+				return c.JSON(http.StatusUnprocessableEntity, results)
+			}
+
+			return err
+		}
+
+		for _, result := range results {
+			if result.Shift != nil {
+				middleware.AuditSubjects(c, result.Shift.UserID)
+			}
+
+			if result.Previous != nil {
+				middleware.AuditSubjects(c, result.Previous.UserID)
+			}
+
+			// Let the workers know when a shift they can see is changed, as when updated one at a time
+			if result.Status == models.BulkUpdated && result.Shift.Status == models.ShiftPublished {
+				shifts.NotifyChanged(c.Request().Context(), db, uid, result.Previous, result.Shift)
+			}
+		}
+
+		return c.JSON(http.StatusOK, results)
+	}
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/middleware"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/notify"
+	"github.com/btnmasher/shiftr/opaque"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// biddingError translates the errors of the bidding models into responses
+func biddingError(err error) error {
+	switch {
+	case errors.Is(err, models.ErrBiddingNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, models.ErrNotOpenShift), errors.Is(err, models.ErrBidderIneligible):
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, models.ErrBidderNotInGroup):
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case errors.Is(err, models.ErrBiddingClosed), errors.Is(err, models.ErrBiddingAwarded),
+		errors.Is(err, models.ErrAlreadyBid), errors.Is(err, models.ErrNoBid), errors.Is(err, models.ErrNoBids):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+
+	return err
+}
+
+// findBidding returns the shift with the ID in the :id parameter along with its bidding, hiding
+// unpublished shifts from users
+func findBidding(c echo.Context, db *gorm.DB) (*models.Shift, *models.Bidding, error) {
+	shift, err := findShift(c, db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if c.Get("role").(string) == "user" && shift.Status != models.ShiftPublished {
+		return nil, nil, echo.ErrNotFound
+	}
+
+	bidding, err := models.FindBidding(db, shift.ID)
+	if err != nil {
+		return nil, nil, biddingError(err)
+	}
+
+	return shift, bidding, nil
+}
+
+func OpenBidding() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.Bidding{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Prepare a new object to write to the database, opening immediately with manual awarding by default
+		bidding := &models.Bidding{
+			Opens:   data.Opens,
+			Closes:  data.Closes,
+			Rule:    data.Rule,
+			GroupID: data.GroupID,
+		}
+
+		if bidding.Opens.IsZero() {
+			bidding.Opens = time.Now()
+		}
+
+		if bidding.Rule == "" {
+			bidding.Rule = models.AwardManual
+		}
+
+		// Ensure we have all necessary fields to create the object
+		err = bidding.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		// Ensure the group bidding is restricted to exists
+		if bidding.GroupID != "" {
+			_, err = models.FindGroupByID(db, bidding.GroupID)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return echo.NewHTTPError(http.StatusBadRequest, "group not found")
+				}
+
+				return err
+			}
+		}
+
+		shift, err := findShift(c, db)
+		if err != nil {
+			return err
+		}
+
+		err = shift.OpenBidding(db, bidding)
+		if err != nil {
+			return biddingError(err)
+		}
+
+		return c.JSON(http.StatusOK, bidding)
+	}
+}
+
+func GetBidding() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		_, bidding, err := findBidding(c, db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, bidding)
+	}
+}
+
+func ListShiftBids() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect context values
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		shift, _, err := findBidding(c, db)
+		if err != nil {
+			return err
+		}
+
+		bids, err := models.ListShiftBids(db, shift.ID)
+		if err != nil {
+			return err
+		}
+
+		// Constrain the user to their own bid if not admin
+		if role == "user" {
+			own := []*models.ShiftBid{}
+			for _, bid := range bids {
+				if bid.UserID == uid {
+					own = append(own, bid)
+				}
+			}
+			bids = own
+		}
+
+		return c.JSON(http.StatusOK, bids)
+	}
+}
+
+func PlaceBid() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.ShiftBid{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect context values
+		db := c.Get("db").(*gorm.DB)
+		uid := c.Get("id").(string)
+
+		shift, bidding, err := findBidding(c, db)
+		if err != nil {
+			return err
+		}
+
+		bid, err := bidding.Bid(db, shift, uid, data.Note)
+		if err != nil {
+			return biddingError(err)
+		}
+
+		return c.JSON(http.StatusCreated, bid)
+	}
+}
+
+func WithdrawBid() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect context values
+		db := c.Get("db").(*gorm.DB)
+		uid := c.Get("id").(string)
+
+		_, bidding, err := findBidding(c, db)
+		if err != nil {
+			return err
+		}
+
+		err = bidding.Withdraw(db, uid)
+		if err != nil {
+			return biddingError(err)
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+func AwardBid(notifier notify.Notifier) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var data struct {
+			UserID string `json:"user_id"` // the most senior bidder when empty
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		shift, bidding, err := findBidding(c, db)
+		if err != nil {
+			return err
+		}
+
+		// Resolve the submitted user ID
+		data.UserID, err = opaque.Decode(opaque.User, data.UserID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid user_id")
+		}
+
+		if data.UserID == "" {
+			data.UserID, err = bidding.SeniorBidder(db)
+			if err != nil {
+				return biddingError(err)
+			}
+		}
+
+		err = bidding.Award(db, shift, data.UserID)
+		if err != nil {
+			return biddingError(err)
+		}
+
+		middleware.AuditSubjects(c, shift.UserID)
+		notifyBidAwarded(c, db, notifier, shift)
+
+		return c.JSON(http.StatusOK, bidding)
+	}
+}
+
+// notifyBidAwarded lets the winning bidder know they have been awarded the shift
+func notifyBidAwarded(c echo.Context, db *gorm.DB, notifier notify.Notifier, shift *models.Shift) {
+	user, err := models.FindUserByID(db, shift.UserID)
+	if err == nil {
+		l := models.FindLocalization(db, user.ID)
+		err = notifier.Notify(c.Request().Context(), notify.Message{
+			UserID:  user.ID,
+			To:      user.Email,
+			Subject: "Shift awarded",
+			Event:   notify.EventShiftAwarded,
+			Locale:  l.Locale,
+			Body: fmt.Sprintf("Your bid was successful, you are now working the shift from %s to %s",
+				l.Format(shift.Start), l.Format(shift.End)),
+		})
+	}
+	if err != nil {
+		c.Logger().Errorf("bid award notification: %s", err)
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/notify"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+func SendBroadcast(channels []notify.Channel) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.Broadcast{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect context values
+		db := c.Get("db").(*gorm.DB)
+		uid := c.Get("id").(string)
+
+		// Prepare a new object to write to the database
+		broadcast := &models.Broadcast{
+			SenderID:   uid,
+			Subject:    data.Subject,
+			Body:       data.Body,
+			LocationID: data.LocationID,
+			PositionID: data.PositionID,
+			GroupID:    data.GroupID,
+			Deliveries: []*models.BroadcastDelivery{},
+		}
+
+		// Ensure we have all necessary fields to create the object
+		err = broadcast.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Find everyone on shift right now, or every member of the group
+		uids, err := broadcastRecipients(db, broadcast)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.NewHTTPError(http.StatusBadRequest, "group not found")
+			}
+
+			return err
+		}
+
+		for _, rid := range uids {
+			user, err := models.FindUserByID(db, rid)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					continue
+				}
+
+				return err
+			}
+
+			deliveries := notify.Broadcast(c.Request().Context(), channels, notify.Message{
+				UserID:  user.ID,
+				To:      user.Email,
+				Subject: broadcast.Subject,
+				Event:   notify.EventBroadcast,
+				Body:    broadcast.Body,
+				Urgent:  true,
+			})
+
+			for _, d := range deliveries {
+				broadcast.AddDelivery(user.ID, d.Channel, d.Err)
+			}
+		}
+
+		// Record the delivery status so it can be reviewed later
+		err = broadcast.Create(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusCreated, broadcast)
+	}
+}
+
+func GetBroadcast() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		broadcast, err := models.FindBroadcastByID(db, c.Param("id"))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		return c.JSON(http.StatusOK, broadcast)
+	}
+}
+
+// broadcastRecipients returns the User.IDs a broadcast is sent to: the members of its group when it has one,
+// otherwise everyone on shift. A location or position narrows them down to those on shift there.
+func broadcastRecipients(db *gorm.DB, broadcast *models.Broadcast) ([]string, error) {
+	if broadcast.GroupID == "" {
+		return models.OnDuty(db, time.Now(), broadcast.LocationID, broadcast.PositionID)
+	}
+
+	group, err := models.FindGroupByID(db, broadcast.GroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	members := group.MemberIDs()
+	if broadcast.LocationID == "" && broadcast.PositionID == "" {
+		return members, nil
+	}
+
+	onDuty, err := models.OnDuty(db, time.Now(), broadcast.LocationID, broadcast.PositionID)
+	if err != nil {
+		return nil, err
+	}
+
+	working := make(map[string]bool, len(onDuty))
+	for _, uid := range onDuty {
+		working[uid] = true
+	}
+
+	uids := []string{}
+	for _, uid := range members {
+		if working[uid] {
+			uids = append(uids, uid)
+		}
+	}
+
+	return uids, nil
+}
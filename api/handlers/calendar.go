@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/ical"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// feedURL returns the CalendarFeed with the URL it is rendered at, as returned to its owner
+func feedURL(c echo.Context, feed *models.CalendarFeed) *models.CalendarFeed {
+	feed.URL = absoluteURL(c, fmt.Sprintf("/calendar/%s.ics", feed.Token))
+
+	return feed
+}
+
+// absoluteURL returns the link to the path at the public URL of the server when configured,
+// otherwise on the host the request was made to under the base path the server is mounted at
+func absoluteURL(c echo.Context, path string) string {
+	if public, _ := c.Get("publicurl").(string); public != "" {
+		return public + path
+	}
+
+	base, _ := c.Get("basepath").(string)
+
+	return fmt.Sprintf("%s://%s%s%s", c.Scheme(), c.Request().Host, base, path)
+}
+
+func GetCalendarFeed() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		// Constrain the user from fetching another user's feed if not admin
+		if role == "user" {
+			if uid != id {
+				return echo.ErrUnauthorized
+			}
+		}
+
+		feed, err := models.FindCalendarFeed(db, id, "")
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		return c.JSON(http.StatusOK, feedURL(c, feed))
+	}
+}
+
+func CreateCalendarFeed() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		// Constrain the user from creating another user's feed if not admin
+		if role == "user" {
+			if uid != id {
+				return echo.ErrUnauthorized
+			}
+		}
+
+		// Ensure the user exists
+		_, err := models.FindUserByID(db, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Attempt to write the new feed to the database, revoking any previous one
+		feed := &models.CalendarFeed{
+			UserID: id,
+		}
+
+		err = feed.Create(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusCreated, feedURL(c, feed))
+	}
+}
+
+func CreateTeamCalendarFeed() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		tid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+		uid := c.Get("id").(string)
+
+		// Ensure the team exists
+		_, err := models.FindTeamByID(db, tid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Attempt to write the new feed to the database, revoking any previous one
+		feed := &models.CalendarFeed{
+			UserID: uid,
+			TeamID: tid,
+		}
+
+		err = feed.Create(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusCreated, feedURL(c, feed))
+	}
+}
+
+func RenderCalendar() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		token := strings.TrimSuffix(c.Param("token"), ".ics")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the feed matching the token
+		feed, err := models.FindCalendarFeedByToken(db, token)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		cal := &ical.Calendar{}
+		names := make(map[string]string)
+		visibility := models.VisibilityFull
+
+		// Resolve whose shifts the feed renders
+		filter := models.FilterUserID(feed.UserID)
+		if feed.TeamID != "" {
+			team, err := models.FindTeamByID(db, feed.TeamID)
+			if err != nil {
+				return err
+			}
+
+			members, err := models.ListTeamMembers(db, team.ID)
+			if err != nil {
+				return err
+			}
+
+			for _, member := range members {
+				names[member.ID] = member.Name
+			}
+
+			cal.Name = "shiftr: " + team.Name
+			visibility = team.Visibility
+			filter = models.FilterTeamID(team.ID)
+		} else {
+			cal.Name = "shiftr"
+		}
+
+		// Fetch the upcoming and in progress shifts
+		shifts, err := models.ListShifts(db,
+			filter,
+			models.FilterEndsAfter(time.Now()),
+			models.FilterStatus(models.ShiftPublished),
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, shift := range shifts {
+			summary := "Shift"
+			if name, ok := names[shift.UserID]; ok && shift.VisibleTo(visibility) == models.VisibilityFull {
+				summary = "Shift: " + name
+			}
+
+			cal.Events = append(cal.Events, ical.Event{
+				UID:      shift.ID + "@shiftr",
+				Summary:  summary,
+				Start:    shift.Start,
+				End:      shift.End,
+				Created:  shift.CreatedAt,
+				Modified: shift.UpdatedAt,
+			})
+		}
+
+		c.Response().Header().Set(echo.HeaderContentType, "text/calendar; charset=utf-8")
+		c.Response().WriteHeader(http.StatusOK)
+
+		return cal.Encode(c.Response())
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+func ListCertifications() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		// Constrain the user to their own certifications if not admin
+		if role == "user" && id != uid {
+			return echo.ErrUnauthorized
+		}
+
+		certs, err := models.ListCertifications(db, id)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, certs)
+	}
+}
+
+func CreateCertification() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.Certification{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		_, err = models.FindUserByID(db, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Prepare a new object to write to the database
+		cert := &models.Certification{
+			UserID:    id,
+			Name:      data.Name,
+			ExpiresAt: data.ExpiresAt,
+			Document:  data.Document,
+		}
+
+		// Ensure we have all necessary fields to create the object
+		err = cert.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		err = cert.Create(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusCreated, cert)
+	}
+}
+
+func UpdateCertification() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		cid := c.Param("cid")
+		db := c.Get("db").(*gorm.DB)
+
+		cert, err := models.FindCertificationByID(db, cid)
+		if err != nil || cert.UserID != id {
+			if err == nil || errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Apply the submitted fields over the existing ones
+		err = c.Bind(cert)
+		if err != nil {
+			return bindError(err)
+		}
+
+		cert.ID = cid
+		cert.UserID = id
+
+		// Ensure the resulting object is still valid
+		err = cert.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		err = cert.Update(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, cert)
+	}
+}
+
+func DeleteCertification() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		cid := c.Param("cid")
+		db := c.Get("db").(*gorm.DB)
+
+		cert, err := models.FindCertificationByID(db, cid)
+		if err != nil || cert.UserID != id {
+			if err == nil || errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		err = cert.Delete(db)
+		if err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
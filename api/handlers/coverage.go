@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// coverageParams is a temporary struct to hold the user submitted filters shared by the coverage endpoints
+type coverageParams struct {
+	Start      time.Time `query:"filter_start"` // RFC33339
+	End        time.Time `query:"filter_end"`   // RFC33339
+	LocationID string    `query:"location_id"`
+	PositionID string    `query:"position_id"`
+}
+
+func CreateCoverageRequirement() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.CoverageRequirement{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Prepare a new object to write to the database
+		req := models.CoverageRequirement{
+			LocationID: data.LocationID,
+			PositionID: data.PositionID,
+			Start:      data.Start,
+			End:        data.End,
+			Headcount:  data.Headcount,
+		}
+
+		// Ensure we have all necessary fields to create the object
+		err = req.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		// Ensure the referenced location and position exist
+		if req.LocationID != "" {
+			_, err = models.FindLocationByID(db, req.LocationID)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return echo.NewHTTPError(http.StatusBadRequest, "location not found")
+				}
+
+				return err
+			}
+		}
+
+		if req.PositionID != "" {
+			_, err = models.FindPositionByID(db, req.PositionID)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return echo.NewHTTPError(http.StatusBadRequest, "position not found")
+				}
+
+				return err
+			}
+		}
+
+		// Attempt to write the new object to the database
+		err = req.Create(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusCreated, req)
+	}
+}
+
+func ListCoverageRequirements() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		params := &coverageParams{}
+		err := c.Bind(params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		reqs, err := models.ListCoverageRequirements(db, params.Start, params.End, params.LocationID, params.PositionID)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, reqs)
+	}
+}
+
+func DeleteCoverageRequirement() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		rid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the requirement in the database with the specified ID
+		req, err := models.FindCoverageRequirementByID(db, rid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Attempt to delete the object from the database
+		err = req.Delete(db)
+		if err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+func CoverageReport() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var params struct {
+			coverageParams
+			Understaffed bool `query:"understaffed"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		reqs, err := models.ListCoverageRequirements(db, params.Start, params.End, params.LocationID, params.PositionID)
+		if err != nil {
+			return err
+		}
+
+		report, err := models.ComputeCoverage(db, reqs)
+		if err != nil {
+			return err
+		}
+
+		// Optionally only return the requirements which are not fully staffed
+		if params.Understaffed {
+			filtered := make([]*models.Coverage, 0, len(report))
+			for _, cov := range report {
+				if cov.Understaffed {
+					filtered = append(filtered, cov)
+				}
+			}
+			report = filtered
+		}
+
+		return c.JSON(http.StatusOK, report)
+	}
+}
+
+func SimulateCoverage() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var data struct {
+			Start      time.Time `json:"start"`
+			End        time.Time `json:"end"`
+			LocationID string    `json:"location_id"`
+			PositionID string    `json:"position_id"`
+			models.Scenario
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Ensure the hypothetical changes are valid
+		for _, absence := range data.Absences {
+			if absence.UserID == "" {
+				return echo.NewHTTPError(http.StatusBadRequest, "absence user id required")
+			}
+
+			if !absence.Start.Before(absence.End) {
+				return echo.NewHTTPError(http.StatusBadRequest, "absence start time must precede absence end time")
+			}
+		}
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		for _, shift := range data.Shifts {
+			shift.Status = models.ShiftPublished
+
+			err = shift.ValidateFor(db, "admin")
+			if err != nil {
+				return shiftInvalid(err)
+			}
+		}
+
+		reqs, err := models.ListCoverageRequirements(db, data.Start, data.End, data.LocationID, data.PositionID)
+		if err != nil {
+			return err
+		}
+
+		baseline, err := models.ComputeCoverage(db, reqs)
+		if err != nil {
+			return err
+		}
+
+		projected, err := models.SimulateCoverage(db, reqs, &data.Scenario)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, echo.Map{
+			"baseline":  baseline,
+			"projected": projected,
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+func CreateCustomField() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.CustomField{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Prepare a new object to write to the database
+		field := models.CustomField{
+			Name:     data.Name,
+			Type:     data.Type,
+			Required: data.Required,
+		}
+
+		// Ensure we have all necessary fields to create the object
+		err = field.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		// Ensure there is no other field with the specified name
+		_, err = models.FindCustomField(db, field.Name)
+		if err == nil {
+			return echo.NewHTTPError(http.StatusConflict, "custom field already exists")
+		}
+
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// Attempt to write the new object to the database
+		err = field.Create(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusCreated, field)
+	}
+}
+
+func ListCustomFields() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		fields, err := models.ListCustomFields(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, fields)
+	}
+}
+
+func UpdateCustomField() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		name := c.Param("name")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the field in the database with the specified name
+		field, err := models.FindCustomField(db, name)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Apply the submitted fields over the existing ones, the name identifies the field and cannot change
+		err = c.Bind(field)
+		if err != nil {
+			return bindError(err)
+		}
+
+		field.Name = name
+
+		// Ensure the resulting object is still valid
+		err = field.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		err = field.Update(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, field)
+	}
+}
+
+func DeleteCustomField() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		name := c.Param("name")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the field in the database with the specified name
+		field, err := models.FindCustomField(db, name)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Attempt to delete the object from the database
+		err = field.Delete(db)
+		if err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+func ListDelegations() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		// Constrain the user to their own delegations if not admin
+		if role == "user" && id != uid {
+			return echo.ErrUnauthorized
+		}
+
+		delegations, err := models.ListDelegations(db, id)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, delegations)
+	}
+}
+
+func CreateDelegation() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.Delegation{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		// Constrain the user to delegating their own authority if not admin
+		if role == "user" && id != uid {
+			return echo.ErrUnauthorized
+		}
+
+		_, err = models.FindUserByID(db, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Prepare a new object to write to the database
+		delegation := &models.Delegation{
+			ManagerID:  id,
+			DelegateID: data.DelegateID,
+			Start:      data.Start,
+			End:        data.End,
+		}
+
+		// Ensure we have all necessary fields to create the object
+		err = delegation.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		err = delegation.Create(db)
+		if err != nil {
+			if errors.Is(err, models.ErrDelegateNotFound) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+
+			return err
+		}
+
+		return c.JSON(http.StatusCreated, delegation)
+	}
+}
+
+func DeleteDelegation() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		did := c.Param("did")
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		// Constrain the user to their own delegations if not admin
+		if role == "user" && id != uid {
+			return echo.ErrUnauthorized
+		}
+
+		delegation, err := models.FindDelegationByID(db, did)
+		if err != nil || delegation.ManagerID != id {
+			if err == nil || errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		err = delegation.Delete(db)
+		if err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+func CreateDifferential() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.Differential{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Prepare a new object to write to the database
+		differential := models.Differential{
+			Name:       data.Name,
+			Weekdays:   data.Weekdays,
+			Start:      data.Start,
+			End:        data.End,
+			Timezone:   data.Timezone,
+			Multiplier: data.Multiplier,
+		}
+
+		// Ensure we have all necessary fields to create the object
+		err = differential.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to write the new object to the database
+		err = differential.Create(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusCreated, differential)
+	}
+}
+
+func ListDifferentials() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		differentials, err := models.ListDifferentials(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, differentials)
+	}
+}
+
+func DeleteDifferential() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		did := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the differential in the database with the specified ID
+		differential, err := models.FindDifferentialByID(db, did)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Attempt to delete the object from the database
+		err = differential.Delete(db)
+		if err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// directoryPositionDays is how far back the shifts giving the positions of coworkers in the directory go
+const directoryPositionDays = 90
+
+func ListDirectory() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var params struct {
+			Search string `query:"q"`
+			TeamID string `query:"team_id"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		since := time.Now().AddDate(0, 0, -directoryPositionDays)
+
+		entries, err := models.ListDirectory(db, params.Search, params.TeamID, since)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, entries)
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/notify"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"net/url"
+)
+
+func RequestEmailChange(notifier notify.Notifier) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.EmailChange{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+		db := c.Get("db").(*gorm.DB)
+
+		// Constrain the user to changing their own address if not admin
+		if role == "user" && id != uid {
+			return echo.ErrUnauthorized
+		}
+
+		user, err := models.FindUserByID(db, id)
+		if err != nil {
+			return echo.ErrNotFound
+		}
+
+		// Prepare a new object to write to the database
+		change := models.EmailChange{
+			UserID: user.ID,
+			Email:  data.Email,
+		}
+
+		// Ensure we have all necessary fields to create the object
+		err = change.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		err = models.CheckEmailAvailable(db, user.ID, change.Email)
+		if err != nil {
+			if errors.Is(err, models.ErrEmailTaken) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+
+			return err
+		}
+
+		// Attempt to write the new object to the database
+		err = change.Create(db)
+		if err != nil {
+			return err
+		}
+
+		// Send the verification link to the new address
+		link := absoluteURL(c, "/email/verify?token="+url.QueryEscape(change.Token))
+
+		err = notifier.Notify(c.Request().Context(), notify.Message{
+			To:      change.Email,
+			Subject: "Verify your new shiftr email address",
+			Event:   notify.EventVerification,
+			Body:    fmt.Sprintf("Follow this link to use this address for the account %q: %s", user.Name, link),
+		})
+		if err != nil {
+			return err
+		}
+
+		// Let the current address know a change was requested
+		if user.Email != "" {
+			err = notifier.Notify(c.Request().Context(), notify.Message{
+				UserID:  user.ID,
+				To:      user.Email,
+				Subject: "Email address change requested",
+				Event:   notify.EventEmailChanged,
+				Body:    fmt.Sprintf("A change of the email address of the account %q to %s was requested", user.Name, change.Email),
+			})
+			if err != nil {
+				c.Logger().Errorf("email change notification: %s", err)
+			}
+		}
+
+		return c.JSON(http.StatusAccepted, echo.Map{
+			"message": "verification email sent",
+		})
+	}
+}
+
+func VerifyEmailChange() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		token := c.QueryParam("token")
+		db := c.Get("db").(*gorm.DB)
+
+		if token == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "token required")
+		}
+
+		// Attempt to find the pending change matching the token
+		change, err := models.FindEmailChangeByToken(db, token)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		user, err := change.Complete(db)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrEmailChangeExpired):
+				return echo.NewHTTPError(http.StatusGone, err.Error())
+			case errors.Is(err, models.ErrEmailTaken):
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		user.Password = ""
+
+		return c.JSON(http.StatusOK, user)
+	}
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/api/service"
+	"github.com/btnmasher/shiftr/health"
+	"github.com/btnmasher/shiftr/i18n"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// invalidFields is the message of an HTTP error answering an object with invalid fields, giving the message
+// and the code of any limit violated by the first, along with the error of each field
+type invalidFields struct {
+	Code    string               `json:"code,omitempty"`
+	Message string               `json:"message"`
+	Errors  []*models.FieldError `json:"errors"`
+}
+
+// HTTPError translates the classes of errors returned by models and services into the HTTP errors they are
+// answered with, so that handlers may return them as they are. Any other error is returned unchanged.
+func HTTPError(err error) error {
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		return err
+	}
+
+	var fields *models.FieldErrors
+	if errors.As(err, &fields) {
+		return fieldsError(fields)
+	}
+
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		return echo.ErrUnauthorized
+	case errors.Is(err, models.ErrNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, models.ErrInvalid):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, models.ErrOverlap):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, models.ErrStale):
+		return echo.NewHTTPError(http.StatusPreconditionFailed, err.Error())
+	case errors.Is(err, health.ErrUnavailable):
+		return echo.NewHTTPError(http.StatusServiceUnavailable, err.Error())
+	}
+
+	return err
+}
+
+// fieldsError answers an object with invalid fields with every field error, as an unprocessable entity when the
+// first violates a limit
+func fieldsError(fields *models.FieldErrors) error {
+	body := &invalidFields{Message: fields.Error(), Errors: fields.Fields}
+
+	var limit *models.ShiftLimitError
+	if errors.As(fields, &limit) {
+		body.Code = limit.Code
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, body)
+	}
+
+	return echo.NewHTTPError(http.StatusBadRequest, body)
+}
+
+// bindError answers a request body which could not be bound, naming the field holding a value of the wrong
+// type when the decoder reports one
+func bindError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Field == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid object")
+	}
+
+	return echo.NewHTTPError(http.StatusBadRequest, &invalidFields{
+		Message: "invalid object",
+		Errors:  []*models.FieldError{{Field: typeErr.Field, Message: expected(typeErr.Type)}},
+	})
+}
+
+// expected describes the JSON values which decode into the type
+func expected(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "must be a string"
+	case reflect.Bool:
+		return "must be true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "must be a whole number"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "must be a positive whole number"
+	case reflect.Float32, reflect.Float64:
+		return "must be a number"
+	case reflect.Slice, reflect.Array:
+		return "must be a list"
+	}
+
+	return "must be an object"
+}
+
+// IdentifyError adds the ID of the request which failed to the body of the HTTP error answering it, so that a
+// failure reported by a client can be found in the logs. Errors other than HTTP errors are answered as the 500
+// Internal Server Error they would be, identified the same way. The error itself is given along with the message
+// when debug is enabled, as it otherwise would be.
+func IdentifyError(err error, rid string, debug bool) error {
+	var he *echo.HTTPError
+	if !errors.As(err, &he) {
+		he = &echo.HTTPError{
+			Code:     http.StatusInternalServerError,
+			Message:  http.StatusText(http.StatusInternalServerError),
+			Internal: err,
+		}
+	} else if internal, ok := he.Internal.(*echo.HTTPError); ok {
+		he = internal
+	}
+
+	if rid == "" {
+		return err
+	}
+
+	message := he.Message
+	if m, ok := message.(string); ok {
+		body := echo.Map{"message": m}
+		if debug {
+			body["error"] = err.Error()
+		}
+
+		message = body
+	}
+
+	data, merr := json.Marshal(message)
+	if merr != nil || len(data) < 2 || data[0] != '{' {
+		return err
+	}
+
+	id, merr := json.Marshal(rid)
+	if merr != nil {
+		return err
+	}
+
+	// The ID is added as the last member of the object, leaving the others in the order they were encoded
+	field := append([]byte(`"request_id":`), id...)
+	if len(data) > 2 {
+		field = append([]byte(","), field...)
+	}
+
+	body := append(append(data[:len(data)-1:len(data)-1], field...), '}')
+
+	return &echo.HTTPError{Code: he.Code, Message: json.RawMessage(body), Internal: he.Internal}
+}
+
+// LocalizeError translates the message of an HTTP error into the locale with the catalog. Messages without a
+// translation, and errors which are not HTTP errors, are returned as is.
+func LocalizeError(err error, catalog *i18n.Catalog, locale string) error {
+	var he *echo.HTTPError
+	if !errors.As(err, &he) {
+		return err
+	}
+
+	if fields, ok := he.Message.(*invalidFields); ok {
+		return &echo.HTTPError{Code: he.Code, Message: localizeFields(fields, catalog, locale), Internal: he.Internal}
+	}
+
+	msg, ok := he.Message.(string)
+	if !ok {
+		return err
+	}
+
+	translated := catalog.Translate(locale, msg)
+	if translated == msg {
+		return err
+	}
+
+	return &echo.HTTPError{Code: he.Code, Message: translated, Internal: he.Internal}
+}
+
+// localizeFields returns a copy of the field errors with their messages translated into the locale with the catalog
+func localizeFields(fields *invalidFields, catalog *i18n.Catalog, locale string) *invalidFields {
+	translated := &invalidFields{
+		Code:    fields.Code,
+		Message: catalog.Translate(locale, fields.Message),
+		Errors:  make([]*models.FieldError, len(fields.Errors)),
+	}
+
+	for i, e := range fields.Errors {
+		translated.Errors[i] = &models.FieldError{
+			Field:   e.Field,
+			Code:    e.Code,
+			Message: catalog.Translate(locale, e.Message),
+		}
+	}
+
+	return translated
+}
+
+// RequestLocale returns the locale negotiated for the request, from its Accept-Language header when the
+// request was refused before it was localized
+func RequestLocale(c echo.Context) string {
+	if locale, ok := c.Get("locale").(string); ok {
+		return locale
+	}
+
+	return requestCatalog(c).Negotiate(c.Request().Header.Get("Accept-Language"))
+}
+
+// requestCatalog returns the catalog the messages of the database serving the request are translated with
+func requestCatalog(c echo.Context) *i18n.Catalog {
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return nil
+	}
+
+	return models.CurrentSettings(db).Catalog
+}
+
+// requestLocation returns the time zone named by tz, or the one negotiated for the request when tz is empty
+func requestLocation(c echo.Context, tz string) (*time.Location, error) {
+	if tz == "" {
+		if loc, ok := c.Get("location").(*time.Location); ok {
+			return loc, nil
+		}
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid time zone")
+	}
+
+	return loc, nil
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+)
+
+// checkIfMatch requires a write to an object to be made against its current version, refusing a request without
+// an If-Match header with 428 Precondition Required, and one not matching the ETag with 412 Precondition Failed
+func checkIfMatch(c echo.Context, etag string) error {
+	header := strings.Join(c.Request().Header.Values("If-Match"), ",")
+	if strings.TrimSpace(header) == "" {
+		return echo.NewHTTPError(http.StatusPreconditionRequired, "If-Match header required")
+	}
+
+	// Weak tags never match, as the comparison of If-Match is strong
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return nil
+		}
+	}
+
+	return models.ErrStale
+}
+
+// setETag sends the ETag of the version of the object in the response
+func setETag(c echo.Context, etag string) {
+	c.Response().Header().Set("ETag", etag)
+}
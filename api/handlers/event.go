@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/middleware"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/api/service"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+func ListEvents() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var params struct {
+			Start time.Time `query:"filter_start"` // RFC33339
+			End   time.Time `query:"filter_end"`   // RFC33339
+			Limit int       `query:"limit"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"invalid parameters")
+		}
+
+		// Only list upcoming events unless a span was requested
+		if params.Start.IsZero() {
+			params.Start = time.Now()
+		}
+
+		// Ensure that the timestamp received isn't malformed
+		if !params.End.IsZero() && params.Start.After(params.End) {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"filter span start time must precede span end time")
+		}
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to list the events from the database
+		events, err := models.ListShifts(db,
+			models.FilterEvents(),
+			models.FilterStatus(models.ShiftPublished),
+			models.FilterStart(params.Start),
+			models.FilterEnd(params.End),
+			models.WithLimit(params.Limit),
+		)
+		if err != nil {
+			return err
+		}
+
+		// Include the remaining capacity of each event
+		err = models.CountSignups(db, events)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, events)
+	}
+}
+
+func SignUp() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		sid := c.Param("id")
+		uid := c.Get("id").(string)
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the event in the database
+		shift, err := models.FindShiftByID(db, sid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Unpublished events cannot be signed up for
+		if shift.Status != models.ShiftPublished {
+			return echo.ErrNotFound
+		}
+
+		// Attempt to take a slot of the event
+		err = shift.SignUp(db, uid)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrNotEvent):
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			case errors.Is(err, models.ErrEventFull), errors.Is(err, models.ErrAlreadySignedUp):
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+
+			return err
+		}
+
+		return c.JSON(http.StatusOK, shift)
+	}
+}
+
+func Withdraw(shifts *service.Shifts) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		sid := c.Param("id")
+		uid := c.Get("id").(string)
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the event in the database
+		shift, err := models.FindShiftByID(db, sid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Attempt to release the slot held by the user
+		promoted, err := shift.Withdraw(db, uid)
+		if err != nil {
+			if errors.Is(err, models.ErrNotSignedUp) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Let any waitlisted users know they received the freed slot
+		middleware.AuditSubjects(c, promoted...)
+		shifts.NotifyPromoted(c.Request().Context(), db, shift, promoted)
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+func ListSignups() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		sid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Ensure the event exists
+		_, err := models.FindShiftByID(db, sid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		signups, err := models.ListSignups(db, sid)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, signups)
+	}
+}
+
+func JoinWaitlist() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		sid := c.Param("id")
+		uid := c.Get("id").(string)
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the event in the database
+		shift, err := models.FindShiftByID(db, sid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Unpublished events cannot be waited for
+		if shift.Status != models.ShiftPublished {
+			return echo.ErrNotFound
+		}
+
+		// Attempt to add the user to the waitlist
+		err = shift.JoinWaitlist(db, uid)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrNotEvent):
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			case errors.Is(err, models.ErrEventNotFull),
+				errors.Is(err, models.ErrAlreadySignedUp),
+				errors.Is(err, models.ErrAlreadyWaiting):
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+
+			return err
+		}
+
+		return c.NoContent(http.StatusCreated)
+	}
+}
+
+func LeaveWaitlist() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		sid := c.Param("id")
+		uid := c.Get("id").(string)
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the event in the database
+		shift, err := models.FindShiftByID(db, sid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Attempt to remove the user from the waitlist
+		err = shift.LeaveWaitlist(db, uid)
+		if err != nil {
+			if errors.Is(err, models.ErrNotWaiting) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+func ListWaitlist() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		sid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Ensure the event exists
+		_, err := models.FindShiftByID(db, sid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		entries, err := models.ListWaitlist(db, sid)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, entries)
+	}
+}
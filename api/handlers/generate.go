@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/utils"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+func GenerateShifts() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var params struct {
+			Week       string `query:"week"` // ISO 8601 week, e.g. 2024-W32
+			TZ         string `query:"tz"`
+			Status     string `query:"status"`
+			LocationID string `query:"location_id"`
+			PositionID string `query:"position_id"`
+			DryRun     bool   `query:"dry_run"`
+		}
+
+		// Collect the submitted parameters from the user
+		err := (&echo.DefaultBinder{}).BindQueryParams(c, &params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		if params.Week == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "week required")
+		}
+
+		loc, err := requestLocation(c, params.TZ)
+		if err != nil {
+			return err
+		}
+
+		start, err := utils.ParseISOWeek(params.Week, loc)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		end := start.AddDate(0, 0, 7)
+
+		// Generated shifts are drafts unless stated otherwise, so they can be reviewed before publishing
+		if params.Status == "" {
+			params.Status = models.ShiftDraft
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		reqs, err := models.ListCoverageRequirements(db, start, end, params.LocationID, params.PositionID)
+		if err != nil {
+			return err
+		}
+
+		shifts := []*models.Shift{}
+		skipped := []importSkip{}
+
+		for _, req := range reqs {
+			open, err := req.OpenShifts(db, start, end)
+			if err != nil {
+				return err
+			}
+
+			for _, shift := range open {
+				shift.Status = params.Status
+
+				err = shift.ValidateFor(db, "admin")
+				if err == nil && !params.DryRun {
+					err = shift.Create(db)
+				}
+
+				if err != nil {
+					skipped = append(skipped, importSkip{Start: &shift.Start, Reason: err.Error()})
+					continue
+				}
+
+				shifts = append(shifts, shift)
+			}
+		}
+
+		return c.JSON(http.StatusOK, echo.Map{
+			"week":      params.Week,
+			"generated": len(shifts),
+			"dry_run":   params.DryRun,
+			"shifts":    shifts,
+			"skipped":   skipped,
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/middleware"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/opaque"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+func CreateGroup() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.Group{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Prepare a new object to write to the database
+		group := models.Group{
+			Name:        data.Name,
+			Description: data.Description,
+			Members:     []models.GroupMember{},
+		}
+
+		// Ensure we have all necessary fields to create the object
+		err = group.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to write the new object to the database
+		err = group.Create(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusCreated, group)
+	}
+}
+
+func ListGroups() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		groups, err := models.ListGroups(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, groups)
+	}
+}
+
+func GetGroup() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		gid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		group, err := models.FindGroupByID(db, gid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		return c.JSON(http.StatusOK, group)
+	}
+}
+
+func UpdateGroup() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		gid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the group in the database with the specified ID
+		group, err := models.FindGroupByID(db, gid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Apply the submitted fields over the existing ones, members are replaced separately
+		err = c.Bind(group)
+		if err != nil {
+			return bindError(err)
+		}
+
+		group.ID = gid
+
+		// Ensure the resulting object is still valid
+		err = group.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		err = group.Update(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, group)
+	}
+}
+
+func DeleteGroup() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		gid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the group in the database with the specified ID
+		group, err := models.FindGroupByID(db, gid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Attempt to delete the object from the database
+		err = group.Delete(db)
+		if err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+func SetGroupMembers() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var data struct {
+			UserIDs []string `json:"user_ids"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect parameters and context values
+		gid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the group in the database with the specified ID
+		group, err := models.FindGroupByID(db, gid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Resolve the submitted user IDs, ensuring each user exists
+		uids := make([]string, len(data.UserIDs))
+		for i, ext := range data.UserIDs {
+			uids[i], err = opaque.Decode(opaque.User, ext)
+			if err == nil {
+				_, err = models.FindUserByID(db, uids[i])
+			}
+
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, opaque.ErrMalformed) {
+					return echo.NewHTTPError(http.StatusBadRequest, "user not found: "+ext)
+				}
+
+				return err
+			}
+		}
+
+		err = group.SetMembers(db, uids)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, group)
+	}
+}
+
+func CreateGroupShifts() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted parameters for binding
+		var params struct {
+			DryRun bool `query:"dry_run"`
+		}
+
+		// Collect the submitted parameters from the user
+		err := (&echo.DefaultBinder{}).BindQueryParams(c, &params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		// Collect the submitted shift every member of the group is given a copy of
+		data := &models.Shift{}
+		err = (&echo.DefaultBinder{}).BindBody(c, data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Group shifts are drafts unless stated otherwise, so they can be reviewed before publishing
+		if data.Status == "" {
+			data.Status = models.ShiftDraft
+		}
+
+		// Collect parameters and context values
+		gid := c.Param("id")
+		role := c.Get("role").(string)
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the group in the database with the specified ID
+		group, err := models.FindGroupByID(db, gid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		shifts := []*models.Shift{}
+		skipped := []importSkip{}
+		uids := []string{}
+
+		for _, uid := range group.MemberIDs() {
+			shift := &models.Shift{
+				UserID:   uid,
+				Start:    data.Start,
+				End:      data.End,
+				Capacity: data.Capacity,
+				Status:   data.Status,
+
+				LocationID: data.LocationID,
+				PositionID: data.PositionID,
+				Color:      data.Color,
+				Visibility: data.Visibility,
+				Metadata:   data.Metadata,
+				Tags:       data.Tags,
+			}
+
+			err = shift.ValidateFor(db, role)
+			if err == nil && !params.DryRun {
+				err = shift.Create(db)
+			}
+
+			if err != nil {
+				person, _ := opaque.Encode(opaque.User, uid)
+				skipped = append(skipped, importSkip{Start: &shift.Start, Person: person, Reason: err.Error()})
+				continue
+			}
+
+			shifts = append(shifts, shift)
+			uids = append(uids, uid)
+		}
+
+		if !params.DryRun {
+			middleware.AuditSubjects(c, uids...)
+		}
+
+		// Annotate the shifts with any holiday and pay differential they fall on
+		err = models.AnnotateShifts(db, shifts)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, echo.Map{
+			"group":   group.ID,
+			"created": len(shifts),
+			"dry_run": params.DryRun,
+			"shifts":  shifts,
+			"skipped": skipped,
+		})
+	}
+}
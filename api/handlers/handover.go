@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/notify"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+// findShift returns the shift with the ID in the :id parameter, translating a missing shift into a 404
+func findShift(c echo.Context, db *gorm.DB) (*models.Shift, error) {
+	shift, err := models.FindShiftByID(db, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, echo.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return shift, nil
+}
+
+func WriteHandover(notifier notify.Notifier) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.Handover{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect context values
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		shift, err := findShift(c, db)
+		if err != nil {
+			return err
+		}
+
+		// Constrain the user to writing the handover of their own shift if not admin
+		if role == "user" && shift.UserID != uid {
+			return echo.ErrUnauthorized
+		}
+
+		// Prepare a new object to write to the database
+		handover := &models.Handover{
+			AuthorID:   uid,
+			Summary:    data.Summary,
+			OpenIssues: data.OpenIssues,
+			FollowUps:  data.FollowUps,
+		}
+
+		// Ensure we have all necessary fields to create the object
+		err = handover.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		next, err := shift.WriteHandover(db, handover)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrNoHandoverPlace), errors.Is(err, models.ErrNoNextShift):
+				return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+			case errors.Is(err, models.ErrHandoverAcknowledged):
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+
+			return err
+		}
+
+		// Let the incoming worker know there is a handover waiting for them
+		incoming, err := models.FindUserByID(db, next.UserID)
+		if err == nil {
+			l := models.FindLocalization(db, incoming.ID)
+			err = notifier.Notify(c.Request().Context(), notify.Message{
+				UserID:  incoming.ID,
+				To:      incoming.Email,
+				Subject: "Shift handover waiting",
+				Event:   notify.EventHandover,
+				Locale:  l.Locale,
+				Body: fmt.Sprintf("A handover note has been left for your shift starting %s:\n\n%s",
+					l.Format(next.Start), handover.Summary),
+			})
+		}
+		if err != nil {
+			c.Logger().Errorf("handover notification: %s", err)
+		}
+
+		return c.JSON(http.StatusOK, handover)
+	}
+}
+
+func GetHandover() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect context values
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		shift, err := findShift(c, db)
+		if err != nil {
+			return err
+		}
+
+		handover, err := models.FindHandover(db, shift.ID)
+		if err != nil {
+			if errors.Is(err, models.ErrHandoverNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Constrain the user to the handovers they wrote or received if not admin
+		if role == "user" && shift.UserID != uid {
+			next, err := models.FindShiftByID(db, handover.NextShiftID)
+			if err != nil || next.UserID != uid {
+				return echo.ErrUnauthorized
+			}
+		}
+
+		return c.JSON(http.StatusOK, handover)
+	}
+}
+
+func GetIncomingHandover() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect context values
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		shift, err := findShift(c, db)
+		if err != nil {
+			return err
+		}
+
+		// Constrain the user to the handovers for their own shifts if not admin
+		if role == "user" && shift.UserID != uid {
+			return echo.ErrUnauthorized
+		}
+
+		handover, err := models.FindIncomingHandover(db, shift.ID)
+		if err != nil {
+			if errors.Is(err, models.ErrHandoverNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		return c.JSON(http.StatusOK, handover)
+	}
+}
+
+func AcknowledgeHandover() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect context values
+		db := c.Get("db").(*gorm.DB)
+		uid := c.Get("id").(string)
+
+		shift, err := findShift(c, db)
+		if err != nil {
+			return err
+		}
+
+		handover, err := models.FindIncomingHandover(db, shift.ID)
+		if err != nil {
+			if errors.Is(err, models.ErrHandoverNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Only the incoming worker can acknowledge, admins included
+		err = handover.Acknowledge(db, shift, uid)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrNotIncomingShiftWorker):
+				return echo.NewHTTPError(http.StatusForbidden, err.Error())
+			case errors.Is(err, models.ErrHandoverAcknowledged):
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+
+			return err
+		}
+
+		return c.JSON(http.StatusOK, handover)
+	}
+}
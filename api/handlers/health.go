@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"github.com/btnmasher/shiftr/health"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout bounds how long the database is given to answer a readiness check
+const readinessTimeout = 2 * time.Second
+
+func Readiness(reg *health.Registry) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		status, database, code := "ok", "ok", http.StatusOK
+
+		// The database is the only dependency the server cannot serve without
+		sqlDB, err := db.DB()
+		if err == nil {
+			ctx, cancel := context.WithTimeout(c.Request().Context(), readinessTimeout)
+			defer cancel()
+
+			err = sqlDB.PingContext(ctx)
+		}
+
+		if err != nil {
+			c.Logger().Errorf("readiness check: %s", err)
+			status, database, code = "unavailable", "unavailable", http.StatusServiceUnavailable
+		} else if reg.Degraded() {
+			status = "degraded"
+		}
+
+		return c.JSON(code, echo.Map{
+			"status":       status,
+			"database":     database,
+			"dependencies": reg.Statuses(),
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// checkHolidayLocation ensures the location a holiday is observed at exists
+func checkHolidayLocation(db *gorm.DB, lid string) error {
+	if lid == "" {
+		return nil
+	}
+
+	_, err := models.FindLocationByID(db, lid)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusBadRequest, "location not found")
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func CreateHoliday() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.Holiday{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Prepare a new object to write to the database
+		holiday := models.Holiday{
+			Name:       data.Name,
+			Date:       data.Date,
+			Timezone:   data.Timezone,
+			LocationID: data.LocationID,
+			Multiplier: data.Multiplier,
+		}
+
+		// Ensure we have all necessary fields to create the object
+		err = holiday.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		err = checkHolidayLocation(db, holiday.LocationID)
+		if err != nil {
+			return err
+		}
+
+		// Attempt to write the new object to the database
+		err = holiday.Create(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusCreated, holiday)
+	}
+}
+
+func ListHolidays() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var params struct {
+			Start      time.Time `query:"filter_start"` // RFC33339
+			End        time.Time `query:"filter_end"`   // RFC33339
+			LocationID string    `query:"location_id"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		holidays, err := models.ListHolidays(db, params.Start, params.End, params.LocationID)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, holidays)
+	}
+}
+
+func UpdateHoliday() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.Holiday{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect parameters and context values
+		hid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the holiday in the database with the specified ID
+		holiday, err := models.FindHolidayByID(db, hid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Apply only the submitted fields, clearing the location requires deleting the holiday
+		if data.Name != "" {
+			holiday.Name = data.Name
+		}
+
+		if data.Date != "" {
+			holiday.Date = data.Date
+		}
+
+		if data.Timezone != "" {
+			holiday.Timezone = data.Timezone
+		}
+
+		if data.LocationID != "" {
+			holiday.LocationID = data.LocationID
+		}
+
+		if data.Multiplier != 0 {
+			holiday.Multiplier = data.Multiplier
+		}
+
+		// Ensure the resulting object is still valid
+		err = holiday.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		err = checkHolidayLocation(db, holiday.LocationID)
+		if err != nil {
+			return err
+		}
+
+		err = holiday.Update(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, holiday)
+	}
+}
+
+func DeleteHoliday() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		hid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the holiday in the database with the specified ID
+		holiday, err := models.FindHolidayByID(db, hid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Attempt to delete the object from the database
+		err = holiday.Delete(db)
+		if err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,538 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/ical"
+	"github.com/btnmasher/shiftr/migrate"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	maxImportSize        = 10 << 20 // bytes
+	maxImportOccurrences = 1000     // per event
+	importFetchTimeout   = time.Second * 30
+	maxImportRedirects   = 5
+	defImportHorizon     = 365 // days
+)
+
+// importSkip describes an event occurrence or export row, or one of its people, which could not be imported
+type importSkip struct {
+	UID    string     `json:"uid,omitempty"`
+	Row    int        `json:"row,omitempty"`
+	Start  *time.Time `json:"start,omitempty"`
+	Person string     `json:"person,omitempty"`
+	Reason string     `json:"reason"`
+}
+
+func ImportShifts() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted parameters for binding
+		var params struct {
+			URL     string `query:"url"`
+			Status  string `query:"status"`
+			Horizon int    `query:"horizon"`
+			DryRun  bool   `query:"dry_run"`
+		}
+
+		// Collect the submitted parameters from the user, the feed itself is read separately
+		err := (&echo.DefaultBinder{}).BindQueryParams(c, &params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		if params.Horizon <= 0 {
+			params.Horizon = defImportHorizon
+		}
+
+		// The feed is either uploaded as the request body or fetched from the given URL
+		body := c.Request().Body
+		if params.URL != "" {
+			feed, err := url.Parse(params.URL)
+			if err != nil || (feed.Scheme != "http" && feed.Scheme != "https" && feed.Scheme != "webcal") {
+				return echo.NewHTTPError(http.StatusBadRequest, "feed url must be http, https or webcal")
+			}
+
+			body, err = fetchFeed(c, feed)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+			}
+		}
+		defer body.Close()
+
+		cal, err := ical.Decode(&limitedReader{r: body, left: maxImportSize})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		users := newUserResolver(db)
+		horizon := time.Now().AddDate(0, 0, params.Horizon)
+
+		shifts := []*models.Shift{}
+		skipped := []importSkip{}
+
+		for i := range cal.Events {
+			event := &cal.Events[i]
+
+			if event.Start.IsZero() || event.End.IsZero() {
+				skipped = append(skipped, importSkip{UID: event.UID, Reason: "event has no start or end time"})
+				continue
+			}
+
+			// Attendees work the shift, an event without any is worked by its organizer
+			people := event.Attendees
+			if len(people) == 0 && event.Organizer != nil {
+				people = []ical.Person{*event.Organizer}
+			}
+
+			var assignees []*models.User
+			for _, person := range people {
+				user, err := users.resolve(person)
+				if err != nil {
+					if !errors.Is(err, gorm.ErrRecordNotFound) {
+						return err
+					}
+
+					skipped = append(skipped, importSkip{UID: event.UID, Person: personName(person), Reason: "no matching user"})
+					continue
+				}
+
+				assignees = append(assignees, user)
+			}
+
+			if len(people) == 0 {
+				skipped = append(skipped, importSkip{UID: event.UID, Reason: "event has no organizer or attendees"})
+			}
+
+			length := event.End.Sub(event.Start)
+
+			for _, start := range event.Occurrences(horizon, maxImportOccurrences) {
+				start := start
+
+				for _, user := range assignees {
+					shift := &models.Shift{
+						UserID: user.ID,
+						Start:  start,
+						End:    start.Add(length),
+						Status: params.Status,
+					}
+
+					err = shift.ValidateFor(db, "admin")
+					if err == nil && !params.DryRun {
+						err = shift.Create(db)
+					}
+
+					if err != nil {
+						skipped = append(skipped, importSkip{UID: event.UID, Start: &start, Person: user.Name, Reason: err.Error()})
+						continue
+					}
+
+					shifts = append(shifts, shift)
+				}
+			}
+		}
+
+		return c.JSON(http.StatusOK, echo.Map{
+			"imported": len(shifts),
+			"dry_run":  params.DryRun,
+			"shifts":   shifts,
+			"skipped":  skipped,
+		})
+	}
+}
+
+func MigrateShifts() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted parameters for binding
+		var params struct {
+			TZ            string `query:"tz"`
+			Status        string `query:"status"`
+			DryRun        bool   `query:"dry_run"`
+			CreateMissing bool   `query:"create_missing"`
+		}
+
+		// Collect the submitted parameters from the user, the export itself is read separately
+		err := (&echo.DefaultBinder{}).BindQueryParams(c, &params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		format, err := migrate.Lookup(c.Param("format"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Exports from these tools hold local times, read in the given zone
+		loc, err := requestLocation(c, params.TZ)
+		if err != nil {
+			return err
+		}
+
+		records, rowErrs, err := format.Parse(io.LimitReader(c.Request().Body, maxImportSize), loc)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		users := newUserResolver(db)
+		places := &placeResolver{db: db, create: params.CreateMissing, dryRun: params.DryRun}
+
+		shifts := []*models.Shift{}
+		skipped := []importSkip{}
+
+		for _, rowErr := range rowErrs {
+			skipped = append(skipped, importSkip{Row: rowErr.Row, Reason: rowErr.Err})
+		}
+
+		for _, rec := range records {
+			rec := rec
+			person := ical.Person{Name: rec.Name, Email: rec.Email}
+
+			user, err := users.resolve(person)
+			if err != nil {
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					return err
+				}
+
+				skipped = append(skipped, importSkip{Row: rec.Row, Person: personName(person), Reason: "no matching user"})
+				continue
+			}
+
+			lid, pid, err := places.resolve(rec.Location, rec.Position)
+			if err != nil {
+				if !errors.Is(err, errUnknownPlace) {
+					return err
+				}
+
+				skipped = append(skipped, importSkip{Row: rec.Row, Person: user.Name, Reason: err.Error()})
+				continue
+			}
+
+			shift := &models.Shift{
+				UserID:     user.ID,
+				Start:      rec.Start,
+				End:        rec.End,
+				Status:     params.Status,
+				LocationID: lid,
+				PositionID: pid,
+			}
+
+			err = shift.ValidateFor(db, "admin")
+			if err == nil && !params.DryRun {
+				err = shift.Create(db)
+			}
+
+			if err != nil {
+				skipped = append(skipped, importSkip{Row: rec.Row, Start: &rec.Start, Person: user.Name, Reason: err.Error()})
+				continue
+			}
+
+			shifts = append(shifts, shift)
+		}
+
+		return c.JSON(http.StatusOK, echo.Map{
+			"format":   format.Name,
+			"imported": len(shifts),
+			"dry_run":  params.DryRun,
+			"shifts":   shifts,
+			"skipped":  skipped,
+		})
+	}
+}
+
+// fetchFeed retrieves an external ICS feed over HTTP(S), from public addresses only so that the server cannot be
+// made to reach the services of its own network, reading at most maxImportSize bytes of it
+func fetchFeed(c echo.Context, feed *url.URL) (io.ReadCloser, error) {
+	u := *feed
+
+	// webcal:// is a widely used alias for http:// on calendar subscription links
+	if u.Scheme == "webcal" {
+		u.Scheme = "http"
+	}
+
+	req, err := http.NewRequestWithContext(c.Request().Context(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch feed: %s", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("could not fetch feed: %s", resp.Status)
+	}
+
+	if resp.ContentLength > maxImportSize {
+		resp.Body.Close()
+		return nil, fmt.Errorf("could not fetch feed: larger than %d bytes", maxImportSize)
+	}
+
+	return resp.Body, nil
+}
+
+// feedClient fetches feeds, connecting to public addresses only, whether the feed's or those it redirects to, and
+// never through a proxy, which would be connected to in their place
+var feedClient = &http.Client{
+	Timeout: importFetchTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: importFetchTimeout,
+			Control: dialPublic,
+		}).DialContext,
+		TLSHandshakeTimeout:   importFetchTimeout,
+		ResponseHeaderTimeout: importFetchTimeout,
+		MaxIdleConns:          1,
+		IdleConnTimeout:       importFetchTimeout,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxImportRedirects {
+			return fmt.Errorf("more than %d redirects", maxImportRedirects)
+		}
+
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return fmt.Errorf("redirected to %s", req.URL.Scheme)
+		}
+
+		return nil
+	},
+}
+
+// nonPublicNets are the networks feeds may not be fetched from: this host, private networks and those reserved
+var nonPublicNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",      // this network
+		"10.0.0.0/8",     // private
+		"100.64.0.0/10",  // carrier-grade NAT
+		"127.0.0.0/8",    // loopback
+		"169.254.0.0/16", // link-local, including cloud metadata services
+		"172.16.0.0/12",  // private
+		"192.0.0.0/24",   // protocol assignments
+		"192.168.0.0/16", // private
+		"198.18.0.0/15",  // benchmarking
+		"224.0.0.0/4",    // multicast
+		"240.0.0.0/4",    // reserved and broadcast
+		"::/128",         // unspecified
+		"::1/128",        // loopback
+		"64:ff9b::/96",   // IPv4 translation, which may reach any of the above
+		"64:ff9b:1::/48", // local IPv4 translation
+		"fc00::/7",       // unique local
+		"fe80::/10",      // link-local
+		"ff00::/8",       // multicast
+	} {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets
+}()
+
+// publicIP reports whether the address is reachable on the internet rather than only by this host or its network
+func publicIP(ip net.IP) bool {
+	// IPv4 addresses mapped into IPv6 are checked as IPv4
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+
+	for _, n := range nonPublicNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// dialPublic refuses connections to addresses which are not public, given the address as resolved, so that a name
+// resolving to one is refused as well
+func dialPublic(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIP(ip) {
+		return fmt.Errorf("%s is not a public address", host)
+	}
+
+	return nil
+}
+
+// limitedReader fails reads past the bytes left, so a feed too large is refused rather than imported in part
+type limitedReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.left <= 0 {
+		// A feed of exactly the limit ends here
+		var next [1]byte
+
+		n, err := l.r.Read(next[:])
+		if n == 0 && err != nil {
+			return 0, err
+		}
+
+		return 0, fmt.Errorf("feed larger than %d bytes", maxImportSize)
+	}
+
+	if int64(len(p)) > l.left {
+		p = p[:l.left]
+	}
+
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+
+	return n, err
+}
+
+// userResolver maps imported people to users, by email address and then by login name,
+// remembering each result so people appearing repeatedly are looked up once
+type userResolver struct {
+	db    *gorm.DB
+	cache map[ical.Person]*models.User
+}
+
+func newUserResolver(db *gorm.DB) *userResolver {
+	return &userResolver{db: db, cache: make(map[ical.Person]*models.User)}
+}
+
+func (r *userResolver) resolve(p ical.Person) (*models.User, error) {
+	if user, ok := r.cache[p]; ok {
+		if user == nil {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return user, nil
+	}
+
+	var (
+		user *models.User
+		err  = gorm.ErrRecordNotFound
+	)
+
+	if p.Email != "" {
+		user, err = models.FindUserByEmail(r.db, p.Email)
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) && p.Name != "" {
+		user, err = models.FindUserByName(r.db, p.Name)
+	}
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			r.cache[p] = nil
+		}
+		return nil, err
+	}
+
+	r.cache[p] = user
+
+	return user, nil
+}
+
+func personName(p ical.Person) string {
+	if p.Email != "" {
+		return strings.TrimSpace(p.Name + " <" + p.Email + ">")
+	}
+
+	return p.Name
+}
+
+// placeResolver maps imported location and position names to their IDs, optionally creating
+// the ones which do not exist yet
+type placeResolver struct {
+	db        *gorm.DB
+	create    bool // create missing locations and positions
+	dryRun    bool // report missing ones as created without writing them
+	locations map[string]string
+	positions map[string]string
+}
+
+// errUnknownPlace is returned for names with no matching location or position which were not created
+var errUnknownPlace = errors.New("not found")
+
+// resolve returns the IDs of the named location and position, empty names resolving to empty IDs
+func (r *placeResolver) resolve(location, position string) (string, string, error) {
+	if r.locations == nil {
+		r.locations = make(map[string]string)
+		r.positions = make(map[string]string)
+	}
+
+	lid, err := r.lookup(r.locations, location, func() (string, error) {
+		loc, err := models.FindLocationByName(r.db, location)
+		if errors.Is(err, gorm.ErrRecordNotFound) && r.create && !r.dryRun {
+			loc = &models.Location{Name: location}
+			err = loc.Create(r.db)
+		}
+		return loc.ID, err
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("location %q %w", location, err)
+	}
+
+	pid, err := r.lookup(r.positions, position, func() (string, error) {
+		pos, err := models.FindPositionByName(r.db, position)
+		if errors.Is(err, gorm.ErrRecordNotFound) && r.create && !r.dryRun {
+			pos = &models.Position{Name: position}
+			err = pos.Create(r.db)
+		}
+		return pos.ID, err
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("position %q %w", position, err)
+	}
+
+	return lid, pid, nil
+}
+
+// lookup returns the cached ID for the name, finding it with find on first use
+func (r *placeResolver) lookup(cache map[string]string, name string, find func() (string, error)) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+
+	if id, ok := cache[name]; ok {
+		return id, nil
+	}
+
+	id, err := find()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if !r.create {
+			return "", errUnknownPlace
+		}
+		err = nil // a dry run would have created it
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	cache[name] = id
+
+	return id, nil
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"bytes"
+	"github.com/labstack/echo/v4"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"127.1.2.3", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"172.31.255.255", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"169.254.169.254", false},
+		{"::ffff:169.254.169.254", false},
+		{"64:ff9b::a9fe:a9fe", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+	}
+
+	for _, tt := range tests {
+		if got := publicIP(net.ParseIP(tt.ip)); got != tt.public {
+			t.Errorf("%s: got public %t, want %t", tt.ip, got, tt.public)
+		}
+	}
+}
+
+func TestFetchFeedRefusesLocalAddresses(t *testing.T) {
+	fetched := false
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched = true
+		w.Write([]byte("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"))
+	}))
+	defer feed.Close()
+
+	port := feed.URL[strings.LastIndexByte(feed.URL, ':'):]
+
+	for _, target := range []string{
+		feed.URL,
+		"http://localhost" + port,
+		"webcal://127.0.0.1" + port,
+		"http://[::1]" + port,
+	} {
+		u, err := url.Parse(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())
+
+		body, err := fetchFeed(c, u)
+		if err == nil {
+			body.Close()
+			t.Errorf("%s: fetched, want refused", target)
+		}
+	}
+
+	if fetched {
+		t.Error("feed was requested from a local address")
+	}
+}
+
+func TestLimitedReader(t *testing.T) {
+	exact := bytes.Repeat([]byte("a"), maxImportSize)
+
+	data, err := ioutil.ReadAll(&limitedReader{r: bytes.NewReader(exact), left: maxImportSize})
+	if err != nil || len(data) != maxImportSize {
+		t.Errorf("reading the limit: got %d bytes and %v", len(data), err)
+	}
+
+	_, err = ioutil.ReadAll(&limitedReader{r: bytes.NewReader(append(exact, 'a')), left: maxImportSize})
+	if err == nil {
+		t.Error("reading past the limit: got no error")
+	}
+}
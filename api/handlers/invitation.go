@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/notify"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// readUserImport reads the rows of a CSV user import, whose header names the name, email, role and team columns
+func readUserImport(r io.Reader) ([]*models.UserImport, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read header: %s", err)
+	}
+
+	cols := map[string]int{"name": -1, "email": -1, "role": -1, "team": -1}
+	for i, col := range header {
+		col = strings.ToLower(strings.TrimSpace(col))
+		if _, ok := cols[col]; ok {
+			cols[col] = i
+		}
+	}
+
+	if cols["name"] < 0 {
+		return nil, errors.New("import has no name column")
+	}
+
+	rows := []*models.UserImport{}
+
+	for row := 2; ; row++ {
+		line, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %s", row, err)
+		}
+
+		get := func(col string) string {
+			if i := cols[col]; i >= 0 && i < len(line) {
+				return strings.TrimSpace(line[i])
+			}
+			return ""
+		}
+
+		// Skip blank lines
+		if strings.TrimSpace(strings.Join(line, "")) == "" {
+			continue
+		}
+
+		rows = append(rows, &models.UserImport{
+			Row:   row,
+			Name:  get("name"),
+			Email: get("email"),
+			Role:  get("role"),
+			Team:  get("team"),
+		})
+	}
+
+	return rows, nil
+}
+
+func ImportUsers(notifier notify.Notifier) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted parameters for binding
+		var params struct {
+			DryRun bool `query:"dry_run"`
+			Invite bool `query:"invite"`
+		}
+
+		// Collect the submitted parameters from the user, the roster itself is read separately
+		err := (&echo.DefaultBinder{}).BindQueryParams(c, &params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		rows, err := readUserImport(io.LimitReader(c.Request().Body, maxImportSize))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		if len(rows) == 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "users required")
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		results, err := models.ImportUsers(db, rows, params.DryRun, params.Invite)
+		if err != nil {
+			if errors.Is(err, models.ErrImportFailed) {
+				return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+					"dry_run": params.DryRun,
+					"results": results,
+				})
+			}
+
+			return err
+		}
+
+		// Invitations are only sent once every user has been created
+		invited := 0
+		for _, result := range results {
+			if result.Invitation == nil {
+				continue
+			}
+
+			link := absoluteURL(c, "/invitation?token="+url.QueryEscape(result.Invitation.Token))
+
+			err = notifier.Notify(c.Request().Context(), notify.Message{
+				UserID:  result.User.ID,
+				To:      result.User.Email,
+				Subject: "You have been invited to shiftr",
+				Event:   notify.EventInvitation,
+				Body:    fmt.Sprintf("An account %q has been created for you. Follow this link to choose your password: %s", result.User.Name, link),
+			})
+			if err != nil {
+				c.Logger().Errorf("user import invitation: %s", err)
+				continue
+			}
+
+			invited++
+		}
+
+		return c.JSON(http.StatusOK, echo.Map{
+			"dry_run": params.DryRun,
+			"invited": invited,
+			"results": results,
+		})
+	}
+}
+
+func AcceptInvitation() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var data struct {
+			Password string `json:"password"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect parameters and context values
+		token := c.QueryParam("token")
+		db := c.Get("db").(*gorm.DB)
+
+		if token == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "token required")
+		}
+
+		// Attempt to find the invitation matching the token
+		invitation, err := models.FindInvitationByToken(db, token)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		if invitation.Expired() {
+			return echo.NewHTTPError(http.StatusGone, "invitation expired")
+		}
+
+		user, err := invitation.Accept(db, data.Password)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalid) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+
+			return err
+		}
+
+		user.Password = ""
+
+		return c.JSON(http.StatusOK, user)
+	}
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/api/service"
+	"github.com/btnmasher/shiftr/export"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// labelParams is a temporary struct to hold the user submitted printing options shared by the label endpoints
+type labelParams struct {
+	Format string `query:"format"` // text or escpos
+	Width  int    `query:"width"`  // characters per line
+	TZ     string `query:"tz"`
+}
+
+// Label widths accepted, from narrow label printers to the widest receipt printers
+const (
+	minLabelWidth = 16
+	maxLabelWidth = 64
+)
+
+// bindLabel collects and checks the printing options along with the time zone times are printed in
+func bindLabel(c echo.Context, opts *labelParams) (*time.Location, error) {
+	err := c.Bind(opts)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+	}
+
+	switch opts.Format {
+	case "":
+		opts.Format = "text"
+	case "text", "escpos":
+	default:
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "format must be text or escpos")
+	}
+
+	if opts.Width == 0 {
+		opts.Width = export.LabelWidth80mm
+	}
+
+	if opts.Width < minLabelWidth || opts.Width > maxLabelWidth {
+		return nil, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("width must be between %d and %d", minLabelWidth, maxLabelWidth))
+	}
+
+	return requestLocation(c, opts.TZ)
+}
+
+// writeLabel renders the label in the requested format
+func writeLabel(c echo.Context, label export.Label, opts *labelParams) error {
+	if opts.Format == "escpos" {
+		c.Response().Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
+		c.Response().WriteHeader(http.StatusOK)
+
+		return export.WriteLabelESCPOS(c.Response(), label, opts.Width)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextPlainCharsetUTF8)
+	c.Response().WriteHeader(http.StatusOK)
+
+	return export.WriteLabelText(c.Response(), label, opts.Width)
+}
+
+// shiftHours formats the times the shift spans in the time zone
+func shiftHours(shift *models.Shift, loc *time.Location) string {
+	return shift.Start.In(loc).Format("15:04") + "-" + shift.End.In(loc).Format("15:04")
+}
+
+func ShiftLabel() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		params := &labelParams{}
+		loc, err := bindLabel(c, params)
+		if err != nil {
+			return err
+		}
+
+		// Collect parameters and context values
+		sid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		// Attempt to find the shift in the database
+		shift, err := models.FindShiftByID(db, sid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Constrain the user to printing the shifts they may view, as with fetching them
+		if role == "user" {
+			if uid != shift.UserID {
+				manages, err := models.Manages(db, uid, shift.UserID)
+				if err != nil {
+					return err
+				}
+
+				if !manages {
+					return echo.ErrUnauthorized
+				}
+			}
+
+			if !service.UserVisible(shift) {
+				return echo.ErrNotFound
+			}
+		}
+
+		// The shift is identified by the same ID the API exposes
+		ext, _, err := shift.ExternalIDs()
+		if err != nil {
+			return err
+		}
+
+		label := export.Label{
+			Title:    "Open shift",
+			Subtitle: shift.Start.In(loc).Format("Mon 2 Jan 2006"),
+			Rows:     []export.LabelRow{{Left: "Time", Right: shiftHours(shift, loc)}},
+			Footer:   ext,
+		}
+
+		if shift.UserID != "" {
+			user, err := models.FindUserByID(db, shift.UserID)
+			if err == nil {
+				label.Title = user.Name
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+
+		if shift.LocationID != "" {
+			location, err := models.FindLocationByID(db, shift.LocationID)
+			if err == nil {
+				label.Rows = append(label.Rows, export.LabelRow{Left: "Location", Right: location.Name})
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+
+		if shift.PositionID != "" {
+			position, err := models.FindPositionByID(db, shift.PositionID)
+			if err == nil {
+				label.Rows = append(label.Rows, export.LabelRow{Left: "Position", Right: position.Name})
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+
+		return writeLabel(c, label, params)
+	}
+}
+
+func RosterLabel() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var params struct {
+			Date       string `query:"date"` // YYYY-MM-DD, defaults to today
+			LocationID string `query:"location_id"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		opts := &labelParams{}
+		loc, err := bindLabel(c, opts)
+		if err != nil {
+			return err
+		}
+
+		day := time.Now().In(loc)
+		if params.Date != "" {
+			day, err = time.ParseInLocation("2006-01-02", params.Date, loc)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "date must be formatted YYYY-MM-DD")
+			}
+		}
+
+		start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		label := export.Label{
+			Title:    "Roster",
+			Subtitle: start.Format("Mon 2 Jan 2006"),
+		}
+
+		if params.LocationID != "" {
+			location, err := models.FindLocationByID(db, params.LocationID)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return echo.NewHTTPError(http.StatusBadRequest, "location not found")
+				}
+
+				return err
+			}
+
+			label.Subtitle = location.Name + ", " + label.Subtitle
+		}
+
+		// The roster is the published shifts starting during the day
+		shifts, err := models.ListShifts(db,
+			models.FilterStart(start),
+			models.FilterStartsBefore(start.AddDate(0, 0, 1)),
+			models.FilterStatus(models.ShiftPublished),
+			models.FilterLocationID(params.LocationID),
+		)
+		if err != nil {
+			return err
+		}
+
+		uids := make([]string, 0, len(shifts))
+		for _, shift := range shifts {
+			uids = append(uids, shift.UserID)
+		}
+
+		names, err := models.FindUserNames(db, uids)
+		if err != nil {
+			return err
+		}
+
+		positions, err := models.ListPositions(db)
+		if err != nil {
+			return err
+		}
+
+		titles := make(map[string]string, len(positions))
+		for _, position := range positions {
+			titles[position.ID] = position.Name
+		}
+
+		for _, shift := range shifts {
+			name, ok := names[shift.UserID]
+			if !ok {
+				name = "Open shift"
+			}
+
+			label.Rows = append(label.Rows, export.LabelRow{
+				Left:  shiftHours(shift, loc) + " " + name,
+				Right: titles[shift.PositionID],
+			})
+		}
+
+		label.Footer = fmt.Sprintf("Shifts: %d, printed %s", len(shifts), time.Now().In(loc).Format("2006-01-02 15:04"))
+
+		return writeLabel(c, label, opts)
+	}
+}
@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/notify"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+func CreateLeavePolicy() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.LeavePolicy{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Prepare a new object to write to the database
+		policy := models.LeavePolicy{
+			Name:         data.Name,
+			AccrualHours: data.AccrualHours,
+			Carryover:    data.Carryover,
+		}
+
+		// Ensure we have all necessary fields to create the object
+		err = policy.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to write the new object to the database
+		err = policy.Create(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusCreated, policy)
+	}
+}
+
+func ListLeavePolicies() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		policies, err := models.ListLeavePolicies(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, policies)
+	}
+}
+
+func DeleteLeavePolicy() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		pid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the policy in the database with the specified ID
+		policy, err := models.FindLeavePolicyByID(db, pid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Attempt to delete the object from the database
+		err = policy.Delete(db)
+		if err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+func SetLeaveAccount() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.LeaveAccount{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		_, err = models.FindUserByID(db, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		_, err = models.FindLeavePolicyByID(db, data.PolicyID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.NewHTTPError(http.StatusBadRequest, "leave policy not found")
+			}
+
+			return err
+		}
+
+		// Accrual starts from today unless given
+		account := &models.LeaveAccount{
+			UserID:   id,
+			PolicyID: data.PolicyID,
+			Start:    data.Start,
+		}
+
+		if account.Start.IsZero() {
+			account.Start = time.Now()
+		}
+
+		err = account.Save(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, account)
+	}
+}
+
+func GetLeaveBalance() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		// Constrain the user to their own balance if not admin
+		if role == "user" && id != uid {
+			return echo.ErrUnauthorized
+		}
+
+		balance, err := models.ComputeLeaveBalance(db, id, time.Now())
+		if err != nil {
+			if errors.Is(err, models.ErrNoLeaveAccount) {
+				return echo.NewHTTPError(http.StatusNotFound, err.Error())
+			}
+
+			return err
+		}
+
+		return c.JSON(http.StatusOK, balance)
+	}
+}
+
+func ListTimeOff() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		// Constrain the user to their own requests if not admin
+		if role == "user" && id != uid {
+			return echo.ErrUnauthorized
+		}
+
+		requests, err := models.ListTimeOff(db, id)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, requests)
+	}
+}
+
+func RequestTimeOff(notifier notify.Notifier) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.TimeOff{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		// Constrain the user to requesting their own time off if not admin
+		if role == "user" && id != uid {
+			return echo.ErrUnauthorized
+		}
+
+		// Prepare a new object to write to the database
+		request := &models.TimeOff{
+			UserID: id,
+			Start:  data.Start,
+			End:    data.End,
+			Hours:  data.Hours,
+			Reason: data.Reason,
+		}
+
+		// Ensure we have all necessary fields to create the object
+		err = request.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		err = request.Request(db)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrNoLeaveAccount):
+				return echo.NewHTTPError(http.StatusNotFound, err.Error())
+			case errors.Is(err, models.ErrInsufficientBalance):
+				return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+			}
+
+			return err
+		}
+
+		notifyApprovers(c, db, notifier, request.UserID, notify.EventTimeOffApproval, "Time off awaiting approval",
+			func(l *models.Localization) string {
+				return fmt.Sprintf("%.2f hours of time off from %s to %s are awaiting your approval",
+					request.Hours, l.Format(request.Start), l.Format(request.End))
+			})
+
+		return c.JSON(http.StatusCreated, request)
+	}
+}
+
+func ListPendingTimeOff() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect context values
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		requests, err := models.ListPendingTimeOff(db)
+		if err != nil {
+			return err
+		}
+
+		// Constrain the requests to those the current user may approve
+		approvable := []*models.TimeOff{}
+		for _, request := range requests {
+			ok, err := models.CanApprove(db, uid, role, request.UserID)
+			if err != nil {
+				return err
+			}
+
+			if ok {
+				approvable = append(approvable, request)
+			}
+		}
+
+		return c.JSON(http.StatusOK, approvable)
+	}
+}
+
+// DecideTimeOff approves or rejects the time off request in the :id parameter, which the requester's
+// manager, their delegate, and admins may do
+func DecideTimeOff(approve bool, notifier notify.Notifier) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var data struct {
+			Note string `json:"note"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect parameters and context values
+		tid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		request, err := models.FindTimeOffByID(db, tid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		ok, err := models.CanApprove(db, uid, role, request.UserID)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return echo.ErrUnauthorized
+		}
+
+		if approve {
+			err = request.Approve(db, uid, data.Note)
+		} else {
+			err = request.Reject(db, uid, data.Note)
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrTimeOffDecided):
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			case errors.Is(err, models.ErrInsufficientBalance):
+				return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+			}
+
+			return err
+		}
+
+		// Let the requester know the outcome
+		user, err := models.FindUserByID(db, request.UserID)
+		if err == nil {
+			l := models.FindLocalization(db, user.ID)
+			err = notifier.Notify(c.Request().Context(), notify.Message{
+				UserID:  user.ID,
+				To:      user.Email,
+				Subject: "Time off request reviewed",
+				Event:   notify.EventTimeOffReviewed,
+				Locale:  l.Locale,
+				Body: fmt.Sprintf("Your time off from %s to %s was %s",
+					l.Format(request.Start), l.Format(request.End), request.Status),
+			})
+		}
+		if err != nil {
+			c.Logger().Errorf("time off approval: %s", err)
+		}
+
+		return c.JSON(http.StatusOK, request)
+	}
+}
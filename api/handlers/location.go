@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+func CreateLocation() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.Location{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Prepare a new object to write to the database
+		location := models.Location{
+			Name:      data.Name,
+			Latitude:  data.Latitude,
+			Longitude: data.Longitude,
+			Radius:    data.Radius,
+			Geofence:  data.Geofence,
+			Currency:  data.Currency,
+		}
+
+		// Ensure we have all necessary fields to create the object
+		err = location.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to write the new object to the database
+		err = location.Create(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusCreated, location)
+	}
+}
+
+func ListLocations() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		locations, err := models.ListLocations(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, locations)
+	}
+}
+
+func UpdateLocation() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		lid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the location in the database with the specified ID
+		location, err := models.FindLocationByID(db, lid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Apply the submitted fields over the existing ones
+		err = c.Bind(location)
+		if err != nil {
+			return bindError(err)
+		}
+
+		location.ID = lid
+
+		// Ensure the resulting object is still valid
+		err = location.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		err = location.Update(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, location)
+	}
+}
+
+func DeleteLocation() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		lid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the location in the database with the specified ID
+		location, err := models.FindLocationByID(db, lid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Attempt to delete the object from the database
+		err = location.Delete(db)
+		if err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
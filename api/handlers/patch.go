@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"mime"
+	"net/http"
+)
+
+// bindPatch applies the JSON Merge Patch (RFC 7386) in the request body to the current object, decoding the
+// result into patched, and returns the writable members the patch changed
+func bindPatch(c echo.Context, current, patched interface{}, writable ...string) (map[string]bool, error) {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != "application/merge-patch+json" && mediaType != echo.MIMEApplicationJSON {
+		return nil, echo.NewHTTPError(http.StatusUnsupportedMediaType,
+			"patches must be sent as application/merge-patch+json")
+	}
+
+	var patch map[string]interface{}
+
+	err := json.NewDecoder(c.Request().Body).Decode(&patch)
+	if err != nil || patch == nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "patch must be a JSON object")
+	}
+
+	return models.ApplyMergePatch(current, patch, patched, writable...)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+func ListPayRates() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		_, err := models.FindUserByID(db.Unscoped(), id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		rates, err := models.ListPayRates(db, id)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, rates)
+	}
+}
+
+func SetPayRates() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user, replacing every pay rate of the user
+		var data []*models.PayRate
+		err := c.Bind(&data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		_, err = models.FindUserByID(db, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Ensure each rate is valid and is for a position which exists
+		for _, rate := range data {
+			err = rate.Validate()
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+
+			_, err = models.FindPositionByID(db, rate.PositionID)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return echo.NewHTTPError(http.StatusBadRequest, "position not found")
+				}
+
+				return err
+			}
+		}
+
+		rates, err := models.SetPayRates(db, id, data)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, rates)
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+func CreatePosition() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.Position{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Prepare a new object to write to the database
+		position := models.Position{
+			Name:          data.Name,
+			Certification: data.Certification,
+		}
+
+		// Ensure we have all necessary fields to create the object
+		err = position.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to write the new object to the database
+		err = position.Create(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusCreated, position)
+	}
+}
+
+func ListPositions() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		positions, err := models.ListPositions(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, positions)
+	}
+}
+
+func UpdatePosition() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		pid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the position in the database with the specified ID
+		position, err := models.FindPositionByID(db, pid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Apply the submitted fields over the existing ones
+		err = c.Bind(position)
+		if err != nil {
+			return bindError(err)
+		}
+
+		position.ID = pid
+
+		// Ensure the resulting object is still valid
+		err = position.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		err = position.Update(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, position)
+	}
+}
+
+func DeletePosition() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		pid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the position in the database with the specified ID
+		position, err := models.FindPositionByID(db, pid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Attempt to delete the object from the database
+		err = position.Delete(db)
+		if err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+func GetPreferences() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		// Constrain the user from fetching another user's preferences if not admin
+		if role == "user" {
+			if uid != id {
+				return echo.ErrUnauthorized
+			}
+		}
+
+		// Ensure the user exists
+		_, err := models.FindUserByID(db, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		pref, err := models.FindPreference(db, id)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, pref)
+	}
+}
+
+func UpdatePreferences() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.Preference{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		// Constrain the user from changing another user's preferences if not admin
+		if role == "user" {
+			if uid != id {
+				return echo.ErrUnauthorized
+			}
+		}
+
+		// Ensure the user exists
+		_, err = models.FindUserByID(db, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Prepare a new object to write to the database
+		pref := models.Preference{
+			UserID:              id,
+			RemindersOptOut:     data.RemindersOptOut,
+			ReminderLeadMinutes: data.ReminderLeadMinutes,
+			Locale:              data.Locale,
+			TimeZone:            data.TimeZone,
+		}
+
+		// Ensure we have all necessary fields to write the object
+		err = pref.Validate(db)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Attempt to write the object to the database
+		err = pref.Save(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, pref)
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/opaque"
+	"github.com/btnmasher/shiftr/presence"
+	"github.com/btnmasher/shiftr/utils"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	presenceIdle      = time.Second * 90 // clients must send a message within this long to stay present
+	presenceSendLimit = time.Second * 10
+)
+
+func WatchSchedule(hub *presence.Hub) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Presence is tracked per ISO 8601 week, written the same way however it was submitted
+		week := c.QueryParam("week")
+		if week == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "week required")
+		}
+
+		start, err := utils.ParseISOWeek(week, time.UTC)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		week = utils.FormatISOWeek(start)
+
+		// Requests which are not upgraded to a websocket are answered with the current viewers
+		if !strings.EqualFold(c.Request().Header.Get(echo.HeaderUpgrade), "websocket") {
+			return c.JSON(http.StatusOK, presence.Update{Week: week, Viewers: hub.Viewers(week)})
+		}
+
+		// Collect context values
+		uid := c.Get("id").(string)
+		db := c.Get("db").(*gorm.DB)
+
+		user, err := models.FindUserByID(db, uid)
+		if err != nil {
+			return err
+		}
+
+		ext, err := opaque.Encode(opaque.User, user.ID)
+		if err != nil {
+			return err
+		}
+
+		viewer := presence.Viewer{UserID: ext, Name: user.Name, Since: time.Now()}
+
+		// The token is checked rather than the origin, as browsers do not send credentials with the handshake
+		server := websocket.Server{Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			session := hub.Join(week, viewer)
+			defer session.Leave()
+
+			// Messages from the client only keep it present, it has gone once they stop
+			gone := make(chan struct{})
+			go func() {
+				defer close(gone)
+
+				var msg string
+				for {
+					err := ws.SetReadDeadline(time.Now().Add(presenceIdle))
+					if err == nil {
+						err = websocket.Message.Receive(ws, &msg)
+					}
+
+					if err != nil {
+						return
+					}
+				}
+			}()
+
+			for {
+				select {
+				case update := <-session.Updates():
+					err := ws.SetWriteDeadline(time.Now().Add(presenceSendLimit))
+					if err == nil {
+						err = websocket.JSON.Send(ws, update)
+					}
+
+					if err != nil {
+						return
+					}
+				case <-gone:
+					return
+				}
+			}
+		}}
+
+		server.ServeHTTP(c.Response(), c.Request())
+
+		return nil
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// reconcileDay returns the day selected by the date and tz query parameters, defaulting to yesterday
+func reconcileDay(c echo.Context) (time.Time, error) {
+
+	// A temporary struct to hold our user submitted data for binding
+	var params struct {
+		Date string `query:"date"` // YYYY-MM-DD
+		TZ   string `query:"tz"`
+	}
+
+	// Collect the submitted data from the user, from the query string for POST requests as well
+	err := (&echo.DefaultBinder{}).BindQueryParams(c, &params)
+	if err != nil {
+		return time.Time{}, echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+	}
+
+	loc, err := requestLocation(c, params.TZ)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if params.Date == "" {
+		return time.Now().In(loc).AddDate(0, 0, -1), nil
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", params.Date, loc)
+	if err != nil {
+		return time.Time{}, echo.NewHTTPError(http.StatusBadRequest, "date must be formatted YYYY-MM-DD")
+	}
+
+	return day, nil
+}
+
+func ReconciliationReport() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		day, err := reconcileDay(c)
+		if err != nil {
+			return err
+		}
+
+		// Collect context values
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		// Constrain managers to the discrepancies of the users reporting to them if not admin
+		var uids []string
+		if role == "user" {
+			uids, err = models.ListReportIDs(db, uid)
+			if err != nil {
+				return err
+			}
+
+			if uids == nil {
+				uids = []string{}
+			}
+		}
+
+		discrepancies, err := models.ListDiscrepancies(db, day.Format("2006-01-02"), uids)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, discrepancies)
+	}
+}
+
+func ReconcileDay(variance time.Duration) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		day, err := reconcileDay(c)
+		if err != nil {
+			return err
+		}
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		discrepancies, err := models.Reconcile(db, day, variance, time.Now())
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, discrepancies)
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/notify"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"net/url"
+)
+
+func Register(signup models.SignupPolicy, notifier notify.Notifier) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.Registration{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Prepare a new object to write to the database, self-registered accounts always receive the default role and team
+		registration := models.Registration{
+			Name:     data.Name,
+			Email:    data.Email,
+			Password: data.Password,
+			Role:     signup.Role,
+			TeamID:   signup.TeamID,
+		}
+
+		// Ensure we have all necessary fields to create the object
+		err = registration.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Ensure the address belongs to a domain allowed to register
+		if !signup.Allows(registration.Email) {
+			return echo.NewHTTPError(http.StatusForbidden, models.ErrDomainNotAllowed.Error())
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		// Ensure there are no other users or pending registrations that already exist with the specified name
+		_, err = models.FindUserByName(db.Unscoped(), data.Name)
+		if err == nil {
+			return echo.NewHTTPError(http.StatusConflict, "user already exists")
+		}
+
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		_, err = models.FindRegistrationByName(db, data.Name)
+		if err == nil {
+			return echo.NewHTTPError(http.StatusConflict, "user already exists")
+		}
+
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		err = models.CheckEmailAvailable(db, "", registration.Email)
+		if err != nil {
+			if errors.Is(err, models.ErrEmailTaken) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+
+			return err
+		}
+
+		// Attempt to write the new object to the database
+		err = registration.Create(db)
+		if err != nil {
+			return err
+		}
+
+		// Send the verification link to the submitted address
+		link := absoluteURL(c, "/register/verify?token="+url.QueryEscape(registration.Token))
+
+		err = notifier.Notify(c.Request().Context(), notify.Message{
+			To:      registration.Email,
+			Subject: "Verify your shiftr account",
+			Event:   notify.EventVerification,
+			Body:    fmt.Sprintf("Follow this link to activate the account %q: %s", registration.Name, link),
+		})
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusAccepted, echo.Map{
+			"message": "verification email sent",
+		})
+	}
+}
+
+func VerifyRegistration() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		token := c.QueryParam("token")
+		db := c.Get("db").(*gorm.DB)
+
+		if token == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "token required")
+		}
+
+		// Attempt to find the pending registration matching the token
+		registration, err := models.FindRegistrationByToken(db, token)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		if registration.Expired() {
+			return echo.NewHTTPError(http.StatusGone, "registration expired")
+		}
+
+		// Ensure the name and address were not taken while the registration was pending
+		_, err = models.FindUserByName(db.Unscoped(), registration.Name)
+		if err == nil {
+			return echo.NewHTTPError(http.StatusConflict, "user already exists")
+		}
+
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		err = models.CheckEmailAvailable(db, "", registration.Email)
+		if err != nil {
+			if errors.Is(err, models.ErrEmailTaken) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+
+			return err
+		}
+
+		// Attempt to create the user from the registration
+		user, err := registration.Complete(db)
+		if err != nil {
+			return err
+		}
+
+		user.Password = ""
+
+		return c.JSON(http.StatusCreated, user)
+	}
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/export"
+	"github.com/btnmasher/shiftr/opaque"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// defaultChurnDays is how close to their start changes to shifts count as last-minute unless a request sets it
+const defaultChurnDays = 2
+
+func HoursReport(weeks models.Weeks) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var params struct {
+			GroupBy    string    `query:"group_by"`
+			Start      time.Time `query:"filter_start"` // RFC33339
+			End        time.Time `query:"filter_end"`   // RFC33339
+			TeamID     string    `query:"team_id"`
+			LocationID string    `query:"location_id"`
+			PositionID string    `query:"position_id"`
+			WeekStart  string    `query:"week_start"` // weekday name or iso, overriding the configured weeks
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		if params.GroupBy == "" {
+			params.GroupBy = models.GroupByUser
+		}
+
+		if params.WeekStart != "" {
+			weeks, err = models.ParseWeeks(params.WeekStart)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+		}
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		// Only published shifts starting within the range are counted
+		totals, err := models.SummarizeHours(db, params.GroupBy, weeks,
+			models.FilterStatus(models.ShiftPublished),
+			models.FilterStart(params.Start),
+			models.FilterStartsBefore(params.End),
+			models.FilterTeamID(params.TeamID),
+			models.FilterLocationID(params.LocationID),
+			models.FilterPositionID(params.PositionID),
+		)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidGrouping) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+
+			return err
+		}
+
+		// Users are identified by the same IDs the API exposes
+		if params.GroupBy == models.GroupByUser {
+			for _, t := range totals {
+				t.Group, err = opaque.Encode(opaque.User, t.Group)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		// Render as a spreadsheet for payroll when CSV is negotiated, with a labor cost column for each currency
+		// paid in
+		if c.Get("mediatype") == "text/csv" {
+			var codes []string
+			seen := make(map[string]bool)
+
+			for _, t := range totals {
+				for code := range t.Costs {
+					if !seen[code] {
+						seen[code] = true
+						codes = append(codes, code)
+					}
+				}
+			}
+
+			sort.Strings(codes)
+
+			header := []interface{}{"group", "name", "shifts", "hours", "paid_hours"}
+			for _, code := range codes {
+				header = append(header, "labor_cost_"+code)
+			}
+
+			sheet := export.Sheet{Name: "Hours", Rows: [][]interface{}{header}}
+
+			for _, t := range totals {
+				row := []interface{}{t.Group, t.Name, t.Shifts, t.Hours, t.Paid}
+				for _, code := range codes {
+					row = append(row, t.Costs[code])
+				}
+
+				sheet.Rows = append(sheet.Rows, row)
+			}
+
+			c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+			c.Response().WriteHeader(http.StatusOK)
+
+			return export.WriteCSV(c.Response(), sheet)
+		}
+
+		return c.JSON(http.StatusOK, totals)
+	}
+}
+
+func ChurnReport(weeks models.Weeks) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var params struct {
+			GroupBy    string    `query:"group_by"`
+			WithinDays int       `query:"within_days"`  // changes made closer than this to the start are last-minute
+			Start      time.Time `query:"filter_start"` // RFC33339
+			End        time.Time `query:"filter_end"`   // RFC33339
+			WeekStart  string    `query:"week_start"`   // weekday name or iso, overriding the configured weeks
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		if params.GroupBy == "" {
+			params.GroupBy = models.GroupByTeam
+		}
+
+		if params.WithinDays == 0 {
+			params.WithinDays = defaultChurnDays
+		}
+
+		if params.WithinDays < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "within_days cannot be negative")
+		}
+
+		if params.WeekStart != "" {
+			weeks, err = models.ParseWeeks(params.WeekStart)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+		}
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		within := time.Duration(params.WithinDays) * 24 * time.Hour
+
+		totals, err := models.SummarizeChurn(db, params.GroupBy, within, weeks, params.Start, params.End)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidChurnGrouping) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+
+			return err
+		}
+
+		// Managers are identified by the same IDs the API exposes
+		if params.GroupBy == models.GroupByManager {
+			for _, t := range totals {
+				t.Group, err = opaque.Encode(opaque.User, t.Group)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		// Render as a spreadsheet when CSV is negotiated, for feeding into other tools
+		if c.Get("mediatype") == "text/csv" {
+			sheet := export.Sheet{
+				Name: "Churn",
+				Rows: [][]interface{}{{"group", "name", "week", "shifts", "changes", "last_minute", "last_minute_rate"}},
+			}
+
+			for _, t := range totals {
+				sheet.Rows = append(sheet.Rows, []interface{}{t.Group, t.Name, t.Week, t.Shifts, t.Changes, t.LastMinute, t.Rate})
+			}
+
+			c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+			c.Response().WriteHeader(http.StatusOK)
+
+			return export.WriteCSV(c.Response(), sheet)
+		}
+
+		return c.JSON(http.StatusOK, totals)
+	}
+}
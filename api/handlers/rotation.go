@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/opaque"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// maxRotationHorizon bounds how far ahead a rotation can be expanded in one request
+const maxRotationHorizon = time.Hour * 24 * 366
+
+func CreateRotation() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.Rotation{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Prepare a new object to write to the database
+		rotation := models.Rotation{
+			Name:       data.Name,
+			Pattern:    data.Pattern,
+			Anchor:     data.Anchor,
+			Timezone:   data.Timezone,
+			LocationID: data.LocationID,
+			PositionID: data.PositionID,
+			ShiftTypes: data.ShiftTypes,
+			Members:    data.Members,
+		}
+
+		if rotation.Timezone == "" {
+			rotation.Timezone = "UTC"
+		}
+
+		// Ensure we have all necessary fields to create the object
+		err = rotation.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to write the new object to the database
+		err = rotation.Create(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusCreated, rotation)
+	}
+}
+
+func ListRotations() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		rotations, err := models.ListRotations(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, rotations)
+	}
+}
+
+func GetRotation() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		rid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		rotation, err := models.FindRotationByID(db, rid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		return c.JSON(http.StatusOK, rotation)
+	}
+}
+
+func DeleteRotation() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		rid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the rotation in the database with the specified ID
+		rotation, err := models.FindRotationByID(db, rid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Attempt to delete the object from the database, shifts already generated are kept
+		err = rotation.Delete(db)
+		if err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+func SetRotationMembers() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		var members []models.RotationMember
+		err := c.Bind(&members)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect parameters and context values
+		rid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		rotation, err := models.FindRotationByID(db, rid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Ensure the rotation is still valid with the new members
+		rotation.Members = members
+		err = rotation.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Ensure every member exists
+		for _, m := range members {
+			_, err = models.FindUserByID(db, m.UserID)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					ext, _ := opaque.Encode(opaque.User, m.UserID)
+					return echo.NewHTTPError(http.StatusBadRequest, "user "+ext+" not found")
+				}
+
+				return err
+			}
+		}
+
+		err = rotation.SetMembers(db, members)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, rotation)
+	}
+}
+
+func GenerateRotationShifts() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var data struct {
+			Start  string `json:"start"` // YYYY-MM-DD
+			End    string `json:"end"`   // YYYY-MM-DD, exclusive
+			Status string `json:"status"`
+			DryRun bool   `json:"dry_run"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		start, err := time.Parse("2006-01-02", data.Start)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "start must be a date formatted YYYY-MM-DD")
+		}
+
+		end, err := time.Parse("2006-01-02", data.End)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "end must be a date formatted YYYY-MM-DD")
+		}
+
+		if !start.Before(end) {
+			return echo.NewHTTPError(http.StatusBadRequest, "start must precede end")
+		}
+
+		if end.Sub(start) > maxRotationHorizon {
+			return echo.NewHTTPError(http.StatusBadRequest, "rotations can be generated at most a year at a time")
+		}
+
+		// Generated shifts are drafts unless stated otherwise, so they can be reviewed before publishing
+		if data.Status == "" {
+			data.Status = models.ShiftDraft
+		}
+
+		// Collect parameters and context values
+		rid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		rotation, err := models.FindRotationByID(db, rid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		expanded, err := rotation.Expand(start, end)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		shifts := []*models.Shift{}
+		skipped := []importSkip{}
+
+		for _, shift := range expanded {
+			shift.Status = data.Status
+
+			err = shift.ValidateFor(db, "admin")
+			if err == nil && !data.DryRun {
+				err = shift.Create(db)
+			}
+
+			if err != nil {
+				person, _ := opaque.Encode(opaque.User, shift.UserID)
+				skipped = append(skipped, importSkip{Start: &shift.Start, Person: person, Reason: err.Error()})
+				continue
+			}
+
+			shifts = append(shifts, shift)
+		}
+
+		return c.JSON(http.StatusOK, echo.Map{
+			"generated": len(shifts),
+			"dry_run":   data.DryRun,
+			"shifts":    shifts,
+			"skipped":   skipped,
+		})
+	}
+}
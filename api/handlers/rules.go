@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+func ListRuleViolations() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var params struct {
+			Start time.Time `query:"filter_start"` // RFC33339
+			End   time.Time `query:"filter_end"`   // RFC33339
+			Rule  string    `query:"rule"`
+			Limit int       `query:"limit"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		violations, totals, err := models.ListRuleViolations(db, params.Start, params.End, params.Rule, params.Limit)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, echo.Map{
+			"totals":     totals,
+			"violations": violations,
+		})
+	}
+}
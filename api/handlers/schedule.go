@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/notify"
+	"github.com/btnmasher/shiftr/opaque"
+	"github.com/btnmasher/shiftr/utils"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+func PublishSchedule(notifier notify.Notifier) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var data struct {
+			Start   time.Time `json:"start"`
+			End     time.Time `json:"end"`
+			UserIDs []string  `json:"user_ids"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		if data.Start.IsZero() || data.End.IsZero() {
+			return echo.NewHTTPError(http.StatusBadRequest, "start and end time required")
+		}
+
+		if !data.Start.Before(data.End) {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"span start time must precede span end time")
+		}
+
+		// Resolve the submitted user IDs
+		for i, ext := range data.UserIDs {
+			data.UserIDs[i], err = opaque.Decode(opaque.User, ext)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid user_ids")
+			}
+		}
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to publish the draft shifts in the range
+		shifts, err := models.PublishShifts(db, data.Start, data.End, data.UserIDs...)
+		if err != nil {
+			return err
+		}
+
+		// Let each affected user know how many of their shifts were published
+		counts := make(map[string]int)
+		for _, shift := range shifts {
+			if shift.UserID != "" {
+				counts[shift.UserID]++
+			}
+		}
+
+		for uid, count := range counts {
+			user, err := models.FindUserByID(db, uid)
+			if err != nil {
+				c.Logger().Errorf("schedule publish: %s", err)
+				continue
+			}
+
+			l := models.FindLocalization(db, user.ID)
+			err = notifier.Notify(c.Request().Context(), notify.Message{
+				UserID:  user.ID,
+				To:      user.Email,
+				Subject: "New schedule published",
+				Event:   notify.EventSchedulePublished,
+				Locale:  l.Locale,
+				Body: fmt.Sprintf("%d of your shifts between %s and %s have been published",
+					count, l.Format(data.Start), l.Format(data.End)),
+			})
+			if err != nil {
+				c.Logger().Errorf("schedule publish: %s", err)
+			}
+		}
+
+		return c.JSON(http.StatusOK, shifts)
+	}
+}
+
+func GetSchedule() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var params struct {
+			Week   string `query:"week"` // ISO 8601 week, e.g. 2024-W32
+			TZ     string `query:"tz"`
+			TeamID string `query:"team_id"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		loc, err := requestLocation(c, params.TZ)
+		if err != nil {
+			return err
+		}
+
+		// Default to the current week
+		if params.Week == "" {
+			params.Week = utils.FormatISOWeek(time.Now().In(loc))
+		}
+
+		start, err := utils.ParseISOWeek(params.Week, loc)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Collect context values
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		query := models.ScheduleQuery{
+			Start:    start,
+			Days:     7,
+			Location: loc,
+			TeamID:   params.TeamID,
+		}
+
+		// Constrain the user to the published schedule of their own team, or only themselves without one
+		var team *models.Team
+		if role == "user" {
+			user, err := models.FindUserByID(db, uid)
+			if err != nil {
+				return err
+			}
+
+			query.Statuses = []string{models.ShiftPublished}
+			query.TeamID = user.TeamID
+			if user.TeamID == "" {
+				query.UserID = uid
+			} else {
+				team, err = models.FindTeamByID(db, user.TeamID)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		rows, err := models.ListSchedule(db, query)
+		if err != nil {
+			return err
+		}
+
+		// Hide who works the shifts of teammates the team or shift keeps private
+		if team != nil {
+			rows = models.AnonymizeSchedule(rows, uid, team.Visibility)
+		}
+
+		return c.JSON(http.StatusOK, echo.Map{
+			"week":  params.Week,
+			"start": start,
+			"end":   start.AddDate(0, 0, 7),
+			"users": rows,
+		})
+	}
+}
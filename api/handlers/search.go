@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Bounds of the number of results of each type a search returns
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+func Search() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var params struct {
+			Query string `query:"q"`
+			Type  string `query:"type"`  // user or shift, both when empty
+			Limit int    `query:"limit"` // of the results of each type
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		params.Query = strings.TrimSpace(params.Query)
+		if params.Query == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "q required")
+		}
+
+		if params.Type != "" && params.Type != models.SearchUser && params.Type != models.SearchShift {
+			return echo.NewHTTPError(http.StatusBadRequest, "type must be user or shift")
+		}
+
+		switch {
+		case params.Limit < 0:
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must not be negative")
+		case params.Limit == 0:
+			params.Limit = defaultSearchLimit
+		case params.Limit > maxSearchLimit:
+			params.Limit = maxSearchLimit
+		}
+
+		// Collect context values
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		results := []*models.SearchResult{}
+
+		// Every user may find their coworkers, as the directory lists them
+		if params.Type == "" || params.Type == models.SearchUser {
+			since := time.Now().AddDate(0, 0, -directoryPositionDays)
+
+			users, err := models.SearchUsers(db, params.Query, params.Limit, since)
+			if err != nil {
+				return err
+			}
+
+			results = append(results, users...)
+		}
+
+		if params.Type == "" || params.Type == models.SearchShift {
+			// Constrain the user to their own published shifts and requests awaiting approval if not admin
+			var opts []models.ShiftFilterOption
+			if role == "user" {
+				opts = append(opts,
+					models.FilterUserID(uid),
+					models.FilterStatus(models.ShiftPublished, models.ShiftPending),
+				)
+			}
+
+			shifts, err := models.SearchShifts(db, params.Query, params.Limit, opts...)
+			if err != nil {
+				return err
+			}
+
+			results = append(results, shifts...)
+		}
+
+		return c.JSON(http.StatusOK, results)
+	}
+}
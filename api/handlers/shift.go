@@ -2,52 +2,60 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/middleware"
 	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/api/service"
+	"github.com/btnmasher/shiftr/export"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
-func CreateShift() func(echo.Context) error {
+func CreateShift(shifts *service.Shifts) func(echo.Context) error {
 	return func(c echo.Context) error {
 
 		// Collect the submitted data from the user
 		data := &models.Shift{}
 		err := c.Bind(data)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, "invalid object")
+			return bindError(err)
 		}
 
 		// Prepare a new object to write to the database
 		shift := models.Shift{
-			UserID: data.UserID,
-			Start:  data.Start,
-			End:    data.End,
+			UserID:   data.UserID,
+			Start:    data.Start,
+			End:      data.End,
+			Capacity: data.Capacity,
+			Status:   data.Status,
+
+			LocationID: data.LocationID,
+			PositionID: data.PositionID,
+			Color:      data.Color,
+			Visibility: data.Visibility,
+			Metadata:   data.Metadata,
+			Tags:       data.Tags,
 		}
 
-		// Ensure we have all necessary fields to create the object
-		err = shift.Validate()
-		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
-		}
-
-		// Collect context values
-		role := c.Get("role").(string)
-		uid := c.Get("id").(string)
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+		who := caller(c)
 
-		// Constrain the user from creating a shift object for another user if not admin
-		if role == "user" {
-			if uid != shift.UserID {
-				return echo.ErrUnauthorized
-			}
+		// Attempt to write the new object to the database once the user is found to be allowed to
+		affected, err := shifts.Create(c.Request().Context(), db, who, &shift)
+		if err != nil {
+			return HTTPError(shiftConflict(db, &shift, err, who.Role == "admin"))
 		}
 
-		// Collect the database reference from context
-		db := c.Get("db").(*gorm.DB)
+		middleware.AuditSubjects(c, affected...)
 
-		// Attempt to write the new object to the database
-		err = shift.Create(db)
+		// Annotate the shift with any holiday and pay differential it falls on
+		err = models.AnnotateShifts(db, []*models.Shift{&shift})
 		if err != nil {
 			return err
 		}
@@ -56,20 +64,18 @@ func CreateShift() func(echo.Context) error {
 	}
 }
 
-func UpdateShift() func(echo.Context) error {
+func UpdateShift(shifts *service.Shifts) func(echo.Context) error {
 	return func(c echo.Context) error {
 
 		// Collect the submitted data from the user
 		data := &models.Shift{}
 		err := c.Bind(data)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, "invalid object")
+			return bindError(err)
 		}
 
 		// Collect parameters and context values
 		sid := c.Param("id")
-		role := c.Get("role").(string)
-		uid := c.Get("id").(string)
 		db := c.Get("db").(*gorm.DB)
 
 		// Check if the shift already exists
@@ -82,23 +88,21 @@ func UpdateShift() func(echo.Context) error {
 			return err
 		}
 
-		// Constrain the user from changing the UserID of the Shift object if not admin
-		if role == "user" {
-			if shift.UserID != uid {
-				return echo.ErrUnauthorized
-			}
-
-			if data.UserID != "" && data.UserID != uid {
-				return echo.ErrUnauthorized
-			}
-		}
-
 		// Prepare a new object to write to the database
 		change := models.Shift{
-			ID:     sid,
-			UserID: data.UserID,
-			Start:  data.Start,
-			End:    data.End,
+			ID:       sid,
+			UserID:   data.UserID,
+			Start:    data.Start,
+			End:      data.End,
+			Capacity: data.Capacity,
+			Status:   data.Status,
+
+			LocationID: data.LocationID,
+			PositionID: data.PositionID,
+			Color:      data.Color,
+			Visibility: data.Visibility,
+			Metadata:   data.Metadata,
+			Tags:       data.Tags,
 		}
 
 		// Ensure there are no zero values before writing
@@ -106,93 +110,668 @@ func UpdateShift() func(echo.Context) error {
 			change.UserID = shift.UserID
 		}
 
+		if data.Capacity == 0 {
+			change.Capacity = shift.Capacity
+		}
+
+		if data.Status == "" {
+			change.Status = shift.Status
+		}
+
+		if data.LocationID == "" {
+			change.LocationID = shift.LocationID
+		}
+
+		if data.PositionID == "" {
+			change.PositionID = shift.PositionID
+		}
+
+		if data.Color == "" {
+			change.Color = shift.Color
+		}
+
+		if data.Visibility == "" {
+			change.Visibility = shift.Visibility
+		}
+
+		if data.Metadata == nil {
+			change.Metadata = shift.Metadata
+		}
+
+		if data.Tags == nil {
+			change.Tags = shift.Tags
+		}
+
 		if data.Start.IsZero() {
-			change.Start = shift.End
+			change.Start = shift.Start
 		}
 
 		if data.End.IsZero() {
 			change.End = shift.End
 		}
 
-		// Attempt to write the new object to the database
-		err = change.Update(db)
+		return saveShift(c, db, shifts, shift, &change)
+	}
+}
+
+func PatchShift(shifts *service.Shifts) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		sid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Check if the shift already exists
+		shift, err := models.FindShiftByID(db, sid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Apply the submitted patch to the shift, so members left out keep their values and null clears them
+		change := &models.Shift{}
+		_, err = bindPatch(c, shift, change, models.ShiftPatchFields...)
 		if err != nil {
 			return err
 		}
 
-		return c.JSON(http.StatusOK, change)
+		change.ID = shift.ID
+
+		return saveShift(c, db, shifts, shift, change)
 	}
 }
 
-func ListShifts() func(echo.Context) error {
-	return func(c echo.Context) error {
+// saveShift writes the change to the shift once the current user is found to be allowed to make it, then responds
+// with the changed shift
+func saveShift(c echo.Context, db *gorm.DB, shifts *service.Shifts, shift, change *models.Shift) error {
+	who := caller(c)
 
-		// A temporary struct to hold our user submitted data for binding
-		var params struct {
-			UserID string    `query:"user_id"`
-			Start  time.Time `query:"filter_start"` // RFC33339
-			End    time.Time `query:"filter_end"`   // RFC33339
-			Limit  int       `query:"limit"`
+	affected, err := shifts.Update(c.Request().Context(), db, who, shift, change, ifMatch(c))
+	if err != nil {
+		return HTTPError(shiftConflict(db, change, err, who.Role == "admin"))
+	}
+
+	middleware.AuditSubjects(c, affected...)
+
+	// Annotate the shift with any holiday and pay differential it falls on
+	err = models.AnnotateShifts(db, []*models.Shift{change})
+	if err != nil {
+		return err
+	}
+
+	setETag(c, change.ETag())
+
+	return c.JSON(http.StatusOK, change)
+}
+
+// caller returns the user making the request
+func caller(c echo.Context) service.Caller {
+	return service.Caller{ID: c.Get("id").(string), Role: c.Get("role").(string)}
+}
+
+// ifMatch returns the precondition of the request, refusing changes to a version of an object other than the one
+// its If-Match header gives
+func ifMatch(c echo.Context) service.Precondition {
+	return func(etag string) error {
+		return checkIfMatch(c, etag)
+	}
+}
+
+// shiftInvalid translates a shift validation error into a response, reporting the code of a violated limit
+// and the error of each invalid field
+func shiftInvalid(err error) error {
+	var fields *models.FieldErrors
+	if errors.As(err, &fields) {
+		return fieldsError(fields)
+	}
+
+	var limit *models.ShiftLimitError
+	if errors.As(err, &limit) {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, limit)
+	}
+
+	return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+}
+
+// shiftConflict translates an overlap error from saving a shift into a response detailing the conflicting
+// shifts along with changes the client can apply to resolve them, and an enforced scheduling rule the shift
+// violates into the response of an invalid shift. Otherwise err is returned as is.
+func shiftConflict(db *gorm.DB, shift *models.Shift, err error, reassign bool) error {
+	var limit *models.ShiftLimitError
+	if errors.As(err, &limit) {
+		return shiftInvalid(err)
+	}
+
+	var overlap *models.OverlapError
+	if !errors.As(err, &overlap) {
+		return err
+	}
+
+	suggestions, serr := models.SuggestResolutions(db, shift, reassign)
+	if serr != nil {
+		return serr
+	}
+
+	return echo.NewHTTPError(http.StatusConflict, echo.Map{
+		"code":        models.ConflictOverlap,
+		"message":     overlap.Error(),
+		"conflicts":   overlap.Conflicts,
+		"suggestions": suggestions,
+	})
+}
+
+// shiftListParams is a temporary struct to hold the user submitted filters shared by the shift listing endpoints
+type shiftListParams struct {
+	UserID string `query:"user_id"`
+	Start  string `query:"filter_start"` // RFC3339, or a date from the midnight it starts with
+	End    string `query:"filter_end"`   // RFC3339, or a date through the midnight it ends with
+	Filter string `query:"filter"`       // today, this_week or next_week, in place of a start and end
+	TZ     string `query:"tz"`           // of dates and periods, the request's time zone when empty
+	Limit  int    `query:"limit"`
+	Format string `query:"format"`
+
+	Sort    string `query:"sort"` // e.g. start,-created_at
+	Page    int    `query:"page"`
+	PerPage int    `query:"per_page"`
+	Cursor  string `query:"cursor"`
+
+	LocationID       string `query:"location_id"`
+	PositionID       string `query:"position_id"`
+	IncludeCancelled bool   `query:"include_cancelled"`
+	Reports          bool   `query:"reports"` // the shifts of everyone reporting to the user rather than their own
+
+	TeamID        string    `query:"team_id"`
+	Status        string    `query:"status"` // comma separated
+	Tags          []string  `query:"tag"`    // repeated or comma separated, every one required
+	ActiveAt      time.Time `query:"active_at"`
+	CreatedAfter  time.Time `query:"created_after"`
+	CreatedBefore time.Time `query:"created_before"`
+	UpdatedAfter  time.Time `query:"updated_after"`
+	UpdatedBefore time.Time `query:"updated_before"`
+}
+
+// shiftStatuses are the statuses shifts may be filtered by
+var shiftStatuses = []string{models.ShiftDraft, models.ShiftPending, models.ShiftPublished, models.ShiftArchived}
+
+// splitList splits comma separated values, dropping empty ones
+func splitList(values ...string) []string {
+	var list []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
 		}
+	}
 
-		// Collect the submitted data from the user
-		err := c.Bind(&params)
+	return list
+}
+
+// includes returns the related records the request asks to embed with the include parameter, refusing any other
+// than those the endpoint offers
+func includes(c echo.Context, offered ...string) (map[string]bool, error) {
+	included := map[string]bool{}
+	for _, name := range splitList(c.QueryParams()["include"]...) {
+		if !contains(offered, name) {
+			return nil, echo.NewHTTPError(http.StatusBadRequest,
+				fmt.Sprintf("include must be %s", strings.Join(offered, " or ")))
+		}
+
+		included[name] = true
+	}
+
+	return included, nil
+}
+
+// contains reports whether the list holds the value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// listShifts collects the submitted filters, constrains them to what the current user may access,
+// and returns the requested page of matching shifts from the database
+func listShifts(c echo.Context, weeks models.Weeks) (*models.ShiftPage, *shiftListParams, error) {
+	params := &shiftListParams{}
+
+	// Collect the submitted data from the user
+	err := c.Bind(params)
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest,
+			"invalid parameters")
+	}
+
+	// Dates and periods are taken in the requested time zone, or the one negotiated for the request
+	loc, err := requestLocation(c, params.TZ)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start, err := parseFilterTime(params.Start, loc, false)
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest,
+			"filter_start must be an RFC 3339 time or a date")
+	}
+
+	end, err := parseFilterTime(params.End, loc, true)
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest,
+			"filter_end must be an RFC 3339 time or a date")
+	}
+
+	// A period stands in for the start and end, selecting the shifts starting within it
+	var startsBefore time.Time
+	if params.Filter != "" {
+		if !start.IsZero() || !end.IsZero() {
+			return nil, nil, echo.NewHTTPError(http.StatusBadRequest,
+				"filter cannot be combined with filter_start or filter_end")
+		}
+
+		start, startsBefore, err = filterPeriod(params.Filter, time.Now().In(loc), weeks)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest,
-				"invalid parameters")
+			return nil, nil, err
 		}
+	}
 
-		// Collect context values
-		role := c.Get("role").(string)
-		uid := c.Get("id").(string)
+	// Times are compared in UTC, as which they are stored, rather than as text in another zone
+	start, end, startsBefore = start.UTC(), end.UTC(), startsBefore.UTC()
+
+	// Collect context values
+	role := c.Get("role").(string)
+	uid := c.Get("id").(string)
+
+	// Constrain the user to published shifts and their requests awaiting approval if not admin
+	var statuses []string
+	if role == "user" {
+		statuses = []string{models.ShiftPublished, models.ShiftPending}
+	}
+
+	// Collect database reference from context
+	db := c.Get("db").(*gorm.DB)
+
+	// Managers may list the shifts of everyone reporting to them, admins of everyone
+	var reports []string
+	if params.Reports {
+		manager := uid
+		if params.UserID != "" {
+			manager, params.UserID = params.UserID, ""
+		}
+
+		if role == "user" && manager != uid {
+			return nil, nil, echo.ErrUnauthorized
+		}
+
+		reports, err = models.ListReportIDs(db, manager)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Constrain the user from listing shifts from another user they do not manage if not admin
+	if role == "user" && !params.Reports {
+		if uid != params.UserID {
+			if params.UserID == "" {
+				// Ensure the user only receives relevant results for their UserID
+				params.UserID = uid
+			} else {
+				manages, err := models.Manages(db, uid, params.UserID)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				if !manages {
+					return nil, nil, echo.ErrUnauthorized
+				}
+			}
+		}
+	}
+
+	// Ensure that the timestamp received isn't malformed
+	if !start.IsZero() && !end.IsZero() {
+		if start.After(end) {
+			return nil, nil, echo.NewHTTPError(http.StatusBadRequest,
+				"filter span start time must precede span end time")
+		}
+	}
+
+	// Requested statuses narrow those the user may see rather than widening them
+	requested := splitList(params.Status)
+	for _, status := range requested {
+		if !contains(shiftStatuses, status) {
+			return nil, nil, echo.NewHTTPError(http.StatusBadRequest,
+				"status must be draft, pending, published or archived")
+		}
+
+		if statuses != nil && !contains(statuses, status) {
+			return nil, nil, echo.ErrUnauthorized
+		}
+	}
+
+	tags := splitList(params.Tags...)
+	if err := models.Tags(tags).Validate(); err != nil {
+		return nil, nil, shiftInvalid(err)
+	}
+
+	opts := []models.ShiftFilterOption{
+		models.FilterUserID(params.UserID),
+		models.FilterStart(start),
+		models.FilterEnd(end),
+		models.FilterStartsBefore(startsBefore),
+		models.FilterStatus(statuses...),
+		models.FilterStatus(requested...),
+		models.FilterLocationID(params.LocationID),
+		models.FilterPositionID(params.PositionID),
+		models.FilterTeamID(params.TeamID),
+		models.FilterTags(tags...),
+		models.FilterActiveAt(params.ActiveAt),
+		models.FilterCreated(params.CreatedAfter, params.CreatedBefore),
+		models.FilterUpdated(params.UpdatedAfter, params.UpdatedBefore),
+		models.IncludeCancelled(params.IncludeCancelled),
+	}
+
+	if params.Reports {
+		opts = append(opts, models.FilterUserIDs(reports))
+	}
+
+	// The limit only caps the results when they are not being paged
+	if params.PerPage < 1 {
+		opts = append(opts, models.WithLimit(params.Limit))
+	}
+
+	// Attempt to fetch the page of matching shifts from the database
+	page, err := models.PageShifts(db, models.ShiftPageQuery{
+		Sort:    params.Sort,
+		Page:    params.Page,
+		PerPage: params.PerPage,
+		Cursor:  params.Cursor,
+	}, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Annotate the shifts with any holidays and pay differentials they fall on
+	err = models.AnnotateShifts(db, page.Shifts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return page, params, nil
+}
+
+// parseFilterTime parses a filter time given as RFC 3339, or as a date taken as the midnight it starts with in the
+// location, or the midnight it ends with when through is set. An empty value is the zero time.
+func parseFilterTime(value string, loc *time.Location, through bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err == nil {
+		if through {
+			day = day.AddDate(0, 0, 1)
+		}
+
+		return day, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// filterPeriod returns the start of the period named by the filter parameter, around now, and the start of the
+// period after it. Weeks begin on the configured day.
+func filterPeriod(name string, now time.Time, weeks models.Weeks) (time.Time, time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch name {
+	case "today":
+		return today, today.AddDate(0, 0, 1), nil
+	case "this_week":
+		start, end := weeks.Of(today)
+		return start, end, nil
+	case "next_week":
+		_, start := weeks.Of(today)
+		return start, start.AddDate(0, 0, 7), nil
+	default:
+		return time.Time{}, time.Time{}, echo.NewHTTPError(http.StatusBadRequest,
+			"filter must be today, this_week or next_week")
+	}
+}
+
+// maxBatchIDs is the most objects which may be fetched by their IDs in a single request
+const maxBatchIDs = 100
+
+// batchIDs returns the IDs the ids parameter asks for in the order given, without repeats
+func batchIDs(c echo.Context) ([]string, error) {
+	ids := []string{}
+	seen := map[string]bool{}
+
+	for _, id := range splitList(c.QueryParams()["ids"]...) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) > maxBatchIDs {
+		return nil, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("cannot fetch more than %d ids at once", maxBatchIDs))
+	}
+
+	return ids, nil
+}
+
+// fetchShifts returns the shifts with the IDs the ids parameter asks for which the user may see, in the order
+// given. Those which do not exist and those the user may not see are left out alike.
+func fetchShifts(c echo.Context) ([]*models.Shift, error) {
+	ids, err := batchIDs(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect context values
+	db := c.Get("db").(*gorm.DB)
+	role := c.Get("role").(string)
+	uid := c.Get("id").(string)
+
+	found, err := models.FindShiftsByIDs(db, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*models.Shift, len(found))
+	for _, shift := range found {
+		byID[shift.ID] = shift
+	}
+
+	// Constrain the user to the shifts they could fetch one at a time, of their own and of those reporting to them
+	manages := map[string]bool{uid: true}
+	shifts := []*models.Shift{}
+
+	for _, id := range ids {
+		shift, ok := byID[id]
+		if !ok {
+			continue
+		}
 
-		// Constrain the user from listing shifts from another user if not admin
 		if role == "user" {
-			if uid != params.UserID {
-				if params.UserID == "" {
-					// Ensure the user only receives relevant results for their UserID
-					params.UserID = uid
-				} else {
-					return echo.ErrUnauthorized
+			allowed, checked := manages[shift.UserID]
+			if !checked {
+				allowed, err = models.Manages(db, uid, shift.UserID)
+				if err != nil {
+					return nil, err
 				}
+
+				manages[shift.UserID] = allowed
+			}
+
+			if !allowed || !service.UserVisible(shift) {
+				continue
 			}
 		}
 
-		// Ensure that the timestamp received isn't malformed
-		if !params.Start.IsZero() && !params.End.IsZero() {
-			if params.Start.After(params.End) {
-				return echo.NewHTTPError(http.StatusBadRequest,
-					"filter span start time must precede span end time")
+		shifts = append(shifts, shift)
+	}
+
+	// Annotate the shifts with any holidays and pay differentials they fall on
+	err = models.AnnotateShifts(db, shifts)
+	if err != nil {
+		return nil, err
+	}
+
+	return shifts, nil
+}
+
+func ListShifts(weeks models.Weeks) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		included, err := includes(c, "user")
+		if err != nil {
+			return err
+		}
+
+		// Shifts fetched by their IDs are answered on their own, rather than filtered and paged
+		if _, ok := c.QueryParams()["ids"]; ok {
+			shifts, err := fetchShifts(c)
+			if err != nil {
+				return err
 			}
+
+			if included["user"] {
+				err = models.IncludeShiftUsers(c.Get("db").(*gorm.DB), shifts)
+				if err != nil {
+					return err
+				}
+			}
+
+			c.Response().Header().Set("X-Total-Count", strconv.Itoa(len(shifts)))
+
+			return c.JSON(http.StatusOK, shifts)
+		}
+
+		page, _, err := listShifts(c, weeks)
+		if err != nil {
+			return err
+		}
+
+		// The page is paired with the total and the cursor of the next page in headers, keeping the body a list
+		c.Response().Header().Set("X-Total-Count", strconv.FormatInt(page.Total, 10))
+		if page.NextCursor != "" {
+			c.Response().Header().Set("X-Next-Cursor", page.NextCursor)
 		}
 
+		// Embed the workers of the shifts when asked, fetched together rather than by the client one at a time
+		if included["user"] {
+			err = models.IncludeShiftUsers(c.Get("db").(*gorm.DB), page.Shifts)
+			if err != nil {
+				return err
+			}
+		}
+
+		return c.JSON(http.StatusOK, page.Shifts)
+	}
+}
+
+func ExportShifts(weeks models.Weeks) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		page, params, err := listShifts(c, weeks)
+		if err != nil {
+			return err
+		}
+
+		shifts := page.Shifts
+
 		// Collect database reference from context
 		db := c.Get("db").(*gorm.DB)
 
-		// Attempt to write the changes to the database
-		shifts, err := models.ListShifts(db,
-			models.FilterUserID(params.UserID),
-			models.FilterStart(params.Start),
-			models.FilterEnd(params.End),
-			models.WithLimit(params.Limit),
-		)
-		//shifts, err := models.ListShifts(db, params.UserID, params.Limit, params.Start, params.End)
+		// Resolve the names of the users the shifts belong to
+		uids := make([]string, 0, len(shifts))
+		for _, shift := range shifts {
+			uids = append(uids, shift.UserID)
+		}
+
+		names, err := models.FindUserNames(db, uids)
 		if err != nil {
 			return err
 		}
 
-		return c.JSON(http.StatusOK, shifts)
+		sheet, err := shiftSheet(shifts, names)
+		if err != nil {
+			return err
+		}
+
+		filename := "shifts-" + time.Now().Format("20060102")
+
+		switch params.Format {
+		case "csv":
+			c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+			c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`.csv"`)
+			c.Response().WriteHeader(http.StatusOK)
+
+			return export.WriteCSV(c.Response(), sheet)
+		case "", "xlsx":
+			c.Response().Header().Set(echo.HeaderContentType, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+			c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`.xlsx"`)
+			c.Response().WriteHeader(http.StatusOK)
+
+			return export.WriteXLSX(c.Response(), sheet)
+		}
+
+		return echo.NewHTTPError(http.StatusBadRequest, "unsupported export format")
 	}
 }
 
+// shiftSheet tabulates the shifts for export, including the user names when provided
+func shiftSheet(shifts []*models.Shift, names map[string]string) (export.Sheet, error) {
+	header := []interface{}{"id", "user_id", "start", "end", "hours", "capacity", "holiday", "created_at", "updated_at"}
+	if names != nil {
+		header = []interface{}{"id", "user_id", "user_name", "start", "end", "hours", "capacity", "holiday", "created_at", "updated_at"}
+	}
+
+	sheet := export.Sheet{
+		Name: "Shifts",
+		Rows: [][]interface{}{header},
+	}
+
+	for _, shift := range shifts {
+		hours := math.Round(shift.End.Sub(shift.Start).Hours()*100) / 100
+
+		// Export the same IDs the API exposes
+		sid, uid, err := shift.ExternalIDs()
+		if err != nil {
+			return export.Sheet{}, err
+		}
+
+		row := []interface{}{sid, uid, shift.Start, shift.End, hours, shift.Capacity, shift.Holiday, shift.CreatedAt, shift.UpdatedAt}
+		if names != nil {
+			row = []interface{}{sid, uid, names[shift.UserID], shift.Start, shift.End, hours, shift.Capacity, shift.Holiday, shift.CreatedAt, shift.UpdatedAt}
+		}
+
+		sheet.Rows = append(sheet.Rows, row)
+	}
+
+	return sheet, nil
+}
+
 func GetShift() func(ctx echo.Context) error {
 	return func(c echo.Context) error {
 
+		included, err := includes(c, "user")
+		if err != nil {
+			return err
+		}
+
 		// Collect parameters and context values
 		sid := c.Param("id")
 		db := c.Get("db").(*gorm.DB)
-		role := c.Get("role").(string)
-		uid := c.Get("id").(string)
 
 		// Attempt to find the shift in the database
 		shift, err := models.FindShiftByID(db, sid)
@@ -204,18 +783,109 @@ func GetShift() func(ctx echo.Context) error {
 			return err
 		}
 
-		// Constrain the user from fetching shifts that do not match their UserID if not admin
-		if role == "user" {
-			if uid != shift.UserID {
-				return echo.ErrUnauthorized
+		// Constrain the user from fetching unpublished shifts or shifts of users other than themselves and those
+		// reporting to them if not admin, besides their own awaiting approval
+		err = service.Visible(db, caller(c), shift)
+		if err != nil {
+			return HTTPError(err)
+		}
+
+		// Annotate the shift with any holiday and pay differential it falls on
+		err = models.AnnotateShifts(db, []*models.Shift{shift})
+		if err != nil {
+			return err
+		}
+
+		if included["user"] {
+			err = models.IncludeShiftUsers(db, []*models.Shift{shift})
+			if err != nil {
+				return err
+			}
+		}
+
+		setETag(c, shift.ETag())
+
+		return c.JSON(http.StatusOK, shift)
+	}
+}
+
+func DeleteShift(shifts *service.Shifts) func(ctx echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var data struct {
+			Reason string `query:"reason" json:"reason"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&data)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		if strings.TrimSpace(data.Reason) == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "cancellation reason required")
+		}
+
+		// Collect parameters and context values
+		sid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the shift in the database
+		shift, err := models.FindShiftByID(db, sid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
 			}
+
+			return err
+		}
+
+		// Attempt to cancel the object, it remains in the database for reporting
+		affected, err := shifts.Cancel(c.Request().Context(), db, caller(c), shift, data.Reason, ifMatch(c))
+		if err != nil {
+			return HTTPError(err)
 		}
 
+		middleware.AuditSubjects(c, affected...)
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+func RestoreShift(shifts *service.Shifts) func(ctx echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		sid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+		who := caller(c)
+
+		// Attempt to find the cancelled shift in the database
+		shift, err := models.FindCancelledShiftByID(db, sid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Attempt to reinstate the object, which fails if its worker can no longer work it
+		affected, err := shifts.Restore(c.Request().Context(), db, who, shift, ifMatch(c))
+		if err != nil {
+			return HTTPError(shiftConflict(db, shift, err, who.Role == "admin"))
+		}
+
+		middleware.AuditSubjects(c, affected...)
+
+		setETag(c, shift.ETag())
+
 		return c.JSON(http.StatusOK, shift)
 	}
 }
 
-func DeleteShift() func(ctx echo.Context) error {
+func ListShiftReminders() func(ctx echo.Context) error {
 	return func(c echo.Context) error {
 
 		// Collect parameters and context values
@@ -234,19 +904,18 @@ func DeleteShift() func(ctx echo.Context) error {
 			return err
 		}
 
-		// Constrain the user from deleting shifts that do not match their UserID if not admin
+		// Constrain the user from fetching reminders for shifts that do not match their UserID if not admin
 		if role == "user" {
 			if uid != shift.UserID {
 				return echo.ErrUnauthorized
 			}
 		}
 
-		// Attempt to delete the object from the database
-		err = shift.Delete(db)
+		reminders, err := models.ListReminders(db, sid)
 		if err != nil {
 			return err
 		}
 
-		return c.NoContent(http.StatusNoContent)
+		return c.JSON(http.StatusOK, reminders)
 	}
 }
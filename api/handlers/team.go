@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+func CreateTeam() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &models.Team{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Prepare a new object to write to the database
+		team := models.Team{
+			Name:       data.Name,
+			Visibility: data.Visibility,
+		}
+
+		// Ensure we have all necessary fields to create the object
+		err = team.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to write the new object to the database
+		err = team.Create(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusCreated, team)
+	}
+}
+
+func ListTeams() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		teams, err := models.ListTeams(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, teams)
+	}
+}
+
+func UpdateTeam() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		tid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the team in the database with the specified ID
+		team, err := models.FindTeamByID(db, tid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Apply the submitted fields over the existing ones
+		err = c.Bind(team)
+		if err != nil {
+			return bindError(err)
+		}
+
+		team.ID = tid
+
+		// Ensure the resulting object is still valid
+		err = team.Validate()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		err = team.Update(db)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, team)
+	}
+}
+
+func DeleteTeam() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		tid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to find the team in the database with the specified ID
+		team, err := models.FindTeamByID(db, tid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		// Attempt to delete the object from the database
+		err = team.Delete(db)
+		if err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/api/service"
+	"github.com/btnmasher/shiftr/events"
+	"github.com/btnmasher/shiftr/opaque"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+	"gorm.io/gorm"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	updateSendLimit = time.Second * 10 // how long sending an update to a client may take before it is taken to be gone
+	streamKeepalive = time.Second * 30 // how often an idle event stream is written to, to notice clients gone
+)
+
+// shiftUpdate is a change to a shift sent to the clients which may see it
+type shiftUpdate struct {
+	Type  string        `json:"type"`            // shift.created, shift.updated or shift.deleted
+	ID    string        `json:"id"`              // of the shift
+	Shift *models.Shift `json:"shift,omitempty"` // as it now stands, unless deleted
+	At    time.Time     `json:"at"`
+}
+
+func WatchShifts(bus *events.Bus) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		if !strings.EqualFold(c.Request().Header.Get(echo.HeaderUpgrade), "websocket") {
+			return echo.NewHTTPError(http.StatusUpgradeRequired, "websocket upgrade required")
+		}
+
+		// Collect context values
+		uid := c.Get("id").(string)
+		role := c.Get("role").(string)
+		db := c.Get("db").(*gorm.DB)
+
+		// The token is checked rather than the origin, as browsers do not send credentials with the handshake
+		server := websocket.Server{Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			// Changes made from now on are sent, those made before are fetched from the API
+			sub := bus.Subscribe()
+			defer sub.Close()
+
+			// Messages from the client are ignored, it has gone once reading fails
+			gone := make(chan struct{})
+			go func() {
+				defer close(gone)
+
+				var msg string
+				for {
+					err := websocket.Message.Receive(ws, &msg)
+					if err != nil {
+						return
+					}
+				}
+			}()
+
+			for {
+				select {
+				case e, ok := <-sub.Events():
+					// A client which fell behind is disconnected, to reconnect and fetch the shifts anew
+					if !ok {
+						return
+					}
+
+					// Users are only told of changes to their own shifts
+					if role != "admin" && !e.Concerns(uid) {
+						continue
+					}
+
+					update, err := shiftUpdateFor(db, e, uid, role)
+					if err != nil {
+						c.Logger().Errorf("shift update: %s", err)
+						continue
+					}
+
+					data, err := serialize(c, update)
+					if err == nil {
+						err = ws.SetWriteDeadline(time.Now().Add(updateSendLimit))
+					}
+					if err == nil {
+						err = websocket.Message.Send(ws, string(data))
+					}
+
+					if err != nil {
+						return
+					}
+				case <-gone:
+					return
+				}
+			}
+		}}
+
+		server.ServeHTTP(c.Response(), c.Request())
+
+		return nil
+	}
+}
+
+// connKey is the context key of the connection a request was read from
+type connKey struct{}
+
+// ConnContext adds the connection to the context of the requests read from it, as the ConnContext of the
+// http.Server, so that event streams can extend its write deadline past the server's write timeout
+func ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, conn)
+}
+
+// StreamOr answers the requests accepting an event stream with stream, and the others with other, or with
+// 406 Not Acceptable when other is nil
+func StreamOr(stream, other echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if strings.Contains(c.Request().Header.Get(echo.HeaderAccept), "text/event-stream") {
+			return stream(c)
+		}
+
+		if other != nil {
+			return other(c)
+		}
+
+		return echo.NewHTTPError(http.StatusNotAcceptable, "text/event-stream required")
+	}
+}
+
+func StreamEvents(bus *events.Bus) func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		res := c.Response()
+		if _, ok := res.Writer.(http.Flusher); !ok {
+			return echo.NewHTTPError(http.StatusInternalServerError, "streaming not supported")
+		}
+
+		// Collect context values
+		uid := c.Get("id").(string)
+		role := c.Get("role").(string)
+		db := c.Get("db").(*gorm.DB)
+
+		// Browsers resume after the last event received when reconnecting, other clients may give it as a parameter
+		last := c.Request().Header.Get("Last-Event-ID")
+		if last == "" {
+			last = c.QueryParam("last_event_id")
+		}
+
+		sub, missed, resumed := bus.SubscribeAfter(last)
+		defer sub.Close()
+
+		// Each write extends the deadline of the connection, when known, so the stream outlasts the write timeout
+		conn, _ := c.Request().Context().Value(connKey{}).(net.Conn)
+		stream := &eventStream{conn: conn, res: res}
+
+		res.Header().Set(echo.HeaderContentType, "text/event-stream")
+		res.Header().Set("Cache-Control", "no-cache")
+		res.WriteHeader(http.StatusOK)
+
+		err := stream.flush()
+		if err != nil {
+			return nil
+		}
+
+		// Clients which may have missed events are told to fetch the shifts anew, as the stream only continues
+		if !resumed {
+			err = stream.send("reset", "", []byte("{}"))
+			if err != nil {
+				return nil
+			}
+		}
+
+		send := func(e events.Event) error {
+			// Users are only told of changes to their own shifts
+			if role != "admin" && !e.Concerns(uid) {
+				return nil
+			}
+
+			update, err := shiftUpdateFor(db, e, uid, role)
+			if err != nil {
+				c.Logger().Errorf("shift update: %s", err)
+				return nil
+			}
+
+			data, err := serialize(c, update)
+			if err != nil {
+				return err
+			}
+
+			return stream.send(update.Type, bus.ID(e), data)
+		}
+
+		for _, e := range missed {
+			err = send(e)
+			if err != nil {
+				return nil
+			}
+		}
+
+		keepalive := time.NewTicker(streamKeepalive)
+		defer keepalive.Stop()
+
+		// Clients send nothing, the request is done once they have gone
+		gone := c.Request().Context().Done()
+
+		for {
+			select {
+			case e, ok := <-sub.Events():
+				// A client which fell behind is disconnected, to resume after the last event it received
+				if !ok {
+					return nil
+				}
+
+				err = send(e)
+			case <-keepalive.C:
+				err = stream.write(": keepalive\n\n")
+			case <-gone:
+				return nil
+			}
+
+			if err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// eventStream writes server-sent events to a response, flushing each to the client
+type eventStream struct {
+	conn net.Conn // the response is written to, if known
+	res  *echo.Response
+}
+
+// send writes the event of the type, with the ID to resume after it unless empty
+func (s *eventStream) send(kind, id string, data []byte) error {
+	msg := fmt.Sprintf("event: %s\n", kind)
+	if id != "" {
+		msg += fmt.Sprintf("id: %s\n", id)
+	}
+
+	// Every line of the data is given its own field, should it be indented
+	lines := strings.ReplaceAll(string(data), "\n", "\ndata: ")
+
+	return s.write(msg + fmt.Sprintf("data: %s\n\n", lines))
+}
+
+func (s *eventStream) write(msg string) error {
+	err := s.extend()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.res.Write([]byte(msg))
+	if err != nil {
+		return err
+	}
+
+	return s.flush()
+}
+
+// flush sends what has been written to the client
+func (s *eventStream) flush() error {
+	err := s.extend()
+	if err != nil {
+		return err
+	}
+
+	s.res.Flush()
+
+	return nil
+}
+
+// extend gives the next write to the connection as long as an update may take to send
+func (s *eventStream) extend() error {
+	if s.conn == nil {
+		return nil
+	}
+
+	return s.conn.SetWriteDeadline(time.Now().Add(updateSendLimit))
+}
+
+// shiftUpdateFor returns the update telling the specified User.ID of the event with the shift as it now stands.
+// A shift the user can no longer see, having been reassigned or unpublished, is sent to them as deleted.
+func shiftUpdateFor(db *gorm.DB, e events.Event, uid, role string) (*shiftUpdate, error) {
+	ext, err := opaque.Encode(opaque.Shift, e.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	update := &shiftUpdate{Type: models.EventShiftDeleted, ID: ext, At: e.At}
+	if e.Type == models.EventShiftDeleted {
+		return update, nil
+	}
+
+	shift, err := models.FindShiftByID(db, e.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return update, nil
+		}
+
+		return nil, err
+	}
+
+	if role == "user" && (shift.UserID != uid || !service.UserVisible(shift)) {
+		return update, nil
+	}
+
+	// Annotate the shift with any holiday and pay differential it falls on
+	err = models.AnnotateShifts(db, []*models.Shift{shift})
+	if err != nil {
+		return nil, err
+	}
+
+	update.Type = e.Type
+	update.Shift = shift
+
+	return update, nil
+}
+
+// serialize encodes the value with the serializer of the request's API version, as it would be in a response
+func serialize(c echo.Context, i interface{}) ([]byte, error) {
+	buf := &bufferWriter{header: http.Header{}}
+
+	ctx := c.Echo().NewContext(c.Request(), buf)
+	for _, key := range []string{"id", "role", "apiversion"} {
+		ctx.Set(key, c.Get(key))
+	}
+
+	err := c.Echo().JSONSerializer.Serialize(ctx, i, "")
+
+	return bytes.TrimSpace(buf.Bytes()), err
+}
+
+// bufferWriter is an http.ResponseWriter holding the body written to it
+type bufferWriter struct {
+	bytes.Buffer
+	header http.Header
+}
+
+func (w *bufferWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferWriter) WriteHeader(int) {}
@@ -3,10 +3,13 @@ package handlers
 import (
 	"errors"
 	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/opaque"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 	"net/http"
+	"reflect"
 	"strconv"
+	"strings"
 )
 
 func CreateUser() func(echo.Context) error {
@@ -22,22 +25,71 @@ func CreateUser() func(echo.Context) error {
 
 		// Prepare a new object to write to the database
 		user := models.User{
-			Name:     data.Name,
-			Password: data.Password,
-			Role:     data.Role,
+			Name:         data.Name,
+			Password:     data.Password,
+			Role:         data.Role,
+			Email:        data.Email,
+			TeamID:       data.TeamID,
+			LocationID:   data.LocationID,
+			ManagerID:    data.ManagerID,
+			Phone:        data.Phone,
+			CustomFields: data.CustomFields,
+			HourlyRate:   data.HourlyRate,
 		}
 
 		// Ensure we have all necessary fields to create the object
 		err = user.Validate()
 		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			return err
 		}
 
 		// Collect the database reference from context
 		db := c.Get("db").(*gorm.DB)
 
+		// Ensure the custom fields match those defined
+		err = models.ValidateCustomFields(db, user.CustomFields)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// Ensure the specified team exists
+		if user.TeamID != "" {
+			_, err = models.FindTeamByID(db, user.TeamID)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return echo.NewHTTPError(http.StatusBadRequest, "team not found")
+				}
+
+				return err
+			}
+		}
+
+		// Ensure the specified location exists
+		if user.LocationID != "" {
+			_, err = models.FindLocationByID(db, user.LocationID)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return echo.NewHTTPError(http.StatusBadRequest, "location not found")
+				}
+
+				return err
+			}
+		}
+
+		// Ensure the specified manager exists
+		if user.ManagerID != "" {
+			err = models.CheckManager(db, "", user.ManagerID)
+			if err != nil {
+				if errors.Is(err, models.ErrManagerNotFound) {
+					return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+				}
+
+				return err
+			}
+		}
+
 		// Ensure there are no other users that already exist with the specified name
-		_, err = models.FindUserByName(db, data.Name)
+		_, err = models.FindUserByName(db.Unscoped(), data.Name)
 		if err == nil {
 			return echo.NewHTTPError(http.StatusConflict, "user already exists")
 		}
@@ -47,6 +99,16 @@ func CreateUser() func(echo.Context) error {
 			return err
 		}
 
+		// Ensure no other user has the specified email address
+		err = models.CheckEmailAvailable(db, "", user.Email)
+		if err != nil {
+			if errors.Is(err, models.ErrEmailTaken) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+
+			return err
+		}
+
 		// Attempt to write the new object to the database
 		err = user.Create(db)
 		if err != nil {
@@ -66,106 +128,427 @@ func UpdateUser() func(echo.Context) error {
 		data := &models.User{}
 		err := c.Bind(data)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest,
-				"invalid object")
+			return bindError(err)
 		}
 
-		// Collect context values
-		role := c.Get("role").(string)
-		uid := c.Get("id").(string)
+		// Collect parameters and context values
+		id := c.Param("id")
 		db := c.Get("db").(*gorm.DB)
 
 		// Prepare a new object to write to the database
 		change := models.User{
-			ID:       uid,
-			Name:     data.Name,
-			Password: data.Password,
-			Role:     data.Role,
+			ID:           id,
+			Name:         data.Name,
+			Password:     data.Password,
+			Role:         data.Role,
+			Email:        data.Email,
+			TeamID:       data.TeamID,
+			LocationID:   data.LocationID,
+			ManagerID:    data.ManagerID,
+			Phone:        data.Phone,
+			CustomFields: data.CustomFields,
+			HourlyRate:   data.HourlyRate,
 		}
 
 		// Ensure we have all necessary fields to update the object
 		err = change.Validate()
 		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			return err
 		}
 
 		// Attempt to fetch the existing user object
-		user, err := models.FindUserByID(db, uid)
+		user, err := models.FindUserByID(db, id)
 		if err != nil {
 			return echo.ErrNotFound
 		}
 
-		// Constrain the user from changing another user's object or their own role if not admin
-		if role == "user" {
-			if user.ID != uid {
-				return echo.ErrUnauthorized
-			}
+		// Ensure there are no zero values before writing
+		if change.TeamID == "" {
+			change.TeamID = user.TeamID
+		}
 
-			if change.Role != user.Role {
-				return echo.ErrUnauthorized
-			}
+		if change.LocationID == "" {
+			change.LocationID = user.LocationID
 		}
 
-		// Ensure that no other user exists with a matching name to the new changes
-		if data.Name != user.Name {
-			check, err := models.FindUserByName(db, data.Name)
-			if err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					// No match was found, change the name in the temporary object
-					change.Name = data.Name
-				} else {
-					return nil
-				}
+		if change.ManagerID == "" {
+			change.ManagerID = user.ManagerID
+		}
+
+		if change.Email == "" {
+			change.Email = user.Email
+		}
+
+		if change.Phone == "" {
+			change.Phone = user.Phone
+		}
+
+		if change.HourlyRate == 0 {
+			change.HourlyRate = user.HourlyRate
+		}
+
+		// Custom fields are replaced as a whole when given
+		if change.CustomFields == nil {
+			change.CustomFields = user.CustomFields
+		}
+
+		return saveUser(c, db, user, &change)
+	}
+}
+
+func PatchUser() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		// Attempt to fetch the existing user object
+		user, err := models.FindUserByID(db, id)
+		if err != nil {
+			return echo.ErrNotFound
+		}
+
+		// Apply the submitted patch to the user, so members left out keep their values and null clears them
+		change := &models.User{}
+		fields, err := bindPatch(c, user, change, models.UserPatchFields...)
+		if err != nil {
+			return err
+		}
+
+		change.ID = user.ID
+
+		// Ensure the resulting object is still valid
+		err = change.Validate()
+		if err != nil {
+			return err
+		}
+
+		// The stored password is already hashed, so it is only written when a new one is given
+		if !fields["password"] {
+			change.Password = ""
+		}
+
+		return saveUser(c, db, user, change)
+	}
+}
+
+// saveUser writes the change to the user after checking the current user may make it and that the
+// team, location, manager, email address and name it refers to are valid
+func saveUser(c echo.Context, db *gorm.DB, user, change *models.User) error {
+	role := c.Get("role").(string)
+	uid := c.Get("id").(string)
+
+	// Constrain the user from changing another user's object or their own role if not admin
+	if role == "user" {
+		if user.ID != uid {
+			return echo.ErrUnauthorized
+		}
+
+		if change.Role != user.Role {
+			return echo.ErrUnauthorized
+		}
+
+		if change.TeamID != user.TeamID || change.LocationID != user.LocationID || change.ManagerID != user.ManagerID {
+			return echo.ErrUnauthorized
+		}
+
+		// Users change their own address through the verified change flow
+		if change.Email != user.Email {
+			return echo.ErrUnauthorized
+		}
+
+		// Custom fields hold data the organization keeps about the user
+		if !reflect.DeepEqual(change.CustomFields, user.CustomFields) {
+			return echo.ErrUnauthorized
+		}
+
+		// Users cannot see their pay, let alone change it
+		if change.HourlyRate != user.HourlyRate {
+			return echo.ErrUnauthorized
+		}
+	}
+
+	// Refuse changes made to a version of the user which has since been changed
+	err := checkIfMatch(c, user.ETag())
+	if err != nil {
+		return err
+	}
+
+	change.Precondition = user.ETag()
+
+	if !reflect.DeepEqual(change.CustomFields, user.CustomFields) {
+		err := models.ValidateCustomFields(db, change.CustomFields)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	// Ensure no other user has the specified email address
+	if change.Email != user.Email {
+		err := models.CheckEmailAvailable(db, user.ID, change.Email)
+		if err != nil {
+			if errors.Is(err, models.ErrEmailTaken) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
 			}
 
-			if check.Name != "" {
-				// Match found, forbid the request
-				return echo.ErrForbidden
+			return err
+		}
+	}
+
+	// Ensure the specified team exists
+	if change.TeamID != user.TeamID && change.TeamID != "" {
+		_, err := models.FindTeamByID(db, change.TeamID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.NewHTTPError(http.StatusBadRequest, "team not found")
 			}
+
+			return err
 		}
+	}
 
-		// Attempt to write the change to the database
-		err = change.Update(db)
+	// Ensure the specified location exists
+	if change.LocationID != user.LocationID && change.LocationID != "" {
+		_, err := models.FindLocationByID(db, change.LocationID)
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return echo.ErrNotFound
+				return echo.NewHTTPError(http.StatusBadRequest, "location not found")
 			}
 
 			return err
 		}
+	}
 
-		change.Password = ""
+	// Ensure the specified manager exists and would not report to the user
+	if change.ManagerID != user.ManagerID {
+		err := models.CheckManager(db, user.ID, change.ManagerID)
+		if err != nil {
+			if errors.Is(err, models.ErrManagerNotFound) || errors.Is(err, models.ErrManagerCycle) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
 
-		return c.JSON(http.StatusOK, change)
+			return err
+		}
 	}
+
+	// Ensure that no other user exists with a matching name to the new changes
+	if change.Name != user.Name {
+		_, err := models.FindUserByName(db.Unscoped(), change.Name)
+		if err == nil {
+			// Match found, forbid the request
+			return echo.ErrForbidden
+		}
+
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+
+	// Attempt to write the change to the database
+	err = change.Update(db)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.ErrNotFound
+		}
+
+		return err
+	}
+
+	change.Password = ""
+
+	setETag(c, change.ETag())
+
+	return c.JSON(http.StatusOK, change)
 }
 
 func ListUsers() func(echo.Context) error {
 	return func(c echo.Context) error {
-		//Safely ignoring error as an invalid limit parameter would return a zero, which is no limit for ListUsers
-		limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+		// A temporary struct to hold our user submitted data for binding
+		var params struct {
+			Search             string `query:"q"`
+			Role               string `query:"role"`
+			Sort               string `query:"sort"`
+			Limit              int    `query:"limit"`
+			Page               int    `query:"page"`
+			Cursor             string `query:"cursor"`
+			IncludeDeactivated bool   `query:"include_deactivated"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		included, err := includes(c, "shifts")
+		if err != nil {
+			return err
+		}
+
+		// Custom fields are filtered on with field.<name>=<value>
+		var fields []models.FieldFilter
+		for key, values := range c.QueryParams() {
+			if name := strings.TrimPrefix(key, "field."); name != key {
+				for _, value := range values {
+					fields = append(fields, models.FieldFilter{Name: name, Value: value})
+				}
+			}
+		}
 
 		// Collect database reference from context
 		db := c.Get("db").(*gorm.DB)
 
+		// Deactivated users are only listed when asked for
+		if params.IncludeDeactivated {
+			db = db.Unscoped()
+		}
+
 		// Attempt to list the users rom the database
-		users, err := models.ListUsers(db, limit)
+		page, err := models.ListUsers(db, models.UserQuery{
+			Search: params.Search,
+			Role:   params.Role,
+			Fields: fields,
+			Sort:   params.Sort,
+			Limit:  params.Limit,
+			Page:   params.Page,
+			Cursor: params.Cursor,
+		})
 		if err != nil {
 			return err
 		}
 
 		// Clear sensitive information from the returned objects
-		for i := range users {
-			users[i].Password = ""
+		for i := range page.Users {
+			page.Users[i].Password = ""
+		}
+
+		// The page is paired with the total and the cursor of the next page in headers, keeping the body a list
+		c.Response().Header().Set("X-Total-Count", strconv.FormatInt(page.Total, 10))
+		if page.NextCursor != "" {
+			c.Response().Header().Set("X-Next-Cursor", page.NextCursor)
 		}
 
+		// Embed the upcoming shifts of the users when asked, fetched together rather than one user at a time
+		if included["shifts"] {
+			err = models.IncludeUserShifts(c.Get("db").(*gorm.DB), page.Users)
+			if err != nil {
+				return err
+			}
+		}
+
+		return c.JSON(http.StatusOK, page.Users)
+	}
+}
+
+func FetchUsers() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// A temporary struct to hold our user submitted data for binding
+		var params struct {
+			IncludeDeactivated bool `query:"include_deactivated"`
+		}
+
+		// Collect the submitted data from the user
+		err := c.Bind(&params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid parameters")
+		}
+
+		included, err := includes(c, "shifts")
+		if err != nil {
+			return err
+		}
+
+		// Collect database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		// Deactivated users are only fetched when asked for
+		if params.IncludeDeactivated {
+			db = db.Unscoped()
+		}
+
+		users, err := fetchUsers(c, db)
+		if err != nil {
+			return err
+		}
+
+		// Embed the upcoming shifts of the users when asked, those the caller may list
+		if included["shifts"] {
+			err = models.IncludeUserShifts(c.Get("db").(*gorm.DB), users, userShiftStatuses(c)...)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.Response().Header().Set("X-Total-Count", strconv.Itoa(len(users)))
+
 		return c.JSON(http.StatusOK, users)
 	}
 }
 
+// userShiftStatuses returns the statuses of the shifts embedded in users for the request, users only being shown
+// the shifts they may list, those published and their own awaiting approval
+func userShiftStatuses(c echo.Context) []string {
+	if c.Get("role").(string) == "user" {
+		return []string{models.ShiftPublished, models.ShiftPending}
+	}
+
+	return nil
+}
+
+// fetchUsers returns the users with the IDs the ids parameter asks for which the user may see, in the order
+// given. Those which do not exist and those the user may not see are left out alike.
+func fetchUsers(c echo.Context, db *gorm.DB) ([]*models.User, error) {
+	ids, err := batchIDs(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect context values
+	role := c.Get("role").(string)
+	uid := c.Get("id").(string)
+
+	found, err := models.FindUsersByIDs(db, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*models.User, len(found))
+	for _, user := range found {
+		byID[user.ID] = user
+	}
+
+	users := []*models.User{}
+	for _, id := range ids {
+		user, ok := byID[id]
+		if !ok {
+			continue
+		}
+
+		// Constrain the user from fetching another user's object if not admin
+		if role == "user" && user.ID != uid {
+			continue
+		}
+
+		// Clear sensitive information from the returned object
+		user.Password = ""
+
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
 func GetUserByID() func(ctx echo.Context) error {
 	return func(c echo.Context) error {
 
+		included, err := includes(c, "shifts")
+		if err != nil {
+			return err
+		}
+
 		// Collect parameters and conext values
 		id := c.Param("id")
 		db := c.Get("db").(*gorm.DB)
@@ -192,11 +575,84 @@ func GetUserByID() func(ctx echo.Context) error {
 		// Clear sensitive information from the returned object
 		user.Password = ""
 
+		if included["shifts"] {
+			err = models.IncludeUserShifts(db, []*models.User{user}, userShiftStatuses(c)...)
+			if err != nil {
+				return err
+			}
+		}
+
+		setETag(c, user.ETag())
+
 		return c.JSON(http.StatusOK, user)
 	}
 }
 
-func DeleteUser() func(ctx echo.Context) error {
+// Me serves a route of the user with the :id parameter as the authenticated user, so that clients need not learn
+// their own ID from their token first
+func Me(h func(echo.Context) error) func(echo.Context) error {
+	return func(c echo.Context) error {
+		c.SetParamNames("id")
+		c.SetParamValues(c.Get("id").(string))
+
+		return h(c)
+	}
+}
+
+// ListMyShifts lists the shifts of the authenticated user, taking the filters and paging of ListShifts
+func ListMyShifts(weeks models.Weeks) func(echo.Context) error {
+	list := ListShifts(weeks)
+
+	return func(c echo.Context) error {
+
+		// The shifts listed are the user's own, rather than those of the IDs or reports asked for
+		query := c.QueryParams()
+		query.Del("ids")
+		query.Del("reports")
+		query.Set("user_id", c.Get("id").(string))
+
+		return list(c)
+	}
+}
+
+func ListDirectReports() func(ctx echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		id := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		// Constrain the user to listing their own reports if not admin
+		if role == "user" && id != uid {
+			return echo.ErrUnauthorized
+		}
+
+		_, err := models.FindUserByID(db, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.ErrNotFound
+			}
+
+			return err
+		}
+
+		users, err := models.ListDirectReports(db, id)
+		if err != nil {
+			return err
+		}
+
+		// Clear sensitive information from the returned objects
+		for i := range users {
+			users[i].Password = ""
+		}
+
+		return c.JSON(http.StatusOK, users)
+	}
+}
+
+func DeactivateUser() func(ctx echo.Context) error {
 	return func(c echo.Context) error {
 
 		// Collect parameters and context values
@@ -213,8 +669,37 @@ func DeleteUser() func(ctx echo.Context) error {
 			return err
 		}
 
-		// Attempt to delete the object from the database
-		err = user.Delete(db)
+		// Prevent admins from locking themselves out
+		if user.ID == c.Get("id").(string) {
+			return echo.NewHTTPError(http.StatusBadRequest, "cannot deactivate yourself")
+		}
+
+		// Refuse to deactivate a version of the user which has since been changed
+		err = checkIfMatch(c, user.ETag())
+		if err != nil {
+			return err
+		}
+
+		user.Precondition = user.ETag()
+
+		// Attempt to deactivate the user, keeping their history
+		err = user.Deactivate(db)
+		if err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+func ReactivateUser() func(ctx echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect parameters and context values
+		uid := c.Param("id")
+		db := c.Get("db").(*gorm.DB)
+
+		user, err := models.FindDeactivatedUserByID(db, uid)
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				return echo.ErrNotFound
@@ -223,6 +708,61 @@ func DeleteUser() func(ctx echo.Context) error {
 			return err
 		}
 
-		return c.NoContent(http.StatusNoContent)
+		err = user.Reactivate(db)
+		if err != nil {
+			return err
+		}
+
+		user.Password = ""
+
+		return c.JSON(http.StatusOK, user)
+	}
+}
+
+func BulkUpdateUsers() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Collect the submitted data from the user
+		data := &struct {
+			Changes []*models.UserChange `json:"changes"`
+		}{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		if len(data.Changes) == 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "changes required")
+		}
+
+		// Resolve the submitted user IDs, reporting results under the IDs as submitted
+		submitted := make([]string, len(data.Changes))
+		for i, change := range data.Changes {
+			submitted[i] = change.UserID
+
+			// IDs which do not resolve are left as submitted and will not be found
+			id, err := opaque.Decode(opaque.User, change.UserID)
+			if err == nil {
+				change.UserID = id
+			}
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		results, err := models.BulkUpdateUsers(db, data.Changes)
+		for i, result := range results {
+			result.UserID = submitted[i]
+		}
+
+		if err != nil {
+			if errors.Is(err, models.ErrBulkUpdateFailed) {
+				return c.JSON(http.StatusUnprocessableEntity, results)
+			}
+
+			return err
+		}
+
+		return c.JSON(http.StatusOK, results)
 	}
 }
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"github.com/btnmasher/shiftr/api/middleware"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+func ValidateUser() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Nothing is written, so nothing is audited
+		middleware.AuditSkip(c)
+
+		// Collect the submitted data from the user, with the ID of the user being changed if any
+		data := &models.User{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect context values
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		// Constrain the user to validating changes to themselves if not admin
+		if role == "user" && data.ID != uid {
+			return echo.ErrUnauthorized
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		errs, err := models.ValidateUser(db, data)
+		if err != nil {
+			return err
+		}
+
+		return validationResult(c, errs)
+	}
+}
+
+func ValidateShift() func(echo.Context) error {
+	return func(c echo.Context) error {
+
+		// Nothing is written, so nothing is audited
+		middleware.AuditSkip(c)
+
+		// Collect the submitted data from the user, with the ID of the shift being changed if any
+		data := &models.Shift{}
+		err := c.Bind(data)
+		if err != nil {
+			return bindError(err)
+		}
+
+		// Collect context values
+		role := c.Get("role").(string)
+		uid := c.Get("id").(string)
+
+		// Constrain the user to validating their own shifts if not admin
+		if role == "user" && data.UserID != uid {
+			return echo.ErrUnauthorized
+		}
+
+		// Collect the database reference from context
+		db := c.Get("db").(*gorm.DB)
+
+		errs, err := models.ValidateShift(db, data, role)
+		if err != nil {
+			return err
+		}
+
+		return validationResult(c, errs)
+	}
+}
+
+// validationResult responds with the field errors of a validated object, translated into the request's locale
+func validationResult(c echo.Context, errs []*models.FieldError) error {
+	locale, catalog := RequestLocale(c), requestCatalog(c)
+	for _, e := range errs {
+		e.Message = catalog.Translate(locale, e.Message)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"valid":  len(errs) == 0,
+		"errors": errs,
+	})
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"github.com/btnmasher/shiftr/logging"
+	"github.com/labstack/echo/v4"
+	"strconv"
+	"time"
+)
+
+// AccessLog logs every request once answered, with its method, URI, status, latency and the sizes of the request
+// and response, through the logger of the request so the line carries its ID. Requests answered with a server
+// error are logged at the error level, the rest at the info level. Tokens in the URI are redacted by the logger.
+func AccessLog(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+
+		// The error is answered here, so the status logged is the one the client was sent
+		err := next(c)
+		if err != nil {
+			c.Error(err)
+		}
+
+		req := c.Request()
+		res := c.Response()
+
+		bytesIn, _ := strconv.ParseInt(req.Header.Get(echo.HeaderContentLength), 10, 64)
+
+		log := logging.FromEcho(c.Logger())
+
+		event := log.Info()
+		if res.Status >= 500 {
+			event = log.Error()
+		}
+
+		if err != nil {
+			event = event.Err(err)
+		}
+
+		event.
+			Str("remote_ip", c.RealIP()).
+			Str("host", req.Host).
+			Str("method", req.Method).
+			Str("uri", req.RequestURI).
+			Str("user_agent", req.UserAgent()).
+			Int("status", res.Status).
+			Dur("latency", time.Since(start)).
+			Int64("bytes_in", bytesIn).
+			Int64("bytes_out", res.Size).
+			Msg("request")
+
+		return err
+	}
+}
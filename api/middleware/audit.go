@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// auditSubjectsKey is the context key holding the users affected by the request
+const auditSubjectsKey = "auditsubjects"
+
+// auditSkipKey is the context key marking a request which does not modify data despite its method
+const auditSkipKey = "auditskip"
+
+// AuditSkip keeps the request out of the audit trail, for requests such as validation which use a method
+// that usually modifies data without modifying any
+func AuditSkip(c echo.Context) {
+	c.Set(auditSkipKey, true)
+}
+
+// AuditSubjects records the specified User.IDs as affected by the request, so its audit trail entry
+// appears in their activity as well as that of the user who made it
+func AuditSubjects(c echo.Context, uids ...string) {
+	subjects, _ := c.Get(auditSubjectsKey).([]string)
+	c.Set(auditSubjectsKey, append(subjects, uids...))
+}
+
+// Audit appends the requests which modify data to the audit trail once they succeed, along with the user
+// who made them. Failing to record a change is logged rather than failing a request which already made it.
+func Audit(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		err := next(c)
+
+		switch c.Request().Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return err
+		}
+
+		if skip, _ := c.Get(auditSkipKey).(bool); skip {
+			return err
+		}
+
+		// Errors have not been written to the response yet
+		status := c.Response().Status
+		if err != nil {
+			status = http.StatusInternalServerError
+
+			var he *echo.HTTPError
+			if errors.As(err, &he) {
+				status = he.Code
+			}
+		}
+
+		if status >= http.StatusBadRequest {
+			return err
+		}
+
+		db, ok := c.Get("db").(*gorm.DB)
+		if !ok {
+			return err
+		}
+
+		uid, _ := c.Get("id").(string)
+		role, _ := c.Get("role").(string)
+		subjects, _ := c.Get(auditSubjectsKey).([]string)
+
+		entry := &models.AuditEntry{
+			At:       time.Now(),
+			UserID:   uid,
+			Role:     role,
+			Method:   c.Request().Method,
+			Route:    c.Path(),
+			Path:     c.Request().URL.Path,
+			Status:   status,
+			Subjects: subjects,
+		}
+
+		if aerr := models.AppendAudit(db, entry); aerr != nil {
+			c.Logger().Errorf("audit: %s", aerr)
+		}
+
+		return err
+	}
+}
@@ -3,6 +3,8 @@ package middleware
 import (
 	"errors"
 	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/api/policy"
+	"github.com/btnmasher/shiftr/opaque"
 	"github.com/btnmasher/shiftr/utils"
 	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
@@ -12,9 +14,10 @@ import (
 )
 
 type claims struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-	Role string `json:"role"`
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Role  string `json:"role"`
+	Email string `json:"email,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -41,23 +44,31 @@ func Login(c echo.Context) error {
 		return echo.ErrUnauthorized
 	}
 
+	// Record who logged in for the audit trail
+	c.Set("id", user.ID)
+	c.Set("role", user.Role)
+
+	// The token carries the external ID so the internal one is never exposed
+	sub, err := opaque.Encode(opaque.User, user.ID)
+	if err != nil {
+		return err
+	}
+
 	// Set custom claims
 	claims := &claims{
-		user.ID,
+		sub,
 		user.Name,
 		user.Role,
+		user.Email,
 		jwt.StandardClaims{
 			ExpiresAt: time.Now().Add(time.Hour * 72).Unix(),
 		},
 	}
 
-	// Create token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	secret := c.Get("jwtsecret").(string)
+	keys := c.Get("jwtkeys").(*KeySet)
 
-	// Generate encoded token and send it as response.
-	t, err := token.SignedString([]byte(secret))
+	// Generate encoded token signed with the current key and send it as response.
+	t, err := keys.Sign(claims)
 	if err != nil {
 		return err
 	}
@@ -67,6 +78,71 @@ func Login(c echo.Context) error {
 	})
 }
 
+// subject returns the internal ID of the user the token was issued to
+func subject(cl jwt.MapClaims) (string, error) {
+	id, _ := cl["id"].(string)
+
+	return opaque.Decode(opaque.User, id)
+}
+
+// active returns 401 Unauthorized if the user the token was issued to has since been deactivated or removed
+func active(c echo.Context, id string) error {
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok {
+		return nil
+	}
+
+	return activeUser(db, id)
+}
+
+// activeUser returns 401 Unauthorized unless the specified User.ID belongs to a user who is not deactivated
+func activeUser(db *gorm.DB, id string) error {
+	var count int64
+
+	err := db.Model(&models.User{}).Where("id = ?", id).Count(&count).Error
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return echo.ErrUnauthorized
+	}
+
+	return nil
+}
+
+// Authenticate verifies the token against the signing keys, returning the internal ID and role of the active user
+// it was issued to. It serves callers outside of echo, such as the gRPC API, and returns 401 Unauthorized for any
+// token which would be refused by the routes accessible to users.
+func Authenticate(db *gorm.DB, keys *KeySet, token string) (id, role string, err error) {
+	parsed, err := jwt.Parse(token, keys.Keyfunc)
+	if err != nil || !parsed.Valid {
+		return "", "", echo.ErrUnauthorized
+	}
+
+	cl, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", "", echo.ErrUnauthorized
+	}
+
+	role, _ = cl["role"].(string)
+	if role != "user" && role != "admin" {
+		return "", "", echo.ErrUnauthorized
+	}
+
+	id, err = subject(cl)
+	if err != nil {
+		return "", "", echo.ErrUnauthorized
+	}
+
+	err = activeUser(db, id)
+	if err != nil {
+		return "", "", err
+	}
+
+	return id, role, nil
+}
+
 func UserAccessible(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		user := c.Get("user")
@@ -82,7 +158,17 @@ func UserAccessible(next echo.HandlerFunc) echo.HandlerFunc {
 			return echo.ErrUnauthorized
 		}
 
-		c.Set("id", cl["id"])
+		id, err := subject(cl)
+		if err != nil {
+			return echo.ErrUnauthorized
+		}
+
+		err = active(c, id)
+		if err != nil {
+			return err
+		}
+
+		c.Set("id", id)
 		c.Set("role", cl["role"])
 
 		return next(c)
@@ -104,7 +190,17 @@ func AdminAccessible(next echo.HandlerFunc) echo.HandlerFunc {
 			return echo.ErrUnauthorized
 		}
 
-		c.Set("id", cl["id"])
+		id, err := subject(cl)
+		if err != nil {
+			return echo.ErrUnauthorized
+		}
+
+		err = active(c, id)
+		if err != nil {
+			return err
+		}
+
+		c.Set("id", id)
 		c.Set("role", cl["role"])
 
 		return next(c)
@@ -119,3 +215,18 @@ func TestAccessible(next echo.HandlerFunc) echo.HandlerFunc {
 		return next(c)
 	}
 }
+
+// Enforce returns the access middleware which implements the declared route policy
+func Enforce(p policy.Policy) echo.MiddlewareFunc {
+	if p.Public {
+		return func(next echo.HandlerFunc) echo.HandlerFunc {
+			return next
+		}
+	}
+
+	if p.Allows("user") {
+		return UserAccessible
+	}
+
+	return AdminAccessible
+}
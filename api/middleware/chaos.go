@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HeaderFaultInjected marks responses which were deliberately delayed or failed by a FaultInjector
+const HeaderFaultInjected = "X-Shiftr-Fault-Injected"
+
+// FaultRule describes the faults to inject into a percentage of the requests to matching routes.
+// A request is delayed, failed with the status, or both.
+type FaultRule struct {
+	Method string        // HTTP method to match, empty for any
+	Path   string        // route path to match such as /api/v1/shifts/:id, a trailing * matches a prefix, empty for any
+	Rate   float64       // fraction of matching requests to affect, from 0 to 1
+	Delay  time.Duration // added before the request is handled
+	Status int           // error status returned instead of handling the request, 0 to only delay
+}
+
+func (r FaultRule) matches(method, path string) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, method) {
+		return false
+	}
+
+	if strings.HasSuffix(r.Path, "*") {
+		return strings.HasPrefix(path, strings.TrimSuffix(r.Path, "*"))
+	}
+
+	return r.Path == "" || r.Path == path
+}
+
+// FaultInjector is testing middleware which injects delays and server errors into requests according
+// to its rules, so clients can exercise their timeout and retry handling. The first matching rule applies.
+type FaultInjector struct {
+	mu    sync.Mutex
+	rules []FaultRule
+	rand  *rand.Rand
+}
+
+// NewFaultInjector returns a FaultInjector applying the rules
+func NewFaultInjector(rules ...FaultRule) *FaultInjector {
+	return &FaultInjector{
+		rules: rules,
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Inject is middleware applying the first rule matching the request's method and route path
+func (f *FaultInjector) Inject(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		rule, ok := f.roll(c.Request().Method, c.Path())
+		if !ok {
+			return next(c)
+		}
+
+		c.Response().Header().Set(HeaderFaultInjected, "true")
+
+		if rule.Delay > 0 {
+			select {
+			case <-time.After(rule.Delay):
+			case <-c.Request().Context().Done():
+				return c.Request().Context().Err()
+			}
+		}
+
+		if rule.Status > 0 {
+			return echo.NewHTTPError(rule.Status, http.StatusText(rule.Status)+" (injected fault)")
+		}
+
+		return next(c)
+	}
+}
+
+// roll finds the rule matching the request and decides whether this request is affected by it
+func (f *FaultInjector) roll(method, path string) (FaultRule, bool) {
+	for _, rule := range f.rules {
+		if !rule.matches(method, path) {
+			continue
+		}
+
+		f.mu.Lock()
+		hit := f.rand.Float64() < rule.Rate
+		f.mu.Unlock()
+
+		return rule, hit
+	}
+
+	return FaultRule{}, false
+}
@@ -0,0 +1,271 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"github.com/labstack/echo/v4"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Compression configures the compression of responses to clients accepting it
+type Compression struct {
+	Level     int  // of gzip from 1 to 9, and of brotli from 0 to 11, the default of each when 0
+	MinLength int  // size in bytes below which responses are sent as they are
+	Brotli    bool // whether brotli is offered, preferred to gzip by clients accepting both equally
+}
+
+// incompressibleTypes are the prefixes of the content types which are already compressed
+var incompressibleTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/vnd.openxmlformats-",
+}
+
+// Compress compresses the responses to the clients accepting it with gzip, or brotli when enabled, once they are
+// at least MinLength long. Responses of types which are already compressed or which already have an encoding
+// are sent as they are, as are websockets and event streams, which stream the connection themselves.
+func Compress(config Compression) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.Method == http.MethodHead || req.Header.Get(echo.HeaderUpgrade) != "" ||
+				strings.Contains(req.Header.Get(echo.HeaderAccept), "text/event-stream") {
+				return next(c)
+			}
+
+			res := c.Response()
+			res.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+
+			encoding := preferredEncoding(req.Header.Get(echo.HeaderAcceptEncoding), config.Brotli)
+			if encoding == "" {
+				return next(c)
+			}
+
+			cw := &compressWriter{ResponseWriter: res.Writer, config: config, encoding: encoding}
+			res.Writer = cw
+
+			defer func() {
+				res.Writer = cw.ResponseWriter
+
+				if err := cw.Close(); err != nil {
+					c.Logger().Errorf("compress: %s", err)
+				}
+			}()
+
+			return next(c)
+		}
+	}
+}
+
+// preferredEncoding returns the content coding the Accept-Encoding header prefers of gzip and, when offered,
+// brotli, or an empty string when it accepts neither
+func preferredEncoding(header string, offerBrotli bool) string {
+	gz := encodingQuality(header, "gzip")
+
+	if offerBrotli {
+		if br := encodingQuality(header, "br"); br > 0 && br >= gz {
+			return "br"
+		}
+	}
+
+	if gz > 0 {
+		return "gzip"
+	}
+
+	return ""
+}
+
+// encodingQuality returns the quality the Accept-Encoding header gives the content coding
+func encodingQuality(header, coding string) float64 {
+	quality, exact := 0.0, false
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name != coding && (name != "*" || exact) {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err != nil {
+					v = 0
+				}
+
+				q = v
+			}
+		}
+
+		quality, exact = q, name == coding
+	}
+
+	return quality
+}
+
+// compressWriter holds the start of the response until it is known to be long enough to compress, then writes it
+// through the encoder, or as it is when shorter or not worth compressing
+type compressWriter struct {
+	http.ResponseWriter
+	config   Compression
+	encoding string
+
+	buf     bytes.Buffer
+	status  int
+	decided bool
+	encoder io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	w.status = status
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf.Write(b)
+		if w.buf.Len() < w.config.MinLength {
+			return len(b), nil
+		}
+
+		err := w.decide(true)
+		if err != nil {
+			return 0, err
+		}
+
+		return len(b), nil
+	}
+
+	if w.encoder != nil {
+		return w.encoder.Write(b)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// decide writes the header of the response, compressing it when long enough and worth it, and whatever of it has
+// been held
+func (w *compressWriter) decide(long bool) error {
+	w.decided = true
+
+	header := w.Header()
+	if long && header.Get(echo.HeaderContentEncoding) == "" && compressible(header.Get(echo.HeaderContentType)) {
+		header.Set(echo.HeaderContentEncoding, w.encoding)
+		header.Del(echo.HeaderContentLength)
+
+		switch w.encoding {
+		case "br":
+			level := brotli.DefaultCompression
+			if w.config.Level != 0 {
+				level = w.config.Level
+			}
+
+			w.encoder = brotli.NewWriterLevel(w.ResponseWriter, level)
+		default:
+			level := gzip.DefaultCompression
+			if w.config.Level != 0 {
+				level = w.config.Level
+			}
+
+			gz, err := gzip.NewWriterLevel(w.ResponseWriter, level)
+			if err != nil {
+				return err
+			}
+
+			w.encoder = gz
+		}
+	}
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+
+	if w.buf.Len() == 0 {
+		return nil
+	}
+
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+
+	w.buf.Reset()
+
+	return err
+}
+
+// Flush writes whatever of the response has been held, compressed when already decided so, to the client
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(false); err != nil {
+			return
+		}
+	}
+
+	if gz, ok := w.encoder.(*gzip.Writer); ok {
+		_ = gz.Flush()
+	} else if br, ok := w.encoder.(*brotli.Writer); ok {
+		_ = br.Flush()
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hands the connection over to the handler, bypassing compression
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.decided = true
+
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	return hijacker.Hijack()
+}
+
+// Close writes the rest of the response, ending the compressed stream
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		return w.decide(false)
+	}
+
+	if w.encoder != nil {
+		return w.encoder.Close()
+	}
+
+	return nil
+}
+
+// compressible reports whether responses of the content type are worth compressing
+func compressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, prefix := range incompressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"github.com/btnmasher/shiftr/opaque"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+)
+
+// externalRoutes maps the route paths whose :id parameter holds the external ID of an object to its kind
+var externalRoutes = map[string]opaque.Kind{
+	"/users/:id":  opaque.User,
+	"/shifts/:id": opaque.Shift,
+}
+
+// externalQuery maps the query parameters which hold the external ID of an object to its kind
+var externalQuery = map[string]opaque.Kind{
+	"user_id":  opaque.User,
+	"shift_id": opaque.Shift,
+}
+
+// externalLists maps the route paths taking a comma separated ids parameter to the kind of the IDs it holds
+var externalLists = map[string]opaque.Kind{
+	"/users/batch": opaque.User,
+	"/shifts":      opaque.Shift,
+}
+
+// ExternalIDs resolves the external IDs of users and shifts given in the route and query parameters
+// into their internal IDs before the request is handled. IDs which do not resolve are not found, those of an ids
+// parameter are left out as they match nothing.
+func ExternalIDs(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if opaque.CurrentCodec() == nil {
+			return next(c)
+		}
+
+		for route, kind := range externalRoutes {
+			if !strings.Contains(c.Path()+"/", route+"/") {
+				continue
+			}
+
+			values := append([]string(nil), c.ParamValues()...)
+			for i, name := range c.ParamNames() {
+				if name != "id" {
+					continue
+				}
+
+				id, err := opaque.Decode(kind, values[i])
+				if err != nil {
+					return echo.ErrNotFound
+				}
+
+				values[i] = id
+			}
+
+			c.SetParamValues(values...)
+		}
+
+		query := c.Request().URL.Query()
+		for param, kind := range externalQuery {
+			if _, ok := query[param]; !ok {
+				continue
+			}
+
+			id, err := opaque.Decode(kind, query.Get(param))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid "+param)
+			}
+
+			query.Set(param, id)
+		}
+
+		if _, ok := query["ids"]; ok {
+			for route, kind := range externalLists {
+				if !strings.HasSuffix(c.Path(), route) {
+					continue
+				}
+
+				var ids []string
+				for _, ext := range strings.Split(strings.Join(query["ids"], ","), ",") {
+					id, err := opaque.Decode(kind, strings.TrimSpace(ext))
+					if err == nil && id != "" {
+						ids = append(ids, id)
+					}
+				}
+
+				query.Set("ids", strings.Join(ids, ","))
+			}
+		}
+
+		c.Request().URL.RawQuery = query.Encode()
+
+		return next(c)
+	}
+}
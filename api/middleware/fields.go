@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"strings"
+)
+
+// FieldsSerializer is an echo.JSONSerializer which answers the lists fetched with a fields parameter, such as
+// fields=id,start,end, with only those fields of each item in the order given, so that clients fetching large
+// lists need not be sent the fields they do not use. Fields the items do not have are left out, and responses
+// which are not lists are answered whole.
+type FieldsSerializer struct {
+	echo.JSONSerializer
+}
+
+// Serialize encodes the value to the response with the wrapped serializer, keeping only the requested fields
+func (s FieldsSerializer) Serialize(c echo.Context, i interface{}, indent string) error {
+	fields := requestedFields(c.QueryParam("fields"))
+	if len(fields) == 0 {
+		return s.JSONSerializer.Serialize(c, i, indent)
+	}
+
+	// The wrapped serializer is held to, so the fields are selected from the JSON it would have answered with
+	res := c.Response()
+	size := res.Size
+	held := &heldResponse{ResponseWriter: res.Writer}
+	res.Writer = held
+
+	err := s.JSONSerializer.Serialize(c, i, "")
+
+	res.Writer = held.ResponseWriter
+	res.Size = size
+
+	if err != nil {
+		return err
+	}
+
+	data, err := selectFields(held.body.Bytes(), fields)
+	if err != nil {
+		return err
+	}
+
+	if indent != "" {
+		var buf bytes.Buffer
+
+		err = json.Indent(&buf, data, "", indent)
+		if err != nil {
+			return err
+		}
+
+		data = buf.Bytes()
+	}
+
+	_, err = res.Write(append(data, '\n'))
+
+	return err
+}
+
+// requestedFields splits the comma separated fields parameter, dropping empty and repeated fields
+func requestedFields(param string) []string {
+	var fields []string
+	seen := map[string]bool{}
+
+	for _, field := range strings.Split(param, ",") {
+		field = strings.TrimSpace(field)
+		if field != "" && !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// selectFields returns the JSON with only the fields of each object of a list, in the order given. JSON which is
+// not a list is returned unchanged, as are the items of a list which are not objects.
+func selectFields(data []byte, fields []string) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '[' {
+		return data, nil
+	}
+
+	var items []json.RawMessage
+
+	err := json.Unmarshal(data, &items)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+
+	for i, item := range items {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		var members map[string]json.RawMessage
+		if json.Unmarshal(item, &members) != nil {
+			buf.Write(item)
+			continue
+		}
+
+		buf.WriteByte('{')
+
+		written := 0
+		for _, field := range fields {
+			value, ok := members[field]
+			if !ok {
+				continue
+			}
+
+			if written > 0 {
+				buf.WriteByte(',')
+			}
+
+			name, err := json.Marshal(field)
+			if err != nil {
+				return nil, err
+			}
+
+			buf.Write(name)
+			buf.WriteByte(':')
+			buf.Write(value)
+			written++
+		}
+
+		buf.WriteByte('}')
+	}
+
+	buf.WriteByte(']')
+
+	return buf.Bytes(), nil
+}
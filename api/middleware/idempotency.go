@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// maxReplayBody is the largest response stored to be replayed, larger ones leave their request to be retried
+const maxReplayBody = 1 << 20
+
+// unreplayedHeaders are the response headers which belong to the response as sent rather than to the request
+// answered. The body is recorded before it is encoded, and every request has an ID of its own.
+var unreplayedHeaders = []string{echo.HeaderContentEncoding, echo.HeaderContentLength, echo.HeaderXRequestID}
+
+// Idempotency replays the response to a request made with an Idempotency-Key header to retries of it by the same
+// user within the window, so that a client unsure whether its request went through may safely send it again.
+// The key of a request which failed with an error or a 5xx status is forgotten, so the request may be retried.
+// A key used again for a different request is refused with 422 Unprocessable Entity, and a retry sent while the
+// request is still being handled with 409 Conflict.
+func Idempotency(window time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get("Idempotency-Key")
+			if key == "" {
+				return next(c)
+			}
+
+			if len(key) > models.MaxIdempotencyKey {
+				return echo.NewHTTPError(http.StatusBadRequest,
+					fmt.Sprintf("Idempotency-Key cannot be longer than %d characters", models.MaxIdempotencyKey))
+			}
+
+			body, err := ioutil.ReadAll(c.Request().Body)
+			if err != nil {
+				return err
+			}
+
+			c.Request().Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			db := c.Get("db").(*gorm.DB)
+			uid, _ := c.Get("id").(string)
+
+			digest := fingerprint(c.Request(), body)
+
+			req, created, err := models.BeginIdempotentRequest(db, uid, key, digest, window)
+			if err != nil {
+				return err
+			}
+
+			if !created {
+				return replay(c, req, digest)
+			}
+
+			// The request is forgotten unless its response is stored, even should the handler panic
+			stored := false
+			defer func() {
+				if !stored {
+					if rerr := req.Release(db); rerr != nil {
+						c.Logger().Errorf("idempotency: %s", rerr)
+					}
+				}
+			}()
+
+			recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+
+			err = next(c)
+
+			c.Response().Writer = recorder.ResponseWriter
+
+			status := c.Response().Status
+			if err != nil || status >= http.StatusInternalServerError || recorder.overflow {
+				return err
+			}
+
+			// Failing to store the response leaves the request to be retried rather than failing it
+			err = req.Complete(db, status, replayHeader(c.Response().Header()), recorder.body.Bytes())
+			if err != nil {
+				c.Logger().Errorf("idempotency: %s", err)
+				return nil
+			}
+
+			stored = true
+
+			return nil
+		}
+	}
+}
+
+// fingerprint identifies the request by its method, path and body
+func fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// replay answers a retry with the response stored for the request, unless it is a different request or the
+// request is still being handled
+func replay(c echo.Context, req *models.IdempotentRequest, digest string) error {
+	if req.Fingerprint != digest {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "Idempotency-Key was used for a different request")
+	}
+
+	if !req.Completed() {
+		return echo.NewHTTPError(http.StatusConflict, "a request with this Idempotency-Key is still being handled")
+	}
+
+	header, err := req.ResponseHeader()
+	if err != nil {
+		return err
+	}
+
+	// Nothing is written by a replay, so nothing is audited
+	AuditSkip(c)
+
+	res := c.Response()
+	for name, values := range replayHeader(header) {
+		res.Header()[name] = values
+	}
+
+	res.Header().Set("Idempotent-Replayed", "true")
+	res.WriteHeader(req.Status)
+
+	_, err = res.Write(req.Body)
+
+	return err
+}
+
+// replayHeader returns a copy of the response header without the headers which are not replayed
+func replayHeader(header http.Header) http.Header {
+	header = header.Clone()
+	for _, name := range unreplayedHeaders {
+		header.Del(name)
+	}
+
+	return header
+}
+
+// responseRecorder copies the body written to the response, up to maxReplayBody
+type responseRecorder struct {
+	http.ResponseWriter
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if !r.overflow {
+		if r.body.Len()+len(b) > maxReplayBody {
+			r.overflow = true
+			r.body.Reset()
+		} else {
+			r.body.Write(b)
+		}
+	}
+
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// idempotentServer returns a server answering POST /shifts through the Idempotency middleware with the handler,
+// as the user named in the X-User header
+func idempotentServer(t *testing.T, handler echo.HandlerFunc) *echo.Echo {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "shiftr.db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent requests take turns on the database, as SQLite would have them
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB.SetMaxOpenConns(1)
+
+	_, err = models.Migrate(db, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.POST("/shifts", handler, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("db", db)
+			c.Set("id", c.Request().Header.Get("X-User"))
+
+			return next(c)
+		}
+	}, Idempotency(time.Hour))
+
+	return e
+}
+
+// post sends the body to POST /shifts as the user, with the Idempotency-Key unless empty
+func post(e *echo.Echo, uid, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/shifts", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("X-User", uid)
+
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestIdempotencyReplays(t *testing.T) {
+	var handled int32
+
+	e := idempotentServer(t, func(c echo.Context) error {
+		n := atomic.AddInt32(&handled, 1)
+		return c.JSON(http.StatusCreated, echo.Map{"created": n})
+	})
+
+	first := post(e, "u1", "create-1", `{"start":"09:00"}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first request: got %d %s", first.Code, first.Body)
+	}
+
+	// A retry is answered with the response to the request, without handling it again
+	retry := post(e, "u1", "create-1", `{"start":"09:00"}`)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry: got %d %s, want %d %s", retry.Code, retry.Body, first.Code, first.Body)
+	}
+
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("retry: not marked as replayed")
+	}
+
+	if n := atomic.LoadInt32(&handled); n != 1 {
+		t.Errorf("handled %d times, want 1", n)
+	}
+
+	// The same key of another user, and requests without a key, are handled of their own
+	for _, req := range []struct{ uid, key string }{{"u2", "create-1"}, {"u1", ""}} {
+		rec := post(e, req.uid, req.key, `{"start":"09:00"}`)
+		if rec.Code != http.StatusCreated || rec.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("user %s with key %q: got %d %s, want it handled", req.uid, req.key, rec.Code, rec.Body)
+		}
+	}
+
+	if n := atomic.LoadInt32(&handled); n != 3 {
+		t.Errorf("handled %d times, want 3", n)
+	}
+}
+
+func TestIdempotencyRejects(t *testing.T) {
+	var handled int32
+
+	e := idempotentServer(t, func(c echo.Context) error {
+		atomic.AddInt32(&handled, 1)
+		return c.NoContent(http.StatusCreated)
+	})
+
+	rec := post(e, "u1", "create-1", `{"start":"09:00"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("first request: got %d %s", rec.Code, rec.Body)
+	}
+
+	// The key used again for a different request is refused, and the request is not handled
+	rec = post(e, "u1", "create-1", `{"start":"10:00"}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("key reused with a different body: got %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+
+	rec = post(e, "u1", strings.Repeat("k", models.MaxIdempotencyKey+1), `{"start":"09:00"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("key too long: got %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	if n := atomic.LoadInt32(&handled); n != 1 {
+		t.Errorf("handled %d times, want 1", n)
+	}
+}
+
+func TestIdempotencyForgetsFailures(t *testing.T) {
+	var handled int32
+
+	e := idempotentServer(t, func(c echo.Context) error {
+		if atomic.AddInt32(&handled, 1) == 1 {
+			return echo.NewHTTPError(http.StatusServiceUnavailable, "try again")
+		}
+
+		return c.NoContent(http.StatusCreated)
+	})
+
+	// A failed request is not replayed, so its retry is handled
+	rec := post(e, "u1", "create-1", `{"start":"09:00"}`)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("failing request: got %d %s", rec.Code, rec.Body)
+	}
+
+	rec = post(e, "u1", "create-1", `{"start":"09:00"}`)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("retry of a failed request: got %d %s, want it handled", rec.Code, rec.Body)
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	var handled int32
+
+	started, release := make(chan struct{}), make(chan struct{})
+
+	e := idempotentServer(t, func(c echo.Context) error {
+		if atomic.AddInt32(&handled, 1) == 1 {
+			close(started)
+			<-release
+		}
+
+		return c.String(http.StatusCreated, "created")
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- post(e, "u1", "create-1", `{"start":"09:00"}`)
+	}()
+
+	<-started
+
+	// A retry sent while the request is handled is refused rather than handled again
+	rec := post(e, "u1", "create-1", `{"start":"09:00"}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("retry while in flight: got %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	close(release)
+
+	if rec := <-done; rec.Code != http.StatusCreated {
+		t.Fatalf("request in flight: got %d %s", rec.Code, rec.Body)
+	}
+
+	// Once handled, retries are replayed
+	rec = post(e, "u1", "create-1", `{"start":"09:00"}`)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry once handled: got %d %s, want the response replayed", rec.Code, rec.Body)
+	}
+
+	// Of retries racing each other, only one is handled
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			post(e, "u1", "create-2", `{"start":"10:00"}`)
+		}()
+	}
+
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&handled); n != 2 {
+		t.Errorf("handled %d times, want 2", n)
+	}
+}
@@ -0,0 +1,299 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/secrets"
+	"github.com/golang-jwt/jwt"
+	"gorm.io/gorm"
+	"sync"
+	"time"
+)
+
+const (
+	keyRefresh = time.Minute     // how long stored keys are used before being loaded again, to pick up rotations
+	keyRecheck = 5 * time.Second // how often an unknown kid may cause the stored keys to be loaded again
+)
+
+// signingKey is a JWT HMAC secret identified by the "kid" header of the tokens it signs
+type signingKey struct {
+	id      string
+	secret  []byte
+	expires time.Time // zero for the current key
+}
+
+// KeyInfo describes a signing key without revealing its secret
+type KeyInfo struct {
+	ID      string     `json:"kid"`
+	Current bool       `json:"current"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// KeySet holds the current JWT signing key along with retired keys which are still accepted
+// for verification until their grace period ends, so the secret can be rotated without
+// instantly invalidating every outstanding token
+type KeySet struct {
+	mu      sync.RWMutex
+	current signingKey
+	retired []signingKey
+	grace   time.Duration
+	initial string // id of the configured secret, assumed for tokens issued without a kid
+
+	db      *gorm.DB  // storing the keys, nil when they are only held in memory
+	loaded  time.Time // when the stored keys were last loaded
+	checked time.Time // when the stored keys were last loaded for an unknown kid
+}
+
+// NewKeySet returns a KeySet using the secret as its current key, retiring keys for the grace period when rotated
+func NewKeySet(secret string, grace time.Duration) *KeySet {
+	key := newSigningKey([]byte(secret))
+
+	return &KeySet{
+		current: key,
+		grace:   grace,
+		initial: key.id,
+	}
+}
+
+// LoadKeySet returns a KeySet storing its keys in the database, so that a rotation survives restarts and the tokens
+// signed by one server are accepted by every server sharing the database. The secret becomes the current key when
+// none is stored yet, unless store is false, as for a read-only database, in which case it is only used until then.
+func LoadKeySet(db *gorm.DB, secret string, grace time.Duration, store bool) (*KeySet, error) {
+	k := NewKeySet(secret, grace)
+	k.db = db
+
+	if store {
+		err := models.SeedSigningKey(db, &models.SigningKey{ID: k.current.id, Secret: secrets.String(secret)})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	err := k.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// load replaces the keys held with those stored, keeping the current key held when none is stored.
+// It must be called with the lock held.
+func (k *KeySet) load() error {
+	stored, err := models.ListSigningKeys(k.db)
+	if err != nil {
+		return err
+	}
+
+	current := k.current
+	var retired []signingKey
+
+	for _, sk := range stored {
+		key := newSigningKey([]byte(sk.Secret))
+		if key.id != sk.ID {
+			return fmt.Errorf("stored jwt key id=%s does not match its secret", sk.ID)
+		}
+
+		if sk.ExpiresAt == nil {
+			current = key
+			continue
+		}
+
+		key.expires = *sk.ExpiresAt
+		retired = append(retired, key)
+	}
+
+	k.current = current
+	k.retired = retired
+	k.loaded = time.Now()
+
+	return nil
+}
+
+// refresh loads the stored keys again once those held are older than the age
+func (k *KeySet) refresh(age time.Duration) error {
+	if k.db == nil {
+		return nil
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if time.Since(k.loaded) < age {
+		return nil
+	}
+
+	return k.load()
+}
+
+func newSigningKey(secret []byte) signingKey {
+	sum := sha256.Sum256(secret)
+
+	return signingKey{
+		id:     hex.EncodeToString(sum[:8]),
+		secret: secret,
+	}
+}
+
+// Sign creates a token with the claims signed by the current key
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	err := k.refresh(keyRefresh)
+	if err != nil {
+		return "", err
+	}
+
+	k.mu.RLock()
+	key := k.current
+	k.mu.RUnlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = key.id
+
+	return token.SignedString(key.secret)
+}
+
+// Keyfunc resolves the key a token was signed with, rejecting unknown and expired keys
+func (k *KeySet) Keyfunc(t *jwt.Token) (interface{}, error) {
+	if t.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+		return nil, fmt.Errorf("unexpected jwt signing method=%v", t.Header["alg"])
+	}
+
+	kid, ok := t.Header["kid"].(string)
+	if !ok {
+		kid = k.initial
+	}
+
+	secret, ok := k.find(kid)
+	if ok {
+		return secret, nil
+	}
+
+	// The key may have been rotated to by another server since the keys were loaded
+	if k.recheck() {
+		secret, ok = k.find(kid)
+		if ok {
+			return secret, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected jwt key id=%v", t.Header["kid"])
+}
+
+// find returns the secret of the key with the id, unless it is unknown or expired
+func (k *KeySet) find(kid string) ([]byte, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if kid == k.current.id {
+		return k.current.secret, true
+	}
+
+	for _, key := range k.retired {
+		if key.id == kid && time.Now().Before(key.expires) {
+			return key.secret, true
+		}
+	}
+
+	return nil, false
+}
+
+// recheck loads the stored keys again for an unknown kid, at most once every keyRecheck, so that tokens with
+// made up kids cannot make every request load them. It reports whether the keys were loaded.
+func (k *KeySet) recheck() bool {
+	if k.db == nil {
+		return false
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if time.Since(k.checked) < keyRecheck {
+		return false
+	}
+
+	k.checked = time.Now()
+
+	return k.load() == nil
+}
+
+// Rotate makes the secret the current key and retires the previous current key for the grace period.
+// A random secret is generated when none is given. The id of the new key is returned, along with the
+// secret when it was generated, as it cannot be found out otherwise.
+func (k *KeySet) Rotate(secret string) (id, generated string, err error) {
+	if secret == "" {
+		raw := make([]byte, 32)
+		_, err = rand.Read(raw)
+		if err != nil {
+			return "", "", err
+		}
+
+		secret = base64.RawURLEncoding.EncodeToString(raw)
+		generated = secret
+	}
+
+	key := newSigningKey([]byte(secret))
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	// Stored keys are rotated in the database, for every server sharing it to load
+	if k.db != nil {
+		err = models.RotateSigningKey(k.db, &models.SigningKey{ID: key.id, Secret: secrets.String(secret)}, k.grace)
+		if err != nil {
+			return "", "", err
+		}
+
+		return key.id, generated, k.load()
+	}
+
+	if key.id == k.current.id {
+		return "", "", models.ErrKeyCurrent
+	}
+
+	now := time.Now()
+	old := k.current
+	old.expires = now.Add(k.grace)
+
+	// Drop retired keys whose grace period has ended
+	retired := []signingKey{old}
+	for _, r := range k.retired {
+		if now.Before(r.expires) && r.id != key.id {
+			retired = append(retired, r)
+		}
+	}
+
+	k.current = key
+	k.retired = retired
+
+	return key.id, generated, nil
+}
+
+// Keys describes the current key and the retired keys still accepted
+func (k *KeySet) Keys() ([]KeyInfo, error) {
+	err := k.refresh(keyRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := time.Now()
+	keys := []KeyInfo{{ID: k.current.id, Current: true}}
+
+	for _, r := range k.retired {
+		if now.Before(r.expires) {
+			expires := r.expires
+			keys = append(keys, KeyInfo{ID: r.id, Expires: &expires})
+		}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/i18n"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// HeaderTimezone is the request header naming the IANA time zone dates are computed in, such as Europe/Paris
+const HeaderTimezone = "X-Timezone"
+
+// Localize resolves the locale and time zone of the request, setting them in the context as "locale" and
+// "location". The Accept-Language and X-Timezone headers are honored first, falling back to the preferences
+// of the authenticated user and then to the default locale and UTC. The locale chosen is returned in the
+// Content-Language header.
+func Localize(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		header := c.Request().Header
+		tz := header.Get(HeaderTimezone)
+		accept := header.Get("Accept-Language")
+
+		var catalog *i18n.Catalog
+		var pref *models.Preference
+		if db, ok := c.Get("db").(*gorm.DB); ok {
+			catalog = models.CurrentSettings(db).Catalog
+
+			if uid, ok := c.Get("id").(string); ok && uid != "" && (tz == "" || accept == "") {
+				pref, _ = models.FindPreference(db, uid)
+			}
+		}
+
+		var locale string
+		if pref != nil {
+			locale = catalog.Negotiate(accept, pref.Locale)
+		} else {
+			locale = catalog.Negotiate(accept)
+		}
+
+		c.Set("locale", locale)
+		c.Response().Header().Set("Content-Language", locale)
+
+		loc := time.UTC
+		switch {
+		case tz != "":
+			var err error
+			loc, err = time.LoadLocation(tz)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid time zone")
+			}
+		case pref != nil:
+			loc = pref.Location()
+		}
+
+		c.Set("location", loc)
+
+		return next(c)
+	}
+}
@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"bytes"
+	"github.com/btnmasher/shiftr/render"
+	"github.com/labstack/echo/v4"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Negotiate answers the lists fetched with an Accept header preferring one of the media types of the renderers
+// with the list written by its renderer rather than as JSON. The JSON the handler answers with is rendered, so
+// the other formats hold what it would. Responses which are not lists are answered as JSON when the client
+// accepts it, and with 406 Not Acceptable otherwise. A format parameter naming the subtype of an offered media type,
+// such as format=csv, asks for it regardless of the Accept header, for links and clients which cannot set one. The
+// media type chosen is set as "mediatype", for handlers writing a format of their own in its place.
+func Negotiate(renderers map[string]render.Renderer) echo.MiddlewareFunc {
+	offers := []string{echo.MIMEApplicationJSON}
+	for mediaType := range renderers {
+		offers = append(offers, mediaType)
+	}
+
+	// JSON is preferred when the client accepts formats equally
+	sort.Strings(offers[1:])
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().Method != http.MethodGet {
+				return next(c)
+			}
+
+			c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+
+			accept := c.Request().Header.Get(echo.HeaderAccept)
+
+			mediaType := formatType(c.QueryParam("format"), offers)
+			if mediaType != "" {
+				accept = mediaType
+			} else {
+				mediaType = preferredType(accept, offers)
+			}
+
+			c.Set("mediatype", mediaType)
+
+			if mediaType == "" || mediaType == echo.MIMEApplicationJSON {
+				return next(c)
+			}
+
+			res := c.Response()
+			held := &heldResponse{ResponseWriter: res.Writer}
+			res.Writer = held
+
+			err := next(c)
+
+			res.Writer = held.ResponseWriter
+
+			if err != nil {
+				if rerr := held.release(); rerr != nil {
+					c.Logger().Errorf("negotiate: %s", rerr)
+				}
+
+				return err
+			}
+
+			// Responses other than JSON and those of failed requests are answered as they were written
+			contentType, _, _ := mime.ParseMediaType(res.Header().Get(echo.HeaderContentType))
+			if !held.written || res.Status < 200 || res.Status >= 300 || contentType != echo.MIMEApplicationJSON {
+				return held.release()
+			}
+
+			value, err := render.Decode(held.body.Bytes())
+			if err != nil {
+				return err
+			}
+
+			items, ok := render.Items(value)
+			if !ok {
+				if preferredType(accept, offers[:1]) != "" {
+					return held.release()
+				}
+
+				res.Committed = false
+				res.Size = 0
+
+				return echo.NewHTTPError(http.StatusNotAcceptable, "only JSON is available for this resource")
+			}
+
+			var body bytes.Buffer
+
+			err = renderers[mediaType].Render(&body, items)
+			if err != nil {
+				return err
+			}
+
+			header := res.Header()
+			header.Set(echo.HeaderContentType, mime.FormatMediaType(mediaType, map[string]string{"charset": "UTF-8"}))
+			header.Del(echo.HeaderContentLength)
+			header.Del("ETag")
+
+			held.body = body
+			res.Size = int64(body.Len())
+
+			return held.release()
+		}
+	}
+}
+
+// formatType returns the offered media type the format names by its subtype, such as text/csv for csv, or an empty
+// string when it names none of them
+func formatType(format string, offers []string) string {
+	if format == "" {
+		return ""
+	}
+
+	for _, offer := range offers {
+		if strings.EqualFold(offer[strings.Index(offer, "/")+1:], format) {
+			return offer
+		}
+	}
+
+	return ""
+}
+
+// preferredType returns the offered media type the Accept header prefers, the first offered of those it prefers
+// equally, or an empty string when it accepts none of them. An empty header accepts any.
+func preferredType(accept string, offers []string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q := acceptQuality(accept, offer)
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
+
+// acceptQuality returns the quality the Accept header gives the media type, by its most specific matching range
+func acceptQuality(accept, mediaType string) float64 {
+	quality, specificity := 0.0, -1
+
+	for _, part := range strings.Split(accept, ",") {
+		rng, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		var s int
+		switch {
+		case rng == mediaType:
+			s = 2
+		case rng == "*/*":
+			s = 0
+		case strings.HasSuffix(rng, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(rng, "*")):
+			s = 1
+		default:
+			continue
+		}
+
+		if s <= specificity {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				q = 0
+			}
+		}
+
+		quality, specificity = q, s
+	}
+
+	return quality
+}
+
+// heldResponse holds the response written to it until released, so that it may be written in another format
+type heldResponse struct {
+	http.ResponseWriter
+	body    bytes.Buffer
+	status  int
+	written bool
+}
+
+func (h *heldResponse) WriteHeader(status int) {
+	h.status = status
+	h.written = true
+}
+
+func (h *heldResponse) Write(b []byte) (int, error) {
+	h.written = true
+
+	return h.body.Write(b)
+}
+
+// release writes the held response
+func (h *heldResponse) release() error {
+	if !h.written {
+		return nil
+	}
+
+	if h.status != 0 {
+		h.ResponseWriter.WriteHeader(h.status)
+	}
+
+	_, err := h.ResponseWriter.Write(h.body.Bytes())
+
+	return err
+}
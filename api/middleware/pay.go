@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/labstack/echo/v4"
+)
+
+// PaySerializer is an echo.JSONSerializer which strips the pay data of users from every response to a caller
+// who is not an admin, wherever in the response it appears
+type PaySerializer struct {
+	echo.DefaultJSONSerializer
+}
+
+// Serialize encodes the value to the response, removing models.PayFields unless the caller is an admin
+func (s PaySerializer) Serialize(c echo.Context, i interface{}, indent string) error {
+	if role, _ := c.Get("role").(string); role == "admin" {
+		return s.DefaultJSONSerializer.Serialize(c, i, indent)
+	}
+
+	data, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+
+	// Most responses hold no pay data and are written as encoded
+	var value interface{} = json.RawMessage(data)
+	if hasPayFields(data) {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+
+		err = dec.Decode(&value)
+		if err != nil {
+			return err
+		}
+
+		stripPayFields(value)
+	}
+
+	enc := json.NewEncoder(c.Response())
+	if indent != "" {
+		enc.SetIndent("", indent)
+	}
+
+	return enc.Encode(value)
+}
+
+// hasPayFields reports whether the encoded JSON may hold any of the pay fields
+func hasPayFields(data []byte) bool {
+	for _, field := range models.PayFields {
+		if bytes.Contains(data, []byte(`"`+field+`"`)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stripPayFields removes the pay fields from every object within the decoded JSON value
+func stripPayFields(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, field := range models.PayFields {
+			delete(v, field)
+		}
+
+		for _, child := range v {
+			stripPayFields(child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			stripPayFields(child)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+)
+
+// rateWindow counts the requests made by a client within the current window
+type rateWindow struct {
+	count int
+	reset time.Time
+}
+
+// RateLimiter allows each client a fixed number of requests per window, identifying clients by IP address.
+// Every response carries the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers so clients
+// can pace themselves, and requests over the limit are rejected with 429 Too Many Requests.
+type RateLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	clients map[string]*rateWindow
+	sweep   time.Time // when expired windows are next removed
+}
+
+// NewRateLimiter returns a RateLimiter allowing limit requests per window
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		window:  window,
+		clients: make(map[string]*rateWindow),
+	}
+}
+
+// Limit is middleware counting the request against the client's allowance
+func (rl *RateLimiter) Limit(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		remaining, reset, ok := rl.take(c.RealIP(), time.Now())
+
+		// Reset is the number of seconds until the window ends, rounded up so it is never 0 early
+		secs := int(math.Ceil(time.Until(reset).Seconds()))
+		if secs < 0 {
+			secs = 0
+		}
+
+		h := c.Response().Header()
+		h.Set(HeaderRateLimitLimit, strconv.Itoa(rl.limit))
+		h.Set(HeaderRateLimitRemaining, strconv.Itoa(remaining))
+		h.Set(HeaderRateLimitReset, strconv.Itoa(secs))
+
+		if !ok {
+			h.Set("Retry-After", strconv.Itoa(secs))
+			return echo.NewHTTPError(http.StatusTooManyRequests, "rate limit exceeded")
+		}
+
+		return next(c)
+	}
+}
+
+// take counts a request by the client, returning the requests remaining in its window, when
+// the window resets, and whether the request is within the limit
+func (rl *RateLimiter) take(client string, now time.Time) (int, time.Time, bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	// Periodically forget clients whose windows have ended
+	if now.After(rl.sweep) {
+		for key, w := range rl.clients {
+			if !now.Before(w.reset) {
+				delete(rl.clients, key)
+			}
+		}
+		rl.sweep = now.Add(rl.window)
+	}
+
+	w, ok := rl.clients[client]
+	if !ok || !now.Before(w.reset) {
+		w = &rateWindow{reset: now.Add(rl.window)}
+		rl.clients[client] = w
+	}
+
+	if w.count >= rl.limit {
+		return 0, w.reset, false
+	}
+
+	w.count++
+
+	return rl.limit - w.count, w.reset, true
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// ReadOnly rejects requests which would modify data with 503 Service Unavailable, for running during a
+// failover or restore, or against a read replica for reporting. Routes listed as exempt, given as the
+// method and path they were registered with such as "POST /login", are still served.
+func ReadOnly(exempt ...string) echo.MiddlewareFunc {
+	allowed := make(map[string]bool, len(exempt))
+	for _, route := range exempt {
+		allowed[route] = true
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			method := c.Request().Method
+
+			switch method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return next(c)
+			}
+
+			if allowed[method+" "+c.Path()] {
+				return next(c)
+			}
+
+			return echo.NewHTTPError(http.StatusServiceUnavailable, "server is in read-only mode")
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/btnmasher/shiftr/logging"
+	"github.com/labstack/echo/v4"
+)
+
+// maxRequestID is the longest X-Request-ID header taken from a client, longer ones are replaced
+const maxRequestID = 128
+
+// requestIDKey is the context key of the ID of the request being served
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of the context carrying the ID of the request it serves
+func WithRequestID(ctx context.Context, rid string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, rid)
+}
+
+// RequestIDFrom returns the ID of the request the context serves, empty when it serves none
+func RequestIDFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	rid, _ := ctx.Value(requestIDKey{}).(string)
+
+	return rid
+}
+
+// RequestID identifies every request by the X-Request-ID header it was sent with, as set by a proxy or client
+// before it, or by a new ID when it has none or one unfit to log. The ID is answered in the X-Request-ID header,
+// is the request_id field of the lines logged for the request, and is carried by the context of the request and
+// of its database handle into the database log.
+func RequestID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+
+		rid := ResolveRequestID(req.Header.Get(echo.HeaderXRequestID))
+
+		// The request header is replaced as well, so the access log records the ID answered
+		req.Header.Set(echo.HeaderXRequestID, rid)
+		c.Response().Header().Set(echo.HeaderXRequestID, rid)
+
+		c.Set("requestid", rid)
+		c.SetRequest(req.WithContext(WithRequestID(req.Context(), rid)))
+		if l, ok := c.Logger().(*logging.EchoLogger); ok {
+			c.SetLogger(l.With("request_id", rid))
+		}
+
+		return next(c)
+	}
+}
+
+// ResolveRequestID returns the request ID given by a client when it may be used as it is, or a new random ID
+func ResolveRequestID(given string) string {
+	if validRequestID(given) {
+		return given
+	}
+
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// validRequestID reports whether the request ID given by a client may be used as it is, being short and holding
+// nothing which could forge or break a log line
+func validRequestID(rid string) bool {
+	if rid == "" || len(rid) > maxRequestID {
+		return false
+	}
+
+	for _, r := range rid {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':', r == '/', r == '+', r == '=':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
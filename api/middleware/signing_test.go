@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// signedServer returns a server answering POST /admin/purge with the body it was sent, once verified by the signer
+func signedServer(s *Signer) *echo.Echo {
+	e := echo.New()
+	e.POST("/admin/purge", func(c echo.Context) error {
+		body, err := ioutil.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+
+		return c.String(http.StatusOK, string(body))
+	}, s.Verify)
+
+	return e
+}
+
+// signedRequest returns a POST of the body to the URI, carrying the signature and timestamp headers unless empty
+func signedRequest(uri, body, signature, timestamp string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, uri, strings.NewReader(body))
+
+	if signature != "" {
+		req.Header.Set(HeaderSignature, signature)
+	}
+
+	if timestamp != "" {
+		req.Header.Set(HeaderSignatureTimestamp, timestamp)
+	}
+
+	return req
+}
+
+func TestSignerRejects(t *testing.T) {
+	signer := NewSigner("secret", time.Minute)
+	other := NewSigner("not the secret", time.Minute)
+
+	now := time.Now().Unix()
+	stamp := strconv.FormatInt(now, 10)
+	body := `{"before":"2021-01-01"}`
+
+	tests := []struct {
+		name      string
+		uri       string
+		body      string
+		signature string
+		timestamp string
+		want      string
+	}{
+		{"unsigned", "/admin/purge", body, "", stamp, "request signature required"},
+		{"no timestamp", "/admin/purge", body, signer.Sign(http.MethodPost, "/admin/purge", now, []byte(body)), "",
+			"request signature required"},
+		{"malformed timestamp", "/admin/purge", body, signer.Sign(http.MethodPost, "/admin/purge", now, []byte(body)),
+			"yesterday", "request signature required"},
+		{"expired", "/admin/purge", body,
+			signer.Sign(http.MethodPost, "/admin/purge", now-120, []byte(body)), strconv.FormatInt(now-120, 10),
+			"request signature expired"},
+		{"too far ahead", "/admin/purge", body,
+			signer.Sign(http.MethodPost, "/admin/purge", now+120, []byte(body)), strconv.FormatInt(now+120, 10),
+			"request signature expired"},
+		{"other secret", "/admin/purge", body, other.Sign(http.MethodPost, "/admin/purge", now, []byte(body)), stamp,
+			"invalid request signature"},
+		{"other body", "/admin/purge", `{"before":"2099-01-01"}`,
+			signer.Sign(http.MethodPost, "/admin/purge", now, []byte(body)), stamp, "invalid request signature"},
+		{"other uri", "/admin/purge?all=true", body,
+			signer.Sign(http.MethodPost, "/admin/purge", now, []byte(body)), stamp, "invalid request signature"},
+		{"other method", "/admin/purge", body,
+			signer.Sign(http.MethodDelete, "/admin/purge", now, []byte(body)), stamp,
+			"invalid request signature"},
+		{"other timestamp", "/admin/purge", body, signer.Sign(http.MethodPost, "/admin/purge", now-1, []byte(body)),
+			stamp, "invalid request signature"},
+	}
+
+	e := signedServer(signer)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, signedRequest(tt.uri, tt.body, tt.signature, tt.timestamp))
+
+			if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), tt.want) {
+				t.Errorf("got %d %s, want %d %q", rec.Code, rec.Body, http.StatusUnauthorized, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignerAcceptsOnce(t *testing.T) {
+	signer := NewSigner("secret", time.Minute)
+	e := signedServer(signer)
+
+	now := time.Now().Unix()
+	body := `{"before":"2021-01-01"}`
+	signature := signer.Sign(http.MethodPost, "/admin/purge", now, []byte(body))
+
+	// The handler is given the body the signature covers
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, signedRequest("/admin/purge", body, signature, strconv.FormatInt(now, 10)))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != body {
+		t.Fatalf("signed request: got %d %s, want %d %s", rec.Code, rec.Body, http.StatusOK, body)
+	}
+
+	// The same signature is refused when replayed, even concurrently
+	const racers = 8
+
+	var wg sync.WaitGroup
+	codes := make([]int, racers)
+
+	for i := range codes {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, signedRequest("/admin/purge", body, signature, strconv.FormatInt(now, 10)))
+			codes[i] = rec.Code
+		}(i)
+	}
+
+	wg.Wait()
+
+	for _, code := range codes {
+		if code != http.StatusUnauthorized {
+			t.Errorf("replayed request: got %d, want %d", code, http.StatusUnauthorized)
+		}
+	}
+
+	// A fresh signature of the same request is accepted, and only once when raced
+	now++
+	signature = signer.Sign(http.MethodPost, "/admin/purge", now, []byte(body))
+
+	accepted := make(chan int, racers)
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, signedRequest("/admin/purge", body, signature, strconv.FormatInt(now, 10)))
+			if rec.Code == http.StatusOK {
+				accepted <- rec.Code
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(accepted)
+
+	if len(accepted) != 1 {
+		t.Errorf("racing one signature: %d requests accepted, want 1", len(accepted))
+	}
+}
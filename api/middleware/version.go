@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIVersion describes a version of the API mounted under /api/<Name>. Every version is served by the same
+// handlers, with the Serializer translating the bodies of its requests and responses to and from the current
+// models. A version which is Deprecated is announced as such on every response, along with the Sunset after
+// which it is retired and the Successor clients should move to.
+type APIVersion struct {
+	Name       string              // path segment of the version such as v1
+	Serializer echo.JSONSerializer // nil for PaySerializer, replacements must also strip pay data
+	Deprecated time.Time           // when the version was or will be deprecated, zero unless deprecated
+	Sunset     time.Time           // when the version stops being served, zero for never
+	Successor  string              // name of the version replacing it
+}
+
+// Versioned marks requests as served by the API version, answering with the deprecation (RFC 9745), sunset
+// (RFC 8594) and successor-version link headers the version calls for, and with 410 Gone once it is retired
+func Versioned(v APIVersion) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("apiversion", v.Name)
+
+			header := c.Response().Header()
+			if !v.Deprecated.IsZero() {
+				header.Set("Deprecation", "@"+strconv.FormatInt(v.Deprecated.Unix(), 10))
+			}
+
+			if !v.Sunset.IsZero() {
+				header.Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+			}
+
+			if v.Successor != "" {
+				header.Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successorPath(c, v)))
+			}
+
+			if !v.Sunset.IsZero() && !time.Now().Before(v.Sunset) {
+				if v.Successor != "" {
+					return echo.NewHTTPError(http.StatusGone,
+						fmt.Sprintf("API %s has been retired, use %s", v.Name, v.Successor))
+				}
+
+				return echo.NewHTTPError(http.StatusGone, fmt.Sprintf("API %s has been retired", v.Name))
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// successorPath returns the path of the request under the successor of the version
+func successorPath(c echo.Context, v APIVersion) string {
+	base, _ := c.Get("basepath").(string)
+	path := c.Request().URL.Path
+
+	prefix := base + "/api/" + v.Name
+	if !strings.HasPrefix(path, prefix) {
+		return base + "/api/" + v.Successor
+	}
+
+	return base + "/api/" + v.Successor + strings.TrimPrefix(path, prefix)
+}
+
+// VersionSerializer is an echo.JSONSerializer which encodes and decodes bodies with the serializer of the API
+// version serving the request, and with the Default serializer outside of the versioned API
+type VersionSerializer struct {
+	Default  echo.JSONSerializer
+	Versions map[string]echo.JSONSerializer
+}
+
+// Serialize encodes the value to the response with the serializer of the request's API version
+func (s VersionSerializer) Serialize(c echo.Context, i interface{}, indent string) error {
+	return s.serializer(c).Serialize(c, i, indent)
+}
+
+// Deserialize decodes the request body with the serializer of the request's API version
+func (s VersionSerializer) Deserialize(c echo.Context, i interface{}) error {
+	return s.serializer(c).Deserialize(c, i)
+}
+
+// serializer returns the serializer of the API version serving the request
+func (s VersionSerializer) serializer(c echo.Context) echo.JSONSerializer {
+	if name, ok := c.Get("apiversion").(string); ok {
+		if serializer := s.Versions[name]; serializer != nil {
+			return serializer
+		}
+	}
+
+	return s.Default
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// AccessReport describes what a User can see and do, for periodic access reviews. Delegations and the
+// approvals they move are evaluated at the time of the report, everything else as it currently stands.
+type AccessReport struct {
+	UserID      string        `json:"user_id"`
+	Name        string        `json:"name"`
+	Role        string        `json:"role"`
+	Active      bool          `json:"active"`    // deactivated users cannot log in
+	At          time.Time     `json:"at"`        // when delegations were evaluated
+	AllUsers    bool          `json:"all_users"` // whether they see and approve everyone, as admins do
+	Teams       []AccessScope `json:"teams"`     // whose schedules they see
+	Locations   []AccessScope `json:"locations"`
+	Groups      []AccessScope `json:"groups"`
+	Reports     []string      `json:"reports"`     // User.IDs whose shifts they may list
+	Approves    []string      `json:"approves"`    // User.IDs whose requests are routed to them
+	Delegated   []*Delegation `json:"delegated"`   // approval authority they handed to others
+	Delegations []*Delegation `json:"delegations"` // approval authority handed to them
+	Permissions []string      `json:"permissions"` // routes their role may call
+}
+
+// AccessScope names a team, location or group within an AccessReport
+type AccessScope struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ReportAccess attempts to compute the AccessReport of the specified User.ID, including deactivated users,
+// with delegations evaluated at the time
+func ReportAccess(db *gorm.DB, uid string, at time.Time) (*AccessReport, error) {
+	user := &User{}
+	err := db.Unscoped().First(user, "id = ?", uid).Error
+	if err != nil {
+		return nil, notFound("user", err)
+	}
+
+	report := &AccessReport{
+		UserID:      user.ID,
+		Name:        user.Name,
+		Role:        user.Role,
+		Active:      !user.DeactivatedAt.Valid,
+		At:          at,
+		AllUsers:    user.Role == "admin",
+		Teams:       []AccessScope{},
+		Locations:   []AccessScope{},
+		Groups:      []AccessScope{},
+		Reports:     []string{},
+		Approves:    []string{},
+		Delegated:   []*Delegation{},
+		Delegations: []*Delegation{},
+		Permissions: []string{},
+	}
+
+	// Admins manage every team and location, users only see their own team and home location
+	teams, locations := db.Model(&Team{}), db.Model(&Location{})
+	if !report.AllUsers {
+		teams = teams.Where("id = ?", user.TeamID)
+		locations = locations.Where("id = ?", user.LocationID)
+	}
+
+	err = teams.Order("name").Find(&report.Teams).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = locations.Order("name").Find(&report.Locations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Model(&Group{}).Where("id IN (?)", db.Model(&GroupMember{}).Select("group_id").Where("user_id = ?", uid)).
+		Order("name").Find(&report.Groups).Error
+	if err != nil {
+		return nil, err
+	}
+
+	reports, err := ListReportIDs(db, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	if reports != nil {
+		report.Reports = reports
+	}
+
+	active := fmt.Sprintf("start <= ? AND %s > ?", quote(db, "end"))
+
+	err = db.Where("manager_id = ? AND "+active, uid, at, at).Order("start").Find(&report.Delegated).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Where("delegate_id = ? AND "+active, uid, at, at).Order("start").Find(&report.Delegations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	report.Approves, err = approvedBy(db, uid, at)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// approvedBy returns the User.IDs whose requests are routed to the specified User.ID at the time, being
+// those of each manager whose approval authority rests with them, including their own
+func approvedBy(db *gorm.DB, uid string, at time.Time) ([]string, error) {
+	var delegations []*Delegation
+
+	err := db.Where(fmt.Sprintf("start <= ? AND %s > ?", quote(db, "end")), at, at).Find(&delegations).Error
+	if err != nil {
+		return []string{}, err
+	}
+
+	// Walk back along the delegations to every manager whose authority may have reached the user
+	candidates := []string{uid}
+	seen := map[string]bool{uid: true}
+
+	for i := 0; i < len(candidates); i++ {
+		for _, d := range delegations {
+			if d.DelegateID == candidates[i] && !seen[d.ManagerID] {
+				seen[d.ManagerID] = true
+				candidates = append(candidates, d.ManagerID)
+			}
+		}
+	}
+
+	var managers []string
+	for _, mid := range candidates {
+		acting, err := ActingApprover(db, mid, at)
+		if err != nil {
+			return []string{}, err
+		}
+
+		if acting == uid {
+			managers = append(managers, mid)
+		}
+	}
+
+	approves := []string{}
+	if len(managers) == 0 {
+		return approves, nil
+	}
+
+	err = db.Model(&User{}).Where("manager_id IN ?", managers).Order("name").Pluck("id", &approves).Error
+	if err != nil {
+		return []string{}, err
+	}
+
+	return approves, nil
+}
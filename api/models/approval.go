@@ -0,0 +1,45 @@
+package models
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// ErrNotPending is returned when approving or rejecting a shift which is not awaiting approval
+var ErrNotPending = errors.New("shift is not awaiting approval")
+
+// Approve attempts to publish the pending Shift so it counts toward the schedule
+func (s *Shift) Approve(db *gorm.DB) error {
+	if s.Status != ShiftPending {
+		return ErrNotPending
+	}
+
+	tx := db.Model(s).Where("status = ?", ShiftPending).Update("status", ShiftPublished)
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected == 0 {
+		return ErrNotPending
+	}
+
+	s.Status = ShiftPublished
+
+	return nil
+}
+
+// Reject attempts to cancel the pending Shift, recording the reason it was rejected
+func (s *Shift) Reject(db *gorm.DB, reason string) error {
+	if s.Status != ShiftPending {
+		return ErrNotPending
+	}
+
+	if strings.TrimSpace(reason) == "" {
+		return invalid("rejection reason required")
+	}
+
+	return s.Cancel(db, "rejected: "+reason)
+}
@@ -0,0 +1,319 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+const (
+	// ClockInEarly is how long before a shift starts that clocking in counts towards it
+	ClockInEarly = time.Hour
+
+	// LateGrace is how long after a shift starts that clocking in is still on time
+	LateGrace = time.Minute * 5
+
+	// NoShowLookback is how long after a shift ends that it is still checked for a no-show
+	NoShowLookback = time.Hour * 24 * 7
+)
+
+// Attendance statuses
+const (
+	AttendancePresent = "present"
+	AttendanceLate    = "late"
+	AttendanceNoShow  = "no_show"
+)
+
+var (
+	ErrAlreadyClockedIn = errors.New("already clocked in")
+	ErrNotClockedIn     = errors.New("not clocked in")
+)
+
+// ClockEntry struct represents a User clocking in and out, linked to the shift they clocked in for if any.
+// The device coordinates of the clock-in are checked against the geofence of the shift's location, and
+// clock-ins accepted despite failing the check are flagged with the reason.
+type ClockEntry struct {
+	ID        string     `gorm:"primaryKey" json:"id"`
+	UserID    string     `gorm:"not null;index" json:"user_id"`
+	ShiftID   string     `gorm:"index" json:"shift_id,omitempty"`
+	ClockIn   time.Time  `gorm:"not null" json:"clock_in"`
+	ClockOut  *time.Time `json:"clock_out,omitempty"`
+	Latitude  *float64   `json:"latitude,omitempty"`
+	Longitude *float64   `json:"longitude,omitempty"`
+	Distance  *float64   `json:"distance,omitempty"` //meters from the shift's location
+	Flag      string     `gorm:"size:50;not null;default:''" json:"flag,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Attendance struct represents whether the worker of a Shift turned up for it, recorded when they first
+// clock in for it or flagged as a no-show once it ends without them clocking in
+type Attendance struct {
+	ShiftID     string     `gorm:"primaryKey" json:"shift_id"`
+	UserID      string     `gorm:"not null;index" json:"user_id"`
+	Status      string     `gorm:"size:10;not null;index" json:"status"`
+	MinutesLate int        `gorm:"not null;default:0" json:"minutes_late,omitempty"`
+	ClockIn     *time.Time `json:"clock_in,omitempty"`
+	ClockOut    *time.Time `json:"clock_out,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// BeforeCreate hooks GORM and prepares a new object for creation
+func (e *ClockEntry) BeforeCreate(_ *gorm.DB) error {
+	id, err := nanoid.Nanoid(10)
+	if err != nil {
+		return fmt.Errorf("unable to generate ClockEntryID: %s", err)
+	}
+
+	e.ID = id
+
+	return nil
+}
+
+// FindOpenClockEntry attempts to return the ClockEntry of the specified User.ID not yet clocked out of
+func FindOpenClockEntry(db *gorm.DB, uid string) (*ClockEntry, error) {
+	entry := &ClockEntry{}
+	err := db.Where("user_id = ? AND clock_out IS NULL", uid).Order("clock_in DESC").First(&entry).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &ClockEntry{}, ErrNotClockedIn
+		}
+
+		return &ClockEntry{}, err
+	}
+
+	return entry, nil
+}
+
+// ClockIn attempts to clock the specified User.ID in at the specified time from the optional device
+// coordinates, linking the entry to the published shift they are working then or starting within
+// ClockInEarly, checking the geofence of the shift's location and recording their attendance
+func ClockIn(db *gorm.DB, uid string, at time.Time, lat, lng *float64) (*ClockEntry, error) {
+	entry := &ClockEntry{
+		UserID:    uid,
+		ClockIn:   at,
+		Latitude:  lat,
+		Longitude: lng,
+	}
+
+	err := transaction(db, func(tx *gorm.DB) error {
+		_, err := FindOpenClockEntry(tx, uid)
+		if err == nil {
+			return ErrAlreadyClockedIn
+		}
+
+		if !errors.Is(err, ErrNotClockedIn) {
+			return err
+		}
+
+		shifts, err := ListShifts(tx,
+			FilterUserID(uid),
+			FilterStatus(ShiftPublished),
+			FilterStartsBefore(at.Add(ClockInEarly)),
+			FilterEndsAfter(at),
+			WithLimit(1),
+		)
+		if err != nil {
+			return err
+		}
+
+		if len(shifts) > 0 {
+			entry.ShiftID = shifts[0].ID
+
+			if lid := shifts[0].LocationID; lid != "" {
+				location, err := FindLocationByID(tx, lid)
+				if err != nil {
+					return err
+				}
+
+				entry.Distance, entry.Flag, err = location.CheckGeofence(lat, lng)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		err = tx.Create(entry).Error
+		if err != nil {
+			return err
+		}
+
+		if len(shifts) == 0 {
+			return nil
+		}
+
+		return recordAttendance(tx, shifts[0], at)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// recordAttendance records the worker of the shift as present, or late if they clocked in after LateGrace.
+// Only the first clock in for a shift is recorded.
+func recordAttendance(db *gorm.DB, shift *Shift, at time.Time) error {
+	attendance := &Attendance{
+		ShiftID: shift.ID,
+		UserID:  shift.UserID,
+		Status:  AttendancePresent,
+		ClockIn: &at,
+	}
+
+	if at.After(shift.Start.Add(LateGrace)) {
+		attendance.Status = AttendanceLate
+		attendance.MinutesLate = int(at.Sub(shift.Start).Minutes())
+	}
+
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(attendance).Error
+}
+
+// ClockOut attempts to clock the specified User.ID out of their open entry at the specified time
+func ClockOut(db *gorm.DB, uid string, at time.Time) (*ClockEntry, error) {
+	entry, err := FindOpenClockEntry(db, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	if at.Before(entry.ClockIn) {
+		return nil, invalid("cannot clock out before clocking in")
+	}
+
+	err = transaction(db, func(tx *gorm.DB) error {
+		err := tx.Model(entry).Update("clock_out", at).Error
+		if err != nil {
+			return err
+		}
+
+		if entry.ShiftID == "" {
+			return nil
+		}
+
+		return tx.Model(&Attendance{}).Where("shift_id = ?", entry.ShiftID).Update("clock_out", at).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entry.ClockOut = &at
+
+	return entry, nil
+}
+
+// DetectNoShows flags the workers of the published shifts which ended by the specified time, within
+// NoShowLookback, without them clocking in. The new no-show records are returned.
+func DetectNoShows(db *gorm.DB, now time.Time) ([]*Attendance, error) {
+	missed := db.Session(&gorm.Session{NewDB: true}).Model(&Attendance{}).Select("shift_id")
+
+	shifts, err := ListShifts(db,
+		FilterStatus(ShiftPublished),
+		FilterEndsAfter(now.Add(-NoShowLookback)),
+		func(db *gorm.DB) {
+			db.Where("end <= ? AND user_id <> '' AND id NOT IN (?)", now, missed)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	noShows := []*Attendance{}
+
+	for _, shift := range shifts {
+		attendance := &Attendance{
+			ShiftID: shift.ID,
+			UserID:  shift.UserID,
+			Status:  AttendanceNoShow,
+		}
+
+		tx := db.Clauses(clause.OnConflict{DoNothing: true}).Create(attendance)
+		if tx.Error != nil {
+			return noShows, tx.Error
+		}
+
+		if tx.RowsAffected > 0 {
+			noShows = append(noShows, attendance)
+		}
+	}
+
+	return noShows, nil
+}
+
+// ListAttendance attempts to return the attendance records of the shifts starting within the window,
+// ordered by shift start, restricted to the specified User.ID when not empty
+func ListAttendance(db *gorm.DB, start, end time.Time, uid string) ([]*Attendance, error) {
+	var records []*Attendance
+
+	tx := db.Model(&Attendance{}).
+		Select("attendances.*").
+		Joins("JOIN shifts ON shifts.id = attendances.shift_id").
+		Order(quote(db, "shifts.start"))
+
+	tx = attendanceWindow(db, tx, start, end, uid)
+
+	err := tx.Find(&records).Error
+	if err != nil {
+		return []*Attendance{}, err
+	}
+
+	return records, nil
+}
+
+// attendanceWindow restricts a query joining attendances with shifts to the window and user
+func attendanceWindow(db, tx *gorm.DB, start, end time.Time, uid string) *gorm.DB {
+	if !start.IsZero() {
+		tx.Where(fmt.Sprintf("%s >= ?", quote(db, "shifts.start")), start)
+	}
+
+	if !end.IsZero() {
+		tx.Where(fmt.Sprintf("%s < ?", quote(db, "shifts.start")), end)
+	}
+
+	if uid != "" {
+		tx.Where("attendances.user_id = ?", uid)
+	}
+
+	return tx
+}
+
+// AttendanceTotal is the attendance of a User over a period
+type AttendanceTotal struct {
+	UserID      string `json:"user_id"`
+	Name        string `json:"name"`
+	Shifts      int    `json:"shifts"`
+	Present     int    `json:"present"`
+	Late        int    `json:"late"`
+	NoShow      int    `json:"no_show"`
+	MinutesLate int    `json:"minutes_late"`
+}
+
+// SummarizeAttendance totals the attendance of each user over the shifts starting within the window,
+// restricted to the specified User.ID when not empty
+func SummarizeAttendance(db *gorm.DB, start, end time.Time, uid string) ([]*AttendanceTotal, error) {
+	totals := []*AttendanceTotal{}
+
+	tx := db.Model(&Attendance{}).
+		Select("attendances.user_id AS user_id, users.name AS name, COUNT(*) AS shifts, "+
+			"SUM(CASE WHEN attendances.status = ? THEN 1 ELSE 0 END) AS present, "+
+			"SUM(CASE WHEN attendances.status = ? THEN 1 ELSE 0 END) AS late, "+
+			"SUM(CASE WHEN attendances.status = ? THEN 1 ELSE 0 END) AS no_show, "+
+			"SUM(attendances.minutes_late) AS minutes_late",
+			AttendancePresent, AttendanceLate, AttendanceNoShow).
+		Joins("JOIN shifts ON shifts.id = attendances.shift_id").
+		Joins("LEFT JOIN users ON users.id = attendances.user_id").
+		Group("attendances.user_id, users.name").
+		Order("users.name")
+
+	tx = attendanceWindow(db, tx, start, end, uid)
+
+	err := tx.Scan(&totals).Error
+	if err != nil {
+		return []*AttendanceTotal{}, err
+	}
+
+	return totals, nil
+}
@@ -0,0 +1,266 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditBatch is how many entries are read at a time when verifying the audit trail
+const auditBatch = 500
+
+var ErrAuditImmutable = errors.New("audit entries cannot be modified or deleted")
+
+// AuditEntry struct represents a change made through the API. Entries form a chain: each records the Hash
+// of the entry before it and its own Hash covers every field including that one, so altering, removing or
+// reordering any entry breaks the chain from that point on.
+type AuditEntry struct {
+	Seq      uint64    `gorm:"primaryKey;autoIncrement:false" json:"seq"`
+	At       time.Time `gorm:"not null;index" json:"at"`
+	UserID   string    `gorm:"index" json:"user_id,omitempty"`
+	Role     string    `gorm:"size:10" json:"role,omitempty"`
+	Method   string    `gorm:"size:10;not null" json:"method"`
+	Route    string    `gorm:"size:255;not null" json:"route"` //path the route was registered with
+	Path     string    `gorm:"size:2048;not null" json:"path"` //path requested
+	Status   int       `gorm:"not null" json:"status"`
+	PrevHash string    `gorm:"size:64;not null" json:"prev_hash"`
+	Hash     string    `gorm:"size:64;not null" json:"hash"`
+	Subjects []string  `gorm:"-" json:"subjects,omitempty"` //users affected by the change, such as the worker of a shift
+}
+
+// AuditSubject struct represents a user affected by the change an AuditEntry records, so the changes
+// affecting a user can be found along with the ones they made
+type AuditSubject struct {
+	Seq    uint64 `gorm:"primaryKey;autoIncrement:false"`
+	UserID string `gorm:"primaryKey"`
+}
+
+// digest returns the hex encoded SHA-256 hash of the fields of the entry other than its Hash. Subjects are
+// only covered when there are any, so entries recorded before subjects were leave their hash unchanged.
+func (e *AuditEntry) digest() string {
+	contents := fmt.Sprintf("%d\n%s\n%s\n%s\n%s\n%s\n%s\n%d\n%s",
+		e.Seq, e.At.UTC().Format(time.RFC3339Nano), e.UserID, e.Role, e.Method, e.Route, e.Path, e.Status, e.PrevHash)
+
+	if len(e.Subjects) > 0 {
+		contents += "\n" + strings.Join(e.Subjects, ",")
+	}
+
+	sum := sha256.Sum256([]byte(contents))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// BeforeUpdate hooks GORM to refuse changes to recorded entries
+func (e *AuditEntry) BeforeUpdate(_ *gorm.DB) error {
+	return ErrAuditImmutable
+}
+
+// BeforeDelete hooks GORM to refuse the removal of recorded entries
+func (e *AuditEntry) BeforeDelete(_ *gorm.DB) error {
+	return ErrAuditImmutable
+}
+
+// BeforeUpdate hooks GORM to refuse changes to recorded subjects
+func (s *AuditSubject) BeforeUpdate(_ *gorm.DB) error {
+	return ErrAuditImmutable
+}
+
+// BeforeDelete hooks GORM to refuse the removal of recorded subjects
+func (s *AuditSubject) BeforeDelete(_ *gorm.DB) error {
+	return ErrAuditImmutable
+}
+
+// auditMu serializes appends within the process, the primary key on Seq keeps the chain linear between processes
+var auditMu sync.Mutex
+
+// AppendAudit attempts to append the entry to the end of the audit trail, linking it to the last entry
+func AppendAudit(db *gorm.DB, e *AuditEntry) error {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	// Stored times lose precision on some databases, which would change the hash when read back
+	e.At = e.At.UTC().Truncate(time.Millisecond)
+	e.Subjects = uniqueSubjects(e.Subjects)
+
+	return transaction(db, func(tx *gorm.DB) error {
+		var last AuditEntry
+
+		err := tx.Order("seq DESC").Limit(1).Find(&last).Error
+		if err != nil {
+			return err
+		}
+
+		e.Seq = last.Seq + 1
+		e.PrevHash = last.Hash
+		e.Hash = e.digest()
+
+		err = tx.Create(e).Error
+		if err != nil {
+			return err
+		}
+
+		for _, uid := range e.Subjects {
+			err = tx.Create(&AuditSubject{Seq: e.Seq, UserID: uid}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// uniqueSubjects returns the non-empty user IDs sorted and without duplicates, so the hash of an entry
+// does not depend on the order its subjects were given in
+func uniqueSubjects(uids []string) []string {
+	seen := make(map[string]bool, len(uids))
+	unique := make([]string, 0, len(uids))
+
+	for _, uid := range uids {
+		if uid != "" && !seen[uid] {
+			seen[uid] = true
+			unique = append(unique, uid)
+		}
+	}
+
+	if len(unique) == 0 {
+		return nil
+	}
+
+	sort.Strings(unique)
+
+	return unique
+}
+
+// loadSubjects fills in the Subjects of the entries
+func loadSubjects(db *gorm.DB, entries []*AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	bySeq := make(map[uint64]*AuditEntry, len(entries))
+	seqs := make([]uint64, 0, len(entries))
+	for _, e := range entries {
+		bySeq[e.Seq] = e
+		seqs = append(seqs, e.Seq)
+	}
+
+	var subjects []*AuditSubject
+
+	err := db.Where("seq IN ?", seqs).Order("seq, user_id").Find(&subjects).Error
+	if err != nil {
+		return err
+	}
+
+	for _, s := range subjects {
+		e := bySeq[s.Seq]
+		e.Subjects = append(e.Subjects, s.UserID)
+	}
+
+	return nil
+}
+
+// ListAudit attempts to return up to limit entries of the audit trail following the specified sequence
+// number, in order. If limit is less than or equal to 0, result will not be limited.
+func ListAudit(db *gorm.DB, after uint64, limit int) ([]*AuditEntry, error) {
+	var entries []*AuditEntry
+
+	if limit < 1 {
+		limit = -1
+	}
+
+	err := db.Where("seq > ?", after).Order("seq").Limit(limit).Find(&entries).Error
+	if err == nil {
+		err = loadSubjects(db, entries)
+	}
+	if err != nil {
+		return []*AuditEntry{}, err
+	}
+
+	return entries, nil
+}
+
+// ListUserActivity attempts to return up to limit entries of the audit trail made by or affecting the specified
+// User.ID, most recent first, preceding the specified sequence number unless it is 0. If limit is less than or
+// equal to 0, result will not be limited.
+func ListUserActivity(db *gorm.DB, uid string, before uint64, limit int) ([]*AuditEntry, error) {
+	var entries []*AuditEntry
+
+	if limit < 1 {
+		limit = -1
+	}
+
+	tx := db.Where("user_id = ? OR seq IN (?)", uid, db.Model(&AuditSubject{}).Select("seq").Where("user_id = ?", uid))
+	if before > 0 {
+		tx = tx.Where("seq < ?", before)
+	}
+
+	err := tx.Order("seq DESC").Limit(limit).Find(&entries).Error
+	if err == nil {
+		err = loadSubjects(db, entries)
+	}
+	if err != nil {
+		return []*AuditEntry{}, err
+	}
+
+	return entries, nil
+}
+
+// AuditVerification is the result of checking the chain of the audit trail
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Entries  int    `json:"entries"`             //entries checked
+	Head     string `json:"head,omitempty"`      //hash of the last valid entry
+	BrokenAt uint64 `json:"broken_at,omitempty"` //sequence number of the first entry failing the check
+	Reason   string `json:"reason,omitempty"`
+}
+
+// VerifyAudit checks every entry of the audit trail in order, recomputing its hash and ensuring it links to
+// the entry before it without any gaps, and reports the first entry where the chain is broken
+func VerifyAudit(db *gorm.DB) (*AuditVerification, error) {
+	result := &AuditVerification{Valid: true}
+
+	var prev AuditEntry
+
+	for {
+		entries, err := ListAudit(db, prev.Seq, auditBatch)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			reason := ""
+
+			switch {
+			case e.Seq != prev.Seq+1:
+				reason = fmt.Sprintf("entries %d to %d are missing", prev.Seq+1, e.Seq-1)
+			case e.PrevHash != prev.Hash:
+				reason = "previous hash does not match the entry before it"
+			case e.Hash != e.digest():
+				reason = "hash does not match the contents of the entry"
+			}
+
+			if reason != "" {
+				result.Valid = false
+				result.BrokenAt = e.Seq
+				result.Reason = reason
+
+				return result, nil
+			}
+
+			result.Entries++
+			result.Head = e.Hash
+			prev = *e
+		}
+
+		if len(entries) < auditBatch {
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+// auditTrail returns a database holding an audit trail of the number of entries, each affecting a user
+func auditTrail(t *testing.T, entries int) *gorm.DB {
+	t.Helper()
+
+	db := testDB(t)
+	at := time.Date(2026, time.March, 7, 9, 0, 0, 0, time.UTC)
+
+	for i := 1; i <= entries; i++ {
+		err := AppendAudit(db, &AuditEntry{At: at.Add(time.Duration(i) * time.Minute), UserID: "admin", Role: "admin",
+			Method: "PATCH", Route: "/api/v1/shifts/:id", Path: fmt.Sprintf("/api/v1/shifts/%d", i), Status: 200,
+			Subjects: []string{fmt.Sprintf("user%d", i)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return db
+}
+
+func TestVerifyAudit(t *testing.T) {
+	db := auditTrail(t, 4)
+
+	entries, err := ListAudit(db, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := VerifyAudit(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Valid || result.Entries != 4 || result.Head != entries[3].Hash {
+		t.Errorf("got %+v, want all 4 entries valid up to %s", result, entries[3].Hash)
+	}
+
+	// Entries and their subjects are refused changes through the models
+	err = db.Model(entries[1]).Update("path", "/api/v1/shifts/9").Error
+	if !errors.Is(err, ErrAuditImmutable) {
+		t.Errorf("updating an entry: got %v, want %v", err, ErrAuditImmutable)
+	}
+
+	err = db.Delete(entries[1]).Error
+	if !errors.Is(err, ErrAuditImmutable) {
+		t.Errorf("deleting an entry: got %v, want %v", err, ErrAuditImmutable)
+	}
+
+	err = db.Delete(&AuditSubject{Seq: 2, UserID: "user2"}).Error
+	if !errors.Is(err, ErrAuditImmutable) {
+		t.Errorf("deleting a subject: got %v, want %v", err, ErrAuditImmutable)
+	}
+}
+
+func TestVerifyAuditDetectsTampering(t *testing.T) {
+	// rehashed rewrites the path of the second entry along with its hash, as one covering their tracks would
+	rehashed := func(db *gorm.DB) error {
+		entries, err := ListAudit(db, 1, 1)
+		if err != nil {
+			return err
+		}
+
+		e := entries[0]
+		e.Path = "/api/v1/shifts/9"
+
+		return db.Exec("UPDATE audit_entries SET path = ?, hash = ? WHERE seq = 2", e.Path, e.digest()).Error
+	}
+
+	tests := []struct {
+		name     string
+		tamper   func(db *gorm.DB) error
+		brokenAt uint64
+		checked  int //entries found valid before the break
+	}{
+		{"edited", func(db *gorm.DB) error {
+			return db.Exec("UPDATE audit_entries SET status = 500 WHERE seq = 2").Error
+		}, 2, 1},
+		{"edited and rehashed", rehashed, 3, 2},
+		{"relinked", func(db *gorm.DB) error {
+			return db.Exec("UPDATE audit_entries SET prev_hash = hash WHERE seq = 3").Error
+		}, 3, 2},
+		{"deleted", func(db *gorm.DB) error {
+			return db.Exec("DELETE FROM audit_entries WHERE seq = 2").Error
+		}, 3, 1},
+		{"deleted first", func(db *gorm.DB) error {
+			return db.Exec("DELETE FROM audit_entries WHERE seq = 1").Error
+		}, 2, 0},
+		{"subject added", func(db *gorm.DB) error {
+			return db.Exec("INSERT INTO audit_subjects (seq, user_id) VALUES (2, 'user9')").Error
+		}, 2, 1},
+		{"subject removed", func(db *gorm.DB) error {
+			return db.Exec("DELETE FROM audit_subjects WHERE seq = 2").Error
+		}, 2, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := auditTrail(t, 4)
+
+			err := tt.tamper(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := VerifyAudit(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.Valid || result.BrokenAt != tt.brokenAt || result.Reason == "" {
+				t.Errorf("got %+v, want the chain broken at %d", result, tt.brokenAt)
+			}
+
+			if result.Entries != tt.checked {
+				t.Errorf("checked %d entries before the break, want %d", result.Entries, tt.checked)
+			}
+		})
+	}
+}
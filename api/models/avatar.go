@@ -0,0 +1,46 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// AvatarPrefix is the blob store key prefix avatars are stored under
+const AvatarPrefix = "avatars/"
+
+// AvatarTypes maps the image types accepted as avatars to the extension they are stored with
+var AvatarTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// SetAvatar attempts to replace the avatar of the User with the one stored under the key, an empty
+// key removes it. The key of the replaced avatar is returned so it can be removed from the store.
+func (u *User) SetAvatar(db *gorm.DB, key string) (string, error) {
+	old := u.Avatar
+
+	err := db.Model(u).Where("id = ?", u.ID).Update("avatar", key).Error
+	if err != nil {
+		return "", err
+	}
+
+	u.Avatar = key
+
+	err = u.AfterFind(db)
+	if err != nil {
+		return "", err
+	}
+
+	return old, nil
+}
+
+// AfterFind hooks GORM and resolves the URL the avatar of the User is served from
+func (u *User) AfterFind(db *gorm.DB) error {
+	u.avatarURL = ""
+	if u.Avatar != "" {
+		u.avatarURL = CurrentSettings(db).AvatarURL(u.Avatar)
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package models
+
+import (
+	"errors"
+	"gorm.io/gorm"
+)
+
+// MaxBatchOperations is the most operations a single batch may hold
+const MaxBatchOperations = 100
+
+// The operations a batch may apply to shifts
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// ErrBatchFailed is returned when a batch was rolled back because an operation could not be applied
+var ErrBatchFailed = errors.New("batch rolled back, an operation could not be applied")
+
+// ShiftOperation struct represents an operation of a batch: creating the Shift, updating the shift with the ID
+// by the JSON Merge Patch, or cancelling the shift with the ID for the Reason
+type ShiftOperation struct {
+	Op     string                 `json:"op"`
+	ID     string                 `json:"id,omitempty"`
+	Shift  *Shift                 `json:"shift,omitempty"`
+	Patch  map[string]interface{} `json:"patch,omitempty"`
+	Reason string                 `json:"reason,omitempty"`
+}
+
+// ShiftOperationResult struct represents the outcome of a ShiftOperation within a batch, with the shift as
+// created or updated, or the code of the limit or rule the shift would have violated
+type ShiftOperationResult struct {
+	Op     string `json:"op"`
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Code   string `json:"code,omitempty"`
+	Shift  *Shift `json:"shift,omitempty"`
+
+	// Previous is the shift as it was before being updated or cancelled
+	Previous *Shift `json:"-"`
+}
+
+// RunShiftBatch attempts to apply every operation in a single transaction as an admin would, returning a result
+// for each operation in the order given. Either every operation is applied, or none are and ErrBatchFailed is
+// returned with the failed operations marked in the results. A failure of the database returns no results.
+func RunShiftBatch(db *gorm.DB, ops []*ShiftOperation) ([]*ShiftOperationResult, error) {
+	results := make([]*ShiftOperationResult, len(ops))
+	failed := false
+
+	err := transaction(db, func(tx *gorm.DB) error {
+		for i, op := range ops {
+			results[i] = &ShiftOperationResult{Op: op.Op, ID: op.ID}
+
+			err := op.apply(tx, results[i])
+			if err != nil {
+				// Only failures of the database itself abort the remaining operations
+				if !operationRefused(err) {
+					return err
+				}
+
+				var limit *ShiftLimitError
+				if errors.As(err, &limit) {
+					results[i].Code = limit.Code
+				}
+
+				results[i].Status = BulkFailed
+				results[i].Error = err.Error()
+				results[i].Shift = nil
+				failed = true
+			}
+		}
+
+		if failed {
+			return ErrBatchFailed
+		}
+
+		return nil
+	})
+	if err != nil {
+		if !failed {
+			return nil, err
+		}
+
+		for _, result := range results {
+			if result.Status != BulkFailed {
+				result.Status = BulkRolledBack
+				result.Shift = nil
+			}
+
+			// Shifts created are gone along with their IDs
+			if result.Op == BatchCreate {
+				result.ID = ""
+			}
+		}
+
+		return results, err
+	}
+
+	return results, nil
+}
+
+// operationRefused reports whether the error refuses an operation as requested, rather than being a failure of
+// the database
+func operationRefused(err error) bool {
+	var limit *ShiftLimitError
+
+	return errors.Is(err, ErrInvalid) || errors.Is(err, ErrNotFound) || errors.Is(err, ErrOverlap) ||
+		errors.As(err, &limit)
+}
+
+// apply validates and performs the operation, recording the shifts it affects in the result
+func (op *ShiftOperation) apply(db *gorm.DB, result *ShiftOperationResult) error {
+	switch op.Op {
+	case BatchCreate:
+		if op.Shift == nil {
+			return invalid("shift required")
+		}
+
+		shift := &Shift{
+			UserID:   op.Shift.UserID,
+			Start:    op.Shift.Start,
+			End:      op.Shift.End,
+			Capacity: op.Shift.Capacity,
+			Status:   op.Shift.Status,
+
+			LocationID: op.Shift.LocationID,
+			PositionID: op.Shift.PositionID,
+			Color:      op.Shift.Color,
+			Visibility: op.Shift.Visibility,
+			Metadata:   op.Shift.Metadata,
+			Tags:       op.Shift.Tags,
+		}
+
+		err := shift.save(db, nil)
+		if err != nil {
+			return err
+		}
+
+		result.ID = shift.ID
+		result.Status = BulkCreated
+		result.Shift = shift
+	case BatchUpdate:
+		if op.Patch == nil {
+			return invalid("patch required")
+		}
+
+		shift, err := FindShiftByID(db, op.ID)
+		if err != nil {
+			return err
+		}
+
+		change := &Shift{}
+
+		_, err = ApplyMergePatch(shift, op.Patch, change, ShiftPatchFields...)
+		if err != nil {
+			return err
+		}
+
+		change.ID = shift.ID
+
+		err = change.save(db, shift)
+		if err != nil {
+			return err
+		}
+
+		result.Status = BulkUpdated
+		result.Shift = change
+		result.Previous = shift
+	case BatchDelete:
+		shift, err := FindShiftByID(db, op.ID)
+		if err != nil {
+			return err
+		}
+
+		err = shift.Cancel(db, op.Reason)
+		if err != nil {
+			return err
+		}
+
+		result.Status = BulkDeleted
+		result.Previous = shift
+	default:
+		return invalid("op must be create, update or delete")
+	}
+
+	return nil
+}
+
+// save validates and writes the Shift, creating it unless it updates the previous version, checking any user
+// it is newly assigned to exists
+func (s *Shift) save(db *gorm.DB, previous *Shift) error {
+	err := s.ValidateFor(db, "admin")
+	if err != nil {
+		return err
+	}
+
+	if s.UserID != "" && (previous == nil || s.UserID != previous.UserID) {
+		_, err = FindUserByID(db, s.UserID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if previous == nil {
+		return s.Create(db)
+	}
+
+	return s.Update(db)
+}
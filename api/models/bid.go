@@ -0,0 +1,302 @@
+package models
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// Bid statuses
+const (
+	BidPending  = "pending"
+	BidAwarded  = "awarded"
+	BidRejected = "rejected"
+)
+
+// Bidding award rules
+const (
+	AwardManual    = "manual"    //a manager picks the winning bid
+	AwardSeniority = "seniority" //the longest serving bidder wins when bidding closes
+)
+
+var (
+	ErrNotOpenShift     = errors.New("bidding is only available for open shifts without a worker")
+	ErrBiddingNotFound  = errors.New("shift is not open for bidding")
+	ErrBiddingClosed    = errors.New("bidding is not open at this time")
+	ErrBiddingAwarded   = errors.New("shift has already been awarded")
+	ErrAlreadyBid       = errors.New("already bid on this shift")
+	ErrNoBid            = errors.New("no pending bid on this shift")
+	ErrNoBids           = errors.New("no pending bids to award")
+	ErrBidderIneligible = errors.New("bidder already works a shift overlapping this one")
+	ErrBidderNotInGroup = errors.New("bidding on this shift is restricted to the members of a group")
+)
+
+// Bidding struct represents an open Shift taking bids between Opens and Closes, awarded to one of
+// the bidders by a manager or by the Rule once bidding closes
+type Bidding struct {
+	ShiftID   string     `gorm:"primaryKey" json:"shift_id"`
+	Opens     time.Time  `gorm:"not null" json:"opens"`
+	Closes    time.Time  `gorm:"not null;index" json:"closes"`
+	Rule      string     `gorm:"size:10;not null" json:"rule"`
+	GroupID   string     `gorm:"index" json:"group_id,omitempty"` //restricts bidding to the members of the group
+	AwardedTo string     `json:"awarded_to,omitempty"`
+	AwardedAt *time.Time `gorm:"index" json:"awarded_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// ShiftBid struct represents a User bidding to work an open Shift
+type ShiftBid struct {
+	ShiftID   string    `gorm:"primaryKey" json:"shift_id"`
+	UserID    string    `gorm:"primaryKey" json:"user_id"`
+	Note      string    `gorm:"size:500" json:"note,omitempty"`
+	Status    string    `gorm:"size:10;not null;index" json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate checks to ensure all fields of the object are present and valid
+func (b *Bidding) Validate() error {
+	if b.Opens.IsZero() {
+		return invalid("opening time required")
+	}
+
+	if b.Closes.IsZero() {
+		return invalid("closing time required")
+	}
+
+	if !b.Opens.Before(b.Closes) {
+		return invalid("bidding must open before it closes")
+	}
+
+	switch b.Rule {
+	case AwardManual, AwardSeniority:
+	default:
+		return invalid("rule must be one of manual or seniority")
+	}
+
+	return nil
+}
+
+// IsOpen reports whether bids are being taken at the specified time
+func (b *Bidding) IsOpen(at time.Time) bool {
+	return b.AwardedAt == nil && !at.Before(b.Opens) && at.Before(b.Closes)
+}
+
+// OpenBidding attempts to start taking bids for the open shift, replacing the window and rule
+// of any bidding not yet awarded
+func (s *Shift) OpenBidding(db *gorm.DB, b *Bidding) error {
+	if s.UserID != "" || s.IsEvent() {
+		return ErrNotOpenShift
+	}
+
+	b.ShiftID = s.ID
+
+	return transaction(db, func(tx *gorm.DB) error {
+		existing, err := FindBidding(tx, s.ID)
+		if err == nil && existing.AwardedAt != nil {
+			return ErrBiddingAwarded
+		}
+
+		if err != nil && !errors.Is(err, ErrBiddingNotFound) {
+			return err
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "shift_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"opens", "closes", "rule", "group_id", "updated_at"}),
+		}).Create(b).Error
+	})
+}
+
+// FindBidding attempts to return the Bidding of the specified Shift.ID
+func FindBidding(db *gorm.DB, sid string) (*Bidding, error) {
+	bidding := &Bidding{}
+	err := db.First(&bidding, "shift_id = ?", sid).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &Bidding{}, ErrBiddingNotFound
+		}
+
+		return &Bidding{}, err
+	}
+
+	return bidding, nil
+}
+
+// Bid attempts to place a bid on the shift for the specified User.ID, who must not already work
+// a shift overlapping it, and must be a member of the group bidding is restricted to if any
+func (b *Bidding) Bid(db *gorm.DB, shift *Shift, uid, note string) (*ShiftBid, error) {
+	if !b.IsOpen(time.Now()) {
+		return nil, ErrBiddingClosed
+	}
+
+	bid := &ShiftBid{
+		ShiftID: b.ShiftID,
+		UserID:  uid,
+		Note:    note,
+		Status:  BidPending,
+	}
+
+	err := transaction(db, func(tx *gorm.DB) error {
+		var count int64
+
+		err := tx.Model(&ShiftBid{}).Where("shift_id = ? AND user_id = ?", b.ShiftID, uid).Count(&count).Error
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return ErrAlreadyBid
+		}
+
+		if b.GroupID != "" {
+			member, err := InGroup(tx, b.GroupID, uid)
+			if err != nil {
+				return err
+			}
+
+			if !member {
+				return ErrBidderNotInGroup
+			}
+		}
+
+		busy, err := ListShifts(tx,
+			FilterUserID(uid),
+			FilterStartsBefore(shift.End),
+			FilterEndsAfter(shift.Start),
+			WithLimit(1),
+		)
+		if err != nil {
+			return err
+		}
+
+		if len(busy) > 0 {
+			return ErrBidderIneligible
+		}
+
+		return tx.Create(bid).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bid, nil
+}
+
+// Withdraw attempts to remove the pending bid of the specified User.ID while bidding is open
+func (b *Bidding) Withdraw(db *gorm.DB, uid string) error {
+	if b.AwardedAt != nil {
+		return ErrBiddingAwarded
+	}
+
+	tx := db.Where("shift_id = ? AND user_id = ? AND status = ?", b.ShiftID, uid, BidPending).Delete(&ShiftBid{})
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected == 0 {
+		return ErrNoBid
+	}
+
+	return nil
+}
+
+// ListShiftBids attempts to return the bids on the specified Shift.ID in the order they were placed
+func ListShiftBids(db *gorm.DB, sid string) ([]*ShiftBid, error) {
+	var bids []*ShiftBid
+
+	err := db.Model(&ShiftBid{}).Where("shift_id = ?", sid).Order("created_at").Find(&bids).Error
+	if err != nil {
+		return []*ShiftBid{}, err
+	}
+
+	return bids, nil
+}
+
+// SeniorBidder attempts to return the User.ID of the longest serving user with a pending bid on the shift
+func (b *Bidding) SeniorBidder(db *gorm.DB) (string, error) {
+	var uids []string
+
+	err := db.Model(&ShiftBid{}).
+		Joins("JOIN users ON users.id = shift_bids.user_id").
+		Where("shift_bids.shift_id = ? AND shift_bids.status = ?", b.ShiftID, BidPending).
+		Order("users.created_at, shift_bids.created_at").
+		Limit(1).
+		Pluck("shift_bids.user_id", &uids).Error
+	if err != nil {
+		return "", err
+	}
+
+	if len(uids) == 0 {
+		return "", ErrNoBids
+	}
+
+	return uids[0], nil
+}
+
+// Award attempts to assign the shift to the bidder with the specified User.ID, rejecting every other bid
+func (b *Bidding) Award(db *gorm.DB, shift *Shift, uid string) error {
+	if b.AwardedAt != nil {
+		return ErrBiddingAwarded
+	}
+
+	return transaction(db, func(tx *gorm.DB) error {
+		res := tx.Model(&ShiftBid{}).Where("shift_id = ? AND user_id = ? AND status = ?", b.ShiftID, uid, BidPending).
+			Update("status", BidAwarded)
+
+		err := res.Error
+		if err != nil {
+			return err
+		}
+
+		if res.RowsAffected == 0 {
+			return ErrNoBid
+		}
+
+		err = tx.Model(&ShiftBid{}).Where("shift_id = ? AND user_id <> ? AND status = ?", b.ShiftID, uid, BidPending).
+			Update("status", BidRejected).Error
+		if err != nil {
+			return err
+		}
+
+		// Assigning the worker runs the usual overlap checks
+		shift.UserID = uid
+		err = shift.Update(tx)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		err = tx.Model(b).Updates(map[string]interface{}{
+			"awarded_to": uid,
+			"awarded_at": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		b.AwardedTo = uid
+		b.AwardedAt = &now
+
+		return nil
+	})
+}
+
+// ListDueBiddings attempts to return the biddings awarded by seniority which have closed without being awarded
+func ListDueBiddings(db *gorm.DB, now time.Time) ([]*Bidding, error) {
+	var biddings []*Bidding
+
+	err := db.Model(&Bidding{}).
+		Where("rule = ? AND awarded_at IS NULL AND closes <= ?", AwardSeniority, now).
+		Find(&biddings).Error
+	if err != nil {
+		return []*Bidding{}, err
+	}
+
+	return biddings, nil
+}
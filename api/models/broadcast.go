@@ -0,0 +1,111 @@
+package models
+
+import (
+	"fmt"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Broadcast delivery statuses
+const (
+	DeliverySent   = "sent"
+	DeliveryFailed = "failed"
+)
+
+// Broadcast struct represents an urgent message sent on every channel to the staff on shift when it was sent
+type Broadcast struct {
+	ID         string               `gorm:"primaryKey" json:"id"`
+	SenderID   string               `gorm:"not null" json:"sender_id"`
+	Subject    string               `gorm:"size:200;not null" json:"subject"`
+	Body       string               `gorm:"size:2000;not null" json:"body"`
+	LocationID string               `json:"location_id,omitempty"` //restricts recipients to those on shift at the location
+	PositionID string               `json:"position_id,omitempty"` //restricts recipients to those on shift in the position
+	GroupID    string               `json:"group_id,omitempty"`    //restricts recipients to the members of the group
+	Deliveries []*BroadcastDelivery `gorm:"foreignKey:BroadcastID" json:"deliveries"`
+	Sent       int                  `gorm:"-" json:"sent"`
+	Failed     int                  `gorm:"-" json:"failed"`
+	CreatedAt  time.Time            `json:"created_at"`
+}
+
+// BroadcastDelivery struct represents the outcome of sending a Broadcast to a User on one channel
+type BroadcastDelivery struct {
+	BroadcastID string `gorm:"primaryKey" json:"-"`
+	UserID      string `gorm:"primaryKey" json:"user_id"`
+	Channel     string `gorm:"primaryKey;size:50" json:"channel"`
+	Status      string `gorm:"size:10;not null" json:"status"`
+	Error       string `gorm:"size:500" json:"error,omitempty"`
+}
+
+// Validate checks to ensure all fields of the object are present and valid
+func (b *Broadcast) Validate() error {
+	if b.Subject == "" {
+		return invalid("subject required")
+	}
+
+	if b.Body == "" {
+		return invalid("body required")
+	}
+
+	return nil
+}
+
+// BeforeCreate hooks GORM and prepares a new object for creation
+func (b *Broadcast) BeforeCreate(_ *gorm.DB) error {
+	id, err := nanoid.Nanoid(10)
+	if err != nil {
+		return fmt.Errorf("unable to generate BroadcastID: %s", err)
+	}
+
+	b.ID = id
+
+	return nil
+}
+
+// Create attempts to create the Broadcast object along with its deliveries in the database
+func (b *Broadcast) Create(db *gorm.DB) error {
+	return db.Create(b).Error
+}
+
+// AddDelivery records the outcome of sending the Broadcast to a User on a channel, a nil err meaning it was sent
+func (b *Broadcast) AddDelivery(uid, channel string, err error) {
+	d := &BroadcastDelivery{
+		UserID:  uid,
+		Channel: channel,
+		Status:  DeliverySent,
+	}
+
+	if err != nil {
+		d.Status = DeliveryFailed
+		d.Error = err.Error()
+	}
+
+	b.Deliveries = append(b.Deliveries, d)
+	b.tally()
+}
+
+// tally counts the sent and failed deliveries
+func (b *Broadcast) tally() {
+	b.Sent, b.Failed = 0, 0
+
+	for _, d := range b.Deliveries {
+		if d.Status == DeliverySent {
+			b.Sent++
+		} else {
+			b.Failed++
+		}
+	}
+}
+
+// FindBroadcastByID attempts to return a row from the Broadcasts table with the matching Broadcast.ID along with its deliveries
+func FindBroadcastByID(db *gorm.DB, bid string) (*Broadcast, error) {
+	broadcast := &Broadcast{}
+	err := db.Preload("Deliveries").First(&broadcast, "id = ?", bid).Error
+	if err != nil {
+		return &Broadcast{}, notFound("broadcast", err)
+	}
+
+	broadcast.tally()
+
+	return broadcast, nil
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"errors"
+	"gorm.io/gorm"
+)
+
+const (
+	BulkCreated    = "created"
+	BulkUpdated    = "updated"
+	BulkDeleted    = "deleted"
+	BulkFailed     = "failed"
+	BulkRolledBack = "rolled_back"
+)
+
+// ErrBulkUpdateFailed is returned when a bulk update was rolled back because a change could not be applied
+var ErrBulkUpdateFailed = errors.New("bulk update rolled back, a change could not be applied")
+
+// UserChange struct represents the changes a bulk update applies to a User, fields left nil are unchanged
+// and an empty TeamID or LocationID removes the user from their team or location
+type UserChange struct {
+	UserID     string  `json:"user_id"`
+	Role       *string `json:"role,omitempty"`
+	TeamID     *string `json:"team_id,omitempty"`
+	LocationID *string `json:"location_id,omitempty"`
+}
+
+// UserChangeResult struct represents the outcome of a UserChange within a bulk update
+type UserChangeResult struct {
+	UserID string `json:"user_id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	User   *User  `json:"user,omitempty"`
+}
+
+// BulkUpdateUsers attempts to apply every change in a single transaction, returning a result for each
+// change in the order given. Either every change is applied, or none are and ErrBulkUpdateFailed is
+// returned with the failed changes marked in the results.
+func BulkUpdateUsers(db *gorm.DB, changes []*UserChange) ([]*UserChangeResult, error) {
+	results := make([]*UserChangeResult, len(changes))
+	failed := false
+
+	err := transaction(db, func(tx *gorm.DB) error {
+		teams := make(map[string]bool)
+		locations := make(map[string]bool)
+		seen := make(map[string]bool, len(changes))
+
+		for i, change := range changes {
+			results[i] = &UserChangeResult{UserID: change.UserID}
+
+			user, err := change.apply(tx, teams, locations, seen)
+			if err != nil {
+				// Only failures of the database itself abort the remaining changes
+				var invalid changeError
+				if !errors.As(err, &invalid) {
+					return err
+				}
+
+				results[i].Status = BulkFailed
+				results[i].Error = err.Error()
+				failed = true
+
+				continue
+			}
+
+			user.Password = ""
+			results[i].Status = BulkUpdated
+			results[i].User = user
+		}
+
+		if failed {
+			return ErrBulkUpdateFailed
+		}
+
+		return nil
+	})
+	if err != nil {
+		if failed {
+			for _, result := range results {
+				if result.Status == BulkUpdated {
+					result.Status = BulkRolledBack
+					result.User = nil
+				}
+			}
+		}
+
+		return results, err
+	}
+
+	return results, nil
+}
+
+// changeError is returned for a UserChange which cannot be applied as requested
+type changeError string
+
+func (e changeError) Error() string {
+	return string(e)
+}
+
+// apply validates and writes the change, caching the teams and locations known to exist
+func (c *UserChange) apply(db *gorm.DB, teams, locations, seen map[string]bool) (*User, error) {
+	if c.UserID == "" {
+		return nil, changeError("user id required")
+	}
+
+	if seen[c.UserID] {
+		return nil, changeError("user changed more than once")
+	}
+	seen[c.UserID] = true
+
+	user, err := FindUserByID(db, c.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, changeError("user not found")
+		}
+
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+
+	if c.Role != nil {
+		if *c.Role != "user" && *c.Role != "admin" {
+			return nil, changeError("invalid role")
+		}
+
+		updates["role"] = *c.Role
+	}
+
+	if c.TeamID != nil {
+		if *c.TeamID != "" && !teams[*c.TeamID] {
+			_, err = FindTeamByID(db, *c.TeamID)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, changeError("team not found")
+				}
+
+				return nil, err
+			}
+
+			teams[*c.TeamID] = true
+		}
+
+		updates["team_id"] = *c.TeamID
+	}
+
+	if c.LocationID != nil {
+		if *c.LocationID != "" && !locations[*c.LocationID] {
+			_, err = FindLocationByID(db, *c.LocationID)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, changeError("location not found")
+				}
+
+				return nil, err
+			}
+
+			locations[*c.LocationID] = true
+		}
+
+		updates["location_id"] = *c.LocationID
+	}
+
+	if len(updates) == 0 {
+		return user, nil
+	}
+
+	err = db.Model(user).Updates(updates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
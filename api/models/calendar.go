@@ -0,0 +1,62 @@
+package models
+
+import (
+	"fmt"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// CalendarFeed struct represents a secret token granting read access to an iCalendar feed of shifts.
+// A feed with a TeamID renders the shifts of every member of that team, otherwise it renders
+// the shifts of the owning User.
+type CalendarFeed struct {
+	Token     string    `gorm:"primaryKey" json:"token"`
+	UserID    string    `gorm:"not null;index" json:"user_id"` //owner of the feed
+	TeamID    string    `gorm:"not null;index" json:"team_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `gorm:"-" json:"url,omitempty"` //where the feed is rendered, set when returned to its owner
+}
+
+// Create attempts to create the CalendarFeed object in the database, replacing any
+// previous feed for the same user and team so the old URL stops working
+func (f *CalendarFeed) Create(db *gorm.DB) error {
+	token, err := nanoid.Nanoid(32)
+	if err != nil {
+		return fmt.Errorf("unable to generate calendar token: %s", err)
+	}
+
+	f.Token = token
+
+	return transaction(db, func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ? AND team_id = ?", f.UserID, f.TeamID).Delete(&CalendarFeed{}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(f).Error
+	})
+}
+
+// FindCalendarFeed attempts to return the feed owned by the User.ID for the Team.ID,
+// an empty Team.ID returns the user's personal feed
+func FindCalendarFeed(db *gorm.DB, uid, tid string) (*CalendarFeed, error) {
+	feed := &CalendarFeed{}
+	err := db.First(&feed, "user_id = ? AND team_id = ?", uid, tid).Error
+	if err != nil {
+		return &CalendarFeed{}, notFound("calendar feed", err)
+	}
+
+	return feed, nil
+}
+
+// FindCalendarFeedByToken attempts to return a row from the CalendarFeeds table with the matching Token
+func FindCalendarFeedByToken(db *gorm.DB, token string) (*CalendarFeed, error) {
+	feed := &CalendarFeed{}
+	err := db.First(&feed, "token = ?", token).Error
+	if err != nil {
+		return &CalendarFeed{}, notFound("calendar feed", err)
+	}
+
+	return feed, nil
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"html"
+	"strings"
+	"time"
+)
+
+// Codes of the ShiftLimitError returned when a shift at a position requiring a certification is assigned
+// to a user without it
+const (
+	CertificationMissing = "certification_missing"
+	CertificationExpired = "certification_expired"
+)
+
+// Certification struct represents a skill or qualification held by a user, such as a first aid certificate.
+// Positions may require a certification of the Name, which must not have expired by the end of a shift.
+type Certification struct {
+	ID        string     `gorm:"primaryKey" json:"id"`
+	UserID    string     `gorm:"not null;index" json:"user_id"`
+	Name      string     `gorm:"size:50;not null" json:"name"`
+	ExpiresAt *time.Time `json:"expires_at"`                         //never expires when nil
+	Document  string     `gorm:"size:255" json:"document,omitempty"` //reference to the certificate, such as a URL
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Validate checks to ensure all fields of the object are present and valid
+func (cert *Certification) Validate() error {
+	if strings.TrimSpace(cert.Name) == "" {
+		return invalid("name required")
+	}
+
+	return nil
+}
+
+// Expired reports whether the Certification has expired by the time
+func (cert *Certification) Expired(at time.Time) bool {
+	return cert.ExpiresAt != nil && !cert.ExpiresAt.After(at)
+}
+
+// BeforeCreate hooks GORM and prepares a new object for creation
+func (cert *Certification) BeforeCreate(_ *gorm.DB) error {
+	id, err := nanoid.Nanoid(10)
+	if err != nil {
+		return fmt.Errorf("unable to generate CertificationID: %s", err)
+	}
+
+	cert.ID = id
+	cert.Name = html.EscapeString(strings.TrimSpace(cert.Name))
+
+	return nil
+}
+
+// Create attempts to create the Certification object in the database
+func (cert *Certification) Create(db *gorm.DB) error {
+	return db.Create(cert).Error
+}
+
+// Update will attempt to update the current Certification object in the database
+func (cert *Certification) Update(db *gorm.DB) error {
+	tx := db.Model(cert).Where("id = ?", cert.ID).Updates(
+		map[string]interface{}{
+			"name":       html.EscapeString(strings.TrimSpace(cert.Name)),
+			"expires_at": cert.ExpiresAt,
+			"document":   cert.Document,
+		},
+	).Take(cert)
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected < 1 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Delete will attempt to delete the Certification object from the database
+func (cert *Certification) Delete(db *gorm.DB) error {
+	tx := db.Delete(cert)
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected == 0 {
+		return &NotFoundError{Kind: "certification"}
+	}
+
+	return nil
+}
+
+// ListCertifications attempts to return the certifications of the specified User.ID ordered by name
+func ListCertifications(db *gorm.DB, uid string) ([]*Certification, error) {
+	var certs []*Certification
+
+	err := db.Model(&Certification{}).Where("user_id = ?", uid).Order("name").Find(&certs).Error
+	if err != nil {
+		return []*Certification{}, err
+	}
+
+	return certs, nil
+}
+
+// FindCertificationByID attempts to return a row from the Certifications table with the matching Certification.ID
+func FindCertificationByID(db *gorm.DB, id string) (*Certification, error) {
+	cert := &Certification{}
+	err := db.First(&cert, "id = ?", id).Error
+	if err != nil {
+		return &Certification{}, notFound("certification", err)
+	}
+
+	return cert, nil
+}
+
+// checkCertification returns a ShiftLimitError if the position of the shift requires a certification
+// which its user does not hold, or which expires before the shift ends
+func (s *Shift) checkCertification(db *gorm.DB) error {
+	if s.PositionID == "" || s.UserID == "" {
+		return nil
+	}
+
+	position, err := FindPositionByID(db, s.PositionID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	if position.Certification == "" {
+		return nil
+	}
+
+	var certs []*Certification
+	err = db.Where("user_id = ? AND LOWER(name) = LOWER(?)", s.UserID, position.Certification).
+		Find(&certs).Error
+	if err != nil {
+		return err
+	}
+
+	if len(certs) == 0 {
+		return &ShiftLimitError{
+			Code:    CertificationMissing,
+			Message: fmt.Sprintf("shifts at %s require the %s certification", position.Name, position.Certification),
+		}
+	}
+
+	for _, cert := range certs {
+		if !cert.Expired(s.End) {
+			return nil
+		}
+	}
+
+	return &ShiftLimitError{
+		Code:    CertificationExpired,
+		Message: fmt.Sprintf("the %s certification required at %s expires before the shift ends", position.Certification, position.Name),
+	}
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/utils"
+	"gorm.io/gorm"
+	"math"
+	"sort"
+	"time"
+)
+
+// Kinds of changes made to published shifts
+const (
+	ChangeRescheduled = "rescheduled" // start or end moved
+	ChangeReassigned  = "reassigned"  // given to another user
+	ChangeCancelled   = "cancelled"
+)
+
+// ShiftChange struct represents a change made to a published Shift, recording how long before the shift was
+// due to start it was made, so that chronic last-minute rescheduling can be measured
+type ShiftChange struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ShiftID   string    `gorm:"index;not null" json:"shift_id"`
+	UserID    string    `gorm:"index" json:"user_id,omitempty"` //worker of the shift before the change
+	Kind      string    `gorm:"size:12;not null" json:"kind"`
+	Start     time.Time `gorm:"index;not null" json:"start"` //start of the shift before the change
+	LeadHours float64   `gorm:"not null" json:"lead_hours"`  //hours before Start the change was made, negative once started
+	ChangedAt time.Time `gorm:"not null" json:"changed_at"`
+}
+
+// recordChange records the change of the published shift as it was before, when it affects the worker's schedule
+func recordChange(db *gorm.DB, before *Shift, kind string) error {
+	if before.Status != ShiftPublished || before.UserID == "" {
+		return nil
+	}
+
+	now := time.Now()
+
+	return db.Create(&ShiftChange{
+		ShiftID:   before.ID,
+		UserID:    before.UserID,
+		Kind:      kind,
+		Start:     before.Start,
+		LeadHours: before.Start.Sub(now).Hours(),
+		ChangedAt: now,
+	}).Error
+}
+
+// changeKind returns the kind of change made to the shift, or an empty string when its worker's schedule is unchanged
+func changeKind(before, after *Shift) string {
+	switch {
+	case after.UserID != before.UserID:
+		return ChangeReassigned
+	case !after.Start.Equal(before.Start) || !after.End.Equal(before.End):
+		return ChangeRescheduled
+	}
+
+	return ""
+}
+
+const GroupByManager = "manager"
+
+var ErrInvalidChurnGrouping = errors.New("group_by must be one of team or manager")
+
+// ChurnTotal measures the stability of the schedule of a group of users for a week
+type ChurnTotal struct {
+	Group      string  `json:"group"`          //Team.ID or the manager's User.ID
+	Name       string  `json:"name,omitempty"` //Team.Name or the manager's User.Name
+	Week       string  `json:"week"`           //the date starting the week or its ISO 8601 week
+	Shifts     int     `json:"shifts"`         //published shifts starting in the week
+	Changes    int     `json:"changes"`        //changes made to those shifts
+	LastMinute int     `json:"last_minute"`    //shifts changed within the window before they started
+	Rate       float64 `json:"last_minute_rate"`
+}
+
+// SummarizeChurn counts the published shifts starting between start and end, and the changes made to them, grouped
+// by the team or manager of their worker and by the week they start in. A shift changed less than within before it
+// was due to start counts as changed at the last minute. A zero start or end leaves the range open on that side.
+func SummarizeChurn(db *gorm.DB, groupBy string, within time.Duration, weeks Weeks, start, end time.Time) ([]*ChurnTotal, error) {
+	var group, name, grouping, join string
+
+	switch groupBy {
+	case GroupByTeam:
+		group, name = "COALESCE(users.team_id, '')", "COALESCE(teams.name, '')"
+		grouping, join = "users.team_id, teams.name", "LEFT JOIN teams ON teams.id = users.team_id"
+	case GroupByManager:
+		group, name = "COALESCE(users.manager_id, '')", "COALESCE(managers.name, '')"
+		grouping, join = "users.manager_id, managers.name", "LEFT JOIN users managers ON managers.id = users.manager_id"
+	default:
+		return []*ChurnTotal{}, ErrInvalidChurnGrouping
+	}
+
+	// query returns the rows of the table starting within the range, joined with the tables needed to group them
+	query := func(model interface{}, table string) *gorm.DB {
+		tx := db.Model(model).
+			Joins(fmt.Sprintf("JOIN users ON users.id = %s.user_id", table)).
+			Joins(join)
+
+		if !start.IsZero() {
+			tx = tx.Where(table+".start >= ?", start)
+		}
+
+		if !end.IsZero() {
+			tx = tx.Where(table+".start < ?", end)
+		}
+
+		return tx
+	}
+
+	totals := []*ChurnTotal{}
+
+	// Cancelled shifts were part of the schedule until they were cancelled
+	week := weekStart(db, "shifts.start", weeks.Start)
+	err := query(&Shift{}, "shifts").Unscoped().
+		Where("shifts.status = ? AND shifts.user_id <> ''", ShiftPublished).
+		Select(fmt.Sprintf("%s AS %s, %s AS name, %s AS week, COUNT(*) AS shifts", group, quote(db, "group"), name, week)).
+		Group(grouping + ", " + week).
+		Scan(&totals).Error
+	if err != nil {
+		return []*ChurnTotal{}, err
+	}
+
+	var changes []*ChurnTotal
+
+	week = weekStart(db, "shift_changes.start", weeks.Start)
+	err = query(&ShiftChange{}, "shift_changes").
+		Select(fmt.Sprintf("%s AS %s, %s AS name, %s AS week, COUNT(*) AS changes, "+
+			"COUNT(DISTINCT CASE WHEN shift_changes.lead_hours < ? THEN shift_changes.shift_id END) AS last_minute",
+			group, quote(db, "group"), name, week), within.Hours()).
+		Group(grouping + ", " + week).
+		Scan(&changes).Error
+	if err != nil {
+		return []*ChurnTotal{}, err
+	}
+
+	// Merge the changes into the totals of the shifts they were made to
+	key := func(t *ChurnTotal) string {
+		return t.Group + "\x00" + t.Week
+	}
+
+	byKey := make(map[string]*ChurnTotal, len(totals))
+	for _, t := range totals {
+		byKey[key(t)] = t
+	}
+
+	for _, c := range changes {
+		t, ok := byKey[key(c)]
+		if !ok {
+			// Every shift changed was reassigned or moved out of the group or range since
+			t = &ChurnTotal{Group: c.Group, Name: c.Name, Week: c.Week}
+			byKey[key(c)] = t
+			totals = append(totals, t)
+		}
+
+		t.Changes = c.Changes
+		t.LastMinute = c.LastMinute
+	}
+
+	for _, t := range totals {
+		if t.Shifts > 0 {
+			t.Rate = math.Round(float64(t.LastMinute)/float64(t.Shifts)*1000) / 1000
+		}
+	}
+
+	// Weeks are sorted by their date before any are labelled as ISO weeks
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Name != totals[j].Name {
+			return totals[i].Name < totals[j].Name
+		}
+
+		if totals[i].Group != totals[j].Group {
+			return totals[i].Group < totals[j].Group
+		}
+
+		return totals[i].Week < totals[j].Week
+	})
+
+	if weeks.ISO {
+		for _, t := range totals {
+			if monday, err := time.Parse("2006-01-02", t.Week); err == nil {
+				t.Week = utils.FormatISOWeek(monday)
+			}
+		}
+	}
+
+	return totals, nil
+}
@@ -0,0 +1,209 @@
+package models
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"sort"
+	"time"
+)
+
+const (
+	// conflictSearchSpan is how far either side of a conflicting shift free windows are searched for
+	conflictSearchSpan = time.Hour * 24 * 7
+
+	// maxAlternativeUsers is the most users suggested to take over a conflicting shift
+	maxAlternativeUsers = 5
+)
+
+// ConflictOverlap is the code reported for a shift overlapping another shift of the same user
+const ConflictOverlap = "shift_overlap"
+
+const (
+	SuggestMove     = "move"
+	SuggestReassign = "reassign"
+)
+
+// OverlapError is returned when saving a Shift whose timespan intersects other shifts of the same user
+type OverlapError struct {
+	Conflicts []*Shift
+}
+
+func (e *OverlapError) Error() string {
+	return "shift timespan cannot intersect other shifts for the same user"
+}
+
+// Is reports whether the target is ErrOverlap, so every OverlapError matches it
+func (e *OverlapError) Is(target error) bool {
+	return target == ErrOverlap
+}
+
+// Suggestion struct represents a change which would resolve a shift conflict, applied by updating the
+// shift with the fields it sets: a new Start and End to move it, or a new UserID to reassign it
+type Suggestion struct {
+	Action string     `json:"action"`
+	Start  *time.Time `json:"start,omitempty"`
+	End    *time.Time `json:"end,omitempty"`
+	UserID string     `json:"user_id,omitempty"`
+	Name   string     `json:"name,omitempty"`
+}
+
+// Overlapping returns the other shifts of the same user which intersect the timespan of the Shift
+func (s *Shift) Overlapping(db *gorm.DB) ([]*Shift, error) {
+	return ListShifts(db,
+		FilterUserID(s.UserID),
+		FilterStartsBefore(s.End),
+		FilterEndsAfter(s.Start),
+		func(db *gorm.DB) {
+			if s.ID != "" {
+				db.Where("id <> ?", s.ID)
+			}
+		},
+	)
+}
+
+// SuggestResolutions returns changes which would resolve the overlap of the Shift with other shifts of
+// its user: moving it to the nearest free windows of the same length before and after, and, when
+// reassign is set, handing it to other users who are free at the time, members of the same team first
+func SuggestResolutions(db *gorm.DB, s *Shift, reassign bool) ([]*Suggestion, error) {
+	suggestions := []*Suggestion{}
+
+	moves, err := s.freeWindows(db)
+	if err != nil {
+		return suggestions, err
+	}
+
+	suggestions = append(suggestions, moves...)
+
+	if !reassign {
+		return suggestions, nil
+	}
+
+	users, err := s.freeUsers(db)
+	if err != nil {
+		return suggestions, err
+	}
+
+	for _, user := range users {
+		suggestions = append(suggestions, &Suggestion{
+			Action: SuggestReassign,
+			UserID: user.ID,
+			Name:   user.Name,
+		})
+	}
+
+	return suggestions, nil
+}
+
+// freeWindows returns the nearest start times before and after the Shift at which its user is free for its length
+func (s *Shift) freeWindows(db *gorm.DB) ([]*Suggestion, error) {
+	length := s.End.Sub(s.Start)
+	from, to := s.Start.Add(-conflictSearchSpan), s.End.Add(conflictSearchSpan)
+
+	busy, err := ListShifts(db,
+		FilterUserID(s.UserID),
+		FilterStartsBefore(to),
+		FilterEndsAfter(from),
+		func(db *gorm.DB) {
+			if s.ID != "" {
+				db.Where("id <> ?", s.ID)
+			}
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Windows in the past are only offered for shifts which are themselves in the past
+	now := time.Now()
+	if s.Start.Before(now) {
+		now = time.Time{}
+	}
+
+	free := func(start time.Time) bool {
+		if start.Before(from) || start.Before(now) || start.Add(length).After(to) {
+			return false
+		}
+
+		for _, b := range busy {
+			if b.Start.Before(start.Add(length)) && b.End.After(start) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	// A free window nearest the shift always begins as another shift ends or ends as another begins
+	var before, after *time.Time
+
+	for _, b := range busy {
+		for _, start := range []time.Time{b.End, b.Start.Add(-length)} {
+			if !free(start) {
+				continue
+			}
+
+			start := start
+			switch {
+			case start.Before(s.Start) && (before == nil || start.After(*before)):
+				before = &start
+			case start.After(s.Start) && (after == nil || start.Before(*after)):
+				after = &start
+			}
+		}
+	}
+
+	moves := []*Suggestion{}
+	for _, start := range []*time.Time{before, after} {
+		if start == nil {
+			continue
+		}
+
+		end := start.Add(length)
+		moves = append(moves, &Suggestion{Action: SuggestMove, Start: start, End: &end})
+	}
+
+	// Nearest first
+	sort.SliceStable(moves, func(i, j int) bool {
+		return absDuration(moves[i].Start.Sub(s.Start)) < absDuration(moves[j].Start.Sub(s.Start))
+	})
+
+	return moves, nil
+}
+
+// freeUsers returns other users with no shift or approved time off during the Shift
+func (s *Shift) freeUsers(db *gorm.DB) ([]*User, error) {
+	user, err := FindUserByID(db, s.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	busy := db.Session(&gorm.Session{NewDB: true}).Model(&Shift{}).Select("user_id").
+		Where(fmt.Sprintf("start < ? AND %s > ?", quote(db, "end")), s.End, s.Start)
+	away := db.Session(&gorm.Session{NewDB: true}).Model(&TimeOff{}).Select("user_id").
+		Where(fmt.Sprintf("status = ? AND start < ? AND %s > ?", quote(db, "end")), TimeOffApproved, s.End, s.Start)
+
+	tx := db.Model(&User{}).
+		Where("id <> ? AND id NOT IN (?) AND id NOT IN (?)", s.UserID, busy, away).
+		Limit(maxAlternativeUsers)
+
+	if user.TeamID != "" {
+		tx = tx.Order(gorm.Expr("CASE WHEN team_id = ? THEN 0 ELSE 1 END", user.TeamID))
+	}
+
+	var users []*User
+
+	err = tx.Order("name").Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+
+	return d
+}
@@ -0,0 +1,470 @@
+package models
+
+import (
+	"fmt"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"sort"
+	"time"
+)
+
+// CoverageRequirement struct represents the headcount required to be on shift during a time window,
+// optionally restricted to shifts at a Location and/or in a Position
+type CoverageRequirement struct {
+	ID         string    `gorm:"primaryKey" json:"id"`
+	LocationID string    `gorm:"index" json:"location_id,omitempty"` //empty matches any location
+	PositionID string    `gorm:"index" json:"position_id,omitempty"` //empty matches any position
+	Start      time.Time `gorm:"not null;index" json:"start"`
+	End        time.Time `gorm:"not null" json:"end"`
+	Headcount  int       `gorm:"not null" json:"headcount"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Validate checks to ensure all fields of the object are present and valid
+func (r *CoverageRequirement) Validate() error {
+	if r.Start.IsZero() {
+		return invalid("start time required")
+	}
+
+	if r.End.IsZero() {
+		return invalid("end time required")
+	}
+
+	if !r.Start.Before(r.End) {
+		return invalid("requirement start time must precede requirement end time")
+	}
+
+	if r.Headcount < 1 {
+		return invalid("headcount must be at least 1")
+	}
+
+	return nil
+}
+
+// BeforeCreate hooks GORM and prepares a new object for creation
+func (r *CoverageRequirement) BeforeCreate(_ *gorm.DB) error {
+	id, err := nanoid.Nanoid(10)
+	if err != nil {
+		return fmt.Errorf("unable to generate CoverageRequirementID: %s", err)
+	}
+
+	r.ID = id
+
+	return nil
+}
+
+// Create attempts to create the CoverageRequirement object in the database
+func (r *CoverageRequirement) Create(db *gorm.DB) error {
+	return db.Create(r).Error
+}
+
+// Delete will attempt to delete the CoverageRequirement object from the database
+func (r *CoverageRequirement) Delete(db *gorm.DB) error {
+	tx := db.Delete(r)
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected == 0 {
+		return &NotFoundError{Kind: "coverage requirement"}
+	}
+
+	return nil
+}
+
+// ListCoverageRequirements attempts to return the rows from the CoverageRequirements table overlapping the
+// specified window, ordered by start time. Zero times leave the window open ended and non-empty location
+// or position IDs restrict the results to requirements for exactly that location or position.
+func ListCoverageRequirements(db *gorm.DB, start, end time.Time, lid, pid string) ([]*CoverageRequirement, error) {
+	var reqs []*CoverageRequirement
+
+	tx := db.Model(&CoverageRequirement{}).Order("start")
+
+	if !start.IsZero() {
+		tx.Where("end > ?", start)
+	}
+
+	if !end.IsZero() {
+		tx.Where("start < ?", end)
+	}
+
+	if lid != "" {
+		tx.Where("location_id = ?", lid)
+	}
+
+	if pid != "" {
+		tx.Where("position_id = ?", pid)
+	}
+
+	err := tx.Find(&reqs).Error
+	if err != nil {
+		return []*CoverageRequirement{}, err
+	}
+
+	return reqs, nil
+}
+
+// FindCoverageRequirementByID attempts to return a row from the CoverageRequirements table with the matching ID
+func FindCoverageRequirementByID(db *gorm.DB, rid string) (*CoverageRequirement, error) {
+	req := &CoverageRequirement{}
+	err := db.First(&req, "id = ?", rid).Error
+	if err != nil {
+		return &CoverageRequirement{}, notFound("coverage requirement", err)
+	}
+
+	return req, nil
+}
+
+// CoverageGap is a period within a requirement during which fewer people are scheduled than required
+type CoverageGap struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Scheduled int       `json:"scheduled"`
+	Shortfall int       `json:"shortfall"`
+}
+
+// Coverage compares a CoverageRequirement against the published shifts scheduled during its window
+type Coverage struct {
+	Requirement  *CoverageRequirement `json:"requirement"`
+	Scheduled    int                  `json:"scheduled"` //lowest headcount at any point in the window
+	Shortfall    int                  `json:"shortfall"` //largest shortfall at any point in the window
+	Understaffed bool                 `json:"understaffed"`
+	Gaps         []CoverageGap        `json:"gaps"`
+}
+
+// CoverageShifts returns the filters selecting the published shifts which count towards the requirement
+func (r *CoverageRequirement) CoverageShifts() []ShiftFilterOption {
+	return []ShiftFilterOption{
+		FilterLocationID(r.LocationID),
+		FilterPositionID(r.PositionID),
+		FilterStatus(ShiftPublished),
+		FilterStartsBefore(r.End),
+		FilterEndsAfter(r.Start),
+	}
+}
+
+// ComputeCoverage reports how well each requirement is staffed by published shifts. Assigned shifts count
+// as one person and event shifts count each of their signups.
+func ComputeCoverage(db *gorm.DB, reqs []*CoverageRequirement) ([]*Coverage, error) {
+	return SimulateCoverage(db, reqs, &Scenario{})
+}
+
+// Absence is a hypothetical period a User does not work, dropping their shifts and signups overlapping it
+type Absence struct {
+	UserID string    `json:"user_id"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+}
+
+// HeadcountChange is a hypothetical change to the headcount of a requirement, or of every requirement
+// when no RequirementID is given
+type HeadcountChange struct {
+	RequirementID string `json:"requirement_id,omitempty"`
+	Delta         int    `json:"delta"`
+}
+
+// Scenario is a set of hypothetical changes to the schedule and requirements used to project coverage
+type Scenario struct {
+	Absences  []Absence         `json:"absences"`
+	Headcount []HeadcountChange `json:"headcount"`
+	Shifts    []*Shift          `json:"shifts"` //additional published shifts
+}
+
+// absent reports whether the user is absent for any part of the period
+func (sc *Scenario) absent(uid string, start, end time.Time) bool {
+	for _, a := range sc.Absences {
+		if a.UserID == uid && a.Start.Before(end) && a.End.After(start) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// apply returns a copy of the requirement with its headcount changed by the scenario
+func (sc *Scenario) apply(r *CoverageRequirement) *CoverageRequirement {
+	req := *r
+
+	for _, change := range sc.Headcount {
+		if change.RequirementID == "" || change.RequirementID == r.ID {
+			req.Headcount += change.Delta
+		}
+	}
+
+	if req.Headcount < 0 {
+		req.Headcount = 0
+	}
+
+	return &req
+}
+
+// shifts returns the additional shifts of the scenario counting towards the requirement
+func (sc *Scenario) shifts(r *CoverageRequirement) []*Shift {
+	var shifts []*Shift
+
+	for _, shift := range sc.Shifts {
+		if r.LocationID != "" && shift.LocationID != r.LocationID {
+			continue
+		}
+
+		if r.PositionID != "" && shift.PositionID != r.PositionID {
+			continue
+		}
+
+		if shift.Start.Before(r.End) && shift.End.After(r.Start) {
+			shifts = append(shifts, shift)
+		}
+	}
+
+	return shifts
+}
+
+// SimulateCoverage reports how well each requirement would be staffed if the changes of the scenario
+// were made to the schedule and requirements. Nothing is written to the database.
+func SimulateCoverage(db *gorm.DB, reqs []*CoverageRequirement, sc *Scenario) ([]*Coverage, error) {
+	report := make([]*Coverage, 0, len(reqs))
+
+	for _, req := range reqs {
+		listed, err := ListShifts(db, req.CoverageShifts()...)
+		if err != nil {
+			return nil, err
+		}
+
+		var shifts, events []*Shift
+		for _, shift := range listed {
+			if shift.IsEvent() {
+				events = append(events, shift)
+			} else if shift.UserID != "" && sc.absent(shift.UserID, shift.Start, shift.End) {
+				continue
+			}
+
+			shifts = append(shifts, shift)
+		}
+
+		err = CountSignups(db, events)
+		if err != nil {
+			return nil, err
+		}
+
+		err = sc.dropSignups(db, events)
+		if err != nil {
+			return nil, err
+		}
+
+		shifts = append(shifts, sc.shifts(req)...)
+
+		report = append(report, sc.apply(req).coverage(shifts))
+	}
+
+	return report, nil
+}
+
+// dropSignups removes the signups of absent users from the counts of the event shifts
+func (sc *Scenario) dropSignups(db *gorm.DB, events []*Shift) error {
+	if len(events) == 0 || len(sc.Absences) == 0 {
+		return nil
+	}
+
+	sids := make([]string, 0, len(events))
+	for _, shift := range events {
+		sids = append(sids, shift.ID)
+	}
+
+	uids := make([]string, 0, len(sc.Absences))
+	for _, a := range sc.Absences {
+		uids = append(uids, a.UserID)
+	}
+
+	var signups []*Signup
+	err := db.Where("shift_id IN ? AND user_id IN ?", sids, uids).Find(&signups).Error
+	if err != nil {
+		return err
+	}
+
+	for _, shift := range events {
+		for _, signup := range signups {
+			if signup.ShiftID == shift.ID && sc.absent(signup.UserID, shift.Start, shift.End) {
+				shift.Signups--
+			}
+		}
+	}
+
+	return nil
+}
+
+// coverage sweeps the shifts overlapping the requirement window, tracking the headcount
+// between each start and end to find the periods where it falls below the requirement
+func (r *CoverageRequirement) coverage(shifts []*Shift) *Coverage {
+	type change struct {
+		at    time.Time
+		delta int
+	}
+
+	var changes []change
+	for _, shift := range shifts {
+		heads := 1
+		if shift.IsEvent() {
+			heads = shift.Signups
+		} else if shift.UserID == "" {
+			heads = 0
+		}
+
+		if heads == 0 {
+			continue
+		}
+
+		start, end := shift.Start, shift.End
+		if start.Before(r.Start) {
+			start = r.Start
+		}
+		if end.After(r.End) {
+			end = r.End
+		}
+
+		changes = append(changes, change{start, heads}, change{end, -heads})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].at.Before(changes[j].at)
+	})
+
+	cov := &Coverage{
+		Requirement: r,
+		Scheduled:   -1,
+		Gaps:        []CoverageGap{},
+	}
+
+	// record accounts for the headcount held over the period between from and to
+	record := func(from, to time.Time, heads int) {
+		if !from.Before(to) {
+			return
+		}
+
+		if cov.Scheduled < 0 || heads < cov.Scheduled {
+			cov.Scheduled = heads
+		}
+
+		if heads >= r.Headcount {
+			return
+		}
+
+		// Extend the previous gap if the shortfall is unchanged and contiguous
+		if n := len(cov.Gaps); n > 0 && cov.Gaps[n-1].End.Equal(from) && cov.Gaps[n-1].Scheduled == heads {
+			cov.Gaps[n-1].End = to
+			return
+		}
+
+		cov.Gaps = append(cov.Gaps, CoverageGap{
+			Start:     from,
+			End:       to,
+			Scheduled: heads,
+			Shortfall: r.Headcount - heads,
+		})
+	}
+
+	heads := 0
+	cursor := r.Start
+	for _, c := range changes {
+		record(cursor, c.at, heads)
+		heads += c.delta
+		if c.at.After(cursor) {
+			cursor = c.at
+		}
+	}
+	record(cursor, r.End, heads)
+
+	for _, gap := range cov.Gaps {
+		if gap.Shortfall > cov.Shortfall {
+			cov.Shortfall = gap.Shortfall
+		}
+	}
+
+	cov.Understaffed = len(cov.Gaps) > 0
+
+	return cov
+}
+
+// OnDuty attempts to return the IDs of the users working a published shift at the specified time, optionally
+// restricted to a location and/or position. Workers of assigned shifts and those signed up to events are included.
+func OnDuty(db *gorm.DB, at time.Time, lid, pid string) ([]string, error) {
+	shifts, err := ListShifts(db,
+		FilterLocationID(lid),
+		FilterPositionID(pid),
+		FilterStatus(ShiftPublished),
+		FilterActiveAt(at),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var uids, events []string
+
+	for _, shift := range shifts {
+		if shift.IsEvent() {
+			events = append(events, shift.ID)
+		} else if shift.UserID != "" && !seen[shift.UserID] {
+			seen[shift.UserID] = true
+			uids = append(uids, shift.UserID)
+		}
+	}
+
+	if len(events) > 0 {
+		var attendees []string
+
+		err = db.Model(&Signup{}).Distinct("user_id").Where("shift_id IN ?", events).Pluck("user_id", &attendees).Error
+		if err != nil {
+			return nil, err
+		}
+
+		for _, uid := range attendees {
+			if !seen[uid] {
+				seen[uid] = true
+				uids = append(uids, uid)
+			}
+		}
+	}
+
+	return uids, nil
+}
+
+// OpenShifts returns the unassigned shifts needed to staff the requirement between start and end, which are
+// left to be claimed or assigned. The requirement window is clipped to the period, and shifts already scheduled
+// over exactly that window at the same location and position count towards the headcount, so generating the
+// same period twice does not duplicate shifts.
+func (r *CoverageRequirement) OpenShifts(db *gorm.DB, start, end time.Time) ([]*Shift, error) {
+	from, to := r.Start, r.End
+	if from.Before(start) {
+		from = start
+	}
+	if to.After(end) {
+		to = end
+	}
+
+	if !from.Before(to) {
+		return nil, nil
+	}
+
+	var existing int64
+	err := db.Model(&Shift{}).
+		Where("location_id = ? AND position_id = ? AND start = ? AND end = ?", r.LocationID, r.PositionID, from, to).
+		Count(&existing).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var shifts []*Shift
+	for i := int(existing); i < r.Headcount; i++ {
+		shifts = append(shifts, &Shift{
+			Start:      from,
+			End:        to,
+			LocationID: r.LocationID,
+			PositionID: r.PositionID,
+		})
+	}
+
+	return shifts, nil
+}
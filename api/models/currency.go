@@ -0,0 +1,27 @@
+package models
+
+import (
+	"golang.org/x/text/currency"
+	"gorm.io/gorm"
+)
+
+// DefaultCurrency is the currency pay is reported in unless the organization sets its own
+const DefaultCurrency = "USD"
+
+// ValidCurrency reports whether the code is an ISO 4217 currency code, written in upper case such as EUR
+func ValidCurrency(code string) bool {
+	unit, err := currency.ParseISO(code)
+
+	return err == nil && unit.String() == code
+}
+
+// shiftCurrency is the SQL expression of the currency of the location of each shift, from the table joined by
+// joinCurrency, empty when the location does not override the organization's
+const shiftCurrency = "COALESCE(sites.site_currency, '')"
+
+// joinCurrency joins the currency of the location of each shift, renaming its columns so that filters on the
+// columns of the shifts stay unambiguous
+func joinCurrency(tx *gorm.DB) *gorm.DB {
+	return tx.Joins("LEFT JOIN (SELECT id AS site_id, currency AS site_currency FROM locations) sites " +
+		"ON sites.site_id = shifts.location_id")
+}
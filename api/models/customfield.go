@@ -0,0 +1,213 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Custom field types
+const (
+	FieldText    = "text"
+	FieldNumber  = "number"
+	FieldBoolean = "boolean"
+	FieldDate    = "date" // YYYY-MM-DD
+)
+
+// fieldName restricts custom field names to those which are safe to use in a JSON path
+var fieldName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// CustomField struct represents a field admins define for every User, such as a badge number, whose values
+// are stored in User.CustomFields
+type CustomField struct {
+	Name      string    `gorm:"primaryKey;size:64" json:"name"`
+	Type      string    `gorm:"size:10;not null" json:"type"` //text, number, boolean or date
+	Required  bool      `gorm:"not null;default:false" json:"required"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate checks to ensure all fields of the object are present and valid
+func (f *CustomField) Validate() error {
+	if !fieldName.MatchString(f.Name) {
+		return invalid("name must start with a lowercase letter followed by up to 63 lowercase letters, digits or underscores")
+	}
+
+	switch f.Type {
+	case FieldText, FieldNumber, FieldBoolean, FieldDate:
+	default:
+		return invalid("type must be one of text, number, boolean or date")
+	}
+
+	return nil
+}
+
+// Create attempts to create the CustomField object in the database
+func (f *CustomField) Create(db *gorm.DB) error {
+	return db.Create(f).Error
+}
+
+// Update will attempt to update the type and requirement of the current CustomField object in the database.
+// Values users already hold are not converted, they are checked against the new type when next written.
+func (f *CustomField) Update(db *gorm.DB) error {
+	tx := db.Model(f).Where("name = ?", f.Name).Updates(
+		map[string]interface{}{
+			"type":     f.Type,
+			"required": f.Required,
+		},
+	).Take(f)
+
+	return tx.Error
+}
+
+// Delete will attempt to delete the CustomField object from the database. Values users hold for it are kept
+// until their custom fields are next written.
+func (f *CustomField) Delete(db *gorm.DB) error {
+	return db.Delete(f).Error
+}
+
+// ListCustomFields attempts to return every row from the CustomFields table ordered by name
+func ListCustomFields(db *gorm.DB) ([]*CustomField, error) {
+	var fields []*CustomField
+
+	err := db.Order("name").Find(&fields).Error
+	if err != nil {
+		return []*CustomField{}, err
+	}
+
+	return fields, nil
+}
+
+// FindCustomField attempts to return the row from the CustomFields table with the matching Name
+func FindCustomField(db *gorm.DB, name string) (*CustomField, error) {
+	field := &CustomField{}
+	err := db.First(&field, "name = ?", name).Error
+	if err != nil {
+		return &CustomField{}, notFound("custom field", err)
+	}
+
+	return field, nil
+}
+
+// check ensures the value is of the type of the CustomField
+func (f *CustomField) check(value interface{}) error {
+	ok := false
+
+	switch f.Type {
+	case FieldText:
+		_, ok = value.(string)
+	case FieldNumber:
+		_, ok = value.(float64)
+	case FieldBoolean:
+		_, ok = value.(bool)
+	case FieldDate:
+		var s string
+		if s, ok = value.(string); ok {
+			_, err := time.Parse("2006-01-02", s)
+			ok = err == nil
+		}
+	}
+
+	if !ok {
+		return invalidf("custom field %s must be a %s", f.Name, f.Type)
+	}
+
+	return nil
+}
+
+// ValidateCustomFields checks the values against the defined custom fields, ensuring every field is defined,
+// holds a value of its type, and that required fields are present
+func ValidateCustomFields(db *gorm.DB, values Metadata) error {
+	err := values.Validate()
+	if err != nil {
+		return err
+	}
+
+	fields, err := ListCustomFields(db)
+	if err != nil {
+		return err
+	}
+
+	defined := make(map[string]*CustomField, len(fields))
+	for _, f := range fields {
+		defined[f.Name] = f
+
+		if _, ok := values[f.Name]; f.Required && !ok {
+			return invalidf("custom field %s required", f.Name)
+		}
+	}
+
+	for name, value := range values {
+		f, ok := defined[name]
+		if !ok {
+			return invalidf("custom field %s is not defined", name)
+		}
+
+		// Optional fields are cleared with null
+		if value == nil && !f.Required {
+			delete(values, name)
+			continue
+		}
+
+		err = f.check(value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FieldFilter selects the users whose custom field holds the value
+type FieldFilter struct {
+	Name  string
+	Value string // compared with the value as written in JSON, such as 42, true or 2024-08-05
+}
+
+// filterFields narrows the query to users matching every filter. Values are parsed as the type of their
+// field, so that 42.0 matches a number stored as 42.
+func filterFields(db, tx *gorm.DB, filters []FieldFilter) (*gorm.DB, error) {
+	if len(filters) == 0 {
+		return tx, nil
+	}
+
+	fields, err := ListCustomFields(db)
+	if err != nil {
+		return nil, err
+	}
+
+	defined := make(map[string]*CustomField, len(fields))
+	for _, f := range fields {
+		defined[f.Name] = f
+	}
+
+	for _, filter := range filters {
+		f, ok := defined[filter.Name]
+		if !ok {
+			return nil, invalidf("custom field %s is not defined", filter.Name)
+		}
+
+		var value interface{} = filter.Value
+
+		switch f.Type {
+		case FieldNumber:
+			value, err = strconv.ParseFloat(strings.TrimSpace(filter.Value), 64)
+		case FieldBoolean:
+			value, err = strconv.ParseBool(strings.TrimSpace(filter.Value))
+		}
+		if err != nil {
+			return nil, invalidf("custom field %s must be a %s", f.Name, f.Type)
+		}
+
+		cond, args, err := jsonEquals(db, "custom_fields", f.Name, value)
+		if err != nil {
+			return nil, err
+		}
+
+		tx = tx.Where(cond, args...)
+	}
+
+	return tx, nil
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"time"
+)
+
+var (
+	ErrDelegateNotFound = &NotFoundError{Kind: "delegate"}
+	ErrDelegateSelf     = errors.New("approval authority cannot be delegated to oneself")
+)
+
+// Delegation struct represents a manager handing their approval authority to another user between
+// Start and End, such as while they are on leave. Requests routed to the manager during the window,
+// whether made before or during it, are routed to the delegate instead.
+type Delegation struct {
+	ID         string    `gorm:"primaryKey" json:"id"`
+	ManagerID  string    `gorm:"not null;index" json:"manager_id"`
+	DelegateID string    `gorm:"not null;index" json:"delegate_id"`
+	Start      time.Time `gorm:"not null" json:"start"`
+	End        time.Time `gorm:"not null" json:"end"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Validate checks to ensure all fields of the object are present and valid
+func (d *Delegation) Validate() error {
+	if d.DelegateID == "" {
+		return invalid("delegate id required")
+	}
+
+	if d.DelegateID == d.ManagerID {
+		return ErrDelegateSelf
+	}
+
+	if d.Start.IsZero() || d.End.IsZero() {
+		return invalid("start and end required")
+	}
+
+	if !d.Start.Before(d.End) {
+		return invalid("delegation start must precede its end")
+	}
+
+	return nil
+}
+
+// BeforeCreate hooks GORM and prepares a new object for creation
+func (d *Delegation) BeforeCreate(_ *gorm.DB) error {
+	id, err := nanoid.Nanoid(10)
+	if err != nil {
+		return fmt.Errorf("unable to generate DelegationID: %s", err)
+	}
+
+	d.ID = id
+
+	return nil
+}
+
+// Create attempts to create the Delegation object in the database, ensuring the delegate exists
+func (d *Delegation) Create(db *gorm.DB) error {
+	_, err := FindUserByID(db, d.DelegateID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDelegateNotFound
+		}
+
+		return err
+	}
+
+	return db.Create(d).Error
+}
+
+// Delete will attempt to delete the Delegation object from the database
+func (d *Delegation) Delete(db *gorm.DB) error {
+	tx := db.Delete(d)
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected == 0 {
+		return &NotFoundError{Kind: "delegation"}
+	}
+
+	return nil
+}
+
+// ListDelegations attempts to return the delegations made by the specified manager User.ID which have not ended
+func ListDelegations(db *gorm.DB, mid string) ([]*Delegation, error) {
+	var delegations []*Delegation
+
+	err := db.Model(&Delegation{}).Where(fmt.Sprintf("manager_id = ? AND %s > ?", quote(db, "end")), mid, time.Now()).
+		Order("start").Find(&delegations).Error
+	if err != nil {
+		return []*Delegation{}, err
+	}
+
+	return delegations, nil
+}
+
+// FindDelegationByID attempts to return a row from the Delegations table with the matching Delegation.ID
+func FindDelegationByID(db *gorm.DB, did string) (*Delegation, error) {
+	delegation := &Delegation{}
+	err := db.First(&delegation, "id = ?", did).Error
+	if err != nil {
+		return &Delegation{}, notFound("delegation", err)
+	}
+
+	return delegation, nil
+}
+
+// ActingApprover returns the user exercising the approval authority of the specified manager User.ID at
+// the time, following delegations made by delegates who are themselves away. The manager is returned
+// when they have not delegated, or when the delegations lead back to them.
+func ActingApprover(db *gorm.DB, mid string, at time.Time) (string, error) {
+	seen := map[string]bool{mid: true}
+	acting := mid
+
+	for {
+		delegation := &Delegation{}
+
+		tx := db.Where(fmt.Sprintf("manager_id = ? AND start <= ? AND %s > ?", quote(db, "end")), acting, at, at).
+			Order("created_at DESC").Limit(1).Find(delegation)
+		if tx.Error != nil {
+			return "", tx.Error
+		}
+
+		if tx.RowsAffected == 0 {
+			return acting, nil
+		}
+
+		if seen[delegation.DelegateID] {
+			return mid, nil
+		}
+		seen[delegation.DelegateID] = true
+
+		acting = delegation.DelegateID
+	}
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The SQL for date arithmetic differs between databases, these helpers return the
+// expression for the dialect of the connection. Column names are quoted as given.
+
+// quote returns the column name quoted for the dialect
+func quote(db *gorm.DB, column string) string {
+	var b strings.Builder
+	db.Dialector.QuoteTo(&b, column)
+	return b.String()
+}
+
+// hoursBetween returns an expression for the number of hours between two timestamp columns
+func hoursBetween(db *gorm.DB, start, end string) string {
+	start, end = quote(db, start), quote(db, end)
+
+	switch db.Dialector.Name() {
+	case "postgres":
+		return fmt.Sprintf("EXTRACT(EPOCH FROM (%s - %s)) / 3600.0", end, start)
+	case "mysql":
+		return fmt.Sprintf("TIMESTAMPDIFF(SECOND, %s, %s) / 3600.0", start, end)
+	case "sqlserver":
+		return fmt.Sprintf("DATEDIFF_BIG(SECOND, %s, %s) / 3600.0", start, end)
+	default:
+		return fmt.Sprintf("(STRFTIME('%%s', %s) - STRFTIME('%%s', %s)) / 3600.0", end, start)
+	}
+}
+
+// weekStart returns an expression for the date of the first day of the week of a timestamp column,
+// for weeks beginning on the specified weekday
+func weekStart(db *gorm.DB, column string, first time.Weekday) string {
+	column = quote(db, column)
+	w := int(first)
+
+	switch db.Dialector.Name() {
+	case "postgres":
+		return fmt.Sprintf("TO_CHAR(CAST(%s AS date) - CAST((EXTRACT(DOW FROM %s) + %d) AS integer) %% 7, 'YYYY-MM-DD')", column, column, 7-w)
+	case "mysql":
+		return fmt.Sprintf("DATE_FORMAT(DATE_SUB(DATE(%s), INTERVAL (DAYOFWEEK(%s) + %d) %% 7 DAY), '%%Y-%%m-%%d')", column, column, 6-w)
+	case "sqlserver":
+		return fmt.Sprintf("CONVERT(char(10), DATEADD(day, -((DATEPART(weekday, %s) + @@DATEFIRST + %d) %% 7), CAST(%s AS date)), 23)", column, 6-w, column)
+	default:
+		// Advance to the last day of the week, then back to its first
+		return fmt.Sprintf("DATE(%s, 'weekday %d', '-6 days')", column, (w+6)%7)
+	}
+}
+
+// jsonEquals returns a condition, and its arguments, matching rows whose JSON column holds the value under the key.
+// SQLite may be built without JSON functions, so there the serialized column is matched against the key and value
+// as they are serialized. The key must be safe to use in a JSON path.
+func jsonEquals(db *gorm.DB, column, key string, value interface{}) (string, []interface{}, error) {
+	column = quote(db, column)
+
+	var text string
+	switch v := value.(type) {
+	case float64:
+		text = strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		text = strconv.FormatBool(v)
+	case string:
+		text = v
+	default:
+		return "", nil, fmt.Errorf("cannot compare %T in JSON", value)
+	}
+
+	switch db.Dialector.Name() {
+	case "postgres":
+		return fmt.Sprintf("%s::jsonb ->> '%s' = ?", column, key), []interface{}{text}, nil
+	case "mysql":
+		return fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%s, '$.%s')) = ?", column, key), []interface{}{text}, nil
+	case "sqlserver":
+		return fmt.Sprintf("JSON_VALUE(%s, '$.%s') = ?", column, key), []interface{}{text}, nil
+	default:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "", nil, err
+		}
+
+		// GLOB is case sensitive where LIKE is not, its wildcards are escaped by enclosing them in brackets
+		escaper := strings.NewReplacer("*", "[*]", "?", "[?]", "[", "[[]")
+		pair := escaper.Replace(fmt.Sprintf("%q:%s", key, encoded))
+
+		return fmt.Sprintf("(%s GLOB ? OR %s GLOB ?)", column, column), []interface{}{"*" + pair + ",*", "*" + pair + "}"}, nil
+	}
+}
@@ -0,0 +1,271 @@
+package models
+
+import (
+	"fmt"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"html"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Differential struct represents a pay differential, such as a night or weekend premium, paying the hours
+// worked within its window at the Multiplier. The window runs from Start to End on each of its Weekdays in
+// its Timezone, continuing into the following day when End is not after Start. Where the windows of several
+// differentials overlap the highest multiplier applies.
+type Differential struct {
+	ID         string    `gorm:"primaryKey" json:"id"`
+	Name       string    `gorm:"size:50;not null" json:"name"`
+	Weekdays   string    `gorm:"size:27" json:"weekdays,omitempty"` //e.g. Sat,Sun, empty applies every day
+	Start      string    `gorm:"size:5;not null" json:"start"`      //time of day, HH:MM
+	End        string    `gorm:"size:5;not null" json:"end"`        //time of day, HH:MM
+	Timezone   string    `gorm:"size:64;not null" json:"timezone"`  //IANA zone the window is in
+	Multiplier float64   `gorm:"not null" json:"multiplier"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Differentials is a set of pay differentials applied together
+type Differentials []*Differential
+
+// Validate checks to ensure all fields of the object are present and valid
+func (d *Differential) Validate() error {
+	if d.Name == "" {
+		return invalid("name required")
+	}
+
+	if _, err := time.Parse("15:04", d.Start); err != nil {
+		return invalid("start must be a time formatted HH:MM")
+	}
+
+	if _, err := time.Parse("15:04", d.End); err != nil {
+		return invalid("end must be a time formatted HH:MM")
+	}
+
+	if _, err := time.LoadLocation(d.Timezone); err != nil {
+		return invalid("invalid timezone")
+	}
+
+	if _, err := d.weekdays(); err != nil {
+		return err
+	}
+
+	if d.Multiplier <= 0 {
+		return invalid("multiplier must be positive")
+	}
+
+	return nil
+}
+
+// weekdays returns the days the window starts on, nil meaning every day
+func (d *Differential) weekdays() (map[time.Weekday]bool, error) {
+	if strings.TrimSpace(d.Weekdays) == "" {
+		return nil, nil
+	}
+
+	days := make(map[time.Weekday]bool)
+
+	for _, name := range strings.Split(d.Weekdays, ",") {
+		found := false
+
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(strings.TrimSpace(name), day.String()[:3]) {
+				days[day] = true
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, invalidf("invalid weekday %q, expected Mon, Tue, Wed, Thu, Fri, Sat or Sun", name)
+		}
+	}
+
+	return days, nil
+}
+
+// BeforeCreate hooks GORM and prepares a new object for creation
+func (d *Differential) BeforeCreate(_ *gorm.DB) error {
+	id, err := nanoid.Nanoid(8)
+	if err != nil {
+		return fmt.Errorf("unable to generate DifferentialID: %s", err)
+	}
+
+	d.ID = id
+	d.Name = html.EscapeString(strings.TrimSpace(d.Name))
+
+	return nil
+}
+
+// Create attempts to create the Differential object in the database
+func (d *Differential) Create(db *gorm.DB) error {
+	return db.Create(d).Error
+}
+
+// Delete will attempt to delete the Differential object from the database
+func (d *Differential) Delete(db *gorm.DB) error {
+	tx := db.Delete(d)
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected == 0 {
+		return &NotFoundError{Kind: "differential"}
+	}
+
+	return nil
+}
+
+// ListDifferentials attempts to return all rows from the Differentials table ordered by name
+func ListDifferentials(db *gorm.DB) (Differentials, error) {
+	var diffs Differentials
+
+	err := db.Model(&Differential{}).Order("name").Find(&diffs).Error
+	if err != nil {
+		return Differentials{}, err
+	}
+
+	return diffs, nil
+}
+
+// FindDifferentialByID attempts to return a row from the Differentials table with the matching Differential.ID
+func FindDifferentialByID(db *gorm.DB, did string) (*Differential, error) {
+	diff := &Differential{}
+	err := db.First(&diff, "id = ?", did).Error
+	if err != nil {
+		return &Differential{}, notFound("differential", err)
+	}
+
+	return diff, nil
+}
+
+// windows returns the periods of the differential overlapping the span between start and end
+func (d *Differential) windows(start, end time.Time) []span {
+	loc, err := time.LoadLocation(d.Timezone)
+	if err != nil {
+		return nil
+	}
+
+	days, err := d.weekdays()
+	if err != nil {
+		return nil
+	}
+
+	from, _ := time.Parse("15:04", d.Start)
+	to, _ := time.Parse("15:04", d.End)
+
+	var spans []span
+
+	// Begin the day before so windows running past midnight into the span are included
+	first := start.In(loc).AddDate(0, 0, -1)
+	for day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc); day.Before(end); day = day.AddDate(0, 0, 1) {
+		if days != nil && !days[day.Weekday()] {
+			continue
+		}
+
+		open := time.Date(day.Year(), day.Month(), day.Day(), from.Hour(), from.Minute(), 0, 0, loc)
+		closed := time.Date(day.Year(), day.Month(), day.Day(), to.Hour(), to.Minute(), 0, 0, loc)
+		if !closed.After(open) {
+			closed = closed.AddDate(0, 0, 1)
+		}
+
+		if open.Before(end) && closed.After(start) {
+			spans = append(spans, span{open, closed, d.Multiplier})
+		}
+	}
+
+	return spans
+}
+
+// span is a period paid at a multiplier
+type span struct {
+	start, end time.Time
+	multiplier float64
+}
+
+// Effective returns the average multiplier the differentials pay across the span between start and end,
+// which is 1 when none apply
+func (diffs Differentials) Effective(start, end time.Time) float64 {
+	length := end.Sub(start)
+	if length <= 0 {
+		return 1
+	}
+
+	var spans []span
+	for _, d := range diffs {
+		spans = append(spans, d.windows(start, end)...)
+	}
+
+	if len(spans) == 0 {
+		return 1
+	}
+
+	// Split the span at every window boundary and pay each piece at the highest multiplier covering it
+	points := []time.Time{start, end}
+	for _, s := range spans {
+		if s.start.After(start) && s.start.Before(end) {
+			points = append(points, s.start)
+		}
+		if s.end.After(start) && s.end.Before(end) {
+			points = append(points, s.end)
+		}
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Before(points[j])
+	})
+
+	weighted := 0.0
+	for i := 1; i < len(points); i++ {
+		from, to := points[i-1], points[i]
+		if !from.Before(to) {
+			continue
+		}
+
+		multiplier := 1.0
+		for _, s := range spans {
+			if !s.start.After(from) && !s.end.Before(to) && s.multiplier > multiplier {
+				multiplier = s.multiplier
+			}
+		}
+
+		weighted += to.Sub(from).Seconds() * multiplier
+	}
+
+	return weighted / length.Seconds()
+}
+
+// FlagDifferentials populates the Differential of each of the specified shifts worked partly or
+// entirely within the window of a pay differential
+func FlagDifferentials(db *gorm.DB, shifts []*Shift) error {
+	if len(shifts) == 0 {
+		return nil
+	}
+
+	diffs, err := ListDifferentials(db)
+	if err != nil {
+		return err
+	}
+
+	for _, shift := range shifts {
+		shift.Differential = 0
+
+		if effective := diffs.Effective(shift.Start, shift.End); effective != 1 {
+			shift.Differential = effective
+		}
+	}
+
+	return nil
+}
+
+// AnnotateShifts populates the holiday and pay differential of each of the specified shifts
+func AnnotateShifts(db *gorm.DB, shifts []*Shift) error {
+	err := FlagHolidays(db, shifts)
+	if err != nil {
+		return err
+	}
+
+	return FlagDifferentials(db, shifts)
+}
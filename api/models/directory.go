@@ -0,0 +1,108 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DirectoryEntry struct represents what users may see of a coworker: who they are, their team, and the
+// positions they work
+type DirectoryEntry struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	TeamID    string   `json:"team_id,omitempty"`
+	Team      string   `json:"team,omitempty"`
+	Positions []string `json:"positions"` //of their published shifts since the directory's cut-off
+	Avatar    string   `json:"-"`         //blob store key, exposed as avatar_url
+
+	avatarURL string // the Avatar is served from
+}
+
+// ListDirectory attempts to return the entries of the active users whose name contains the search, of the
+// specified Team.ID when not empty, ordered by name. Their positions are those of their published shifts
+// ending after since.
+func ListDirectory(db *gorm.DB, search, tid string, since time.Time) ([]*DirectoryEntry, error) {
+	return listDirectory(db, search, tid, since, 0)
+}
+
+// listDirectory returns the first limit entries ListDirectory would, or every entry when limit is not positive
+func listDirectory(db *gorm.DB, search, tid string, since time.Time, limit int) ([]*DirectoryEntry, error) {
+	entries := []*DirectoryEntry{}
+
+	tx := db.Model(&User{}).
+		Joins("LEFT JOIN teams ON teams.id = users.team_id").
+		Select("users.id, users.name, users.team_id, COALESCE(teams.name, '') AS team, users.avatar")
+
+	if search != "" {
+		// Wildcards in the search are matched literally, escaped with a character every dialect accepts
+		escaper := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+		tx = tx.Where("LOWER(users.name) LIKE ? ESCAPE '!'", "%"+escaper.Replace(strings.ToLower(search))+"%")
+	}
+
+	if tid != "" {
+		tx = tx.Where("users.team_id = ?", tid)
+	}
+
+	if limit > 0 {
+		tx = tx.Limit(limit)
+	}
+
+	err := tx.Order("users.name").Scan(&entries).Error
+	if err != nil {
+		return []*DirectoryEntry{}, err
+	}
+
+	if len(entries) == 0 {
+		return entries, nil
+	}
+
+	avatars := CurrentSettings(db)
+	for _, e := range entries {
+		if e.Avatar != "" {
+			e.avatarURL = avatars.AvatarURL(e.Avatar)
+		}
+	}
+
+	var worked []struct {
+		UserID string
+		Name   string
+	}
+
+	tx = db.Model(&Shift{}).
+		Joins("JOIN positions ON positions.id = shifts.position_id").
+		Where("shifts.user_id <> '' AND shifts.status = ?", ShiftPublished).
+		Where(quote(db, "shifts.end")+" > ?", since)
+
+	// A limited directory only needs the positions of the users it lists
+	if limit > 0 {
+		ids := make([]string, len(entries))
+		for i, e := range entries {
+			ids[i] = e.ID
+		}
+
+		tx = tx.Where("shifts.user_id IN ?", ids)
+	}
+
+	err = tx.Distinct("shifts.user_id", "positions.name").Scan(&worked).Error
+	if err != nil {
+		return []*DirectoryEntry{}, err
+	}
+
+	positions := make(map[string][]string)
+	for _, w := range worked {
+		positions[w.UserID] = append(positions[w.UserID], w.Name)
+	}
+
+	for _, e := range entries {
+		e.Positions = positions[e.ID]
+		if e.Positions == nil {
+			e.Positions = []string{}
+		}
+
+		sort.Strings(e.Positions)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// EmailChangeTTL is how long a pending EmailChange may be verified before it expires
+const EmailChangeTTL = time.Hour * 24
+
+var (
+	ErrEmailTaken         = errors.New("email already in use")
+	ErrEmailChangeExpired = errors.New("email change expired")
+)
+
+// EmailChange struct represents a pending change of a User's email address awaiting verification.
+// The address is only changed once the Token emailed to the new address has been verified.
+type EmailChange struct {
+	Token     string    `gorm:"primaryKey" json:"-"`
+	UserID    string    `gorm:"not null;index" json:"user_id"`
+	Email     string    `gorm:"size:254;not null" json:"email"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate checks to ensure all fields of the object are present and valid
+func (e *EmailChange) Validate() error {
+	if e.Email == "" {
+		return invalid("email required")
+	}
+
+	if _, err := mail.ParseAddress(e.Email); err != nil {
+		return invalid("invalid email")
+	}
+
+	return nil
+}
+
+// CheckEmailAvailable returns ErrEmailTaken if a user other than the specified User.ID has the email address,
+// including deactivated users so that they keep their address should they be reactivated
+func CheckEmailAvailable(db *gorm.DB, uid, email string) error {
+	if email == "" {
+		return nil
+	}
+
+	user, err := FindUserByEmail(db.Unscoped(), email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	if user.ID != uid {
+		return ErrEmailTaken
+	}
+
+	return nil
+}
+
+// Create attempts to create the pending EmailChange in the database, generating its verification token
+// and replacing any change the user has pending
+func (e *EmailChange) Create(db *gorm.DB) error {
+	token, err := nanoid.Nanoid(32)
+	if err != nil {
+		return fmt.Errorf("unable to generate email change token: %s", err)
+	}
+
+	e.Token = token
+	e.Email = strings.TrimSpace(e.Email)
+	e.ExpiresAt = time.Now().Add(EmailChangeTTL)
+
+	return transaction(db, func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ?", e.UserID).Delete(&EmailChange{}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(e).Error
+	})
+}
+
+// Expired reports whether the EmailChange can no longer be verified
+func (e *EmailChange) Expired() bool {
+	return time.Now().After(e.ExpiresAt)
+}
+
+// Complete changes the email address of the User to the verified address and removes the pending EmailChange
+func (e *EmailChange) Complete(db *gorm.DB) (*User, error) {
+	if e.Expired() {
+		return nil, ErrEmailChangeExpired
+	}
+
+	user := &User{}
+
+	err := transaction(db, func(tx *gorm.DB) error {
+		// Ensure the address was not taken while the change was pending
+		err := CheckEmailAvailable(tx, e.UserID, e.Email)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&User{}).Where("id = ?", e.UserID).Updates(map[string]interface{}{
+			"email":     e.Email,
+			"email_key": emailKey(e.Email),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.First(user, "id = ?", e.UserID).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Delete(e).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// FindEmailChangeByToken attempts to return a row from the EmailChanges table with the matching Token
+func FindEmailChangeByToken(db *gorm.DB, token string) (*EmailChange, error) {
+	change := &EmailChange{}
+	err := db.First(&change, "token = ?", token).Error
+	if err != nil {
+		return &EmailChange{}, notFound("email change", err)
+	}
+
+	return change, nil
+}
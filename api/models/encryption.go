@@ -0,0 +1,76 @@
+package models
+
+import (
+	"github.com/btnmasher/shiftr/secrets"
+	"gorm.io/gorm"
+)
+
+// encryptedColumns lists the secrets.String columns of each model, which are re-encrypted by RotateEncryption
+var encryptedColumns = []struct {
+	model  interface{}
+	column string
+}{
+	{&User{}, "phone"},
+	{&SigningKey{}, "secret"},
+}
+
+// RotateEncryption re-encrypts every stored value of the encrypted columns which is either plaintext
+// or was sealed with a key other than the primary key of the keyring of the database, returning the number
+// of values rewritten. Retired keys may be removed from the keyring once this completes.
+func RotateEncryption(db *gorm.DB) (int, error) {
+	keyring := secrets.CurrentKeyring(db)
+	if keyring == nil {
+		return 0, secrets.ErrNoKeyring
+	}
+
+	rotated := 0
+
+	for _, col := range encryptedColumns {
+		pending := make(map[string]string)
+
+		rows, err := db.Model(col.model).Select("id", col.column).Where(col.column + " <> ''").Rows()
+		if err != nil {
+			return rotated, err
+		}
+
+		// Collect the stale values before writing, the connection is busy until the rows are closed
+		for rows.Next() {
+			var id, stored string
+
+			err = rows.Scan(&id, &stored)
+			if err != nil {
+				rows.Close()
+				return rotated, err
+			}
+
+			if keyring.NeedsRotation(stored) {
+				pending[id] = stored
+			}
+		}
+
+		err = rows.Close()
+		if err != nil {
+			return rotated, err
+		}
+
+		for id, stored := range pending {
+			plaintext := []byte(stored)
+
+			if secrets.IsEncrypted(stored) {
+				plaintext, err = keyring.Decrypt(stored)
+				if err != nil {
+					return rotated, err
+				}
+			}
+
+			err = db.Model(col.model).Where("id = ?", id).UpdateColumn(col.column, secrets.String(plaintext)).Error
+			if err != nil {
+				return rotated, err
+			}
+
+			rotated++
+		}
+	}
+
+	return rotated, nil
+}
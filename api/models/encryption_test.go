@@ -0,0 +1,121 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"github.com/btnmasher/shiftr/secrets"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+)
+
+// withKeys returns a handle of the database encrypting with the primary of the keys, each derived from its ID,
+// failing the test on error
+func withKeys(t *testing.T, db *gorm.DB, primary string, ids ...string) *gorm.DB {
+	t.Helper()
+
+	keys := make(map[string][]byte)
+	for _, id := range ids {
+		keys[id] = bytes.Repeat([]byte(id), 16)
+	}
+
+	keyring, err := secrets.NewKeyring(primary, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := secrets.Configure(db, keyring)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tx
+}
+
+// storedPhone returns the phone number of the user as stored in the database
+func storedPhone(t *testing.T, db *gorm.DB, uid string) string {
+	t.Helper()
+
+	var phone string
+
+	err := db.Raw("SELECT phone FROM users WHERE id = ?", uid).Row().Scan(&phone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return phone
+}
+
+func TestRotateEncryption(t *testing.T) {
+	db := testDB(t)
+
+	plain, err := secrets.Configure(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Phone numbers stored before encryption was configured, sealed with the retired key and with the primary key
+	handles := map[string]*gorm.DB{
+		"plain":   plain,
+		"retired": withKeys(t, db, "k1", "k1"),
+		"primary": withKeys(t, db, "k2", "k1", "k2"),
+	}
+
+	phones := make(map[string]string)
+
+	for name, tx := range handles {
+		user := &User{Name: name, Password: "password" + name, Role: "user", Phone: secrets.String("555-01" + name[:2])}
+
+		err = user.Create(tx)
+		if err != nil {
+			t.Fatalf("could not create user %s: %s", name, err)
+		}
+
+		phones[user.ID] = string(user.Phone)
+	}
+
+	_, err = RotateEncryption(plain)
+	if !errors.Is(err, secrets.ErrNoKeyring) {
+		t.Errorf("rotating without a keyring: got %v, want %v", err, secrets.ErrNoKeyring)
+	}
+
+	// A keyring missing the retired key fails rather than losing the values sealed with it, keeping those rewritten
+	partial, err := RotateEncryption(withKeys(t, db, "k2", "k2"))
+	if !errors.Is(err, secrets.ErrUnknownKey) {
+		t.Errorf("rotating without the retired key: got %v, want %v", err, secrets.ErrUnknownKey)
+	}
+
+	current := withKeys(t, db, "k2", "k1", "k2")
+
+	rotated, err := RotateEncryption(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if partial+rotated != 2 {
+		t.Errorf("rotated %d values, want 2", partial+rotated)
+	}
+
+	// Every value is sealed with the primary key and still reads as it was written
+	readable := withKeys(t, db, "k2", "k2")
+
+	for uid, phone := range phones {
+		if stored := storedPhone(t, db, uid); !strings.HasPrefix(stored, "enc:v1:k2:") {
+			t.Errorf("user %s: stored the phone number as %q", uid, stored)
+		}
+
+		user, err := FindUserByID(readable, uid)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(user.Phone) != phone {
+			t.Errorf("user %s: got phone number %q, want %q", uid, user.Phone, phone)
+		}
+	}
+
+	rotated, err = RotateEncryption(current)
+	if err != nil || rotated != 0 {
+		t.Errorf("rotating again: rotated %d values, %v, want none", rotated, err)
+	}
+}
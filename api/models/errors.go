@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// The errors returned by models fall into these classes, which callers can test for with errors.Is
+// regardless of the specific error, such as a *NotFoundError naming the kind of object missing
+var (
+	ErrNotFound = errors.New("not found")
+	ErrInvalid  = errors.New("invalid")
+	ErrOverlap  = errors.New("shift overlaps another shift of the same user")
+	ErrStale    = errors.New("changed since it was read")
+)
+
+// NotFoundError is returned when the object of the Kind requested does not exist. It matches ErrNotFound,
+// and gorm.ErrRecordNotFound when it was the database reporting no matching row.
+type NotFoundError struct {
+	Kind string
+	Err  error
+}
+
+func (e *NotFoundError) Error() string {
+	return e.Kind + " not found"
+}
+
+// Is reports whether the target is ErrNotFound, so every NotFoundError matches it
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// notFound returns a *NotFoundError for the kind of object when err reports no matching row, otherwise err
+func notFound(kind string, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &NotFoundError{Kind: kind, Err: err}
+	}
+
+	return err
+}
+
+// ValidationError is returned when an object fails validation, its message describing the invalid field.
+// It matches ErrInvalid.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// Is reports whether the target is ErrInvalid, so every ValidationError matches it
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalid
+}
+
+// invalid returns a *ValidationError with the message
+func invalid(message string) error {
+	return &ValidationError{Message: message}
+}
+
+// invalidf returns a *ValidationError with the message formatted according to the format specifier
+func invalidf(format string, args ...interface{}) error {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
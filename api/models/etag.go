@@ -0,0 +1,26 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// ETag returns the strong entity tag of the version of the object with the ID last updated at the time, quoted
+// as sent in headers. The time must be as read from the database, whose precision may be coarser than Go's.
+func ETag(id string, updated time.Time) string {
+	sum := sha256.Sum256([]byte(id + "@" + strconv.FormatInt(updated.UnixNano(), 10)))
+
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// ETag returns the entity tag of the version of the Shift as read from the database
+func (s *Shift) ETag() string {
+	return ETag(s.ID, s.UpdatedAt)
+}
+
+// ETag returns the entity tag of the version of the User as read from the database
+func (u *User) ETag() string {
+	return ETag(u.ID, u.UpdatedAt)
+}
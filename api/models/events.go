@@ -0,0 +1,150 @@
+package models
+
+import (
+	"context"
+	"github.com/btnmasher/shiftr/events"
+	"gorm.io/gorm"
+	"time"
+)
+
+// The types of the events published as shifts change
+const (
+	EventShiftCreated = "shift.created"
+	EventShiftUpdated = "shift.updated"
+	EventShiftDeleted = "shift.deleted"
+)
+
+// pendingKey is the context key of the events emitted within a transaction, held until it commits
+type pendingKey struct{}
+
+type pendingEvents struct {
+	events []events.Event
+}
+
+// emit publishes the event, once the transaction the db is in commits when it was started with transaction
+func emit(db *gorm.DB, e events.Event) {
+	b := CurrentSettings(db).EventBus
+	if b == nil {
+		return
+	}
+
+	e.At = time.Now()
+
+	if pending, ok := db.Statement.Context.Value(pendingKey{}).(*pendingEvents); ok {
+		pending.events = append(pending.events, e)
+		return
+	}
+
+	b.Publish(e)
+}
+
+// transaction runs fn in a transaction as db.Transaction does, holding the events emitted within it until it
+// commits so that changes which are rolled back are never announced. Events of a nested transaction are handed to
+// the enclosing one once it succeeds.
+func transaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	parent, _ := ctx.Value(pendingKey{}).(*pendingEvents)
+	pending := &pendingEvents{}
+
+	err := db.WithContext(context.WithValue(ctx, pendingKey{}, pending)).Transaction(fn)
+	if err != nil {
+		return err
+	}
+
+	if parent != nil {
+		parent.events = append(parent.events, pending.events...)
+		return nil
+	}
+
+	if b := CurrentSettings(db).EventBus; b != nil {
+		for _, e := range pending.events {
+			b.Publish(e)
+		}
+	}
+
+	return nil
+}
+
+// shiftEvent returns the event of the type for the Shift, concerning its worker and the worker it was reassigned
+// from, if any
+func (s *Shift) shiftEvent(kind string) events.Event {
+	e := events.Event{Type: kind, ID: s.ID}
+
+	if s.UserID != "" {
+		e.Users = append(e.Users, s.UserID)
+	}
+
+	if s.formerUserID != "" && s.formerUserID != s.UserID {
+		e.Users = append(e.Users, s.formerUserID)
+	}
+
+	return e
+}
+
+// updateShifts sets the column of every shift the query matches to the value in a single statement, announcing each
+// shift it updated as the hooks cannot for a statement updating many
+func updateShifts(query *gorm.DB, column string, value interface{}) error {
+	query = query.Session(&gorm.Session{})
+
+	var updated []*Shift
+
+	err := query.Select("id", "user_id").Find(&updated).Error
+	if err != nil {
+		return err
+	}
+
+	if len(updated) == 0 {
+		return nil
+	}
+
+	err = query.Update(column, value).Error
+	if err != nil {
+		return err
+	}
+
+	for _, shift := range updated {
+		emit(query, shift.shiftEvent(EventShiftUpdated))
+	}
+
+	return nil
+}
+
+// affected returns the number of rows affected by the statement a hook was called for, which GORM records on the
+// statement's own DB rather than the session the hook is given
+func affected(db *gorm.DB) int64 {
+	return db.Statement.DB.RowsAffected
+}
+
+// AfterCreate hooks GORM to announce the created shift
+func (s *Shift) AfterCreate(db *gorm.DB) error {
+	emit(db, s.shiftEvent(EventShiftCreated))
+
+	return nil
+}
+
+// AfterUpdate hooks GORM to announce the updated shift. Updates of many shifts in a single statement are
+// announced by their callers, and those which matched no shift are not announced.
+func (s *Shift) AfterUpdate(db *gorm.DB) error {
+	if s.ID == "" || affected(db) < 1 {
+		return nil
+	}
+
+	emit(db, s.shiftEvent(EventShiftUpdated))
+
+	return nil
+}
+
+// AfterDelete hooks GORM to announce the cancelled shift
+func (s *Shift) AfterDelete(db *gorm.DB) error {
+	if s.ID == "" || affected(db) < 1 {
+		return nil
+	}
+
+	emit(db, s.shiftEvent(EventShiftDeleted))
+
+	return nil
+}
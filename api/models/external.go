@@ -0,0 +1,931 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/btnmasher/shiftr/opaque"
+)
+
+// MarshalJSON implements json.Marshaler, exposing the external IDs of the User and their manager
+// along with the URL of their avatar
+func (u User) MarshalJSON() ([]byte, error) {
+	type user User
+	ext := struct {
+		user
+		AvatarURL string `json:"avatar_url,omitempty"`
+	}{user: user(u)}
+
+	var err error
+	ext.ID, err = opaque.Encode(opaque.User, u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ext.ManagerID, err = opaque.Encode(opaque.User, u.ManagerID)
+	if err != nil {
+		return nil, err
+	}
+
+	ext.AvatarURL = u.avatarURL
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external IDs of the User and their manager
+func (u *User) UnmarshalJSON(data []byte) error {
+	type user User
+	ext := (*user)(u)
+
+	err := json.Unmarshal(data, ext)
+	if err != nil {
+		return err
+	}
+
+	u.ID, err = opaque.Decode(opaque.User, u.ID)
+	if err != nil {
+		return err
+	}
+
+	u.ManagerID, err = opaque.Decode(opaque.User, u.ManagerID)
+
+	return err
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external IDs of the Shift and its User
+func (s Shift) MarshalJSON() ([]byte, error) {
+	type shift Shift
+	ext := shift(s)
+
+	var err error
+	ext.ID, ext.UserID, err = s.ExternalIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external IDs of the Shift and its User
+func (s *Shift) UnmarshalJSON(data []byte) error {
+	type shift Shift
+	ext := (*shift)(s)
+
+	err := json.Unmarshal(data, ext)
+	if err != nil {
+		return err
+	}
+
+	s.ID, err = opaque.Decode(opaque.Shift, s.ID)
+	if err != nil {
+		return err
+	}
+
+	s.UserID, err = opaque.Decode(opaque.User, s.UserID)
+
+	return err
+}
+
+// ExternalIDs returns the IDs of the Shift and its User as exposed to API consumers
+func (s *Shift) ExternalIDs() (sid, uid string, err error) {
+	sid, err = opaque.Encode(opaque.Shift, s.ID)
+	if err != nil {
+		return "", "", err
+	}
+
+	uid, err = opaque.Encode(opaque.User, s.UserID)
+	if err != nil {
+		return "", "", err
+	}
+
+	return sid, uid, nil
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external IDs of the manager and delegate
+func (d Delegation) MarshalJSON() ([]byte, error) {
+	type delegation Delegation
+	ext := delegation(d)
+
+	var err error
+	ext.ManagerID, err = opaque.Encode(opaque.User, d.ManagerID)
+	if err != nil {
+		return nil, err
+	}
+
+	ext.DelegateID, err = opaque.Encode(opaque.User, d.DelegateID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external IDs of the manager and delegate
+func (d *Delegation) UnmarshalJSON(data []byte) error {
+	type delegation Delegation
+	ext := (*delegation)(d)
+
+	err := json.Unmarshal(data, ext)
+	if err != nil {
+		return err
+	}
+
+	d.ManagerID, err = opaque.Decode(opaque.User, d.ManagerID)
+	if err != nil {
+		return err
+	}
+
+	d.DelegateID, err = opaque.Decode(opaque.User, d.DelegateID)
+
+	return err
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the User holding the Certification
+func (cert Certification) MarshalJSON() ([]byte, error) {
+	type certification Certification
+	ext := certification(cert)
+
+	var err error
+	ext.UserID, err = opaque.Encode(opaque.User, cert.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the User a shift may be reassigned to
+func (s Suggestion) MarshalJSON() ([]byte, error) {
+	type suggestion Suggestion
+	ext := suggestion(s)
+
+	var err error
+	ext.UserID, err = opaque.Encode(opaque.User, s.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external IDs of the User who made the change and
+// the Users it affected
+func (e AuditEntry) MarshalJSON() ([]byte, error) {
+	type entry AuditEntry
+	ext := entry(e)
+
+	var err error
+	ext.UserID, err = opaque.Encode(opaque.User, e.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	ext.Subjects = make([]string, len(e.Subjects))
+	for i, uid := range e.Subjects {
+		ext.Subjects[i], err = opaque.Encode(opaque.User, uid)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(ext)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external IDs of the Shift and User in violation
+func (v RuleViolation) MarshalJSON() ([]byte, error) {
+	type violation RuleViolation
+	ext := violation(v)
+
+	var err error
+	ext.ShiftID, err = opaque.Encode(opaque.Shift, v.ShiftID)
+	if err != nil {
+		return nil, err
+	}
+
+	ext.UserID, err = opaque.Encode(opaque.User, v.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external IDs of the Shift and User in discrepancy
+func (d Discrepancy) MarshalJSON() ([]byte, error) {
+	type discrepancy Discrepancy
+	ext := discrepancy(d)
+
+	var err error
+	ext.ShiftID, err = opaque.Encode(opaque.Shift, d.ShiftID)
+	if err != nil {
+		return nil, err
+	}
+
+	ext.UserID, err = opaque.Encode(opaque.User, d.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the User belonging to the Group
+func (m GroupMember) MarshalJSON() ([]byte, error) {
+	type member GroupMember
+	ext := member(m)
+
+	var err error
+	ext.UserID, err = opaque.Encode(opaque.User, m.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the coworker along with the URL of
+// their avatar
+func (e DirectoryEntry) MarshalJSON() ([]byte, error) {
+	type entry DirectoryEntry
+	ext := struct {
+		entry
+		AvatarURL string `json:"avatar_url,omitempty"`
+	}{entry: entry(e)}
+
+	var err error
+	ext.ID, err = opaque.Encode(opaque.User, e.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ext.AvatarURL = e.avatarURL
+
+	return json.Marshal(ext)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external IDs of the User and the Users they may
+// list or approve for
+func (r AccessReport) MarshalJSON() ([]byte, error) {
+	type report AccessReport
+	ext := report(r)
+
+	var err error
+	ext.UserID, err = opaque.Encode(opaque.User, r.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	ext.Reports = make([]string, len(r.Reports))
+	for i, uid := range r.Reports {
+		ext.Reports[i], err = opaque.Encode(opaque.User, uid)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ext.Approves = make([]string, len(r.Approves))
+	for i, uid := range r.Approves {
+		ext.Approves[i], err = opaque.Encode(opaque.User, uid)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(ext)
+}
+
+// encodeIDs replaces each internal ID of the kind with its external ID
+func encodeIDs(kind opaque.Kind, ids ...*string) error {
+	for _, id := range ids {
+		ext, err := opaque.Encode(kind, *id)
+		if err != nil {
+			return err
+		}
+
+		*id = ext
+	}
+
+	return nil
+}
+
+// decodeIDs replaces each external ID of the kind with its internal ID
+func decodeIDs(kind opaque.Kind, ids ...*string) error {
+	for _, ext := range ids {
+		id, err := opaque.Decode(kind, *ext)
+		if err != nil {
+			return err
+		}
+
+		*ext = id
+	}
+
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external IDs of the Shift and the User it was awarded to
+func (b Bidding) MarshalJSON() ([]byte, error) {
+	type bidding Bidding
+	ext := bidding(b)
+
+	err := encodeIDs(opaque.Shift, &ext.ShiftID)
+	if err == nil {
+		err = encodeIDs(opaque.User, &ext.AwardedTo)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external IDs of the Shift and the User it was
+// awarded to
+func (b *Bidding) UnmarshalJSON(data []byte) error {
+	type bidding Bidding
+
+	err := json.Unmarshal(data, (*bidding)(b))
+	if err != nil {
+		return err
+	}
+
+	err = decodeIDs(opaque.Shift, &b.ShiftID)
+	if err != nil {
+		return err
+	}
+
+	return decodeIDs(opaque.User, &b.AwardedTo)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external IDs of the Shift and the User bidding on it
+func (b ShiftBid) MarshalJSON() ([]byte, error) {
+	type bid ShiftBid
+	ext := bid(b)
+
+	err := encodeIDs(opaque.Shift, &ext.ShiftID)
+	if err == nil {
+		err = encodeIDs(opaque.User, &ext.UserID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external IDs of the Shift and the User bidding on it
+func (b *ShiftBid) UnmarshalJSON(data []byte) error {
+	type bid ShiftBid
+
+	err := json.Unmarshal(data, (*bid)(b))
+	if err != nil {
+		return err
+	}
+
+	err = decodeIDs(opaque.Shift, &b.ShiftID)
+	if err != nil {
+		return err
+	}
+
+	return decodeIDs(opaque.User, &b.UserID)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external IDs of the User clocking in and their Shift
+func (e ClockEntry) MarshalJSON() ([]byte, error) {
+	type entry ClockEntry
+	ext := entry(e)
+
+	err := encodeIDs(opaque.Shift, &ext.ShiftID)
+	if err == nil {
+		err = encodeIDs(opaque.User, &ext.UserID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external IDs of the User clocking in and their Shift
+func (e *ClockEntry) UnmarshalJSON(data []byte) error {
+	type entry ClockEntry
+
+	err := json.Unmarshal(data, (*entry)(e))
+	if err != nil {
+		return err
+	}
+
+	err = decodeIDs(opaque.Shift, &e.ShiftID)
+	if err != nil {
+		return err
+	}
+
+	return decodeIDs(opaque.User, &e.UserID)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external IDs of the Shift and its worker
+func (a Attendance) MarshalJSON() ([]byte, error) {
+	type attendance Attendance
+	ext := attendance(a)
+
+	err := encodeIDs(opaque.Shift, &ext.ShiftID)
+	if err == nil {
+		err = encodeIDs(opaque.User, &ext.UserID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external IDs of the Shift and its worker
+func (a *Attendance) UnmarshalJSON(data []byte) error {
+	type attendance Attendance
+
+	err := json.Unmarshal(data, (*attendance)(a))
+	if err != nil {
+		return err
+	}
+
+	err = decodeIDs(opaque.Shift, &a.ShiftID)
+	if err != nil {
+		return err
+	}
+
+	return decodeIDs(opaque.User, &a.UserID)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the User attending
+func (t AttendanceTotal) MarshalJSON() ([]byte, error) {
+	type total AttendanceTotal
+	ext := total(t)
+
+	err := encodeIDs(opaque.User, &ext.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the User accruing leave
+func (a LeaveAccount) MarshalJSON() ([]byte, error) {
+	type account LeaveAccount
+	ext := account(a)
+
+	err := encodeIDs(opaque.User, &ext.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external ID of the User accruing leave
+func (a *LeaveAccount) UnmarshalJSON(data []byte) error {
+	type account LeaveAccount
+
+	err := json.Unmarshal(data, (*account)(a))
+	if err != nil {
+		return err
+	}
+
+	return decodeIDs(opaque.User, &a.UserID)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external IDs of the User requesting the time off and the
+// User who decided on it
+func (t TimeOff) MarshalJSON() ([]byte, error) {
+	type timeOff TimeOff
+	ext := timeOff(t)
+
+	err := encodeIDs(opaque.User, &ext.UserID, &ext.DecidedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external IDs of the User requesting the time off and
+// the User who decided on it
+func (t *TimeOff) UnmarshalJSON(data []byte) error {
+	type timeOff TimeOff
+
+	err := json.Unmarshal(data, (*timeOff)(t))
+	if err != nil {
+		return err
+	}
+
+	return decodeIDs(opaque.User, &t.UserID, &t.DecidedBy)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the User the balance belongs to
+func (b LeaveBalance) MarshalJSON() ([]byte, error) {
+	type balance LeaveBalance
+	ext := balance(b)
+
+	err := encodeIDs(opaque.User, &ext.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the User clocking in
+func (t Timesheet) MarshalJSON() ([]byte, error) {
+	type timesheet Timesheet
+	ext := timesheet(t)
+
+	err := encodeIDs(opaque.User, &ext.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the User the row belongs to
+func (r ScheduleRow) MarshalJSON() ([]byte, error) {
+	type row ScheduleRow
+	ext := row(r)
+
+	err := encodeIDs(opaque.User, &ext.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external IDs of the Shift changed and its worker
+func (c ShiftChange) MarshalJSON() ([]byte, error) {
+	type change ShiftChange
+	ext := change(c)
+
+	err := encodeIDs(opaque.Shift, &ext.ShiftID)
+	if err == nil {
+		err = encodeIDs(opaque.User, &ext.UserID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external IDs of the Shift changed and its worker
+func (c *ShiftChange) UnmarshalJSON(data []byte) error {
+	type change ShiftChange
+
+	err := json.Unmarshal(data, (*change)(c))
+	if err != nil {
+		return err
+	}
+
+	err = decodeIDs(opaque.Shift, &c.ShiftID)
+	if err != nil {
+		return err
+	}
+
+	return decodeIDs(opaque.User, &c.UserID)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the User assigned to the slot
+func (m RotationMember) MarshalJSON() ([]byte, error) {
+	type member RotationMember
+	ext := member(m)
+
+	err := encodeIDs(opaque.User, &ext.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external ID of the User assigned to the slot
+func (m *RotationMember) UnmarshalJSON(data []byte) error {
+	type member RotationMember
+
+	err := json.Unmarshal(data, (*member)(m))
+	if err != nil {
+		return err
+	}
+
+	return decodeIDs(opaque.User, &m.UserID)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the User who sent the Broadcast
+func (b Broadcast) MarshalJSON() ([]byte, error) {
+	type broadcast Broadcast
+	ext := broadcast(b)
+
+	err := encodeIDs(opaque.User, &ext.SenderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external ID of the User who sent the Broadcast
+func (b *Broadcast) UnmarshalJSON(data []byte) error {
+	type broadcast Broadcast
+
+	err := json.Unmarshal(data, (*broadcast)(b))
+	if err != nil {
+		return err
+	}
+
+	return decodeIDs(opaque.User, &b.SenderID)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the User the Broadcast was sent to
+func (d BroadcastDelivery) MarshalJSON() ([]byte, error) {
+	type delivery BroadcastDelivery
+	ext := delivery(d)
+
+	err := encodeIDs(opaque.User, &ext.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external ID of the User the Broadcast was sent to
+func (d *BroadcastDelivery) UnmarshalJSON(data []byte) error {
+	type delivery BroadcastDelivery
+
+	err := json.Unmarshal(data, (*delivery)(d))
+	if err != nil {
+		return err
+	}
+
+	return decodeIDs(opaque.User, &d.UserID)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external IDs of the event Shift and the User signed up
+func (s Signup) MarshalJSON() ([]byte, error) {
+	type signup Signup
+	ext := signup(s)
+
+	err := encodeIDs(opaque.Shift, &ext.ShiftID)
+	if err == nil {
+		err = encodeIDs(opaque.User, &ext.UserID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external IDs of the event Shift and the User signed up
+func (s *Signup) UnmarshalJSON(data []byte) error {
+	type signup Signup
+
+	err := json.Unmarshal(data, (*signup)(s))
+	if err != nil {
+		return err
+	}
+
+	err = decodeIDs(opaque.Shift, &s.ShiftID)
+	if err != nil {
+		return err
+	}
+
+	return decodeIDs(opaque.User, &s.UserID)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external IDs of the event Shift and the User waiting
+func (e WaitlistEntry) MarshalJSON() ([]byte, error) {
+	type entry WaitlistEntry
+	ext := entry(e)
+
+	err := encodeIDs(opaque.Shift, &ext.ShiftID)
+	if err == nil {
+		err = encodeIDs(opaque.User, &ext.UserID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external IDs of the event Shift and the User waiting
+func (e *WaitlistEntry) UnmarshalJSON(data []byte) error {
+	type entry WaitlistEntry
+
+	err := json.Unmarshal(data, (*entry)(e))
+	if err != nil {
+		return err
+	}
+
+	err = decodeIDs(opaque.Shift, &e.ShiftID)
+	if err != nil {
+		return err
+	}
+
+	return decodeIDs(opaque.User, &e.UserID)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the User changed
+func (c UserChange) MarshalJSON() ([]byte, error) {
+	type change UserChange
+	ext := change(c)
+
+	err := encodeIDs(opaque.User, &ext.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external ID of the User changed
+func (c *UserChange) UnmarshalJSON(data []byte) error {
+	type change UserChange
+
+	err := json.Unmarshal(data, (*change)(c))
+	if err != nil {
+		return err
+	}
+
+	return decodeIDs(opaque.User, &c.UserID)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the User changed
+func (r UserChangeResult) MarshalJSON() ([]byte, error) {
+	type result UserChangeResult
+	ext := result(r)
+
+	err := encodeIDs(opaque.User, &ext.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the User absent
+func (a Absence) MarshalJSON() ([]byte, error) {
+	type absence Absence
+	ext := absence(a)
+
+	err := encodeIDs(opaque.User, &ext.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external ID of the User absent
+func (a *Absence) UnmarshalJSON(data []byte) error {
+	type absence Absence
+
+	err := json.Unmarshal(data, (*absence)(a))
+	if err != nil {
+		return err
+	}
+
+	return decodeIDs(opaque.User, &a.UserID)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the User owning the feed
+func (f CalendarFeed) MarshalJSON() ([]byte, error) {
+	type feed CalendarFeed
+	ext := feed(f)
+
+	err := encodeIDs(opaque.User, &ext.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the User changing their email address
+func (e EmailChange) MarshalJSON() ([]byte, error) {
+	type change EmailChange
+	ext := change(e)
+
+	err := encodeIDs(opaque.User, &ext.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external ID of the User changing their email address
+func (e *EmailChange) UnmarshalJSON(data []byte) error {
+	type change EmailChange
+
+	err := json.Unmarshal(data, (*change)(e))
+	if err != nil {
+		return err
+	}
+
+	return decodeIDs(opaque.User, &e.UserID)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the User invited
+func (i Invitation) MarshalJSON() ([]byte, error) {
+	type invitation Invitation
+	ext := invitation(i)
+
+	err := encodeIDs(opaque.User, &ext.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external IDs of the outgoing and incoming shifts, of the
+// User who wrote the Handover and of the User who acknowledged it
+func (h Handover) MarshalJSON() ([]byte, error) {
+	type handover Handover
+	ext := handover(h)
+
+	err := encodeIDs(opaque.Shift, &ext.ShiftID, &ext.NextShiftID)
+	if err == nil {
+		err = encodeIDs(opaque.User, &ext.AuthorID, &ext.AcknowledgedBy)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external IDs of the outgoing and incoming shifts,
+// of the User who wrote the Handover and of the User who acknowledged it
+func (h *Handover) UnmarshalJSON(data []byte) error {
+	type handover Handover
+
+	err := json.Unmarshal(data, (*handover)(h))
+	if err != nil {
+		return err
+	}
+
+	err = decodeIDs(opaque.Shift, &h.ShiftID, &h.NextShiftID)
+	if err != nil {
+		return err
+	}
+
+	return decodeIDs(opaque.User, &h.AuthorID, &h.AcknowledgedBy)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external ID of the User the preferences belong to
+func (p Preference) MarshalJSON() ([]byte, error) {
+	type preference Preference
+	ext := preference(p)
+
+	err := encodeIDs(opaque.User, &ext.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, resolving the external ID of the User the preferences belong to
+func (p *Preference) UnmarshalJSON(data []byte) error {
+	type preference Preference
+
+	err := json.Unmarshal(data, (*preference)(p))
+	if err != nil {
+		return err
+	}
+
+	return decodeIDs(opaque.User, &p.UserID)
+}
+
+// MarshalJSON implements json.Marshaler, exposing the external IDs of the Shift and the User reminded
+func (r Reminder) MarshalJSON() ([]byte, error) {
+	type reminder Reminder
+	ext := reminder(r)
+
+	err := encodeIDs(opaque.Shift, &ext.ShiftID)
+	if err == nil {
+		err = encodeIDs(opaque.User, &ext.UserID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ext)
+}
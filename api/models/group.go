@@ -0,0 +1,178 @@
+package models
+
+import (
+	"fmt"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"html"
+	"strings"
+	"time"
+)
+
+// Group struct represents a named set of users, kept apart from teams, who can be targeted together such as
+// by bulk shift creation, broadcasts, and the bidding on open shifts. A user may belong to any number of groups.
+type Group struct {
+	ID          string        `gorm:"primaryKey" json:"id"`
+	Name        string        `gorm:"size:50;not null;unique" json:"name"`
+	Description string        `gorm:"size:255" json:"description,omitempty"`
+	Members     []GroupMember `gorm:"foreignKey:GroupID" json:"members"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// GroupMember struct represents a User belonging to a Group
+type GroupMember struct {
+	GroupID string `gorm:"primaryKey" json:"-"`
+	UserID  string `gorm:"primaryKey;index" json:"user_id"`
+}
+
+// Validate checks to ensure all fields of the object are present and valid
+func (g *Group) Validate() error {
+	if strings.TrimSpace(g.Name) == "" {
+		return invalid("name required")
+	}
+
+	return nil
+}
+
+// BeforeCreate hooks GORM and prepares a new object for creation
+func (g *Group) BeforeCreate(_ *gorm.DB) error {
+	id, err := nanoid.Nanoid(8)
+	if err != nil {
+		return fmt.Errorf("unable to generate GroupID: %s", err)
+	}
+
+	g.ID = id
+	g.Name = html.EscapeString(strings.TrimSpace(g.Name))
+
+	return nil
+}
+
+// Create attempts to create the Group object in the database, without its members
+func (g *Group) Create(db *gorm.DB) error {
+	return db.Omit("Members").Create(g).Error
+}
+
+// Update will attempt to update the name and description of the current Group object in the database
+func (g *Group) Update(db *gorm.DB) error {
+	tx := db.Model(g).Omit("Members").Where("id = ?", g.ID).Updates(
+		map[string]interface{}{
+			"name":        html.EscapeString(strings.TrimSpace(g.Name)),
+			"description": g.Description,
+		},
+	).Take(g)
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected < 1 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return db.Where("group_id = ?", g.ID).Find(&g.Members).Error
+}
+
+// Delete will attempt to delete the Group object from the database
+func (g *Group) Delete(db *gorm.DB) error {
+	tx := db.Delete(g)
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected == 0 {
+		return &NotFoundError{Kind: "group"}
+	}
+
+	return nil
+}
+
+// AfterDelete hooks GORM to remove the members of this group when it is deleted, and to open the bidding
+// restricted to the group to every user
+func (g *Group) AfterDelete(db *gorm.DB) error {
+	err := db.Model(&Bidding{}).Where("group_id = ?", g.ID).Update("group_id", "").Error
+	if err != nil {
+		return err
+	}
+
+	return db.Where("group_id = ?", g.ID).Delete(&GroupMember{}).Error
+}
+
+// SetMembers attempts to replace the members of the Group with the specified User.IDs
+func (g *Group) SetMembers(db *gorm.DB, uids []string) error {
+	members := make([]GroupMember, 0, len(uids))
+	seen := make(map[string]bool, len(uids))
+
+	for _, uid := range uids {
+		if !seen[uid] {
+			seen[uid] = true
+			members = append(members, GroupMember{GroupID: g.ID, UserID: uid})
+		}
+	}
+
+	return transaction(db, func(tx *gorm.DB) error {
+		err := tx.Where("group_id = ?", g.ID).Delete(&GroupMember{}).Error
+		if err != nil {
+			return err
+		}
+
+		if len(members) > 0 {
+			err = tx.Create(&members).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		g.Members = members
+
+		return nil
+	})
+}
+
+// MemberIDs returns the User.IDs of the members of the Group
+func (g *Group) MemberIDs() []string {
+	uids := make([]string, len(g.Members))
+	for i, m := range g.Members {
+		uids[i] = m.UserID
+	}
+
+	return uids
+}
+
+// ListGroups attempts to return all rows from the Groups table along with their members, ordered by name
+func ListGroups(db *gorm.DB) ([]*Group, error) {
+	var groups []*Group
+
+	err := db.Model(&Group{}).Preload("Members").Order("name").Find(&groups).Error
+	if err != nil {
+		return []*Group{}, err
+	}
+
+	return groups, nil
+}
+
+// FindGroupByID attempts to return a row from the Groups table with the matching Group.ID along with its members
+func FindGroupByID(db *gorm.DB, gid string) (*Group, error) {
+	group := &Group{}
+	err := db.Preload("Members").First(&group, "id = ?", gid).Error
+	if err != nil {
+		return &Group{}, notFound("group", err)
+	}
+
+	return group, nil
+}
+
+// InGroup reports whether the specified User.ID is a member of the specified Group.ID
+func InGroup(db *gorm.DB, gid, uid string) (bool, error) {
+	var count int64
+
+	err := db.Model(&GroupMember{}).Where("group_id = ? AND user_id = ?", gid, uid).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// MaxHandoverGap is how long after a shift ends the next shift may start and still receive its handover
+const MaxHandoverGap = time.Hour * 24
+
+var (
+	ErrNoHandoverPlace        = errors.New("shift has no location or position to hand over at")
+	ErrNoNextShift            = errors.New("no following shift at the same location and position")
+	ErrHandoverAcknowledged   = errors.New("handover has already been acknowledged")
+	ErrHandoverNotFound       = &NotFoundError{Kind: "handover"}
+	ErrNotIncomingShiftWorker = errors.New("only the incoming worker can acknowledge the handover")
+)
+
+// Handover struct represents the note the worker of a Shift leaves for the worker of the next shift
+// at the same location and position, which the incoming worker acknowledges once read
+type Handover struct {
+	ShiftID        string     `gorm:"primaryKey" json:"shift_id"`          //outgoing shift
+	NextShiftID    string     `gorm:"not null;index" json:"next_shift_id"` //incoming shift
+	AuthorID       string     `gorm:"not null" json:"author_id"`
+	Summary        string     `gorm:"size:2000;not null" json:"summary"`
+	OpenIssues     string     `gorm:"size:2000" json:"open_issues,omitempty"`
+	FollowUps      string     `gorm:"size:2000" json:"follow_ups,omitempty"`
+	AcknowledgedBy string     `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Validate checks to ensure all fields of the object are present and valid
+func (h *Handover) Validate() error {
+	if h.Summary == "" {
+		return invalid("summary required")
+	}
+
+	return nil
+}
+
+// NextShift attempts to return the earliest shift at the same location and position which starts after
+// this shift does and no later than MaxHandoverGap after it ends, worked by someone else
+func (s *Shift) NextShift(db *gorm.DB) (*Shift, error) {
+	if s.LocationID == "" && s.PositionID == "" {
+		return nil, ErrNoHandoverPlace
+	}
+
+	shifts, err := ListShifts(db,
+		FilterLocationID(s.LocationID),
+		FilterPositionID(s.PositionID),
+		FilterStatus(ShiftPublished),
+		FilterStartsBefore(s.End.Add(MaxHandoverGap)),
+		func(db *gorm.DB) {
+			db.Where("start > ? AND id <> ? AND user_id <> ? AND user_id <> ''", s.Start, s.ID, s.UserID)
+		},
+		WithLimit(1),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(shifts) == 0 {
+		return nil, ErrNoNextShift
+	}
+
+	return shifts[0], nil
+}
+
+// WriteHandover attempts to save the handover note for the shift, linking it to the next shift.
+// Notes may be revised until the incoming worker acknowledges them.
+func (s *Shift) WriteHandover(db *gorm.DB, h *Handover) (*Shift, error) {
+	next, err := s.NextShift(db)
+	if err != nil {
+		return nil, err
+	}
+
+	h.ShiftID = s.ID
+	h.NextShiftID = next.ID
+
+	err = transaction(db, func(tx *gorm.DB) error {
+		existing, err := FindHandover(tx, s.ID)
+		if err == nil && existing.AcknowledgedAt != nil {
+			return ErrHandoverAcknowledged
+		}
+
+		if err != nil && !errors.Is(err, ErrHandoverNotFound) {
+			return err
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "shift_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"next_shift_id", "author_id", "summary", "open_issues",
+				"follow_ups", "updated_at"}),
+		}).Create(h).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// Acknowledge attempts to record that the worker of the incoming shift has read the handover
+func (h *Handover) Acknowledge(db *gorm.DB, incoming *Shift, uid string) error {
+	if incoming.UserID != uid {
+		return ErrNotIncomingShiftWorker
+	}
+
+	if h.AcknowledgedAt != nil {
+		return ErrHandoverAcknowledged
+	}
+
+	now := time.Now()
+
+	tx := db.Model(h).Where("acknowledged_at IS NULL").Updates(map[string]interface{}{
+		"acknowledged_by": uid,
+		"acknowledged_at": now,
+	})
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected == 0 {
+		return ErrHandoverAcknowledged
+	}
+
+	h.AcknowledgedBy = uid
+	h.AcknowledgedAt = &now
+
+	return nil
+}
+
+// FindHandover attempts to return the Handover written for the outgoing Shift.ID
+func FindHandover(db *gorm.DB, sid string) (*Handover, error) {
+	handover := &Handover{}
+	err := db.First(&handover, "shift_id = ?", sid).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &Handover{}, ErrHandoverNotFound
+		}
+
+		return &Handover{}, err
+	}
+
+	return handover, nil
+}
+
+// FindIncomingHandover attempts to return the most recent Handover written for the incoming Shift.ID
+func FindIncomingHandover(db *gorm.DB, sid string) (*Handover, error) {
+	handover := &Handover{}
+	err := db.Order("updated_at DESC").First(&handover, "next_shift_id = ?", sid).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &Handover{}, ErrHandoverNotFound
+		}
+
+		return &Handover{}, err
+	}
+
+	return handover, nil
+}
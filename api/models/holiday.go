@@ -0,0 +1,216 @@
+package models
+
+import (
+	"fmt"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"html"
+	"strings"
+	"time"
+)
+
+const holidayDateFormat = "2006-01-02"
+
+// Holiday struct represents a public holiday observed at a Location, or everywhere when no location is set.
+// The holiday runs from midnight to midnight of its Date in its Timezone, and hours worked on shifts
+// starting during it are paid at the Multiplier in hour summaries.
+type Holiday struct {
+	ID         string    `gorm:"primaryKey" json:"id"`
+	Name       string    `gorm:"size:50;not null" json:"name"`
+	Date       string    `gorm:"size:10;not null;index" json:"date"` //YYYY-MM-DD
+	Timezone   string    `gorm:"size:64;not null" json:"timezone"`   //IANA zone the date is observed in
+	LocationID string    `gorm:"index" json:"location_id,omitempty"` //empty applies to every location
+	Multiplier float64   `gorm:"not null;default:1" json:"multiplier"`
+	Start      time.Time `gorm:"not null;index" json:"start"` //derived from Date and Timezone
+	End        time.Time `gorm:"not null" json:"end"`         //derived from Date and Timezone
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Validate checks to ensure all fields of the object are present and valid
+func (h *Holiday) Validate() error {
+	if h.Name == "" {
+		return invalid("name required")
+	}
+
+	if _, err := time.Parse(holidayDateFormat, h.Date); err != nil {
+		return invalid("date must be formatted YYYY-MM-DD")
+	}
+
+	if _, err := time.LoadLocation(h.Timezone); err != nil {
+		return invalid("invalid timezone")
+	}
+
+	if h.Multiplier < 0 {
+		return invalid("multiplier cannot be negative")
+	}
+
+	return nil
+}
+
+// BeforeCreate hooks GORM and prepares a new object for creation
+func (h *Holiday) BeforeCreate(_ *gorm.DB) error {
+	id, err := nanoid.Nanoid(8)
+	if err != nil {
+		return fmt.Errorf("unable to generate HolidayID: %s", err)
+	}
+
+	h.ID = id
+
+	return nil
+}
+
+// BeforeSave hooks GORM to derive the span of the holiday from its date and timezone
+func (h *Holiday) BeforeSave(_ *gorm.DB) error {
+	h.Name = html.EscapeString(strings.TrimSpace(h.Name))
+
+	if h.Multiplier == 0 {
+		h.Multiplier = 1
+	}
+
+	loc, err := time.LoadLocation(h.Timezone)
+	if err != nil {
+		return err
+	}
+
+	date, err := time.ParseInLocation(holidayDateFormat, h.Date, loc)
+	if err != nil {
+		return err
+	}
+
+	h.Start = date
+	h.End = date.AddDate(0, 0, 1)
+
+	return nil
+}
+
+// Create attempts to create the Holiday object in the database
+func (h *Holiday) Create(db *gorm.DB) error {
+	return db.Create(h).Error
+}
+
+// Update will attempt to update the current Holiday object in the database
+func (h *Holiday) Update(db *gorm.DB) error {
+	tx := db.Model(h).Where("id = ?", h.ID).Select("name", "date", "timezone", "location_id", "multiplier",
+		"start", "end").Updates(h).Take(h)
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected < 1 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Delete will attempt to delete the Holiday object from the database
+func (h *Holiday) Delete(db *gorm.DB) error {
+	tx := db.Delete(h)
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected == 0 {
+		return &NotFoundError{Kind: "holiday"}
+	}
+
+	return nil
+}
+
+// ListHolidays attempts to return the rows from the Holidays table ordered by date, restricted to those
+// overlapping the window when start or end are not zero, and to those observed at the location when set
+func ListHolidays(db *gorm.DB, start, end time.Time, lid string) ([]*Holiday, error) {
+	var holidays []*Holiday
+
+	tx := db.Model(&Holiday{}).Order("start")
+
+	if !start.IsZero() {
+		tx.Where("end > ?", start)
+	}
+
+	if !end.IsZero() {
+		tx.Where("start < ?", end)
+	}
+
+	if lid != "" {
+		tx.Where("(location_id = '' OR location_id = ?)", lid)
+	}
+
+	err := tx.Find(&holidays).Error
+	if err != nil {
+		return []*Holiday{}, err
+	}
+
+	return holidays, nil
+}
+
+// FindHolidayByID attempts to return a row from the Holidays table with the matching Holiday.ID
+func FindHolidayByID(db *gorm.DB, hid string) (*Holiday, error) {
+	holiday := &Holiday{}
+	err := db.First(&holiday, "id = ?", hid).Error
+	if err != nil {
+		return &Holiday{}, notFound("holiday", err)
+	}
+
+	return holiday, nil
+}
+
+// Observes reports whether the holiday applies to the shift, which it does when the shift
+// starts during the holiday at a location the holiday is observed at
+func (h *Holiday) Observes(s *Shift) bool {
+	if h.LocationID != "" && h.LocationID != s.LocationID {
+		return false
+	}
+
+	return !s.Start.Before(h.Start) && s.Start.Before(h.End)
+}
+
+// FlagHolidays populates the Holiday of each of the specified shifts starting on a holiday
+func FlagHolidays(db *gorm.DB, shifts []*Shift) error {
+	if len(shifts) == 0 {
+		return nil
+	}
+
+	start, end := shifts[0].Start, shifts[0].Start
+	for _, shift := range shifts {
+		if shift.Start.Before(start) {
+			start = shift.Start
+		}
+		if shift.Start.After(end) {
+			end = shift.Start
+		}
+	}
+
+	holidays, err := ListHolidays(db, start, end.Add(time.Nanosecond), "")
+	if err != nil {
+		return err
+	}
+
+	for _, shift := range shifts {
+		shift.Holiday = ""
+
+		for _, holiday := range holidays {
+			if holiday.Observes(shift) {
+				shift.Holiday = holiday.Name
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// holidayMultiplier returns a SQL expression giving the pay multiplier of the holiday the shift
+// starts on, or 1 when it is not a holiday. The highest multiplier wins when holidays coincide.
+func holidayMultiplier(db *gorm.DB) string {
+	start := quote(db, "shifts.start")
+
+	return fmt.Sprintf("COALESCE((SELECT MAX(holidays.multiplier) FROM holidays WHERE %s <= %s AND %s > %s "+
+		"AND (holidays.location_id = '' OR holidays.location_id = shifts.location_id)), 1)",
+		quote(db, "holidays.start"), start, quote(db, "holidays.end"), start)
+}
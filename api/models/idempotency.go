@@ -0,0 +1,117 @@
+package models
+
+import (
+	"encoding/json"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"time"
+)
+
+// MaxIdempotencyKey is the longest Idempotency-Key a client may send
+const MaxIdempotencyKey = 255
+
+// IdempotencyTimeout is how long a request may be handled before it is taken to be abandoned, such as by a server
+// which stopped, and its key is free to be used again
+const IdempotencyTimeout = 5 * time.Minute
+
+// IdempotentRequest struct represents a request made by the user with an Idempotency-Key, identified by the
+// Fingerprint of its method, path and body, along with the response to replay to retries of it until it expires.
+// A request without a Status is still being handled.
+type IdempotentRequest struct {
+	UserID      string    `gorm:"primaryKey;size:64"`
+	Key         string    `gorm:"column:idempotency_key;primaryKey;size:255"`
+	Fingerprint string    `gorm:"size:64;not null"`
+	Status      int       `gorm:"not null;default:0"`
+	Header      string    // JSON encoded headers of the response
+	Body        []byte    // body of the response
+	CreatedAt   time.Time `gorm:"not null"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
+
+// BeginIdempotentRequest records the start of the user's request with the key, kept for the window, returning it
+// with created set. When the key is already held by an unexpired request, that request is returned instead, so
+// the caller can replay its response or refuse the retry.
+func BeginIdempotentRequest(db *gorm.DB, uid, key, fingerprint string, window time.Duration) (req *IdempotentRequest, created bool, err error) {
+	now := time.Now()
+
+	// Keys are reused once their request has expired or was abandoned
+	err = db.Where("user_id = ? AND idempotency_key = ?", uid, key).
+		Where("expires_at <= ? OR (status = 0 AND created_at <= ?)", now, now.Add(-IdempotencyTimeout)).
+		Delete(&IdempotentRequest{}).Error
+	if err != nil {
+		return nil, false, err
+	}
+
+	req = &IdempotentRequest{
+		UserID:      uid,
+		Key:         key,
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(window),
+	}
+
+	// Of concurrent requests with the same key, only the first is recorded
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(req)
+	if res.Error != nil {
+		return nil, false, res.Error
+	}
+
+	if res.RowsAffected > 0 {
+		return req, true, nil
+	}
+
+	existing := &IdempotentRequest{}
+
+	err = db.First(existing, "user_id = ? AND idempotency_key = ?", uid, key).Error
+	if err != nil {
+		return nil, false, notFound("idempotent request", err)
+	}
+
+	return existing, false, nil
+}
+
+// Complete stores the response to the request, to be replayed to its retries
+func (r *IdempotentRequest) Complete(db *gorm.DB, status int, header http.Header, body []byte) error {
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	r.Status = status
+	r.Header = string(encoded)
+	r.Body = body
+
+	return db.Model(r).
+		Where("user_id = ? AND idempotency_key = ?", r.UserID, r.Key).
+		Updates(map[string]interface{}{"status": status, "header": r.Header, "body": body}).Error
+}
+
+// Release forgets the request without a response, so a retry of it is handled anew
+func (r *IdempotentRequest) Release(db *gorm.DB) error {
+	return db.Where("user_id = ? AND idempotency_key = ?", r.UserID, r.Key).Delete(&IdempotentRequest{}).Error
+}
+
+// Completed reports whether the response to the request has been stored
+func (r *IdempotentRequest) Completed() bool {
+	return r.Status != 0
+}
+
+// ResponseHeader returns the headers stored with the response to the request
+func (r *IdempotentRequest) ResponseHeader() (http.Header, error) {
+	header := http.Header{}
+	if r.Header == "" {
+		return header, nil
+	}
+
+	err := json.Unmarshal([]byte(r.Header), &header)
+
+	return header, err
+}
+
+// PurgeIdempotentRequests deletes the requests expired by the time, returning how many were deleted
+func PurgeIdempotentRequests(db *gorm.DB, now time.Time) (int64, error) {
+	res := db.Where("expires_at <= ?", now).Delete(&IdempotentRequest{})
+
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,204 @@
+package models
+
+import (
+	"errors"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"html"
+	"strings"
+)
+
+const (
+	ImportCreated    = "created"
+	ImportValid      = "valid" //would be created, in a dry run
+	ImportFailed     = "failed"
+	ImportRolledBack = "rolled_back"
+)
+
+// ErrImportFailed is returned when a user import was rolled back because a row could not be imported
+var ErrImportFailed = errors.New("user import rolled back, a row could not be imported")
+
+// errDryRun rolls back the transaction of a dry run once every row has been checked
+var errDryRun = errors.New("dry run")
+
+// UserImport struct represents a row of a user import. The Team is given by its ID or name.
+type UserImport struct {
+	Row   int
+	Name  string
+	Email string
+	Role  string
+	Team  string
+}
+
+// UserImportResult struct represents the outcome of importing a row, along with the Invitation sent to the
+// created User when invitations were requested and they have an email address
+type UserImportResult struct {
+	Row        int         `json:"row"`
+	Name       string      `json:"name"`
+	Status     string      `json:"status"`
+	Error      string      `json:"error,omitempty"`
+	User       *User       `json:"user,omitempty"`
+	Invitation *Invitation `json:"-"`
+}
+
+// ImportUsers attempts to create a User for every row in a single transaction, returning a result for each
+// row in the order given. Imported users are given an unguessable password, and an Invitation to choose their
+// own when invite is set. Either every row is imported, or none are and ErrImportFailed is returned with the
+// failed rows marked in the results. A dry run checks every row as an import would without writing any.
+func ImportUsers(db *gorm.DB, rows []*UserImport, dryRun, invite bool) ([]*UserImportResult, error) {
+	results := make([]*UserImportResult, len(rows))
+	failed := false
+
+	err := transaction(db, func(tx *gorm.DB) error {
+		teams := make(map[string]string)
+
+		for i, row := range rows {
+			results[i] = &UserImportResult{Row: row.Row, Name: row.Name}
+
+			user, err := row.create(tx, teams)
+			if err != nil {
+				// Only failures of the database itself abort the remaining rows
+				var invalid changeError
+				if !errors.As(err, &invalid) {
+					return err
+				}
+
+				results[i].Status = ImportFailed
+				results[i].Error = err.Error()
+				failed = true
+
+				continue
+			}
+
+			if invite && user.Email != "" {
+				invitation := &Invitation{UserID: user.ID}
+				err = invitation.Create(tx)
+				if err != nil {
+					return err
+				}
+
+				results[i].Invitation = invitation
+			}
+
+			user.Password = ""
+			results[i].Status = ImportCreated
+			results[i].User = user
+		}
+
+		if failed {
+			return ErrImportFailed
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		for _, result := range results {
+			result.Status = ImportValid
+			result.User = nil
+			result.Invitation = nil
+		}
+
+		return results, nil
+	}
+
+	if err != nil {
+		if failed {
+			for _, result := range results {
+				if result.Status == ImportCreated {
+					result.Status = ImportRolledBack
+					result.User = nil
+					result.Invitation = nil
+				}
+			}
+		}
+
+		return results, err
+	}
+
+	return results, nil
+}
+
+// create validates and writes the User of the row, caching the IDs of the teams resolved
+func (r *UserImport) create(db *gorm.DB, teams map[string]string) (*User, error) {
+	password, err := nanoid.Nanoid(32)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &User{
+		Name:     strings.TrimSpace(r.Name),
+		Email:    strings.TrimSpace(r.Email),
+		Role:     strings.TrimSpace(r.Role),
+		Password: password,
+	}
+
+	if user.Role == "" {
+		user.Role = "user"
+	}
+
+	err = user.Validate()
+	if err != nil {
+		return nil, changeError(err.Error())
+	}
+
+	_, err = FindUserByName(db.Unscoped(), html.EscapeString(user.Name))
+	if err == nil {
+		return nil, changeError("user already exists")
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	err = CheckEmailAvailable(db, "", user.Email)
+	if err != nil {
+		if errors.Is(err, ErrEmailTaken) {
+			return nil, changeError(err.Error())
+		}
+
+		return nil, err
+	}
+
+	if team := strings.TrimSpace(r.Team); team != "" {
+		tid, ok := teams[team]
+		if !ok {
+			tid, err = resolveTeam(db, team)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, changeError("team not found")
+				}
+
+				return nil, err
+			}
+
+			teams[team] = tid
+		}
+
+		user.TeamID = tid
+	}
+
+	err = user.Create(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// resolveTeam returns the ID of the team with the ID or name
+func resolveTeam(db *gorm.DB, team string) (string, error) {
+	t, err := FindTeamByID(db, team)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		t, err = FindTeamByName(db, team)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return t.ID, nil
+}
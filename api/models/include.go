@@ -0,0 +1,83 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// IncludeShiftUsers sets the User of each of the shifts, fetching the users of every shift in a single query.
+// The passwords of the users are cleared, and shifts no active user works are left without one.
+func IncludeShiftUsers(db *gorm.DB, shifts []*Shift) error {
+	seen := map[string]bool{}
+	uids := []string{}
+	for _, shift := range shifts {
+		if shift.UserID != "" && !seen[shift.UserID] {
+			seen[shift.UserID] = true
+			uids = append(uids, shift.UserID)
+		}
+	}
+
+	if len(uids) == 0 {
+		return nil
+	}
+
+	var users []*User
+
+	err := db.Model(&User{}).Where("id IN ?", uids).Find(&users).Error
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[string]*User, len(users))
+	for _, user := range users {
+		user.Password = ""
+		byID[user.ID] = user
+	}
+
+	for _, shift := range shifts {
+		shift.User = byID[shift.UserID]
+	}
+
+	return nil
+}
+
+// IncludeUserShifts sets the Shifts of each of the users to those they work which have not yet ended, of the
+// statuses unless none are given, in the order they start. The shifts of every user are fetched in a single query.
+func IncludeUserShifts(db *gorm.DB, users []*User, statuses ...string) error {
+	uids := make([]string, 0, len(users))
+	for _, user := range users {
+		user.Shifts = []*Shift{}
+		uids = append(uids, user.ID)
+	}
+
+	if len(uids) == 0 {
+		return nil
+	}
+
+	shifts, err := ListShifts(db,
+		FilterUserIDs(uids),
+		FilterEndsAfter(time.Now()),
+		FilterStatus(statuses...),
+	)
+	if err != nil {
+		return err
+	}
+
+	err = AnnotateShifts(db, shifts)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[string]*User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+
+	for _, shift := range shifts {
+		if user := byID[shift.UserID]; user != nil {
+			user.Shifts = append(user.Shifts, shift)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/utils"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// InvitationTTL is how long an Invitation may be accepted before it expires
+const InvitationTTL = time.Hour * 24 * 7
+
+// ErrInvitationExpired is returned when accepting an Invitation after its Token has expired
+var ErrInvitationExpired = errors.New("invitation expired")
+
+// Invitation struct represents an invitation emailed to a User created on their behalf, such as by an
+// import, to choose their own password. Accepting it sets the password and removes the Invitation.
+type Invitation struct {
+	Token     string    `gorm:"primaryKey" json:"-"`
+	UserID    string    `gorm:"not null;index" json:"user_id"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Create attempts to create the Invitation in the database, generating its token
+func (i *Invitation) Create(db *gorm.DB) error {
+	token, err := nanoid.Nanoid(32)
+	if err != nil {
+		return fmt.Errorf("unable to generate invitation token: %s", err)
+	}
+
+	i.Token = token
+	i.ExpiresAt = time.Now().Add(InvitationTTL)
+
+	return db.Create(i).Error
+}
+
+// Expired reports whether the Invitation can no longer be accepted
+func (i *Invitation) Expired() bool {
+	return time.Now().After(i.ExpiresAt)
+}
+
+// Accept sets the password of the invited User and removes their invitations
+func (i *Invitation) Accept(db *gorm.DB, password string) (*User, error) {
+	if i.Expired() {
+		return nil, ErrInvitationExpired
+	}
+
+	if password == "" {
+		return nil, invalid("password required")
+	}
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := FindUserByID(db, i.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = transaction(db, func(tx *gorm.DB) error {
+		err := tx.Model(user).Update("password", string(hashedPassword)).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Where("user_id = ?", i.UserID).Delete(&Invitation{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// FindInvitationByToken attempts to return a row from the Invitations table with the matching Token
+func FindInvitationByToken(db *gorm.DB, token string) (*Invitation, error) {
+	invitation := &Invitation{}
+	err := db.First(&invitation, "token = ?", token).Error
+	if err != nil {
+		return &Invitation{}, notFound("invitation", err)
+	}
+
+	return invitation, nil
+}
@@ -0,0 +1,410 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"html"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	TimeOffPending  = "pending"
+	TimeOffApproved = "approved"
+	TimeOffRejected = "rejected"
+)
+
+var (
+	ErrNoLeaveAccount      = errors.New("user has no leave policy")
+	ErrInsufficientBalance = errors.New("insufficient leave balance")
+	ErrTimeOffDecided      = errors.New("time off request has already been decided")
+)
+
+// LeavePolicy struct represents how leave is earned: AccrualHours are credited on the first of every month
+// after a user's account starts, and at the end of each year at most Carryover hours of the remaining
+// balance are carried into the next, the rest being forfeited
+type LeavePolicy struct {
+	ID           string    `gorm:"primaryKey" json:"id"`
+	Name         string    `gorm:"size:50;not null;unique" json:"name"`
+	AccrualHours float64   `gorm:"not null" json:"accrual_hours"` //credited monthly
+	Carryover    float64   `gorm:"not null" json:"carryover"`     //most hours carried into a new year
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// LeaveAccount struct represents the LeavePolicy a User accrues leave under since Start
+type LeaveAccount struct {
+	UserID    string    `gorm:"primaryKey" json:"user_id"`
+	PolicyID  string    `gorm:"not null;index" json:"policy_id"`
+	Start     time.Time `gorm:"not null" json:"start"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TimeOff struct represents a request by a User for Hours of leave between Start and End, which is
+// deducted from their balance once approved by their manager or an admin
+type TimeOff struct {
+	ID        string     `gorm:"primaryKey" json:"id"`
+	UserID    string     `gorm:"not null;index" json:"user_id"`
+	Start     time.Time  `gorm:"not null" json:"start"`
+	End       time.Time  `gorm:"not null" json:"end"`
+	Hours     float64    `gorm:"not null" json:"hours"`
+	Reason    string     `gorm:"size:255" json:"reason,omitempty"`
+	Status    string     `gorm:"size:10;not null;index" json:"status"`
+	DecidedBy string     `json:"decided_by,omitempty"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+	Note      string     `gorm:"size:255" json:"note,omitempty"` //given by the approver
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// LeaveBalance struct represents the leave of a User in the current year
+type LeaveBalance struct {
+	UserID      string  `json:"user_id"`
+	PolicyID    string  `json:"policy_id"`
+	Year        int     `json:"year"`
+	CarriedOver float64 `json:"carried_over"` //from the previous year
+	Accrued     float64 `json:"accrued"`      //so far this year
+	Used        float64 `json:"used"`         //approved time off starting this year
+	Pending     float64 `json:"pending"`      //requested time off starting this year awaiting approval
+	Available   float64 `json:"available"`    //carried over and accrued, less used
+}
+
+// Validate checks to ensure all fields of the object are present and valid
+func (p *LeavePolicy) Validate() error {
+	if p.Name == "" {
+		return invalid("name required")
+	}
+
+	if p.AccrualHours < 0 {
+		return invalid("accrual hours cannot be negative")
+	}
+
+	if p.Carryover < 0 {
+		return invalid("carryover cannot be negative")
+	}
+
+	return nil
+}
+
+// BeforeCreate hooks GORM and prepares a new object for creation
+func (p *LeavePolicy) BeforeCreate(_ *gorm.DB) error {
+	id, err := nanoid.Nanoid(8)
+	if err != nil {
+		return fmt.Errorf("unable to generate LeavePolicyID: %s", err)
+	}
+
+	p.ID = id
+	p.Name = html.EscapeString(strings.TrimSpace(p.Name))
+
+	return nil
+}
+
+// Create attempts to create the LeavePolicy object in the database
+func (p *LeavePolicy) Create(db *gorm.DB) error {
+	return db.Create(p).Error
+}
+
+// Delete will attempt to delete the LeavePolicy object from the database
+func (p *LeavePolicy) Delete(db *gorm.DB) error {
+	tx := db.Delete(p)
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected == 0 {
+		return &NotFoundError{Kind: "leave policy"}
+	}
+
+	return nil
+}
+
+// AfterDelete hooks GORM to remove the accounts accruing under this policy when it is deleted
+func (p *LeavePolicy) AfterDelete(db *gorm.DB) error {
+	return db.Where("policy_id = ?", p.ID).Delete(&LeaveAccount{}).Error
+}
+
+// ListLeavePolicies attempts to return all rows from the LeavePolicies table ordered by name
+func ListLeavePolicies(db *gorm.DB) ([]*LeavePolicy, error) {
+	var policies []*LeavePolicy
+
+	err := db.Model(&LeavePolicy{}).Order("name").Find(&policies).Error
+	if err != nil {
+		return []*LeavePolicy{}, err
+	}
+
+	return policies, nil
+}
+
+// FindLeavePolicyByID attempts to return a row from the LeavePolicies table with the matching LeavePolicy.ID
+func FindLeavePolicyByID(db *gorm.DB, pid string) (*LeavePolicy, error) {
+	policy := &LeavePolicy{}
+	err := db.First(&policy, "id = ?", pid).Error
+	if err != nil {
+		return &LeavePolicy{}, notFound("leave policy", err)
+	}
+
+	return policy, nil
+}
+
+// Save attempts to create or replace the LeaveAccount object in the database
+func (a *LeaveAccount) Save(db *gorm.DB) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"policy_id", "start", "updated_at"}),
+	}).Create(a).Error
+}
+
+// FindLeaveAccount attempts to return the LeaveAccount of the specified User.ID
+func FindLeaveAccount(db *gorm.DB, uid string) (*LeaveAccount, error) {
+	account := &LeaveAccount{}
+	err := db.First(&account, "user_id = ?", uid).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &LeaveAccount{}, ErrNoLeaveAccount
+		}
+
+		return &LeaveAccount{}, err
+	}
+
+	return account, nil
+}
+
+// accruals returns the number of month starts after from, up to and including to
+func accruals(from, to time.Time) int {
+	n := 0
+
+	for month := time.Date(from.Year(), from.Month()+1, 1, 0, 0, 0, 0, time.UTC); !month.After(to); month = month.AddDate(0, 1, 0) {
+		n++
+	}
+
+	return n
+}
+
+// ComputeLeaveBalance returns the leave balance of the specified User.ID for the year of at, carrying
+// over what remained at the end of each earlier year since their account started
+func ComputeLeaveBalance(db *gorm.DB, uid string, at time.Time) (*LeaveBalance, error) {
+	account, err := FindLeaveAccount(db, uid)
+	if err != nil {
+		return &LeaveBalance{}, err
+	}
+
+	policy, err := FindLeavePolicyByID(db, account.PolicyID)
+	if err != nil {
+		return &LeaveBalance{}, err
+	}
+
+	var requests []*TimeOff
+
+	err = db.Model(&TimeOff{}).Where("user_id = ? AND status IN ?", uid, []string{TimeOffApproved, TimeOffPending}).
+		Find(&requests).Error
+	if err != nil {
+		return &LeaveBalance{}, err
+	}
+
+	at = at.UTC()
+	balance := &LeaveBalance{
+		UserID:   uid,
+		PolicyID: policy.ID,
+		Year:     at.Year(),
+	}
+
+	used := make(map[int]float64)
+	for _, r := range requests {
+		if r.Status == TimeOffPending {
+			// Requests are drawn from the balance of the year they start in
+			if r.Start.UTC().Year() == at.Year() {
+				balance.Pending += r.Hours
+			}
+
+			continue
+		}
+
+		used[r.Start.UTC().Year()] += r.Hours
+	}
+
+	carry := 0.0
+	start := account.Start.UTC()
+
+	for year := start.Year(); year <= at.Year(); year++ {
+		// The first of January is credited in the year it starts
+		from := time.Date(year-1, 12, 31, 0, 0, 0, 0, time.UTC)
+		if from.Before(start) {
+			from = start
+		}
+
+		to := time.Date(year, 12, 31, 23, 59, 59, 0, time.UTC)
+		if year == at.Year() {
+			to = at
+		}
+
+		accrued := float64(accruals(from, to)) * policy.AccrualHours
+		remaining := carry + accrued - used[year]
+
+		if year == at.Year() {
+			balance.CarriedOver = round(carry)
+			balance.Accrued = round(accrued)
+			balance.Used = round(used[year])
+			balance.Available = round(remaining)
+			break
+		}
+
+		carry = math.Min(remaining, policy.Carryover)
+	}
+
+	balance.Pending = round(balance.Pending)
+
+	return balance, nil
+}
+
+// round rounds hours to hundredths
+func round(hours float64) float64 {
+	return math.Round(hours*100) / 100
+}
+
+// Validate checks to ensure all fields of the object are present and valid
+func (t *TimeOff) Validate() error {
+	if t.UserID == "" {
+		return invalid("user id required")
+	}
+
+	if t.Start.IsZero() || t.End.IsZero() {
+		return invalid("start and end required")
+	}
+
+	if !t.Start.Before(t.End) {
+		return invalid("time off start must precede its end")
+	}
+
+	if t.Hours <= 0 {
+		return invalid("hours must be positive")
+	}
+
+	if t.Hours > t.End.Sub(t.Start).Hours() {
+		return invalid("hours must not exceed the time between start and end")
+	}
+
+	return nil
+}
+
+// BeforeCreate hooks GORM and prepares a new object for creation
+func (t *TimeOff) BeforeCreate(_ *gorm.DB) error {
+	id, err := nanoid.Nanoid(10)
+	if err != nil {
+		return fmt.Errorf("unable to generate TimeOffID: %s", err)
+	}
+
+	t.ID = id
+	t.Status = TimeOffPending
+
+	return nil
+}
+
+// Request attempts to create the TimeOff object in the database, failing if the hours exceed what
+// the user will have available when it starts once their other requests awaiting approval are accounted for
+func (t *TimeOff) Request(db *gorm.DB) error {
+	return transaction(db, func(tx *gorm.DB) error {
+		balance, err := ComputeLeaveBalance(tx, t.UserID, t.Start)
+		if err != nil {
+			return err
+		}
+
+		if t.Hours > balance.Available-balance.Pending {
+			return ErrInsufficientBalance
+		}
+
+		return tx.Create(t).Error
+	})
+}
+
+// Approve attempts to approve the pending TimeOff, deducting it from the balance of the user
+func (t *TimeOff) Approve(db *gorm.DB, approverID, note string) error {
+	return t.decide(db, TimeOffApproved, approverID, note)
+}
+
+// Reject attempts to reject the pending TimeOff
+func (t *TimeOff) Reject(db *gorm.DB, approverID, note string) error {
+	return t.decide(db, TimeOffRejected, approverID, note)
+}
+
+func (t *TimeOff) decide(db *gorm.DB, status, approverID, note string) error {
+	if t.Status != TimeOffPending {
+		return ErrTimeOffDecided
+	}
+
+	return transaction(db, func(tx *gorm.DB) error {
+		// The balance may have changed since the request was made
+		if status == TimeOffApproved {
+			balance, err := ComputeLeaveBalance(tx, t.UserID, t.Start)
+			if err != nil {
+				return err
+			}
+
+			if t.Hours > balance.Available {
+				return ErrInsufficientBalance
+			}
+		}
+
+		now := time.Now()
+
+		res := tx.Model(t).Where("status = ?", TimeOffPending).Updates(map[string]interface{}{
+			"status":     status,
+			"decided_by": approverID,
+			"decided_at": now,
+			"note":       note,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			return ErrTimeOffDecided
+		}
+
+		t.Status = status
+		t.DecidedBy = approverID
+		t.DecidedAt = &now
+		t.Note = note
+
+		return nil
+	})
+}
+
+// ListTimeOff attempts to return the time off requested by the specified User.ID, latest first
+func ListTimeOff(db *gorm.DB, uid string) ([]*TimeOff, error) {
+	var requests []*TimeOff
+
+	err := db.Model(&TimeOff{}).Where("user_id = ?", uid).Order("start DESC").Find(&requests).Error
+	if err != nil {
+		return []*TimeOff{}, err
+	}
+
+	return requests, nil
+}
+
+// ListPendingTimeOff attempts to return every time off request awaiting approval, earliest first
+func ListPendingTimeOff(db *gorm.DB) ([]*TimeOff, error) {
+	var requests []*TimeOff
+
+	err := db.Model(&TimeOff{}).Where("status = ?", TimeOffPending).Order("start").Find(&requests).Error
+	if err != nil {
+		return []*TimeOff{}, err
+	}
+
+	return requests, nil
+}
+
+// FindTimeOffByID attempts to return a row from the TimeOffs table with the matching TimeOff.ID
+func FindTimeOffByID(db *gorm.DB, tid string) (*TimeOff, error) {
+	request := &TimeOff{}
+	err := db.First(&request, "id = ?", tid).Error
+	if err != nil {
+		return &TimeOff{}, notFound("time off", err)
+	}
+
+	return request, nil
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"errors"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testDB returns a migrated database of the test's own
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "shiftr.db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Migrate(db, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestTimeOffValidateHours(t *testing.T) {
+	start := time.Date(2026, time.March, 7, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		end   time.Time
+		hours float64
+		valid bool
+	}{
+		{"within", start.Add(8 * time.Hour), 6, true},
+		{"whole span", start.Add(8 * time.Hour), 8, true},
+		{"days", start.AddDate(0, 0, 3), 24, true},
+		{"exceeding", start.Add(8 * time.Hour), 8.5, false},
+		{"far exceeding", start.Add(time.Hour), 1000, false},
+		{"none", start.Add(8 * time.Hour), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			off := &TimeOff{UserID: "u1", Start: start, End: tt.end, Hours: tt.hours}
+
+			err := off.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("got %s, want valid", err)
+			}
+
+			if !tt.valid && !errors.Is(err, ErrInvalid) {
+				t.Errorf("got %v, want invalid", err)
+			}
+		})
+	}
+}
+
+func TestTimeOffBalanceAtStart(t *testing.T) {
+	db := testDB(t)
+
+	policy := &LeavePolicy{Name: "monthly", AccrualHours: 8, Carryover: 1000}
+	err := policy.Create(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	account := &LeaveAccount{UserID: "u1", PolicyID: policy.ID, Start: now.AddDate(0, 0, -1)}
+	err = account.Save(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// At least six months are accrued by the time off, though hardly any are by now
+	start := now.AddDate(0, 7, 0)
+	off := &TimeOff{UserID: "u1", Start: start, End: start.AddDate(0, 0, 5), Hours: 40}
+
+	err = off.Request(db)
+	if err != nil {
+		t.Fatalf("requesting leave accrued by its start: %s", err)
+	}
+
+	err = off.Approve(db, "approver", "")
+	if err != nil {
+		t.Fatalf("approving leave accrued by its start: %s", err)
+	}
+
+	// More than accrued by the start is refused
+	early := &TimeOff{UserID: "u1", Start: now.AddDate(0, 1, 0), End: now.AddDate(0, 1, 10), Hours: 200}
+
+	err = early.Request(db)
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("requesting leave not yet accrued: got %v, want %s", err, ErrInsufficientBalance)
+	}
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	LimitTooLong     = "shift_too_long"
+	LimitTooFarAhead = "shift_too_far_ahead"
+	LimitInPast      = "shift_in_past"
+)
+
+// ShiftLimits constrains the shifts which may be saved beyond their basic validity. Zero values disable
+// the corresponding check.
+type ShiftLimits struct {
+	MaxDuration time.Duration // longest a shift may last
+	MaxAdvance  time.Duration // furthest ahead of now a shift may start
+	RejectPast  bool          // refuse shifts which have already ended unless saved by an admin
+}
+
+// ShiftLimitError is returned when a Shift violates the configured ShiftLimits, identified by a stable Code
+type ShiftLimitError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ShiftLimitError) Error() string {
+	return e.Message
+}
+
+// checkLimits checks the shift against the limits which apply regardless of who saves it
+func (s *Shift) checkLimits(l ShiftLimits, now time.Time) error {
+	if l.MaxDuration > 0 && s.End.Sub(s.Start) > l.MaxDuration {
+		return &ShiftLimitError{
+			Code:    LimitTooLong,
+			Message: fmt.Sprintf("shift cannot last longer than %s", l.MaxDuration),
+		}
+	}
+
+	if l.MaxAdvance > 0 && s.Start.After(now.Add(l.MaxAdvance)) {
+		return &ShiftLimitError{
+			Code:    LimitTooFarAhead,
+			Message: fmt.Sprintf("shift cannot start more than %s from now", l.MaxAdvance),
+		}
+	}
+
+	return nil
+}
+
+// ValidateFor checks the shift as Validate does for a shift saved through the database by a user of the role,
+// additionally checking it against the ShiftLimits of the database, which refuse shifts which have already
+// ended when they reject them and the role is not admin
+func (s *Shift) ValidateFor(db *gorm.DB, role string) error {
+	return allInvalid(append(s.checks(), s.roleChecks(db, role)...))
+}
+
+// roleChecks returns the checks of the Shift which depend on the limits of the database and the role of the user
+// saving it
+func (s *Shift) roleChecks(db *gorm.DB, role string) []fieldCheck {
+	l := CurrentSettings(db).ShiftLimits
+
+	return []fieldCheck{
+		{"start", func() error {
+			return s.checkLimits(l, time.Now())
+		}},
+		{"end", func() error {
+			if role != "admin" && l.RejectPast && !s.End.After(time.Now()) {
+				return &ShiftLimitError{
+					Code:    LimitInPast,
+					Message: "shift cannot end in the past",
+				}
+			}
+
+			return nil
+		}},
+	}
+}
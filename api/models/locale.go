@@ -0,0 +1,37 @@
+package models
+
+import (
+	"github.com/btnmasher/shiftr/i18n"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Localization holds the locale and time zone a User reads messages and dates in
+type Localization struct {
+	Locale   string
+	Location *time.Location
+}
+
+// FindLocalization returns the Localization the specified User.ID prefers. Preferences which cannot be
+// read fall back to the default locale and UTC, so notifications are still sent.
+func FindLocalization(db *gorm.DB, uid string) *Localization {
+	l := &Localization{Locale: i18n.Default, Location: time.UTC}
+
+	pref, err := FindPreference(db, uid)
+	if err != nil {
+		return l
+	}
+
+	if pref.Locale != "" {
+		l.Locale = CurrentSettings(db).Catalog.Negotiate("", pref.Locale)
+	}
+
+	l.Location = pref.Location()
+
+	return l
+}
+
+// Format returns the time as read in the Localization's time zone
+func (l *Localization) Format(t time.Time) string {
+	return t.In(l.Location).Format(time.RFC1123)
+}
@@ -0,0 +1,235 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"html"
+	"math"
+	"strings"
+	"time"
+)
+
+// Geofence modes deciding what happens to clock-ins outside of a Location's geofence
+const (
+	GeofenceFlag   = "flag"   //accept the clock-in but flag it for review
+	GeofenceReject = "reject" //refuse the clock-in
+)
+
+// earthRadius is the mean radius of the Earth in meters
+const earthRadius = 6371008.8
+
+var (
+	ErrNoCoordinates   = errors.New("clock-in requires device coordinates at this location")
+	ErrOutsideGeofence = errors.New("clock-in is outside of the location's geofence")
+)
+
+// Location struct represents a site at which shifts are worked, optionally with a geofence of Radius
+// meters around its coordinates which clock-ins for shifts at the location are checked against. Shifts at the
+// location are paid in its Currency, an ISO 4217 code, when it differs from the organization's.
+type Location struct {
+	ID        string    `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"size:50;not null;unique" json:"name"`
+	Latitude  *float64  `json:"latitude,omitempty"`
+	Longitude *float64  `json:"longitude,omitempty"`
+	Radius    float64   `gorm:"not null;default:0" json:"radius,omitempty"`  //meters
+	Geofence  string    `gorm:"size:10;not null;default:''" json:"geofence"` //empty, flag or reject
+	Currency  string    `gorm:"size:3;not null;default:''" json:"currency,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate checks to ensure all fields of the object are present and valid
+func (l *Location) Validate() error {
+	if l.Name == "" {
+		return invalid("name required")
+	}
+
+	if (l.Latitude == nil) != (l.Longitude == nil) {
+		return invalid("latitude and longitude must be set together")
+	}
+
+	if l.Latitude != nil && (*l.Latitude < -90 || *l.Latitude > 90) {
+		return invalid("latitude must be between -90 and 90")
+	}
+
+	if l.Longitude != nil && (*l.Longitude < -180 || *l.Longitude > 180) {
+		return invalid("longitude must be between -180 and 180")
+	}
+
+	if l.Radius < 0 {
+		return invalid("radius cannot be negative")
+	}
+
+	switch l.Geofence {
+	case "":
+	case GeofenceFlag, GeofenceReject:
+		if l.Latitude == nil || l.Radius == 0 {
+			return invalid("geofence requires coordinates and a radius")
+		}
+	default:
+		return invalid("geofence must be one of flag or reject")
+	}
+
+	if l.Currency != "" && !ValidCurrency(l.Currency) {
+		return invalid("currency must be an ISO 4217 code such as EUR")
+	}
+
+	return nil
+}
+
+// CheckGeofence checks device coordinates against the geofence of the location, returning the distance
+// from the location in meters when coordinates are given. A nil error means the clock-in is accepted, and
+// a flag is the reason it should be reviewed.
+func (l *Location) CheckGeofence(lat, lng *float64) (distance *float64, flag string, err error) {
+	if l.Geofence == "" {
+		return nil, "", nil
+	}
+
+	if lat == nil || lng == nil {
+		if l.Geofence == GeofenceReject {
+			return nil, "", ErrNoCoordinates
+		}
+
+		return nil, "no device coordinates", nil
+	}
+
+	d := haversine(*l.Latitude, *l.Longitude, *lat, *lng)
+
+	if d > l.Radius {
+		if l.Geofence == GeofenceReject {
+			return &d, "", ErrOutsideGeofence
+		}
+
+		return &d, "outside geofence", nil
+	}
+
+	return &d, "", nil
+}
+
+// haversine returns the great circle distance in meters between two coordinates
+func haversine(lat1, lng1, lat2, lng2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+// BeforeCreate hooks GORM and prepares a new object for creation
+func (l *Location) BeforeCreate(_ *gorm.DB) error {
+	id, err := nanoid.Nanoid(8)
+	if err != nil {
+		return fmt.Errorf("unable to generate LocationID: %s", err)
+	}
+
+	l.ID = id
+	l.Name = html.EscapeString(strings.TrimSpace(l.Name))
+
+	return nil
+}
+
+// Create attempts to create the Location object in the database
+func (l *Location) Create(db *gorm.DB) error {
+	return db.Create(l).Error
+}
+
+// Update will attempt to update the current Location object in the database
+func (l *Location) Update(db *gorm.DB) error {
+	tx := db.Model(l).Where("id = ?", l.ID).Updates(
+		map[string]interface{}{
+			"name":      html.EscapeString(strings.TrimSpace(l.Name)),
+			"latitude":  l.Latitude,
+			"longitude": l.Longitude,
+			"radius":    l.Radius,
+			"geofence":  l.Geofence,
+			"currency":  l.Currency,
+		},
+	).Take(l)
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected < 1 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Delete will attempt to delete the Location object from the database
+func (l *Location) Delete(db *gorm.DB) error {
+	tx := db.Delete(l)
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected == 0 {
+		return &NotFoundError{Kind: "location"}
+	}
+
+	return nil
+}
+
+// AfterDelete hooks GORM to unassign the shifts and users at this location and remove its
+// coverage requirements and holidays when it is deleted
+func (l *Location) AfterDelete(db *gorm.DB) error {
+	err := updateShifts(db.Model(&Shift{}).Unscoped().Where("location_id = ?", l.ID), "location_id", "")
+	if err != nil {
+		return err
+	}
+
+	err = db.Model(&User{}).Where("location_id = ?", l.ID).Update("location_id", "").Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Where("location_id = ?", l.ID).Delete(&CoverageRequirement{}).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Where("location_id = ?", l.ID).Delete(&Holiday{}).Error
+}
+
+// ListLocations attempts to return all rows from the Locations table ordered by name
+func ListLocations(db *gorm.DB) ([]*Location, error) {
+	var locations []*Location
+
+	err := db.Model(&Location{}).Order("name").Find(&locations).Error
+	if err != nil {
+		return []*Location{}, err
+	}
+
+	return locations, nil
+}
+
+// FindLocationByID attempts to return a row from the Locations table with the matching Location.ID
+func FindLocationByID(db *gorm.DB, id string) (*Location, error) {
+	location := &Location{}
+	err := db.First(&location, "id = ?", id).Error
+	if err != nil {
+		return &Location{}, notFound("location", err)
+	}
+
+	return location, nil
+}
+
+// FindLocationByName attempts to return a row from the Locations table with the matching Location.Name
+func FindLocationByName(db *gorm.DB, name string) (*Location, error) {
+	location := &Location{}
+	err := db.First(&location, "name = ?", html.EscapeString(strings.TrimSpace(name))).Error
+	if err != nil {
+		return &Location{}, notFound("location", err)
+	}
+
+	return location, nil
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+var (
+	ErrManagerNotFound = &NotFoundError{Kind: "manager"}
+	ErrManagerCycle    = errors.New("manager cannot report to the user they manage")
+)
+
+// CheckManager ensures the manager exists and that making them the manager of the user would not
+// place the user above themselves in the reporting chain
+func CheckManager(db *gorm.DB, uid, mid string) error {
+	seen := map[string]bool{uid: true}
+
+	for id := mid; id != ""; {
+		if seen[id] {
+			return ErrManagerCycle
+		}
+		seen[id] = true
+
+		manager, err := FindUserByID(db, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrManagerNotFound
+			}
+
+			return err
+		}
+
+		id = manager.ManagerID
+	}
+
+	return nil
+}
+
+// ListDirectReports attempts to return the rows from the Users table managed by the specified User.ID ordered by name
+func ListDirectReports(db *gorm.DB, mid string) ([]*User, error) {
+	var users []*User
+
+	err := db.Model(&User{}).Where("manager_id = ?", mid).Order("name").Find(&users).Error
+	if err != nil {
+		return []*User{}, err
+	}
+
+	return users, nil
+}
+
+// ListReportIDs attempts to return the User.IDs of everyone reporting to the specified manager,
+// directly or through the managers reporting to them
+func ListReportIDs(db *gorm.DB, mid string) ([]string, error) {
+	seen := map[string]bool{mid: true}
+	var reports []string
+
+	for level := []string{mid}; len(level) > 0; {
+		var ids []string
+
+		err := db.Model(&User{}).Where("manager_id IN ?", level).Order("name").Pluck("id", &ids).Error
+		if err != nil {
+			return []string{}, err
+		}
+
+		level = level[:0:0]
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				reports = append(reports, id)
+				level = append(level, id)
+			}
+		}
+	}
+
+	return reports, nil
+}
+
+// Manages reports whether the manager is above the specified User.ID in their reporting chain
+func Manages(db *gorm.DB, mid, uid string) (bool, error) {
+	if mid == "" {
+		return false, nil
+	}
+
+	seen := map[string]bool{uid: true}
+
+	for id := uid; id != ""; {
+		user, err := FindUserByID(db, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return false, nil
+			}
+
+			return false, err
+		}
+
+		if user.ManagerID == mid {
+			return true, nil
+		}
+
+		// Chains saved before cycles were refused must still end
+		if seen[user.ManagerID] {
+			return false, nil
+		}
+		seen[user.ManagerID] = true
+
+		id = user.ManagerID
+	}
+
+	return false, nil
+}
+
+// Approvers returns the users who approve the requests of the specified User.ID, which is their
+// manager, or whoever the manager has delegated to while away, when they have one and every admin otherwise
+func Approvers(db *gorm.DB, uid string) ([]*User, error) {
+	user, err := FindUserByID(db, uid)
+	if err != nil {
+		return []*User{}, err
+	}
+
+	if user.ManagerID != "" {
+		acting, err := ActingApprover(db, user.ManagerID, time.Now())
+		if err != nil {
+			return []*User{}, err
+		}
+
+		manager, err := FindUserByID(db, acting)
+		if err == nil {
+			return []*User{manager}, nil
+		}
+
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return []*User{}, err
+		}
+	}
+
+	var admins []*User
+
+	err = db.Model(&User{}).Where("role = ?", "admin").Order("name").Find(&admins).Error
+	if err != nil {
+		return []*User{}, err
+	}
+
+	return admins, nil
+}
+
+// CanApprove reports whether the approver, with the given role, may approve the requests of the specified
+// User.ID. Admins may approve any request, other users only those of their direct reports while they have
+// not delegated their authority, and those of the managers who delegated it to them.
+func CanApprove(db *gorm.DB, approverID, role, uid string) (bool, error) {
+	if role == "admin" {
+		return true, nil
+	}
+
+	user, err := FindUserByID(db, uid)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	if user.ManagerID == "" {
+		return false, nil
+	}
+
+	acting, err := ActingApprover(db, user.ManagerID, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	return acting == approverID, nil
+}
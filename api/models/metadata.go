@@ -0,0 +1,85 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// MaxMetadataSize is the largest a Metadata map may be once serialized, in bytes
+const MaxMetadataSize = 4096
+
+// Metadata is a map of arbitrary values attached by frontends and integrations, stored serialized as JSON
+type Metadata map[string]interface{}
+
+// Validate checks the serialized metadata fits within MaxMetadataSize
+func (m Metadata) Validate() error {
+	if len(m) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	if len(data) > MaxMetadataSize {
+		return invalidf("metadata cannot be larger than %d bytes", MaxMetadataSize)
+	}
+
+	return nil
+}
+
+// GormDataType implements schema.GormDataTypeInterface
+func (Metadata) GormDataType() string {
+	return "json"
+}
+
+// GormDBDataType stores the metadata as jsonb on postgres and as text elsewhere
+func (Metadata) GormDBDataType(db *gorm.DB, _ *schema.Field) string {
+	if db.Dialector.Name() == "postgres" {
+		return "jsonb"
+	}
+
+	return "text"
+}
+
+// Value implements driver.Valuer, serializing the map
+func (m Metadata) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner, deserializing the map
+func (m *Metadata) Scan(src interface{}) error {
+	var data []byte
+
+	switch v := src.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("cannot scan %T into Metadata", src)
+	}
+
+	if len(data) == 0 {
+		*m = nil
+		return nil
+	}
+
+	return json.Unmarshal(data, m)
+}
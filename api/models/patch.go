@@ -0,0 +1,82 @@
+package models
+
+import (
+	"encoding/json"
+)
+
+// ShiftPatchFields are the members of a Shift which a merge patch may change
+var ShiftPatchFields = []string{"user_id", "start", "end", "capacity", "status", "location_id", "position_id",
+	"color", "visibility", "metadata", "tags"}
+
+// UserPatchFields are the members of a User which a merge patch may change
+var UserPatchFields = []string{"name", "password", "role", "email", "team_id", "location_id", "manager_id", "phone",
+	"custom_fields", "hourly_rate"}
+
+// ApplyMergePatch applies the JSON Merge Patch (RFC 7386) to the JSON of the current object and decodes the result
+// into patched. Members set to null are removed, and so reset to their zero value, while objects such as metadata
+// are merged member by member. Only the writable members may appear in the patch, and the ones which did are
+// returned.
+func ApplyMergePatch(current interface{}, patch map[string]interface{}, patched interface{},
+	writable ...string) (map[string]bool, error) {
+	allowed := make(map[string]bool, len(writable))
+	for _, name := range writable {
+		allowed[name] = true
+	}
+
+	fields := make(map[string]bool, len(patch))
+	for name := range patch {
+		if !allowed[name] {
+			return nil, invalidf("%s cannot be patched", name)
+		}
+
+		fields[name] = true
+	}
+
+	data, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+
+	var document interface{}
+
+	err = json.Unmarshal(data, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = json.Marshal(mergePatch(document, patch))
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, patched)
+	if err != nil {
+		return nil, invalid("invalid object")
+	}
+
+	return fields, nil
+}
+
+// mergePatch returns the target with the patch applied as described by RFC 7386
+func mergePatch(target, patch interface{}) interface{} {
+	members, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	result, ok := target.(map[string]interface{})
+	if !ok {
+		result = make(map[string]interface{}, len(members))
+	}
+
+	for name, value := range members {
+		if value == nil {
+			delete(result, name)
+			continue
+		}
+
+		result[name] = mergePatch(result[name], value)
+	}
+
+	return result
+}
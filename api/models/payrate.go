@@ -0,0 +1,91 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// PayFields are the JSON keys holding pay data, which is only shown to admins
+var PayFields = []string{"hourly_rate", "labor_cost"}
+
+// PayRate struct represents the hourly rate a User is paid when working at a Position, overriding their own
+type PayRate struct {
+	UserID     string  `gorm:"primaryKey" json:"-"`
+	PositionID string  `gorm:"primaryKey;index" json:"position_id"`
+	HourlyRate float64 `gorm:"not null" json:"hourly_rate"`
+}
+
+// Validate checks to ensure all fields of the object are present and valid
+func (r *PayRate) Validate() error {
+	if r.PositionID == "" {
+		return invalid("position_id required")
+	}
+
+	if r.HourlyRate < 0 {
+		return invalid("hourly_rate cannot be negative")
+	}
+
+	return nil
+}
+
+// ListPayRates attempts to return the rows from the PayRates table of the specified User.ID ordered by position
+func ListPayRates(db *gorm.DB, uid string) ([]*PayRate, error) {
+	var rates []*PayRate
+
+	err := db.Where("user_id = ?", uid).Order("position_id").Find(&rates).Error
+	if err != nil {
+		return []*PayRate{}, err
+	}
+
+	return rates, nil
+}
+
+// SetPayRates attempts to replace the pay rates of the specified User.ID with the rates given, the last rate
+// given for a position taking effect
+func SetPayRates(db *gorm.DB, uid string, rates []*PayRate) ([]*PayRate, error) {
+	byPosition := make(map[string]*PayRate, len(rates))
+	set := make([]*PayRate, 0, len(rates))
+
+	for _, r := range rates {
+		r.UserID = uid
+
+		if existing, ok := byPosition[r.PositionID]; ok {
+			existing.HourlyRate = r.HourlyRate
+			continue
+		}
+
+		byPosition[r.PositionID] = r
+		set = append(set, r)
+	}
+
+	err := transaction(db, func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ?", uid).Delete(&PayRate{}).Error
+		if err != nil {
+			return err
+		}
+
+		if len(set) == 0 {
+			return nil
+		}
+
+		return tx.Create(&set).Error
+	})
+	if err != nil {
+		return []*PayRate{}, err
+	}
+
+	return set, nil
+}
+
+// hourlyRate is the SQL expression of the hourly rate the worker of each shift is paid at its position, from
+// the tables joined by joinPayRates
+const hourlyRate = "COALESCE(rates.position_rate, workers.worker_rate, 0)"
+
+// joinPayRates joins the rates the worker of each shift is paid, renaming their columns so that filters on
+// the columns of the shifts stay unambiguous
+func joinPayRates(tx *gorm.DB) *gorm.DB {
+	return tx.
+		Joins("LEFT JOIN (SELECT user_id AS rate_user_id, position_id AS rate_position_id, hourly_rate AS position_rate " +
+			"FROM pay_rates) rates ON rates.rate_user_id = shifts.user_id AND rates.rate_position_id = shifts.position_id").
+		Joins("LEFT JOIN (SELECT id AS worker_id, hourly_rate AS worker_rate FROM users) workers " +
+			"ON workers.worker_id = shifts.user_id")
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"fmt"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"html"
+	"strings"
+	"time"
+)
+
+// Position struct represents a role or station which shifts are worked in
+type Position struct {
+	ID            string    `gorm:"primaryKey" json:"id"`
+	Name          string    `gorm:"size:50;not null;unique" json:"name"`
+	Certification string    `gorm:"size:50" json:"certification,omitempty"` //name of the certification required to work it
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Validate checks to ensure all fields of the object are present and valid
+func (p *Position) Validate() error {
+	if p.Name == "" {
+		return invalid("name required")
+	}
+
+	return nil
+}
+
+// BeforeCreate hooks GORM and prepares a new object for creation
+func (p *Position) BeforeCreate(_ *gorm.DB) error {
+	id, err := nanoid.Nanoid(8)
+	if err != nil {
+		return fmt.Errorf("unable to generate PositionID: %s", err)
+	}
+
+	p.ID = id
+	p.Name = html.EscapeString(strings.TrimSpace(p.Name))
+	p.Certification = html.EscapeString(strings.TrimSpace(p.Certification))
+
+	return nil
+}
+
+// Create attempts to create the Position object in the database
+func (p *Position) Create(db *gorm.DB) error {
+	return db.Create(p).Error
+}
+
+// Update will attempt to update the current Position object in the database
+func (p *Position) Update(db *gorm.DB) error {
+	tx := db.Model(p).Where("id = ?", p.ID).Updates(
+		map[string]interface{}{
+			"name":          html.EscapeString(strings.TrimSpace(p.Name)),
+			"certification": html.EscapeString(strings.TrimSpace(p.Certification)),
+		},
+	).Take(p)
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected < 1 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Delete will attempt to delete the Position object from the database
+func (p *Position) Delete(db *gorm.DB) error {
+	tx := db.Delete(p)
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected == 0 {
+		return &NotFoundError{Kind: "position"}
+	}
+
+	return nil
+}
+
+// AfterDelete hooks GORM to unassign the shifts at this position and remove its pay rates and its
+// coverage requirements when it is deleted
+func (p *Position) AfterDelete(db *gorm.DB) error {
+	err := updateShifts(db.Model(&Shift{}).Unscoped().Where("position_id = ?", p.ID), "position_id", "")
+	if err != nil {
+		return err
+	}
+
+	err = db.Where("position_id = ?", p.ID).Delete(&PayRate{}).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Where("position_id = ?", p.ID).Delete(&CoverageRequirement{}).Error
+}
+
+// ListPositions attempts to return all rows from the Positions table ordered by name
+func ListPositions(db *gorm.DB) ([]*Position, error) {
+	var positions []*Position
+
+	err := db.Model(&Position{}).Order("name").Find(&positions).Error
+	if err != nil {
+		return []*Position{}, err
+	}
+
+	return positions, nil
+}
+
+// FindPositionByID attempts to return a row from the Positions table with the matching Position.ID
+func FindPositionByID(db *gorm.DB, id string) (*Position, error) {
+	position := &Position{}
+	err := db.First(&position, "id = ?", id).Error
+	if err != nil {
+		return &Position{}, notFound("position", err)
+	}
+
+	return position, nil
+}
+
+// FindPositionByName attempts to return a row from the Positions table with the matching Position.Name
+func FindPositionByName(db *gorm.DB, name string) (*Position, error) {
+	position := &Position{}
+	err := db.First(&position, "name = ?", html.EscapeString(strings.TrimSpace(name))).Error
+	if err != nil {
+		return &Position{}, notFound("position", err)
+	}
+
+	return position, nil
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"time"
+)
+
+// Preference struct represents the notification and localization preferences of a User
+type Preference struct {
+	UserID              string    `gorm:"primaryKey" json:"user_id"`
+	RemindersOptOut     bool      `gorm:"not null" json:"reminders_opt_out"`     //disable shift reminders
+	ReminderLeadMinutes int       `gorm:"not null" json:"reminder_lead_minutes"` //0 uses the server default
+	Locale              string    `gorm:"size:35" json:"locale,omitempty"`       //language of messages, e.g. fr
+	TimeZone            string    `gorm:"size:64" json:"time_zone,omitempty"`    //IANA zone of dates, e.g. Europe/Paris
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// Validate checks to ensure all fields of the object are present and valid, with a locale the messages of the
+// database are translated into
+func (p *Preference) Validate(db *gorm.DB) error {
+	if p.UserID == "" {
+		return invalid("user id required")
+	}
+
+	if p.ReminderLeadMinutes < 0 {
+		return invalid("reminder lead time cannot be negative")
+	}
+
+	catalog := CurrentSettings(db).Catalog
+	if p.Locale != "" && !catalog.IsSupported(p.Locale) {
+		return invalidf("locale must be one of %s", strings.Join(catalog.Supported(), ", "))
+	}
+
+	if _, err := time.LoadLocation(p.TimeZone); err != nil {
+		return invalid("invalid time zone")
+	}
+
+	return nil
+}
+
+// ReminderLead returns the configured reminder lead time, or def if none has been set
+func (p *Preference) ReminderLead(def time.Duration) time.Duration {
+	if p.ReminderLeadMinutes < 1 {
+		return def
+	}
+
+	return time.Duration(p.ReminderLeadMinutes) * time.Minute
+}
+
+// Location returns the time zone the User prefers dates in, UTC when they have not set one
+func (p *Preference) Location() *time.Location {
+	loc, err := time.LoadLocation(p.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// Save attempts to create or replace the Preference object in the database
+func (p *Preference) Save(db *gorm.DB) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reminders_opt_out", "reminder_lead_minutes", "locale", "time_zone", "updated_at"}),
+	}).Create(p).Error
+}
+
+// FindPreference returns the Preference for the specified User.ID,
+// or the default preferences if the user has never saved any
+func FindPreference(db *gorm.DB, uid string) (*Preference, error) {
+	pref := &Preference{}
+	err := db.First(&pref, "user_id = ?", uid).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &Preference{UserID: uid}, nil
+		}
+
+		return &Preference{}, err
+	}
+
+	return pref, nil
+}
+
+// FindPreferences returns the saved preferences for the specified User.IDs keyed by User.ID
+func FindPreferences(db *gorm.DB, uids []string) (map[string]*Preference, error) {
+	var prefs []*Preference
+
+	prefMap := make(map[string]*Preference, len(uids))
+	if len(uids) == 0 {
+		return prefMap, nil
+	}
+
+	err := db.Where("user_id IN ?", uids).Find(&prefs).Error
+	if err != nil {
+		return prefMap, err
+	}
+
+	for _, pref := range prefs {
+		prefMap[pref.UserID] = pref
+	}
+
+	return prefMap, nil
+}
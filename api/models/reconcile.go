@@ -0,0 +1,186 @@
+package models
+
+import (
+	"fmt"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Kinds of discrepancy between the shifts scheduled on a day and the time clocked
+const (
+	DiscrepancyNoClockIn       = "no_clock_in"       // the worker never clocked in for the shift
+	DiscrepancyMissingClockOut = "missing_clock_out" // the worker clocked in for the shift but never out
+	DiscrepancyVariance        = "variance"          // the time worked differs from the time scheduled
+	DiscrepancyUnscheduled     = "unscheduled"       // time clocked without a shift to count towards
+)
+
+// Discrepancy struct represents a difference between the schedule and the time clocked on a day, flagged
+// for review by reconciliation. Times are in whole minutes, the variance being worked less scheduled.
+type Discrepancy struct {
+	ID               string    `gorm:"primaryKey" json:"id"`
+	Date             string    `gorm:"size:10;not null;index" json:"date"` //YYYY-MM-DD
+	UserID           string    `gorm:"not null;index" json:"user_id"`
+	ShiftID          string    `gorm:"index" json:"shift_id,omitempty"`
+	ClockEntryID     string    `json:"clock_entry_id,omitempty"`
+	Kind             string    `gorm:"size:20;not null" json:"kind"`
+	ScheduledMinutes int       `gorm:"not null;default:0" json:"scheduled_minutes"`
+	WorkedMinutes    int       `gorm:"not null;default:0" json:"worked_minutes"`
+	VarianceMinutes  int       `gorm:"not null;default:0" json:"variance_minutes"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// BeforeCreate hooks GORM and prepares a new object for creation
+func (d *Discrepancy) BeforeCreate(_ *gorm.DB) error {
+	id, err := nanoid.Nanoid(10)
+	if err != nil {
+		return fmt.Errorf("unable to generate DiscrepancyID: %s", err)
+	}
+
+	d.ID = id
+
+	return nil
+}
+
+// Reconcile compares the published shifts starting on the day, in its location, with the time clocked
+// for them and flags the discrepancies: shifts never clocked in for or out of, shifts whose worked time
+// differs from the scheduled time by more than variance, and time clocked without a shift. Worked time is
+// computed from punches rounded by the PunchRounding of the database. Shifts still in progress at now are left
+// for a later reconciliation. The discrepancies replace any previously flagged for the day.
+func Reconcile(db *gorm.DB, day time.Time, variance time.Duration, now time.Time) ([]*Discrepancy, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1)
+	date := start.Format("2006-01-02")
+	rounding := CurrentSettings(db).PunchRounding
+
+	discrepancies := []*Discrepancy{}
+
+	err := transaction(db, func(tx *gorm.DB) error {
+		shifts, err := ListShifts(tx,
+			FilterStatus(ShiftPublished),
+			FilterStart(start),
+			FilterStartsBefore(end),
+			func(db *gorm.DB) {
+				db.Where("user_id <> ''")
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		var entries []*ClockEntry
+		err = tx.Where("clock_in >= ? AND clock_in < ?", start.Add(-ClockInEarly), end).Order("clock_in").Find(&entries).Error
+		if err != nil {
+			return err
+		}
+
+		punches := make(map[string][]*ClockEntry)
+		for _, entry := range entries {
+			if entry.ShiftID != "" {
+				punches[entry.ShiftID] = append(punches[entry.ShiftID], entry)
+			} else if !entry.ClockIn.Before(start) {
+				discrepancies = append(discrepancies, unscheduled(date, entry))
+			}
+		}
+
+		for _, shift := range shifts {
+			if shift.End.After(now) {
+				continue
+			}
+
+			if d := reconcileShift(date, shift, punches[shift.ID], variance, rounding); d != nil {
+				discrepancies = append(discrepancies, d)
+			}
+		}
+
+		err = tx.Where("date = ?", date).Delete(&Discrepancy{}).Error
+		if err != nil {
+			return err
+		}
+
+		if len(discrepancies) == 0 {
+			return nil
+		}
+
+		return tx.Create(&discrepancies).Error
+	})
+	if err != nil {
+		return []*Discrepancy{}, err
+	}
+
+	return discrepancies, nil
+}
+
+// reconcileShift returns the discrepancy between the shift and the punches made for it, rounded as given, if any
+func reconcileShift(date string, shift *Shift, entries []*ClockEntry, variance time.Duration, r PunchRounding) *Discrepancy {
+	scheduled := shift.End.Sub(shift.Start)
+	d := &Discrepancy{
+		Date:             date,
+		UserID:           shift.UserID,
+		ShiftID:          shift.ID,
+		ScheduledMinutes: int(scheduled.Minutes()),
+	}
+
+	if len(entries) == 0 {
+		d.Kind = DiscrepancyNoClockIn
+		d.VarianceMinutes = -d.ScheduledMinutes
+		return d
+	}
+
+	var worked time.Duration
+	for _, entry := range entries {
+		if entry.ClockOut == nil {
+			d.Kind = DiscrepancyMissingClockOut
+			d.ClockEntryID = entry.ID
+			return d
+		}
+
+		worked += r.Round(*entry.ClockOut, shift.End).Sub(r.Round(entry.ClockIn, shift.Start))
+	}
+
+	d.WorkedMinutes = int(worked.Minutes())
+	d.VarianceMinutes = d.WorkedMinutes - d.ScheduledMinutes
+
+	if absDuration(worked-scheduled) <= variance {
+		return nil
+	}
+
+	d.Kind = DiscrepancyVariance
+
+	return d
+}
+
+// unscheduled returns the discrepancy of a clock entry made without a shift
+func unscheduled(date string, entry *ClockEntry) *Discrepancy {
+	d := &Discrepancy{
+		Date:         date,
+		UserID:       entry.UserID,
+		ClockEntryID: entry.ID,
+		Kind:         DiscrepancyUnscheduled,
+	}
+
+	if entry.ClockOut != nil {
+		d.WorkedMinutes = int(entry.ClockOut.Sub(entry.ClockIn).Minutes())
+		d.VarianceMinutes = d.WorkedMinutes
+	}
+
+	return d
+}
+
+// ListDiscrepancies attempts to return the discrepancies flagged for the date, formatted YYYY-MM-DD,
+// restricted to the specified User.IDs when not nil
+func ListDiscrepancies(db *gorm.DB, date string, uids []string) ([]*Discrepancy, error) {
+	var discrepancies []*Discrepancy
+
+	tx := db.Model(&Discrepancy{}).Where("date = ?", date).Order("user_id, kind")
+	if uids != nil {
+		tx.Where("user_id IN ?", uids)
+	}
+
+	err := tx.Find(&discrepancies).Error
+	if err != nil {
+		return []*Discrepancy{}, err
+	}
+
+	return discrepancies, nil
+}
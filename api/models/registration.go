@@ -0,0 +1,196 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/utils"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"html"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// RegistrationTTL is how long a pending Registration may be verified before it expires
+const RegistrationTTL = time.Hour * 24
+
+// ErrRegistrationExpired is returned when completing a Registration after its Token has expired
+var ErrRegistrationExpired = errors.New("registration expired")
+
+// ErrDomainNotAllowed is returned when registering with an email address outside the allowed domains
+var ErrDomainNotAllowed = errors.New("email domain not allowed")
+
+// SignupPolicy restricts who may self-register and sets what their accounts are given, so that opening
+// registration does not let anyone create an account with unrestricted access
+type SignupPolicy struct {
+	Role    string   // role assigned to new accounts
+	TeamID  string   // team new accounts join, none when empty
+	Domains []string // email domains allowed to register, such as "example.com", any when empty
+}
+
+// Validate checks to ensure all fields of the object are valid
+func (p SignupPolicy) Validate() error {
+	if p.Role != "user" && p.Role != "admin" {
+		return invalid("role must be user or admin")
+	}
+
+	for _, domain := range p.Domains {
+		if domain == "" || strings.ContainsAny(domain, "@ ") {
+			return invalidf("invalid email domain %q", domain)
+		}
+	}
+
+	return nil
+}
+
+// Allows reports whether the email address may be registered under the SignupPolicy. Domains match
+// exactly and without regard to case, so subdomains must be listed on their own.
+func (p SignupPolicy) Allows(email string) bool {
+	if len(p.Domains) == 0 {
+		return true
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		return false
+	}
+
+	domain := addr.Address[strings.LastIndex(addr.Address, "@")+1:]
+	for _, allowed := range p.Domains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Registration struct represents a pending self-registered account awaiting email verification.
+// The account is only created as a User once the emailed Token has been verified.
+type Registration struct {
+	Token     string    `gorm:"primaryKey" json:"-"`
+	Name      string    `gorm:"size:30;not null" json:"name"`
+	Email     string    `gorm:"size:254;not null" json:"email"`
+	Password  string    `gorm:"size:100;not null" json:"password,omitempty"` //bcrypt hash
+	Role      string    `gorm:"size:10;not null" json:"role"`
+	TeamID    string    `json:"team_id,omitempty"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate checks to ensure all fields of the object are present and valid
+func (r *Registration) Validate() error {
+	if r.Name == "" {
+		return invalid("name required")
+	}
+
+	if r.Email == "" {
+		return invalid("email required")
+	}
+
+	if _, err := mail.ParseAddress(r.Email); err != nil {
+		return invalid("invalid email")
+	}
+
+	if r.Password == "" {
+		return invalid("password required")
+	}
+
+	return nil
+}
+
+// Create attempts to create the pending Registration in the database, generating its verification token
+func (r *Registration) Create(db *gorm.DB) error {
+	token, err := nanoid.Nanoid(32)
+	if err != nil {
+		return fmt.Errorf("unable to generate registration token: %s", err)
+	}
+
+	hashedPassword, err := utils.HashPassword(r.Password)
+	if err != nil {
+		return err
+	}
+
+	r.Token = token
+	r.Name = html.EscapeString(strings.TrimSpace(r.Name))
+	r.Email = strings.TrimSpace(r.Email)
+	r.Password = string(hashedPassword)
+	r.ExpiresAt = time.Now().Add(RegistrationTTL)
+
+	return db.Create(r).Error
+}
+
+// Expired reports whether the Registration can no longer be verified
+func (r *Registration) Expired() bool {
+	return time.Now().After(r.ExpiresAt)
+}
+
+// Complete creates the User described by the Registration and removes the pending Registration
+func (r *Registration) Complete(db *gorm.DB) (*User, error) {
+	if r.Expired() {
+		return nil, ErrRegistrationExpired
+	}
+
+	id, err := newUserID()
+	if err != nil {
+		return nil, err
+	}
+
+	user := &User{
+		ID:       id,
+		Name:     r.Name,
+		Email:    r.Email,
+		EmailKey: emailKey(r.Email),
+		Password: r.Password, // already hashed
+		Role:     r.Role,
+		TeamID:   r.TeamID,
+	}
+
+	err = transaction(db, func(tx *gorm.DB) error {
+		// The team may have been removed while the registration was pending, the account is then left unassigned
+		if user.TeamID != "" {
+			_, err := FindTeamByID(tx, user.TeamID)
+			if errors.Is(err, ErrNotFound) {
+				user.TeamID = ""
+			} else if err != nil {
+				return err
+			}
+		}
+
+		err := tx.Create(user).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Delete(r).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// FindRegistrationByToken attempts to return a row from the Registrations table with the matching Token
+func FindRegistrationByToken(db *gorm.DB, token string) (*Registration, error) {
+	registration := &Registration{}
+	err := db.First(&registration, "token = ?", token).Error
+	if err != nil {
+		return &Registration{}, notFound("registration", err)
+	}
+
+	return registration, nil
+}
+
+// FindRegistrationByName attempts to return an unexpired row from the Registrations table with the matching Name
+func FindRegistrationByName(db *gorm.DB, name string) (*Registration, error) {
+	registration := &Registration{}
+	err := db.First(&registration, "name = ? AND expires_at > ?", name, time.Now()).Error
+	if err != nil {
+		return &Registration{}, notFound("registration", err)
+	}
+
+	return registration, nil
+}
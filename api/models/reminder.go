@@ -0,0 +1,72 @@
+package models
+
+import (
+	"fmt"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	ReminderSent   = "sent"
+	ReminderFailed = "failed"
+)
+
+// Reminder struct represents the delivery record of a reminder sent for a Shift.
+// A reminder is tracked against the shift start time it announced, so a rescheduled
+// shift will be reminded again.
+type Reminder struct {
+	ID         string    `gorm:"primaryKey" json:"id"`
+	ShiftID    string    `gorm:"not null;uniqueIndex:idx_reminder_shift" json:"shift_id"`
+	ShiftStart time.Time `gorm:"not null;uniqueIndex:idx_reminder_shift" json:"shift_start"`
+	UserID     string    `gorm:"not null;index" json:"user_id"`
+	Status     string    `gorm:"size:10;not null" json:"status"` //delivery status: sent, failed
+	Error      string    `json:"error,omitempty"`
+	Attempts   int       `gorm:"not null" json:"attempts"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// BeforeCreate hooks GORM and prepares a new object for creation
+func (r *Reminder) BeforeCreate(_ *gorm.DB) error {
+	id, err := nanoid.Nanoid(10)
+	if err != nil {
+		return fmt.Errorf("unable to generate ReminderID: %s", err)
+	}
+
+	r.ID = id
+
+	return nil
+}
+
+// Save attempts to create or update the Reminder object in the database
+func (r *Reminder) Save(db *gorm.DB) error {
+	if r.ID == "" {
+		return db.Create(r).Error
+	}
+
+	return db.Save(r).Error
+}
+
+// FindReminder attempts to return the delivery record of the reminder for the shift at the specified start time
+func FindReminder(db *gorm.DB, sid string, start time.Time) (*Reminder, error) {
+	reminder := &Reminder{}
+	err := db.First(&reminder, "shift_id = ? AND shift_start = ?", sid, start).Error
+	if err != nil {
+		return &Reminder{}, notFound("reminder", err)
+	}
+
+	return reminder, nil
+}
+
+// ListReminders attempts to return the delivery records of the reminders sent for the specified Shift.ID
+func ListReminders(db *gorm.DB, sid string) ([]*Reminder, error) {
+	var reminders []*Reminder
+
+	err := db.Model(&Reminder{}).Where("shift_id = ?", sid).Order("created_at").Find(&reminders).Error
+	if err != nil {
+		return []*Reminder{}, err
+	}
+
+	return reminders, nil
+}
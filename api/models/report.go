@@ -0,0 +1,238 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/utils"
+	"gorm.io/gorm"
+	"math"
+	"strings"
+	"time"
+)
+
+// HoursTotal is the number of scheduled hours of a group of shifts
+type HoursTotal struct {
+	Group  string  `json:"group"`          //User.ID, Team.ID, or the date starting the week or its ISO 8601 week
+	Name   string  `json:"name,omitempty"` //User.Name or Team.Name
+	Shifts int     `json:"shifts"`
+	Hours  float64 `json:"hours"`
+	Paid   float64 `json:"paid_hours"` //hours with holiday and differential pay multipliers applied
+
+	// Costs is the labor cost of the paid hours at the hourly rate of each worker, by ISO 4217 currency code
+	Costs map[string]float64 `gorm:"-" json:"labor_cost"`
+}
+
+// addCost adds the amount to the labor cost in the currency, the organization's given when empty
+func (t *HoursTotal) addCost(code, org string, amount float64) {
+	if code == "" {
+		code = org
+	}
+
+	t.Costs[code] += amount
+}
+
+const (
+	GroupByUser = "user"
+	GroupByTeam = "team"
+	GroupByWeek = "week"
+)
+
+var (
+	ErrInvalidGrouping  = errors.New("group_by must be one of user, team or week")
+	ErrInvalidWeekStart = errors.New("week_start must be iso or the name of a weekday")
+)
+
+// Weeks sets how shifts are grouped into weeks. Weeks begin on Start, or when ISO is set are the ISO 8601
+// weeks beginning on Monday and are labelled by their number, such as 2024-W32.
+type Weeks struct {
+	Start time.Weekday
+	ISO   bool
+}
+
+// Of returns the start of the week containing the time, at midnight in its location, and the start of the next
+func (w Weeks) Of(t time.Time) (time.Time, time.Time) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	start := day.AddDate(0, 0, -((int(day.Weekday()) - int(w.Start) + 7) % 7))
+
+	return start, start.AddDate(0, 0, 7)
+}
+
+// ParseWeeks returns the Weeks beginning on the named weekday, or the ISO 8601 weeks for iso
+func ParseWeeks(s string) (Weeks, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "iso" {
+		return Weeks{Start: time.Monday, ISO: true}, nil
+	}
+
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if name := strings.ToLower(day.String()); s == name || s == name[:3] {
+			return Weeks{Start: day}, nil
+		}
+	}
+
+	return Weeks{}, ErrInvalidWeekStart
+}
+
+// SummarizeHours totals the scheduled hours of the assigned shifts matching the filters, grouped by
+// user, team or week, with weeks as specified. The totals are computed by the database rather than by loading every shift.
+// Paid hours weight the hours of shifts starting on a holiday by the holiday's multiplier, and those
+// worked within the window of a pay differential by the differential's multiplier as well. The labor cost
+// pays them at the worker's rate for the position of the shift, or their own hourly rate without one, in the
+// currency of the location of the shift or the organization's.
+func SummarizeHours(db *gorm.DB, groupBy string, weeks Weeks, opts ...ShiftFilterOption) ([]*HoursTotal, error) {
+	totals := []*HoursTotal{}
+
+	hours := hoursBetween(db, "shifts.start", "shifts.end")
+	multiplier := holidayMultiplier(db)
+	rate := hourlyRate
+	code := shiftCurrency
+
+	// query returns the filtered shifts joined with the tables needed to group them
+	query := func() *gorm.DB {
+		tx := db.Model(&Shift{}).Where("shifts.user_id <> ''")
+
+		for _, opt := range opts {
+			opt(tx)
+		}
+
+		if groupBy != GroupByWeek {
+			tx.Joins("LEFT JOIN users ON users.id = shifts.user_id")
+		}
+
+		if groupBy == GroupByTeam {
+			tx.Joins("LEFT JOIN teams ON teams.id = users.team_id")
+		}
+
+		joinPayRates(tx)
+		joinCurrency(tx)
+
+		return tx
+	}
+
+	var group, name, grouping, order string
+
+	switch groupBy {
+	case GroupByUser:
+		group, name = "shifts.user_id", "users.name"
+		grouping, order = "shifts.user_id, users.name", "users.name"
+	case GroupByTeam:
+		group, name = "COALESCE(users.team_id, '')", "COALESCE(teams.name, '')"
+		grouping, order = "users.team_id, teams.name", "name"
+	case GroupByWeek:
+		group, name = weekStart(db, "shifts.start", weeks.Start), "''"
+		grouping, order = group, group
+	default:
+		return totals, ErrInvalidGrouping
+	}
+
+	err := query().
+		Select(fmt.Sprintf("%s AS %s, %s AS name, COUNT(*) AS shifts, SUM(%s) AS hours, SUM((%s) * %s) AS paid",
+			group, quote(db, "group"), name, hours, hours, multiplier)).
+		Group(grouping).
+		Order(order).
+		Scan(&totals).Error
+	if err != nil {
+		return []*HoursTotal{}, err
+	}
+
+	byGroup := make(map[string]*HoursTotal, len(totals))
+	for _, total := range totals {
+		total.Costs = make(map[string]float64)
+		byGroup[total.Group] = total
+	}
+
+	// Shifts of a group may be paid in several currencies, which are totalled separately, in the organization's
+	// unless their location sets its own
+	org := CurrentSettings(db).Currency
+
+	var costs []struct {
+		Group    string
+		Currency string
+		Cost     float64
+	}
+
+	err = query().
+		Select(fmt.Sprintf("%s AS %s, %s AS currency, SUM((%s) * %s * %s) AS cost",
+			group, quote(db, "group"), code, hours, multiplier, rate)).
+		Group(grouping + ", " + code).
+		Scan(&costs).Error
+	if err != nil {
+		return []*HoursTotal{}, err
+	}
+
+	for _, c := range costs {
+		if total, ok := byGroup[c.Group]; ok {
+			total.addCost(c.Currency, org, c.Cost)
+		}
+	}
+
+	diffs, err := ListDifferentials(db)
+	if err != nil {
+		return []*HoursTotal{}, err
+	}
+
+	// ISO weeks are labelled once the premiums, matched to the totals by date, have been added
+	if groupBy == GroupByWeek && weeks.ISO {
+		defer labelISOWeeks(totals)
+	}
+
+	if len(diffs) == 0 {
+		roundTotals(totals)
+		return totals, nil
+	}
+
+	// The windows of differentials depend on the time of day and weekday of each shift in the zone of the
+	// differential, which is not practical to compute in SQL across dialects, so the premium is added here
+	var premiums []struct {
+		Group      string
+		Start      time.Time
+		End        time.Time
+		Multiplier float64
+		Rate       float64
+		Currency   string
+	}
+
+	err = query().
+		Select(fmt.Sprintf("%s AS %s, %s AS start, %s AS %s, %s AS multiplier, %s AS rate, %s AS currency",
+			group, quote(db, "group"), quote(db, "shifts.start"), quote(db, "shifts.end"), quote(db, "end"),
+			multiplier, rate, code)).
+		Scan(&premiums).Error
+	if err != nil {
+		return []*HoursTotal{}, err
+	}
+
+	for _, p := range premiums {
+		total, ok := byGroup[p.Group]
+		if !ok {
+			continue
+		}
+
+		effective := diffs.Effective(p.Start, p.End)
+		premium := p.End.Sub(p.Start).Hours() * p.Multiplier * (effective - 1)
+		total.Paid += premium
+		total.addCost(p.Currency, org, premium*p.Rate)
+	}
+
+	roundTotals(totals)
+
+	return totals, nil
+}
+
+// roundTotals rounds away the error of mixing database and Go arithmetic from the paid hours and labor cost
+func roundTotals(totals []*HoursTotal) {
+	for _, total := range totals {
+		total.Paid = math.Round(total.Paid*100) / 100
+		for code, cost := range total.Costs {
+			total.Costs[code] = math.Round(cost*100) / 100
+		}
+	}
+}
+
+// labelISOWeeks relabels weekly totals, grouped by the date of the Monday starting each week, with their ISO 8601 week
+func labelISOWeeks(totals []*HoursTotal) {
+	for _, total := range totals {
+		if monday, err := time.Parse("2006-01-02", total.Group); err == nil {
+			total.Group = utils.FormatISOWeek(monday)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package models
+
+import (
+	"fmt"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"html"
+	"strings"
+	"time"
+)
+
+// RotationOff marks a day off within a Rotation pattern
+const RotationOff = '-'
+
+const rotationDateFormat = "2006-01-02"
+
+// Rotation struct represents a repeating multi-week schedule, such as a DuPont or Pitman cycle, defined
+// once and expanded into shifts for each member. The Pattern holds one character per day of the cycle,
+// either RotationOff or the Code of one of the rotation's ShiftTypes. Each member works the pattern
+// shifted by their Offset in days, so crews share one pattern while working different days.
+type Rotation struct {
+	ID         string              `gorm:"primaryKey" json:"id"`
+	Name       string              `gorm:"size:50;not null" json:"name"`
+	Pattern    string              `gorm:"size:366;not null" json:"pattern"` //e.g. DD--DDD--DD---
+	Anchor     string              `gorm:"size:10;not null" json:"anchor"`   //date of the first day of the cycle, YYYY-MM-DD
+	Timezone   string              `gorm:"size:64;not null" json:"timezone"` //IANA zone the shift times are in
+	LocationID string              `json:"location_id,omitempty"`            //applied to generated shifts
+	PositionID string              `json:"position_id,omitempty"`            //applied to generated shifts
+	ShiftTypes []RotationShiftType `gorm:"foreignKey:RotationID" json:"shift_types"`
+	Members    []RotationMember    `gorm:"foreignKey:RotationID" json:"members"`
+	CreatedAt  time.Time           `json:"created_at"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+}
+
+// RotationShiftType struct represents the shift worked on the days of a Rotation pattern marked with its Code
+type RotationShiftType struct {
+	RotationID string `gorm:"primaryKey" json:"-"`
+	Code       string `gorm:"primaryKey;size:1" json:"code"`
+	Start      string `gorm:"size:5;not null" json:"start"` //time of day, HH:MM
+	Minutes    int    `gorm:"not null" json:"minutes"`      //length of the shift on the clock
+}
+
+// RotationMember struct represents a User assigned to a slot of a Rotation, working its pattern Offset days later
+type RotationMember struct {
+	RotationID string `gorm:"primaryKey" json:"-"`
+	UserID     string `gorm:"primaryKey" json:"user_id"`
+	Offset     int    `gorm:"not null" json:"offset"`
+}
+
+// Validate checks to ensure all fields of the object are present and valid
+func (r *Rotation) Validate() error {
+	if r.Name == "" {
+		return invalid("name required")
+	}
+
+	if r.Pattern == "" {
+		return invalid("pattern required")
+	}
+
+	if _, err := r.location(); err != nil {
+		return invalid("invalid timezone")
+	}
+
+	if _, err := time.Parse(rotationDateFormat, r.Anchor); err != nil {
+		return invalid("anchor must be a date formatted YYYY-MM-DD")
+	}
+
+	codes := make(map[string]bool, len(r.ShiftTypes))
+	for _, st := range r.ShiftTypes {
+		if len(st.Code) != 1 || st.Code[0] == RotationOff {
+			return invalidf("shift type code %q must be a single character other than %q", st.Code, RotationOff)
+		}
+
+		if codes[st.Code] {
+			return invalidf("shift type code %q defined more than once", st.Code)
+		}
+		codes[st.Code] = true
+
+		if _, err := time.Parse("15:04", st.Start); err != nil {
+			return invalidf("shift type %q start must be a time formatted HH:MM", st.Code)
+		}
+
+		if st.Minutes < 1 {
+			return invalidf("shift type %q must be at least a minute long", st.Code)
+		}
+	}
+
+	for _, day := range r.Pattern {
+		if day != RotationOff && !codes[string(day)] {
+			return invalidf("pattern uses undefined shift type %q", day)
+		}
+	}
+
+	for _, m := range r.Members {
+		if m.UserID == "" {
+			return invalid("member user id required")
+		}
+	}
+
+	return nil
+}
+
+func (r *Rotation) location() (*time.Location, error) {
+	return time.LoadLocation(r.Timezone)
+}
+
+// BeforeCreate hooks GORM and prepares a new object for creation
+func (r *Rotation) BeforeCreate(_ *gorm.DB) error {
+	id, err := nanoid.Nanoid(8)
+	if err != nil {
+		return fmt.Errorf("unable to generate RotationID: %s", err)
+	}
+
+	r.ID = id
+	r.Name = html.EscapeString(strings.TrimSpace(r.Name))
+
+	return nil
+}
+
+// Create attempts to create the Rotation object along with its shift types and members in the database
+func (r *Rotation) Create(db *gorm.DB) error {
+	return db.Create(r).Error
+}
+
+// Delete will attempt to delete the Rotation object from the database
+func (r *Rotation) Delete(db *gorm.DB) error {
+	tx := db.Delete(r)
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected == 0 {
+		return &NotFoundError{Kind: "rotation"}
+	}
+
+	return nil
+}
+
+// AfterDelete hooks GORM to remove the shift types and members of this rotation when it is deleted
+func (r *Rotation) AfterDelete(db *gorm.DB) error {
+	err := db.Where("rotation_id = ?", r.ID).Delete(&RotationShiftType{}).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Where("rotation_id = ?", r.ID).Delete(&RotationMember{}).Error
+}
+
+// SetMembers attempts to replace the members of the Rotation
+func (r *Rotation) SetMembers(db *gorm.DB, members []RotationMember) error {
+	for i := range members {
+		members[i].RotationID = r.ID
+	}
+
+	return transaction(db, func(tx *gorm.DB) error {
+		err := tx.Where("rotation_id = ?", r.ID).Delete(&RotationMember{}).Error
+		if err != nil {
+			return err
+		}
+
+		if len(members) > 0 {
+			err = tx.Create(&members).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		r.Members = members
+
+		return nil
+	})
+}
+
+// Expand returns the shifts worked by the members of the Rotation on the calendar dates from start
+// up to but excluding end. Only the dates are used, the shifts start at the times of day of their
+// types in the rotation's timezone.
+func (r *Rotation) Expand(start, end time.Time) ([]*Shift, error) {
+	loc, err := r.location()
+	if err != nil {
+		return nil, err
+	}
+
+	anchor, err := time.ParseInLocation(rotationDateFormat, r.Anchor, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	types := make(map[byte]RotationShiftType, len(r.ShiftTypes))
+	for _, st := range r.ShiftTypes {
+		types[st.Code[0]] = st
+	}
+
+	first := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	last := dateOf(end)
+	cycle := len(r.Pattern)
+
+	var shifts []*Shift
+
+	for day := first; dateOf(day).Before(last); day = day.AddDate(0, 0, 1) {
+		// Count calendar days rather than hours so daylight saving changes do not shift the cycle
+		since := int(dateOf(day).Sub(dateOf(anchor)).Hours() / 24)
+
+		for _, m := range r.Members {
+			idx := ((since-m.Offset)%cycle + cycle) % cycle
+
+			st, ok := types[r.Pattern[idx]]
+			if !ok {
+				continue
+			}
+
+			// Both ends are wall clock times, so a 22:00 to 06:00 shift spanning a daylight saving
+			// change still ends at 06:00, having lasted 7 or 9 hours
+			clock, _ := time.Parse("15:04", st.Start)
+			begin := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+			end := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute()+st.Minutes, 0, 0, loc)
+
+			shifts = append(shifts, &Shift{
+				UserID:     m.UserID,
+				Start:      begin,
+				End:        end,
+				LocationID: r.LocationID,
+				PositionID: r.PositionID,
+			})
+		}
+	}
+
+	return shifts, nil
+}
+
+// dateOf returns midnight UTC of the calendar date of t
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// ListRotations attempts to return all rows from the Rotations table with their shift types and members ordered by name
+func ListRotations(db *gorm.DB) ([]*Rotation, error) {
+	var rotations []*Rotation
+
+	err := db.Model(&Rotation{}).Preload("ShiftTypes").Preload("Members").Order("name").Find(&rotations).Error
+	if err != nil {
+		return []*Rotation{}, err
+	}
+
+	return rotations, nil
+}
+
+// FindRotationByID attempts to return a row from the Rotations table with the matching Rotation.ID
+func FindRotationByID(db *gorm.DB, rid string) (*Rotation, error) {
+	rotation := &Rotation{}
+	err := db.Preload("ShiftTypes").Preload("Members").First(&rotation, "id = ?", rid).Error
+	if err != nil {
+		return &Rotation{}, notFound("rotation", err)
+	}
+
+	return rotation, nil
+}
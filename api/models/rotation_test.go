@@ -0,0 +1,107 @@
+package models
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestRotationExpandAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	night := &Rotation{
+		Pattern:    "N",
+		Anchor:     "2026-01-01",
+		Timezone:   "America/New_York",
+		ShiftTypes: []RotationShiftType{{Code: "N", Start: "22:00", Minutes: 8 * 60}},
+		Members:    []RotationMember{{UserID: "u1"}},
+	}
+
+	tests := []struct {
+		name  string
+		date  string
+		start string
+		end   string
+		hours float64
+	}{
+		{"before spring forward", "2026-03-06", "2026-03-06 22:00", "2026-03-07 06:00", 8},
+		{"spring forward", "2026-03-07", "2026-03-07 22:00", "2026-03-08 06:00", 7},
+		{"after spring forward", "2026-03-08", "2026-03-08 22:00", "2026-03-09 06:00", 8},
+		{"before fall back", "2026-10-30", "2026-10-30 22:00", "2026-10-31 06:00", 8},
+		{"fall back", "2026-10-31", "2026-10-31 22:00", "2026-11-01 06:00", 9},
+		{"after fall back", "2026-11-01", "2026-11-01 22:00", "2026-11-02 06:00", 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day, _ := time.Parse("2006-01-02", tt.date)
+
+			shifts, err := night.Expand(day, day.AddDate(0, 0, 1))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(shifts) != 1 {
+				t.Fatalf("expanded %d shifts, want 1", len(shifts))
+			}
+
+			shift := shifts[0]
+
+			if got := shift.Start.In(loc).Format("2006-01-02 15:04"); got != tt.start {
+				t.Errorf("start %s, want %s", got, tt.start)
+			}
+
+			if got := shift.End.In(loc).Format("2006-01-02 15:04"); got != tt.end {
+				t.Errorf("end %s, want %s", got, tt.end)
+			}
+
+			if got := shift.End.Sub(shift.Start).Hours(); got != tt.hours {
+				t.Errorf("lasted %v hours, want %v", got, tt.hours)
+			}
+		})
+	}
+}
+
+func TestRotationExpandKeepsCycleAcrossDST(t *testing.T) {
+	rotation := &Rotation{
+		Pattern:    "D-",
+		Anchor:     "2026-03-01",
+		Timezone:   "America/New_York",
+		ShiftTypes: []RotationShiftType{{Code: "D", Start: "09:00", Minutes: 8 * 60}},
+		Members:    []RotationMember{{UserID: "u1"}, {UserID: "u2", Offset: 1}},
+	}
+
+	start, _ := time.Parse("2006-01-02", "2026-03-06")
+	end, _ := time.Parse("2006-01-02", "2026-03-11")
+
+	shifts, err := rotation.Expand(start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"u2 2026-03-06 09:00",
+		"u1 2026-03-07 09:00",
+		"u2 2026-03-08 09:00",
+		"u1 2026-03-09 09:00",
+		"u2 2026-03-10 09:00",
+	}
+
+	var got []string
+	for _, shift := range shifts {
+		got = append(got, shift.UserID+" "+shift.Start.Format("2006-01-02 15:04"))
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("expanded %v, want %v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("shift %d is %s, want %s", i, got[i], want[i])
+		}
+	}
+}
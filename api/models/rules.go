@@ -0,0 +1,242 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	RuleInsufficientRest = "insufficient_rest"
+	RuleOvertimeCap      = "overtime_cap"
+)
+
+// Rule modes
+const (
+	RuleOff     = ""        // the rule is not checked
+	RuleShadow  = "shadow"  // violations are recorded but the shift is still saved
+	RuleEnforce = "enforce" // shifts violating the rule are refused
+)
+
+var ErrInvalidRuleMode = errors.New("rule mode must be empty, shadow or enforce")
+
+// SchedulingRules are checked against the other shifts of a user whenever a shift assigned to them is
+// saved. Each rule runs in its own mode, so a new rule can be run in shadow mode to gauge how many shifts
+// would be refused before it is enforced.
+type SchedulingRules struct {
+	MinRest     time.Duration // shortest break between the shifts of a user
+	MinRestMode string
+
+	MaxWeeklyHours     float64 // most hours a user may be scheduled for in a week beginning Monday
+	MaxWeeklyHoursMode string
+}
+
+// Validate checks to ensure all fields of the object are valid
+func (r SchedulingRules) Validate() error {
+	for _, mode := range []string{r.MinRestMode, r.MaxWeeklyHoursMode} {
+		if mode != RuleOff && mode != RuleShadow && mode != RuleEnforce {
+			return ErrInvalidRuleMode
+		}
+	}
+
+	return nil
+}
+
+// RuleViolation struct represents a shift saved despite violating a rule running in shadow mode
+type RuleViolation struct {
+	ID        string    `gorm:"primaryKey" json:"id"`
+	Rule      string    `gorm:"size:30;not null;index" json:"rule"`
+	ShiftID   string    `gorm:"not null;index" json:"shift_id"`
+	UserID    string    `gorm:"not null;index" json:"user_id"`
+	Message   string    `gorm:"size:255;not null" json:"message"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// BeforeCreate hooks GORM and prepares a new object for creation
+func (v *RuleViolation) BeforeCreate(_ *gorm.DB) error {
+	id, err := nanoid.Nanoid(10)
+	if err != nil {
+		return fmt.Errorf("unable to generate RuleViolationID: %s", err)
+	}
+
+	v.ID = id
+
+	return nil
+}
+
+// checkRules checks the shift against the SchedulingRules of the database, returning the error of the first enforced rule
+// it violates and keeping the violations of rules in shadow mode to be recorded once the shift is saved
+func (s *Shift) checkRules(db *gorm.DB) error {
+	r := CurrentSettings(db).SchedulingRules
+	s.violations = nil
+
+	if r.MinRestMode != RuleOff && r.MinRest > 0 {
+		err := s.checkRest(db, r.MinRest)
+		if err != nil {
+			if err = s.violated(r.MinRestMode, err); err != nil {
+				return err
+			}
+		}
+	}
+
+	if r.MaxWeeklyHoursMode != RuleOff && r.MaxWeeklyHours > 0 {
+		err := s.checkWeeklyHours(db, r.MaxWeeklyHours)
+		if err != nil {
+			if err = s.violated(r.MaxWeeklyHoursMode, err); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// violated returns the violation when the rule is enforced, otherwise keeping it to be recorded
+func (s *Shift) violated(mode string, err error) error {
+	var violation *ShiftLimitError
+	if mode == RuleEnforce || !errors.As(err, &violation) {
+		return err
+	}
+
+	s.violations = append(s.violations, &RuleViolation{
+		Rule:    violation.Code,
+		UserID:  s.UserID,
+		Message: violation.Message,
+	})
+
+	return nil
+}
+
+// checkRest returns a ShiftLimitError if the user has another shift ending or starting within rest of the shift
+func (s *Shift) checkRest(db *gorm.DB, rest time.Duration) error {
+	shifts, err := ListShifts(db,
+		FilterUserID(s.UserID),
+		FilterStartsBefore(s.End.Add(rest)),
+		FilterEndsAfter(s.Start.Add(-rest)),
+		func(db *gorm.DB) {
+			if s.ID != "" {
+				db.Where("id <> ?", s.ID)
+			}
+		},
+		WithLimit(1),
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(shifts) > 0 {
+		return &ShiftLimitError{
+			Code:    RuleInsufficientRest,
+			Message: fmt.Sprintf("shifts of the same user must be at least %s apart", rest),
+		}
+	}
+
+	return nil
+}
+
+// checkWeeklyHours returns a ShiftLimitError if the user would be scheduled for more than max hours in
+// the week the shift starts in, counting the shifts which start in that week
+func (s *Shift) checkWeeklyHours(db *gorm.DB, max float64) error {
+	day := s.Start
+	start := time.Date(day.Year(), day.Month(), day.Day()-(int(day.Weekday())+6)%7, 0, 0, 0, 0, day.Location())
+
+	shifts, err := ListShifts(db,
+		FilterUserID(s.UserID),
+		FilterStart(start),
+		FilterStartsBefore(start.AddDate(0, 0, 7)),
+		func(db *gorm.DB) {
+			if s.ID != "" {
+				db.Where("id <> ?", s.ID)
+			}
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	hours := s.End.Sub(s.Start).Hours()
+	for _, shift := range shifts {
+		hours += shift.End.Sub(shift.Start).Hours()
+	}
+
+	if hours > max {
+		return &ShiftLimitError{
+			Code:    RuleOvertimeCap,
+			Message: fmt.Sprintf("user cannot be scheduled for more than %g hours in a week", max),
+		}
+	}
+
+	return nil
+}
+
+// AfterSave hooks GORM to record the violations of rules in shadow mode by the saved shift
+func (s *Shift) AfterSave(db *gorm.DB) error {
+	if len(s.violations) == 0 {
+		return nil
+	}
+
+	for _, v := range s.violations {
+		v.ShiftID = s.ID
+	}
+
+	err := db.Session(&gorm.Session{NewDB: true}).Create(&s.violations).Error
+	s.violations = nil
+
+	return err
+}
+
+// RuleViolationTotal is the number of recorded violations of a rule
+type RuleViolationTotal struct {
+	Rule       string `json:"rule"`
+	Violations int    `json:"violations"`
+	Shifts     int    `json:"shifts"`
+	Users      int    `json:"users"`
+}
+
+// ListRuleViolations attempts to return the violations recorded within the window, most recent first,
+// restricted to the specified rule when not empty, along with the totals of each rule
+func ListRuleViolations(db *gorm.DB, start, end time.Time, rule string, limit int) ([]*RuleViolation, []*RuleViolationTotal, error) {
+	query := func() *gorm.DB {
+		tx := db.Model(&RuleViolation{})
+
+		if !start.IsZero() {
+			tx.Where("created_at >= ?", start)
+		}
+
+		if !end.IsZero() {
+			tx.Where("created_at < ?", end)
+		}
+
+		if rule != "" {
+			tx.Where("rule = ?", rule)
+		}
+
+		return tx
+	}
+
+	if limit < 1 {
+		limit = -1
+	}
+
+	violations := []*RuleViolation{}
+
+	err := query().Order("created_at DESC").Limit(limit).Find(&violations).Error
+	if err != nil {
+		return []*RuleViolation{}, []*RuleViolationTotal{}, err
+	}
+
+	totals := []*RuleViolationTotal{}
+
+	err = query().
+		Select("rule, COUNT(*) AS violations, COUNT(DISTINCT shift_id) AS shifts, COUNT(DISTINCT user_id) AS users").
+		Group("rule").
+		Order("rule").
+		Scan(&totals).Error
+	if err != nil {
+		return []*RuleViolation{}, []*RuleViolationTotal{}, err
+	}
+
+	return violations, totals, nil
+}
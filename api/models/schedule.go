@@ -0,0 +1,164 @@
+package models
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// ScheduleDay holds the shifts of a User starting on a single date
+type ScheduleDay struct {
+	Date   string   `json:"date"` //YYYY-MM-DD
+	Shifts []*Shift `json:"shifts"`
+}
+
+// ScheduleRow holds the shifts of a User for each day of a schedule, including days without any
+type ScheduleRow struct {
+	UserID string         `json:"user_id"`
+	Name   string         `json:"name"`
+	TeamID string         `json:"team_id,omitempty"`
+	Days   []*ScheduleDay `json:"days"`
+}
+
+// ScheduleQuery selects the users and shifts included in a schedule grid
+type ScheduleQuery struct {
+	Start    time.Time      // midnight of the first day
+	Days     int            // number of days from Start
+	Location *time.Location // zone the days are in
+	Statuses []string       // shift statuses to include, all when empty
+	TeamID   string         // restrict to members of the team when not empty
+	UserID   string         // restrict to the user when not empty
+}
+
+// scheduleCell is a row of the users and shifts join, the shift columns are nil for users with no shifts
+type scheduleCell struct {
+	UserID     string
+	UserName   string
+	TeamID     string
+	ShiftID    *string
+	Start      *time.Time
+	End        *time.Time
+	Status     *string
+	Capacity   *int
+	LocationID *string
+	PositionID *string
+	Color      *string
+	Visibility *string
+	Metadata   Metadata
+	CreatedAt  *time.Time
+	UpdatedAt  *time.Time
+}
+
+// ListSchedule returns a row per user with their shifts grouped by the day they start on. Users with
+// no shifts in the period are included so the grid is complete, and everything is read in one query.
+func ListSchedule(db *gorm.DB, q ScheduleQuery) ([]*ScheduleRow, error) {
+	loc := q.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	start := time.Date(q.Start.Year(), q.Start.Month(), q.Start.Day(), 0, 0, 0, 0, loc)
+	end := start.AddDate(0, 0, q.Days)
+
+	// The shift conditions belong to the join so that users without matching shifts still appear
+	join := fmt.Sprintf("LEFT JOIN shifts ON shifts.user_id = users.id AND shifts.deleted_at IS NULL AND %s >= ? AND %s < ?",
+		quote(db, "shifts.start"), quote(db, "shifts.start"))
+	args := []interface{}{start, end}
+
+	if len(q.Statuses) > 0 {
+		join += " AND shifts.status IN ?"
+		args = append(args, q.Statuses)
+	}
+
+	tx := db.Model(&User{}).
+		Select(fmt.Sprintf("users.id AS user_id, users.name AS user_name, users.team_id AS team_id, shifts.id AS shift_id, "+
+			"%s AS start, %s AS %s, shifts.status AS status, shifts.capacity AS capacity, shifts.location_id AS location_id, "+
+			"shifts.position_id AS position_id, shifts.color AS color, shifts.visibility AS visibility, shifts.metadata AS metadata, shifts.created_at AS created_at, shifts.updated_at AS updated_at",
+			quote(db, "shifts.start"), quote(db, "shifts.end"), quote(db, "end"))).
+		Joins(join, args...).
+		Order(fmt.Sprintf("users.name, users.id, %s", quote(db, "shifts.start")))
+
+	if q.TeamID != "" {
+		tx.Where("users.team_id = ?", q.TeamID)
+	}
+
+	if q.UserID != "" {
+		tx.Where("users.id = ?", q.UserID)
+	}
+
+	var cells []scheduleCell
+	err := tx.Scan(&cells).Error
+	if err != nil {
+		return []*ScheduleRow{}, err
+	}
+
+	rows := []*ScheduleRow{}
+	var row *ScheduleRow
+
+	for _, cell := range cells {
+		// Cells are ordered by user, so a new user starts a new row
+		if row == nil || row.UserID != cell.UserID {
+			row = &ScheduleRow{
+				UserID: cell.UserID,
+				Name:   cell.UserName,
+				TeamID: cell.TeamID,
+				Days:   make([]*ScheduleDay, q.Days),
+			}
+
+			for i := range row.Days {
+				row.Days[i] = &ScheduleDay{
+					Date:   start.AddDate(0, 0, i).Format("2006-01-02"),
+					Shifts: []*Shift{},
+				}
+			}
+
+			rows = append(rows, row)
+		}
+
+		if cell.ShiftID == nil {
+			continue
+		}
+
+		shift := &Shift{
+			ID:         *cell.ShiftID,
+			UserID:     cell.UserID,
+			Start:      *cell.Start,
+			End:        *cell.End,
+			Status:     deref(cell.Status),
+			LocationID: deref(cell.LocationID),
+			PositionID: deref(cell.PositionID),
+			Color:      deref(cell.Color),
+			Visibility: deref(cell.Visibility),
+			Metadata:   cell.Metadata,
+		}
+
+		if cell.Capacity != nil {
+			shift.Capacity = *cell.Capacity
+		}
+
+		if cell.CreatedAt != nil {
+			shift.CreatedAt = *cell.CreatedAt
+		}
+
+		if cell.UpdatedAt != nil {
+			shift.UpdatedAt = *cell.UpdatedAt
+		}
+
+		// Count calendar days from the start of the schedule in its zone
+		local := shift.Start.In(loc)
+		day := int(dateOf(local).Sub(dateOf(start)).Hours() / 24)
+		if day >= 0 && day < q.Days {
+			row.Days[day].Shifts = append(row.Days[day].Shifts, shift)
+		}
+	}
+
+	return rows, nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
@@ -0,0 +1,187 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Models returns an instance of every model persisted in the database, in migration order
+func Models() []interface{} {
+	return []interface{}{
+		&User{}, &Shift{}, &Registration{}, &Preference{}, &Reminder{}, &Signup{}, &WaitlistEntry{},
+		&Team{}, &CalendarFeed{}, &Location{}, &Position{}, &CoverageRequirement{},
+		&Rotation{}, &RotationShiftType{}, &RotationMember{}, &Handover{},
+		&Group{}, &GroupMember{},
+		&Broadcast{}, &BroadcastDelivery{},
+		&Holiday{}, &Differential{}, &PayRate{}, &ShiftChange{},
+		&Bidding{}, &ShiftBid{},
+		&ClockEntry{}, &Attendance{}, &Discrepancy{},
+		&Delegation{}, &Certification{}, &CustomField{},
+		&LeavePolicy{}, &LeaveAccount{}, &TimeOff{},
+		&EmailChange{}, &Invitation{},
+		&AuditEntry{}, &AuditSubject{}, &RuleViolation{},
+		&IdempotentRequest{}, &SigningKey{},
+		&SchemaVersion{},
+	}
+}
+
+// SchemaVersion records each distinct schema the database has been migrated to. The version is
+// a fingerprint of the tables and columns of every model, so it changes whenever a model does.
+type SchemaVersion struct {
+	Version   string    `gorm:"primaryKey;size:16" json:"version"`
+	Tables    int       `gorm:"not null" json:"tables"`
+	AppliedAt time.Time `gorm:"not null" json:"applied_at"`
+}
+
+// Migrate creates or updates the tables of all models, along with any extra models registered by an application
+// embedding the server, and records the resulting schema version. The destructive changes the migration makes are
+// returned, and unless allowDestructive is set the migration is refused with a *DestructiveMigrationError when there are any.
+func Migrate(db *gorm.DB, allowDestructive bool, extra ...interface{}) ([]DestructiveChange, error) {
+	all := append(Models(), extra...)
+
+	changes, err := CheckMigration(db, all...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(changes) > 0 && !allowDestructive {
+		return changes, &DestructiveMigrationError{Changes: changes}
+	}
+
+	err = db.AutoMigrate(all...)
+	if err != nil {
+		return changes, err
+	}
+
+	version, err := Fingerprint(db, all...)
+	if err != nil {
+		return changes, err
+	}
+
+	// Only the first migration to a version is recorded
+	return changes, db.Where(SchemaVersion{Version: version}).
+		Attrs(SchemaVersion{Tables: len(all), AppliedAt: time.Now()}).
+		FirstOrCreate(&SchemaVersion{}).Error
+}
+
+// DestructiveChange is a change to an existing column which loses data, or leaves it behind
+type DestructiveChange struct {
+	Table  string
+	Column string
+	Reason string
+}
+
+func (c DestructiveChange) String() string {
+	return fmt.Sprintf("%s.%s: %s", c.Table, c.Column, c.Reason)
+}
+
+// DestructiveMigrationError is returned when a migration would make destructive changes without them being allowed
+type DestructiveMigrationError struct {
+	Changes []DestructiveChange
+}
+
+func (e *DestructiveMigrationError) Error() string {
+	changes := make([]string, 0, len(e.Changes))
+	for _, c := range e.Changes {
+		changes = append(changes, c.String())
+	}
+
+	return fmt.Sprintf("migration would make %d destructive change(s), allow destructive migrations to apply them: %s",
+		len(e.Changes), strings.Join(changes, "; "))
+}
+
+// CheckMigration compares the existing tables with the models and returns the changes migrating them would
+// make which lose data. Columns no longer in a model are left in place by the migration but are reported
+// as their data is abandoned, and columns shrunk to a smaller size or precision may be truncated.
+func CheckMigration(db *gorm.DB, models ...interface{}) ([]DestructiveChange, error) {
+	var changes []DestructiveChange
+
+	migrator := db.Migrator()
+
+	for _, model := range models {
+		s, err := schema.Parse(model, &sync.Map{}, db.NamingStrategy)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse schema of %T: %s", model, err)
+		}
+
+		// New tables have nothing to lose
+		if !migrator.HasTable(s.Table) {
+			continue
+		}
+
+		columns, err := migrator.ColumnTypes(model)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read columns of %s: %s", s.Table, err)
+		}
+
+		for _, column := range columns {
+			field := s.LookUpField(column.Name())
+			if field == nil || field.DBName == "" || field.IgnoreMigration {
+				changes = append(changes, DestructiveChange{s.Table, column.Name(), "column is no longer in the model"})
+				continue
+			}
+
+			if length, ok := column.Length(); ok && length > 0 && field.Size > 0 && int64(field.Size) < length {
+				changes = append(changes, DestructiveChange{s.Table, column.Name(),
+					fmt.Sprintf("size narrowed from %d to %d", length, field.Size)})
+			}
+
+			if precision, scale, ok := column.DecimalSize(); ok {
+				if field.Precision > 0 && int64(field.Precision) < precision {
+					changes = append(changes, DestructiveChange{s.Table, column.Name(),
+						fmt.Sprintf("precision narrowed from %d to %d", precision, field.Precision)})
+				} else if field.Scale > 0 && int64(field.Scale) < scale {
+					changes = append(changes, DestructiveChange{s.Table, column.Name(),
+						fmt.Sprintf("scale narrowed from %d to %d", scale, field.Scale)})
+				}
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// Fingerprint returns a short hash identifying the table and column definitions of the models
+func Fingerprint(db *gorm.DB, models ...interface{}) (string, error) {
+	var defs []string
+
+	for _, model := range models {
+		s, err := schema.Parse(model, &sync.Map{}, db.NamingStrategy)
+		if err != nil {
+			return "", fmt.Errorf("unable to parse schema of %T: %s", model, err)
+		}
+
+		for _, field := range s.Fields {
+			if field.DBName == "" {
+				continue
+			}
+
+			defs = append(defs, fmt.Sprintf("%s.%s:%s:%d:%t:%t",
+				s.Table, field.DBName, field.DataType, field.Size, field.NotNull, field.PrimaryKey))
+		}
+	}
+
+	sort.Strings(defs)
+
+	sum := sha256.Sum256([]byte(strings.Join(defs, "\n")))
+
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// CurrentSchemaVersion attempts to return the most recently applied SchemaVersion
+func CurrentSchemaVersion(db *gorm.DB) (*SchemaVersion, error) {
+	version := &SchemaVersion{}
+	err := db.Order("applied_at DESC").First(&version).Error
+	if err != nil {
+		return &SchemaVersion{}, err
+	}
+
+	return version, nil
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// The types of the records a search returns
+const (
+	SearchUser  = "user"
+	SearchShift = "shift"
+)
+
+// SearchResult struct represents a record matching a search, of the Type given, with the field it matched on
+type SearchResult struct {
+	Type  string          `json:"type"`
+	Match string          `json:"match"` //name of a user, tag or note of a shift
+	User  *DirectoryEntry `json:"user,omitempty"`
+	Shift *Shift          `json:"shift,omitempty"`
+}
+
+// SearchUsers attempts to return up to limit results for the active users whose name contains the search, ordered
+// by name, with the positions of their published shifts ending after since as the directory lists them
+func SearchUsers(db *gorm.DB, search string, limit int, since time.Time) ([]*SearchResult, error) {
+	results := []*SearchResult{}
+
+	entries, err := listDirectory(db, search, "", since, limit)
+	if err != nil {
+		return results, err
+	}
+
+	for _, entry := range entries {
+		results = append(results, &SearchResult{Type: SearchUser, Match: "name", User: entry})
+	}
+
+	return results, nil
+}
+
+// SearchShifts attempts to return up to limit results for the shifts matching the filters which have a tag, or a
+// handover note, containing the search, latest first
+func SearchShifts(db *gorm.DB, search string, limit int, opts ...ShiftFilterOption) ([]*SearchResult, error) {
+	results := []*SearchResult{}
+
+	// Wildcards in the search are matched literally, escaped with a character every dialect accepts
+	escaper := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	pattern := "%" + escaper.Replace(strings.ToLower(search)) + "%"
+
+	note := func(column string) string {
+		return fmt.Sprintf("LOWER(handovers.%s) LIKE @pattern ESCAPE '!'", column)
+	}
+
+	opts = append(opts,
+		func(db *gorm.DB) {
+			db.Where(fmt.Sprintf(`(LOWER(shifts.tags) LIKE @pattern ESCAPE '!' OR EXISTS (SELECT 1 FROM handovers
+				WHERE handovers.shift_id = shifts.id AND (%s OR %s OR %s)))`,
+				note("summary"), note("open_issues"), note("follow_ups")),
+				map[string]interface{}{"pattern": pattern})
+			db.Order("start DESC, id")
+		},
+		WithLimit(limit),
+	)
+
+	shifts, err := ListShifts(db, opts...)
+	if err != nil {
+		return results, err
+	}
+
+	search = strings.ToLower(search)
+
+	for _, shift := range shifts {
+		match := "note"
+		for _, tag := range shift.Tags {
+			if strings.Contains(strings.ToLower(tag), search) {
+				match = "tag"
+				break
+			}
+		}
+
+		results = append(results, &SearchResult{Type: SearchShift, Match: match, Shift: shift})
+	}
+
+	return results, nil
+}
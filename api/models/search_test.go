@@ -0,0 +1,83 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSearchUsers(t *testing.T) {
+	db := testDB(t)
+
+	users := map[string]*User{}
+	for _, name := range []string{"amber", "ambrose", "cambria", "ember", "rambo_x", "zamboni"} {
+		user := &User{Name: name, Password: "password" + name, Role: "user"}
+		err := user.Create(db)
+		if err != nil {
+			t.Fatalf("could not create user %s: %s", name, err)
+		}
+
+		users[name] = user
+	}
+
+	position := &Position{Name: "medic"}
+	err := position.Create(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Minute)
+	for _, name := range []string{"ambrose", "zamboni"} {
+		shift := &Shift{UserID: users[name].ID, PositionID: position.ID, Start: start, End: start.Add(8 * time.Hour)}
+		err = shift.Create(db)
+		if err != nil {
+			t.Fatalf("could not create shift of %s: %s", name, err)
+		}
+
+		err = db.Model(shift).Update("status", ShiftPublished).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		search string
+		limit  int
+		want   []string
+	}{
+		{"every match", "amb", 0, []string{"amber", "ambrose", "cambria", "rambo_x", "zamboni"}},
+		{"limited", "amb", 2, []string{"amber", "ambrose"}},
+		{"limit beyond matches", "emb", 10, []string{"ember"}},
+		{"wildcard taken literally", "o_", 0, []string{"rambo_x"}},
+		{"percent taken literally", "%", 0, nil},
+		{"no match", "xyz", 5, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := SearchUsers(db, tt.search, tt.limit, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, r := range results {
+				got = append(got, r.User.Name)
+
+				want := []string{}
+				if r.User.Name == "ambrose" || r.User.Name == "zamboni" {
+					want = []string{"medic"}
+				}
+
+				if !reflect.DeepEqual(r.User.Positions, want) {
+					t.Errorf("%s: got positions %v, want %v", r.User.Name, r.User.Positions, want)
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
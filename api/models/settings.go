@@ -0,0 +1,80 @@
+package models
+
+import (
+	"github.com/btnmasher/shiftr/events"
+	"github.com/btnmasher/shiftr/i18n"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// Settings are the configuration the models apply to the changes made through a database handle, kept with
+// the handle by Configure so that each server embedded in one process applies its own. Zero values apply
+// the defaults.
+type Settings struct {
+	ShiftLimits     ShiftLimits     // checked when validating shifts
+	SchedulingRules SchedulingRules // checked when saving shifts
+	PunchRounding   PunchRounding   // applied when computing worked hours
+	Currency        string          // ISO 4217 code of the currency the organization pays in, DefaultCurrency when empty
+	AvatarBaseURL   string          // URL avatars are served from, /avatars/ when empty
+	EventBus        *events.Bus     // changes to shifts are published on, nil publishes none
+	Catalog         *i18n.Catalog   // locales are negotiated with, nil uses the bundled translations
+}
+
+// settingsPlugin is the name the Settings of a database handle are kept under among its gorm plugins
+const settingsPlugin = "shiftr:settings"
+
+// settings keeps the Settings of a database handle as a gorm plugin, which every session and transaction
+// started from the handle carries along
+type settings struct {
+	Settings
+}
+
+// Name implements gorm.Plugin
+func (*settings) Name() string {
+	return settingsPlugin
+}
+
+// Initialize implements gorm.Plugin
+func (*settings) Initialize(*gorm.DB) error {
+	return nil
+}
+
+// Configure returns a handle of the database applying the Settings, as do the sessions and transactions
+// started from it. Other handles of the database keep their own, so that several servers may share it.
+func Configure(db *gorm.DB, s Settings) *gorm.DB {
+	tx := db.Session(&gorm.Session{NewDB: true})
+
+	// The session holds its own copy of the configuration of the database, but shares its plugins
+	plugins := make(map[string]gorm.Plugin, len(tx.Plugins)+1)
+	for name, plugin := range tx.Plugins {
+		plugins[name] = plugin
+	}
+
+	plugins[settingsPlugin] = &settings{Settings: s}
+	tx.Plugins = plugins
+
+	return tx
+}
+
+// CurrentSettings returns the Settings applied through the database handle, with the defaults filled in
+func CurrentSettings(db *gorm.DB) Settings {
+	var s Settings
+	if plugin, ok := db.Config.Plugins[settingsPlugin].(*settings); ok {
+		s = plugin.Settings
+	}
+
+	if s.Currency == "" {
+		s.Currency = DefaultCurrency
+	}
+
+	if s.AvatarBaseURL == "" {
+		s.AvatarBaseURL = "/avatars/"
+	}
+
+	return s
+}
+
+// AvatarURL returns the URL the avatar stored under the key is served from
+func (s Settings) AvatarURL(key string) string {
+	return strings.TrimRight(s.AvatarBaseURL, "/") + "/" + strings.TrimPrefix(key, AvatarPrefix)
+}
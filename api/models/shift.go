@@ -1,39 +1,134 @@
 package models
 
 import (
-	"errors"
 	"fmt"
+	"github.com/btnmasher/shiftr/opaque"
 	"github.com/jkomyno/nanoid"
 	"gorm.io/gorm"
+	"regexp"
+	"strings"
 	"time"
 )
 
 // Shift struct represents a timespan of a work shift object with a Unique ID, Start and End times,
 // and a UserID which the shift belongs to.
+// A Shift with a Capacity and no UserID is an event which users sign up for themselves.
 type Shift struct {
-	ID        string    `gorm:"primaryKey" json:"id"`
-	Start     time.Time `gorm:"not null" json:"start"`
-	End       time.Time `gorm:"not null" json:"end"`
-	UserID    string    `gorm:"not null" json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           string    `gorm:"primaryKey;index:idx_shifts_start_id,priority:2" json:"id"`
+	Start        time.Time `gorm:"not null;index:idx_shifts_start_id,priority:1" json:"start"`
+	End          time.Time `gorm:"not null" json:"end"`
+	UserID       string    `gorm:"not null" json:"user_id"`
+	Capacity     int       `gorm:"not null;default:0" json:"capacity,omitempty"`             //event signup slots
+	Signups      int       `gorm:"-" json:"signups,omitempty"`                               //event signups taken
+	Status       string    `gorm:"size:10;not null;default:'published';index" json:"status"` //lifecycle: draft or pending, published, archived
+	LocationID   string    `gorm:"index" json:"location_id,omitempty"`
+	PositionID   string    `gorm:"index" json:"position_id,omitempty"`
+	Color        string    `gorm:"size:7" json:"color,omitempty"`       //display color, #RRGGBB
+	Visibility   string    `gorm:"size:10" json:"visibility,omitempty"` //to other users: full or anonymous, the team's when empty
+	Metadata     Metadata  `json:"metadata,omitempty"`                  //arbitrary integration data
+	Tags         Tags      `gorm:"size:400" json:"tags,omitempty"`      //labels to filter by
+	Holiday      string    `gorm:"-" json:"holiday,omitempty"`          //name of the holiday the shift starts on
+	Differential float64   `gorm:"-" json:"differential,omitempty"`     //effective pay differential multiplier
+	Anonymous    bool      `gorm:"-" json:"anonymous,omitempty"`        //shown as a covered block to the viewer
+	User         *User     `gorm:"-" json:"user,omitempty"`             //worker, when included by the request
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	// Cancelled shifts are soft deleted so they remain reportable
+	CancelledAt  gorm.DeletedAt `gorm:"column:deleted_at;index" json:"cancelled_at"`
+	CancelReason string         `gorm:"size:255" json:"cancel_reason,omitempty"`
+	CancelledBy  string         `json:"-"` //User.ID of whoever cancelled it, set before Cancel
+
+	// Precondition is the ETag the stored shift must still have for Update, Cancel or Restore to apply, empty for none
+	Precondition string `gorm:"-" json:"-"`
+
+	violations   []*RuleViolation // of rules in shadow mode, recorded once saved
+	formerUserID string           // worker before the update in progress, told of the change when reassigned
 }
 
-// Validate checks to ensure all fields of the object are present and valid
+// colorPattern matches a hex color such as #1E90FF
+var colorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+const (
+	ShiftDraft     = "draft"
+	ShiftPending   = "pending" //created by a user and awaiting approval
+	ShiftPublished = "published"
+	ShiftArchived  = "archived"
+)
+
+// Validate checks to ensure all fields of the object are present and valid, returning a *FieldErrors listing
+// each invalid field
 func (s *Shift) Validate() error {
-	if s.Start.IsZero() {
-		return errors.New("start time required")
-	}
+	return allInvalid(s.checks())
+}
 
-	if s.End.IsZero() {
-		return errors.New("end time required")
-	}
+// checks returns the checks of each field of the Shift, in the order Validate makes them
+func (s *Shift) checks() []fieldCheck {
+	return []fieldCheck{
+		{"start", func() error {
+			if s.Start.IsZero() {
+				return invalid("start time required")
+			}
+
+			return nil
+		}},
+		{"end", func() error {
+			if s.End.IsZero() {
+				return invalid("end time required")
+			}
+
+			if s.Start.After(s.End) {
+				return invalid("shift start time must precede shift end time")
+			}
+
+			return nil
+		}},
+		{"color", func() error {
+			if s.Color != "" && !colorPattern.MatchString(s.Color) {
+				return invalid("color must be formatted #RRGGBB")
+			}
+
+			return nil
+		}},
+		{"visibility", func() error {
+			if !validVisibility(s.Visibility) {
+				return invalid("visibility must be full or anonymous")
+			}
+
+			return nil
+		}},
+		{"metadata", func() error {
+			return s.Metadata.Validate()
+		}},
+		{"tags", func() error {
+			return s.Tags.Validate()
+		}},
+		{"capacity", func() error {
+			if s.Capacity < 0 {
+				return invalid("capacity cannot be negative")
+			}
+
+			if s.Capacity > 0 && s.UserID != "" {
+				return invalid("event shifts with a capacity cannot be assigned to a user")
+			}
+
+			return nil
+		}},
+		{"status", func() error {
+			switch s.Status {
+			case "", ShiftDraft, ShiftPending, ShiftPublished, ShiftArchived:
+			default:
+				return invalid("invalid status")
+			}
 
-	if s.Start.After(s.End) {
-		return errors.New("shift start time must precede shift end time")
+			return nil
+		}},
 	}
+}
 
-	return nil
+// IsEvent reports whether the shift is an event which users sign up for themselves
+func (s *Shift) IsEvent() bool {
+	return s.Capacity > 0
 }
 
 // BeforeCreate hooks GORM and prepares a new object for creation
@@ -45,39 +140,43 @@ func (s *Shift) BeforeCreate(_ *gorm.DB) error {
 
 	s.ID = id
 
+	if s.Status == "" {
+		s.Status = ShiftPublished
+	}
+
 	return nil
 }
 
 // BeforeSave hooks GORM to run necessary checks before saving the object
 func (s *Shift) BeforeSave(db *gorm.DB) error {
+	return s.checkSchedule(db)
+}
 
-	// Fetch all shifts that fall within the new shift's time span
-	shifts, err := ListShifts(db,
-		FilterUserID(s.UserID),
-		FilterStart(s.Start),
-		FilterEnd(s.End),
-	)
+// checkSchedule checks that the worker of the shift is free, certified and permitted by the scheduling rules to
+// work it, returning an *OverlapError listing the shifts it intersects or the ShiftLimitError of what it violates
+func (s *Shift) checkSchedule(db *gorm.DB) error {
+
+	// Unassigned shifts cannot overlap anyone
+	if s.UserID == "" {
+		return nil
+	}
 
+	// Fetch all other shifts of the user intersecting the new shift's time span
+	conflicts, err := s.Overlapping(db)
 	if err != nil {
 		return err
 	}
 
-	overlap := false
-
-	// Find overlapping shifts
-	for _, shift := range shifts {
-		if shift.End.After(s.Start) && shift.Start.Before(s.End) {
-			if shift.ID != s.ID {
-				overlap = true
-			}
-		}
+	if len(conflicts) > 0 {
+		return &OverlapError{Conflicts: conflicts}
 	}
 
-	if overlap {
-		return errors.New("shift timespan cannot intersect other shifts for the same user")
+	err = s.checkCertification(db)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return s.checkRules(db)
 }
 
 // Create attempts to create the Shift object in the database
@@ -90,44 +189,177 @@ func (s *Shift) Create(db *gorm.DB) error {
 	return nil
 }
 
-// Update will attempt to update the current Shift object in the database
+// Update will attempt to update the current Shift object in the database. Changes to the time or worker of a
+// published shift are recorded to measure the stability of the schedule. ErrStale is returned when the Shift has a
+// Precondition which the stored shift no longer matches.
 func (s *Shift) Update(db *gorm.DB) error {
+	return transaction(db, func(tx *gorm.DB) error {
+		before := &Shift{}
+
+		err := tx.First(before, "id = ?", s.ID).Error
+		if err != nil {
+			return err
+		}
+
+		var version time.Time
+		if s.Precondition != "" {
+			if before.ETag() != s.Precondition {
+				return ErrStale
+			}
+
+			version = before.UpdatedAt
+		}
+
+		s.formerUserID = before.UserID
+		defer func() { s.formerUserID = "" }()
+
+		err = s.update(tx, version)
+		if err != nil {
+			return err
+		}
+
+		if kind := changeKind(before, s); kind != "" {
+			return recordChange(tx, before, kind)
+		}
+
+		return nil
+	})
+}
+
+// update writes the columns of the Shift which may be changed, only over the version last updated at the time
+// unless it is zero
+func (s *Shift) update(db *gorm.DB, version time.Time) error {
+	columns := map[string]interface{}{
+		"start":       s.Start,
+		"end":         s.End,
+		"user_id":     s.UserID,
+		"capacity":    s.Capacity,
+		"status":      s.Status,
+		"location_id": s.LocationID,
+		"position_id": s.PositionID,
+		"color":       s.Color,
+		"visibility":  s.Visibility,
+		"metadata":    s.Metadata,
+		"tags":        s.Tags,
+	}
+
+	// Update only the specific columns, of the version when given
+	tx := db.Model(s).Where("id = ?", s.ID)
+	if !version.IsZero() {
+		tx = tx.Where("updated_at = ?", version)
+	}
 
-	// Update only the specific columns
-	tx := db.Model(s).Where("id = ?", s.ID).Updates(
-		map[string]interface{}{
-			"start":   s.Start,
-			"end":     s.End,
-			"user_id": s.UserID,
-		},
-	).Take(s) // Update the current reference
+	tx = tx.Updates(columns)
 
 	err := tx.Error
 	if err != nil {
 		return err
 	}
 
-	if tx.RowsAffected < 1 {
-		return gorm.ErrRecordNotFound
+	// Another write made since the version was read leaves no row to update
+	if !version.IsZero() && tx.RowsAffected < 1 {
+		return ErrStale
 	}
 
-	return nil
+	// Update the current reference
+	return db.Take(s, "id = ?", s.ID).Error
 }
 
-// Delete will attempt to delete the Shift object from the database
-func (s *Shift) Delete(db *gorm.DB) error {
-	tx := db.Delete(s)
+// Cancel will attempt to soft delete the Shift object from the database, recording the reason
+// it was cancelled. Cancelled shifts are excluded from queries unless explicitly included. ErrStale is returned
+// when the Shift has a Precondition and the stored shift was changed since it was read.
+func (s *Shift) Cancel(db *gorm.DB, reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return invalid("cancellation reason required")
+	}
 
-	err := tx.Error
-	if err != nil {
-		return err
+	if s.Precondition != "" && s.ETag() != s.Precondition {
+		return ErrStale
 	}
 
-	if tx.RowsAffected == 0 {
-		return errors.New("shift not found")
+	return transaction(db, func(tx *gorm.DB) error {
+		update := tx.Model(s)
+		if s.Precondition != "" {
+			update = update.Where("updated_at = ?", s.UpdatedAt)
+		}
+
+		res := update.UpdateColumns(map[string]interface{}{
+			"cancel_reason": reason,
+			"cancelled_by":  s.CancelledBy,
+		})
+
+		err := res.Error
+		if err != nil {
+			return err
+		}
+
+		// Another write made since the shift was read leaves no row to update
+		if s.Precondition != "" && res.RowsAffected == 0 {
+			return ErrStale
+		}
+
+		res = tx.Delete(s)
+
+		err = res.Error
+		if err != nil {
+			return err
+		}
+
+		if res.RowsAffected == 0 {
+			return &NotFoundError{Kind: "shift"}
+		}
+
+		return recordChange(tx, s, ChangeCancelled)
+	})
+}
+
+// Restore will attempt to reinstate the cancelled Shift object, failing as saving it would if its worker is no longer
+// free, certified or permitted by the scheduling rules to work it. ErrStale is returned when the Shift has a
+// Precondition which the stored shift no longer matches, and a NotFoundError when it is no longer cancelled.
+func (s *Shift) Restore(db *gorm.DB) error {
+	if s.Precondition != "" && s.ETag() != s.Precondition {
+		return ErrStale
 	}
 
-	return nil
+	return transaction(db, func(tx *gorm.DB) error {
+		// The shift is checked against the schedule as it stands within the transaction, which excludes it as cancelled
+		err := s.checkSchedule(tx)
+		if err != nil {
+			return err
+		}
+
+		update := tx.Unscoped().Model(&Shift{}).Where("id = ? AND deleted_at IS NOT NULL", s.ID)
+		if s.Precondition != "" {
+			update = update.Where("updated_at = ?", s.UpdatedAt)
+		}
+
+		res := update.UpdateColumns(map[string]interface{}{
+			"deleted_at":    nil,
+			"cancel_reason": "",
+			"cancelled_by":  "",
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+
+		// Another write made since the shift was read leaves no row to update
+		if res.RowsAffected == 0 {
+			if s.Precondition != "" {
+				return ErrStale
+			}
+
+			return &NotFoundError{Kind: "cancelled shift"}
+		}
+
+		s.CancelledAt = gorm.DeletedAt{}
+		s.CancelReason = ""
+		s.CancelledBy = ""
+
+		// Columns are updated without hooks, so the restored shift is announced and its violations recorded here
+		emit(tx, s.shiftEvent(EventShiftUpdated))
+
+		return s.AfterSave(tx)
+	})
 }
 
 type ShiftFilterOption func(*gorm.DB)
@@ -142,6 +374,13 @@ func FilterUserID(uid string) func(*gorm.DB) {
 	}
 }
 
+// FilterUserIDs is used with ListShifts to filter the query to return results belonging to any of the specified User.IDs
+func FilterUserIDs(uids []string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		db.Where("user_id IN ?", uids)
+	}
+}
+
 // WithLimit is used with ListShifts to limit the number of results returned by the query.
 // If limit specified is less than or equal to 0, result will not be limited
 func WithLimit(limit int) func(*gorm.DB) {
@@ -175,17 +414,208 @@ func FilterEnd(end time.Time) func(*gorm.DB) {
 	}
 }
 
-// ListShifts attempts to return rows from the Shifts table with the specified limits and filters ordered by start time
-// Provide ShiftFilterOption parameters to modify the query with additional filters.
+// FilterTeamID is used with ListShifts to filter the query to return results belonging to members of the specific Team.ID
+// If tid is not an empty string, results will be filtered by that Team.ID
+func FilterTeamID(tid string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if tid != "" {
+			members := db.Session(&gorm.Session{NewDB: true}).Model(&User{}).Select("id").Where("team_id = ?", tid)
+			db.Where("user_id IN (?)", members)
+		}
+	}
+}
+
+// FilterLocationID is used with ListShifts to filter the query to return results at the specific Location.ID
+// If lid is not an empty string, results will be filtered by that Location.ID
+func FilterLocationID(lid string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if lid != "" {
+			db.Where("location_id = ?", lid)
+		}
+	}
+}
+
+// FilterPositionID is used with ListShifts to filter the query to return results in the specific Position.ID
+// If pid is not an empty string, results will be filtered by that Position.ID
+func FilterPositionID(pid string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if pid != "" {
+			db.Where("position_id = ?", pid)
+		}
+	}
+}
+
+// FilterEndsAfter is used with ListShifts to filter Shift results that have end times that fall after the
+// specified filtered time, including shifts still in progress at that time.
+// If after is specified as a time.Time zero value, it is ignored.
+func FilterEndsAfter(after time.Time) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if !after.IsZero() {
+			db.Where("end > ?", after)
+		}
+	}
+}
+
+// FilterStatus is used with ListShifts to filter the query to return results in any of the specified statuses
+// If no statuses are specified, results will not be filtered by status
+func FilterStatus(statuses ...string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if len(statuses) > 0 {
+			db.Where("status IN ?", statuses)
+		}
+	}
+}
+
+// IncludeCancelled is used with ListShifts to include cancelled shifts in the results
+func IncludeCancelled(include bool) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if include {
+			db.Statement.Unscoped = true
+		}
+	}
+}
+
+// FilterEvents is used with ListShifts to filter the query to return only event shifts which have a capacity.
+func FilterEvents() func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		db.Where("capacity > 0")
+	}
+}
+
+// FilterStartsBefore is used with ListShifts to filter Shift results that have start times that fall before the
+// specified filtered time.
+// If before is specified as a time.Time zero value, it is ignored.
+func FilterStartsBefore(before time.Time) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if !before.IsZero() {
+			db.Where("start < ?", before)
+		}
+	}
+}
+
+// FilterActiveAt is used with ListShifts to filter Shift results to those in progress at the specified time.
+// If at is specified as a time.Time zero value, it is ignored.
+func FilterActiveAt(at time.Time) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if !at.IsZero() {
+			db.Where("start <= ? AND end > ?", at, at)
+		}
+	}
+}
+
+// FilterTags is used with ListShifts to filter the query to return results carrying every one of the specified tags
+// If no tags are specified, results will not be filtered by tag
+func FilterTags(tags ...string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		// Underscores are allowed in tags, so they are escaped to match literally
+		escaper := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+		for _, tag := range tags {
+			db.Where("tags LIKE ? ESCAPE '!'", "%,"+escaper.Replace(tag)+",%")
+		}
+	}
+}
+
+// FilterCreated is used with ListShifts to filter Shift results to those created on or after the first time and
+// before the second. Either time specified as a time.Time zero value is ignored.
+func FilterCreated(after, before time.Time) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if !after.IsZero() {
+			db.Where("created_at >= ?", after)
+		}
+
+		if !before.IsZero() {
+			db.Where("created_at < ?", before)
+		}
+	}
+}
+
+// FilterUpdated is used with ListShifts to filter Shift results to those last updated on or after the first time
+// and before the second. Either time specified as a time.Time zero value is ignored.
+func FilterUpdated(after, before time.Time) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if !after.IsZero() {
+			db.Where("updated_at >= ?", after)
+		}
+
+		if !before.IsZero() {
+			db.Where("updated_at < ?", before)
+		}
+	}
+}
+
+// shiftSorts maps the sort parameters accepted by SortShifts and PageShifts to the column they order by
+var shiftSorts = map[string]sortColumn{
+	"start":      {Column: "start", Time: true},
+	"end":        {Column: "end", Time: true},
+	"status":     {Column: "status"},
+	"created_at": {Column: "created_at", Time: true},
+	"updated_at": {Column: "updated_at", Time: true},
+}
+
+// SortShifts is used with ListShifts to order Shift results by a comma separated list of start, end, status,
+// created_at and updated_at, each prefixed with - to sort descending, and then by ID. If sort is an empty string,
+// shifts are ordered by start time. An unknown column fails the query with ErrInvalid.
+func SortShifts(sort string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		keys, err := parseSort(sort, shiftSorts, "start")
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+
+		db.Order(sortOrder(db, keys))
+	}
+}
+
+// FilterAfterCursor is used with ListShifts to continue after the shift the cursor of a ShiftPage is positioned at,
+// seeking along the index of the first sort column rather than scanning past every earlier shift as an offset would.
+// Shifts must be sorted as they were when the cursor was made. If cursor is an empty string, it is ignored.
+// A malformed cursor fails the query with ErrInvalid.
+func FilterAfterCursor(cursor string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if cursor == "" {
+			return
+		}
+
+		position, err := decodeCursor(opaque.Shift, cursor)
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+
+		keys, err := parseSort(position.Sort, shiftSorts, "start")
+		if err != nil || len(position.Values) != len(keys) {
+			db.AddError(invalid("invalid cursor"))
+			return
+		}
+
+		query, args, err := afterPosition(db, keys, position)
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+
+		db.Where(query, args...)
+	}
+}
+
+// ListShifts attempts to return rows from the Shifts table with the specified limits and filters ordered by start
+// time and then ID, unless ordered otherwise with SortShifts. Provide ShiftFilterOption parameters to modify the
+// query with additional filters.
 func ListShifts(db *gorm.DB, opts ...ShiftFilterOption) ([]*Shift, error) {
 	var shifts []*Shift
 
-	tx := db.Model(&Shift{}).Order("start")
+	tx := db.Model(&Shift{})
 
 	for _, opt := range opts {
 		opt(tx)
 	}
 
+	if _, ok := tx.Statement.Clauses["ORDER BY"]; !ok {
+		tx.Order("start, id")
+	}
+
 	tx.Find(&shifts)
 
 	err := tx.Error
@@ -196,13 +626,191 @@ func ListShifts(db *gorm.DB, opts ...ShiftFilterOption) ([]*Shift, error) {
 	return shifts, nil
 }
 
+// ShiftPageQuery orders and pages the shifts returned by PageShifts
+type ShiftPageQuery struct {
+	Sort    string // comma separated columns accepted by SortShifts, prefixed with - to sort descending. Default: start
+	Page    int    // page of PerPage shifts to return starting from 1, ignored when Cursor is set
+	PerPage int    // shifts per page, every match in a single page when less than 1
+	Cursor  string // returned as NextCursor by the previous page, continues after its last shift
+}
+
+// ShiftPage holds a page of shifts, the total number matching the filters across all pages,
+// and the cursor of the following page when there is one
+type ShiftPage struct {
+	Shifts     []*Shift
+	Total      int64
+	NextCursor string
+}
+
+// PageShifts attempts to return a page of rows from the Shifts table matching the filters, along with the total
+// number of matches. Shifts are ordered by the sort columns and then ID, so cursors continue from a stable position.
+func PageShifts(db *gorm.DB, q ShiftPageQuery, opts ...ShiftFilterOption) (*ShiftPage, error) {
+	keys, err := parseSort(q.Sort, shiftSorts, "start")
+	if err != nil {
+		return nil, err
+	}
+
+	tx := db.Model(&Shift{})
+
+	for _, opt := range opts {
+		opt(tx)
+	}
+
+	result := &ShiftPage{Shifts: []*Shift{}}
+
+	err = tx.Session(&gorm.Session{}).Count(&result.Total).Error
+	if err != nil {
+		return nil, err
+	}
+
+	tx = tx.Order(sortOrder(db, keys))
+
+	// The cursor is applied after counting, as the total covers every page
+	if q.Cursor != "" {
+		cursor, err := cursorFor(opaque.Shift, q.Cursor, keys)
+		if err != nil {
+			return nil, err
+		}
+
+		query, args, err := afterPosition(db, keys, cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		tx = tx.Where(query, args...)
+	} else if q.Page > 1 && q.PerPage > 0 {
+		tx = tx.Offset((q.Page - 1) * q.PerPage)
+	}
+
+	// Fetch one shift past the page to tell whether another page follows
+	if q.PerPage > 0 {
+		tx = tx.Limit(q.PerPage + 1)
+	}
+
+	err = tx.Find(&result.Shifts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if q.PerPage > 0 && len(result.Shifts) > q.PerPage {
+		result.Shifts = result.Shifts[:q.PerPage]
+		result.NextCursor, err = ShiftCursor(result.Shifts[q.PerPage-1], q.Sort)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// ShiftCursor returns the opaque cursor positioned at the shift within the sort accepted by SortShifts,
+// from which FilterAfterCursor continues
+func ShiftCursor(s *Shift, sort string) (string, error) {
+	keys, err := parseSort(sort, shiftSorts, "start")
+	if err != nil {
+		return "", err
+	}
+
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		switch key.Name {
+		case "start":
+			values[i] = s.Start.Format(time.RFC3339Nano)
+		case "end":
+			values[i] = s.End.Format(time.RFC3339Nano)
+		case "status":
+			values[i] = s.Status
+		case "created_at":
+			values[i] = s.CreatedAt.Format(time.RFC3339Nano)
+		case "updated_at":
+			values[i] = s.UpdatedAt.Format(time.RFC3339Nano)
+		}
+	}
+
+	return encodeCursor(opaque.Shift, keys, values, s.ID)
+}
+
 // FindShiftByID attempts to return a row from the Shifts table with the matching ID
 func FindShiftByID(db *gorm.DB, sid string) (*Shift, error) {
 
 	shift := &Shift{}
 	err := db.First(&shift, "id = ?", sid).Error
 	if err != nil {
-		return &Shift{}, err
+		return &Shift{}, notFound("shift", err)
+	}
+
+	return shift, nil
+}
+
+// FindShiftsByIDs attempts to return the rows from the Shifts table with any of the specified Shift.IDs in a single
+// query, in no particular order. IDs matching no shift are left out.
+func FindShiftsByIDs(db *gorm.DB, sids []string) ([]*Shift, error) {
+	shifts := []*Shift{}
+	if len(sids) == 0 {
+		return shifts, nil
+	}
+
+	err := db.Where("id IN ?", sids).Find(&shifts).Error
+	if err != nil {
+		return []*Shift{}, err
+	}
+
+	return shifts, nil
+}
+
+// PublishShifts transitions every draft shift starting within the timespan to published,
+// optionally only for the specified User.IDs, returning the published shifts
+func PublishShifts(db *gorm.DB, start, end time.Time, uids ...string) ([]*Shift, error) {
+	var shifts []*Shift
+
+	err := transaction(db, func(tx *gorm.DB) error {
+		q := tx.Model(&Shift{}).Where("status = ? AND start >= ? AND start < ?", ShiftDraft, start, end)
+		if len(uids) > 0 {
+			q = q.Where("user_id IN ?", uids)
+		}
+
+		err := q.Order("start").Find(&shifts).Error
+		if err != nil {
+			return err
+		}
+
+		if len(shifts) == 0 {
+			return nil
+		}
+
+		sids := make([]string, 0, len(shifts))
+		for _, shift := range shifts {
+			sids = append(sids, shift.ID)
+			shift.Status = ShiftPublished
+		}
+
+		err = tx.Model(&Shift{}).Where("id IN ?", sids).Update("status", ShiftPublished).Error
+		if err != nil {
+			return err
+		}
+
+		// The hooks cannot announce each of the shifts published together
+		for _, shift := range shifts {
+			emit(tx, shift.shiftEvent(EventShiftUpdated))
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return []*Shift{}, err
+	}
+
+	return shifts, nil
+}
+
+// FindCancelledShiftByID attempts to return a cancelled row from the Shifts table with the matching ID
+func FindCancelledShiftByID(db *gorm.DB, sid string) (*Shift, error) {
+
+	shift := &Shift{}
+	err := db.Unscoped().Where("deleted_at IS NOT NULL").First(&shift, "id = ?", sid).Error
+	if err != nil {
+		return &Shift{}, notFound("shift", err)
 	}
 
 	return shift, nil
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"errors"
+	"github.com/btnmasher/shiftr/secrets"
+	"gorm.io/gorm"
+	"time"
+)
+
+// ErrKeyCurrent is returned when rotating to the signing key which is already current
+var ErrKeyCurrent = errors.New("secret is already the current key")
+
+// SigningKey struct represents a JWT signing secret, stored so that the tokens signed by one server are accepted by
+// every server sharing the database and outlive restarts. The current key has no expiry, retired keys are accepted
+// until they expire.
+type SigningKey struct {
+	ID        string         `gorm:"primaryKey;size:16"` // the kid of the tokens it signs
+	Secret    secrets.String `gorm:"not null"`           //encrypted at rest
+	ExpiresAt *time.Time     // when a retired key stops being accepted, nil for the current key
+	CreatedAt time.Time      `gorm:"not null"`
+}
+
+// ListSigningKeys returns the current signing key and the retired keys which have not expired, newest first
+func ListSigningKeys(db *gorm.DB) ([]*SigningKey, error) {
+	var keys []*SigningKey
+
+	err := db.Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("created_at DESC").
+		Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// SeedSigningKey stores the key as the current signing key unless there is one already
+func SeedSigningKey(db *gorm.DB, key *SigningKey) error {
+	return transaction(db, func(tx *gorm.DB) error {
+		var current int64
+
+		err := tx.Model(&SigningKey{}).Where("expires_at IS NULL").Count(&current).Error
+		if err != nil || current > 0 {
+			return err
+		}
+
+		// The key may have been current before, and since retired
+		err = tx.Delete(&SigningKey{}, "id = ?", key.ID).Error
+		if err != nil {
+			return err
+		}
+
+		key.ExpiresAt = nil
+
+		return tx.Create(key).Error
+	})
+}
+
+// RotateSigningKey makes the key the current signing key, retiring the current key for the grace period and
+// dropping the keys which have expired. ErrKeyCurrent is returned when the key is already current.
+func RotateSigningKey(db *gorm.DB, key *SigningKey, grace time.Duration) error {
+	now := time.Now()
+	expires := now.Add(grace)
+
+	return transaction(db, func(tx *gorm.DB) error {
+		var current int64
+
+		err := tx.Model(&SigningKey{}).Where("id = ? AND expires_at IS NULL", key.ID).Count(&current).Error
+		if err != nil {
+			return err
+		}
+
+		if current > 0 {
+			return ErrKeyCurrent
+		}
+
+		err = tx.Model(&SigningKey{}).Where("expires_at IS NULL").Update("expires_at", expires).Error
+		if err != nil {
+			return err
+		}
+
+		// A retired key given again is made current anew
+		err = tx.Where("id = ? OR expires_at <= ?", key.ID, now).Delete(&SigningKey{}).Error
+		if err != nil {
+			return err
+		}
+
+		key.ExpiresAt = nil
+
+		return tx.Create(key).Error
+	})
+}
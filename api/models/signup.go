@@ -0,0 +1,166 @@
+package models
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var (
+	ErrNotEvent        = errors.New("shift is not an event")
+	ErrEventFull       = errors.New("event is full")
+	ErrAlreadySignedUp = errors.New("already signed up for this event")
+	ErrNotSignedUp     = errors.New("not signed up for this event")
+	ErrEventNotFull    = errors.New("event still has open slots")
+	ErrAlreadyWaiting  = errors.New("already on the waitlist for this event")
+	ErrNotWaiting      = errors.New("not on the waitlist for this event")
+)
+
+// Signup struct represents a User having signed up for a slot of an event Shift
+type Signup struct {
+	ShiftID   string    `gorm:"primaryKey" json:"shift_id"`
+	UserID    string    `gorm:"primaryKey" json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SignUp attempts to take one of the event's slots for the specified User.ID
+func (s *Shift) SignUp(db *gorm.DB, uid string) error {
+	if !s.IsEvent() {
+		return ErrNotEvent
+	}
+
+	return transaction(db, func(tx *gorm.DB) error {
+		err := s.lock(tx)
+		if err != nil {
+			return err
+		}
+
+		var count int64
+
+		err = tx.Model(&Signup{}).Where("shift_id = ? AND user_id = ?", s.ID, uid).Count(&count).Error
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return ErrAlreadySignedUp
+		}
+
+		err = tx.Model(&Signup{}).Where("shift_id = ?", s.ID).Count(&count).Error
+		if err != nil {
+			return err
+		}
+
+		if int(count) >= s.Capacity {
+			return ErrEventFull
+		}
+
+		err = tx.Create(&Signup{ShiftID: s.ID, UserID: uid}).Error
+		if err != nil {
+			return err
+		}
+
+		// A user taking a slot no longer needs their place on the waitlist
+		err = tx.Where("shift_id = ? AND user_id = ?", s.ID, uid).Delete(&WaitlistEntry{}).Error
+		if err != nil {
+			return err
+		}
+
+		s.Signups = int(count) + 1
+
+		return nil
+	})
+}
+
+// lock locks the row of the event until the transaction ends, so that concurrent signups are counted against its
+// capacity one after the other rather than each counting the slots the other is about to take. The capacity is
+// read again under the lock. Databases without row locks, such as SQLite, serialize their writes instead.
+func (s *Shift) lock(tx *gorm.DB) error {
+	var locked Shift
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "capacity").
+		Where("id = ?", s.ID).Take(&locked).Error
+	if err != nil {
+		return err
+	}
+
+	s.Capacity = locked.Capacity
+
+	return nil
+}
+
+// Withdraw attempts to release the event slot held by the specified User.ID,
+// promoting waitlisted users into any freed slots. The promoted User.IDs are returned.
+func (s *Shift) Withdraw(db *gorm.DB, uid string) ([]string, error) {
+	var promoted []string
+
+	err := transaction(db, func(tx *gorm.DB) error {
+		err := s.lock(tx)
+		if err != nil {
+			return err
+		}
+
+		res := tx.Where("shift_id = ? AND user_id = ?", s.ID, uid).Delete(&Signup{})
+
+		err = res.Error
+		if err != nil {
+			return err
+		}
+
+		if res.RowsAffected == 0 {
+			return ErrNotSignedUp
+		}
+
+		promoted, err = s.PromoteWaitlist(tx)
+		return err
+	})
+
+	return promoted, err
+}
+
+// ListSignups attempts to return the signups of the specified Shift.ID ordered by signup time
+func ListSignups(db *gorm.DB, sid string) ([]*Signup, error) {
+	var signups []*Signup
+
+	err := db.Model(&Signup{}).Where("shift_id = ?", sid).Order("created_at").Find(&signups).Error
+	if err != nil {
+		return []*Signup{}, err
+	}
+
+	return signups, nil
+}
+
+// CountSignups populates the Signups count of each of the specified event shifts
+func CountSignups(db *gorm.DB, shifts []*Shift) error {
+	if len(shifts) == 0 {
+		return nil
+	}
+
+	sids := make([]string, 0, len(shifts))
+	for _, shift := range shifts {
+		sids = append(sids, shift.ID)
+	}
+
+	var counts []struct {
+		ShiftID string
+		Count   int
+	}
+
+	err := db.Model(&Signup{}).Select("shift_id, COUNT(*) AS count").
+		Where("shift_id IN ?", sids).Group("shift_id").Scan(&counts).Error
+	if err != nil {
+		return err
+	}
+
+	countMap := make(map[string]int, len(counts))
+	for _, count := range counts {
+		countMap[count.ShiftID] = count.Count
+	}
+
+	for _, shift := range shifts {
+		shift.Signups = countMap[shift.ID]
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/btnmasher/shiftr/opaque"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sortColumn is a column a listing may be ordered by
+type sortColumn struct {
+	Column string
+	Time   bool // whether the column holds timestamps, carried in cursors formatted as RFC 3339
+}
+
+// sortKey is a column of a parsed sort along with its direction
+type sortKey struct {
+	Name string
+	sortColumn
+	Desc bool
+}
+
+// parseSort parses a comma separated list of sort names, each optionally prefixed with - to sort descending,
+// into the columns they order by. Only names found in columns are accepted, so the column names placed in the query
+// never come from the user. An empty sort falls back to def.
+func parseSort(s string, columns map[string]sortColumn, def string) ([]sortKey, error) {
+	if strings.TrimSpace(s) == "" {
+		s = def
+	}
+
+	var keys []sortKey
+	seen := make(map[string]bool)
+
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		name := strings.TrimPrefix(field, "-")
+
+		column, ok := columns[name]
+		if !ok {
+			names := make([]string, 0, len(columns))
+			for name := range columns {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			return nil, invalid(fmt.Sprintf("sort must be a comma separated list of %s, each optionally prefixed with -",
+				strings.Join(names, ", ")))
+		}
+
+		if seen[name] {
+			return nil, invalid(fmt.Sprintf("sort lists %s more than once", name))
+		}
+		seen[name] = true
+
+		keys = append(keys, sortKey{Name: name, sortColumn: column, Desc: strings.HasPrefix(field, "-")})
+	}
+
+	return keys, nil
+}
+
+// sortString returns the canonical form of the sort, which cursors record to tell which order they were made for
+func sortString(keys []sortKey) string {
+	fields := make([]string, len(keys))
+	for i, key := range keys {
+		fields[i] = key.Name
+		if key.Desc {
+			fields[i] = "-" + key.Name
+		}
+	}
+
+	return strings.Join(fields, ",")
+}
+
+// sortOrder returns the ORDER BY clause of the sort, ending with the ID in the direction of the last column
+// so that rows with equal values still come in a stable order
+func sortOrder(db *gorm.DB, keys []sortKey) string {
+	var b strings.Builder
+
+	for _, key := range keys {
+		b.WriteString(quote(db, key.Column))
+		b.WriteString(direction(key.Desc))
+		b.WriteString(", ")
+	}
+
+	b.WriteString("id")
+	b.WriteString(direction(keys[len(keys)-1].Desc))
+
+	return b.String()
+}
+
+// direction returns the ORDER BY keyword of the direction
+func direction(desc bool) string {
+	if desc {
+		return " DESC"
+	}
+
+	return " ASC"
+}
+
+// comparison returns the operator selecting the rows following a value in the direction
+func comparison(desc bool) string {
+	if desc {
+		return "<"
+	}
+
+	return ">"
+}
+
+// afterPosition returns the condition selecting the rows which follow the cursor in the order of the sort.
+// It leads with a range on the first column, letting databases seek along an index beginning with it.
+func afterPosition(db *gorm.DB, keys []sortKey, cursor *listCursor) (string, []interface{}, error) {
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		values[i] = cursor.Values[i]
+		if key.Time {
+			at, err := time.Parse(time.RFC3339Nano, cursor.Values[i])
+			if err != nil {
+				return "", nil, invalid("invalid cursor")
+			}
+			values[i] = at
+		}
+	}
+
+	first := quote(db, keys[0].Column)
+	query := fmt.Sprintf("%s %s= ? AND (", first, comparison(keys[0].Desc))
+	args := []interface{}{values[0]}
+
+	// Each alternative matches the columns before one and follows the cursor on that one, the ID breaking the tie
+	for i := 0; i <= len(keys); i++ {
+		if i > 0 {
+			query += " OR "
+		}
+
+		query += "("
+		for j := 0; j < i; j++ {
+			query += quote(db, keys[j].Column) + " = ? AND "
+			args = append(args, values[j])
+		}
+
+		if i < len(keys) {
+			query += fmt.Sprintf("%s %s ?)", quote(db, keys[i].Column), comparison(keys[i].Desc))
+			args = append(args, values[i])
+		} else {
+			query += fmt.Sprintf("id %s ?)", comparison(keys[len(keys)-1].Desc))
+			args = append(args, cursor.ID)
+		}
+	}
+
+	return query + ")", args, nil
+}
+
+// listCursor is the position of the last row of a page within a sort, encoded as an opaque cursor
+type listCursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+	ID     string   `json:"i"`
+}
+
+// encodeCursor returns the cursor positioned at the row with the values of the sort columns, carrying its
+// external ID so the internal one is never exposed
+func encodeCursor(kind opaque.Kind, keys []sortKey, values []string, id string) (string, error) {
+	ext, err := opaque.Encode(kind, id)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(listCursor{Sort: sortString(keys), Values: values, ID: ext})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor returns the position of the cursor, with the internal ID of the row it was made at
+func decodeCursor(kind opaque.Kind, s string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, invalid("invalid cursor")
+	}
+
+	cursor := &listCursor{}
+
+	err = json.Unmarshal(data, cursor)
+	if err != nil || cursor.ID == "" || cursor.Sort == "" {
+		return nil, invalid("invalid cursor")
+	}
+
+	cursor.ID, err = opaque.Decode(kind, cursor.ID)
+	if err != nil {
+		return nil, invalid("invalid cursor")
+	}
+
+	return cursor, nil
+}
+
+// cursorFor decodes the cursor, which must have been made for the sort and hold a value for each of its columns
+func cursorFor(kind opaque.Kind, s string, keys []sortKey) (*listCursor, error) {
+	cursor, err := decodeCursor(kind, s)
+	if err != nil {
+		return nil, err
+	}
+
+	// A cursor only marks a position within the order it was made for
+	if cursor.Sort != sortString(keys) {
+		return nil, invalid("cursor was made for a different sort")
+	}
+
+	if len(cursor.Values) != len(keys) {
+		return nil, invalid("invalid cursor")
+	}
+
+	return cursor, nil
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TableStats reports the size of a model's table
+type TableStats struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+}
+
+// IndexStats reports an index and, where the database tracks it, how often it has been scanned
+type IndexStats struct {
+	Table string `json:"table"`
+	Index string `json:"index"`
+	Scans *int64 `json:"scans,omitempty"`
+}
+
+// DatabaseStats is a snapshot of the state of the database for diagnosing growth and pooling issues
+type DatabaseStats struct {
+	Driver     string         `json:"driver"`
+	Schema     *SchemaVersion `json:"schema,omitempty"`
+	Tables     []TableStats   `json:"tables"`
+	Indexes    []IndexStats   `json:"indexes"`
+	IndexError string         `json:"index_error,omitempty"` //set when index statistics could not be read
+	Pool       sql.DBStats    `json:"pool"`
+}
+
+// indexQueries read the indexes of the current database along with their usage, for the drivers which track it
+var indexQueries = map[string]string{
+	"postgres": `SELECT relname AS "table", indexrelname AS "index", idx_scan AS scans
+		FROM pg_stat_user_indexes ORDER BY relname, indexrelname`,
+	"mysql": `SELECT object_name AS ` + "`table`" + `, index_name AS ` + "`index`" + `, count_star AS scans
+		FROM performance_schema.table_io_waits_summary_by_index_usage
+		WHERE object_schema = DATABASE() AND index_name IS NOT NULL ORDER BY object_name, index_name`,
+	"sqlserver": `SELECT t.name AS [table], i.name AS [index], s.user_seeks + s.user_scans + s.user_lookups AS scans
+		FROM sys.indexes i JOIN sys.tables t ON t.object_id = i.object_id
+		LEFT JOIN sys.dm_db_index_usage_stats s ON s.object_id = i.object_id AND s.index_id = i.index_id
+			AND s.database_id = DB_ID()
+		WHERE i.name IS NOT NULL ORDER BY t.name, i.name`,
+	"sqlite": `SELECT tbl_name AS "table", name AS "index", NULL AS scans
+		FROM sqlite_master WHERE type = 'index' ORDER BY tbl_name, name`,
+}
+
+// CollectDatabaseStats gathers the row counts of every model table, index usage where the driver
+// supports it, connection pool statistics and the current schema version
+func CollectDatabaseStats(db *gorm.DB) (*DatabaseStats, error) {
+	stats := &DatabaseStats{
+		Driver:  db.Dialector.Name(),
+		Tables:  []TableStats{},
+		Indexes: []IndexStats{},
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Pool = sqlDB.Stats()
+
+	version, err := CurrentSchemaVersion(db)
+	if err == nil {
+		stats.Schema = version
+	}
+
+	for _, model := range Models() {
+		s, err := schema.Parse(model, &sync.Map{}, db.NamingStrategy)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse schema of %T: %s", model, err)
+		}
+
+		var rows int64
+		err = db.Table(s.Table).Count(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+
+		stats.Tables = append(stats.Tables, TableStats{Table: s.Table, Rows: rows})
+	}
+
+	sort.Slice(stats.Tables, func(i, j int) bool {
+		return stats.Tables[i].Table < stats.Tables[j].Table
+	})
+
+	// Index statistics often need extra privileges, so failing to read them is reported rather than fatal
+	query, ok := indexQueries[stats.Driver]
+	if !ok {
+		stats.IndexError = "index statistics are not supported by the " + stats.Driver + " driver"
+		return stats, nil
+	}
+
+	err = db.Raw(query).Scan(&stats.Indexes).Error
+	if err != nil {
+		stats.IndexError = err.Error()
+	}
+
+	return stats, nil
+}
+
+// AdminStats are the aggregate counts shown at a glance on the admin dashboard
+type AdminStats struct {
+	Users       int64            `json:"users"`         //active users
+	UsersByRole map[string]int64 `json:"users_by_role"` //active users of each role
+	WeekStart   time.Time        `json:"week_start"`
+	WeekEnd     time.Time        `json:"week_end"`
+	WeekShifts  int64            `json:"week_shifts"` //published shifts starting this week
+	WeekHours   float64          `json:"week_hours"`  //scheduled hours of the assigned shifts among them
+	OpenShifts  int64            `json:"open_shifts"` //upcoming published shifts without a worker
+}
+
+// CollectAdminStats counts the active users by role, the published shifts of the week containing now and the
+// hours scheduled by them, and the upcoming open shifts. Events, which users sign up for, are not open shifts.
+// The counts are computed by the database rather than by loading every user and shift.
+func CollectAdminStats(db *gorm.DB, weeks Weeks, now time.Time) (*AdminStats, error) {
+	start, end := weeks.Of(now)
+
+	stats := &AdminStats{
+		UsersByRole: map[string]int64{},
+		WeekStart:   start,
+		WeekEnd:     end,
+	}
+
+	var roles []struct {
+		Role  string
+		Count int64
+	}
+
+	err := db.Model(&User{}).Select("role, COUNT(*) AS count").Group("role").Scan(&roles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range roles {
+		stats.UsersByRole[r.Role] = r.Count
+		stats.Users += r.Count
+	}
+
+	startCol, userCol := quote(db, "shifts.start"), quote(db, "shifts.user_id")
+	capacityCol := quote(db, "shifts.capacity")
+	thisWeek := fmt.Sprintf("%s >= ? AND %s < ?", startCol, startCol)
+
+	var shifts struct {
+		WeekShifts int64
+		WeekHours  float64
+		OpenShifts int64
+	}
+
+	err = db.Model(&Shift{}).
+		Select(fmt.Sprintf(`COUNT(CASE WHEN %s THEN 1 END) AS week_shifts,
+			COALESCE(SUM(CASE WHEN %s AND %s <> '' THEN %s END), 0) AS week_hours,
+			COUNT(CASE WHEN %s > ? AND %s = '' AND %s = 0 THEN 1 END) AS open_shifts`,
+			thisWeek, thisWeek, userCol, hoursBetween(db, "shifts.start", "shifts.end"), startCol, userCol, capacityCol),
+			start, end, start, end, now).
+		Where("shifts.status = ?", ShiftPublished).
+		Scan(&shifts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats.WeekShifts = shifts.WeekShifts
+	stats.WeekHours = math.Round(shifts.WeekHours*100) / 100
+	stats.OpenShifts = shifts.OpenShifts
+
+	return stats, nil
+}
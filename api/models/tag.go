@@ -0,0 +1,85 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MaxTags is the most tags a shift may carry
+const MaxTags = 10
+
+// tagPattern matches a tag: lowercase letters, digits, dashes and underscores
+var tagPattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// Tags are short labels attached to a shift, such as "training" or "overtime", for filtering.
+// They are stored as a single column of the sorted tags wrapped in commas, such as ",overtime,training,",
+// so that a tag is matched with LIKE on every dialect.
+type Tags []string
+
+// Validate checks the tags are well formed, distinct and no more than MaxTags
+func (t Tags) Validate() error {
+	if len(t) > MaxTags {
+		return invalidf("a shift cannot have more than %d tags", MaxTags)
+	}
+
+	seen := make(map[string]bool, len(t))
+	for _, tag := range t {
+		if !tagPattern.MatchString(tag) {
+			return invalid("tags must be 1 to 32 lowercase letters, digits, dashes or underscores")
+		}
+
+		if seen[tag] {
+			return invalidf("tag %s is listed more than once", tag)
+		}
+		seen[tag] = true
+	}
+
+	return nil
+}
+
+// GormDataType implements schema.GormDataTypeInterface
+func (Tags) GormDataType() string {
+	return "string"
+}
+
+// Value implements driver.Valuer, joining the sorted tags
+func (t Tags) Value() (driver.Value, error) {
+	if len(t) == 0 {
+		return nil, nil
+	}
+
+	sorted := append(Tags{}, t...)
+	sort.Strings(sorted)
+
+	return "," + strings.Join(sorted, ",") + ",", nil
+}
+
+// Scan implements sql.Scanner, splitting the stored tags
+func (t *Tags) Scan(src interface{}) error {
+	var data string
+
+	switch v := src.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Tags", src)
+	}
+
+	data = strings.Trim(data, ",")
+	if data == "" {
+		*t = nil
+		return nil
+	}
+
+	*t = strings.Split(data, ",")
+
+	return nil
+}
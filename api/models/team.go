@@ -0,0 +1,140 @@
+package models
+
+import (
+	"fmt"
+	"github.com/jkomyno/nanoid"
+	"gorm.io/gorm"
+	"html"
+	"strings"
+	"time"
+)
+
+// Team struct represents a named group of users scheduled together
+type Team struct {
+	ID         string    `gorm:"primaryKey" json:"id"`
+	Name       string    `gorm:"size:50;not null;unique" json:"name"`
+	Visibility string    `gorm:"size:10;not null;default:'full'" json:"visibility"` //of member shifts to other members: full or anonymous
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Validate checks to ensure all fields of the object are present and valid
+func (t *Team) Validate() error {
+	if t.Name == "" {
+		return invalid("name required")
+	}
+
+	if !validVisibility(t.Visibility) {
+		return invalid("visibility must be full or anonymous")
+	}
+
+	return nil
+}
+
+// BeforeCreate hooks GORM and prepares a new object for creation
+func (t *Team) BeforeCreate(_ *gorm.DB) error {
+	id, err := nanoid.Nanoid(8)
+	if err != nil {
+		return fmt.Errorf("unable to generate TeamID: %s", err)
+	}
+
+	t.ID = id
+	t.Name = html.EscapeString(strings.TrimSpace(t.Name))
+
+	if t.Visibility == "" {
+		t.Visibility = VisibilityFull
+	}
+
+	return nil
+}
+
+// Create attempts to create the Team object in the database
+func (t *Team) Create(db *gorm.DB) error {
+	return db.Create(t).Error
+}
+
+// Update will attempt to update the current Team object in the database
+func (t *Team) Update(db *gorm.DB) error {
+	if t.Visibility == "" {
+		t.Visibility = VisibilityFull
+	}
+
+	tx := db.Model(t).Where("id = ?", t.ID).Updates(
+		map[string]interface{}{
+			"name":       html.EscapeString(strings.TrimSpace(t.Name)),
+			"visibility": t.Visibility,
+		},
+	).Take(t)
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected < 1 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Delete will attempt to delete the Team object from the database
+func (t *Team) Delete(db *gorm.DB) error {
+	tx := db.Delete(t)
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected == 0 {
+		return &NotFoundError{Kind: "team"}
+	}
+
+	return nil
+}
+
+// AfterDelete hooks GORM to remove the members and calendar feeds of this team
+// when it is deleted
+func (t *Team) AfterDelete(db *gorm.DB) error {
+	err := db.Model(&User{}).Where("team_id = ?", t.ID).Update("team_id", "").Error
+	if err != nil {
+		return err
+	}
+
+	return db.Where("team_id = ?", t.ID).Delete(&CalendarFeed{}).Error
+}
+
+// ListTeams attempts to return all rows from the Teams table ordered by name
+func ListTeams(db *gorm.DB) ([]*Team, error) {
+	var teams []*Team
+
+	err := db.Model(&Team{}).Order("name").Find(&teams).Error
+	if err != nil {
+		return []*Team{}, err
+	}
+
+	return teams, nil
+}
+
+// FindTeamByID attempts to return a row from the Teams table with the matching Team.ID
+func FindTeamByID(db *gorm.DB, tid string) (*Team, error) {
+	team := &Team{}
+	err := db.First(&team, "id = ?", tid).Error
+	if err != nil {
+		return &Team{}, notFound("team", err)
+	}
+
+	return team, nil
+}
+
+// FindTeamByName attempts to return a row from the Teams table with the matching Team.Name
+func FindTeamByName(db *gorm.DB, name string) (*Team, error) {
+	team := &Team{}
+	err := db.First(&team, "name = ?", html.EscapeString(strings.TrimSpace(name))).Error
+	if err != nil {
+		return &Team{}, notFound("team", err)
+	}
+
+	return team, nil
+}
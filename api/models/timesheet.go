@@ -0,0 +1,150 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// PunchRounding sets how clock punches are rounded when computing worked hours. Punches within Grace of
+// the scheduled start or end of the shift they were made for count as made at the scheduled time, and any
+// others are rounded to the nearest Increment. Zero values disable the corresponding rule.
+type PunchRounding struct {
+	Increment time.Duration // e.g. 5, 10 or 15 minutes
+	Grace     time.Duration // distance from the scheduled time within which punches snap to it
+}
+
+// Round returns the time a punch made at the specified time counts as, given the scheduled time of
+// the shift it was made for, which is ignored when zero
+func (r PunchRounding) Round(at, scheduled time.Time) time.Time {
+	if r.Grace > 0 && !scheduled.IsZero() && absDuration(at.Sub(scheduled)) <= r.Grace {
+		return scheduled
+	}
+
+	if r.Increment > 0 {
+		return at.Round(r.Increment)
+	}
+
+	return at
+}
+
+// TimesheetEntry is a ClockEntry with its punches rounded. The raw punches are kept as recorded.
+type TimesheetEntry struct {
+	Punch    *ClockEntry `json:"punch"`
+	ClockIn  time.Time   `json:"clock_in"`            //rounded
+	ClockOut *time.Time  `json:"clock_out,omitempty"` //rounded, empty while still clocked in
+	Hours    float64     `json:"hours"`               //between the rounded punches
+}
+
+// Timesheet holds the clock entries of a User over a period with the hours they worked
+type Timesheet struct {
+	UserID   string            `json:"user_id"`
+	Name     string            `json:"name"`
+	Entries  []*TimesheetEntry `json:"entries"`
+	Hours    float64           `json:"hours"`     //between the rounded punches
+	RawHours float64           `json:"raw_hours"` //between the punches as recorded
+}
+
+// ListTimesheets returns a Timesheet for each user who clocked in within the window, restricted to the
+// specified User.ID when not empty, with the worked hours computed from punches rounded by the
+// PunchRounding of the database. Entries still clocked in count no hours.
+func ListTimesheets(db *gorm.DB, start, end time.Time, uid string) ([]*Timesheet, error) {
+	timesheets := []*Timesheet{}
+
+	tx := db.Model(&ClockEntry{}).
+		Select("clock_entries.*, users.name AS name").
+		Joins("LEFT JOIN users ON users.id = clock_entries.user_id").
+		Order("users.name, clock_entries.user_id, clock_entries.clock_in")
+
+	if !start.IsZero() {
+		tx.Where("clock_entries.clock_in >= ?", start)
+	}
+
+	if !end.IsZero() {
+		tx.Where("clock_entries.clock_in < ?", end)
+	}
+
+	if uid != "" {
+		tx.Where("clock_entries.user_id = ?", uid)
+	}
+
+	var rows []struct {
+		ClockEntry
+		Name string
+	}
+
+	err := tx.Scan(&rows).Error
+	if err != nil {
+		return timesheets, err
+	}
+
+	// Collect the shifts the entries were made for, including any since cancelled
+	var sids []string
+	for _, row := range rows {
+		if row.ShiftID != "" {
+			sids = append(sids, row.ShiftID)
+		}
+	}
+
+	scheduled := make(map[string]*Shift, len(sids))
+
+	if len(sids) > 0 {
+		shifts, err := ListShifts(db, IncludeCancelled(true), func(db *gorm.DB) {
+			db.Where("id IN ?", sids)
+		})
+		if err != nil {
+			return timesheets, err
+		}
+
+		for _, shift := range shifts {
+			scheduled[shift.ID] = shift
+		}
+	}
+
+	r := CurrentSettings(db).PunchRounding
+	var sheet *Timesheet
+
+	for i := range rows {
+		punch := rows[i].ClockEntry
+
+		// Rows are ordered by user, so a new user starts a new timesheet
+		if sheet == nil || sheet.UserID != punch.UserID {
+			sheet = &Timesheet{
+				UserID:  punch.UserID,
+				Name:    rows[i].Name,
+				Entries: []*TimesheetEntry{},
+			}
+
+			timesheets = append(timesheets, sheet)
+		}
+
+		var shiftStart, shiftEnd time.Time
+		if shift, ok := scheduled[punch.ShiftID]; ok {
+			shiftStart, shiftEnd = shift.Start, shift.End
+		}
+
+		entry := &TimesheetEntry{
+			Punch:   &punch,
+			ClockIn: r.Round(punch.ClockIn, shiftStart),
+		}
+
+		if punch.ClockOut != nil {
+			out := r.Round(*punch.ClockOut, shiftEnd)
+			entry.ClockOut = &out
+
+			if out.After(entry.ClockIn) {
+				entry.Hours = round(out.Sub(entry.ClockIn).Hours())
+			}
+
+			sheet.RawHours += punch.ClockOut.Sub(punch.ClockIn).Hours()
+		}
+
+		sheet.Hours += entry.Hours
+		sheet.Entries = append(sheet.Entries, entry)
+	}
+
+	for _, sheet := range timesheets {
+		sheet.Hours, sheet.RawHours = round(sheet.Hours), round(sheet.RawHours)
+	}
+
+	return timesheets, nil
+}
@@ -1,51 +1,105 @@
 package models
 
 import (
-	"errors"
 	"fmt"
+	"github.com/btnmasher/shiftr/opaque"
+	"github.com/btnmasher/shiftr/secrets"
 	"github.com/btnmasher/shiftr/utils"
 	"github.com/jkomyno/nanoid"
 	"gorm.io/gorm"
 	"html"
+	"net/mail"
 	"strings"
 	"time"
 )
 
 // User struct represents a user with a unique ID, Name, Password, and Role
 type User struct {
-	ID        string    `gorm:"primaryKey" json:"id"`
-	Name      string    `gorm:"size:30;not null;unique'" json:"name"`        //login name
-	Password  string    `gorm:"size:100;not null" json:"password,omitempty"` //bcrypt hash
-	Role      string    `gorm:"size:10;not null" json:"role"`                //user role: user, admin
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           string         `gorm:"primaryKey" json:"id"`
+	Name         string         `gorm:"size:30;not null;unique'" json:"name"`        //login name
+	Password     string         `gorm:"size:100;not null" json:"password,omitempty"` //bcrypt hash
+	Role         string         `gorm:"size:10;not null" json:"role"`                //user role: user, admin
+	Email        string         `gorm:"size:254" json:"email,omitempty"`             //contact address
+	EmailKey     *string        `gorm:"size:254;uniqueIndex" json:"-"`               //lowercased Email, null when none
+	TeamID       string         `gorm:"index" json:"team_id,omitempty"`
+	LocationID   string         `gorm:"index" json:"location_id,omitempty"` //home location
+	ManagerID    string         `gorm:"index" json:"manager_id,omitempty"`  //user approving their requests
+	Phone        secrets.String `gorm:"size:255" json:"phone,omitempty"`    //encrypted at rest
+	Avatar       string         `gorm:"size:64" json:"-"`                   //blob store key, exposed as avatar_url
+	CustomFields Metadata       `json:"custom_fields,omitempty"`            //values of the admin-defined CustomFields
+	HourlyRate   float64        `gorm:"not null;default:0" json:"hourly_rate,omitempty"`
+	Shifts       []*Shift       `gorm:"-" json:"shifts,omitempty"` //not yet ended, when included by the request
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+
+	DeactivatedAt gorm.DeletedAt `gorm:"column:deleted_at;index" json:"deactivated_at"`
+
+	// Precondition is the ETag the stored user must still have for Update or Deactivate to apply, empty for none
+	Precondition string `gorm:"-" json:"-"`
+
+	// avatarURL is the URL the Avatar is served from, resolved when the user is read from the database
+	avatarURL string
 }
 
-// Validate checks to ensure all fields of the object are present and valid
+// Validate checks to ensure all fields of the object are present and valid, returning a *FieldErrors listing
+// each invalid field
 func (u *User) Validate() error {
-	if u.Name == "" {
-		return errors.New("name required")
-	}
-
-	if u.Password == "" {
-		return errors.New("password required")
-	}
-
-	if u.Role == "" {
-		return errors.New("role required")
-	}
+	return allInvalid(u.checks())
+}
 
-	if u.Role != "user" && u.Role != "admin" {
-		return errors.New("invalid role")
+// checks returns the checks of each field of the User, in the order Validate makes them
+func (u *User) checks() []fieldCheck {
+	return []fieldCheck{
+		{"name", func() error {
+			if u.Name == "" {
+				return invalid("name required")
+			}
+
+			return nil
+		}},
+		{"password", func() error {
+			if u.Password == "" {
+				return invalid("password required")
+			}
+
+			return nil
+		}},
+		{"role", func() error {
+			if u.Role == "" {
+				return invalid("role required")
+			}
+
+			if u.Role != "user" && u.Role != "admin" {
+				return invalid("invalid role")
+			}
+
+			return nil
+		}},
+		{"email", func() error {
+			if u.Email != "" {
+				if _, err := mail.ParseAddress(u.Email); err != nil {
+					return invalid("invalid email")
+				}
+			}
+
+			return nil
+		}},
+		{"hourly_rate", func() error {
+			if u.HourlyRate < 0 {
+				return invalid("hourly_rate cannot be negative")
+			}
+
+			return nil
+		}},
 	}
-
-	return nil
 }
 
 // Prepare prepares a new object for update by escaping the name field and
 // hashing the password field before it is written
 func (u *User) Prepare() error {
 	u.Name = html.EscapeString(strings.TrimSpace(u.Name))
+	u.Email = strings.TrimSpace(u.Email)
+	u.EmailKey = emailKey(u.Email)
 
 	hashedPassword, err := utils.HashPassword(u.Password)
 	if err != nil {
@@ -57,11 +111,33 @@ func (u *User) Prepare() error {
 	return nil
 }
 
+// emailKey returns the key enforcing the uniqueness of an email address regardless of case, which is
+// null for users without one so that any number of them may have no address
+func emailKey(email string) *string {
+	if email == "" {
+		return nil
+	}
+
+	key := strings.ToLower(email)
+
+	return &key
+}
+
+// newUserID generates a new unique User.ID
+func newUserID() (string, error) {
+	id, err := nanoid.Nanoid(8)
+	if err != nil {
+		return "", fmt.Errorf("unable to generate UserID: %s", err)
+	}
+
+	return id, nil
+}
+
 // Create attempts to create the User object in the database
 func (u *User) Create(db *gorm.DB) error {
-	id, err := nanoid.Nanoid(8)
+	id, err := newUserID()
 	if err != nil {
-		return fmt.Errorf("unable to generate UserID: %s", err)
+		return err
 	}
 
 	u.ID = id
@@ -79,32 +155,80 @@ func (u *User) Create(db *gorm.DB) error {
 	return nil
 }
 
-// Update will attempt to update the current User object in the database
+// Update will attempt to update the current User object in the database. The password is kept unchanged when
+// the User.Password is empty. ErrStale is returned when the User has a Precondition which the stored user no
+// longer matches.
 func (u *User) Update(db *gorm.DB) error {
+	keepPassword := u.Password == ""
+
 	err := u.Prepare()
 	if err != nil {
 		return err
 	}
 
-	// Update only the specific columns
-	tx := db.Model(u).Where("id = ?", u.ID).Updates(
-		map[string]interface{}{
-			"name":     u.Name,
-			"password": u.Password,
-			"role":     u.Role,
-		},
-	).Take(u) // Update the current reference
+	columns := map[string]interface{}{
+		"name":          u.Name,
+		"password":      u.Password,
+		"role":          u.Role,
+		"email":         u.Email,
+		"email_key":     u.EmailKey,
+		"team_id":       u.TeamID,
+		"location_id":   u.LocationID,
+		"manager_id":    u.ManagerID,
+		"phone":         u.Phone,
+		"custom_fields": u.CustomFields,
+		"hourly_rate":   u.HourlyRate,
+	}
+
+	if keepPassword {
+		delete(columns, "password")
+	}
+
+	// Update only the specific columns, of the version read when conditional
+	tx := db.Model(u).Where("id = ?", u.ID)
+	if u.Precondition != "" {
+		current := &User{}
+
+		err = db.Select("id", "updated_at").Take(current, "id = ?", u.ID).Error
+		if err != nil {
+			return err
+		}
+
+		if current.ETag() != u.Precondition {
+			return ErrStale
+		}
+
+		tx = tx.Where("updated_at = ?", current.UpdatedAt)
+	}
+
+	tx = tx.Updates(columns)
 
 	err = tx.Error
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// Another write made since the version was read leaves no row to update
+	if u.Precondition != "" && tx.RowsAffected < 1 {
+		return ErrStale
+	}
+
+	// Update the current reference
+	return db.Take(u, "id = ?", u.ID).Error
 }
 
-// Delete will attempt to delete the User object from the database
-func (u *User) Delete(db *gorm.DB) error {
+// Deactivate will attempt to soft delete the User object from the database. Deactivated users cannot log in
+// and are excluded from queries unless explicitly included, while their past shifts are kept for reporting.
+// ErrStale is returned when the User has a Precondition and the stored user was changed since it was read.
+func (u *User) Deactivate(db *gorm.DB) error {
+	if u.Precondition != "" {
+		if u.ETag() != u.Precondition {
+			return ErrStale
+		}
+
+		db = db.Where("updated_at = ?", u.UpdatedAt)
+	}
+
 	tx := db.Delete(u)
 
 	err := tx.Error
@@ -113,33 +237,204 @@ func (u *User) Delete(db *gorm.DB) error {
 	}
 
 	if tx.RowsAffected == 0 {
-		return errors.New("user not found")
+		// Another write made since the user was read leaves no row to deactivate
+		if u.Precondition != "" {
+			return ErrStale
+		}
+
+		return &NotFoundError{Kind: "user"}
 	}
 
 	return nil
 }
 
-// AfterDelete hooks GORM to remove the associated Shift rows for ths user
-// when it is deleted
+// AfterDelete hooks GORM to withdraw the deactivated user from scheduling: their shifts yet to start are left
+// unassigned, they leave rotations, groups, bids, upcoming event signups and waitlists, their direct reports are left
+// without a manager, delegations made to or by them end, and their calendar feeds and pending email changes
+// are removed. Past shifts, leave and time off are kept.
 func (u *User) AfterDelete(db *gorm.DB) error {
-	return db.Model(&Shift{}).Where("user_id = ?", u.ID).Delete(&Shift{}).Error
+	now := time.Now()
+	upcoming := db.Session(&gorm.Session{NewDB: true}).Model(&Shift{}).Select("id").Where("start > ?", now)
+
+	err := updateShifts(db.Model(&Shift{}).Where("user_id = ? AND start > ?", u.ID, now), "user_id", "")
+	if err != nil {
+		return err
+	}
+
+	err = db.Where("user_id = ? AND shift_id IN (?)", u.ID, upcoming).Delete(&Signup{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Where("user_id = ? AND shift_id IN (?)", u.ID, upcoming).Delete(&WaitlistEntry{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Model(&User{}).Where("manager_id = ?", u.ID).Update("manager_id", "").Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Where("manager_id = ? OR delegate_id = ?", u.ID, u.ID).Delete(&Delegation{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Where("user_id = ?", u.ID).Delete(&RotationMember{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Where("user_id = ?", u.ID).Delete(&GroupMember{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Where("user_id = ?", u.ID).Delete(&ShiftBid{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Where("user_id = ?", u.ID).Delete(&EmailChange{}).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Where("user_id = ?", u.ID).Delete(&CalendarFeed{}).Error
 }
 
-// ListUsers attempts to return rows from the Users table with the specified limit
-// If limit specified is less than or equal to 0, result will not be limited.
-func ListUsers(db *gorm.DB, limit int) ([]*User, error) {
-	var users []*User
+// Reactivate will attempt to reinstate the deactivated User object
+func (u *User) Reactivate(db *gorm.DB) error {
+	u.DeactivatedAt = gorm.DeletedAt{}
+
+	return db.Unscoped().Model(u).Update("deleted_at", nil).Error
+}
 
-	if limit < 1 {
-		limit = -1
+// FindDeactivatedUserByID attempts to return a deactivated row from the Users table with the matching User.ID
+func FindDeactivatedUserByID(db *gorm.DB, uid string) (*User, error) {
+	user := &User{}
+	err := db.Unscoped().Where("deleted_at IS NOT NULL").First(&user, "id = ?", uid).Error
+	if err != nil {
+		return &User{}, notFound("user", err)
 	}
 
-	err := db.Model(&User{}).Limit(limit).Find(&users).Error
+	return user, nil
+}
+
+// userSorts maps the sort parameters accepted by ListUsers to the column they order by
+var userSorts = map[string]sortColumn{
+	"name":       {Column: "name"},
+	"role":       {Column: "role"},
+	"created_at": {Column: "created_at", Time: true},
+}
+
+// UserQuery selects, orders and pages the users returned by ListUsers
+type UserQuery struct {
+	Search string        // case insensitive part of the name
+	Role   string        // only users with the role when not empty
+	Fields []FieldFilter // only users whose custom fields hold every value
+	Sort   string        // comma separated name, role or created_at, prefixed with - to sort descending. Default: name
+	Limit  int           // users per page, unlimited when less than 1
+	Page   int           // page of Limit users to return starting from 1, ignored when Cursor is set
+	Cursor string        // returned as NextCursor by the previous page, continues after its last user
+}
+
+// UserPage holds a page of users, the total number matching the query across all pages,
+// and the cursor of the following page when there is one
+type UserPage struct {
+	Users      []*User
+	Total      int64
+	NextCursor string
+}
+
+// ListUsers attempts to return a page of rows from the Users table matching the query, along with the total
+// number of matches. Users are ordered by the sort columns and then ID, so cursors continue from a stable position.
+func ListUsers(db *gorm.DB, q UserQuery) (*UserPage, error) {
+	keys, err := parseSort(q.Sort, userSorts, "name")
 	if err != nil {
-		return []*User{}, err
+		return nil, err
 	}
 
-	return users, nil
+	tx := db.Model(&User{})
+
+	if q.Search != "" {
+		// Wildcards in the search are matched literally, escaped with a character every dialect accepts
+		escaper := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+		tx = tx.Where("LOWER(name) LIKE ? ESCAPE '!'", "%"+escaper.Replace(strings.ToLower(q.Search))+"%")
+	}
+
+	if q.Role != "" {
+		tx = tx.Where("role = ?", q.Role)
+	}
+
+	tx, err = filterFields(db, tx, q.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &UserPage{Users: []*User{}}
+
+	err = tx.Session(&gorm.Session{}).Count(&page.Total).Error
+	if err != nil {
+		return nil, err
+	}
+
+	tx = tx.Order(sortOrder(db, keys))
+
+	if q.Cursor != "" {
+		cursor, err := cursorFor(opaque.User, q.Cursor, keys)
+		if err != nil {
+			return nil, err
+		}
+
+		query, args, err := afterPosition(db, keys, cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		tx = tx.Where(query, args...)
+	} else if q.Page > 1 && q.Limit > 0 {
+		tx = tx.Offset((q.Page - 1) * q.Limit)
+	}
+
+	// Fetch one user past the page to tell whether another page follows
+	limit := -1
+	if q.Limit > 0 {
+		limit = q.Limit + 1
+	}
+
+	err = tx.Limit(limit).Find(&page.Users).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if q.Limit > 0 && len(page.Users) > q.Limit {
+		page.Users = page.Users[:q.Limit]
+		page.NextCursor, err = userCursor(keys, page.Users[q.Limit-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+// userCursor returns the cursor positioned at the user within the sort
+func userCursor(keys []sortKey, u *User) (string, error) {
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		switch key.Name {
+		case "name":
+			values[i] = u.Name
+		case "role":
+			values[i] = u.Role
+		case "created_at":
+			values[i] = u.CreatedAt.Format(time.RFC3339Nano)
+		}
+	}
+
+	return encodeCursor(opaque.User, keys, values, u.ID)
 }
 
 // FindUserByID attempts to return a row from the Users table with the matching User.ID
@@ -147,18 +442,78 @@ func FindUserByID(db *gorm.DB, uid string) (*User, error) {
 	user := &User{}
 	err := db.First(&user, "id = ?", uid).Error
 	if err != nil {
-		return &User{}, err
+		return &User{}, notFound("user", err)
 	}
 
 	return user, nil
 }
 
+// FindUsersByIDs attempts to return the rows from the Users table with any of the specified User.IDs in a single
+// query, in no particular order. IDs matching no user are left out.
+func FindUsersByIDs(db *gorm.DB, uids []string) ([]*User, error) {
+	users := []*User{}
+	if len(uids) == 0 {
+		return users, nil
+	}
+
+	err := db.Where("id IN ?", uids).Find(&users).Error
+	if err != nil {
+		return []*User{}, err
+	}
+
+	return users, nil
+}
+
+// ListTeamMembers attempts to return the rows from the Users table belonging to the specified Team.ID
+func ListTeamMembers(db *gorm.DB, tid string) ([]*User, error) {
+	var users []*User
+
+	err := db.Model(&User{}).Where("team_id = ?", tid).Order("name").Find(&users).Error
+	if err != nil {
+		return []*User{}, err
+	}
+
+	return users, nil
+}
+
+// FindUserNames returns the names of the specified User.IDs keyed by User.ID
+func FindUserNames(db *gorm.DB, uids []string) (map[string]string, error) {
+	var users []*User
+
+	names := make(map[string]string, len(uids))
+	if len(uids) == 0 {
+		return names, nil
+	}
+
+	err := db.Model(&User{}).Select("id", "name").Where("id IN ?", uids).Find(&users).Error
+	if err != nil {
+		return names, err
+	}
+
+	for _, user := range users {
+		names[user.ID] = user.Name
+	}
+
+	return names, nil
+}
+
 // FindUserByName attempts to return a row from the Users table with the matching User.Name
 func FindUserByName(db *gorm.DB, name string) (*User, error) {
 	user := &User{}
 	err := db.First(&user, "name = ?", name).Error
 	if err != nil {
-		return &User{}, err
+		return &User{}, notFound("user", err)
+	}
+
+	return user, nil
+}
+
+// FindUserByEmail attempts to return a row from the Users table with the matching User.Email, ignoring case
+func FindUserByEmail(db *gorm.DB, email string) (*User, error) {
+	user := &User{}
+	err := db.First(&user, "LOWER(email) = ?", strings.ToLower(strings.TrimSpace(email))).Error
+	if err != nil {
+		return &User{}, notFound("user", err)
 	}
 
 	return user, nil
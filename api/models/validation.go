@@ -0,0 +1,177 @@
+package models
+
+import (
+	"errors"
+	"gorm.io/gorm"
+)
+
+// FieldError describes why a field of an object is invalid, along with the Code of the limit or rule it
+// violates when it has one
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+
+	err error // the error of the check which failed
+}
+
+// FieldErrors is returned when an object fails validation, listing the error of each invalid field. It reads
+// as, and unwraps to, the error of the first field, so it still matches the class of that error.
+type FieldErrors struct {
+	Fields []*FieldError
+}
+
+func (e *FieldErrors) Error() string {
+	return e.Fields[0].Message
+}
+
+func (e *FieldErrors) Unwrap() error {
+	return e.Fields[0].err
+}
+
+// fieldCheck is a check of a single field, which is only made while the field has no other error
+type fieldCheck struct {
+	field string
+	check func() error
+}
+
+// limitFields names the field blamed for a violated limit or rule
+var limitFields = map[string]string{
+	LimitTooLong:         "end",
+	LimitTooFarAhead:     "start",
+	LimitInPast:          "end",
+	CertificationMissing: "user_id",
+	RuleInsufficientRest: "start",
+	RuleOvertimeCap:      "user_id",
+}
+
+// allInvalid makes every check, returning a *FieldErrors listing each field found invalid
+func allInvalid(checks []fieldCheck) error {
+	errs, err := checkFields(nil, checks)
+	if err != nil {
+		return err
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return &FieldErrors{Fields: errs}
+}
+
+// checkFields makes every check, appending the error of each field found invalid to errs. Errors other than
+// those of an invalid object, such as a failing database, are returned as is.
+func checkFields(errs []*FieldError, checks []fieldCheck) ([]*FieldError, error) {
+	failed := make(map[string]bool, len(errs))
+	for _, e := range errs {
+		failed[e.Field] = true
+	}
+
+	for _, c := range checks {
+		if failed[c.field] {
+			continue
+		}
+
+		err := c.check()
+		if err == nil {
+			continue
+		}
+
+		e := &FieldError{Field: c.field, Message: err.Error(), err: err}
+
+		var limit *ShiftLimitError
+		switch {
+		case errors.As(err, &limit):
+			e.Code = limit.Code
+			if field, ok := limitFields[limit.Code]; ok {
+				e.Field = field
+			}
+		case errors.Is(err, ErrOverlap):
+			e.Code = ConflictOverlap
+		case errors.Is(err, ErrInvalid), errors.Is(err, ErrNotFound), errors.Is(err, ErrEmailTaken),
+			errors.Is(err, ErrManagerCycle):
+		default:
+			return errs, err
+		}
+
+		failed[e.Field] = true
+		errs = append(errs, e)
+	}
+
+	return errs, nil
+}
+
+// ValidateUser makes every check made when saving the User without writing it, reporting each invalid field.
+// A User with an ID is checked as a change to that user.
+func ValidateUser(db *gorm.DB, u *User) ([]*FieldError, error) {
+	errs, err := checkFields([]*FieldError{}, u.checks())
+	if err != nil {
+		return errs, err
+	}
+
+	return checkFields(errs, []fieldCheck{
+		{"name", func() error {
+			existing, err := FindUserByName(db.Unscoped(), u.Name)
+			if err == nil && existing.ID != u.ID {
+				return invalid("user already exists")
+			}
+
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+
+			return err
+		}},
+		{"email", func() error {
+			return CheckEmailAvailable(db, u.ID, u.Email)
+		}},
+		{"team_id", func() error {
+			if u.TeamID == "" {
+				return nil
+			}
+
+			_, err := FindTeamByID(db, u.TeamID)
+
+			return notFound("team", err)
+		}},
+		{"location_id", func() error {
+			if u.LocationID == "" {
+				return nil
+			}
+
+			_, err := FindLocationByID(db, u.LocationID)
+
+			return notFound("location", err)
+		}},
+		{"manager_id", func() error {
+			if u.ManagerID == "" {
+				return nil
+			}
+
+			return CheckManager(db, u.ID, u.ManagerID)
+		}},
+		{"custom_fields", func() error {
+			return ValidateCustomFields(db, u.CustomFields)
+		}},
+	})
+}
+
+// ValidateShift makes every check made when a user of the role saves the Shift without writing it, reporting
+// each invalid field. A Shift with an ID is checked as a change to that shift.
+func ValidateShift(db *gorm.DB, s *Shift, role string) ([]*FieldError, error) {
+	errs, err := checkFields([]*FieldError{}, append(s.checks(), s.roleChecks(db, role)...))
+	if err != nil || len(errs) > 0 || s.UserID == "" {
+		return errs, err
+	}
+
+	return checkFields(errs, []fieldCheck{
+		{"user_id", func() error {
+			_, err := FindUserByID(db, s.UserID)
+
+			return notFound("user", err)
+		}},
+		{"start", func() error {
+			return s.BeforeSave(db)
+		}},
+	})
+}
@@ -0,0 +1,98 @@
+package models
+
+// The visibility of shifts to the users who are neither working them nor admins
+const (
+	VisibilityFull      = "full"      // the user working the shift is shown
+	VisibilityAnonymous = "anonymous" // only that the shift is covered is shown
+)
+
+// validVisibility reports whether v is a known visibility, empty inheriting that of the team
+func validVisibility(v string) bool {
+	switch v {
+	case "", VisibilityFull, VisibilityAnonymous:
+		return true
+	}
+
+	return false
+}
+
+// VisibleTo returns the visibility of the Shift, its own when set otherwise that of the team of its User
+func (s *Shift) VisibleTo(team string) string {
+	if s.Visibility != "" {
+		return s.Visibility
+	}
+
+	if team == "" {
+		return VisibilityFull
+	}
+
+	return team
+}
+
+// Anonymize strips the Shift down to a covered block, removing anything identifying the User working it
+func (s *Shift) Anonymize() {
+	s.UserID = ""
+	s.Color = ""
+	s.Metadata = nil
+	s.Tags = nil
+	s.CancelReason = ""
+	s.Anonymous = true
+}
+
+// AnonymizeSchedule applies the visibility of the shifts in the schedule rows of a team for the viewing
+// User. Anonymous shifts of other users are moved to a final row without a User, and the rows of
+// other users are left out entirely when the team itself is anonymous. The viewer's own row is untouched.
+func AnonymizeSchedule(rows []*ScheduleRow, viewer, team string) []*ScheduleRow {
+	var covered *ScheduleRow
+	result := make([]*ScheduleRow, 0, len(rows)+1)
+
+	for _, row := range rows {
+		if row.UserID == viewer {
+			result = append(result, row)
+			continue
+		}
+
+		if covered == nil {
+			covered = &ScheduleRow{Days: make([]*ScheduleDay, len(row.Days))}
+			for i, day := range row.Days {
+				covered.Days[i] = &ScheduleDay{Date: day.Date, Shifts: []*Shift{}}
+			}
+		}
+
+		for i, day := range row.Days {
+			shifts := day.Shifts[:0]
+			for _, shift := range day.Shifts {
+				if shift.VisibleTo(team) == VisibilityAnonymous {
+					shift.Anonymize()
+					covered.Days[i].Shifts = append(covered.Days[i].Shifts, shift)
+					continue
+				}
+
+				shifts = append(shifts, shift)
+			}
+
+			day.Shifts = shifts
+		}
+
+		if team != VisibilityAnonymous {
+			result = append(result, row)
+		}
+	}
+
+	if covered != nil && (team == VisibilityAnonymous || coveredShifts(covered)) {
+		result = append(result, covered)
+	}
+
+	return result
+}
+
+// coveredShifts reports whether the row holds any shifts
+func coveredShifts(row *ScheduleRow) bool {
+	for _, day := range row.Days {
+		if len(day.Shifts) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
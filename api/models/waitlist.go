@@ -0,0 +1,141 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// WaitlistEntry struct represents a User waiting for a slot of a full event Shift to free up.
+// Entries are promoted to signups in the order they were created.
+type WaitlistEntry struct {
+	ShiftID   string    `gorm:"primaryKey" json:"shift_id"`
+	UserID    string    `gorm:"primaryKey" json:"user_id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// JoinWaitlist attempts to add the specified User.ID to the waitlist of the full event
+func (s *Shift) JoinWaitlist(db *gorm.DB, uid string) error {
+	if !s.IsEvent() {
+		return ErrNotEvent
+	}
+
+	return transaction(db, func(tx *gorm.DB) error {
+		err := s.lock(tx)
+		if err != nil {
+			return err
+		}
+
+		var count int64
+
+		err = tx.Model(&Signup{}).Where("shift_id = ? AND user_id = ?", s.ID, uid).Count(&count).Error
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return ErrAlreadySignedUp
+		}
+
+		err = tx.Model(&WaitlistEntry{}).Where("shift_id = ? AND user_id = ?", s.ID, uid).Count(&count).Error
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return ErrAlreadyWaiting
+		}
+
+		err = tx.Model(&Signup{}).Where("shift_id = ?", s.ID).Count(&count).Error
+		if err != nil {
+			return err
+		}
+
+		if int(count) < s.Capacity {
+			return ErrEventNotFull
+		}
+
+		return tx.Create(&WaitlistEntry{ShiftID: s.ID, UserID: uid}).Error
+	})
+}
+
+// LeaveWaitlist attempts to remove the specified User.ID from the waitlist of the event
+func (s *Shift) LeaveWaitlist(db *gorm.DB, uid string) error {
+	tx := db.Where("shift_id = ? AND user_id = ?", s.ID, uid).Delete(&WaitlistEntry{})
+
+	err := tx.Error
+	if err != nil {
+		return err
+	}
+
+	if tx.RowsAffected == 0 {
+		return ErrNotWaiting
+	}
+
+	return nil
+}
+
+// PromoteWaitlist signs up waitlisted users, first come first served, until the event is full again.
+// The promoted User.IDs are returned.
+func (s *Shift) PromoteWaitlist(db *gorm.DB) ([]string, error) {
+	var promoted []string
+
+	if !s.IsEvent() {
+		return promoted, nil
+	}
+
+	err := transaction(db, func(tx *gorm.DB) error {
+		err := s.lock(tx)
+		if err != nil {
+			return err
+		}
+
+		var count int64
+
+		err = tx.Model(&Signup{}).Where("shift_id = ?", s.ID).Count(&count).Error
+		if err != nil {
+			return err
+		}
+
+		free := s.Capacity - int(count)
+		if free < 1 {
+			return nil
+		}
+
+		var entries []*WaitlistEntry
+
+		err = tx.Where("shift_id = ?", s.ID).Order("created_at").Limit(free).Find(&entries).Error
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			err = tx.Create(&Signup{ShiftID: s.ID, UserID: entry.UserID}).Error
+			if err != nil {
+				return err
+			}
+
+			err = tx.Where("shift_id = ? AND user_id = ?", entry.ShiftID, entry.UserID).Delete(&WaitlistEntry{}).Error
+			if err != nil {
+				return err
+			}
+
+			promoted = append(promoted, entry.UserID)
+		}
+
+		return nil
+	})
+
+	return promoted, err
+}
+
+// ListWaitlist attempts to return the waitlist of the specified Shift.ID in promotion order
+func ListWaitlist(db *gorm.DB, sid string) ([]*WaitlistEntry, error) {
+	var entries []*WaitlistEntry
+
+	err := db.Model(&WaitlistEntry{}).Where("shift_id = ?", sid).Order("created_at").Find(&entries).Error
+	if err != nil {
+		return []*WaitlistEntry{}, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,166 @@
+package policy
+
+import (
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Policy describes the access requirements declared for a route
+type Policy struct {
+	Public bool     `json:"public"`
+	Roles  []string `json:"roles,omitempty"`
+	Signed bool     `json:"signed,omitempty"`
+}
+
+var (
+	// Public routes are reachable without authentication
+	Public = Policy{Public: true}
+
+	// User routes are reachable by any authenticated user
+	User = Policy{Roles: []string{"user", "admin"}}
+
+	// Admin routes are reachable only by authenticated admins
+	Admin = Policy{Roles: []string{"admin"}}
+
+	// Privileged routes are reachable only by authenticated admins and, when request
+	// signing is enabled, only with a valid request signature
+	Privileged = Policy{Roles: []string{"admin"}, Signed: true}
+)
+
+// Allows reports whether the policy grants access to the specified role
+func (p Policy) Allows(role string) bool {
+	if p.Public {
+		return true
+	}
+
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Route represents a registered route and the policy it declared
+type Route struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Policy
+}
+
+// Registry records the declared policy of every route registered through it
+type Registry struct {
+	mu     sync.RWMutex
+	routes map[string]Route
+}
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		routes: make(map[string]Route),
+	}
+}
+
+func routeKey(method, path string) string {
+	return method + " " + path
+}
+
+// Declare records the policy for the route matching the method and path
+func (r *Registry) Declare(method, path string, p Policy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routes[routeKey(method, path)] = Route{
+		Method: method,
+		Path:   path,
+		Policy: p,
+	}
+}
+
+// Lookup returns the declared route matching the method and path, if any
+func (r *Registry) Lookup(method, path string) (Route, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	route, ok := r.routes[routeKey(method, path)]
+	return route, ok
+}
+
+// Routes returns all declared routes ordered by path then method
+func (r *Registry) Routes() []Route {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	routes := make([]Route, 0, len(r.routes))
+	for _, route := range r.routes {
+		routes = append(routes, route)
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path == routes[j].Path {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Path < routes[j].Path
+	})
+
+	return routes
+}
+
+// Allowed returns the declared routes an authenticated user with the role may reach, ordered as Routes
+func (r *Registry) Allowed(role string) []Route {
+	var allowed []Route
+	for _, route := range r.Routes() {
+		if !route.Public && route.Allows(role) {
+			allowed = append(allowed, route)
+		}
+	}
+
+	return allowed
+}
+
+// notFoundName is the handler name echo uses for the catch-all routes it adds to groups with middleware
+var notFoundName = runtime.FuncForPC(reflect.ValueOf(echo.NotFoundHandler).Pointer()).Name()
+
+// Undeclared returns the routes registered on the echo instance which have no declared policy
+func Undeclared(e *echo.Echo, r *Registry) []string {
+	var missing []string
+
+	for _, route := range e.Routes() {
+		if route.Name == notFoundName {
+			continue
+		}
+
+		if _, ok := r.Lookup(route.Method, route.Path); !ok {
+			missing = append(missing, routeKey(route.Method, route.Path))
+		}
+	}
+
+	sort.Strings(missing)
+
+	return missing
+}
+
+// TestingT is the subset of testing.TB used by AssertDeclared
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// AssertDeclared fails the test for every route registered on the echo instance
+// which has no declared policy, catching routes accidentally left unprotected
+func AssertDeclared(t TestingT, e *echo.Echo, r *Registry) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	missing := Undeclared(e, r)
+	for _, route := range missing {
+		t.Errorf("route %s does not declare an access policy", route)
+	}
+
+	return len(missing) == 0
+}
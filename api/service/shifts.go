@@ -0,0 +1,369 @@
+// Package service makes the changes to shifts shared by the REST API and the gRPC API, deciding whether the user
+// making them may, and letting those affected know, so that both transports apply the same rules.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/middleware"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/logging"
+	"github.com/btnmasher/shiftr/notify"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// ErrForbidden is returned when the user may not make the change they asked for
+var ErrForbidden = errors.New("permission denied")
+
+// Caller is the user making a change
+type Caller struct {
+	ID   string
+	Role string
+}
+
+// Precondition checks the version of a shift a change was made to, given the ETag of the shift as it now stands,
+// failing when the change was made to another
+type Precondition func(etag string) error
+
+// Shifts makes changes to shifts on behalf of their callers
+type Shifts struct {
+	Notifier notify.Notifier // nil sends no notifications
+	Approval bool            // shifts users create or reschedule await approval
+	Log      *zerolog.Logger // nil logs to logging.Default()
+}
+
+// Visible ensures the caller may see the shift. Users may see the published shifts of their own and of the users
+// reporting to them, along with their own awaiting approval.
+func Visible(db *gorm.DB, c Caller, shift *models.Shift) error {
+	if c.Role != "user" {
+		return nil
+	}
+
+	if c.ID != shift.UserID {
+		manages, err := models.Manages(db, c.ID, shift.UserID)
+		if err != nil {
+			return err
+		}
+
+		if !manages {
+			return ErrForbidden
+		}
+	}
+
+	if !UserVisible(shift) {
+		return &models.NotFoundError{Kind: "shift"}
+	}
+
+	return nil
+}
+
+// UserVisible reports whether a user may see their own shift, which they may once it is published
+// or while it awaits approval
+func UserVisible(shift *models.Shift) bool {
+	return shift.Status == models.ShiftPublished || shift.Status == models.ShiftPending
+}
+
+// owned ensures a user works the shift they are changing, and may still see it
+func owned(c Caller, shift *models.Shift) error {
+	if c.Role != "user" {
+		return nil
+	}
+
+	if c.ID != shift.UserID {
+		return ErrForbidden
+	}
+
+	if !UserVisible(shift) {
+		return &models.NotFoundError{Kind: "shift"}
+	}
+
+	return nil
+}
+
+// assignable ensures a shift may be assigned to the specified User.ID, which must exist and not be deactivated
+func assignable(db *gorm.DB, uid string) error {
+	if uid == "" {
+		return nil
+	}
+
+	_, err := models.FindUserByID(db, uid)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) || errors.Is(err, gorm.ErrRecordNotFound) {
+			return &models.ValidationError{Message: "user not found"}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// rescheduled reports whether the change moves the start or end of the shift
+func rescheduled(shift, change *models.Shift) bool {
+	return !change.Start.Equal(shift.Start) || !change.End.Equal(shift.End)
+}
+
+// Create writes the new shift after checking the caller may, returning the users it affects. Users may only create
+// published shifts for themselves, which await approval instead when it is required.
+func (s *Shifts) Create(ctx context.Context, db *gorm.DB, c Caller, shift *models.Shift) ([]string, error) {
+	// Ensure we have all necessary fields to create the object
+	err := shift.ValidateFor(db, c.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	// Constrain the user from creating a shift object for another user or an unpublished shift if not admin
+	if c.Role == "user" {
+		if c.ID != shift.UserID {
+			return nil, ErrForbidden
+		}
+
+		if shift.Status != "" && shift.Status != models.ShiftPublished {
+			return nil, ErrForbidden
+		}
+
+		// Shifts users create for themselves await approval when required
+		if s.Approval {
+			shift.Status = models.ShiftPending
+		}
+	}
+
+	err = assignable(db, shift.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Attempt to write the new object to the database
+	err = shift.Create(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Let the approvers know the shift is waiting for them
+	if shift.Status == models.ShiftPending {
+		s.notifyApprovers(ctx, db, shift)
+	}
+
+	return []string{shift.UserID}, nil
+}
+
+// Update writes the change to the shift after checking the caller may make it, returning the users it affects.
+// Users may only move their own shifts and change how they appear, and a published shift they move awaits approval
+// again when it is required. Raising the capacity of an event signs up those waitlisted for the slots opened up.
+func (s *Shifts) Update(ctx context.Context, db *gorm.DB, c Caller, shift, change *models.Shift, match Precondition) ([]string, error) {
+	// Constrain the user from changing the worker, status, capacity, location or position of the shift if not admin
+	err := owned(c, shift)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Role == "user" {
+		if change.Status != shift.Status || change.UserID != shift.UserID || change.Capacity != shift.Capacity {
+			return nil, ErrForbidden
+		}
+
+		if change.LocationID != shift.LocationID || change.PositionID != shift.PositionID {
+			return nil, ErrForbidden
+		}
+
+		// A shift the user moves awaits approval again when required
+		if s.Approval && change.Status == models.ShiftPublished && rescheduled(shift, change) {
+			change.Status = models.ShiftPending
+		}
+	}
+
+	// Refuse changes made to a version of the shift which has since been changed
+	err = match(shift.ETag())
+	if err != nil {
+		return nil, err
+	}
+
+	change.Precondition = shift.ETag()
+
+	// Ensure the resulting object is still valid
+	err = change.ValidateFor(db, c.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	if change.UserID != shift.UserID {
+		err = assignable(db, change.UserID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Attempt to write the new object to the database
+	err = change.Update(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Both the previous and the new worker are affected when the shift is reassigned
+	affected := []string{shift.UserID, change.UserID}
+
+	// Fill any slots opened up by raising the capacity of an event from its waitlist
+	if change.Capacity > shift.Capacity {
+		promoted, err := change.PromoteWaitlist(db)
+		if err != nil {
+			return nil, err
+		}
+
+		affected = append(affected, promoted...)
+		s.NotifyPromoted(ctx, db, change, promoted)
+	}
+
+	// Let the workers know when someone else changes a shift they can see, and the approvers when it awaits them anew
+	switch {
+	case change.Status == models.ShiftPublished:
+		s.NotifyChanged(ctx, db, c.ID, shift, change)
+	case change.Status == models.ShiftPending && rescheduled(shift, change):
+		s.notifyApprovers(ctx, db, change)
+	}
+
+	return affected, nil
+}
+
+// Cancel cancels the shift for the reason after checking the caller may, returning the users it affects. Users may
+// cancel their own published shifts and withdraw their own awaiting approval. The shift remains in the database
+// for reporting, and may be restored by the user who cancelled it.
+func (s *Shifts) Cancel(ctx context.Context, db *gorm.DB, c Caller, shift *models.Shift, reason string, match Precondition) ([]string, error) {
+	err := owned(c, shift)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refuse to cancel a version of the shift which has since been changed
+	err = match(shift.ETag())
+	if err != nil {
+		return nil, err
+	}
+
+	shift.Precondition = shift.ETag()
+	shift.CancelledBy = c.ID
+
+	err = shift.Cancel(db, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{shift.UserID}, nil
+}
+
+// Restore reinstates the cancelled shift after checking the caller may, returning the users it affects. Users may
+// only restore the shifts of their own they cancelled themselves, and it fails if its worker can no longer work it.
+func (s *Shifts) Restore(ctx context.Context, db *gorm.DB, c Caller, shift *models.Shift, match Precondition) ([]string, error) {
+	if c.Role == "user" && (c.ID != shift.UserID || c.ID != shift.CancelledBy) {
+		return nil, ErrForbidden
+	}
+
+	// Refuse to restore a version of the shift which has since been changed
+	err := match(shift.ETag())
+	if err != nil {
+		return nil, err
+	}
+
+	shift.Precondition = shift.ETag()
+
+	err = shift.Restore(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{shift.UserID}, nil
+}
+
+// NotifyChanged lets the workers of a shift changed by the specified User.ID know about it, both the previous
+// and the new worker when it was reassigned
+func (s *Shifts) NotifyChanged(ctx context.Context, db *gorm.DB, uid string, before, after *models.Shift) {
+	messages := map[string]func(l *models.Localization) string{}
+
+	if after.UserID != "" {
+		messages[after.UserID] = func(l *models.Localization) string {
+			return fmt.Sprintf("Your shift is now from %s to %s", l.Format(after.Start), l.Format(after.End))
+		}
+	}
+
+	if before.UserID != "" && before.UserID != after.UserID {
+		messages[before.UserID] = func(l *models.Localization) string {
+			return fmt.Sprintf("Your shift from %s to %s has been reassigned", l.Format(before.Start), l.Format(before.End))
+		}
+	}
+
+	for wid, body := range messages {
+		if wid == uid {
+			continue
+		}
+
+		user, err := models.FindUserByID(db, wid)
+		if err != nil {
+			s.logger(ctx).Error().Err(err).Msg("shift change notification")
+			continue
+		}
+
+		s.notify(ctx, db, user, notify.EventShiftChanged, "Shift changed", body)
+	}
+}
+
+// NotifyPromoted lets the specified User.IDs know they were signed up for the event from its waitlist
+func (s *Shifts) NotifyPromoted(ctx context.Context, db *gorm.DB, shift *models.Shift, uids []string) {
+	for _, uid := range uids {
+		user, err := models.FindUserByID(db, uid)
+		if err != nil {
+			s.logger(ctx).Error().Err(err).Msg("waitlist promotion")
+			continue
+		}
+
+		s.notify(ctx, db, user, notify.EventWaitlistPromoted, "You're off the waitlist",
+			func(l *models.Localization) string {
+				return fmt.Sprintf("A slot opened up and you are now signed up for the event from %s to %s",
+					l.Format(shift.Start), l.Format(shift.End))
+			})
+	}
+}
+
+// notifyApprovers lets the approvers of the worker of the pending shift know it is waiting for them
+func (s *Shifts) notifyApprovers(ctx context.Context, db *gorm.DB, shift *models.Shift) {
+	approvers, err := models.Approvers(db, shift.UserID)
+	if err != nil {
+		s.logger(ctx).Error().Err(err).Msg("approval")
+		return
+	}
+
+	for _, approver := range approvers {
+		s.notify(ctx, db, approver, notify.EventShiftApproval, "Shift awaiting approval",
+			func(l *models.Localization) string {
+				return fmt.Sprintf("A shift from %s to %s is awaiting your approval",
+					l.Format(shift.Start), l.Format(shift.End))
+			})
+	}
+}
+
+// notify sends the user a notification in their locale, logging rather than failing the change when it cannot be
+func (s *Shifts) notify(ctx context.Context, db *gorm.DB, user *models.User, event, subject string,
+	body func(l *models.Localization) string) {
+	if s.Notifier == nil {
+		return
+	}
+
+	l := models.FindLocalization(db, user.ID)
+	err := s.Notifier.Notify(ctx, notify.Message{
+		UserID:  user.ID,
+		To:      user.Email,
+		Subject: subject,
+		Event:   event,
+		Locale:  l.Locale,
+		Body:    body(l),
+	})
+	if err != nil {
+		s.logger(ctx).Error().Err(err).Str("event", event).Msg("notification")
+	}
+}
+
+// logger returns the logger of the service adding the ID of the request the context serves to every line
+func (s *Shifts) logger(ctx context.Context) *zerolog.Logger {
+	l := logging.Or(s.Log).With().Str("request_id", middleware.RequestIDFrom(ctx)).Logger()
+	return &l
+}
@@ -0,0 +1,830 @@
+// Package dbtest is a conformance suite for the stores shiftr keeps its models in. Run it from a test against
+// a Store of your own, or against Gorm for a new gorm dialector or a database reached through a supported one, to
+// verify it behaves as the supported databases do: shifts overlap, filter and page the same, batches of shift
+// operations are applied all or nothing, users page the same, writes conditional on the ETag of the version
+// read apply only to that version, requests with an Idempotency-Key are recorded once and replayed, and changes
+// to shifts are announced once they are kept.
+//
+//	func TestConformance(t *testing.T) {
+//		dbtest.Run(t, func(t *testing.T) dbtest.Store {
+//			db, err := gorm.Open(mydialector.Open(dsn), &gorm.Config{})
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//
+//			return dbtest.Gorm(db)
+//		})
+//	}
+//
+// The suite gives each store the settings it needs with Store.WithSettings, so it may run alongside anything else.
+package dbtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/events"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Open returns a new, empty store for a single test of the suite
+type Open func(t *testing.T) Store
+
+// Run runs every test of the suite against its own store, migrated before the test starts
+func Run(t *testing.T, open Open) {
+	tests := []struct {
+		name string
+		test func(*testing.T, Store)
+	}{
+		{"ShiftOverlap", ShiftOverlap},
+		{"ShiftFilters", ShiftFilters},
+		{"ShiftPagination", ShiftPagination},
+		{"UserPagination", UserPagination},
+		{"ShiftBatch", ShiftBatch},
+		{"ConditionalWrites", ConditionalWrites},
+		{"IdempotentRequests", IdempotentRequests},
+		{"ShiftEvents", ShiftEvents},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			store := open(t)
+
+			err := store.Migrate()
+			if err != nil {
+				t.Fatalf("migrating: %s", err)
+			}
+
+			tt.test(t, store)
+		})
+	}
+}
+
+// defaults returns the store without shift limits or scheduling rules, so only the behaviour of the database
+// is under test
+func defaults(store Store) Store {
+	return store.WithSettings(models.Settings{})
+}
+
+// base is the day the suite's shifts are scheduled on, far enough ahead that no rule about the past applies
+var base = time.Date(2100, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+// at returns the time the hours into the suite's day
+func at(hours int) time.Time {
+	return base.Add(time.Duration(hours) * time.Hour)
+}
+
+func createUser(t *testing.T, store Store, name, role, team string) *models.User {
+	t.Helper()
+
+	user := &models.User{Name: name, Password: "conformance", Role: role, TeamID: team}
+
+	err := store.CreateUser(user)
+	if err != nil {
+		t.Fatalf("creating user %s: %s", name, err)
+	}
+
+	return user
+}
+
+func createShift(t *testing.T, store Store, shift *models.Shift) *models.Shift {
+	t.Helper()
+
+	err := store.CreateShift(shift)
+	if err != nil {
+		t.Fatalf("creating shift from %s to %s: %s", shift.Start, shift.End, err)
+	}
+
+	return shift
+}
+
+// ShiftOverlap verifies that a user's shifts may not intersect one another, while shifts which
+// only touch, belong to someone else, are unassigned, or were cancelled do not conflict
+func ShiftOverlap(t *testing.T, store Store) {
+	store = defaults(store)
+
+	worker := createUser(t, store, "overlapworker", "user", "")
+	other := createUser(t, store, "overlapother", "user", "")
+
+	shift := createShift(t, store, &models.Shift{UserID: worker.ID, Start: at(9), End: at(17)})
+
+	overlapping := []struct {
+		name       string
+		start, end int
+	}{
+		{"within", 10, 11},
+		{"across the start", 8, 10},
+		{"across the end", 16, 18},
+		{"around", 8, 18},
+		{"identical", 9, 17},
+	}
+
+	for _, tt := range overlapping {
+		err := store.CreateShift(&models.Shift{UserID: worker.ID, Start: at(tt.start), End: at(tt.end)})
+		if !errors.Is(err, models.ErrOverlap) {
+			t.Errorf("shift %s an existing shift: got error %v, want %v", tt.name, err, models.ErrOverlap)
+		}
+
+		var overlap *models.OverlapError
+		if errors.As(err, &overlap) && (len(overlap.Conflicts) != 1 || overlap.Conflicts[0].ID != shift.ID) {
+			t.Errorf("shift %s an existing shift: got %d conflicts, want only the existing shift", tt.name, len(overlap.Conflicts))
+		}
+	}
+
+	createShift(t, store, &models.Shift{UserID: worker.ID, Start: at(17), End: at(19)})
+	createShift(t, store, &models.Shift{UserID: worker.ID, Start: at(7), End: at(9)})
+	createShift(t, store, &models.Shift{UserID: other.ID, Start: at(9), End: at(17)})
+	createShift(t, store, &models.Shift{Start: at(9), End: at(17)})
+
+	// Saving a shift unchanged must not find it overlapping itself
+	err := store.UpdateShift(shift)
+	if err != nil {
+		t.Errorf("updating a shift without changes: %s", err)
+	}
+
+	late := createShift(t, store, &models.Shift{UserID: worker.ID, Start: at(20), End: at(22)})
+	late.Start, late.End = at(18), at(21)
+
+	err = store.UpdateShift(late)
+	if !errors.Is(err, models.ErrOverlap) {
+		t.Errorf("moving a shift onto another: got error %v, want %v", err, models.ErrOverlap)
+	}
+
+	// Cancelled shifts free their time span
+	err = store.CancelShift(shift, "conformance")
+	if err != nil {
+		t.Fatalf("cancelling shift: %s", err)
+	}
+
+	createShift(t, store, &models.Shift{UserID: worker.ID, Start: at(10), End: at(11)})
+}
+
+// ShiftFilters verifies that ListShifts orders shifts by their start and applies each filter
+func ShiftFilters(t *testing.T, store Store) {
+	store = defaults(store)
+
+	first := createUser(t, store, "filterfirst", "user", "team-a")
+	second := createUser(t, store, "filtersecond", "user", "team-a")
+	third := createUser(t, store, "filterthird", "user", "team-b")
+
+	// Timestamps left zero are set to now, long before the base the others are set around
+	early := createShift(t, store, &models.Shift{UserID: first.ID, Start: at(6), End: at(10), LocationID: "north",
+		Tags: models.Tags{"training", "night_shift"}, CreatedAt: at(-48)})
+	day := createShift(t, store, &models.Shift{UserID: second.ID, Start: at(9), End: at(17), PositionID: "cook",
+		Tags: models.Tags{"training"}, UpdatedAt: at(-24)})
+	late := createShift(t, store, &models.Shift{UserID: third.ID, Start: at(16), End: at(24), LocationID: "north",
+		PositionID: "cook", Tags: models.Tags{"nightxshift"}})
+	draft := createShift(t, store, &models.Shift{UserID: first.ID, Start: at(30), End: at(34), Status: models.ShiftDraft})
+	cancelled := createShift(t, store, &models.Shift{UserID: second.ID, Start: at(30), End: at(34)})
+
+	err := store.CancelShift(cancelled, "conformance")
+	if err != nil {
+		t.Fatalf("cancelling shift: %s", err)
+	}
+
+	filters := []struct {
+		name   string
+		filter ShiftFilter
+		want   []*models.Shift
+	}{
+		{"none", ShiftFilter{}, []*models.Shift{early, day, late, draft}},
+		{"user", ShiftFilter{UserID: first.ID}, []*models.Shift{early, draft}},
+		{"team", ShiftFilter{TeamID: "team-a"}, []*models.Shift{early, day, draft}},
+		{"start", ShiftFilter{Start: at(9)}, []*models.Shift{day, late, draft}},
+		{"end", ShiftFilter{End: at(17)}, []*models.Shift{early, day}},
+		{"starts before", ShiftFilter{StartsBefore: at(9)}, []*models.Shift{early}},
+		{"ends after", ShiftFilter{EndsAfter: at(17)}, []*models.Shift{late, draft}},
+		{"active", ShiftFilter{ActiveAt: at(9)}, []*models.Shift{early, day}},
+		{"location", ShiftFilter{LocationID: "north"}, []*models.Shift{early, late}},
+		{"position", ShiftFilter{PositionID: "cook"}, []*models.Shift{day, late}},
+		{"status", ShiftFilter{Status: models.ShiftDraft}, []*models.Shift{draft}},
+		{"tag", ShiftFilter{Tags: []string{"training"}}, []*models.Shift{early, day}},
+		{"tags", ShiftFilter{Tags: []string{"training", "night_shift"}}, []*models.Shift{early}},
+		{"created after", ShiftFilter{CreatedAfter: at(-49)}, []*models.Shift{early}},
+		{"created before", ShiftFilter{CreatedBefore: at(-49)}, []*models.Shift{day, late, draft}},
+		{"updated", ShiftFilter{UpdatedAfter: at(-25), UpdatedBefore: at(-23)}, []*models.Shift{day}},
+		{"limit", ShiftFilter{Limit: 2}, []*models.Shift{early, day}},
+		{"cancelled", ShiftFilter{IncludeCancelled: true, Start: at(30)}, []*models.Shift{draft, cancelled}},
+		{"combined", ShiftFilter{LocationID: "north", PositionID: "cook"}, []*models.Shift{late}},
+	}
+
+	for _, tt := range filters {
+		shifts, err := store.ListShifts(tt.filter)
+		if err != nil {
+			t.Errorf("listing shifts filtered by %s: %s", tt.name, err)
+			continue
+		}
+
+		if !sameShifts(shifts, tt.want, tt.name != "cancelled") {
+			t.Errorf("listing shifts filtered by %s: got %v, want %v", tt.name, shiftIDs(shifts), shiftIDs(tt.want))
+		}
+	}
+}
+
+// ShiftPagination verifies that PageShifts counts every matching shift, and that page numbers and cursors
+// select them in order with PageShifts and ListShifts, including shifts starting at the same time and sorts
+// by several columns in either direction
+func ShiftPagination(t *testing.T, store Store) {
+	store = defaults(store)
+
+	for i := 0; i < 7; i++ {
+		user := createUser(t, store, fmt.Sprintf("pageworker%d", i), "user", "")
+
+		// Pairs of shifts start together so the order falls back to their IDs
+		createShift(t, store, &models.Shift{UserID: user.ID, Start: at(i / 2 * 8), End: at(i/2*8 + 4)})
+	}
+
+	// The order of IDs follows the collation of the database, so the full listing is the reference
+	all, err := store.PageShifts(models.ShiftPageQuery{})
+	if err != nil {
+		t.Fatalf("listing shifts: %s", err)
+	}
+
+	want := all.Shifts
+	if len(want) != 7 || all.Total != 7 || all.NextCursor != "" {
+		t.Fatalf("listing shifts: got %d of %d with cursor %q, want 7 of 7 without", len(want), all.Total,
+			all.NextCursor)
+	}
+
+	for i := 1; i < len(want); i++ {
+		if want[i].Start.Before(want[i-1].Start) {
+			t.Errorf("listing shifts: got %v, want them ordered by start", shiftIDs(want))
+			break
+		}
+	}
+
+	page, err := store.PageShifts(models.ShiftPageQuery{Page: 2, PerPage: 3})
+	if err != nil {
+		t.Fatalf("listing shifts by page: %s", err)
+	}
+
+	if !sameShifts(page.Shifts, want[3:6], true) || page.Total != int64(len(want)) {
+		t.Errorf("listing the second page of shifts: got %v of %d, want %v of %d", shiftIDs(page.Shifts), page.Total,
+			shiftIDs(want[3:6]), len(want))
+	}
+
+	// Walking the cursors visits every shift once in order
+	var seen []*models.Shift
+	cursor := ""
+
+	for i := 0; i <= len(want); i++ {
+		page, err = store.PageShifts(models.ShiftPageQuery{PerPage: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("listing shifts from cursor %q: %s", cursor, err)
+		}
+
+		seen = append(seen, page.Shifts...)
+
+		cursor = page.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+
+	if !sameShifts(seen, want, true) {
+		t.Errorf("walking shifts with cursors: got %v, want %v", shiftIDs(seen), shiftIDs(want))
+	}
+
+	// ListShifts continues from a cursor too, whatever page it came from
+	cursor, err = models.ShiftCursor(want[2], "")
+	if err != nil {
+		t.Fatalf("making a cursor: %s", err)
+	}
+
+	shifts, err := store.ListShifts(ShiftFilter{AfterCursor: cursor, Limit: 2})
+	if err != nil {
+		t.Fatalf("listing shifts after a cursor: %s", err)
+	}
+
+	if !sameShifts(shifts, want[3:5], true) {
+		t.Errorf("listing shifts after a cursor: got %v, want %v", shiftIDs(shifts), shiftIDs(want[3:5]))
+	}
+
+	_, err = store.PageShifts(models.ShiftPageQuery{PerPage: 2, Cursor: "not a cursor"})
+	if !errors.Is(err, models.ErrInvalid) {
+		t.Errorf("listing shifts from a malformed cursor: got error %v, want %v", err, models.ErrInvalid)
+	}
+
+	_, err = store.PageShifts(models.ShiftPageQuery{Sort: "-start", PerPage: 2, Cursor: cursor})
+	if !errors.Is(err, models.ErrInvalid) {
+		t.Errorf("listing shifts from a cursor of another sort: got error %v, want %v", err, models.ErrInvalid)
+	}
+
+	// Sorting by several columns in either direction walks every shift once in the order ListShifts gives
+	for _, sort := range []string{"-start", "end,-created_at", "status,-end", "-updated_at,start"} {
+		want, err := store.ListShifts(ShiftFilter{Sort: sort})
+		if err != nil {
+			t.Fatalf("listing shifts sorted by %s: %s", sort, err)
+		}
+
+		var seen []*models.Shift
+		cursor := ""
+
+		for i := 0; i <= len(want); i++ {
+			page, err = store.PageShifts(models.ShiftPageQuery{Sort: sort, PerPage: 3, Cursor: cursor})
+			if err != nil {
+				t.Fatalf("listing shifts sorted by %s from cursor %q: %s", sort, cursor, err)
+			}
+
+			seen = append(seen, page.Shifts...)
+
+			cursor = page.NextCursor
+			if cursor == "" {
+				break
+			}
+		}
+
+		if len(want) != 7 || !sameShifts(seen, want, true) {
+			t.Errorf("walking shifts sorted by %s with cursors: got %v, want %v", sort, shiftIDs(seen), shiftIDs(want))
+		}
+	}
+
+	shifts, err = store.ListShifts(ShiftFilter{Sort: "-start"})
+	if err != nil {
+		t.Fatalf("listing shifts sorted by -start: %s", err)
+	}
+
+	for i := 1; i < len(shifts); i++ {
+		if shifts[i].Start.After(shifts[i-1].Start) {
+			t.Errorf("listing shifts sorted by -start: got %v, want them ordered by start descending", shiftIDs(shifts))
+			break
+		}
+	}
+
+	for _, sort := range []string{"user_id", "start;DROP TABLE shifts", "start,-start"} {
+		_, err = store.PageShifts(models.ShiftPageQuery{Sort: sort})
+		if !errors.Is(err, models.ErrInvalid) {
+			t.Errorf("paging shifts sorted by %q: got error %v, want %v", sort, err, models.ErrInvalid)
+		}
+
+		_, err = store.ListShifts(ShiftFilter{Sort: sort})
+		if !errors.Is(err, models.ErrInvalid) {
+			t.Errorf("listing shifts sorted by %q: got error %v, want %v", sort, err, models.ErrInvalid)
+		}
+	}
+
+	_, err = store.ListShifts(ShiftFilter{AfterCursor: "not a cursor"})
+	if !errors.Is(err, models.ErrInvalid) {
+		t.Errorf("listing shifts after a malformed cursor: got error %v, want %v", err, models.ErrInvalid)
+	}
+}
+
+// ShiftBatch verifies that RunShiftBatch applies every operation of a batch in one transaction, and that a batch
+// with an operation which cannot be applied leaves every shift as it was
+func ShiftBatch(t *testing.T, store Store) {
+	store = defaults(store)
+
+	user := createUser(t, store, "batchworker", "user", "")
+
+	moved := createShift(t, store, &models.Shift{UserID: user.ID, Start: at(0), End: at(4)})
+	dropped := createShift(t, store, &models.Shift{UserID: user.ID, Start: at(8), End: at(12)})
+
+	results, err := store.RunShiftBatch([]*models.ShiftOperation{
+		{Op: models.BatchCreate, Shift: &models.Shift{UserID: user.ID, Start: at(16), End: at(20)}},
+		{Op: models.BatchUpdate, ID: moved.ID, Patch: map[string]interface{}{"end": at(6)}},
+		{Op: models.BatchDelete, ID: dropped.ID, Reason: "conformance"},
+	})
+	if err != nil {
+		t.Fatalf("running a batch: %s", err)
+	}
+
+	want := []string{models.BulkCreated, models.BulkUpdated, models.BulkDeleted}
+	for i, result := range results {
+		if result.Status != want[i] {
+			t.Errorf("running a batch: operation %d got %s (%s), want %s", i, result.Status, result.Error, want[i])
+		}
+	}
+
+	shifts, err := store.ListShifts(ShiftFilter{UserID: user.ID})
+	if err != nil {
+		t.Fatalf("listing shifts: %s", err)
+	}
+
+	if len(shifts) != 2 || shifts[0].ID != moved.ID || !shifts[0].End.Equal(at(6)) || !shifts[1].Start.Equal(at(16)) {
+		t.Errorf("running a batch: got %v, want %s ending at %s and the created shift", shiftIDs(shifts), moved.ID, at(6))
+	}
+
+	// The second operation overlaps the first, so neither is kept
+	results, err = store.RunShiftBatch([]*models.ShiftOperation{
+		{Op: models.BatchUpdate, ID: moved.ID, Patch: map[string]interface{}{"start": at(2)}},
+		{Op: models.BatchCreate, Shift: &models.Shift{UserID: user.ID, Start: at(17), End: at(19)}},
+		{Op: models.BatchDelete, ID: "missing", Reason: "conformance"},
+	})
+	if !errors.Is(err, models.ErrBatchFailed) {
+		t.Fatalf("running a failing batch: got error %v, want %v", err, models.ErrBatchFailed)
+	}
+
+	want = []string{models.BulkRolledBack, models.BulkFailed, models.BulkFailed}
+	for i, result := range results {
+		if result.Status != want[i] {
+			t.Errorf("running a failing batch: operation %d got %s, want %s", i, result.Status, want[i])
+		}
+	}
+
+	after, err := store.ListShifts(ShiftFilter{UserID: user.ID})
+	if err != nil {
+		t.Fatalf("listing shifts: %s", err)
+	}
+
+	if !sameShifts(after, shifts, true) || !after[0].Start.Equal(at(0)) {
+		t.Errorf("running a failing batch: got %v, want the shifts unchanged as %v", shiftIDs(after), shiftIDs(shifts))
+	}
+}
+
+// ConditionalWrites verifies that the ETag of a shift or user read back matches the one of the version written,
+// and that writes with a Precondition apply only while it still holds
+func ConditionalWrites(t *testing.T, store Store) {
+	store = defaults(store)
+
+	user := createUser(t, store, "conditionalworker", "user", "")
+	created := createShift(t, store, &models.Shift{UserID: user.ID, Start: at(9), End: at(17)})
+
+	shift, err := store.FindShift(created.ID)
+	if err != nil {
+		t.Fatalf("finding shift: %s", err)
+	}
+
+	read := shift.ETag()
+
+	shift.End = at(16)
+	shift.Precondition = read
+
+	err = store.UpdateShift(shift)
+	if err != nil {
+		t.Fatalf("updating the shift as read: %s", err)
+	}
+
+	updated, err := store.FindShift(shift.ID)
+	if err != nil {
+		t.Fatalf("finding shift: %s", err)
+	}
+
+	if updated.ETag() != shift.ETag() || updated.ETag() == read {
+		t.Errorf("updating a shift: got ETag %s read back, want the new %s", updated.ETag(), shift.ETag())
+	}
+
+	// The version read first has since been updated
+	stale := &models.Shift{ID: shift.ID, UserID: user.ID, Start: at(9), End: at(15), Precondition: read}
+
+	err = store.UpdateShift(stale)
+	if !errors.Is(err, models.ErrStale) {
+		t.Errorf("updating a stale shift: got error %v, want %v", err, models.ErrStale)
+	}
+
+	shift.Precondition = read
+
+	err = store.CancelShift(shift, "conformance")
+	if !errors.Is(err, models.ErrStale) {
+		t.Errorf("cancelling a stale shift: got error %v, want %v", err, models.ErrStale)
+	}
+
+	updated.Precondition = updated.ETag()
+
+	err = store.CancelShift(updated, "conformance")
+	if err != nil {
+		t.Errorf("cancelling the shift as read: %s", err)
+	}
+
+	found, err := store.FindUser(user.ID)
+	if err != nil {
+		t.Fatalf("finding user: %s", err)
+	}
+
+	read = found.ETag()
+
+	found.Email = "conditional@example.com"
+	found.Password = ""
+	found.Precondition = read
+
+	err = store.UpdateUser(found)
+	if err != nil {
+		t.Fatalf("updating the user as read: %s", err)
+	}
+
+	stored, err := store.FindUser(user.ID)
+	if err != nil {
+		t.Fatalf("finding user: %s", err)
+	}
+
+	if stored.ETag() != found.ETag() || stored.ETag() == read {
+		t.Errorf("updating a user: got ETag %s read back, want the new %s", stored.ETag(), found.ETag())
+	}
+
+	found.Precondition = read
+
+	err = store.UpdateUser(found)
+	if !errors.Is(err, models.ErrStale) {
+		t.Errorf("updating a stale user: got error %v, want %v", err, models.ErrStale)
+	}
+
+	err = store.DeactivateUser(found)
+	if !errors.Is(err, models.ErrStale) {
+		t.Errorf("deactivating a stale user: got error %v, want %v", err, models.ErrStale)
+	}
+
+	stored.Precondition = stored.ETag()
+
+	err = store.DeactivateUser(stored)
+	if err != nil {
+		t.Errorf("deactivating the user as read: %s", err)
+	}
+}
+
+// IdempotentRequests verifies that only the first of the requests with the same key is recorded, and that its
+// response is stored and read back whole until it expires
+func IdempotentRequests(t *testing.T, store Store) {
+	user := createUser(t, store, "idempotentworker", "user", "")
+
+	req, created, err := store.BeginIdempotentRequest(user.ID, "conformance", "first", time.Hour)
+	if err != nil || !created {
+		t.Fatalf("beginning a request: got created %t and error %v, want it created", created, err)
+	}
+
+	// Another user's key is their own
+	_, created, err = store.BeginIdempotentRequest("another", "conformance", "other", time.Hour)
+	if err != nil || !created {
+		t.Errorf("beginning another user's request: got created %t and error %v, want it created", created, err)
+	}
+
+	retry, created, err := store.BeginIdempotentRequest(user.ID, "conformance", "retry", time.Hour)
+	if err != nil || created || retry.Fingerprint != "first" || retry.Completed() {
+		t.Fatalf("retrying a request being handled: got created %t and error %v, want the first in progress", created, err)
+	}
+
+	body := []byte(`{"id":"conformance"}`)
+
+	err = store.CompleteIdempotentRequest(req, 201, http.Header{"Content-Type": {"application/json"}}, body)
+	if err != nil {
+		t.Fatalf("completing a request: %s", err)
+	}
+
+	retry, _, err = store.BeginIdempotentRequest(user.ID, "conformance", "first", time.Hour)
+	if err != nil {
+		t.Fatalf("retrying a request: %s", err)
+	}
+
+	header, err := retry.ResponseHeader()
+	if err != nil || retry.Status != 201 || !bytes.Equal(retry.Body, body) || header.Get("Content-Type") != "application/json" {
+		t.Errorf("retrying a request: got %d %v %q, want the stored response", retry.Status, header, retry.Body)
+	}
+
+	purged, err := store.PurgeIdempotentRequests(time.Now().Add(2 * time.Hour))
+	if err != nil || purged != 2 {
+		t.Errorf("purging expired requests: got %d and error %v, want 2", purged, err)
+	}
+
+	_, created, err = store.BeginIdempotentRequest(user.ID, "conformance", "again", time.Hour)
+	if err != nil || !created {
+		t.Errorf("reusing an expired key: got created %t and error %v, want it created", created, err)
+	}
+}
+
+// ShiftEvents verifies that created, updated and cancelled shifts are announced to the users they concern, and that
+// changes which are rolled back are not
+func ShiftEvents(t *testing.T, store Store) {
+	bus := events.NewBus()
+	store = store.WithSettings(models.Settings{EventBus: bus})
+
+	sub := bus.Subscribe()
+	defer sub.Close()
+
+	first := createUser(t, store, "eventworker", "user", "")
+	second := createUser(t, store, "eventcover", "user", "")
+
+	shift := createShift(t, store, &models.Shift{UserID: first.ID, Start: at(0), End: at(4)})
+	expectEvents(t, "creating a shift", sub, models.EventShiftCreated)
+
+	change := *shift
+	change.UserID = second.ID
+	change.Precondition = shift.ETag()
+
+	err := store.UpdateShift(&change)
+	if err != nil {
+		t.Fatalf("reassigning a shift: %s", err)
+	}
+
+	got := expectEvents(t, "reassigning a shift", sub, models.EventShiftUpdated)
+	if len(got) == 1 && (!got[0].Concerns(first.ID) || !got[0].Concerns(second.ID)) {
+		t.Errorf("reassigning a shift: got an event concerning %v, want both workers", got[0].Users)
+	}
+
+	// A stale write and a failing batch change nothing, so announce nothing
+	shift.Precondition = shift.ETag()
+	if err := store.CancelShift(shift, "conformance"); !errors.Is(err, models.ErrStale) {
+		t.Fatalf("cancelling a stale shift: got error %v, want %v", err, models.ErrStale)
+	}
+
+	_, err = store.RunShiftBatch([]*models.ShiftOperation{
+		{Op: models.BatchCreate, Shift: &models.Shift{UserID: first.ID, Start: at(8), End: at(12)}},
+		{Op: models.BatchCreate, Shift: &models.Shift{UserID: first.ID, Start: at(9), End: at(11)}},
+	})
+	if !errors.Is(err, models.ErrBatchFailed) {
+		t.Fatalf("running a failing batch: got error %v, want %v", err, models.ErrBatchFailed)
+	}
+
+	expectEvents(t, "rolling back changes", sub)
+
+	change.Precondition = ""
+	err = store.CancelShift(&change, "conformance")
+	if err != nil {
+		t.Fatalf("cancelling a shift: %s", err)
+	}
+
+	expectEvents(t, "cancelling a shift", sub, models.EventShiftDeleted)
+}
+
+// expectEvents returns the events the subscription has received, failing the test unless they are of the types
+func expectEvents(t *testing.T, action string, sub *events.Subscription, types ...string) []events.Event {
+	t.Helper()
+
+	var got []events.Event
+	for {
+		select {
+		case e := <-sub.Events():
+			got = append(got, e)
+			continue
+		default:
+		}
+
+		break
+	}
+
+	var gotTypes []string
+	for _, e := range got {
+		gotTypes = append(gotTypes, e.Type)
+	}
+
+	if !equal(gotTypes, types) {
+		t.Errorf("%s: got events %v, want %v", action, gotTypes, types)
+	}
+
+	return got
+}
+
+// sameShifts reports whether the shifts are the wanted ones, in the same order when ordered is set
+func sameShifts(got, want []*models.Shift, ordered bool) bool {
+	if len(got) != len(want) {
+		return false
+	}
+
+	ids := make(map[string]int, len(want))
+	for i, shift := range want {
+		ids[shift.ID] = i
+	}
+
+	for i, shift := range got {
+		j, ok := ids[shift.ID]
+		if !ok || (ordered && i != j) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func shiftIDs(shifts []*models.Shift) []string {
+	ids := make([]string, 0, len(shifts))
+	for _, shift := range shifts {
+		ids = append(ids, shift.ID)
+	}
+
+	return ids
+}
+
+// UserPagination verifies that ListUsers searches, filters, sorts and counts users, and that pages
+// selected by number or cursor cover every matching user exactly once
+func UserPagination(t *testing.T, store Store) {
+	names := []string{"delta", "alpha", "echo", "charlie", "bravo", "foxtrot", "golf", "50% off"}
+	for _, name := range names {
+		createUser(t, store, name, "user", "")
+	}
+
+	createUser(t, store, "alphadmin", "admin", "")
+
+	page, err := store.ListUsers(models.UserQuery{Role: "user"})
+	if err != nil {
+		t.Fatalf("listing users: %s", err)
+	}
+
+	want := []string{"50% off", "alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf"}
+	if got := userNames(page.Users); !equal(got, want) || page.Total != int64(len(want)) {
+		t.Errorf("listing users by role: got %v of %d, want %v of %d", got, page.Total, want, len(want))
+	}
+
+	page, err = store.ListUsers(models.UserQuery{Search: "ALPHA"})
+	if err != nil {
+		t.Fatalf("searching users: %s", err)
+	}
+
+	if got, want := userNames(page.Users), []string{"alpha", "alphadmin"}; !equal(got, want) {
+		t.Errorf("searching users case insensitively: got %v, want %v", got, want)
+	}
+
+	page, err = store.ListUsers(models.UserQuery{Search: "%"})
+	if err != nil {
+		t.Fatalf("searching users: %s", err)
+	}
+
+	if got, want := userNames(page.Users), []string{"50% off"}; !equal(got, want) {
+		t.Errorf("searching users for a wildcard: got %v, want %v", got, want)
+	}
+
+	page, err = store.ListUsers(models.UserQuery{Role: "user", Sort: "-name", Limit: 3, Page: 2})
+	if err != nil {
+		t.Fatalf("listing users by page: %s", err)
+	}
+
+	if got, want := userNames(page.Users), []string{"delta", "charlie", "bravo"}; !equal(got, want) || page.Total != 8 {
+		t.Errorf("listing the second page of users sorted descending: got %v of %d, want %v of 8", got, page.Total, want)
+	}
+
+	// Walking the cursors visits every user once in order, however the pages fall
+	for _, sort := range []string{"name", "-name", "created_at", "-role", "role,-name"} {
+		var seen []string
+		cursor := ""
+
+		for i := 0; i <= len(names); i++ {
+			page, err = store.ListUsers(models.UserQuery{Role: "user", Sort: sort, Limit: 3, Cursor: cursor})
+			if err != nil {
+				t.Fatalf("listing users sorted by %s from cursor %q: %s", sort, cursor, err)
+			}
+
+			seen = append(seen, userNames(page.Users)...)
+
+			cursor = page.NextCursor
+			if cursor == "" {
+				break
+			}
+		}
+
+		if len(seen) != len(names) || !distinct(seen) {
+			t.Errorf("walking users sorted by %s with cursors: got %v, want each of %v once", sort, seen, names)
+		}
+
+		if sort == "name" && !equal(seen, want) {
+			t.Errorf("walking users sorted by name with cursors: got %v, want %v", seen, want)
+		}
+
+		// Every user shares the role, so the name decides
+		if sort == "role,-name" && !equal(seen, reversed(want)) {
+			t.Errorf("walking users sorted by role and name with cursors: got %v, want %v", seen, reversed(want))
+		}
+	}
+
+	for _, sort := range []string{"password", "name;DROP TABLE users", "name,name", "name,"} {
+		_, err = store.ListUsers(models.UserQuery{Sort: sort})
+		if !errors.Is(err, models.ErrInvalid) {
+			t.Errorf("listing users sorted by %q: got error %v, want %v", sort, err, models.ErrInvalid)
+		}
+	}
+}
+
+func reversed(a []string) []string {
+	b := make([]string, len(a))
+	for i := range a {
+		b[len(a)-1-i] = a[i]
+	}
+
+	return b
+}
+
+func userNames(users []*models.User) []string {
+	names := make([]string, 0, len(users))
+	for _, user := range users {
+		names = append(names, user.Name)
+	}
+
+	return names
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func distinct(values []string) bool {
+	seen := make(map[string]bool, len(values))
+	for _, v := range values {
+		if seen[v] {
+			return false
+		}
+
+		seen[v] = true
+	}
+
+	return true
+}
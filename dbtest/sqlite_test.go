@@ -0,0 +1,23 @@
+package dbtest_test
+
+import (
+	"github.com/btnmasher/shiftr/dbtest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"testing"
+)
+
+func TestSqlite(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) dbtest.Store {
+		db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "shiftr.db")), &gorm.Config{
+			Logger: logger.Discard,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return dbtest.Gorm(db)
+	})
+}
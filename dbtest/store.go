@@ -0,0 +1,189 @@
+package dbtest
+
+import (
+	"github.com/btnmasher/shiftr/api/models"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// Store is the storage of shifts, users and idempotent requests the suite runs against. A store reports failures
+// with the errors of the models package, such as models.ErrOverlap or models.ErrStale, applies the shift limits
+// and scheduling rules of its settings, and announces the changes it keeps on their event bus, so that the API
+// behaves the same whichever store it is given.
+type Store interface {
+	ShiftStore
+	UserStore
+	IdempotencyStore
+
+	// Migrate prepares a new, empty store for use
+	Migrate() error
+
+	// WithSettings returns the store applying the settings, as models.Configure does for a database
+	WithSettings(s models.Settings) Store
+}
+
+// ShiftStore stores shifts
+type ShiftStore interface {
+	CreateShift(shift *models.Shift) error
+	UpdateShift(shift *models.Shift) error
+	CancelShift(shift *models.Shift, reason string) error
+	FindShift(id string) (*models.Shift, error)
+	ListShifts(filter ShiftFilter) ([]*models.Shift, error)
+	PageShifts(q models.ShiftPageQuery) (*models.ShiftPage, error)
+	RunShiftBatch(ops []*models.ShiftOperation) ([]*models.ShiftOperationResult, error)
+}
+
+// UserStore stores users
+type UserStore interface {
+	CreateUser(user *models.User) error
+	UpdateUser(user *models.User) error
+	DeactivateUser(user *models.User) error
+	FindUser(id string) (*models.User, error)
+	ListUsers(q models.UserQuery) (*models.UserPage, error)
+}
+
+// IdempotencyStore stores the requests made with an Idempotency-Key and their responses
+type IdempotencyStore interface {
+	BeginIdempotentRequest(uid, key, fingerprint string, window time.Duration) (*models.IdempotentRequest, bool, error)
+	CompleteIdempotentRequest(req *models.IdempotentRequest, status int, header http.Header, body []byte) error
+	PurgeIdempotentRequests(now time.Time) (int64, error)
+}
+
+// ShiftFilter selects the shifts listed by ShiftStore.ListShifts. Zero fields select every shift, and the shifts
+// are ordered by their start unless sorted otherwise, as with models.ListShifts.
+type ShiftFilter struct {
+	UserID     string
+	TeamID     string
+	LocationID string
+	PositionID string
+	Status     string
+	Tags       []string // shifts holding every tag
+
+	Start        time.Time // shifts starting at or after
+	End          time.Time // shifts ending at or before
+	StartsBefore time.Time
+	EndsAfter    time.Time
+	ActiveAt     time.Time
+
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+
+	IncludeCancelled bool
+	Sort             string // as accepted by models.SortShifts
+	AfterCursor      string // as returned by models.ShiftCursor
+	Limit            int
+}
+
+// Gorm returns the Store shiftr keeps its models in through the gorm database, as it does on the supported
+// databases, so that the suite can be run against a new gorm dialector
+func Gorm(db *gorm.DB) Store {
+	return gormStore{db: db}
+}
+
+type gormStore struct {
+	db *gorm.DB
+}
+
+func (s gormStore) Migrate() error {
+	_, err := models.Migrate(s.db, false)
+	return err
+}
+
+func (s gormStore) WithSettings(settings models.Settings) Store {
+	return gormStore{db: models.Configure(s.db, settings)}
+}
+
+func (s gormStore) CreateShift(shift *models.Shift) error {
+	return shift.Create(s.db)
+}
+
+func (s gormStore) UpdateShift(shift *models.Shift) error {
+	return shift.Update(s.db)
+}
+
+func (s gormStore) CancelShift(shift *models.Shift, reason string) error {
+	return shift.Cancel(s.db, reason)
+}
+
+func (s gormStore) FindShift(id string) (*models.Shift, error) {
+	return models.FindShiftByID(s.db, id)
+}
+
+func (s gormStore) ListShifts(f ShiftFilter) ([]*models.Shift, error) {
+	opts := []models.ShiftFilterOption{
+		models.FilterUserID(f.UserID),
+		models.FilterTeamID(f.TeamID),
+		models.FilterLocationID(f.LocationID),
+		models.FilterPositionID(f.PositionID),
+		models.FilterStart(f.Start),
+		models.FilterEnd(f.End),
+		models.FilterStartsBefore(f.StartsBefore),
+		models.FilterEndsAfter(f.EndsAfter),
+		models.FilterActiveAt(f.ActiveAt),
+		models.FilterCreated(f.CreatedAfter, f.CreatedBefore),
+		models.FilterUpdated(f.UpdatedAfter, f.UpdatedBefore),
+		models.IncludeCancelled(f.IncludeCancelled),
+		models.WithLimit(f.Limit),
+	}
+
+	if f.Status != "" {
+		opts = append(opts, models.FilterStatus(f.Status))
+	}
+
+	if len(f.Tags) > 0 {
+		opts = append(opts, models.FilterTags(f.Tags...))
+	}
+
+	if f.Sort != "" {
+		opts = append(opts, models.SortShifts(f.Sort))
+	}
+
+	if f.AfterCursor != "" {
+		opts = append(opts, models.FilterAfterCursor(f.AfterCursor))
+	}
+
+	return models.ListShifts(s.db, opts...)
+}
+
+func (s gormStore) PageShifts(q models.ShiftPageQuery) (*models.ShiftPage, error) {
+	return models.PageShifts(s.db, q)
+}
+
+func (s gormStore) RunShiftBatch(ops []*models.ShiftOperation) ([]*models.ShiftOperationResult, error) {
+	return models.RunShiftBatch(s.db, ops)
+}
+
+func (s gormStore) CreateUser(user *models.User) error {
+	return user.Create(s.db)
+}
+
+func (s gormStore) UpdateUser(user *models.User) error {
+	return user.Update(s.db)
+}
+
+func (s gormStore) DeactivateUser(user *models.User) error {
+	return user.Deactivate(s.db)
+}
+
+func (s gormStore) FindUser(id string) (*models.User, error) {
+	return models.FindUserByID(s.db, id)
+}
+
+func (s gormStore) ListUsers(q models.UserQuery) (*models.UserPage, error) {
+	return models.ListUsers(s.db, q)
+}
+
+func (s gormStore) BeginIdempotentRequest(uid, key, fingerprint string, window time.Duration) (*models.IdempotentRequest, bool, error) {
+	return models.BeginIdempotentRequest(s.db, uid, key, fingerprint, window)
+}
+
+func (s gormStore) CompleteIdempotentRequest(req *models.IdempotentRequest, status int, header http.Header, body []byte) error {
+	return req.Complete(s.db, status, header, body)
+}
+
+func (s gormStore) PurgeIdempotentRequests(now time.Time) (int64, error) {
+	return models.PurgeIdempotentRequests(s.db, now)
+}
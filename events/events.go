@@ -0,0 +1,164 @@
+package events
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	subscriptionBuffer = 64   // how many events a subscriber may fall behind by before it is dropped
+	historyLimit       = 1024 // how many of the latest events are at least held for subscribers resuming after one
+)
+
+// Event is a change made to an object of the schedule
+type Event struct {
+	Type  string    // what happened, such as shift.created
+	ID    string    // internal ID of the object changed
+	Users []string  // internal IDs of the users the change concerns, such as the worker of a shift
+	At    time.Time // when the change was made
+	Seq   uint64    // position of the event on the Bus, set as it is published
+}
+
+// Concerns reports whether the event concerns the specified User.ID
+func (e Event) Concerns(uid string) bool {
+	for _, id := range e.Users {
+		if id == uid {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Bus hands every event published on it to each of its subscribers. Events are held in memory and are not shared
+// between instances of the server, the latest of them kept for subscribers resuming after the last they received.
+type Bus struct {
+	mu      sync.Mutex
+	subs    map[*Subscription]bool
+	epoch   string // distinguishes the IDs of the events of this Bus from those of any before it
+	seq     uint64
+	history []Event
+}
+
+// Subscription receives the events published on a Bus until it is closed. A subscriber which falls too far behind
+// is dropped, closing its channel, so that it can catch up from the current state rather than stall the bus.
+type Subscription struct {
+	bus    *Bus
+	events chan Event
+}
+
+// NewBus returns a Bus with no subscribers
+func NewBus() *Bus {
+	return &Bus{
+		subs:  make(map[*Subscription]bool),
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+}
+
+// Subscribe returns a Subscription receiving every event published from now on
+func (b *Bus) Subscribe() *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.subscribe()
+}
+
+// SubscribeAfter returns a Subscription receiving every event published from now on, along with those held which
+// were published after the event of the ID. It reports false when events since may have been missed, as the ID is
+// of a Bus before this one or of an event no longer held, in which case no events are returned. An empty ID
+// resumes after nothing, as Subscribe does.
+func (b *Bus) SubscribeAfter(id string) (*Subscription, []Event, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.subscribe()
+	if id == "" {
+		return s, nil, true
+	}
+
+	epoch, num := id, ""
+	if i := strings.LastIndexByte(id, '-'); i >= 0 {
+		epoch, num = id[:i], id[i+1:]
+	}
+
+	seq, err := strconv.ParseUint(num, 10, 64)
+	if err != nil || epoch != b.epoch || seq > b.seq {
+		return s, nil, false
+	}
+
+	// The event after the one given must still be held for none to have been missed
+	if len(b.history) > 0 && b.history[0].Seq > seq+1 {
+		return s, nil, false
+	}
+
+	var missed []Event
+	for _, e := range b.history {
+		if e.Seq > seq {
+			missed = append(missed, e)
+		}
+	}
+
+	return s, missed, true
+}
+
+func (b *Bus) subscribe() *Subscription {
+	s := &Subscription{
+		bus:    b,
+		events: make(chan Event, subscriptionBuffer),
+	}
+
+	b.subs[s] = true
+
+	return s
+}
+
+// ID returns the ID of the published event, to resume after it with SubscribeAfter
+func (b *Bus) ID(e Event) string {
+	return b.epoch + "-" + strconv.FormatUint(e.Seq, 10)
+}
+
+// Publish hands the event to every subscriber without waiting on any of them
+func (b *Bus) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	e.Seq = b.seq
+
+	// The history is trimmed once it holds twice the limit, rather than copied on every event
+	b.history = append(b.history, e)
+	if len(b.history) >= 2*historyLimit {
+		b.history = append(b.history[:0:0], b.history[len(b.history)-historyLimit:]...)
+	}
+
+	for s := range b.subs {
+		select {
+		case s.events <- e:
+		default:
+			delete(b.subs, s)
+			close(s.events)
+		}
+	}
+}
+
+// Events returns the channel the events are received on, closed once the Subscription is closed or dropped
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close stops the Subscription receiving events
+func (s *Subscription) Close() {
+	b := s.bus
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.subs[s] {
+		return
+	}
+
+	delete(b.subs, s)
+	close(s.events)
+}
@@ -0,0 +1,56 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// formulaPrefixes are the characters spreadsheets read a cell starting with as a formula
+const formulaPrefixes = "=+-@\t\r"
+
+// WriteCSV writes the rows of the sheet to w as comma separated values,
+// formatting time.Time cells as RFC3339 timestamps. Text cells which a
+// spreadsheet would run as a formula are prefixed with a single quote.
+func WriteCSV(w io.Writer, sheet Sheet) error {
+	cw := csv.NewWriter(w)
+
+	for _, row := range sheet.Rows {
+		record := make([]string, len(row))
+
+		for i, val := range row {
+			switch v := val.(type) {
+			case nil:
+			case time.Time:
+				if !v.IsZero() {
+					record[i] = v.Format(time.RFC3339)
+				}
+			case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+				record[i] = fmt.Sprint(v)
+			default:
+				record[i] = escapeFormula(fmt.Sprint(v))
+			}
+		}
+
+		err := cw.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// escapeFormula prefixes text starting like a formula with a single quote, so it is shown rather than evaluated
+func escapeFormula(text string) string {
+	if text != "" && strings.IndexByte(formulaPrefixes, text[0]) >= 0 {
+		return "'" + text
+	}
+
+	return text
+}
@@ -0,0 +1,55 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWriteCSVEscapesFormulas(t *testing.T) {
+	at := time.Date(2021, time.March, 4, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		cell interface{}
+		want string
+	}{
+		{"equals", `=HYPERLINK("http://example.com","x")`, `'=HYPERLINK("http://example.com","x")`},
+		{"plus", "+1+2", "'+1+2"},
+		{"minus", "-2+3", "'-2+3"},
+		{"at", "@SUM(A1:A2)", "'@SUM(A1:A2)"},
+		{"tab", "\t=1", "'\t=1"},
+		{"carriage return", "\r=1", "'\r=1"},
+		{"text", "night shift", "night shift"},
+		{"inner equals", "a=b", "a=b"},
+		{"empty", "", ""},
+		{"nil", nil, ""},
+		{"negative int", -3, "-3"},
+		{"negative float", -1.5, "-1.5"},
+		{"negative number", json.Number("-7"), "-7"},
+		{"time", at, "2021-03-04T09:00:00Z"},
+		{"stringer", time.Duration(-time.Hour), "'-1h0m0s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			err := WriteCSV(&buf, Sheet{Rows: [][]interface{}{{tt.cell, "next"}}})
+			if err != nil {
+				t.Fatalf("writing: %s", err)
+			}
+
+			records, err := csv.NewReader(&buf).ReadAll()
+			if err != nil {
+				t.Fatalf("reading %q: %s", buf.String(), err)
+			}
+
+			if got := records[0][0]; got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package export
+
+import (
+	"bufio"
+	"golang.org/x/text/encoding/charmap"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Label is a compact printout for a receipt printer, such as a single shift or the roster of a day,
+// of a centered title and subtitle above rows of text and a centered footer
+type Label struct {
+	Title    string
+	Subtitle string
+	Rows     []LabelRow
+	Footer   string
+}
+
+// LabelRow is a line of a Label, its Left text aligned left and its Right text aligned right. When both do not
+// fit on one line the Right text is moved to a line of its own.
+type LabelRow struct {
+	Left  string
+	Right string
+}
+
+// The number of characters fitting a line of the standard font on common receipt printers
+const (
+	LabelWidth58mm = 32
+	LabelWidth80mm = 48
+)
+
+// ESC/POS commands understood by most receipt printers
+var (
+	escposInit       = []byte{0x1b, '@'}        // reset the printer
+	escposCodePage   = []byte{0x1b, 't', 0}     // select code page 437
+	escposLeft       = []byte{0x1b, 'a', 0}     // align left
+	escposCenter     = []byte{0x1b, 'a', 1}     // align center
+	escposBoldOn     = []byte{0x1b, 'E', 1}     // emphasize
+	escposBoldOff    = []byte{0x1b, 'E', 0}     // stop emphasizing
+	escposDoubleOn   = []byte{0x1d, '!', 0x11}  // double width and height
+	escposDoubleOff  = []byte{0x1d, '!', 0}     // normal size
+	escposFeedAndCut = []byte{0x1d, 'V', 66, 3} // feed past the cutter and cut partially
+)
+
+// WriteLabelText writes the label to w as plain text lines of at most width characters
+func WriteLabelText(w io.Writer, label Label, width int) error {
+	bw := bufio.NewWriter(w)
+
+	for _, line := range labelLines(label, width) {
+		bw.WriteString(line)
+		bw.WriteByte('\n')
+	}
+
+	return bw.Flush()
+}
+
+// WriteLabelESCPOS writes the label to w as ESC/POS commands for a receipt printer whose lines hold width
+// characters, printing the title in double size and cutting the paper after the footer. Text is encoded
+// in code page 437, with characters it lacks printed as a question mark.
+func WriteLabelESCPOS(w io.Writer, label Label, width int) error {
+	bw := bufio.NewWriter(w)
+
+	bw.Write(escposInit)
+	bw.Write(escposCodePage)
+	bw.Write(escposCenter)
+
+	// Double width halves the characters fitting a line
+	if label.Title != "" {
+		bw.Write(escposBoldOn)
+		bw.Write(escposDoubleOn)
+		for _, line := range wrapLabel(label.Title, width/2) {
+			writeCP437(bw, line)
+			bw.WriteByte('\n')
+		}
+		bw.Write(escposDoubleOff)
+		bw.Write(escposBoldOff)
+	}
+
+	if label.Subtitle != "" {
+		for _, line := range wrapLabel(label.Subtitle, width) {
+			writeCP437(bw, line)
+			bw.WriteByte('\n')
+		}
+	}
+
+	bw.Write(escposLeft)
+	writeCP437(bw, strings.Repeat("-", width))
+	bw.WriteByte('\n')
+
+	for _, line := range labelRows(label.Rows, width) {
+		writeCP437(bw, line)
+		bw.WriteByte('\n')
+	}
+
+	if label.Footer != "" {
+		writeCP437(bw, strings.Repeat("-", width))
+		bw.WriteByte('\n')
+		bw.Write(escposCenter)
+		for _, line := range wrapLabel(label.Footer, width) {
+			writeCP437(bw, line)
+			bw.WriteByte('\n')
+		}
+	}
+
+	bw.Write(escposFeedAndCut)
+
+	return bw.Flush()
+}
+
+// labelLines lays the label out as lines of at most width characters, centering the title, subtitle and footer
+func labelLines(label Label, width int) []string {
+	var lines []string
+
+	for _, text := range []string{label.Title, label.Subtitle} {
+		for _, line := range wrapLabel(text, width) {
+			lines = append(lines, centerLabel(line, width))
+		}
+	}
+
+	lines = append(lines, strings.Repeat("-", width))
+	lines = append(lines, labelRows(label.Rows, width)...)
+
+	if label.Footer != "" {
+		lines = append(lines, strings.Repeat("-", width))
+		for _, line := range wrapLabel(label.Footer, width) {
+			lines = append(lines, centerLabel(line, width))
+		}
+	}
+
+	return lines
+}
+
+// labelRows lays the rows out as lines of at most width characters
+func labelRows(rows []LabelRow, width int) []string {
+	var lines []string
+
+	for _, row := range rows {
+		left, right := []rune(cleanLabel(row.Left)), []rune(cleanLabel(row.Right))
+
+		switch {
+		case len(right) == 0:
+			lines = append(lines, wrapLabel(string(left), width)...)
+		case len(left)+1+len(right) <= width:
+			lines = append(lines, string(left)+strings.Repeat(" ", width-len(left)-len(right))+string(right))
+		default:
+			lines = append(lines, wrapLabel(string(left), width)...)
+			for _, line := range wrapLabel(string(right), width) {
+				lines = append(lines, strings.Repeat(" ", width-len([]rune(line)))+line)
+			}
+		}
+	}
+
+	return lines
+}
+
+// wrapLabel breaks the text into lines of at most width characters, between words where possible
+func wrapLabel(text string, width int) []string {
+	var lines []string
+	var line []rune
+
+	for _, word := range strings.Fields(cleanLabel(text)) {
+		runes := []rune(word)
+
+		if len(line) > 0 && len(line)+1+len(runes) > width {
+			lines = append(lines, string(line))
+			line = nil
+		}
+
+		if len(line) > 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, runes...)
+
+		// Words longer than a line are broken wherever the line ends
+		for len(line) > width {
+			lines = append(lines, string(line[:width]))
+			line = line[width:]
+		}
+	}
+
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+
+	return lines
+}
+
+// centerLabel pads the line on the left to center it within width characters
+func centerLabel(line string, width int) string {
+	pad := (width - len([]rune(line))) / 2
+	if pad < 1 {
+		return line
+	}
+
+	return strings.Repeat(" ", pad) + line
+}
+
+// cleanLabel replaces control characters with spaces, so text such as a user's name cannot carry printer commands
+func cleanLabel(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, text)
+}
+
+// writeCP437 writes the line encoded in code page 437, replacing characters it lacks with a question mark
+func writeCP437(w *bufio.Writer, line string) {
+	for _, r := range line {
+		b, ok := charmap.CodePage437.EncodeRune(r)
+		if !ok || b < 0x20 {
+			b = '?'
+		}
+		w.WriteByte(b)
+	}
+}
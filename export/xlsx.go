@@ -0,0 +1,194 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Sheet represents a single worksheet of a workbook. Cell values may be a string, an integer,
+// a float64, or a time.Time, any other type is written using its default string format.
+type Sheet struct {
+	Name string
+	Rows [][]interface{}
+}
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+%s</Types>`
+
+	xlsxSheetType = `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+`
+
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets>%s</sheets>
+</workbook>`
+
+	xlsxWorkbookSheet = `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`
+
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+%s<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+	xlsxWorkbookSheetRel = `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>
+`
+
+	// styles.xml declares a single extra cell format (index 1) using the builtin date-time number format
+	xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="22" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>
+</styleSheet>`
+
+	xlsxSheetHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+	xlsxSheetFooter = `</sheetData></worksheet>`
+
+	xlsxDateStyle = 1
+)
+
+// excelEpoch is the zero date of the 1900 date system, adjusted for its fictional leap day
+var excelEpoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+// WriteXLSX writes the sheets to w as an Office Open XML workbook
+func WriteXLSX(w io.Writer, sheets ...Sheet) error {
+	zw := zip.NewWriter(w)
+
+	var types, entries, rels strings.Builder
+	for i, sheet := range sheets {
+		n := i + 1
+		fmt.Fprintf(&types, xlsxSheetType, n)
+		fmt.Fprintf(&entries, xlsxWorkbookSheet, escapeXML(sheetName(sheet.Name, n)), n, n)
+		fmt.Fprintf(&rels, xlsxWorkbookSheetRel, n, n)
+	}
+
+	parts := []struct {
+		name string
+		body string
+	}{
+		{"[Content_Types].xml", fmt.Sprintf(xlsxContentTypes, types.String())},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, entries.String())},
+		{"xl/_rels/workbook.xml.rels", fmt.Sprintf(xlsxWorkbookRels, rels.String(), len(sheets)+1)},
+		{"xl/styles.xml", xlsxStyles},
+	}
+
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(f, part.body)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, sheet := range sheets {
+		f, err := zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1))
+		if err != nil {
+			return err
+		}
+
+		err = writeSheet(f, sheet)
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func writeSheet(w io.Writer, sheet Sheet) error {
+	var b strings.Builder
+
+	b.WriteString(xlsxSheetHeader)
+
+	for r, row := range sheet.Rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+
+		for c, val := range row {
+			ref := cellRef(c, r)
+
+			switch v := val.(type) {
+			case nil:
+				continue
+			case int, int32, int64, uint, uint32, uint64:
+				fmt.Fprintf(&b, `<c r="%s"><v>%d</v></c>`, ref, v)
+			case float32, float64:
+				fmt.Fprintf(&b, `<c r="%s"><v>%v</v></c>`, ref, v)
+			case time.Time:
+				if v.IsZero() {
+					continue
+				}
+				fmt.Fprintf(&b, `<c r="%s" s="%d"><v>%.8f</v></c>`, ref, xlsxDateStyle, excelSerial(v))
+			default:
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`,
+					ref, escapeXML(fmt.Sprint(v)))
+			}
+		}
+
+		b.WriteString(`</row>`)
+	}
+
+	b.WriteString(xlsxSheetFooter)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// excelSerial converts the wall clock time of t to a 1900 date system serial number
+func excelSerial(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	return wall.Sub(excelEpoch).Hours() / 24
+}
+
+// cellRef returns the A1 style reference of the zero based column and row
+func cellRef(col, row int) string {
+	name := ""
+	for col >= 0 {
+		name = string(rune('A'+col%26)) + name
+		col = col/26 - 1
+	}
+
+	return fmt.Sprintf("%s%d", name, row+1)
+}
+
+func sheetName(name string, n int) string {
+	if name == "" {
+		return fmt.Sprintf("Sheet%d", n)
+	}
+
+	// Sheet names are limited to 31 characters
+	if len(name) > 31 {
+		return name[:31]
+	}
+
+	return name
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
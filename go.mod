@@ -3,12 +3,19 @@ module github.com/btnmasher/shiftr
 go 1.16
 
 require (
+	github.com/andybalholm/brotli v1.0.4
 	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/jkomyno/nanoid v0.0.0-20210415085252-937cefe9123e
 	github.com/labstack/echo/v4 v4.5.0
+	github.com/labstack/gommon v0.3.0
+	github.com/rs/zerolog v1.23.0
 	github.com/stretchr/testify v1.7.0 // indirect
 	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2
+	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4
 	golang.org/x/sys v0.0.0-20210510120138-977fb7262007 // indirect
+	golang.org/x/text v0.3.6
+	google.golang.org/grpc v1.40.0
+	google.golang.org/protobuf v1.27.1
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
 	gorm.io/driver/mysql v1.1.1
 	gorm.io/driver/postgres v1.1.0
@@ -0,0 +1,230 @@
+// Package health tracks the optional dependencies of the server, such as the notifier and the blob store,
+// behind circuit breakers. A dependency failing repeatedly is given a rest instead of being called on every
+// request, so its outage degrades the features using it rather than slowing or failing everything else.
+package health
+
+import (
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/logging"
+	"github.com/rs/zerolog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnavailable is returned in place of calling a dependency whose breaker is open
+var ErrUnavailable = errors.New("dependency unavailable")
+
+// The states of a Breaker
+const (
+	StateClosed   = "closed"    // calls go through
+	StateOpen     = "open"      // calls fail at once until the cooldown ends
+	StateHalfOpen = "half_open" // a single trial call decides whether to close again
+)
+
+// Defaults used for breakers unless configured otherwise
+const (
+	DefaultThreshold = 5
+	DefaultCooldown  = 30 * time.Second
+)
+
+// Breaker is a circuit breaker guarding calls to a dependency. It opens after Threshold consecutive failures,
+// refusing calls with ErrUnavailable for the Cooldown, then lets a single trial call through and closes again
+// if it succeeds.
+type Breaker struct {
+	Name      string
+	Threshold int
+	Cooldown  time.Duration
+
+	// Detail, when set, adds a description of the dependency's current condition to its Status
+	Detail func() string
+
+	Log *zerolog.Logger // of the breaker opening and closing, nil logs to logging.Default()
+
+	mu          sync.Mutex
+	state       string
+	failures    int
+	openedAt    time.Time
+	lastFailure time.Time
+	trial       bool // whether the trial call of a half open breaker is under way
+}
+
+// NewBreaker returns a closed Breaker for the named dependency, using the defaults for a threshold or cooldown
+// which is not positive
+func NewBreaker(name string, threshold int, cooldown time.Duration) *Breaker {
+	if threshold < 1 {
+		threshold = DefaultThreshold
+	}
+
+	if cooldown <= 0 {
+		cooldown = DefaultCooldown
+	}
+
+	return &Breaker{
+		Name:      name,
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		state:     StateClosed,
+	}
+}
+
+// Allow reports whether a call may be made now, returning an error wrapping ErrUnavailable if not.
+// Every allowed call must be followed by Record with its outcome.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.Cooldown {
+			return fmt.Errorf("%s: %w", b.Name, ErrUnavailable)
+		}
+
+		b.state = StateHalfOpen
+		b.trial = true
+	case StateHalfOpen:
+		if b.trial {
+			return fmt.Errorf("%s: %w", b.Name, ErrUnavailable)
+		}
+
+		b.trial = true
+	}
+
+	return nil
+}
+
+// Record notes the outcome of an allowed call, a nil error being a success
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+
+	if err == nil {
+		if b.state != StateClosed {
+			logging.Or(b.Log).Info().Str("dependency", b.Name).Msg("dependency recovered, closing its circuit breaker")
+		}
+
+		b.state = StateClosed
+		b.failures = 0
+
+		return
+	}
+
+	b.failures++
+	b.lastFailure = time.Now()
+
+	if b.state == StateHalfOpen || b.failures >= b.Threshold {
+		if b.state != StateOpen {
+			logging.Or(b.Log).Warn().
+				Err(err).
+				Str("dependency", b.Name).
+				Dur("cooldown", b.Cooldown).
+				Msg("dependency failing, opening its circuit breaker")
+		}
+
+		b.state = StateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Do calls fn if the breaker allows it and records its outcome
+func (b *Breaker) Do(fn func() error) error {
+	err := b.Allow()
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	b.Record(err)
+
+	return err
+}
+
+// Status describes the condition of a dependency
+type Status struct {
+	Name        string     `json:"name"`
+	State       string     `json:"state"`
+	Failures    int        `json:"failures"` // consecutive
+	LastFailure *time.Time `json:"last_failure,omitempty"`
+	Detail      string     `json:"detail,omitempty"`
+}
+
+// Status returns the current condition of the dependency guarded by the breaker
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+
+	status := Status{
+		Name:     b.Name,
+		State:    b.state,
+		Failures: b.failures,
+	}
+
+	if !b.lastFailure.IsZero() {
+		last := b.lastFailure
+		status.LastFailure = &last
+	}
+
+	// An open breaker whose cooldown has ended lets the next call through
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.Cooldown {
+		status.State = StateHalfOpen
+	}
+
+	b.mu.Unlock()
+
+	if b.Detail != nil {
+		status.Detail = b.Detail()
+	}
+
+	return status
+}
+
+// Registry holds the breakers of every optional dependency, for reporting their condition
+type Registry struct {
+	mu       sync.RWMutex
+	breakers map[string]*Breaker
+}
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		breakers: make(map[string]*Breaker),
+	}
+}
+
+// Register adds the breaker to the registry, replacing any registered under the same name
+func (r *Registry) Register(b *Breaker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.breakers[b.Name] = b
+}
+
+// Statuses returns the condition of every registered dependency ordered by name
+func (r *Registry) Statuses() []Status {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statuses := make([]Status, 0, len(r.breakers))
+	for _, b := range r.breakers {
+		statuses = append(statuses, b.Status())
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses
+}
+
+// Degraded reports whether any registered dependency is not currently closed
+func (r *Registry) Degraded() bool {
+	for _, status := range r.Statuses() {
+		if status.State != StateClosed {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,126 @@
+package i18n
+
+// catalogs holds the translations bundled with shiftr for each locale other than the Default, keyed by the English
+// message
+var catalogs = map[string]map[string]string{
+	"de": {
+		"Bad Request":           "Ungültige Anfrage",
+		"Unauthorized":          "Nicht autorisiert",
+		"Forbidden":             "Verboten",
+		"Not Found":             "Nicht gefunden",
+		"Conflict":              "Konflikt",
+		"Too Many Requests":     "Zu viele Anfragen",
+		"Internal Server Error": "Interner Serverfehler",
+
+		"invalid object":      "ungültiges Objekt",
+		"invalid parameters":  "ungültige Parameter",
+		"invalid time zone":   "ungültige Zeitzone",
+		"token required":      "Token erforderlich",
+		"name required":       "Name erforderlich",
+		"password required":   "Passwort erforderlich",
+		"role required":       "Rolle erforderlich",
+		"invalid role":        "ungültige Rolle",
+		"invalid email":       "ungültige E-Mail-Adresse",
+		"user already exists": "Benutzer existiert bereits",
+		"user not found":      "Benutzer nicht gefunden",
+		"team not found":      "Team nicht gefunden",
+		"location not found":  "Standort nicht gefunden",
+
+		"start time required":                          "Startzeit erforderlich",
+		"end time required":                            "Endzeit erforderlich",
+		"start and end time required":                  "Start- und Endzeit erforderlich",
+		"shift start time must precede shift end time": "Schichtbeginn muss vor dem Schichtende liegen",
+		"color must be formatted #RRGGBB":              "Farbe muss im Format #RRGGBB angegeben werden",
+		"capacity cannot be negative":                  "Kapazität darf nicht negativ sein",
+
+		"must be a string":                "muss eine Zeichenkette sein",
+		"must be true or false":           "muss true oder false sein",
+		"must be a whole number":          "muss eine ganze Zahl sein",
+		"must be a positive whole number": "muss eine positive ganze Zahl sein",
+		"must be a number":                "muss eine Zahl sein",
+		"must be a list":                  "muss eine Liste sein",
+		"must be an object":               "muss ein Objekt sein",
+
+		"%d shiftr notifications": "%d shiftr-Benachrichtigungen",
+	},
+	"es": {
+		"Bad Request":           "Solicitud incorrecta",
+		"Unauthorized":          "No autorizado",
+		"Forbidden":             "Prohibido",
+		"Not Found":             "No encontrado",
+		"Conflict":              "Conflicto",
+		"Too Many Requests":     "Demasiadas solicitudes",
+		"Internal Server Error": "Error interno del servidor",
+
+		"invalid object":      "objeto no válido",
+		"invalid parameters":  "parámetros no válidos",
+		"invalid time zone":   "zona horaria no válida",
+		"token required":      "se requiere un token",
+		"name required":       "se requiere un nombre",
+		"password required":   "se requiere una contraseña",
+		"role required":       "se requiere un rol",
+		"invalid role":        "rol no válido",
+		"invalid email":       "correo electrónico no válido",
+		"user already exists": "el usuario ya existe",
+		"user not found":      "usuario no encontrado",
+		"team not found":      "equipo no encontrado",
+		"location not found":  "ubicación no encontrada",
+
+		"start time required":                          "se requiere la hora de inicio",
+		"end time required":                            "se requiere la hora de fin",
+		"start and end time required":                  "se requieren la hora de inicio y de fin",
+		"shift start time must precede shift end time": "el inicio del turno debe preceder a su fin",
+		"color must be formatted #RRGGBB":              "el color debe tener el formato #RRGGBB",
+		"capacity cannot be negative":                  "la capacidad no puede ser negativa",
+
+		"must be a string":                "debe ser una cadena",
+		"must be true or false":           "debe ser true o false",
+		"must be a whole number":          "debe ser un número entero",
+		"must be a positive whole number": "debe ser un número entero positivo",
+		"must be a number":                "debe ser un número",
+		"must be a list":                  "debe ser una lista",
+		"must be an object":               "debe ser un objeto",
+
+		"%d shiftr notifications": "%d notificaciones de shiftr",
+	},
+	"fr": {
+		"Bad Request":           "Requête invalide",
+		"Unauthorized":          "Non autorisé",
+		"Forbidden":             "Interdit",
+		"Not Found":             "Introuvable",
+		"Conflict":              "Conflit",
+		"Too Many Requests":     "Trop de requêtes",
+		"Internal Server Error": "Erreur interne du serveur",
+
+		"invalid object":      "objet invalide",
+		"invalid parameters":  "paramètres invalides",
+		"invalid time zone":   "fuseau horaire invalide",
+		"token required":      "jeton requis",
+		"name required":       "nom requis",
+		"password required":   "mot de passe requis",
+		"role required":       "rôle requis",
+		"invalid role":        "rôle invalide",
+		"invalid email":       "adresse e-mail invalide",
+		"user already exists": "l'utilisateur existe déjà",
+		"user not found":      "utilisateur introuvable",
+		"team not found":      "équipe introuvable",
+		"location not found":  "lieu introuvable",
+
+		"start time required":                          "heure de début requise",
+		"end time required":                            "heure de fin requise",
+		"start and end time required":                  "heures de début et de fin requises",
+		"shift start time must precede shift end time": "le début du créneau doit précéder sa fin",
+		"color must be formatted #RRGGBB":              "la couleur doit être au format #RRGGBB",
+		"capacity cannot be negative":                  "la capacité ne peut pas être négative",
+
+		"must be a string":                "doit être une chaîne",
+		"must be true or false":           "doit être true ou false",
+		"must be a whole number":          "doit être un nombre entier",
+		"must be a positive whole number": "doit être un nombre entier positif",
+		"must be a number":                "doit être un nombre",
+		"must be a list":                  "doit être une liste",
+		"must be an object":               "doit être un objet",
+
+		"%d shiftr notifications": "%d notifications shiftr",
+	},
+}
@@ -0,0 +1,161 @@
+// Package i18n negotiates the locale of a request and translates the messages shiftr returns into it.
+// Messages are looked up by their English text, which is returned as is when no translation exists.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default is the locale used when none of the supported locales are preferred
+const Default = "en"
+
+// Catalog holds the translations of each supported locale other than the Default, keyed by the English message.
+// A nil Catalog holds the translations bundled with shiftr.
+type Catalog struct {
+	locales map[string]map[string]string
+}
+
+// New returns a Catalog of the translations bundled with shiftr, which further catalogs can be loaded into
+// without changing those of any other Catalog
+func New() *Catalog {
+	c := &Catalog{locales: make(map[string]map[string]string, len(catalogs))}
+	for locale, translations := range catalogs {
+		c.locales[locale] = make(map[string]string, len(translations))
+		for message, translated := range translations {
+			c.locales[locale][message] = translated
+		}
+	}
+
+	return c
+}
+
+// table returns the translations of each locale held by the Catalog
+func (c *Catalog) table() map[string]map[string]string {
+	if c == nil {
+		return catalogs
+	}
+
+	return c.locales
+}
+
+// Supported returns the locales messages are translated into by the bundled translations, including the Default
+func Supported() []string {
+	return (*Catalog)(nil).Supported()
+}
+
+// Supported returns the locales messages are translated into, including the Default
+func (c *Catalog) Supported() []string {
+	locales := []string{Default}
+	for locale := range c.table() {
+		if locale != Default {
+			locales = append(locales, locale)
+		}
+	}
+
+	sort.Strings(locales)
+
+	return locales
+}
+
+// IsSupported reports whether messages are translated into the locale by the bundled translations
+func IsSupported(locale string) bool {
+	return (*Catalog)(nil).IsSupported(locale)
+}
+
+// IsSupported reports whether messages are translated into the locale, regardless of its region
+func (c *Catalog) IsSupported(locale string) bool {
+	locale = base(locale)
+
+	_, ok := c.table()[locale]
+
+	return ok || locale == Default
+}
+
+// Negotiate returns the locale best matching an Accept-Language header as Catalog.Negotiate does, among those
+// of the bundled translations
+func Negotiate(header string, fallback ...string) string {
+	return (*Catalog)(nil).Negotiate(header, fallback...)
+}
+
+// Negotiate returns the supported locale best matching an Accept-Language header, trying each fallback in
+// turn, such as the locale a user prefers, when the header matches none. Regions are ignored, so fr-CH
+// matches fr. The Default is returned when nothing matches.
+func (c *Catalog) Negotiate(header string, fallback ...string) string {
+	type tag struct {
+		locale string
+		q      float64
+	}
+
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		if fields[0] == "" {
+			continue
+		}
+
+		t := tag{locale: fields[0], q: 1}
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				if err == nil {
+					t.q = q
+				}
+			}
+		}
+
+		if t.q > 0 {
+			tags = append(tags, t)
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	for _, t := range tags {
+		if c.IsSupported(t.locale) {
+			return base(t.locale)
+		}
+	}
+
+	for _, locale := range fallback {
+		if locale != "" && c.IsSupported(locale) {
+			return base(locale)
+		}
+	}
+
+	return Default
+}
+
+// Translate returns the message in the locale with the bundled translations, or as is when it has none
+func Translate(locale, message string) string {
+	return (*Catalog)(nil).Translate(locale, message)
+}
+
+// Translate returns the message in the locale, or as is when it has no translation
+func (c *Catalog) Translate(locale, message string) string {
+	if translated, ok := c.table()[base(locale)][message]; ok {
+		return translated
+	}
+
+	return message
+}
+
+// Sprintf formats according to the translation of the format specifier in the locale
+func (c *Catalog) Sprintf(locale, format string, args ...interface{}) string {
+	return fmt.Sprintf(c.Translate(locale, format), args...)
+}
+
+// base returns the primary language subtag of the locale, lowercased
+func base(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	return locale
+}
@@ -0,0 +1,90 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Load adds the translations to the Catalog for the locale, replacing those already given for the same messages,
+// so that a locale can be supported, or its bundled translations corrected, without rebuilding shiftr. It must be
+// called on a Catalog from New before messages are translated with it, such as when the server is initialized.
+func (c *Catalog) Load(locale string, translations map[string]string) error {
+	locale = base(locale)
+	if !validLocale(locale) {
+		return fmt.Errorf("invalid locale %q", locale)
+	}
+
+	catalog, ok := c.locales[locale]
+	if !ok {
+		catalog = make(map[string]string, len(translations))
+		c.locales[locale] = catalog
+	}
+
+	for message, translated := range translations {
+		if translated != "" {
+			catalog[message] = translated
+		}
+	}
+
+	return nil
+}
+
+// LoadFile loads the catalog of a JSON file named after its locale, such as pt.json, holding an object of the
+// English messages and their translations. Messages left untranslated, as empty strings, are returned as they are.
+func (c *Catalog) LoadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var translations map[string]string
+
+	err = json.Unmarshal(data, &translations)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	locale := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	err = c.Load(locale, translations)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	return nil
+}
+
+// LoadDir loads the catalog of every JSON file in the directory with LoadFile
+func (c *Catalog) LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		err = c.LoadFile(file)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validLocale reports whether the primary language subtag is two or three letters
+func validLocale(locale string) bool {
+	if len(locale) < 2 || len(locale) > 3 {
+		return false
+	}
+
+	for _, r := range locale {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,335 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Person identifies the organizer or an attendee of an Event
+type Person struct {
+	Name  string // common name (CN parameter), if provided
+	Email string // address of a mailto: calendar user, if provided
+}
+
+var (
+	ErrNoCalendar   = errors.New("ical: no VCALENDAR object found")
+	ErrUnterminated = errors.New("ical: unterminated component")
+)
+
+// property is a single unfolded content line split into its name, parameters and value
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Decode parses the first VCALENDAR object read from r. Only the properties
+// represented by Calendar and Event are kept, everything else is ignored.
+func Decode(r io.Reader) (*Calendar, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		cal   *Calendar
+		event *Event
+		depth int // nesting of components within the current VEVENT, such as VALARM
+	)
+
+	for n, line := range lines {
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("ical: line %d: %s", n+1, err)
+		}
+
+		switch {
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VCALENDAR") && cal == nil:
+			cal = &Calendar{}
+		case cal == nil:
+			continue
+		case prop.name == "END" && strings.EqualFold(prop.value, "VCALENDAR") && event == nil:
+			return cal, nil
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VEVENT") && event == nil:
+			event = &Event{}
+		case event == nil:
+			if prop.name == "X-WR-CALNAME" {
+				cal.Name = unescape(prop.value)
+			}
+		case prop.name == "BEGIN":
+			depth++
+		case prop.name == "END" && depth > 0:
+			depth--
+		case prop.name == "END":
+			if event.End.IsZero() && !event.duration.zero() {
+				event.End = event.duration.after(event.Start)
+			}
+			cal.Events = append(cal.Events, *event)
+			event = nil
+		case depth == 0:
+			if err := event.set(prop); err != nil {
+				return nil, fmt.Errorf("ical: line %d: %s", n+1, err)
+			}
+		}
+	}
+
+	if cal == nil {
+		return nil, ErrNoCalendar
+	}
+
+	return nil, ErrUnterminated
+}
+
+// set assigns the property to the matching Event field
+func (e *Event) set(prop property) error {
+	var err error
+
+	switch prop.name {
+	case "UID":
+		e.UID = unescape(prop.value)
+	case "SUMMARY":
+		e.Summary = unescape(prop.value)
+	case "DESCRIPTION":
+		e.Description = unescape(prop.value)
+	case "DTSTART":
+		e.Start, err = parseTime(prop)
+	case "DTEND":
+		e.End, err = parseTime(prop)
+	case "DURATION":
+		e.duration, err = parseDuration(prop.value)
+	case "CREATED":
+		e.Created, err = parseTime(prop)
+	case "LAST-MODIFIED":
+		e.Modified, err = parseTime(prop)
+	case "ORGANIZER":
+		p := parsePerson(prop)
+		e.Organizer = &p
+	case "ATTENDEE":
+		e.Attendees = append(e.Attendees, parsePerson(prop))
+	case "RRULE":
+		e.Recurrence, err = parseRule(prop.value)
+	case "EXDATE":
+		for _, v := range strings.Split(prop.value, ",") {
+			var t time.Time
+			t, err = parseTime(property{name: prop.name, params: prop.params, value: v})
+			if err != nil {
+				break
+			}
+			e.Exceptions = append(e.Exceptions, t)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid %s: %s", prop.name, err)
+	}
+
+	return nil
+}
+
+// unfold reads the content lines from r, joining folded continuation lines
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+// parseLine splits a content line of the form NAME;PARAM=VALUE:VALUE
+func parseLine(line string) (property, error) {
+	prop := property{params: make(map[string]string)}
+
+	// Find the colon separating the value, ignoring any within quoted parameter values
+	quoted := false
+	sep := -1
+	for i := 0; i < len(line) && sep < 0; i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				sep = i
+			}
+		}
+	}
+
+	if sep < 0 {
+		return prop, errors.New("missing value")
+	}
+
+	prop.value = line[sep+1:]
+
+	parts := splitParams(line[:sep])
+	prop.name = strings.ToUpper(parts[0])
+
+	for _, param := range parts[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		prop.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+	}
+
+	return prop, nil
+}
+
+// splitParams splits the name and parameters on semicolons outside of quotes
+func splitParams(s string) []string {
+	var (
+		parts  []string
+		quoted bool
+		start  int
+	)
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+var unescaper = strings.NewReplacer(
+	`\\`, `\`,
+	`\;`, `;`,
+	`\,`, `,`,
+	`\n`, "\n",
+	`\N`, "\n",
+)
+
+// unescape reverses the escaping of a TEXT property value
+func unescape(s string) string {
+	return unescaper.Replace(s)
+}
+
+const (
+	localTimeFormat = "20060102T150405"
+	dateFormat      = "20060102"
+)
+
+// parseTime parses a DATE-TIME or DATE value, honouring the TZID parameter for local times
+func parseTime(prop property) (time.Time, error) {
+	value := prop.value
+
+	if prop.params["VALUE"] == "DATE" || len(value) == len(dateFormat) {
+		return time.Parse(dateFormat, value)
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(timeFormat, value)
+	}
+
+	loc := time.UTC
+	if tzid := prop.params["TZID"]; tzid != "" {
+		l, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, err
+		}
+		loc = l
+	}
+
+	return time.ParseInLocation(localTimeFormat, value, loc)
+}
+
+// duration is a DURATION value. Days and weeks are nominal, so a day spanning a daylight saving
+// change in the zone of the start is 23 or 25 hours long, while the time part is exact.
+type duration struct {
+	days  int
+	exact time.Duration
+}
+
+func (d duration) zero() bool {
+	return d.days == 0 && d.exact == 0
+}
+
+// after returns the time the duration ends when it begins at t
+func (d duration) after(t time.Time) time.Time {
+	return t.AddDate(0, 0, d.days).Add(d.exact)
+}
+
+// parseDuration parses a DURATION value such as PT8H, P1D or P1W
+func parseDuration(value string) (duration, error) {
+	s := strings.TrimPrefix(value, "+")
+	if !strings.HasPrefix(s, "P") {
+		return duration{}, errors.New("malformed duration")
+	}
+	s = s[1:]
+
+	var (
+		d      duration
+		n      int
+		digits bool
+		inTime bool
+	)
+
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			n = n*10 + int(r-'0')
+			digits = true
+			continue
+		case r == 'T':
+			inTime = true
+			continue
+		case !digits:
+			return duration{}, errors.New("malformed duration")
+		case r == 'W' && !inTime:
+			d.days += n * 7
+		case r == 'D' && !inTime:
+			d.days += n
+		case r == 'H' && inTime:
+			d.exact += time.Duration(n) * time.Hour
+		case r == 'M' && inTime:
+			d.exact += time.Duration(n) * time.Minute
+		case r == 'S' && inTime:
+			d.exact += time.Duration(n) * time.Second
+		default:
+			return duration{}, errors.New("malformed duration")
+		}
+
+		n, digits = 0, false
+	}
+
+	if digits {
+		return duration{}, errors.New("malformed duration")
+	}
+
+	return d, nil
+}
+
+// parsePerson parses a CAL-ADDRESS value along with its CN parameter
+func parsePerson(prop property) Person {
+	p := Person{Name: prop.params["CN"]}
+
+	if len(prop.value) > 7 && strings.EqualFold(prop.value[:7], "mailto:") {
+		p.Email = prop.value[7:]
+	}
+
+	return p
+}
@@ -0,0 +1,97 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestDecodeDurationAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		start    string
+		duration string
+		end      string
+		hours    float64
+	}{
+		{"day before spring forward", "20260306T090000", "P1D", "2026-03-07 09:00", 24},
+		{"day over spring forward", "20260307T090000", "P1D", "2026-03-08 09:00", 23},
+		{"exact hours over spring forward", "20260307T090000", "PT24H", "2026-03-08 10:00", 24},
+		{"night over spring forward", "20260307T220000", "PT8H", "2026-03-08 07:00", 8},
+		{"day over fall back", "20261031T090000", "P1D", "2026-11-01 09:00", 25},
+		{"exact hours over fall back", "20261031T090000", "PT24H", "2026-11-01 08:00", 24},
+		{"week over fall back", "20261029T090000", "P1W", "2026-11-05 09:00", 7*24 + 1},
+		{"day and hours over fall back", "20261031T220000", "P1DT8H", "2026-11-02 06:00", 33},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cal, err := Decode(strings.NewReader(strings.Join([]string{
+				"BEGIN:VCALENDAR",
+				"VERSION:2.0",
+				"BEGIN:VEVENT",
+				"UID:1",
+				"DTSTART;TZID=America/New_York:" + tt.start,
+				"DURATION:" + tt.duration,
+				"END:VEVENT",
+				"END:VCALENDAR",
+			}, "\r\n")))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(cal.Events) != 1 {
+				t.Fatalf("decoded %d events, want 1", len(cal.Events))
+			}
+
+			event := cal.Events[0]
+
+			if got := event.End.In(loc).Format("2006-01-02 15:04"); got != tt.end {
+				t.Errorf("end %s, want %s", got, tt.end)
+			}
+
+			if got := event.End.Sub(event.Start).Hours(); got != tt.hours {
+				t.Errorf("lasted %v hours, want %v", got, tt.hours)
+			}
+		})
+	}
+}
+
+func TestEncodeAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A night shift ending at 06:00 on the clock the morning the clocks go forward
+	start := time.Date(2026, time.March, 7, 22, 0, 0, 0, loc)
+	end := time.Date(2026, time.March, 8, 6, 0, 0, 0, loc)
+
+	var b strings.Builder
+
+	err = (&Calendar{Events: []Event{{UID: "1", Start: start, End: end}}}).Encode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"DTSTART:20260308T030000Z", "DTEND:20260308T100000Z"} {
+		if !strings.Contains(b.String(), line+"\r\n") {
+			t.Errorf("encoded calendar does not contain %s:\n%s", line, b.String())
+		}
+	}
+
+	cal, err := Decode(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cal.Events[0].End.Sub(cal.Events[0].Start); got != 7*time.Hour {
+		t.Errorf("decoded event lasts %s, want 7h", got)
+	}
+}
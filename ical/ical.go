@@ -0,0 +1,122 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Event represents a single VEVENT within a Calendar
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+	Created     time.Time
+	Modified    time.Time
+
+	// Populated when decoding, not written by Encode
+	Organizer  *Person
+	Attendees  []Person
+	Recurrence *Rule
+	Exceptions []time.Time // EXDATE occurrences excluded from the Recurrence
+
+	duration duration // DURATION, used when no DTEND is given
+}
+
+// Calendar represents an iCalendar (RFC 5545) VCALENDAR object
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+const (
+	prodID     = "-//btnmasher//shiftr//EN"
+	timeFormat = "20060102T150405Z"
+	maxLine    = 75 // octets, excluding the line break
+)
+
+// Encode writes the Calendar to w in the iCalendar format
+func (cal *Calendar) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	now := time.Now()
+
+	writeLine(bw, "BEGIN:VCALENDAR")
+	writeLine(bw, "VERSION:2.0")
+	writeLine(bw, "PRODID:"+prodID)
+	writeLine(bw, "CALSCALE:GREGORIAN")
+	writeLine(bw, "METHOD:PUBLISH")
+
+	if cal.Name != "" {
+		writeLine(bw, "X-WR-CALNAME:"+escape(cal.Name))
+	}
+
+	for _, event := range cal.Events {
+		writeLine(bw, "BEGIN:VEVENT")
+		writeLine(bw, "UID:"+escape(event.UID))
+		writeLine(bw, "DTSTAMP:"+formatTime(now))
+		writeLine(bw, "DTSTART:"+formatTime(event.Start))
+		writeLine(bw, "DTEND:"+formatTime(event.End))
+		writeLine(bw, "SUMMARY:"+escape(event.Summary))
+
+		if event.Description != "" {
+			writeLine(bw, "DESCRIPTION:"+escape(event.Description))
+		}
+
+		if !event.Created.IsZero() {
+			writeLine(bw, "CREATED:"+formatTime(event.Created))
+		}
+
+		if !event.Modified.IsZero() {
+			writeLine(bw, "LAST-MODIFIED:"+formatTime(event.Modified))
+		}
+
+		writeLine(bw, "END:VEVENT")
+	}
+
+	writeLine(bw, "END:VCALENDAR")
+
+	return bw.Flush()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+var escaper = strings.NewReplacer(
+	`\`, `\\`,
+	`;`, `\;`,
+	`,`, `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+// escape escapes a TEXT property value
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// writeLine writes a content line terminated with CRLF, folding it into multiple
+// lines if it is longer than 75 octets without splitting multi-byte characters
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLine
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+
+		fmt.Fprintf(w, "%s\r\n ", line[:cut])
+		line = line[cut:]
+		limit = maxLine - 1 // continuation lines begin with a space
+	}
+
+	fmt.Fprintf(w, "%s\r\n", line)
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
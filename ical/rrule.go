@@ -0,0 +1,196 @@
+package ical
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the FREQ of a recurrence Rule
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// Rule is the supported subset of an RRULE: a daily, weekly or monthly frequency with an
+// optional interval, bounded by a count or an end time, and for weekly rules a set of weekdays
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	Count    int
+	Until    time.Time
+	ByDay    []time.Weekday
+}
+
+var ErrUnsupportedRule = errors.New("ical: unsupported recurrence rule")
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// parseRule parses an RRULE value such as FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10
+func parseRule(value string) (*Rule, error) {
+	rule := &Rule{Interval: 1}
+
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.New("malformed rule part")
+		}
+
+		var err error
+
+		switch strings.ToUpper(kv[0]) {
+		case "FREQ":
+			rule.Freq = Frequency(strings.ToUpper(kv[1]))
+			if rule.Freq != Daily && rule.Freq != Weekly && rule.Freq != Monthly {
+				return nil, fmt.Errorf("%w: FREQ=%s", ErrUnsupportedRule, kv[1])
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(kv[1])
+			if err == nil && rule.Interval < 1 {
+				err = errors.New("interval must be positive")
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(kv[1])
+		case "UNTIL":
+			rule.Until, err = parseTime(property{value: kv[1]})
+		case "BYDAY":
+			for _, day := range strings.Split(kv[1], ",") {
+				wd, ok := weekdays[strings.ToUpper(day)]
+				if !ok {
+					return nil, fmt.Errorf("%w: BYDAY=%s", ErrUnsupportedRule, day)
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+		case "WKST":
+			// Only affects weekly rules with an interval, weeks are assumed to start on Monday
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedRule, kv[0])
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, errors.New("FREQ required")
+	}
+
+	if len(rule.ByDay) > 0 && rule.Freq != Weekly {
+		return nil, fmt.Errorf("%w: BYDAY requires FREQ=WEEKLY", ErrUnsupportedRule)
+	}
+
+	return rule, nil
+}
+
+// Occurrences returns the start times of the event, expanding its Recurrence and removing
+// its Exceptions. Expansion stops at the limit or when occurrences start after the horizon,
+// which bounds rules with neither a count nor an end time.
+func (e *Event) Occurrences(horizon time.Time, limit int) []time.Time {
+	if e.Recurrence == nil {
+		return []time.Time{e.Start}
+	}
+
+	excluded := make(map[int64]bool, len(e.Exceptions))
+	for _, t := range e.Exceptions {
+		excluded[t.Unix()] = true
+	}
+
+	var (
+		starts []time.Time
+		count  int
+		rule   = e.Recurrence
+	)
+
+	// emit records a candidate, reporting false once expansion should stop
+	emit := func(t time.Time) bool {
+		if (!rule.Until.IsZero() && t.After(rule.Until)) || t.After(horizon) {
+			return false
+		}
+
+		if rule.Count > 0 && count >= rule.Count {
+			return false
+		}
+		count++
+
+		if !excluded[t.Unix()] {
+			starts = append(starts, t)
+		}
+
+		return len(starts) < limit
+	}
+
+	for period := 0; ; period++ {
+		switch rule.Freq {
+		case Daily:
+			if !emit(e.Start.AddDate(0, 0, period*rule.Interval)) {
+				return starts
+			}
+		case Monthly:
+			t := e.Start.AddDate(0, period*rule.Interval, 0)
+			if t.Day() != e.Start.Day() {
+				// Months without the day of the start are skipped, as in RFC 5545
+				if t.After(horizon) {
+					return starts
+				}
+				continue
+			}
+			if !emit(t) {
+				return starts
+			}
+		case Weekly:
+			if !e.emitWeek(period, emit) {
+				return starts
+			}
+		}
+	}
+}
+
+// emitWeek emits the occurrences within the nth repetition of a weekly rule
+func (e *Event) emitWeek(period int, emit func(time.Time) bool) bool {
+	rule := e.Recurrence
+
+	if len(rule.ByDay) == 0 {
+		return emit(e.Start.AddDate(0, 0, 7*period*rule.Interval))
+	}
+
+	// Weeks start on Monday, find the Monday of the week containing the start
+	offset := (int(e.Start.Weekday()) + 6) % 7
+	monday := e.Start.AddDate(0, 0, 7*period*rule.Interval-offset)
+
+	for i := 0; i < 7; i++ {
+		t := monday.AddDate(0, 0, i)
+		if t.Before(e.Start) || !hasWeekday(rule.ByDay, t.Weekday()) {
+			continue
+		}
+
+		if !emit(t) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hasWeekday(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+
+	return false
+}
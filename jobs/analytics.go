@@ -0,0 +1,157 @@
+package jobs
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/logging"
+	"github.com/btnmasher/shiftr/opaque"
+	"github.com/btnmasher/shiftr/storage"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	// AnalyticsPrefix is the key prefix under which analytics exports are stored
+	AnalyticsPrefix = "analytics/"
+
+	// analyticsBatchSize is how many rows are read from the database at a time while exporting
+	analyticsBatchSize = 500
+)
+
+// AnalyticsExport is a background job which periodically dumps the shifts and users tables
+// as CSV files to a BlobStore, so BI tools can ingest them without querying the operational database
+type AnalyticsExport struct {
+	DB       *gorm.DB
+	Store    storage.BlobStore
+	Interval time.Duration
+	Log      *zerolog.Logger // nil logs to logging.Default()
+}
+
+// Run exports every Interval until the context is cancelled
+func (a *AnalyticsExport) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			_, err := a.Export(ctx, now)
+			if err != nil {
+				logging.Or(a.Log).Error().Err(err).Msg("analytics export")
+			}
+		}
+	}
+}
+
+// Export writes a snapshot of the shifts and users tables beneath a key prefix named after the time,
+// returning the prefix of the snapshot. Users and shifts are identified by the same IDs the API exposes.
+func (a *AnalyticsExport) Export(ctx context.Context, now time.Time) (string, error) {
+	db := a.DB.WithContext(ctx)
+	prefix := AnalyticsPrefix + now.UTC().Format("20060102T150405Z") + "/"
+
+	err := a.put(ctx, prefix+"shifts.csv", func(w *csv.Writer) error {
+		err := w.Write([]string{"id", "user_id", "start", "end", "capacity", "created_at", "updated_at"})
+		if err != nil {
+			return err
+		}
+
+		var batch []*models.Shift
+
+		return db.Model(&models.Shift{}).Order("id").FindInBatches(&batch, analyticsBatchSize, func(_ *gorm.DB, _ int) error {
+			for _, shift := range batch {
+				sid, uid, err := shift.ExternalIDs()
+				if err != nil {
+					return err
+				}
+
+				err = w.Write([]string{
+					sid,
+					uid,
+					shift.Start.UTC().Format(time.RFC3339),
+					shift.End.UTC().Format(time.RFC3339),
+					strconv.Itoa(shift.Capacity),
+					shift.CreatedAt.UTC().Format(time.RFC3339),
+					shift.UpdatedAt.UTC().Format(time.RFC3339),
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}).Error
+	})
+	if err != nil {
+		return "", fmt.Errorf("exporting shifts: %s", err)
+	}
+
+	err = a.put(ctx, prefix+"users.csv", func(w *csv.Writer) error {
+		err := w.Write([]string{"id", "name", "role", "team_id", "created_at", "updated_at"})
+		if err != nil {
+			return err
+		}
+
+		var batch []*models.User
+
+		// Only non-sensitive columns are exported
+		return db.Model(&models.User{}).Select("id", "name", "role", "team_id", "created_at", "updated_at").
+			Order("id").FindInBatches(&batch, analyticsBatchSize, func(_ *gorm.DB, _ int) error {
+			for _, user := range batch {
+				uid, err := opaque.Encode(opaque.User, user.ID)
+				if err != nil {
+					return err
+				}
+
+				err = w.Write([]string{
+					uid,
+					user.Name,
+					user.Role,
+					user.TeamID,
+					user.CreatedAt.UTC().Format(time.RFC3339),
+					user.UpdatedAt.UTC().Format(time.RFC3339),
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}).Error
+	})
+	if err != nil {
+		return "", fmt.Errorf("exporting users: %s", err)
+	}
+
+	return prefix, nil
+}
+
+// put streams the CSV produced by write into the store under the key
+func (a *AnalyticsExport) put(ctx context.Context, key string, write func(w *csv.Writer) error) error {
+	pr, pw := io.Pipe()
+
+	go func() {
+		w := csv.NewWriter(pw)
+
+		err := write(w)
+		if err == nil {
+			w.Flush()
+			err = w.Error()
+		}
+
+		pw.CloseWithError(err)
+	}()
+
+	err := a.Store.Put(ctx, key, pr, "text/csv")
+
+	// Unblock the writer if the store gave up early
+	pr.CloseWithError(err)
+
+	return err
+}
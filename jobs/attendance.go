@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"context"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/logging"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"time"
+)
+
+// DefaultNoShowInterval is how often the scheduler checks for shifts ended without their worker clocking in
+const DefaultNoShowInterval = time.Minute
+
+// NoShows is a background scheduler which flags the workers of shifts that ended without them clocking in
+type NoShows struct {
+	DB       *gorm.DB
+	Interval time.Duration   // how often to check for no-shows
+	Log      *zerolog.Logger // nil logs to logging.Default()
+}
+
+// Run flags no-shows every Interval until the context is cancelled
+func (n *NoShows) Run(ctx context.Context) {
+	interval := n.Interval
+	if interval <= 0 {
+		interval = DefaultNoShowInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := n.Tick(ctx, time.Now())
+		if err != nil {
+			logging.Or(n.Log).Error().Err(err).Msg("no-shows")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick flags every shift ended by the specified time without its worker clocking in
+func (n *NoShows) Tick(ctx context.Context, now time.Time) error {
+	noShows, err := models.DetectNoShows(n.DB.WithContext(ctx), now)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range noShows {
+		logging.Or(n.Log).Info().Str("user_id", a.UserID).Str("shift_id", a.ShiftID).Msg("no-show, user did not clock in")
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/logging"
+	"github.com/btnmasher/shiftr/notify"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"time"
+)
+
+// DefaultBidAwardInterval is how often the scheduler checks for closed biddings to award
+const DefaultBidAwardInterval = time.Minute
+
+// BidAwards is a background scheduler which awards shifts bid on under the seniority rule once bidding closes
+type BidAwards struct {
+	DB       *gorm.DB
+	Notifier notify.Notifier
+	Interval time.Duration   // how often to check for closed biddings
+	Log      *zerolog.Logger // nil logs to logging.Default()
+}
+
+// Run awards closed biddings every Interval until the context is cancelled
+func (b *BidAwards) Run(ctx context.Context) {
+	interval := b.Interval
+	if interval <= 0 {
+		interval = DefaultBidAwardInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := b.Tick(ctx, time.Now())
+		if err != nil {
+			logging.Or(b.Log).Error().Err(err).Msg("bid awards")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick awards every seniority bidding closed by the specified time to its most senior bidder. Biddings
+// closing without bids are left for a manager to reopen.
+func (b *BidAwards) Tick(ctx context.Context, now time.Time) error {
+	db := b.DB.WithContext(ctx)
+
+	biddings, err := models.ListDueBiddings(db, now)
+	if err != nil {
+		return err
+	}
+
+	for _, bidding := range biddings {
+		uid, err := bidding.SeniorBidder(db)
+		if errors.Is(err, models.ErrNoBids) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		shift, err := models.FindShiftByID(db, bidding.ShiftID)
+		if err != nil {
+			logging.Or(b.Log).Error().Err(err).Str("shift_id", bidding.ShiftID).Msg("bid awards")
+			continue
+		}
+
+		err = bidding.Award(db, shift, uid)
+		if err != nil {
+			logging.Or(b.Log).Error().Err(err).Str("shift_id", bidding.ShiftID).Msg("bid awards")
+			continue
+		}
+
+		b.notify(ctx, db, shift)
+	}
+
+	return nil
+}
+
+// notify lets the winning bidder know they have been awarded the shift
+func (b *BidAwards) notify(ctx context.Context, db *gorm.DB, shift *models.Shift) {
+	user, err := models.FindUserByID(db, shift.UserID)
+	if err == nil {
+		l := models.FindLocalization(db, user.ID)
+		err = b.Notifier.Notify(ctx, notify.Message{
+			UserID:  user.ID,
+			To:      user.Email,
+			Subject: "Shift awarded",
+			Event:   notify.EventShiftAwarded,
+			Locale:  l.Locale,
+			Body: fmt.Sprintf("Your bid was successful, you are now working the shift from %s to %s",
+				l.Format(shift.Start), l.Format(shift.End)),
+		})
+	}
+	if err != nil {
+		logging.Or(b.Log).Error().Err(err).Msg("bid award notification")
+	}
+}
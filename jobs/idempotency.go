@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"context"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/logging"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"time"
+)
+
+// DefaultIdempotencyPurgeInterval is how often the responses kept for requests with an Idempotency-Key are purged
+const DefaultIdempotencyPurgeInterval = time.Hour
+
+// IdempotencyPurge is a background scheduler which deletes the responses kept for requests with an
+// Idempotency-Key once they expire
+type IdempotencyPurge struct {
+	DB       *gorm.DB
+	Interval time.Duration   // how often to purge expired responses
+	Log      *zerolog.Logger // nil logs to logging.Default()
+}
+
+// Run purges expired responses every Interval until the context is cancelled
+func (p *IdempotencyPurge) Run(ctx context.Context) {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = DefaultIdempotencyPurgeInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := p.Tick(ctx, time.Now())
+		if err != nil {
+			logging.Or(p.Log).Error().Err(err).Msg("idempotency purge")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick deletes the responses expired by the specified time
+func (p *IdempotencyPurge) Tick(ctx context.Context, now time.Time) error {
+	_, err := models.PurgeIdempotentRequests(p.DB.WithContext(ctx), now)
+
+	return err
+}
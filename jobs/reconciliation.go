@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"context"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/logging"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	// DefaultReconcileVariance is how far the time worked may differ from the time scheduled before it is flagged
+	DefaultReconcileVariance = time.Minute * 15
+
+	// ReconcileDelay is how long after midnight the previous day is reconciled, giving the workers of shifts
+	// ending at midnight time to clock out
+	ReconcileDelay = time.Hour
+)
+
+// Reconciliation is a background scheduler which reconciles each day's shifts with the time clocked for
+// them overnight, flagging the discrepancies for managers to review
+type Reconciliation struct {
+	DB       *gorm.DB
+	Variance time.Duration   // how far worked time may differ from scheduled time unflagged
+	Location *time.Location  // zone whose midnight ends each day
+	Log      *zerolog.Logger // nil logs to logging.Default()
+}
+
+// Run reconciles the previous day every night until the context is cancelled
+func (r *Reconciliation) Run(ctx context.Context) {
+	for {
+		timer := time.NewTimer(time.Until(r.next(time.Now())))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		err := r.Tick(ctx, time.Now())
+		if err != nil {
+			logging.Or(r.Log).Error().Err(err).Msg("reconciliation")
+		}
+	}
+}
+
+// next returns the time of the first nightly reconciliation after now
+func (r *Reconciliation) next(now time.Time) time.Time {
+	now = now.In(r.location())
+	next := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Add(ReconcileDelay)
+	if !next.After(now) {
+		next = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location()).Add(ReconcileDelay)
+	}
+
+	return next
+}
+
+// Tick reconciles the day before the specified time
+func (r *Reconciliation) Tick(ctx context.Context, now time.Time) error {
+	variance := r.Variance
+	if variance <= 0 {
+		variance = DefaultReconcileVariance
+	}
+
+	day := now.In(r.location()).AddDate(0, 0, -1)
+
+	discrepancies, err := models.Reconcile(r.DB.WithContext(ctx), day, variance, now)
+	if err != nil {
+		return err
+	}
+
+	if len(discrepancies) > 0 {
+		logging.Or(r.Log).Warn().
+			Int("discrepancies", len(discrepancies)).
+			Str("day", day.Format("2006-01-02")).
+			Msg("reconciliation flagged discrepancies")
+	}
+
+	return nil
+}
+
+func (r *Reconciliation) location() *time.Location {
+	if r.Location == nil {
+		return time.UTC
+	}
+
+	return r.Location
+}
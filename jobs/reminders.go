@@ -0,0 +1,178 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/logging"
+	"github.com/btnmasher/shiftr/notify"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	// DefaultReminderLead is how long before a shift starts that its reminder is sent
+	DefaultReminderLead = time.Hour
+
+	// DefaultReminderInterval is how often the scheduler checks for reminders that are due
+	DefaultReminderInterval = time.Minute
+
+	// maxReminderAttempts is how many times delivery of a failed reminder is retried
+	maxReminderAttempts = 3
+)
+
+// Reminders is a background scheduler which notifies users ahead of their upcoming shifts
+type Reminders struct {
+	DB       *gorm.DB
+	Notifier notify.Notifier
+	Lead     time.Duration   // default lead time for users without a preference
+	Interval time.Duration   // how often to check for due reminders
+	Log      *zerolog.Logger // nil logs to logging.Default()
+}
+
+// Run checks for due reminders every Interval until the context is cancelled
+func (r *Reminders) Run(ctx context.Context) {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultReminderInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := r.Tick(ctx, time.Now())
+		if err != nil {
+			logging.Or(r.Log).Error().Err(err).Msg("shift reminders")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick sends every reminder that is due at the specified time and has not already been delivered
+func (r *Reminders) Tick(ctx context.Context, now time.Time) error {
+	db := r.DB.WithContext(ctx)
+
+	// Find the furthest any user wants to be reminded ahead of a shift
+	horizon := r.lead()
+
+	var maxLead int
+	err := db.Model(&models.Preference{}).Select("COALESCE(MAX(reminder_lead_minutes), 0)").Scan(&maxLead).Error
+	if err != nil {
+		return err
+	}
+
+	if lead := time.Duration(maxLead) * time.Minute; lead > horizon {
+		horizon = lead
+	}
+
+	// Fetch all upcoming shifts that could have a reminder due
+	shifts, err := models.ListShifts(db,
+		models.FilterStart(now),
+		models.FilterStartsBefore(now.Add(horizon)),
+		models.FilterStatus(models.ShiftPublished),
+	)
+	if err != nil {
+		return err
+	}
+
+	uids := make([]string, 0, len(shifts))
+	for _, shift := range shifts {
+		uids = append(uids, shift.UserID)
+	}
+
+	prefs, err := models.FindPreferences(db, uids)
+	if err != nil {
+		return err
+	}
+
+	for _, shift := range shifts {
+		if shift.UserID == "" {
+			continue
+		}
+
+		pref, ok := prefs[shift.UserID]
+		if !ok {
+			pref = &models.Preference{UserID: shift.UserID}
+		}
+
+		if pref.RemindersOptOut {
+			continue
+		}
+
+		if shift.Start.Add(-pref.ReminderLead(r.lead())).After(now) {
+			continue
+		}
+
+		err = r.remind(ctx, db, shift)
+		if err != nil {
+			logging.Or(r.Log).Error().Err(err).Str("shift_id", shift.ID).Msg("shift reminders")
+		}
+	}
+
+	return nil
+}
+
+func (r *Reminders) lead() time.Duration {
+	if r.Lead <= 0 {
+		return DefaultReminderLead
+	}
+
+	return r.Lead
+}
+
+// remind delivers the reminder for a single shift, recording the delivery attempt
+func (r *Reminders) remind(ctx context.Context, db *gorm.DB, shift *models.Shift) error {
+	reminder, err := models.FindReminder(db, shift.ID, shift.Start)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		reminder = &models.Reminder{
+			ShiftID:    shift.ID,
+			ShiftStart: shift.Start,
+			UserID:     shift.UserID,
+		}
+	}
+
+	// Skip reminders already delivered or which have exhausted their retries
+	if reminder.Status == models.ReminderSent || reminder.Attempts >= maxReminderAttempts {
+		return nil
+	}
+
+	user, err := models.FindUserByID(db, shift.UserID)
+	if err != nil {
+		return err
+	}
+
+	reminder.Attempts++
+
+	l := models.FindLocalization(db, user.ID)
+	err = r.Notifier.Notify(ctx, notify.Message{
+		UserID:  user.ID,
+		To:      user.Email,
+		Subject: "Upcoming shift reminder",
+		Event:   notify.EventShiftReminder,
+		Locale:  l.Locale,
+		Body: fmt.Sprintf("Your shift starts at %s and ends at %s",
+			l.Format(shift.Start), l.Format(shift.End)),
+	})
+
+	if err != nil {
+		reminder.Status = models.ReminderFailed
+		reminder.Error = err.Error()
+	} else {
+		reminder.Status = models.ReminderSent
+		reminder.Error = ""
+	}
+
+	return reminder.Save(db)
+}
@@ -0,0 +1,173 @@
+package logging
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"github.com/rs/zerolog"
+	"io"
+)
+
+// EchoLogger is an echo.Logger writing through a structured logger, so that the lines logged by echo and by the
+// handlers through the context are structured and redacted like every other
+type EchoLogger struct {
+	log    zerolog.Logger
+	prefix string
+}
+
+// Echo returns an echo.Logger writing through the logger
+func Echo(l zerolog.Logger) *EchoLogger {
+	return &EchoLogger{log: l}
+}
+
+// FromEcho returns the structured logger an echo.Logger writes through, or the default logger when it writes
+// through none
+func FromEcho(l echo.Logger) *zerolog.Logger {
+	if el, ok := l.(*EchoLogger); ok {
+		return &el.log
+	}
+
+	return &defaultLogger
+}
+
+// With returns a copy of the logger adding the field to every line, such as the ID of the request being served
+func (l *EchoLogger) With(key, value string) *EchoLogger {
+	return &EchoLogger{log: l.log.With().Str(key, value).Logger(), prefix: l.prefix}
+}
+
+// Output returns a writer logging each write as a line without a level
+func (l *EchoLogger) Output() io.Writer {
+	return l.log
+}
+
+// SetOutput sets the destination of the lines, which are still redacted
+func (l *EchoLogger) SetOutput(w io.Writer) {
+	l.log = l.log.Output(Redact(w))
+}
+
+func (l *EchoLogger) Prefix() string {
+	return l.prefix
+}
+
+// SetPrefix names the logger, added to every line as its prefix field
+func (l *EchoLogger) SetPrefix(p string) {
+	l.prefix = p
+	l.log = l.log.With().Str("prefix", p).Logger()
+}
+
+func (l *EchoLogger) Level() log.Lvl {
+	switch l.log.GetLevel() {
+	case zerolog.TraceLevel, zerolog.DebugLevel:
+		return log.DEBUG
+	case zerolog.InfoLevel:
+		return log.INFO
+	case zerolog.WarnLevel:
+		return log.WARN
+	case zerolog.ErrorLevel:
+		return log.ERROR
+	default:
+		return log.OFF
+	}
+}
+
+func (l *EchoLogger) SetLevel(v log.Lvl) {
+	switch v {
+	case log.DEBUG:
+		l.log = l.log.Level(zerolog.DebugLevel)
+	case log.INFO:
+		l.log = l.log.Level(zerolog.InfoLevel)
+	case log.WARN:
+		l.log = l.log.Level(zerolog.WarnLevel)
+	case log.ERROR:
+		l.log = l.log.Level(zerolog.ErrorLevel)
+	default:
+		l.log = l.log.Level(zerolog.Disabled)
+	}
+}
+
+// SetHeader is a no-op, the fields of every line are set by the structured logger
+func (l *EchoLogger) SetHeader(string) {}
+
+func (l *EchoLogger) Print(i ...interface{}) {
+	l.log.Log().Msg(fmt.Sprint(i...))
+}
+
+func (l *EchoLogger) Printf(format string, args ...interface{}) {
+	l.log.Log().Msgf(format, args...)
+}
+
+func (l *EchoLogger) Printj(j log.JSON) {
+	l.log.Log().Fields(map[string]interface{}(j)).Send()
+}
+
+func (l *EchoLogger) Debug(i ...interface{}) {
+	l.log.Debug().Msg(fmt.Sprint(i...))
+}
+
+func (l *EchoLogger) Debugf(format string, args ...interface{}) {
+	l.log.Debug().Msgf(format, args...)
+}
+
+func (l *EchoLogger) Debugj(j log.JSON) {
+	l.log.Debug().Fields(map[string]interface{}(j)).Send()
+}
+
+func (l *EchoLogger) Info(i ...interface{}) {
+	l.log.Info().Msg(fmt.Sprint(i...))
+}
+
+func (l *EchoLogger) Infof(format string, args ...interface{}) {
+	l.log.Info().Msgf(format, args...)
+}
+
+func (l *EchoLogger) Infoj(j log.JSON) {
+	l.log.Info().Fields(map[string]interface{}(j)).Send()
+}
+
+func (l *EchoLogger) Warn(i ...interface{}) {
+	l.log.Warn().Msg(fmt.Sprint(i...))
+}
+
+func (l *EchoLogger) Warnf(format string, args ...interface{}) {
+	l.log.Warn().Msgf(format, args...)
+}
+
+func (l *EchoLogger) Warnj(j log.JSON) {
+	l.log.Warn().Fields(map[string]interface{}(j)).Send()
+}
+
+func (l *EchoLogger) Error(i ...interface{}) {
+	l.log.Error().Msg(fmt.Sprint(i...))
+}
+
+func (l *EchoLogger) Errorf(format string, args ...interface{}) {
+	l.log.Error().Msgf(format, args...)
+}
+
+func (l *EchoLogger) Errorj(j log.JSON) {
+	l.log.Error().Fields(map[string]interface{}(j)).Send()
+}
+
+func (l *EchoLogger) Fatal(i ...interface{}) {
+	l.log.Fatal().Msg(fmt.Sprint(i...))
+}
+
+func (l *EchoLogger) Fatalf(format string, args ...interface{}) {
+	l.log.Fatal().Msgf(format, args...)
+}
+
+func (l *EchoLogger) Fatalj(j log.JSON) {
+	l.log.Fatal().Fields(map[string]interface{}(j)).Send()
+}
+
+func (l *EchoLogger) Panic(i ...interface{}) {
+	l.log.Panic().Msg(fmt.Sprint(i...))
+}
+
+func (l *EchoLogger) Panicf(format string, args ...interface{}) {
+	l.log.Panic().Msgf(format, args...)
+}
+
+func (l *EchoLogger) Panicj(j log.JSON) {
+	l.log.Panic().Fields(map[string]interface{}(j)).Send()
+}
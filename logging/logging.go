@@ -0,0 +1,71 @@
+// Package logging builds the structured logger shiftr writes its logs through, which every line passes through
+// redacting the passwords and tokens it may hold before it is written
+package logging
+
+import (
+	"fmt"
+	"github.com/rs/zerolog"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Formats of the log lines
+const (
+	FormatJSON    = "json"    // a JSON object per line, for log collectors
+	FormatConsole = "console" // colored text lines, for reading in a terminal
+)
+
+// Config describes the logger to build, empty fields taking their default
+type Config struct {
+	Level  string    // least severe level logged, of trace, debug, info, warn and error. Default: info
+	Format string    // FormatJSON or FormatConsole. Default: FormatJSON
+	Output io.Writer // destination of the log lines. Default: os.Stderr
+}
+
+// defaultLogger is used by whatever was not given a logger of its own
+var defaultLogger = zerolog.New(Redact(os.Stderr)).With().Timestamp().Logger()
+
+// New returns a logger as configured, redacting secrets from every line it writes
+func New(config Config) (zerolog.Logger, error) {
+	level := zerolog.InfoLevel
+	if config.Level != "" {
+		var err error
+
+		level, err = zerolog.ParseLevel(strings.ToLower(config.Level))
+		if err != nil || level == zerolog.NoLevel {
+			return zerolog.Logger{}, fmt.Errorf("unknown log level %q", config.Level)
+		}
+	}
+
+	out := config.Output
+	if out == nil {
+		out = os.Stderr
+	}
+
+	switch strings.ToLower(config.Format) {
+	case "", FormatJSON:
+	case FormatConsole:
+		// The console writer formats the JSON lines once redacted, as keys are matched within JSON
+		out = zerolog.ConsoleWriter{Out: out, TimeFormat: time.RFC3339}
+	default:
+		return zerolog.Logger{}, fmt.Errorf("unknown log format %q", config.Format)
+	}
+
+	return zerolog.New(Redact(out)).Level(level).With().Timestamp().Logger(), nil
+}
+
+// Default returns the logger used by whatever was not given one, writing JSON lines to os.Stderr from the info level
+func Default() *zerolog.Logger {
+	return &defaultLogger
+}
+
+// Or returns the logger, or the default logger when it is nil
+func Or(l *zerolog.Logger) *zerolog.Logger {
+	if l == nil {
+		return &defaultLogger
+	}
+
+	return l
+}
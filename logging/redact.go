@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"io"
+	"regexp"
+)
+
+// redacted replaces the secrets found in log lines
+const redacted = "[REDACTED]"
+
+// redactions match the secrets log lines may hold, replaced by the groups before them and redacted
+var redactions = []*regexp.Regexp{
+	// JSON fields named after a secret, such as "password" or "reset_token"
+	regexp.MustCompile(`(?i)("[^"]*(?:password|passwd|secret|token|authorization|api_?key)[^"]*"\s*:\s*")(?:[^"\\]|\\.)*`),
+	// Query and form parameters, such as ?token= in a URI or a link sent in a notification
+	regexp.MustCompile(`(?i)(\b(?:pass|password|passwd|token|secret|api_?key|sig|signature)=)[^&\s"\\]+`),
+	// Go values printed with their field names, such as a configuration printed with %+v
+	regexp.MustCompile(`(?i)(\b\w*(?:pass|secret|token)\w*:)[^\s,}\]"\\]+`),
+	// Credentials of Authorization headers
+	regexp.MustCompile(`(?i)(\b(?:bearer|basic)\s+)[A-Za-z0-9\-_.~+/]+=*`),
+	// Tokens of calendar feed URLs, which are the only credential of the feed, such as /calendar/<token>.ics
+	regexp.MustCompile(`(/calendar/)[^/?#.\s"\\]+`),
+	// JSON web tokens wherever they appear
+	regexp.MustCompile(`()\beyJ[A-Za-z0-9\-_]*\.[A-Za-z0-9\-_]*\.[A-Za-z0-9\-_]*`),
+}
+
+// redactWriter writes log lines with the secrets they hold redacted
+type redactWriter struct {
+	out io.Writer
+}
+
+// Redact returns a writer writing to out with the passwords, tokens and other secrets of each write redacted.
+// Loggers built by New redact through it already, loggers built otherwise may write through it to do the same.
+func Redact(out io.Writer) io.Writer {
+	return redactWriter{out: out}
+}
+
+// Write redacts the line before writing it, reporting the whole line as written
+func (w redactWriter) Write(p []byte) (int, error) {
+	line := p
+	for _, re := range redactions {
+		line = re.ReplaceAll(line, []byte("${1}"+redacted))
+	}
+
+	_, err := w.out.Write(line)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
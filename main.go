@@ -1,28 +1,55 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/logging"
 	"github.com/btnmasher/shiftr/server"
-	"log"
+	"os"
+	"os/signal"
 	"time"
 )
 
 func main() {
+	allowDestructive := flag.Bool("allow-destructive", false, "allow database migrations which lose data")
+	basePath := flag.String("base-path", "", "path prefix to serve every route under, e.g. /shiftr")
+	publicURL := flag.String("public-url", "", "URL clients reach the server at when behind a reverse proxy")
+	grpcPort := flag.Int("grpc-port", 0, "port to serve the gRPC API on, none when 0")
+	logLevel := flag.String("log-level", "", "least severe level logged, of trace, debug, info, warn and error")
+	messages := flag.String("messages", "", "directory of JSON message catalogs named after their locale, e.g. pt.json")
+	logFormat := flag.String("log-format", logging.FormatJSON, "format of the log lines, json or console")
+	flag.Parse()
+
 	cfg := server.NewConfig(
 		server.DatabaseDriver(server.SqliteMem),
 		server.DebugEnabled(true),
+		server.AllowDestructiveMigrations(*allowDestructive),
+		server.WithBasePath(*basePath),
+		server.WithPublicURL(*publicURL),
+		server.ListenGRPCPort(*grpcPort),
+		server.WithLogLevel(*logLevel),
+		server.WithLogFormat(*logFormat),
+		server.WithMessageCatalogs(*messages),
 	)
 
 	srv := server.New()
 
 	err := srv.Initialize(cfg)
 	if err != nil {
-		log.Fatalln(err)
+		logging.Or(srv.Log).Fatal().Err(err).Msg("could not initialize the server")
 	}
 
 	setupDemoData(srv)
 
-	srv.Run()
+	// Shut down gracefully when interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err = srv.Run(ctx)
+	if err != nil {
+		srv.Log.Fatal().Err(err).Msg("server stopped")
+	}
 }
 
 func setupDemoData(srv *server.Server) {
@@ -0,0 +1,315 @@
+// Package migrate reads the shift exports of other scheduling tools so they can be
+// mapped onto shiftr users and shifts when switching over.
+package migrate
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Record is a single shift read from an export, identified by the worker's name and/or email
+type Record struct {
+	Row      int    // 1-based line number in the export, counting the header
+	Name     string // full name of the worker
+	Email    string
+	Position string
+	Location string
+	Start    time.Time
+	End      time.Time
+}
+
+// RowError describes an export row which could not be read
+type RowError struct {
+	Row int    `json:"row"`
+	Err string `json:"error"`
+}
+
+// field identifies a column a Format knows how to read
+type field int
+
+const (
+	fieldName field = iota
+	fieldFirstName
+	fieldLastName
+	fieldEmail
+	fieldPosition
+	fieldLocation
+	fieldDate
+	fieldStart
+	fieldEndDate
+	fieldEnd
+)
+
+// Format describes the CSV layout of another tool's shift export as the header names of
+// each column, matched case-insensitively with alternatives for the variants seen in the wild
+type Format struct {
+	Name    string
+	Tool    string
+	columns map[field][]string
+}
+
+var formats = map[string]*Format{
+	"wheniwork": {
+		Name: "wheniwork",
+		Tool: "When I Work",
+		columns: map[field][]string{
+			fieldName:      {"employee", "name", "user"},
+			fieldFirstName: {"first name", "first"},
+			fieldLastName:  {"last name", "last"},
+			fieldEmail:     {"email", "email address"},
+			fieldPosition:  {"position"},
+			fieldLocation:  {"location", "schedule"},
+			fieldDate:      {"start date", "date"},
+			fieldStart:     {"start time", "start"},
+			fieldEndDate:   {"end date"},
+			fieldEnd:       {"end time", "end"},
+		},
+	},
+	"deputy": {
+		Name: "deputy",
+		Tool: "Deputy",
+		columns: map[field][]string{
+			fieldName:      {"employee", "employee name", "name"},
+			fieldFirstName: {"first name"},
+			fieldLastName:  {"last name"},
+			fieldEmail:     {"email", "employee email"},
+			fieldPosition:  {"area", "operational unit", "position"},
+			fieldLocation:  {"location", "workplace"},
+			fieldDate:      {"date", "roster date"},
+			fieldStart:     {"start", "start time"},
+			fieldEndDate:   {"end date"},
+			fieldEnd:       {"end", "end time", "finish", "finish time"},
+		},
+	},
+	"homebase": {
+		Name: "homebase",
+		Tool: "Homebase",
+		columns: map[field][]string{
+			fieldName:      {"employee", "name", "team member"},
+			fieldFirstName: {"first name"},
+			fieldLastName:  {"last name"},
+			fieldEmail:     {"email"},
+			fieldPosition:  {"role", "job", "department"},
+			fieldLocation:  {"location"},
+			fieldDate:      {"date", "shift date"},
+			fieldStart:     {"start time", "start", "shift start"},
+			fieldEndDate:   {"end date"},
+			fieldEnd:       {"end time", "end", "shift end"},
+		},
+	},
+}
+
+var ErrUnknownFormat = errors.New("migrate: unknown export format")
+
+// Lookup returns the Format with the specified name
+func Lookup(name string) (*Format, error) {
+	f, ok := formats[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w %q, supported formats are %s", ErrUnknownFormat, name, strings.Join(Formats(), ", "))
+	}
+
+	return f, nil
+}
+
+// Formats returns the names of the supported formats
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Parse reads the shifts from an export in this format. Times without a zone are read in loc.
+// Rows which cannot be read are returned as RowErrors rather than failing the whole export.
+func (f *Format) Parse(r io.Reader, loc *time.Location) ([]Record, []RowError, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("migrate: could not read header: %s", err)
+	}
+
+	cols := f.index(header)
+
+	if cols[fieldName] < 0 && cols[fieldFirstName] < 0 && cols[fieldEmail] < 0 {
+		return nil, nil, fmt.Errorf("migrate: %s export has no employee name or email column", f.Tool)
+	}
+
+	if cols[fieldStart] < 0 || cols[fieldEnd] < 0 {
+		return nil, nil, fmt.Errorf("migrate: %s export has no start and end columns", f.Tool)
+	}
+
+	var (
+		records []Record
+		errs    []RowError
+	)
+
+	for row := 2; ; row++ {
+		line, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			var perr *csv.ParseError
+			if errors.As(err, &perr) {
+				errs = append(errs, RowError{Row: row, Err: perr.Err.Error()})
+				continue
+			}
+
+			return nil, nil, err
+		}
+
+		get := func(fd field) string {
+			if i := cols[fd]; i >= 0 && i < len(line) {
+				return strings.TrimSpace(line[i])
+			}
+			return ""
+		}
+
+		rec := Record{
+			Row:      row,
+			Name:     get(fieldName),
+			Email:    get(fieldEmail),
+			Position: get(fieldPosition),
+			Location: get(fieldLocation),
+		}
+
+		if rec.Name == "" {
+			rec.Name = strings.TrimSpace(get(fieldFirstName) + " " + get(fieldLastName))
+		}
+
+		// Skip blank lines and summary rows which name nobody
+		if rec.Name == "" && rec.Email == "" {
+			continue
+		}
+
+		endDate := get(fieldEndDate)
+		if endDate == "" {
+			endDate = get(fieldDate)
+		}
+
+		rec.Start, err = parseTime(get(fieldDate), get(fieldStart), loc)
+		if err == nil {
+			rec.End, err = parseTime(endDate, get(fieldEnd), loc)
+		}
+
+		if err != nil {
+			errs = append(errs, RowError{Row: row, Err: err.Error()})
+			continue
+		}
+
+		// Overnight shifts exported with only a start date end on the following day
+		if !rec.End.After(rec.Start) && get(fieldEndDate) == "" {
+			rec.End = rec.End.AddDate(0, 0, 1)
+		}
+
+		records = append(records, rec)
+	}
+
+	return records, errs, nil
+}
+
+// index finds the position of each known column in the header, -1 when absent
+func (f *Format) index(header []string) map[field]int {
+	cols := make(map[field]int, len(f.columns))
+
+	for fd, names := range f.columns {
+		cols[fd] = -1
+
+	search:
+		for _, name := range names {
+			for i, h := range header {
+				if strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")), name) {
+					cols[fd] = i
+					break search
+				}
+			}
+		}
+	}
+
+	return cols
+}
+
+var (
+	dateTimeLayouts = []string{
+		time.RFC3339,
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04",
+		"01/02/2006 15:04",
+		"01/02/2006 3:04 PM",
+		"01/02/2006 3:04PM",
+		"1/2/2006 15:04",
+		"1/2/2006 3:04 PM",
+		"1/2/2006 3:04PM",
+		"Jan 2, 2006 3:04 PM",
+		"Mon, Jan 2, 2006 3:04 PM",
+	}
+
+	dateLayouts = []string{
+		"2006-01-02",
+		"01/02/2006",
+		"1/2/2006",
+		"Jan 2, 2006",
+		"Mon, Jan 2, 2006",
+		"Mon Jan 2, 2006",
+	}
+
+	timeLayouts = []string{
+		"15:04",
+		"15:04:05",
+		"3:04 PM",
+		"3:04PM",
+		"3:04:05 PM",
+		"3 PM",
+		"3PM",
+	}
+)
+
+// parseTime reads a timestamp from a combined date and time value, or from separate date and time values
+func parseTime(date, clock string, loc *time.Location) (time.Time, error) {
+	value := strings.ToUpper(clock)
+
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	if date == "" {
+		return time.Time{}, fmt.Errorf("unrecognized date and time %q", clock)
+	}
+
+	var day time.Time
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, date, loc); err == nil {
+			day = t
+			break
+		}
+	}
+
+	if day.IsZero() {
+		return time.Time{}, fmt.Errorf("unrecognized date %q", date)
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized time %q", clock)
+}
@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btnmasher/shiftr/i18n"
+	"github.com/btnmasher/shiftr/logging"
+	"github.com/rs/zerolog"
+)
+
+// DefaultImmediate lists the events a Digest delivers at once unless configured otherwise,
+// as their recipients are waiting on them or must learn of them without delay
+var DefaultImmediate = []string{EventVerification, EventEmailChanged, EventInvitation}
+
+// Digest is a Notifier which coalesces the messages sent to each recipient within a window into a single
+// digest, so that a manager editing many shifts at once does not flood their workers with notifications.
+// The window starts with the first message a recipient is sent. Urgent messages, and messages for the
+// events listed as immediate, are passed on at once.
+type Digest struct {
+	Notifier  Notifier
+	Window    time.Duration
+	Immediate map[string]bool
+	Catalog   *i18n.Catalog   // subjects are translated with, nil uses the bundled translations
+	Log       *zerolog.Logger // of failed deliveries, nil logs to logging.Default()
+
+	mu      sync.Mutex
+	pending map[string]*digestBatch
+}
+
+// digestBatch holds the messages waiting to be sent to a recipient
+type digestBatch struct {
+	messages []Message
+	timer    *time.Timer
+}
+
+// NewDigest returns a Digest delivering through the Notifier, passing on messages for the immediate
+// events at once. DefaultImmediate is used when none are given.
+func NewDigest(n Notifier, window time.Duration, immediate ...string) *Digest {
+	if len(immediate) == 0 {
+		immediate = DefaultImmediate
+	}
+
+	d := &Digest{
+		Notifier:  n,
+		Window:    window,
+		Immediate: make(map[string]bool, len(immediate)),
+		pending:   make(map[string]*digestBatch),
+	}
+
+	for _, event := range immediate {
+		d.Immediate[event] = true
+	}
+
+	return d
+}
+
+// Notify queues the message for the recipient's next digest, or sends it at once when it is urgent or immediate
+func (d *Digest) Notify(ctx context.Context, msg Message) error {
+	if msg.Urgent || d.Immediate[msg.Event] || d.Window <= 0 {
+		return d.Notifier.Notify(ctx, msg)
+	}
+
+	key := msg.UserID + "\x00" + msg.To
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	batch, ok := d.pending[key]
+	if !ok {
+		batch = &digestBatch{}
+		batch.timer = time.AfterFunc(d.Window, func() {
+			d.send(key)
+		})
+
+		d.pending[key] = batch
+	}
+
+	batch.messages = append(batch.messages, msg)
+
+	return nil
+}
+
+// Flush sends every pending digest at once, such as when shutting down
+func (d *Digest) Flush() {
+	d.mu.Lock()
+	keys := make([]string, 0, len(d.pending))
+	for key, batch := range d.pending {
+		batch.timer.Stop()
+		keys = append(keys, key)
+	}
+	d.mu.Unlock()
+
+	for _, key := range keys {
+		d.send(key)
+	}
+}
+
+// send delivers the digest pending for the recipient. The request which queued the messages has
+// long finished by then, so delivery failures are logged.
+func (d *Digest) send(key string) {
+	d.mu.Lock()
+	batch, ok := d.pending[key]
+	delete(d.pending, key)
+	d.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	err := d.Notifier.Notify(context.Background(), d.digestMessage(batch.messages))
+	if err != nil {
+		logging.Or(d.Log).Error().Err(err).Msg("notification digest")
+	}
+}
+
+// digestMessage combines the messages to a recipient into one, a single message is sent as it is
+func (d *Digest) digestMessage(messages []Message) Message {
+	if len(messages) == 1 {
+		return messages[0]
+	}
+
+	first := messages[0]
+
+	var body strings.Builder
+	for i, msg := range messages {
+		if i > 0 {
+			body.WriteString("\n\n")
+		}
+
+		fmt.Fprintf(&body, "%s\n%s", msg.Subject, msg.Body)
+	}
+
+	return Message{
+		UserID:  first.UserID,
+		To:      first.To,
+		Subject: d.Catalog.Sprintf(first.Locale, "%d shiftr notifications", len(messages)),
+		Body:    body.String(),
+		Event:   EventDigest,
+		Locale:  first.Locale,
+	}
+}
@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"context"
+	"github.com/btnmasher/shiftr/logging"
+	"github.com/rs/zerolog"
+)
+
+// The events notifications are sent for, identifying the kind of a Message
+const (
+	EventShiftChanged      = "shift_changed"
+	EventShiftApproval     = "shift_approval"
+	EventShiftReviewed     = "shift_reviewed"
+	EventSchedulePublished = "schedule_published"
+	EventShiftReminder     = "shift_reminder"
+	EventShiftAwarded      = "shift_awarded"
+	EventWaitlistPromoted  = "waitlist_promoted"
+	EventHandover          = "handover"
+	EventTimeOffReviewed   = "time_off_reviewed"
+	EventTimeOffApproval   = "time_off_approval"
+	EventVerification      = "verification"
+	EventEmailChanged      = "email_changed"
+	EventInvitation        = "invitation"
+	EventBroadcast         = "broadcast"
+	EventDigest            = "digest"
+)
+
+// Message represents a notification addressed to a single recipient
+type Message struct {
+	UserID  string // recipient User.ID, if the recipient is a registered user
+	To      string // recipient address, such as an email address
+	Subject string
+	Body    string
+	Event   string // the event the notification is for, such as EventShiftChanged
+	Urgent  bool   // set for emergency broadcasts, which channels may deliver with higher priority
+	Locale  string // the recipient's preferred locale, such as "de", when known
+}
+
+// Notifier is implemented by any delivery mechanism capable of sending a Message
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// NotifierFunc adapts an ordinary function to the Notifier interface
+type NotifierFunc func(ctx context.Context, msg Message) error
+
+// Notify calls f(ctx, msg)
+func (f NotifierFunc) Notify(ctx context.Context, msg Message) error {
+	return f(ctx, msg)
+}
+
+// LogNotifier writes notifications to a logger instead of delivering them,
+// it is used when no other Notifier has been configured
+type LogNotifier struct {
+	Log *zerolog.Logger // nil logs to logging.Default()
+}
+
+// Notify logs the message
+func (n LogNotifier) Notify(_ context.Context, msg Message) error {
+	logging.Or(n.Log).Info().
+		Str("user_id", msg.UserID).
+		Str("to", msg.To).
+		Str("event", msg.Event).
+		Str("subject", msg.Subject).
+		Str("body", msg.Body).
+		Msg("notification")
+
+	return nil
+}
+
+// Channel is a named Notifier, such as email or SMS, used when a message must go out by every available means
+type Channel struct {
+	Name     string
+	Notifier Notifier
+}
+
+// Delivery is the outcome of sending a message on a Channel, Err is nil if it was sent
+type Delivery struct {
+	Channel string
+	Err     error
+}
+
+// Broadcast sends the message on every channel, returning the outcome on each
+func Broadcast(ctx context.Context, channels []Channel, msg Message) []Delivery {
+	deliveries := make([]Delivery, 0, len(channels))
+
+	for _, ch := range channels {
+		deliveries = append(deliveries, Delivery{
+			Channel: ch.Name,
+			Err:     ch.Notifier.Notify(ctx, msg),
+		})
+	}
+
+	return deliveries
+}
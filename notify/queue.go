@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btnmasher/shiftr/health"
+	"github.com/btnmasher/shiftr/logging"
+	"github.com/rs/zerolog"
+)
+
+// Defaults used for a Queue unless configured otherwise
+const (
+	DefaultQueueLimit   = 1000             // messages held during an outage before dropping the oldest
+	DefaultQueueTimeout = 10 * time.Second // of each delivery
+)
+
+// Queue is a Notifier delivering through another behind a circuit breaker. While the breaker is open, or when
+// a delivery fails, the message is held and retried in the background once the breaker allows it, so an outage
+// of the delivery mechanism delays notifications rather than slowing or failing the requests sending them.
+// Messages held beyond the Limit are dropped oldest first.
+type Queue struct {
+	Notifier Notifier
+	Breaker  *health.Breaker
+	Limit    int
+	Timeout  time.Duration   // of each delivery, unlimited when zero
+	Log      *zerolog.Logger // of retries and dropped messages, nil logs to logging.Default()
+
+	mu   sync.Mutex
+	held []Message
+}
+
+// NewQueue returns a Queue delivering through the Notifier behind the breaker within DefaultQueueTimeout,
+// reporting the number of messages held in the breaker's status
+func NewQueue(n Notifier, b *health.Breaker, limit int) *Queue {
+	if limit < 1 {
+		limit = DefaultQueueLimit
+	}
+
+	q := &Queue{
+		Notifier: n,
+		Breaker:  b,
+		Limit:    limit,
+		Timeout:  DefaultQueueTimeout,
+	}
+
+	b.Detail = func() string {
+		if held := q.Held(); held > 0 {
+			return fmt.Sprintf("notifications queued: %d", held)
+		}
+
+		return ""
+	}
+
+	return q
+}
+
+// Notify delivers the message, or holds it for later while the breaker is open or delivery fails
+func (q *Queue) Notify(ctx context.Context, msg Message) error {
+	// Messages already waiting go out first once deliveries resume
+	if q.Held() > 0 {
+		q.hold(msg)
+		return nil
+	}
+
+	err := q.deliver(ctx, msg)
+	if err != nil {
+		logging.Or(q.Log).Warn().Err(err).Msg("notification queued for retry")
+		q.hold(msg)
+	}
+
+	return nil
+}
+
+// Held returns the number of messages waiting to be delivered
+func (q *Queue) Held() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.held)
+}
+
+// Run retries the held messages whenever the breaker allows a delivery, until the context is cancelled
+func (q *Queue) Run(ctx context.Context) {
+	ticker := time.NewTicker(q.Breaker.Cooldown)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if held := q.Held(); held > 0 {
+				logging.Or(q.Log).Warn().Int("held", held).Msg("queued notifications were not delivered before shutdown")
+			}
+			return
+		case <-ticker.C:
+			q.retry(ctx)
+		}
+	}
+}
+
+// retry delivers the held messages in order, stopping at the first which cannot be delivered
+func (q *Queue) retry(ctx context.Context) {
+	for {
+		q.mu.Lock()
+		if len(q.held) == 0 {
+			q.mu.Unlock()
+			return
+		}
+		msg := q.held[0]
+		q.mu.Unlock()
+
+		if q.deliver(ctx, msg) != nil {
+			return
+		}
+
+		q.mu.Lock()
+		q.held = q.held[1:]
+		q.mu.Unlock()
+	}
+}
+
+// deliver sends the message through the breaker
+func (q *Queue) deliver(ctx context.Context, msg Message) error {
+	return q.Breaker.Do(func() error {
+		if q.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, q.Timeout)
+			defer cancel()
+		}
+
+		return q.Notifier.Notify(ctx, msg)
+	})
+}
+
+// hold keeps the message for a later retry, dropping the oldest when the queue is full
+func (q *Queue) hold(msg Message) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.held) >= q.Limit {
+		logging.Or(q.Log).Error().Str("user_id", q.held[0].UserID).Str("to", q.held[0].To).Msg("notification queue full, dropping the oldest notification")
+		q.held = q.held[1:]
+	}
+
+	q.held = append(q.held, msg)
+}
@@ -0,0 +1,168 @@
+package opaque
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"sync"
+)
+
+// Kind identifies the type of object an ID belongs to, so that the external ID of one kind
+// of object cannot be used in place of another
+type Kind byte
+
+const (
+	User  Kind = 'u'
+	Shift Kind = 's'
+)
+
+// maxID is the longest internal ID which fits in a single block alongside its kind and length
+const maxID = aes.BlockSize - 2
+
+var (
+	ErrTooLong   = errors.New("id too long to encode")
+	ErrMalformed = errors.New("malformed external id")
+)
+
+// Codec maps internal IDs to opaque external IDs and back by encrypting them as a single AES block.
+// The mapping is deterministic, so an object keeps the same external ID for as long as the key
+// is unchanged, and values which were not produced with the key are rejected on decode.
+type Codec struct {
+	block cipher.Block
+}
+
+// NewCodec returns a Codec for the AES key (16, 24, or 32 bytes)
+func NewCodec(key []byte) (*Codec, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Codec{block: block}, nil
+}
+
+// Encode returns the external ID for the internal ID of an object of the kind
+func (c *Codec) Encode(kind Kind, id string) (string, error) {
+	if len(id) > maxID {
+		return "", ErrTooLong
+	}
+
+	// kind | length | id | zero padding
+	plain := make([]byte, aes.BlockSize)
+	plain[0] = byte(kind)
+	plain[1] = byte(len(id))
+	copy(plain[2:], id)
+
+	sealed := make([]byte, aes.BlockSize)
+	c.block.Encrypt(sealed, plain)
+
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decode returns the internal ID of the external ID, which must have been encoded for the same kind
+func (c *Codec) Decode(kind Kind, ext string) (string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(ext)
+	if err != nil || len(sealed) != aes.BlockSize {
+		return "", ErrMalformed
+	}
+
+	plain := make([]byte, aes.BlockSize)
+	c.block.Decrypt(plain, sealed)
+
+	n := int(plain[1])
+	if plain[0] != byte(kind) || n > maxID {
+		return "", ErrMalformed
+	}
+
+	for _, b := range plain[2+n:] {
+		if b != 0 {
+			return "", ErrMalformed
+		}
+	}
+
+	return string(plain[2 : 2+n]), nil
+}
+
+// ErrCodecInUse is returned by Register while another Codec is in use
+var ErrCodecInUse = errors.New("another external id codec is in use")
+
+var (
+	mu      sync.RWMutex
+	current *Codec
+	users   int // of the current Codec, which is released once none are left
+)
+
+// Register sets the Codec used by Encode and Decode until the returned release is called, nil exposing internal IDs
+// unchanged. Values encode their IDs without knowing which server sends them, so every server in a process must use
+// the same Codec: ErrCodecInUse is returned while another one is in use.
+func Register(c *Codec) (release func(), err error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if users > 0 && !c.same(current) {
+		return nil, ErrCodecInUse
+	}
+
+	current = c
+	users++
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			mu.Lock()
+			defer mu.Unlock()
+
+			users--
+			if users == 0 {
+				current = nil
+			}
+		})
+	}, nil
+}
+
+// CurrentCodec returns the Codec used by Encode and Decode, if one is registered
+func CurrentCodec() *Codec {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return current
+}
+
+// same reports whether the Codecs map IDs alike, both being nil or sharing their key
+func (c *Codec) same(other *Codec) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+
+	probe := make([]byte, aes.BlockSize)
+	a, b := make([]byte, aes.BlockSize), make([]byte, aes.BlockSize)
+	c.block.Encrypt(a, probe)
+	other.block.Encrypt(b, probe)
+
+	return bytes.Equal(a, b)
+}
+
+// Encode returns the external ID for the internal ID with the current Codec. Empty IDs stay empty
+// and IDs are returned unchanged when no Codec is registered.
+func Encode(kind Kind, id string) (string, error) {
+	c := CurrentCodec()
+	if c == nil || id == "" {
+		return id, nil
+	}
+
+	return c.Encode(kind, id)
+}
+
+// Decode returns the internal ID for the external ID with the current Codec. Empty IDs stay empty
+// and IDs are returned unchanged when no Codec is registered.
+func Decode(kind Kind, ext string) (string, error) {
+	c := CurrentCodec()
+	if c == nil || ext == "" {
+		return ext, nil
+	}
+
+	return c.Decode(kind, ext)
+}
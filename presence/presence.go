@@ -0,0 +1,137 @@
+package presence
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Viewer describes a user who has a schedule open
+type Viewer struct {
+	UserID string    `json:"user_id"`
+	Name   string    `json:"name"`
+	Since  time.Time `json:"since"`
+}
+
+// Update lists everyone viewing the schedule of a week, sent to its viewers whenever someone opens or closes it
+type Update struct {
+	Week    string   `json:"week"`
+	Viewers []Viewer `json:"viewers"`
+}
+
+// Hub tracks who is viewing the schedule of each week, so that concurrent editors learn of each other before
+// their changes collide. Presence is held in memory and is not shared between instances of the server.
+type Hub struct {
+	mu    sync.Mutex
+	weeks map[string]map[*Session]bool
+}
+
+// Session is a single viewing of the schedule of a week, such as a browser tab. A user may hold several at once.
+type Session struct {
+	hub     *Hub
+	week    string
+	viewer  Viewer
+	updates chan Update
+}
+
+// NewHub returns a Hub with no viewers
+func NewHub() *Hub {
+	return &Hub{weeks: make(map[string]map[*Session]bool)}
+}
+
+// Join records the viewer as viewing the schedule of the week and lets everyone viewing it know
+func (h *Hub) Join(week string, viewer Viewer) *Session {
+	s := &Session{
+		hub:     h,
+		week:    week,
+		viewer:  viewer,
+		updates: make(chan Update, 1),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.weeks[week] == nil {
+		h.weeks[week] = make(map[*Session]bool)
+	}
+
+	h.weeks[week][s] = true
+	h.publish(week)
+
+	return s
+}
+
+// Viewers returns everyone viewing the schedule of the week, listing each user once in the order they opened it
+func (h *Hub) Viewers(week string) []Viewer {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.viewers(week)
+}
+
+// viewers must be called with the lock held
+func (h *Hub) viewers(week string) []Viewer {
+	earliest := make(map[string]Viewer)
+	for s := range h.weeks[week] {
+		v, ok := earliest[s.viewer.UserID]
+		if !ok || s.viewer.Since.Before(v.Since) {
+			earliest[s.viewer.UserID] = s.viewer
+		}
+	}
+
+	viewers := make([]Viewer, 0, len(earliest))
+	for _, v := range earliest {
+		viewers = append(viewers, v)
+	}
+
+	sort.Slice(viewers, func(i, j int) bool {
+		if !viewers[i].Since.Equal(viewers[j].Since) {
+			return viewers[i].Since.Before(viewers[j].Since)
+		}
+
+		return viewers[i].UserID < viewers[j].UserID
+	})
+
+	return viewers
+}
+
+// publish sends the viewers of the week to each of its sessions, replacing any update they have yet to receive
+// as only the latest matters. It must be called with the lock held.
+func (h *Hub) publish(week string) {
+	update := Update{Week: week, Viewers: h.viewers(week)}
+
+	for s := range h.weeks[week] {
+		select {
+		case <-s.updates:
+		default:
+		}
+
+		s.updates <- update
+	}
+}
+
+// Updates returns the channel the viewers of the week are sent on whenever they change
+func (s *Session) Updates() <-chan Update {
+	return s.updates
+}
+
+// Leave records the session as no longer viewing the schedule and lets everyone still viewing it know
+func (s *Session) Leave() {
+	h := s.hub
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sessions, ok := h.weeks[s.week]
+	if !ok || !sessions[s] {
+		return
+	}
+
+	delete(sessions, s)
+	if len(sessions) == 0 {
+		delete(h.weeks, s.week)
+		return
+	}
+
+	h.publish(s.week)
+}
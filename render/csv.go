@@ -0,0 +1,109 @@
+package render
+
+import (
+	"encoding/json"
+	"github.com/btnmasher/shiftr/export"
+	"io"
+)
+
+// CSV renders a list as comma separated values with a header row, one row per item. The members of nested objects
+// become columns of their own named by their path, such as holiday.name, and lists are written as their JSON.
+type CSV struct{}
+
+func (CSV) Render(w io.Writer, items []interface{}) error {
+	var columns []string
+	index := map[string]int{}
+
+	rows := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		row := map[string]interface{}{}
+		err := flatten(row, "", item, func(name string) {
+			if _, ok := index[name]; !ok {
+				index[name] = len(columns)
+				columns = append(columns, name)
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		rows[i] = row
+	}
+
+	sheet := export.Sheet{Rows: make([][]interface{}, 0, len(rows)+1)}
+
+	header := make([]interface{}, len(columns))
+	for i, name := range columns {
+		header[i] = name
+	}
+	sheet.Rows = append(sheet.Rows, header)
+
+	for _, row := range rows {
+		cells := make([]interface{}, len(columns))
+		for name, value := range row {
+			cells[index[name]] = value
+		}
+
+		sheet.Rows = append(sheet.Rows, cells)
+	}
+
+	return export.WriteCSV(w, sheet)
+}
+
+// flatten sets the cells of the value in the row, naming each column it sets with column
+func flatten(row map[string]interface{}, prefix string, value interface{}, column func(string)) error {
+	switch v := value.(type) {
+	case Object:
+		for _, f := range v {
+			name := f.Name
+			if prefix != "" {
+				name = prefix + "." + f.Name
+			}
+
+			err := flatten(row, name, f.Value, column)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case []interface{}:
+		data, err := json.Marshal(plain(v))
+		if err != nil {
+			return err
+		}
+
+		value = string(data)
+	}
+
+	if prefix == "" {
+		prefix = "value"
+	}
+
+	column(prefix)
+	row[prefix] = value
+
+	return nil
+}
+
+// plain returns the decoded value with its objects as maps, to be encoded as JSON again
+func plain(value interface{}) interface{} {
+	switch v := value.(type) {
+	case Object:
+		m := make(map[string]interface{}, len(v))
+		for _, f := range v {
+			m[f.Name] = plain(f.Value)
+		}
+
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = plain(item)
+		}
+
+		return list
+	}
+
+	return value
+}
@@ -0,0 +1,113 @@
+// Package render writes the lists answered by the API in formats other than JSON. A Renderer is given the
+// items of a list as decoded from the JSON response, so every format holds exactly what the JSON would.
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Renderer writes the items of a list in a format other than JSON
+type Renderer interface {
+	// Render writes the items, each as decoded by Decode, to w
+	Render(w io.Writer, items []interface{}) error
+}
+
+// Field is a member of a decoded JSON object
+type Field struct {
+	Name  string
+	Value interface{}
+}
+
+// Object is a decoded JSON object, holding its members in the order they were encoded
+type Object []Field
+
+// Decode decodes JSON into values of nil, bool, json.Number, string, []interface{} and Object, keeping the
+// members of objects in order so that formats may follow the order of the JSON
+func Decode(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	return decodeValue(dec)
+}
+
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		obj := Object{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			name, ok := key.(string)
+			if !ok {
+				return nil, errors.New("render: object key is not a string")
+			}
+
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			obj = append(obj, Field{Name: name, Value: value})
+		}
+
+		_, err = dec.Token()
+
+		return obj, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for dec.More() {
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			list = append(list, value)
+		}
+
+		_, err = dec.Token()
+
+		return list, err
+	}
+
+	return tok, nil
+}
+
+// Items returns the items of the list a decoded response holds, either as the response itself or as the only list
+// of an object such as a page of results. It reports false for responses which are not a list.
+func Items(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, true
+	case Object:
+		var items []interface{}
+		found := false
+
+		for _, f := range v {
+			list, ok := f.Value.([]interface{})
+			if !ok {
+				continue
+			}
+
+			if found {
+				return nil, false
+			}
+
+			items, found = list, true
+		}
+
+		return items, found
+	}
+
+	return nil, false
+}
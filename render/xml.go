@@ -0,0 +1,105 @@
+package render
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// XML renders a list as an items element holding an item element per item. The members of objects become
+// elements named after them, and the values of lists become item elements within the element of the list.
+type XML struct{}
+
+func (XML) Render(w io.Writer, items []interface{}) error {
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	err = encodeElement(enc, "items", items)
+	if err != nil {
+		return err
+	}
+
+	err = enc.Flush()
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+
+	return err
+}
+
+// encodeElement writes the value as an element of the name, leaving the element empty for null
+func encodeElement(enc *xml.Encoder, name string, value interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: elementName(name)}}
+
+	err := enc.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case Object:
+		for _, f := range v {
+			err = encodeElement(enc, f.Name, f.Value)
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			err = encodeElement(enc, "item", item)
+			if err != nil {
+				return err
+			}
+		}
+	case nil:
+	case string:
+		err = enc.EncodeToken(xml.CharData(v))
+	case json.Number:
+		err = enc.EncodeToken(xml.CharData(v.String()))
+	default:
+		err = enc.EncodeToken(xml.CharData(fmt.Sprint(v)))
+	}
+	if err != nil {
+		return err
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+// elementName returns the name as a valid XML element name, replacing the characters one cannot hold with _
+func elementName(name string) string {
+	valid := func(i int, r rune) bool {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			return true
+		case r >= '0' && r <= '9', r == '-', r == '.':
+			return i > 0
+		}
+
+		return false
+	}
+
+	var b strings.Builder
+	for i, r := range name {
+		if valid(i, r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+
+	if b.Len() == 0 {
+		return "_"
+	}
+
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/api/service"
+	"github.com/btnmasher/shiftr/health"
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+// httpCodes maps the statuses of the errors shared with the REST API to their gRPC codes
+var httpCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.AlreadyExists,
+	http.StatusPreconditionFailed:  codes.Aborted,
+	http.StatusUnprocessableEntity: codes.FailedPrecondition,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+}
+
+// statusError translates an error returned by the models and services, or by the middleware shared with the REST
+// API, into the gRPC status of its class. Unclassified errors are internal and their details are not exposed.
+func statusError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		code, ok := httpCodes[he.Code]
+		if !ok {
+			code = codes.Unknown
+		}
+
+		return status.Error(code, http.StatusText(he.Code))
+	}
+
+	var limit *models.ShiftLimitError
+	if errors.As(err, &limit) {
+		return status.Error(codes.FailedPrecondition, limit.Error())
+	}
+
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, models.ErrNotFound), errors.Is(err, gorm.ErrRecordNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, models.ErrInvalid):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, models.ErrOverlap):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, models.ErrStale):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, health.ErrUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
+
+// checkETag refuses a write to a version of an object other than the one read, given by its ETag
+func checkETag(given, current string) error {
+	if given == "" {
+		return status.Error(codes.FailedPrecondition, "etag required")
+	}
+
+	if given != "*" && given != current {
+		return status.Error(codes.Aborted, models.ErrStale.Error())
+	}
+
+	return nil
+}
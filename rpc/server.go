@@ -0,0 +1,145 @@
+// Package rpc serves the users and shifts of the schedule over gRPC, for internal services which prefer typed RPC
+// to JSON. The services are defined in shiftr.proto, from which shiftr.pb.go and shiftr_grpc.pb.go are generated:
+//
+//	protoc --go_out=paths=source_relative:. --go-grpc_out=paths=source_relative:. shiftr.proto
+//
+// Calls are made as the user whose JWT is given in the authorization metadata, as a bearer token like the REST API
+// takes, and are held to the same role rules as the REST API.
+package rpc
+
+//go:generate protoc --go_out=paths=source_relative:. --go-grpc_out=paths=source_relative:. shiftr.proto
+
+import (
+	"context"
+	"github.com/btnmasher/shiftr/api/middleware"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/api/service"
+	"github.com/btnmasher/shiftr/logging"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// reads are the methods which do not modify data, served in read-only mode and left out of the audit trail
+var reads = map[string]bool{
+	"/shiftr.UserService/GetUser":     true,
+	"/shiftr.UserService/ListUsers":   true,
+	"/shiftr.ShiftService/GetShift":   true,
+	"/shiftr.ShiftService/ListShifts": true,
+}
+
+// Server serves the UserService and ShiftService over the database, authenticating calls with the JWT keys
+type Server struct {
+	DB       *gorm.DB
+	Keys     *middleware.KeySet
+	Shifts   *service.Shifts // making the changes to shifts, as the REST API does
+	ReadOnly bool            // calls which would modify data are refused
+	Log      *zerolog.Logger // nil logs to logging.Default()
+}
+
+// GRPC returns a gRPC server with the services registered behind authentication
+func (s *Server) GRPC(opts ...grpc.ServerOption) *grpc.Server {
+	g := grpc.NewServer(append(opts, grpc.UnaryInterceptor(s.intercept))...)
+
+	RegisterUserServiceServer(g, &userService{Server: s})
+	RegisterShiftServiceServer(g, &shiftService{Server: s})
+
+	return g
+}
+
+// caller is the authenticated user making a call
+type caller struct {
+	id       string
+	role     string
+	subjects []string // users affected by the call, recorded in the audit trail
+}
+
+type callerKey struct{}
+
+// callerFrom returns the user making the call
+func callerFrom(ctx context.Context) *caller {
+	return ctx.Value(callerKey{}).(*caller)
+}
+
+// service returns the caller as the services see them
+func (c *caller) service() service.Caller {
+	return service.Caller{ID: c.id, Role: c.role}
+}
+
+// affects records the specified User.IDs as affected by the call, like middleware.AuditSubjects
+func affects(ctx context.Context, uids ...string) {
+	c := callerFrom(ctx)
+	c.subjects = append(c.subjects, uids...)
+}
+
+// logger returns the logger of the server adding the ID of the call the context serves to every line
+func (s *Server) logger(ctx context.Context) *zerolog.Logger {
+	l := logging.Or(s.Log).With().Str("request_id", middleware.RequestIDFrom(ctx)).Logger()
+	return &l
+}
+
+// intercept authenticates every call with the bearer token of its authorization metadata, refuses writes in
+// read-only mode and appends the writes which succeed to the audit trail
+func (s *Server) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	// Calls are identified as requests are, by the x-request-id they were sent with or a new ID answered to them
+	var given string
+	if values := md.Get("x-request-id"); len(values) > 0 {
+		given = values[0]
+	}
+
+	rid := middleware.ResolveRequestID(given)
+	ctx = middleware.WithRequestID(ctx, rid)
+
+	if err := grpc.SetHeader(ctx, metadata.Pairs("x-request-id", rid)); err != nil {
+		s.logger(ctx).Error().Err(err).Msg("rpc request id")
+	}
+
+	var token string
+	if values := md.Get("authorization"); len(values) > 0 {
+		token = strings.TrimPrefix(values[0], "Bearer ")
+	}
+
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+
+	id, role, err := middleware.Authenticate(s.DB, s.Keys, token)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	if s.ReadOnly && !reads[info.FullMethod] {
+		return nil, status.Error(codes.Unavailable, "server is in read-only mode")
+	}
+
+	c := &caller{id: id, role: role}
+	resp, err := handler(context.WithValue(ctx, callerKey{}, c), req)
+	if err != nil || reads[info.FullMethod] {
+		return resp, err
+	}
+
+	// gRPC calls are POSTs over HTTP/2, recorded under the method they called
+	entry := &models.AuditEntry{
+		At:       time.Now(),
+		UserID:   id,
+		Role:     role,
+		Method:   "POST",
+		Route:    info.FullMethod,
+		Path:     info.FullMethod,
+		Status:   200,
+		Subjects: c.subjects,
+	}
+
+	if aerr := models.AppendAudit(s.DB.WithContext(ctx), entry); aerr != nil {
+		s.logger(ctx).Error().Err(aerr).Msg("rpc audit")
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,372 @@
+package rpc
+
+import (
+	"context"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/api/service"
+	"github.com/btnmasher/shiftr/opaque"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// shiftService implements ShiftService
+type shiftService struct {
+	*Server
+	UnimplementedShiftServiceServer
+}
+
+func (s *shiftService) GetShift(ctx context.Context, req *GetShiftRequest) (*Shift, error) {
+	c := callerFrom(ctx)
+	db := s.DB.WithContext(ctx)
+
+	shift, err := s.find(db, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Constrain the user to published shifts of their own or of the users reporting to them, besides their own
+	// awaiting approval, if not admin
+	err = service.Visible(db, c.service(), shift)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return s.message(db, shift)
+}
+
+func (s *shiftService) ListShifts(ctx context.Context, req *ListShiftsRequest) (*ListShiftsResponse, error) {
+	c := callerFrom(ctx)
+	db := s.DB.WithContext(ctx)
+
+	uid, err := opaque.Decode(opaque.User, req.UserId)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user_id")
+	}
+
+	// Constrain the user to the published shifts of their own or of the users reporting to them, along with those
+	// awaiting approval, if not admin
+	var statuses []string
+	if c.role == "user" {
+		statuses = []string{models.ShiftPublished, models.ShiftPending}
+
+		if uid == "" {
+			uid = c.id
+		}
+
+		if uid != c.id {
+			manages, err := models.Manages(db, c.id, uid)
+			if err != nil {
+				return nil, statusError(err)
+			}
+
+			if !manages {
+				return nil, status.Error(codes.PermissionDenied, "permission denied")
+			}
+		}
+	}
+
+	// Requested statuses narrow those the user may see rather than widening them
+	for _, requested := range req.Status {
+		if statuses != nil && !contains(statuses, requested) {
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		}
+	}
+
+	start, end := timeOf(req.Start), timeOf(req.End)
+	if !start.IsZero() && !end.IsZero() && start.After(end) {
+		return nil, status.Error(codes.InvalidArgument, "filter span start time must precede span end time")
+	}
+
+	page, err := models.PageShifts(db, models.ShiftPageQuery{
+		Sort:    req.Sort,
+		Page:    int(req.Page),
+		PerPage: int(req.PerPage),
+		Cursor:  req.Cursor,
+	},
+		models.FilterUserID(uid),
+		models.FilterStart(start),
+		models.FilterEnd(end),
+		models.FilterStatus(statuses...),
+		models.FilterStatus(req.Status...),
+	)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	// Annotate the shifts with any holidays they fall on
+	err = models.AnnotateShifts(db, page.Shifts)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resp := &ListShiftsResponse{Total: page.Total, NextCursor: page.NextCursor}
+	for _, shift := range page.Shifts {
+		msg, err := shiftMessage(shift)
+		if err != nil {
+			return nil, statusError(err)
+		}
+
+		resp.Shifts = append(resp.Shifts, msg)
+	}
+
+	return resp, nil
+}
+
+func (s *shiftService) CreateShift(ctx context.Context, req *CreateShiftRequest) (*Shift, error) {
+	c := callerFrom(ctx)
+	db := s.DB.WithContext(ctx)
+
+	data, err := shiftModel(req.Shift)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prepare a new object to write to the database
+	shift := models.Shift{
+		UserID:     data.UserID,
+		Start:      data.Start,
+		End:        data.End,
+		Capacity:   data.Capacity,
+		Status:     data.Status,
+		LocationID: data.LocationID,
+		PositionID: data.PositionID,
+		Color:      data.Color,
+		Visibility: data.Visibility,
+		Metadata:   data.Metadata,
+		Tags:       data.Tags,
+	}
+
+	// Attempt to write the new object to the database once the user is found to be allowed to
+	affected, err := s.Shifts.Create(ctx, db, c.service(), &shift)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	affects(ctx, affected...)
+
+	return s.message(db, &shift)
+}
+
+func (s *shiftService) UpdateShift(ctx context.Context, req *UpdateShiftRequest) (*Shift, error) {
+	c := callerFrom(ctx)
+	db := s.DB.WithContext(ctx)
+
+	data, err := shiftModel(req.Shift)
+	if err != nil {
+		return nil, err
+	}
+
+	shift, err := s.find(db, req.GetShift().GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	// Fields left unset keep their values
+	change := *shift
+
+	if data.UserID != "" {
+		change.UserID = data.UserID
+	}
+
+	if !data.Start.IsZero() {
+		change.Start = data.Start
+	}
+
+	if !data.End.IsZero() {
+		change.End = data.End
+	}
+
+	if data.Capacity != 0 {
+		change.Capacity = data.Capacity
+	}
+
+	if data.Status != "" {
+		change.Status = data.Status
+	}
+
+	if data.LocationID != "" {
+		change.LocationID = data.LocationID
+	}
+
+	if data.PositionID != "" {
+		change.PositionID = data.PositionID
+	}
+
+	if data.Color != "" {
+		change.Color = data.Color
+	}
+
+	if data.Visibility != "" {
+		change.Visibility = data.Visibility
+	}
+
+	if data.Metadata != nil {
+		change.Metadata = data.Metadata
+	}
+
+	if data.Tags != nil {
+		change.Tags = data.Tags
+	}
+
+	// Attempt to write the new object to the database once the user is found to be allowed to make the change,
+	// refusing changes made to a version of the shift which has since been changed
+	affected, err := s.Shifts.Update(ctx, db, c.service(), shift, &change, func(etag string) error {
+		return checkETag(req.Etag, etag)
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	affects(ctx, affected...)
+
+	return s.message(db, &change)
+}
+
+func (s *shiftService) CancelShift(ctx context.Context, req *CancelShiftRequest) (*emptypb.Empty, error) {
+	c := callerFrom(ctx)
+	db := s.DB.WithContext(ctx)
+
+	if strings.TrimSpace(req.Reason) == "" {
+		return nil, status.Error(codes.InvalidArgument, "cancellation reason required")
+	}
+
+	shift, err := s.find(db, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Attempt to cancel the object, it remains in the database for reporting
+	affected, err := s.Shifts.Cancel(ctx, db, c.service(), shift, req.Reason, func(etag string) error {
+		return checkETag(req.Etag, etag)
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	affects(ctx, affected...)
+
+	return &emptypb.Empty{}, nil
+}
+
+// find returns the shift with the external ID
+func (s *shiftService) find(db *gorm.DB, ext string) (*models.Shift, error) {
+	sid, err := opaque.Decode(opaque.Shift, ext)
+	if err != nil || sid == "" {
+		return nil, status.Error(codes.NotFound, "shift not found")
+	}
+
+	shift, err := models.FindShiftByID(db, sid)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return shift, nil
+}
+
+// message returns the message of the shift annotated with any holiday it falls on
+func (s *shiftService) message(db *gorm.DB, shift *models.Shift) (*Shift, error) {
+	err := models.AnnotateShifts(db, []*models.Shift{shift})
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	msg, err := shiftMessage(shift)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return msg, nil
+}
+
+// contains reports whether the list holds the value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// timeOf returns the time of the timestamp, zero when it is not set
+func timeOf(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+
+	return ts.AsTime()
+}
+
+// shiftModel returns the shift of the message with its internal IDs, empty when there is no message
+func shiftModel(msg *Shift) (*models.Shift, error) {
+	if msg == nil {
+		return &models.Shift{}, nil
+	}
+
+	uid, err := opaque.Decode(opaque.User, msg.UserId)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "user not found")
+	}
+
+	// Metadata left unset is nil, so that updates keep the shift's own
+	var metadata models.Metadata
+	if msg.Metadata != nil {
+		metadata = msg.Metadata.AsMap()
+	}
+
+	return &models.Shift{
+		UserID:     uid,
+		Start:      timeOf(msg.Start),
+		End:        timeOf(msg.End),
+		Capacity:   int(msg.Capacity),
+		Status:     msg.Status,
+		LocationID: msg.LocationId,
+		PositionID: msg.PositionId,
+		Color:      msg.Color,
+		Visibility: msg.Visibility,
+		Metadata:   metadata,
+		Tags:       msg.Tags,
+	}, nil
+}
+
+// shiftMessage returns the message of the shift, with the external IDs of the shift and its user
+func shiftMessage(s *models.Shift) (*Shift, error) {
+	sid, uid, err := s.ExternalIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata *structpb.Struct
+	if s.Metadata != nil {
+		metadata, err = structpb.NewStruct(s.Metadata)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Shift{
+		Id:         sid,
+		UserId:     uid,
+		Start:      timestamppb.New(s.Start),
+		End:        timestamppb.New(s.End),
+		Capacity:   int32(s.Capacity),
+		Status:     s.Status,
+		LocationId: s.LocationID,
+		PositionId: s.PositionID,
+		Color:      s.Color,
+		Visibility: s.Visibility,
+		Tags:       s.Tags,
+		Holiday:    s.Holiday,
+		CreatedAt:  timestamppb.New(s.CreatedAt),
+		UpdatedAt:  timestamppb.New(s.UpdatedAt),
+		Etag:       s.ETag(),
+		Metadata:   metadata,
+	}, nil
+}
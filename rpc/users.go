@@ -0,0 +1,208 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/opaque"
+	"github.com/btnmasher/shiftr/secrets"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+// userService implements UserService
+type userService struct {
+	*Server
+	UnimplementedUserServiceServer
+}
+
+func (s *userService) GetUser(ctx context.Context, req *GetUserRequest) (*User, error) {
+	c := callerFrom(ctx)
+
+	id, err := opaque.Decode(opaque.User, req.Id)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	// Constrain the user from fetching another user's object if not admin
+	if c.role == "user" && c.id != id {
+		return nil, status.Error(codes.PermissionDenied, "permission denied")
+	}
+
+	user, err := models.FindUserByID(s.DB.WithContext(ctx), id)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	msg, err := userMessage(user)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return msg, nil
+}
+
+func (s *userService) ListUsers(ctx context.Context, req *ListUsersRequest) (*ListUsersResponse, error) {
+	if callerFrom(ctx).role != "admin" {
+		return nil, status.Error(codes.PermissionDenied, "permission denied")
+	}
+
+	page, err := models.ListUsers(s.DB.WithContext(ctx), models.UserQuery{
+		Search: req.Search,
+		Role:   req.Role,
+		Sort:   req.Sort,
+		Limit:  int(req.Limit),
+		Page:   int(req.Page),
+		Cursor: req.Cursor,
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resp := &ListUsersResponse{Total: page.Total, NextCursor: page.NextCursor}
+	for _, user := range page.Users {
+		msg, err := userMessage(user)
+		if err != nil {
+			return nil, statusError(err)
+		}
+
+		resp.Users = append(resp.Users, msg)
+	}
+
+	return resp, nil
+}
+
+func (s *userService) CreateUser(ctx context.Context, req *CreateUserRequest) (*User, error) {
+	if callerFrom(ctx).role != "admin" {
+		return nil, status.Error(codes.PermissionDenied, "permission denied")
+	}
+
+	data := req.User
+	if data == nil {
+		data = &User{}
+	}
+
+	manager, err := opaque.Decode(opaque.User, data.ManagerId)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "manager not found")
+	}
+
+	// Prepare a new object to write to the database
+	user := models.User{
+		Name:       data.Name,
+		Password:   req.Password,
+		Role:       data.Role,
+		Email:      data.Email,
+		TeamID:     data.TeamId,
+		LocationID: data.LocationId,
+		ManagerID:  manager,
+		Phone:      secrets.String(data.Phone),
+	}
+
+	// Ensure we have all necessary fields to create the object
+	err = user.Validate()
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	db := s.DB.WithContext(ctx)
+
+	// Ensure the specified team, location and manager exist
+	if user.TeamID != "" {
+		_, err = models.FindTeamByID(db, user.TeamID)
+		if err != nil {
+			return nil, referenceError("team", err)
+		}
+	}
+
+	if user.LocationID != "" {
+		_, err = models.FindLocationByID(db, user.LocationID)
+		if err != nil {
+			return nil, referenceError("location", err)
+		}
+	}
+
+	if user.ManagerID != "" {
+		err = models.CheckManager(db, "", user.ManagerID)
+		if err != nil {
+			if errors.Is(err, models.ErrManagerNotFound) {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+
+			return nil, statusError(err)
+		}
+	}
+
+	// Ensure there are no other users that already exist with the specified name
+	_, err = models.FindUserByName(db.Unscoped(), user.Name)
+	if err == nil {
+		return nil, status.Error(codes.AlreadyExists, "user already exists")
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, statusError(err)
+	}
+
+	// Ensure no other user has the specified email address
+	err = models.CheckEmailAvailable(db, "", user.Email)
+	if err != nil {
+		if errors.Is(err, models.ErrEmailTaken) {
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		}
+
+		return nil, statusError(err)
+	}
+
+	// Attempt to write the new object to the database
+	err = user.Create(db)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	affects(ctx, user.ID)
+
+	msg, err := userMessage(&user)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return msg, nil
+}
+
+// referenceError answers a reference to an object of the kind which could not be found as an invalid argument
+func referenceError(kind string, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, models.ErrNotFound) {
+		return status.Error(codes.InvalidArgument, kind+" not found")
+	}
+
+	return statusError(err)
+}
+
+// userMessage returns the message of the user, with the external IDs of the user and their manager
+func userMessage(u *models.User) (*User, error) {
+	id, err := opaque.Encode(opaque.User, u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	manager, err := opaque.Encode(opaque.User, u.ManagerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &User{
+		Id:         id,
+		Name:       u.Name,
+		Role:       u.Role,
+		Email:      u.Email,
+		TeamId:     u.TeamID,
+		LocationId: u.LocationID,
+		ManagerId:  manager,
+		Phone:      string(u.Phone),
+		CreatedAt:  timestamppb.New(u.CreatedAt),
+		UpdatedAt:  timestamppb.New(u.UpdatedAt),
+		Etag:       u.ETag(),
+	}, nil
+}
@@ -0,0 +1,135 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// prefix marks values which were encrypted by a Keyring
+const prefix = "enc:v1:"
+
+var (
+	ErrNoKeyring  = errors.New("no encryption keyring configured")
+	ErrUnknownKey = errors.New("value was encrypted with an unknown key")
+	ErrMalformed  = errors.New("malformed encrypted value")
+)
+
+// Keyring encrypts values with AES-GCM using its primary key, and decrypts values encrypted
+// with any of its keys so that the primary key can be rotated without losing existing data
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring returns a Keyring for the AES keys (16, 24, or 32 bytes) keyed by their ID,
+// encrypting new values with the key identified by primary
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("primary key %q not provided", primary)
+	}
+
+	k := &Keyring{
+		primary: primary,
+		keys:    make(map[string]cipher.AEAD, len(keys)),
+	}
+
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %s", id, err)
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %s", id, err)
+		}
+
+		k.keys[id] = aead
+	}
+
+	return k, nil
+}
+
+// Primary returns the ID of the key used to encrypt new values
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// Encrypt seals the plaintext with the primary key
+func (k *Keyring) Encrypt(plaintext []byte) (string, error) {
+	aead := k.keys[k.primary]
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err := io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(k.primary))
+
+	return prefix + k.primary + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt with whichever key sealed it
+func (k *Keyring) Decrypt(value string) ([]byte, error) {
+	id, data, err := split(value)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, ok := k.keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, ciphertext, []byte(id))
+}
+
+// NeedsRotation reports whether the stored value is plaintext or was sealed with a key other than the primary key
+func (k *Keyring) NeedsRotation(value string) bool {
+	if value == "" {
+		return false
+	}
+
+	id, _, err := split(value)
+	if err != nil {
+		return true
+	}
+
+	return id != k.primary
+}
+
+// IsEncrypted reports whether the stored value was produced by a Keyring
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func split(value string) (id, data string, err error) {
+	if !IsEncrypted(value) {
+		return "", "", ErrMalformed
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(value, prefix), ":", 2)
+	if len(parts) != 2 {
+		return "", "", ErrMalformed
+	}
+
+	return parts[0], parts[1], nil
+}
@@ -0,0 +1,156 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testKeyring returns a Keyring encrypting with the primary of the keys k1 and k2
+func testKeyring(t *testing.T, primary string) *Keyring {
+	t.Helper()
+
+	k, err := NewKeyring(primary, map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 32),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k
+}
+
+func TestKeyringRoundTrip(t *testing.T) {
+	k := testKeyring(t, "k1")
+
+	sealed, err := k.Encrypt([]byte("555-0100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsEncrypted(sealed) || strings.Contains(sealed, "555-0100") {
+		t.Fatalf("sealed value %q does not hide the plaintext", sealed)
+	}
+
+	again, err := k.Encrypt([]byte("555-0100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again == sealed {
+		t.Error("sealing the same plaintext twice gave the same value")
+	}
+
+	// A keyring rotated to another primary key still opens values sealed with the old one
+	for _, primary := range []string{"k1", "k2"} {
+		plaintext, err := testKeyring(t, primary).Decrypt(sealed)
+		if err != nil || string(plaintext) != "555-0100" {
+			t.Errorf("keyring with primary %s: got %q, %v", primary, plaintext, err)
+		}
+	}
+}
+
+func TestKeyringRejects(t *testing.T) {
+	k := testKeyring(t, "k1")
+
+	sealed, err := k.Encrypt([]byte("555-0100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := strings.TrimPrefix(sealed, prefix+"k1:")
+
+	// Flip a bit of the last character of the ciphertext, which holds the authentication tag
+	tampered := []byte(data)
+	tampered[len(tampered)-1] ^= 0x01
+
+	wrong, err := NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{9}, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	only2, err := NewKeyring("k2", map[string][]byte{"k2": bytes.Repeat([]byte{2}, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		keyring *Keyring
+		value   string
+		want    error // nil for any error
+	}{
+		{"wrong key", wrong, sealed, nil},
+		{"unknown key", only2, sealed, ErrUnknownKey},
+		{"tampered", k, prefix + "k1:" + string(tampered), nil},
+		{"relabelled key", k, prefix + "k2:" + data, nil},
+		{"truncated", k, prefix + "k1:" + data[:8], ErrMalformed},
+		{"not base64", k, prefix + "k1:***", ErrMalformed},
+		{"no key id", k, prefix + data, ErrMalformed},
+		{"plaintext", k, "555-0100", ErrMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext, err := tt.keyring.Decrypt(tt.value)
+			if err == nil {
+				t.Fatalf("got %q, want an error", plaintext)
+			}
+
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewKeyringRejects(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+
+	tests := []struct {
+		name    string
+		primary string
+		keys    map[string][]byte
+	}{
+		{"no primary", "k2", map[string][]byte{"k1": key}},
+		{"empty id", "", map[string][]byte{"": key}},
+		{"colon in id", "k:1", map[string][]byte{"k:1": key}},
+		{"short key", "k1", map[string][]byte{"k1": key[:10]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyring(tt.primary, tt.keys)
+			if err == nil {
+				t.Error("got a keyring, want an error")
+			}
+		})
+	}
+}
+
+func TestKeyringNeedsRotation(t *testing.T) {
+	old, current := testKeyring(t, "k1"), testKeyring(t, "k2")
+
+	sealedOld, err := old.Encrypt([]byte("555-0100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealedCurrent, err := current.Encrypt([]byte("555-0100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for value, want := range map[string]bool{
+		"":            false,
+		"555-0100":    true,
+		sealedOld:     true,
+		sealedCurrent: false,
+	} {
+		if got := current.NeedsRotation(value); got != want {
+			t.Errorf("NeedsRotation(%q): got %t, want %t", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+)
+
+// KeyProvider is implemented by any source of encryption keys, such as a KMS client,
+// returning the ID of the primary key and every key which may still be needed for decryption
+type KeyProvider interface {
+	Keys(ctx context.Context) (primary string, keys map[string][]byte, err error)
+}
+
+// StaticKeys is a KeyProvider for keys supplied directly in configuration
+type StaticKeys struct {
+	Primary string
+	Set     map[string][]byte
+}
+
+// Keys returns the configured keys
+func (s StaticKeys) Keys(_ context.Context) (string, map[string][]byte, error) {
+	return s.Primary, s.Set, nil
+}
+
+// Base64Keys returns a StaticKeys provider from base64 encoded keys keyed by their ID
+func Base64Keys(primary string, encoded map[string]string) (StaticKeys, error) {
+	keys := make(map[string][]byte, len(encoded))
+
+	for id, enc := range encoded {
+		key, err := base64.StdEncoding.DecodeString(enc)
+		if err != nil {
+			return StaticKeys{}, fmt.Errorf("key %q: %s", id, err)
+		}
+
+		keys[id] = key
+	}
+
+	return StaticKeys{Primary: primary, Set: keys}, nil
+}
+
+// LoadKeyring builds a Keyring from the provider
+func LoadKeyring(ctx context.Context, p KeyProvider) (*Keyring, error) {
+	primary, keys, err := p.Keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKeyring(primary, keys)
+}
@@ -0,0 +1,178 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"reflect"
+)
+
+// String is a string column which is transparently encrypted at rest with the Keyring of the database handle
+// writing it, attached with Configure. Values are stored as plaintext when the handle has no Keyring, and plaintext
+// values are still read so that existing rows can be encrypted later by rotation.
+type String string
+
+// stringType is the type of the fields decrypted once read
+var stringType = reflect.TypeOf(String(""))
+
+// GormValue implements gorm.Valuer, encrypting the string with the Keyring of the database handle writing it
+func (s String) GormValue(_ context.Context, db *gorm.DB) clause.Expr {
+	k := CurrentKeyring(db)
+	if s == "" || k == nil {
+		return clause.Expr{SQL: "?", Vars: []interface{}{string(s)}}
+	}
+
+	sealed, err := k.Encrypt([]byte(s))
+	if err != nil {
+		_ = db.AddError(err)
+	}
+
+	return clause.Expr{SQL: "?", Vars: []interface{}{sealed}}
+}
+
+// Scan implements sql.Scanner, reading the stored string as it is. Encrypted values are decrypted once the
+// records holding them are read through a database handle configured with Configure.
+func (s *String) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s = ""
+	case string:
+		*s = String(v)
+	case []byte:
+		*s = String(v)
+	default:
+		return fmt.Errorf("cannot scan %T into secrets.String", src)
+	}
+
+	return nil
+}
+
+// keyringPlugin is the name the Keyring of a database handle is kept under among its gorm plugins
+const keyringPlugin = "secrets:keyring"
+
+// decryptCallback is the name of the query callback decrypting the records read
+const decryptCallback = "secrets:decrypt"
+
+// plugin keeps the Keyring of a database handle as a gorm plugin, which every session and transaction started
+// from the handle carries along
+type plugin struct {
+	keyring *Keyring
+}
+
+// Name implements gorm.Plugin
+func (*plugin) Name() string {
+	return keyringPlugin
+}
+
+// Initialize implements gorm.Plugin
+func (*plugin) Initialize(*gorm.DB) error {
+	return nil
+}
+
+// Configure returns a handle of the database encrypting the String columns it writes with the Keyring and
+// decrypting those it reads, as do the sessions and transactions started from it. Without a Keyring, new values are
+// stored as plaintext and encrypted values cannot be read. Other handles of the database keep their own Keyring.
+func Configure(db *gorm.DB, k *Keyring) (*gorm.DB, error) {
+	// The handles of a database share its callbacks, so records are decrypted with the Keyring of the handle reading
+	if db.Callback().Query().Get(decryptCallback) == nil {
+		err := db.Callback().Query().After("gorm:query").Register(decryptCallback, decrypt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tx := db.Session(&gorm.Session{NewDB: true})
+
+	// The session holds its own copy of the configuration of the database, but shares its plugins
+	plugins := make(map[string]gorm.Plugin, len(tx.Plugins)+1)
+	for name, p := range tx.Plugins {
+		plugins[name] = p
+	}
+
+	plugins[keyringPlugin] = &plugin{keyring: k}
+	tx.Plugins = plugins
+
+	return tx, nil
+}
+
+// CurrentKeyring returns the Keyring String columns are encrypted with through the database handle, if any
+func CurrentKeyring(db *gorm.DB) *Keyring {
+	if p, ok := db.Config.Plugins[keyringPlugin].(*plugin); ok {
+		return p.keyring
+	}
+
+	return nil
+}
+
+// decrypt decrypts the String fields of the records read through a handle configured with Configure
+func decrypt(db *gorm.DB) {
+	p, ok := db.Config.Plugins[keyringPlugin].(*plugin)
+	if !ok || db.Error != nil || !db.Statement.ReflectValue.IsValid() {
+		return
+	}
+
+	err := decryptValue(p.keyring, db.Statement.ReflectValue)
+	if err != nil {
+		_ = db.AddError(err)
+	}
+}
+
+// decryptValue decrypts the Strings of the value, which is a String, a record or a list of either
+func decryptValue(k *Keyring, v reflect.Value) error {
+	if v.Type() == stringType {
+		return decryptString(k, v)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+
+		return decryptValue(k, v.Elem())
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			err := decryptValue(k, v.Index(i))
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		// Only the fields of the record itself are read by the query, its associations are read by their own
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.Type != stringType && !field.Anonymous {
+				continue
+			}
+
+			err := decryptValue(k, v.Field(i))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// decryptString replaces the encrypted String with its plaintext
+func decryptString(k *Keyring, v reflect.Value) error {
+	stored := v.String()
+	if !IsEncrypted(stored) || !v.CanSet() {
+		return nil
+	}
+
+	if k == nil {
+		return ErrNoKeyring
+	}
+
+	plaintext, err := k.Decrypt(stored)
+	if err != nil {
+		return err
+	}
+
+	v.SetString(string(plaintext))
+
+	return nil
+}
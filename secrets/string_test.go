@@ -0,0 +1,166 @@
+package secrets
+
+import (
+	"errors"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"testing"
+)
+
+// contact is a record with an encrypted column
+type contact struct {
+	ID    uint
+	Phone String
+}
+
+// testDB returns a database of the test's own holding the contacts table
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "secrets.db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.AutoMigrate(&contact{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// configure returns a handle of the database with the Keyring, failing the test on error
+func configure(t *testing.T, db *gorm.DB, k *Keyring) *gorm.DB {
+	t.Helper()
+
+	tx, err := Configure(db, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tx
+}
+
+// stored returns the phone number of the contact as stored in the database
+func stored(t *testing.T, db *gorm.DB, id uint) string {
+	t.Helper()
+
+	var phone string
+
+	err := db.Raw("SELECT phone FROM contacts WHERE id = ?", id).Row().Scan(&phone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return phone
+}
+
+func TestStringEncryptedAtRest(t *testing.T) {
+	db := testDB(t)
+	encrypted := configure(t, db, testKeyring(t, "k1"))
+
+	c := &contact{Phone: "555-0100"}
+
+	err := encrypted.Create(c).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if phone := stored(t, db, c.ID); !IsEncrypted(phone) {
+		t.Fatalf("stored the phone number as %q", phone)
+	}
+
+	// Records and lists of them are read decrypted, in transactions too
+	var one contact
+
+	err = encrypted.First(&one, c.ID).Error
+	if err != nil || one.Phone != "555-0100" {
+		t.Errorf("reading the record: got %q, %v", one.Phone, err)
+	}
+
+	err = encrypted.Transaction(func(tx *gorm.DB) error {
+		var all []*contact
+
+		err := tx.Find(&all).Error
+		if err == nil && (len(all) != 1 || all[0].Phone != "555-0100") {
+			t.Errorf("reading the records in a transaction: got %v", all)
+		}
+
+		return err
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// A handle without keys stores plaintext, which a handle with keys reads as it is
+	plain := configure(t, db, nil)
+
+	p := &contact{Phone: "555-0199"}
+
+	err = plain.Create(p).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if phone := stored(t, db, p.ID); phone != "555-0199" {
+		t.Errorf("handle without keys stored %q", phone)
+	}
+
+	var other contact
+
+	err = encrypted.First(&other, p.ID).Error
+	if err != nil || other.Phone != "555-0199" {
+		t.Errorf("reading plaintext: got %q, %v", other.Phone, err)
+	}
+}
+
+func TestStringRejectsUnreadable(t *testing.T) {
+	db := testDB(t)
+
+	c := &contact{Phone: "555-0100"}
+
+	err := configure(t, db, testKeyring(t, "k1")).Create(c).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := NewKeyring("k3", map[string][]byte{"k3": make([]byte, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tt := range map[string]struct {
+		keyring *Keyring
+		want    error
+	}{
+		"no keyring":  {nil, ErrNoKeyring},
+		"unknown key": {other, ErrUnknownKey},
+	} {
+		var read contact
+
+		err = configure(t, db, tt.keyring).First(&read, c.ID).Error
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", name, err, tt.want)
+		}
+	}
+
+	// A value tampered with at rest is refused rather than read
+	sealed := stored(t, db, c.ID)
+
+	err = db.Exec("UPDATE contacts SET phone = ? WHERE id = ?", sealed[:len(sealed)-2]+"AA", c.ID).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var read contact
+
+	err = configure(t, db, testKeyring(t, "k1")).First(&read, c.ID).Error
+	if err == nil {
+		t.Errorf("reading a tampered value: got %q, want an error", read.Phone)
+	}
+}
@@ -2,17 +2,80 @@ package server
 
 import (
 	"fmt"
+	"github.com/btnmasher/shiftr/api/middleware"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/jobs"
+	"github.com/btnmasher/shiftr/logging"
+	"github.com/btnmasher/shiftr/notify"
+	"github.com/btnmasher/shiftr/render"
+	"github.com/btnmasher/shiftr/secrets"
+	"github.com/btnmasher/shiftr/storage"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"io"
+	"strings"
 	"time"
 )
 
 type Config struct {
 	// api
 	JwtSecret    string
+	jwtGrace     time.Duration
+	signSecret   string
+	signWindow   time.Duration
+	rateLimit    int
+	rateWindow   time.Duration
+	compression  *middleware.Compression
+	faults       []middleware.FaultRule
+	versions     []middleware.APIVersion
+	idempotency  time.Duration
+	renderers    map[string]render.Renderer
+	keys         secrets.KeyProvider
+	idKey        []byte
 	addr         string
 	port         int
+	grpcPort     int
 	readtimeout  time.Duration
 	writetimeout time.Duration
 	debug        bool
+	logging      logging.Config
+	logger       *zerolog.Logger
+	notifier     notify.Notifier
+	channels     []notify.Channel
+	eventMode    bool
+	approval     bool
+	shiftLimits  models.ShiftLimits
+	blobStore    storage.BlobStore
+	// analytics
+	analyticsInterval time.Duration
+	// reminders
+	reminders    bool
+	reminderLead time.Duration
+	// scheduling rules
+	schedulingRules models.SchedulingRules
+	// timesheets
+	punchRounding models.PunchRounding
+	// reports
+	reportWeeks models.Weeks
+	currency    string
+	catalogDir  string
+	// reconciliation
+	reconcileVariance time.Duration
+	reconcileLocation *time.Location
+	// notification digests
+	digestWindow    time.Duration
+	digestImmediate []string
+	// circuit breakers
+	breakerThreshold int
+	breakerCooldown  time.Duration
+	// avatars
+	avatarMaxSize int64
+	avatarBaseURL string
+	// registration
+	registration        bool
+	registrationRole    string
+	registrationTeam    string
+	registrationDomains []string
 	// database
 	dbHost   string
 	dbPort   int
@@ -20,6 +83,14 @@ type Config struct {
 	dbName   string
 	dbUser   string
 	dbPass   string
+	// embedding
+	db        *gorm.DB
+	basePath  string
+	publicURL string
+	// allow migrations which lose data
+	allowDestructive bool
+	// reject requests which modify data
+	readOnly bool
 }
 
 // NewConfig returns a prepared Config struct with the given ConfigOption parameters modifying the state.
@@ -34,6 +105,18 @@ func NewConfig(opts ...ConfigOption) *Config {
 		defDbType       = SqliteMem
 		defDbName       = "shiftr"
 		defJtwSecret    = "changemeohgodplease"
+		defJwtGrace     = time.Hour * 72
+		defSignWindow   = time.Minute * 5
+		defVersion      = "v1"
+		defIdempotency  = time.Hour * 24
+		defEventMode    = false
+		defBlobDir      = "data"
+		defRegistration = false
+		defRegRole      = "user"
+		defReminders    = false
+		defReminderLead = time.Hour
+		defAvatarSize   = 2 << 20
+		defCompressMin  = 1 << 10
 	)
 
 	c := &Config{
@@ -46,6 +129,28 @@ func NewConfig(opts ...ConfigOption) *Config {
 		dbDriver:     defDbType,
 		dbName:       defDbName,
 		JwtSecret:    defJtwSecret,
+		jwtGrace:     defJwtGrace,
+		signWindow:   defSignWindow,
+		versions:     []middleware.APIVersion{{Name: defVersion}},
+		idempotency:  defIdempotency,
+		compression:  &middleware.Compression{MinLength: defCompressMin},
+		renderers:    map[string]render.Renderer{"text/csv": render.CSV{}, "application/xml": render.XML{}},
+		eventMode:    defEventMode,
+		blobStore:    storage.NewLocalStore(defBlobDir),
+
+		registration:     defRegistration,
+		registrationRole: defRegRole,
+
+		reminders:    defReminders,
+		reminderLead: defReminderLead,
+
+		reportWeeks: models.Weeks{Start: time.Monday},
+		currency:    models.DefaultCurrency,
+
+		reconcileVariance: jobs.DefaultReconcileVariance,
+		reconcileLocation: time.UTC,
+
+		avatarMaxSize: defAvatarSize,
 	}
 
 	for _, opt := range opts {
@@ -59,6 +164,10 @@ func (c *Config) serverURL() string {
 	return fmt.Sprintf("%s:%d", c.addr, c.port)
 }
 
+func (c *Config) grpcURL() string {
+	return fmt.Sprintf("%s:%d", c.addr, c.grpcPort)
+}
+
 const (
 	SqliteMemoryUrl    = "file::memory:?cache=shared"
 	SqliteUrlFormat    = "%s.db"
@@ -93,6 +202,14 @@ func ListenPort(port int) ConfigOption {
 	}
 }
 
+// ListenGRPCPort sets the port which the gRPC server will accept connections on, alongside the http server on the
+// same address, 0 to serve no gRPC. Default: 0
+func ListenGRPCPort(port int) ConfigOption {
+	return func(c *Config) {
+		c.grpcPort = port
+	}
+}
+
 // ListenAddr sets the port which the http server will accept connection. Default: localhost
 func ListenAddr(addr string) ConfigOption {
 	return func(c *Config) {
@@ -107,6 +224,48 @@ func WithJWTSecret(secret string) ConfigOption {
 	}
 }
 
+// WithJWTGracePeriod sets how long tokens signed with a rotated JWT secret remain valid. Default: time.Hour * 72
+func WithJWTGracePeriod(grace time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.jwtGrace = grace
+	}
+}
+
+// WithRateLimit limits each client IP address to the number of requests per window, disabled when limit is 0
+func WithRateLimit(limit int, window time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.rateLimit = limit
+		c.rateWindow = window
+	}
+}
+
+// WithCompression sets how responses are compressed for the clients accepting it, nil to send every response as
+// it is. Default: gzip of responses of at least 1 KiB
+func WithCompression(compression *middleware.Compression) ConfigOption {
+	return func(c *Config) {
+		c.compression = compression
+	}
+}
+
+// WithFaultInjection injects the delays and errors described by the rules into requests, so client
+// retry logic can be tested. The rules are ignored unless debug is enabled.
+func WithFaultInjection(rules ...middleware.FaultRule) ConfigOption {
+	return func(c *Config) {
+		c.faults = rules
+	}
+}
+
+// WithRequestSigning requires high-privilege admin requests to carry an HMAC signature made with the secret,
+// accepted only within the window around its timestamp and only once
+func WithRequestSigning(secret string, window time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.signSecret = secret
+		if window > 0 {
+			c.signWindow = window
+		}
+	}
+}
+
 type DriverType string
 
 const (
@@ -194,9 +353,333 @@ func WithWriteTimeout(timeout time.Duration) ConfigOption {
 	}
 }
 
-// DebugEnabled sets whether or not to enable Debug logging (sensitive data will be written to stdout!). Default: false
+// DebugEnabled sets whether or not to enable Debug logging (sensitive data will be written to the log!), logging
+// from the debug level unless another level is set by WithLogLevel. Default: false
 func DebugEnabled(enabled bool) ConfigOption {
 	return func(c *Config) {
 		c.debug = enabled
 	}
 }
+
+// WithLogLevel sets the least severe level logged, of trace, debug, info, warn and error. Default: info
+func WithLogLevel(level string) ConfigOption {
+	return func(c *Config) {
+		c.logging.Level = level
+	}
+}
+
+// WithLogFormat sets the format of the log lines, logging.FormatJSON or logging.FormatConsole.
+// Default: logging.FormatJSON
+func WithLogFormat(format string) ConfigOption {
+	return func(c *Config) {
+		c.logging.Format = format
+	}
+}
+
+// WithLogOutput sets the destination of the log lines. Default: os.Stderr
+func WithLogOutput(w io.Writer) ConfigOption {
+	return func(c *Config) {
+		c.logging.Output = w
+	}
+}
+
+// WithLogger sets the logger the server logs through, such as one shared with an application embedding shiftr,
+// in place of the one built from the level, format and output options. Lines are redacted only when it writes
+// through logging.Redact. Default: none
+func WithLogger(l zerolog.Logger) ConfigOption {
+	return func(c *Config) {
+		c.logger = &l
+	}
+}
+
+// AllowDestructiveMigrations sets whether the startup migration may make changes which lose data,
+// such as narrowing a column or abandoning one removed from a model. Default: false
+func AllowDestructiveMigrations(allow bool) ConfigOption {
+	return func(c *Config) {
+		c.allowDestructive = allow
+	}
+}
+
+// WithDatabase sets an already opened database connection for the server to use, such as one shared with an
+// application embedding shiftr. The database driver, host and credential options are ignored. Default: none
+func WithDatabase(db *gorm.DB) ConfigOption {
+	return func(c *Config) {
+		c.db = db
+	}
+}
+
+// WithBasePath serves every route under the given path prefix, such as "/shiftr", so the server can be mounted
+// within another application's router and generates links which include the prefix. Default: none
+func WithBasePath(path string) ConfigOption {
+	return func(c *Config) {
+		c.basePath = "/" + strings.Trim(path, "/")
+		if c.basePath == "/" {
+			c.basePath = ""
+		}
+	}
+}
+
+// WithPublicURL sets the URL clients reach the server at, such as "https://example.com/shiftr" behind a reverse
+// proxy which adds a path prefix, so generated links and avatar URLs point there rather than at the address the
+// request arrived on. A proxy which strips the prefix only needs this, one which passes it on also needs WithBasePath.
+// Default: the scheme and host of each request under the base path
+func WithPublicURL(url string) ConfigOption {
+	return func(c *Config) {
+		c.publicURL = strings.TrimRight(url, "/")
+	}
+}
+
+// WithAPIVersions sets the versions of the API mounted under /api, each served by the same handlers with its own
+// serializer and deprecation schedule. Default: v1 alone
+func WithAPIVersions(versions ...middleware.APIVersion) ConfigOption {
+	return func(c *Config) {
+		c.versions = versions
+	}
+}
+
+// WithIdempotencyWindow sets how long the response to a request made with an Idempotency-Key header is kept to
+// replay to retries of it, 0 to ignore the header. Default: 24 hours
+func WithIdempotencyWindow(window time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.idempotency = window
+	}
+}
+
+// WithRenderer sets the Renderer answering the lists of the API to requests accepting the media type rather
+// than JSON, replacing any set for it, or stops the media type being offered when nil.
+// Default: text/csv and application/xml
+func WithRenderer(mediaType string, r render.Renderer) ConfigOption {
+	return func(c *Config) {
+		if r == nil {
+			delete(c.renderers, mediaType)
+			return
+		}
+
+		c.renderers[mediaType] = r
+	}
+}
+
+// ReadOnlyMode sets whether the server refuses every request which would modify data, for use during failovers
+// and restores or when connected to a read replica. Migrations and background jobs are skipped. Default: false
+func ReadOnlyMode(enabled bool) ConfigOption {
+	return func(c *Config) {
+		c.readOnly = enabled
+	}
+}
+
+// WithNotifier sets the Notifier used to deliver emails and other notifications. Default: notify.LogNotifier
+func WithNotifier(n notify.Notifier) ConfigOption {
+	return func(c *Config) {
+		c.notifier = n
+	}
+}
+
+// WithNotificationDigest coalesces the notifications sent to each user within the window into a single digest.
+// Urgent notifications and those for the immediate events are still sent at once, notify.DefaultImmediate when
+// none are given. Default: disabled
+func WithNotificationDigest(window time.Duration, immediate ...string) ConfigOption {
+	return func(c *Config) {
+		c.digestWindow = window
+		c.digestImmediate = immediate
+	}
+}
+
+// WithCircuitBreakers sets how many consecutive failures of an optional dependency, the notifier, each broadcast
+// channel or the blob store, open its circuit breaker, and how long it is left to recover before being tried again.
+// Default: 5 failures, 30 seconds
+func WithCircuitBreakers(threshold int, cooldown time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.breakerThreshold = threshold
+		c.breakerCooldown = cooldown
+	}
+}
+
+// WithBroadcastChannels sets every channel an emergency broadcast is sent on. Default: the configured Notifier
+func WithBroadcastChannels(channels ...notify.Channel) ConfigOption {
+	return func(c *Config) {
+		c.channels = channels
+	}
+}
+
+// broadcastChannels returns the configured broadcast channels, falling back to the Notifier
+func (c *Config) broadcastChannels() []notify.Channel {
+	if len(c.channels) > 0 {
+		return c.channels
+	}
+
+	return []notify.Channel{{Name: "default", Notifier: c.notifier}}
+}
+
+// RegistrationEnabled sets whether accounts may be self-registered through POST /register. Default: false
+func RegistrationEnabled(enabled bool) ConfigOption {
+	return func(c *Config) {
+		c.registration = enabled
+	}
+}
+
+// RegistrationRole sets the role assigned to self-registered accounts. Default: user
+func RegistrationRole(role string) ConfigOption {
+	return func(c *Config) {
+		c.registrationRole = role
+	}
+}
+
+// RegistrationTeam sets the ID of the team self-registered accounts join. Default: none
+func RegistrationTeam(tid string) ConfigOption {
+	return func(c *Config) {
+		c.registrationTeam = tid
+	}
+}
+
+// RegistrationDomains restricts self-registration to email addresses in the given domains, such as
+// "example.com". Subdomains must be listed on their own. Default: any domain
+func RegistrationDomains(domains ...string) ConfigOption {
+	return func(c *Config) {
+		c.registrationDomains = domains
+	}
+}
+
+// signupPolicy returns the policy applied to self-registered accounts
+func (c *Config) signupPolicy() models.SignupPolicy {
+	return models.SignupPolicy{
+		Role:    c.registrationRole,
+		TeamID:  c.registrationTeam,
+		Domains: c.registrationDomains,
+	}
+}
+
+// RemindersEnabled sets whether the background scheduler sends reminders ahead of upcoming shifts. Default: false
+func RemindersEnabled(enabled bool) ConfigOption {
+	return func(c *Config) {
+		c.reminders = enabled
+	}
+}
+
+// WithReminderLead sets how long before a shift starts its reminder is sent, for users
+// who have not set their own preference. Default: time.Hour
+func WithReminderLead(lead time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.reminderLead = lead
+	}
+}
+
+// ShiftApproval sets whether shifts users create for themselves await approval by their manager or an admin
+// before they are published. Default: false
+func ShiftApproval(enabled bool) ConfigOption {
+	return func(c *Config) {
+		c.approval = enabled
+	}
+}
+
+// EventMode sets whether shifts with a capacity may be signed up for by users themselves,
+// for volunteer coordination without manager assignment. Default: false
+func EventMode(enabled bool) ConfigOption {
+	return func(c *Config) {
+		c.eventMode = enabled
+	}
+}
+
+// WithShiftLimits constrains the duration of shifts, how far ahead they may be scheduled, and whether users
+// may save shifts which have already ended. Default: unconstrained
+func WithShiftLimits(limits models.ShiftLimits) ConfigOption {
+	return func(c *Config) {
+		c.shiftLimits = limits
+	}
+}
+
+// WithSchedulingRules sets the rules checked against the other shifts of a user when saving their shifts, such
+// as the minimum rest between shifts and the most hours in a week. Each rule is off, enforced, or in shadow mode
+// where violations are only recorded for review. Default: no rules
+func WithSchedulingRules(rules models.SchedulingRules) ConfigOption {
+	return func(c *Config) {
+		c.schedulingRules = rules
+	}
+}
+
+// WithPunchRounding sets how clock punches are rounded when computing worked hours for timesheets, to the
+// nearest increment or to the scheduled time of the shift when within a grace period. Default: unrounded
+func WithPunchRounding(rounding models.PunchRounding) ConfigOption {
+	return func(c *Config) {
+		c.punchRounding = rounding
+	}
+}
+
+// WithReportWeeks sets how reports group shifts into weeks, either weeks beginning on a given weekday as is
+// customary in the organization's locale or ISO 8601 weeks. Requests may override it. Default: weeks beginning Monday
+func WithReportWeeks(weeks models.Weeks) ConfigOption {
+	return func(c *Config) {
+		c.reportWeeks = weeks
+	}
+}
+
+// WithCurrency sets the ISO 4217 code of the currency the organization pays in, which labor costs are reported in
+// for shifts at locations without a currency of their own. Default: USD
+func WithCurrency(code string) ConfigOption {
+	return func(c *Config) {
+		c.currency = code
+	}
+}
+
+// WithMessageCatalogs loads the message catalogs of the JSON files in the directory, each named after its locale
+// such as pt.json and holding an object of the English messages and their translations, adding locales or
+// correcting the bundled translations. Default: none
+func WithMessageCatalogs(dir string) ConfigOption {
+	return func(c *Config) {
+		c.catalogDir = dir
+	}
+}
+
+// WithReconciliation sets how far the time worked on a shift may differ from the time scheduled before the nightly
+// reconciliation flags it, and the zone whose midnight ends each day. Default: 15 minutes, UTC
+func WithReconciliation(variance time.Duration, loc *time.Location) ConfigOption {
+	return func(c *Config) {
+		c.reconcileVariance = variance
+		c.reconcileLocation = loc
+	}
+}
+
+// WithBlobStore sets the object storage used for exported files and uploads. Default: local directory "data"
+func WithBlobStore(store storage.BlobStore) ConfigOption {
+	return func(c *Config) {
+		c.blobStore = store
+	}
+}
+
+// WithAvatarMaxSize sets the largest avatar image in bytes users may upload. Default: 2 MiB
+func WithAvatarMaxSize(size int64) ConfigOption {
+	return func(c *Config) {
+		c.avatarMaxSize = size
+	}
+}
+
+// WithAvatarBaseURL sets the URL avatars are linked to in user responses, such as a CDN or public bucket in front
+// of the blob store, which the name of the avatar's file is appended to. Default: the server's /avatars/ route
+func WithAvatarBaseURL(url string) ConfigOption {
+	return func(c *Config) {
+		c.avatarBaseURL = url
+	}
+}
+
+// AnalyticsExportInterval sets how often the shifts and users tables are exported to the blob store
+// for analytics ingestion, zero disables the export. Default: 0
+func AnalyticsExportInterval(interval time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.analyticsInterval = interval
+	}
+}
+
+// WithEncryptionKeys sets the provider of the AES keys used to encrypt sensitive columns at rest,
+// such as secrets.StaticKeys or a KMS backed provider. Default: none (stored as plaintext)
+func WithEncryptionKeys(keys secrets.KeyProvider) ConfigOption {
+	return func(c *Config) {
+		c.keys = keys
+	}
+}
+
+// WithExternalIDKey exposes users and shifts to API consumers under opaque IDs, encrypted with the AES key
+// (16, 24, or 32 bytes), in URLs, response bodies and token subjects. Default: none (internal IDs exposed)
+func WithExternalIDKey(key []byte) ConfigOption {
+	return func(c *Config) {
+		c.idKey = key
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/btnmasher/shiftr/api/middleware"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"time"
+)
+
+// slowStatement is how long a statement may take before it is logged as slow
+const slowStatement = 200 * time.Millisecond
+
+// dbLogger logs the statements made to the database through the server's logger, with the ID of the request
+// making them. Every statement is logged at the debug level, slow ones at the warn level, and failed ones at the
+// error level, except for records not being found, which is how models report a missing record.
+type dbLogger struct {
+	log   *zerolog.Logger
+	level logger.LogLevel
+}
+
+func (l dbLogger) LogMode(level logger.LogLevel) logger.Interface {
+	l.level = level
+	return l
+}
+
+func (l dbLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Info {
+		l.with(ctx, l.log.Info()).Msgf(msg, args...)
+	}
+}
+
+func (l dbLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Warn {
+		l.with(ctx, l.log.Warn()).Msgf(msg, args...)
+	}
+}
+
+func (l dbLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Error {
+		l.with(ctx, l.log.Error()).Msgf(msg, args...)
+	}
+}
+
+func (l dbLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+
+	msg := "database statement"
+
+	var event *zerolog.Event
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= logger.Error:
+		event = l.log.Error().Err(err)
+	case elapsed > slowStatement && l.level >= logger.Warn:
+		event = l.log.Warn()
+		msg = "slow database statement"
+	case l.level >= logger.Info:
+		event = l.log.Debug()
+	default:
+		return
+	}
+
+	// Building the statement is skipped when the line will not be written
+	if !event.Enabled() {
+		return
+	}
+
+	sql, rows := fc()
+
+	l.with(ctx, event).
+		Str("sql", sql).
+		Int64("rows", rows).
+		Dur("elapsed", elapsed).
+		Msg(msg)
+}
+
+// with adds the ID of the request the context serves to the line, if it serves one
+func (l dbLogger) with(ctx context.Context, event *zerolog.Event) *zerolog.Event {
+	if rid := middleware.RequestIDFrom(ctx); rid != "" {
+		event = event.Str("request_id", rid)
+	}
+
+	return event
+}
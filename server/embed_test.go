@@ -0,0 +1,188 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/opaque"
+	"github.com/btnmasher/shiftr/secrets"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServersKeepTheirOwnConfiguration(t *testing.T) {
+	catalogs := t.TempDir()
+
+	err := ioutil.WriteFile(filepath.Join(catalogs, "pt.json"), []byte(`{"invalid object": "objeto inválido"}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := secrets.StaticKeys{Primary: "k1", Set: map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}}
+
+	configured := newTestServer(t,
+		WithShiftLimits(models.ShiftLimits{MaxDuration: 4 * time.Hour}),
+		WithMessageCatalogs(catalogs),
+		WithEncryptionKeys(keys),
+		WithAvatarBaseURL("https://cdn.example.com/avatars"),
+	)
+	plain := newTestServer(t)
+
+	servers := map[string]*Server{"configured": configured, "plain": plain}
+
+	// Each server applies its own shift limits
+	for name, want := range map[string]int{"configured": http.StatusUnprocessableEntity, "plain": http.StatusOK} {
+		srv := servers[name]
+
+		user, err := models.FindUserByName(srv.DB, "testuser")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		start := time.Now().AddDate(0, 0, 1).Truncate(time.Hour)
+		body := fmt.Sprintf(`{"user_id":%q,"start":%q,"end":%q}`, user.ID,
+			start.Format(time.RFC3339), start.Add(8*time.Hour).Format(time.RFC3339))
+
+		rec := serve(srv, http.MethodPost, "/api/v1/shifts", login(t, srv, "adminuser", "adminpass"), body, nil)
+		if rec.Code != want {
+			t.Errorf("%s server creating an 8 hour shift: got %d, want %d: %s", name, rec.Code, want, rec.Body)
+		}
+	}
+
+	// Each server publishes the changes made through it on its own bus
+	configuredEvents, plainEvents := configured.Events.Subscribe(), plain.Events.Subscribe()
+	defer configuredEvents.Close()
+	defer plainEvents.Close()
+
+	createShift(t, plain, "testuser", 2)
+
+	select {
+	case e := <-configuredEvents.Events():
+		t.Errorf("configured server published %s of a shift created through the plain server", e.Type)
+	default:
+	}
+
+	select {
+	case e := <-plainEvents.Events():
+		if e.Type != models.EventShiftCreated {
+			t.Errorf("plain server published %s, want %s", e.Type, models.EventShiftCreated)
+		}
+	default:
+		t.Error("plain server published nothing of the shift created through it")
+	}
+
+	// Each server negotiates the locales of its own catalog
+	for name, want := range map[string]string{"configured": "pt", "plain": "en"} {
+		srv := servers[name]
+		token := login(t, srv, "testuser", "testpass")
+
+		rec := serve(srv, http.MethodPost, "/api/v1/shifts", token, `{"start":1}`, http.Header{"Accept-Language": {"pt"}})
+		if got := rec.Header().Get("Content-Language"); got != want {
+			t.Errorf("%s server: got Content-Language %q, want %q", name, got, want)
+		}
+
+		if name == "configured" && !strings.Contains(rec.Body.String(), "objeto inválido") {
+			t.Errorf("configured server: got %s, want the message translated by its catalog", rec.Body)
+		}
+	}
+
+	// Each server encrypts with its own keys, and serves avatars from its own URL
+	want := map[string]string{
+		"configured": "https://cdn.example.com/avatars/face.png",
+		"plain":      "/avatars/face.png",
+	}
+
+	for name, srv := range servers {
+		user, err := models.FindUserByName(srv.DB, "testuser")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = srv.DB.Model(user).Update("phone", secrets.String("555-0100")).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var stored string
+
+		err = srv.DB.Raw("SELECT phone FROM users WHERE id = ?", user.ID).Row().Scan(&stored)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if secrets.IsEncrypted(stored) != (srv == configured) {
+			t.Errorf("%s server stored the phone number as %q", name, stored)
+		}
+
+		_, err = user.SetAvatar(srv.DB, models.AvatarPrefix+"face.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		user, err = models.FindUserByID(srv.DB, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if user.Phone != "555-0100" {
+			t.Errorf("%s server read the phone number as %q", name, user.Phone)
+		}
+
+		data, err := json.Marshal(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ext struct {
+			AvatarURL string `json:"avatar_url"`
+		}
+
+		err = json.Unmarshal(data, &ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ext.AvatarURL != want[name] {
+			t.Errorf("%s server: got avatar_url %q, want %q", name, ext.AvatarURL, want[name])
+		}
+	}
+}
+
+func TestServersShareExternalIDKey(t *testing.T) {
+	first := newTestServer(t, WithExternalIDKey(bytes.Repeat([]byte{1}, 16)))
+
+	// Another server with the same key may run alongside
+	second := newTestServer(t, WithExternalIDKey(bytes.Repeat([]byte{1}, 16)))
+
+	// A server mapping IDs differently is refused while they run, and initialized once they are closed
+	for _, opts := range [][]ConfigOption{{WithExternalIDKey(bytes.Repeat([]byte{2}, 16))}, nil} {
+		opts = append(opts, DatabaseDriver(SqliteMem), WithLogOutput(ioutil.Discard))
+
+		err := New().Initialize(NewConfig(opts...))
+		if !errors.Is(err, opaque.ErrCodecInUse) {
+			t.Errorf("initializing a server mapping IDs differently: got %v, want %v", err, opaque.ErrCodecInUse)
+		}
+	}
+
+	first.Close()
+	second.Close()
+
+	srv := New()
+
+	err := srv.Initialize(NewConfig(
+		DatabaseDriver(SqliteMem),
+		WithLogOutput(ioutil.Discard),
+		WithExternalIDKey(bytes.Repeat([]byte{2}, 16)),
+	))
+	if err != nil {
+		t.Fatalf("initializing a server with another external id key once the others are closed: %s", err)
+	}
+
+	srv.Close()
+}
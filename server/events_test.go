@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stream opens the event stream at the path with the token as a query parameter, as an EventSource does, resuming
+// after an event of another server so a reset is sent, and returns what was sent before it was closed
+func stream(t *testing.T, srv *Server, path, token string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	req := httptest.NewRequest(http.MethodGet, path+"?token="+token, nil).WithContext(ctx)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Last-Event-ID", "restarted-1")
+
+	rec := httptest.NewRecorder()
+	srv.API.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestEventStreamPaths(t *testing.T) {
+	for _, eventMode := range []bool{false, true} {
+		srv := newTestServer(t, EventMode(eventMode))
+		token := login(t, srv, "testuser", "testpass")
+
+		for _, path := range []string{"/api/v1/events", "/api/v1/events/stream"} {
+			rec := stream(t, srv, path, token)
+
+			if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" {
+				t.Errorf("event mode %t, %s: got %d %q", eventMode, path, rec.Code, rec.Header().Get("Content-Type"))
+			}
+
+			if !strings.HasPrefix(rec.Body.String(), "event: reset\n") {
+				t.Errorf("event mode %t, %s: got %q, want a reset event", eventMode, path, rec.Body)
+			}
+		}
+
+		if rec := stream(t, srv, "/api/v1/events", "not-a-token"); rec.Code != http.StatusUnauthorized {
+			t.Errorf("event mode %t: got %d streaming with an invalid token, want 401", eventMode, rec.Code)
+		}
+
+		// Requests not asking for the stream are listed the event signups, in event mode only
+		rec := serve(srv, http.MethodGet, "/api/v1/events", token, "", nil)
+
+		want := http.StatusNotAcceptable
+		if eventMode {
+			want = http.StatusOK
+		}
+
+		if rec.Code != want {
+			t.Errorf("event mode %t: got %d listing events, want %d: %s", eventMode, rec.Code, want, rec.Body)
+		}
+
+		if eventMode && !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+			t.Errorf("got %q listing events, want JSON", rec.Header().Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/opaque"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// externalSkipped are the GET routes not walked for internal IDs: the roots of the route groups, those streaming
+// until the client goes away, and those serving an object by a name or token of its own
+var externalSkipped = map[string]bool{
+	"/admin":                                true,
+	"/admin/*":                              true,
+	"/api/v1":                               true,
+	"/api/v1/*":                             true,
+	"/api/v1/ws":                            true,
+	"/api/v1/events":                        true,
+	"/api/v1/events/stream":                 true,
+	"/api/v1/schedule/presence":             true,
+	"/calendar/:token":                      true,
+	"/avatars/:name":                        true,
+	"/email/verify":                         true,
+	"/admin/analytics/exports/*":            true,
+	"/api/v1/users/:id/delegations/:did":    true,
+	"/api/v1/users/:id/certifications/:cid": true,
+}
+
+func TestExternalIDsNotExposed(t *testing.T) {
+	srv := newTestServer(t, WithExternalIDKey(bytes.Repeat([]byte{3}, 16)), EventMode(true))
+	admin := login(t, srv, "adminuser", "adminpass")
+	user := login(t, srv, "testuser", "testpass")
+
+	// Responses must only hold the external IDs of users and shifts, checked once every object is created
+	responses := make(map[string]string)
+
+	send := func(method, target, token, body string, header http.Header) (int, string) {
+		rec := serve(srv, method, target, token, body, header)
+		responses[method+" "+target] = rec.Body.String()
+
+		return rec.Code, rec.Body.String()
+	}
+
+	// call sends a request creating or changing objects, returning the ID of the object in the response if any
+	call := func(method, target, token, body string, header http.Header) string {
+		t.Helper()
+
+		code, resp := send(method, target, token, body, header)
+		if code >= http.StatusBadRequest {
+			t.Fatalf("%s %s: got %d %s", method, target, code, resp)
+		}
+
+		var created struct {
+			ID string `json:"id"`
+		}
+
+		_ = json.Unmarshal([]byte(resp), &created)
+
+		return created.ID
+	}
+
+	worker, err := models.FindUserByName(srv.DB, "testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uid, err := opaque.Encode(opaque.User, worker.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userPath := "/api/v1/users/" + uid
+	now := time.Now().Truncate(time.Hour)
+	day := func(days int) string {
+		return now.AddDate(0, 0, days).Format(time.RFC3339)
+	}
+
+	// A shift worked by the user, started and clocked into, then handed over to the next shift in the position
+	worked := createShift(t, srv, "testuser", 0)
+	call(http.MethodPost, "/api/v1/clock/in", user, `{}`, nil)
+	call(http.MethodPost, "/api/v1/clock/out", user, `{}`, nil)
+
+	position := call(http.MethodPost, "/api/v1/positions", admin, `{"name":"cashier"}`, nil)
+
+	err = srv.DB.Model(worked).Update("position_id", position).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err := models.FindUserByName(srv.DB, "adminuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := &models.Shift{UserID: manager.ID, Start: worked.End, End: worked.End.Add(time.Hour),
+		Status: models.ShiftPublished, PositionID: position}
+
+	err = next.Create(srv.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call(http.MethodPut, shiftPath(t, worked, "/handover"), user, `{"summary":"all quiet"}`, nil)
+
+	// A shift rescheduled once published
+
+	shift := createShift(t, srv, "testuser", 2)
+	call(http.MethodPatch, shiftPath(t, shift, ""), admin, fmt.Sprintf(`{"end":%q}`, day(3)),
+		http.Header{"If-Match": {shift.ETag()}})
+
+	// An open shift bid on by the user and awarded to them by their external ID
+	open := createShift(t, srv, "", 4)
+	call(http.MethodPut, shiftPath(t, open, "/bidding"), admin, fmt.Sprintf(`{"closes":%q,"rule":"manual"}`, day(1)), nil)
+	call(http.MethodPost, shiftPath(t, open, "/bids"), user, `{}`, nil)
+	call(http.MethodPost, shiftPath(t, open, "/bids/award"), admin, fmt.Sprintf(`{"user_id":%q}`, uid), nil)
+
+	// An event shift the user signed up for
+	event := createShift(t, srv, "", 5)
+	err = srv.DB.Model(event).Update("capacity", 2).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call(http.MethodPost, shiftPath(t, event, "/signup"), user, `{}`, nil)
+
+	// A draft published for the user by their external ID
+	draft := &models.Shift{UserID: worker.ID, Start: now.AddDate(0, 0, 6), End: now.AddDate(0, 0, 6).Add(time.Hour),
+		Status: models.ShiftDraft}
+
+	err = draft.Create(srv.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call(http.MethodPost, "/api/v1/schedules/publish", admin,
+		fmt.Sprintf(`{"start":%q,"end":%q,"user_ids":[%q]}`, day(6), day(7), uid), nil)
+
+	// Leave and time off, preferences and a calendar feed of the user
+	policy := call(http.MethodPost, "/api/v1/leave/policies", admin,
+		`{"name":"annual","accrual_hours":8,"carryover":40}`, nil)
+	call(http.MethodPut, userPath+"/leave", admin, fmt.Sprintf(`{"policy_id":%q,"start":%q}`, policy, day(-60)), nil)
+	call(http.MethodPost, userPath+"/timeoff", user, fmt.Sprintf(`{"start":%q,"end":%q,"hours":8}`, day(10), day(11)), nil)
+	call(http.MethodPut, userPath+"/preferences", user, `{"reminders_opt_out":true}`, nil)
+	call(http.MethodPost, userPath+"/calendar", user, `{}`, nil)
+
+	// A group, a rotation and a bulk update naming the user by their external ID
+	group := call(http.MethodPost, "/api/v1/groups", admin, `{"name":"floor"}`, nil)
+	call(http.MethodPut, "/api/v1/groups/"+group+"/members", admin, fmt.Sprintf(`{"user_ids":[%q]}`, uid), nil)
+
+	rotation := call(http.MethodPost, "/api/v1/rotations", admin, fmt.Sprintf(`{"name":"days","pattern":"D-",`+
+		`"anchor":%q,"timezone":"UTC","shift_types":[{"code":"D","start":"09:00","minutes":60}]}`,
+		now.AddDate(0, 0, 30).Format("2006-01-02")), nil)
+	call(http.MethodPut, "/api/v1/rotations/"+rotation+"/members", admin,
+		fmt.Sprintf(`[{"user_id":%q,"offset":0}]`, uid), nil)
+
+	call(http.MethodPost, "/admin/users/bulk-update", admin,
+		fmt.Sprintf(`{"changes":[{"user_id":%q,"role":"user"}]}`, uid), nil)
+
+	call(http.MethodPost, "/api/v1/coverage/simulate", admin, fmt.Sprintf(`{"start":%q,"end":%q,`+
+		`"absences":[{"user_id":%q,"start":%q,"end":%q}]}`, day(0), day(7), uid, day(0), day(7)), nil)
+
+	// A broadcast to the user on shift
+	broadcast := call(http.MethodPost, "/api/v1/broadcast", admin, `{"subject":"closing","body":"closing early"}`, nil)
+
+	// Every GET route is walked as an admin over the whole period the objects span, those of a shift on each shift
+	// until one is found the route applies to
+	shifts := []*models.Shift{worked, next, shift, open, event, draft}
+	ids := map[string]string{
+		"/users/:id":     uid,
+		"/groups/:id":    group,
+		"/rotations/:id": rotation,
+		"/broadcast/:id": broadcast,
+	}
+
+	queries := map[string]string{
+		"/admin/access-report": "user_id=" + uid,
+		"/api/v1/search":       "q=test",
+	}
+
+	window := url.Values{
+		"filter_start": {now.AddDate(0, 0, -1).Format(time.RFC3339)},
+		"filter_end":   {now.AddDate(0, 0, 14).Format(time.RFC3339)},
+	}
+
+	for _, route := range srv.API.Routes() {
+		if route.Method != http.MethodGet || externalSkipped[route.Path] {
+			continue
+		}
+
+		target := route.Path
+		for param, id := range ids {
+			target = strings.Replace(target, param, strings.TrimSuffix(param, ":id")+id, 1)
+		}
+
+		targets := []string{target}
+		if strings.Contains(target, "/shifts/:id") {
+			targets = nil
+			for _, shift := range shifts {
+				targets = append(targets, strings.Replace(target, "/api/v1/shifts/:id", shiftPath(t, shift, ""), 1))
+			}
+		}
+
+		if strings.ContainsAny(target, "*") || strings.Contains(targets[0], ":") {
+			t.Errorf("GET %s: no object to walk the route on", route.Path)
+			continue
+		}
+
+		query := window.Encode()
+		if extra, ok := queries[route.Path]; ok {
+			query += "&" + extra
+		}
+
+		var code int
+		var resp string
+
+		for _, target := range targets {
+			code, resp = send(http.MethodGet, target+"?"+query, admin, "", nil)
+			if code < http.StatusBadRequest {
+				break
+			}
+		}
+
+		if code >= http.StatusBadRequest {
+			t.Errorf("GET %s: got %d %s", route.Path, code, resp)
+		}
+	}
+
+	// No response holds the internal ID of a user or shift
+	var internal, sids []string
+
+	err = srv.DB.Model(&models.User{}).Pluck("id", &internal).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = srv.DB.Unscoped().Model(&models.Shift{}).Pluck("id", &sids).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for request, resp := range responses {
+		for _, id := range append(internal, sids...) {
+			if strings.Contains(resp, id) {
+				t.Errorf("%s exposes internal ID %s: %s", request, id, resp)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotentReplayCompressed(t *testing.T) {
+	srv := newTestServer(t)
+	token := login(t, srv, "adminuser", "adminpass")
+
+	// Enough shifts are created for the response to be compressed
+	var ops []string
+	for i := 0; i < 10; i++ {
+		start := time.Date(2100, time.January, 1+i, 9, 0, 0, 0, time.UTC)
+		ops = append(ops, fmt.Sprintf(`{"op":"create","shift":{"start":%q,"end":%q}}`,
+			start.Format(time.RFC3339), start.Add(8*time.Hour).Format(time.RFC3339)))
+	}
+
+	body := `{"operations":[` + strings.Join(ops, ",") + `]}`
+
+	var first []byte
+
+	for i, rid := range []string{"first", "second", "third"} {
+		rec := serve(srv, http.MethodPost, "/api/v1/batch", token, body, http.Header{
+			"Idempotency-Key": {"replayed-batch"},
+			"Accept-Encoding": {"gzip"},
+			"X-Request-Id":    {rid},
+		})
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: got %d %s", i, rec.Code, rec.Body)
+		}
+
+		if got := rec.Header().Get("X-Request-ID"); got != rid {
+			t.Errorf("request %d: got X-Request-ID %q, want %q", i, got, rid)
+		}
+
+		if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+			t.Fatalf("request %d: got Content-Encoding %q, want gzip", i, got)
+		}
+
+		if got := rec.Header().Values("Content-Encoding"); len(got) != 1 {
+			t.Errorf("request %d: got Content-Encoding %v, want it once", i, got)
+		}
+
+		gz, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("request %d: %s", i, err)
+		}
+
+		data, err := ioutil.ReadAll(gz)
+		if err != nil {
+			t.Fatalf("request %d: reading body: %s", i, err)
+		}
+
+		if i == 0 {
+			first = data
+			continue
+		}
+
+		if rec.Header().Get("Idempotent-Replayed") != "true" {
+			t.Errorf("request %d: not replayed", i)
+		}
+
+		if !bytes.Equal(data, first) {
+			t.Errorf("request %d: replayed %s, want %s", i, data, first)
+		}
+	}
+
+	// A client not accepting compression is replayed the plain body
+	rec := serve(srv, http.MethodPost, "/api/v1/batch", token, body, http.Header{"Idempotency-Key": {"replayed-batch"}})
+	if rec.Header().Get("Content-Encoding") != "" || !bytes.Equal(rec.Body.Bytes(), first) {
+		t.Errorf("plain replay: got Content-Encoding %q and body %s, want the plain body",
+			rec.Header().Get("Content-Encoding"), rec.Body)
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/btnmasher/shiftr/api/middleware"
+	"github.com/golang-jwt/jwt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+// reopen initializes another server on the database of a test server, as a replica or a restart would
+func reopen(t *testing.T, name string) *Server {
+	t.Helper()
+
+	srv := New()
+
+	err := srv.Initialize(NewConfig(
+		DatabaseDriver(Sqlite),
+		DatabaseName(name),
+		WithLogOutput(ioutil.Discard),
+	))
+	if err != nil {
+		t.Fatalf("could not initialize server: %s", err)
+	}
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestJWTKeysShared(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "shiftr")
+	first := newTestServer(t, DatabaseName(name))
+	replica := reopen(t, name)
+
+	admin := login(t, first, "adminuser", "adminpass")
+
+	// Rotating without a secret reveals the one generated
+	rec := serve(first, http.MethodPost, "/admin/jwt/rotate", admin, `{}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rotating: got %d %s", rec.Code, rec.Body)
+	}
+
+	var rotated struct {
+		Keys   []middleware.KeyInfo `json:"keys"`
+		Secret string               `json:"secret"`
+	}
+
+	err := json.Unmarshal(rec.Body.Bytes(), &rotated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rotated.Secret == "" || len(rotated.Keys) != 2 || !rotated.Keys[0].Current {
+		t.Fatalf("rotating: got %s, want the generated secret and the current and retired keys", rec.Body)
+	}
+
+	kid := rotated.Keys[0].ID
+
+	// Rotating to the current secret again is refused
+	rec = serve(first, http.MethodPost, "/admin/jwt/rotate", admin, `{"secret":"`+rotated.Secret+`"}`, nil)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("rotating to the current secret: got %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	// Tokens signed with the new key are accepted by the replica, and both the new and the retired key by a restart
+	user := login(t, first, "testuser", "testpass")
+
+	if code := serve(replica, http.MethodGet, "/api/v1/shifts", user, "", nil).Code; code != http.StatusOK {
+		t.Errorf("replica verifying a token signed with the new key: got %d, want %d", code, http.StatusOK)
+	}
+
+	restarted := reopen(t, name)
+
+	for key, token := range map[string]string{"new": user, "retired": admin} {
+		if code := serve(restarted, http.MethodGet, "/api/v1/shifts", token, "", nil).Code; code != http.StatusOK {
+			t.Errorf("restart verifying a token signed with the %s key: got %d, want %d", key, code, http.StatusOK)
+		}
+	}
+
+	keys, err := restarted.JWTKeys.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keys[0].ID != kid {
+		t.Errorf("restart signing with key %s, want %s", keys[0].ID, kid)
+	}
+
+	// The claims of a token signed with an unknown key are still refused
+	claims := jwt.MapClaims{}
+
+	_, _, err = new(jwt.Parser).ParseUnverified(user, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := middleware.NewKeySet("not the secret", 0).Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code := serve(restarted, http.MethodGet, "/api/v1/shifts", token, "", nil).Code; code != http.StatusUnauthorized {
+		t.Errorf("token signed with an unknown key: got %d, want %d", code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"bytes"
+	"github.com/btnmasher/shiftr/api/models"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogRedactsCalendarToken(t *testing.T) {
+	var buf bytes.Buffer
+
+	srv := newTestServer(t, WithLogOutput(&buf))
+
+	user, err := models.FindUserByName(srv.DB, "testuser")
+	if err != nil {
+		t.Fatalf("could not find user: %s", err)
+	}
+
+	feed := &models.CalendarFeed{UserID: user.ID}
+	err = feed.Create(srv.DB)
+	if err != nil {
+		t.Fatalf("could not create feed: %s", err)
+	}
+
+	for _, target := range []string{
+		"/calendar/" + feed.Token + ".ics",
+		"/calendar/" + feed.Token,
+		"/calendar/" + feed.Token + "?tz=UTC",
+		"/calendar/" + feed.Token[:16], // an unknown token is as secret as a known one
+	} {
+		rec := serve(srv, http.MethodGet, target, "", "", nil)
+		if rec.Code == http.StatusInternalServerError {
+			t.Fatalf("fetching %s: got %d %s", target, rec.Code, rec.Body)
+		}
+	}
+
+	if strings.Contains(buf.String(), feed.Token[:16]) {
+		t.Errorf("calendar token reached the log:\n%s", buf.String())
+	}
+
+	if !strings.Contains(buf.String(), `"uri":"/calendar/[REDACTED].ics"`) {
+		t.Errorf("access log of the feed not found:\n%s", buf.String())
+	}
+}
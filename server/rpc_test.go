@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/notify"
+	"github.com/btnmasher/shiftr/rpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net"
+	"testing"
+	"time"
+)
+
+// dialRPC serves the gRPC API of the server in memory, returning a client of its shift service
+func dialRPC(t *testing.T, srv *Server) rpc.ShiftServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	go srv.RPC.Serve(lis)
+	t.Cleanup(srv.RPC.Stop)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(
+		func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}))
+	if err != nil {
+		t.Fatalf("could not dial the gRPC server: %s", err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return rpc.NewShiftServiceClient(conn)
+}
+
+func TestRPCUpdateShift(t *testing.T) {
+	n := &notified{}
+	srv := newTestServer(t, ListenGRPCPort(1), WithNotifier(n))
+	client := dialRPC(t, srv)
+
+	as := func(name, pass string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(),
+			"authorization", "Bearer "+login(t, srv, name, pass))
+	}
+
+	admin, user := as("adminuser", "adminpass"), as("testuser", "testpass")
+
+	shift := createShift(t, srv, "testuser", 2)
+	shift.Metadata = models.Metadata{"source": "crm"}
+
+	err := srv.DB.Model(shift).Update("metadata", shift.Metadata).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := models.FindShiftByID(srv.DB, shift.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sid, _, err := shift.ExternalIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Users may not change the capacity of their shifts, as over the REST API
+	_, err = client.UpdateShift(user, &rpc.UpdateShiftRequest{
+		Shift: &rpc.Shift{Id: sid, Capacity: 3},
+		Etag:  current.ETag(),
+	})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("user raising the capacity: got %v, want PermissionDenied", err)
+	}
+
+	// Moving the shift keeps its metadata and lets its worker know
+	end := current.End.Add(time.Hour)
+
+	msg, err := client.UpdateShift(admin, &rpc.UpdateShiftRequest{
+		Shift: &rpc.Shift{Id: sid, End: timestamppb.New(end)},
+		Etag:  current.ETag(),
+	})
+	if err != nil {
+		t.Fatalf("rescheduling: %s", err)
+	}
+
+	if got := msg.GetMetadata().AsMap()["source"]; got != "crm" {
+		t.Errorf("rescheduled shift: got metadata source %v, want crm", got)
+	}
+
+	if events := n.events(shift.UserID); len(events) != 1 || events[0] != notify.EventShiftChanged {
+		t.Errorf("worker told %v of the rescheduled shift, want %s", events, notify.EventShiftChanged)
+	}
+
+	// Metadata given replaces the shift's own
+	metadata, err := structpb.NewStruct(map[string]interface{}{"source": "erp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err = client.UpdateShift(admin, &rpc.UpdateShiftRequest{
+		Shift: &rpc.Shift{Id: sid, Metadata: metadata},
+		Etag:  msg.Etag,
+	})
+	if err != nil {
+		t.Fatalf("replacing metadata: %s", err)
+	}
+
+	if got := msg.GetMetadata().AsMap()["source"]; got != "erp" {
+		t.Errorf("updated shift: got metadata source %v, want erp", got)
+	}
+}
@@ -1,30 +1,78 @@
 package server
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/btnmasher/shiftr/api/handlers"
 	"github.com/btnmasher/shiftr/api/middleware"
 	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/api/policy"
+	"github.com/btnmasher/shiftr/api/service"
+	"github.com/btnmasher/shiftr/events"
+	"github.com/btnmasher/shiftr/health"
+	"github.com/btnmasher/shiftr/i18n"
+	"github.com/btnmasher/shiftr/jobs"
+	"github.com/btnmasher/shiftr/logging"
+	"github.com/btnmasher/shiftr/notify"
+	"github.com/btnmasher/shiftr/opaque"
+	"github.com/btnmasher/shiftr/presence"
+	"github.com/btnmasher/shiftr/rpc"
+	"github.com/btnmasher/shiftr/secrets"
+	"github.com/btnmasher/shiftr/storage"
 	"github.com/labstack/echo/v4"
 	echomw "github.com/labstack/echo/v4/middleware"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/driver/sqlserver"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
-	"log"
+	"net"
+	"net/http"
 )
 
 type Server struct {
-	DB     *gorm.DB
-	API    *echo.Echo
-	Config *Config
+	DB       *gorm.DB
+	API      *echo.Echo
+	Config   *Config
+	Policies *policy.Registry
+	JWTKeys  *middleware.KeySet
+	Signer   *middleware.Signer
+	Presence *presence.Hub
+	Events   *events.Bus
+	Health   *health.Registry
+	Catalog  *i18n.Catalog   // messages are translated with, set by Initialize
+	Codec    *opaque.Codec   // mapping IDs to the external IDs exposed, nil when they are exposed unchanged
+	Shifts   *service.Shifts // making changes to shifts for both the http and the gRPC API, set by Initialize
+	RPC      *grpc.Server    // serving the gRPC API when a gRPC port is configured
+	Log      *zerolog.Logger // of everything the server runs, set by Initialize
+
+	models  []interface{} // extra models migrated with shiftr's own
+	digest  *notify.Digest
+	queues  []*notify.Queue
+	release func() // of the Codec registered for the process
 }
 
 func New() *Server {
-	return &Server{}
+	return &Server{
+		Policies: policy.NewRegistry(),
+		Health:   health.NewRegistry(),
+	}
+}
+
+// RegisterModels adds models of an application embedding the server to the startup migration,
+// so they are checked and migrated along with shiftr's own. It must be called before Initialize.
+func (s *Server) RegisterModels(models ...interface{}) {
+	s.models = append(s.models, models...)
+}
+
+// Echo returns the echo instance serving the API once the Server is initialized, so that it can be
+// mounted within another application, for instance as the http.Handler of a path prefix
+func (s *Server) Echo() *echo.Echo {
+	return s.API
 }
 
 // Initialize starts the Server, connecting to the database specified in the configuration
@@ -32,89 +80,751 @@ func New() *Server {
 func (s *Server) Initialize(config *Config) error {
 	s.Config = config
 
-	cfg := &gorm.Config{}
+	// Everything the server runs logs through its logger, from the debug level in debug mode unless set otherwise
+	if config.logger != nil {
+		s.Log = config.logger
+	} else {
+		logConfig := config.logging
+		if config.debug && logConfig.Level == "" {
+			logConfig.Level = "debug"
+		}
+
+		l, err := logging.New(logConfig)
+		if err != nil {
+			return fmt.Errorf("could not build logger: %s", err)
+		}
+
+		s.Log = &l
+	}
+
+	// Statements are logged with the ID of the request making them, every one of them in debug mode
+	cfg := &gorm.Config{Logger: dbLogger{log: s.Log, level: logger.Warn}}
 
 	if config.debug {
-		cfg.Logger = logger.Default.LogMode(logger.Info)
-		fmt.Printf("Configuration Initializing:\n%+v\n", *config)
+		cfg.Logger = cfg.Logger.LogMode(logger.Info)
+		s.Log.Debug().Msgf("configuration initializing: %+v", *config)
 	}
 
-	var err error
-	switch config.dbDriver {
-	case SqliteMem:
-		fallthrough
-	case Sqlite:
-		s.DB, err = gorm.Open(sqlite.Open(config.databaseUrl()), cfg)
-		break
-	case Postgres:
-		s.DB, err = gorm.Open(postgres.Open(config.databaseUrl()), cfg)
-		break
-	case Mysql:
-		s.DB, err = gorm.Open(mysql.Open(config.databaseUrl()), cfg)
-		break
-	case Sqlserver:
-		s.DB, err = gorm.Open(sqlserver.Open(config.databaseUrl()), cfg)
-		break
-	default:
-		return errors.New("unknown/unsupported database driver type specified")
+	// Load the column encryption keys before any data is read
+	var keyring *secrets.Keyring
+	if config.keys != nil {
+		var err error
+		keyring, err = secrets.LoadKeyring(context.Background(), config.keys)
+		if err != nil {
+			return fmt.Errorf("could not load encryption keys: %s", err)
+		}
+	}
+
+	if config.idKey != nil {
+		codec, err := opaque.NewCodec(config.idKey)
+		if err != nil {
+			return fmt.Errorf("could not load external id key: %s", err)
+		}
+
+		s.Codec = codec
+	}
+
+	// Every server translates with a catalog of its own, holding the bundled translations and those loaded for it
+	s.Catalog = i18n.New()
+	if config.catalogDir != "" {
+		if err := s.Catalog.LoadDir(config.catalogDir); err != nil {
+			return fmt.Errorf("could not load message catalogs: %s", err)
+		}
+	}
+
+	// Optional dependencies are called behind circuit breakers, so their outages degrade the features using them
+	// instead of failing requests. Notifications are queued while their notifier is down.
+	if config.notifier == nil {
+		config.notifier = notify.LogNotifier{Log: s.Log}
+	}
+
+	config.notifier = s.guardNotifier("notifier", config.notifier)
+
+	for i, ch := range config.channels {
+		config.channels[i].Notifier = s.guardNotifier("broadcast:"+ch.Name, ch.Notifier)
+	}
+
+	storeBreaker := health.NewBreaker("blob_store", config.breakerThreshold, config.breakerCooldown)
+	storeBreaker.Log = s.Log
+	s.Health.Register(storeBreaker)
+	config.blobStore = &storage.Guard{Store: config.blobStore, Breaker: storeBreaker}
+
+	// Notifications are digested in front of the configured notifier, which every handler and job then uses
+	if config.digestWindow > 0 {
+		s.digest = notify.NewDigest(config.notifier, config.digestWindow, config.digestImmediate...)
+		s.digest.Catalog = s.Catalog
+		s.digest.Log = s.Log
+		config.notifier = s.digest
+	}
+
+	if err := config.schedulingRules.Validate(); err != nil {
+		return fmt.Errorf("invalid scheduling rules: %s", err)
+	}
+
+	if config.registration {
+		if err := config.signupPolicy().Validate(); err != nil {
+			return fmt.Errorf("invalid registration settings: %s", err)
+		}
+	}
+
+	if !models.ValidCurrency(config.currency) {
+		return fmt.Errorf("invalid currency: %q is not an ISO 4217 code", config.currency)
+	}
+
+	// Avatars are served by the server itself unless they are fronted elsewhere
+	avatarBase := config.basePath + "/avatars/"
+	switch {
+	case config.avatarBaseURL != "":
+		avatarBase = config.avatarBaseURL
+	case config.publicURL != "":
+		avatarBase = config.publicURL + "/avatars/"
+	}
+
+	var db *gorm.DB
+	if config.db != nil {
+		db = config.db
+	} else {
+		var err error
+		db, err = s.connect(cfg)
+		if err != nil {
+			return err
+		}
 	}
 
+	// Changes to shifts are published for the clients watching them, and for an embedding application to subscribe to
+	s.Events = events.NewBus()
+
+	// The models apply the settings of the server, and encrypt with its keys, through its own handle of the database,
+	// so that servers sharing a process or a database do not apply each other's
+	db, err := secrets.Configure(db, keyring)
 	if err != nil {
-		return fmt.Errorf("could not connect to %s database: %s", config.dbDriver, err)
+		return fmt.Errorf("could not configure column encryption: %s", err)
 	}
 
-	log.Printf("connected to the %s database successfully", config.dbDriver)
+	s.DB = models.Configure(db, models.Settings{
+		ShiftLimits:     config.shiftLimits,
+		SchedulingRules: config.schedulingRules,
+		PunchRounding:   config.punchRounding,
+		Currency:        config.currency,
+		AvatarBaseURL:   avatarBase,
+		EventBus:        s.Events,
+		Catalog:         s.Catalog,
+	})
+
+	// A read-only database cannot be migrated, so it is used as it stands
+	if config.readOnly {
+		s.Log.Info().Msgf("read-only mode enabled, skipping %s database migration", config.dbDriver)
+	} else {
+		changes, err := models.Migrate(s.DB, config.allowDestructive, s.models...) //database migration
+		if err != nil {
+			return fmt.Errorf("could not automigrate models: %s", err)
+		}
+
+		for _, change := range changes {
+			s.Log.Warn().Msgf("destructive migration change allowed: %s", change)
+		}
 
-	err = s.DB.AutoMigrate(&models.User{}, &models.Shift{}) //database migration
+		s.Log.Info().Msgf("migrated %s database models successfully", config.dbDriver)
+	}
+
+	// Signing keys are kept in the database, so rotations outlive restarts and reach every server sharing it
+	keys, err := middleware.LoadKeySet(s.DB, config.JwtSecret, config.jwtGrace, !config.readOnly)
 	if err != nil {
-		return fmt.Errorf("could not automigrate models: %s", err)
+		return fmt.Errorf("could not load jwt signing keys: %s", err)
 	}
 
-	log.Printf("migrated %s database models successfully", config.dbDriver)
+	s.JWTKeys = keys
+	s.Presence = presence.NewHub()
+
+	if config.signSecret != "" {
+		s.Signer = middleware.NewSigner(config.signSecret, config.signWindow)
+	}
 
 	s.API = echo.New()
 	s.API.HideBanner = true
+	s.API.Logger = logging.Echo(*s.Log)
 	s.API.Debug = config.debug
 	s.API.Server.ReadTimeout = config.readtimeout
 	s.API.Server.WriteTimeout = config.writetimeout
 
+	// Event streams extend the write deadline of their connection past the write timeout
+	s.API.Server.ConnContext = s.ConnContext
+
+	// Pay data is only ever shown to admins, by every version of the API unless its serializer replaces it
+	serializer := middleware.VersionSerializer{
+		Default:  middleware.PaySerializer{},
+		Versions: make(map[string]echo.JSONSerializer, len(config.versions)),
+	}
+	for _, version := range config.versions {
+		serializer.Versions[version.Name] = version.Serializer
+	}
+
+	// Lists are answered with only the fields requested, selected from what the version would answer with
+	s.API.JSONSerializer = middleware.FieldsSerializer{JSONSerializer: serializer}
+
+	// Errors returned by models are answered with the status matching their class, in the request's locale,
+	// identified by the ID of the request
+	s.API.HTTPErrorHandler = func(err error, c echo.Context) {
+		err = handlers.LocalizeError(handlers.HTTPError(err), s.Catalog, handlers.RequestLocale(c))
+		rid, _ := c.Get("requestid").(string)
+
+		s.API.DefaultHTTPErrorHandler(handlers.IdentifyError(err, rid, s.API.Debug), c)
+	}
+
+	// Requests are identified before anything else, so every line logged for them carries the ID
+	s.API.Use(middleware.RequestID)
+
 	s.API.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			c.Set("jwtsecret", config.JwtSecret)
-			c.Set("db", s.DB)
+			c.Set("jwtkeys", s.JWTKeys)
+			// The database handle carries the request ID to the database log, but not the cancellation of the
+			// request, which would leave the writes of a client going away half made
+			c.Set("db", s.DB.WithContext(middleware.WithRequestID(context.Background(), c.Get("requestid").(string))))
+			c.Set("basepath", s.Config.basePath)
+			c.Set("publicurl", s.Config.publicURL)
 			return next(c)
 		}
 	})
 
-	s.API.Use(echomw.Logger())
+	s.API.Use(middleware.AccessLog)
+
+	if config.rateLimit > 0 && config.rateWindow > 0 {
+		s.API.Use(middleware.NewRateLimiter(config.rateLimit, config.rateWindow).Limit)
+	}
+
+	if config.compression != nil {
+		s.API.Use(middleware.Compress(*config.compression))
+	}
+
+	if config.readOnly {
+		exempt := []string{http.MethodPost + " " + config.basePath + "/login"}
+		for _, version := range config.versions {
+			exempt = append(exempt,
+				http.MethodPost+" "+config.basePath+"/api/"+version.Name+"/validate/user",
+				http.MethodPost+" "+config.basePath+"/api/"+version.Name+"/validate/shift",
+			)
+		}
+
+		s.API.Use(middleware.ReadOnly(exempt...))
+	}
+
+	// Fault injection is strictly a testing aid and never runs outside of debug mode
+	if len(config.faults) > 0 {
+		if config.debug {
+			s.Log.Warn().Msgf("fault injection enabled with %d rules", len(config.faults))
+			s.API.Use(middleware.NewFaultInjector(config.faults...).Inject)
+		} else {
+			s.Log.Warn().Msg("fault injection rules ignored, debug mode is disabled")
+		}
+	}
+
+	// Both the http and the gRPC API make changes to shifts through the same service
+	s.Shifts = &service.Shifts{
+		Notifier: config.notifier,
+		Approval: config.approval,
+		Log:      s.Log,
+	}
 
 	s.initRoutes()
 
+	// The gRPC API shares the database, signing keys and shift service of the http API
+	if config.grpcPort > 0 {
+		rpcServer := &rpc.Server{
+			DB:       s.DB,
+			Keys:     s.JWTKeys,
+			Shifts:   s.Shifts,
+			ReadOnly: config.readOnly,
+			Log:      s.Log,
+		}
+
+		s.RPC = rpcServer.GRPC()
+	}
+
+	// External IDs are encoded by the models themselves, so the codec is shared by every server in the process
+	release, err := opaque.Register(s.Codec)
+	if err != nil {
+		return fmt.Errorf("could not use external id key: %w", err)
+	}
+
+	s.release = release
+
 	return nil
 }
 
+// ConnContext adds the connection to the context of the requests read from it, so that event streams can extend its
+// write deadline past the write timeout. An application serving the Echo instance on its own http.Server sets this
+// as the ConnContext of that server.
+func (s *Server) ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	return handlers.ConnContext(ctx, conn)
+}
+
+// Close releases the external ID codec the server registered for the process, so that another server using a
+// different key may be initialized once this one no longer serves requests
+func (s *Server) Close() {
+	if s.release != nil {
+		s.release()
+		s.release = nil
+	}
+}
+
+// guardNotifier returns the notifier behind a circuit breaker registered under the name, queueing its messages
+// while it is down
+func (s *Server) guardNotifier(name string, n notify.Notifier) notify.Notifier {
+	breaker := health.NewBreaker(name, s.Config.breakerThreshold, s.Config.breakerCooldown)
+	breaker.Log = s.Log
+	s.Health.Register(breaker)
+
+	queue := notify.NewQueue(n, breaker, notify.DefaultQueueLimit)
+	queue.Log = s.Log
+	s.queues = append(s.queues, queue)
+
+	return queue
+}
+
+// connect opens the database specified in the configuration
+func (s *Server) connect(cfg *gorm.Config) (*gorm.DB, error) {
+	config := s.Config
+
+	var db *gorm.DB
+	var err error
+	switch config.dbDriver {
+	case SqliteMem:
+		fallthrough
+	case Sqlite:
+		db, err = gorm.Open(sqlite.Open(config.databaseUrl()), cfg)
+		break
+	case Postgres:
+		db, err = gorm.Open(postgres.Open(config.databaseUrl()), cfg)
+		break
+	case Mysql:
+		db, err = gorm.Open(mysql.Open(config.databaseUrl()), cfg)
+		break
+	case Sqlserver:
+		db, err = gorm.Open(sqlserver.Open(config.databaseUrl()), cfg)
+		break
+	default:
+		return nil, errors.New("unknown/unsupported database driver type specified")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to %s database: %s", config.dbDriver, err)
+	}
+
+	s.Log.Info().Msgf("connected to the %s database successfully", config.dbDriver)
+
+	return db, nil
+}
+
 func (s *Server) initRoutes() {
-	s.API.POST("/login", middleware.Login)
+	// Every route is served under the base path, which is empty unless the server is mounted elsewhere
+	root := s.API.Group(s.Config.basePath)
+
+	// Logins are recorded in the audit trail, unless the database cannot be written to
+	login := middleware.Login
+	if !s.Config.readOnly {
+		login = middleware.Audit(login)
+	}
+
+	s.handle(root, http.MethodPost, "/login", login, policy.Public)
+	s.handle(root, http.MethodGet, "/readyz", handlers.Readiness(s.Health), policy.Public)
+
+	s.handle(root, http.MethodGet, "/calendar/:token", handlers.RenderCalendar(), policy.Public)
+	s.handle(root, http.MethodGet, "/email/verify", handlers.VerifyEmailChange(), policy.Public)
+	s.handle(root, http.MethodPost, "/invitation", handlers.AcceptInvitation(), policy.Public)
+	s.handle(root, http.MethodGet, "/avatars/:name", handlers.GetAvatar(s.Config.blobStore), policy.Public)
+
+	// Self-registration is only exposed when enabled
+	if s.Config.registration {
+		s.handle(root, http.MethodPost, "/register", handlers.Register(s.Config.signupPolicy(), s.Config.notifier), policy.Public)
+		s.handle(root, http.MethodGet, "/register/verify", handlers.VerifyRegistration(), policy.Public)
+	}
 
-	// Wrap the /api/v1 route in JWT auth
-	g := s.API.Group("/api/v1")
-	g.Use(echomw.JWT([]byte(s.Config.JwtSecret)))
+	// Tokens are verified against the current and retired signing keys
+	jwtAuth := echomw.JWTWithConfig(echomw.JWTConfig{
+		KeyFunc: s.JWTKeys.Keyfunc,
+	})
+
+	// Every version of the API is served by the same handlers, wrapped in JWT auth
+	for _, version := range s.Config.versions {
+		versioned := middleware.Versioned(version)
+
+		// Routes other than websockets and event streams map external IDs, are audited and negotiate their format
+		api := []echo.MiddlewareFunc{
+			middleware.ExternalIDs,
+			middleware.Audit,
+			middleware.Negotiate(s.Config.renderers),
+		}
 
+		g := root.Group("/api/"+version.Name, versioned)
+		g.Use(jwtAuth)
+		g.Use(api...)
+
+		// Browsers cannot give websockets or event streams headers, so their routes also take the token as a query
+		// parameter
+		live := root.Group("/api/"+version.Name, versioned, echomw.JWTWithConfig(echomw.JWTConfig{
+			KeyFunc:     s.JWTKeys.Keyfunc,
+			TokenLookup: "header:" + echo.HeaderAuthorization + ",query:token",
+		}))
+
+		s.apiRoutes(g, live, api)
+	}
+
+	// Wrap the /admin route in JWT auth
+	a := root.Group("/admin")
+	a.Use(jwtAuth)
+	a.Use(middleware.Audit)
+
+	s.handle(a, http.MethodGet, "/route-permissions", handlers.ListRoutePermissions(s.Policies), policy.Admin)
+	s.handle(a, http.MethodGet, "/access-report", handlers.AccessReport(s.Policies), policy.Admin)
+	s.handle(a, http.MethodGet, "/db-stats", handlers.DatabaseStats(), policy.Admin)
+	s.handle(a, http.MethodGet, "/audit", handlers.ExportAudit(), policy.Admin)
+	s.handle(a, http.MethodGet, "/audit/verify", handlers.VerifyAudit(), policy.Admin)
+	s.handle(a, http.MethodGet, "/rules/violations", handlers.ListRuleViolations(), policy.Admin)
+	s.handle(a, http.MethodPost, "/users/bulk-update", handlers.BulkUpdateUsers(), policy.Privileged)
+	s.handle(a, http.MethodGet, "/jwt/keys", handlers.ListJWTKeys(s.JWTKeys), policy.Admin)
+	s.handle(a, http.MethodPost, "/jwt/rotate", handlers.RotateJWTSecret(s.JWTKeys), policy.Privileged)
+	s.handle(a, http.MethodPost, "/encryption/rotate", handlers.RotateEncryption(), policy.Privileged)
+	s.handle(a, http.MethodGet, "/analytics/exports", handlers.ListAnalyticsExports(s.Config.blobStore), policy.Admin)
+	s.handle(a, http.MethodGet, "/analytics/exports/*", handlers.GetAnalyticsExport(s.Config.blobStore), policy.Admin)
+}
+
+// apiRoutes registers the routes of a version of the API on its group, with its websockets on their own group and
+// the middleware of the API group given for the routes of both
+func (s *Server) apiRoutes(g, live router, api []echo.MiddlewareFunc) {
 	// User-role accessible endpoints
-	g.GET("/shifts", handlers.ListShifts(), middleware.UserAccessible)
-	g.GET("/shifts/:id", handlers.GetShift(), middleware.UserAccessible)
-	g.POST("/shifts", handlers.CreateShift(), middleware.UserAccessible)
-	g.PUT("/shifts/:id", handlers.UpdateShift(), middleware.UserAccessible)
-	g.DELETE("/shifts/:id", handlers.DeleteShift(), middleware.UserAccessible)
-	g.GET("/users/:id", handlers.GetUserByID(), middleware.UserAccessible)
-	g.PUT("/users/:id", handlers.UpdateUser(), middleware.UserAccessible)
+	s.handle(g, http.MethodGet, "/shifts", handlers.ListShifts(s.Config.reportWeeks), policy.User)
+	s.handle(g, http.MethodGet, "/shifts/export", handlers.ExportShifts(s.Config.reportWeeks), policy.User)
+	s.handle(g, http.MethodGet, "/shifts/pending", handlers.ListPendingShifts(), policy.User)
+	s.handle(g, http.MethodGet, "/shifts/:id", handlers.GetShift(), policy.User)
+	s.handle(g, http.MethodPost, "/shifts", handlers.CreateShift(s.Shifts), policy.User)
+	s.handle(g, http.MethodPut, "/shifts/:id", handlers.UpdateShift(s.Shifts), policy.User)
+	s.handle(g, http.MethodPatch, "/shifts/:id", handlers.PatchShift(s.Shifts), policy.User)
+	s.handle(g, http.MethodPost, "/batch", handlers.RunBatch(s.Shifts), policy.Admin)
+	s.handle(g, http.MethodDelete, "/shifts/:id", handlers.DeleteShift(s.Shifts), policy.User)
+	s.handle(g, http.MethodPost, "/shifts/:id/restore", handlers.RestoreShift(s.Shifts), policy.User)
+	s.handle(g, http.MethodPost, "/shifts/:id/approve", handlers.ApproveShift(s.Config.notifier), policy.User)
+	s.handle(g, http.MethodPost, "/shifts/:id/reject", handlers.RejectShift(s.Config.notifier), policy.User)
+	s.handle(g, http.MethodGet, "/shifts/:id/reminders", handlers.ListShiftReminders(), policy.User)
+	s.handle(g, http.MethodGet, "/shifts/:id/bidding", handlers.GetBidding(), policy.User)
+	s.handle(g, http.MethodPut, "/shifts/:id/bidding", handlers.OpenBidding(), policy.Admin)
+	s.handle(g, http.MethodGet, "/shifts/:id/bids", handlers.ListShiftBids(), policy.User)
+	s.handle(g, http.MethodPost, "/shifts/:id/bids", handlers.PlaceBid(), policy.User)
+	s.handle(g, http.MethodDelete, "/shifts/:id/bids", handlers.WithdrawBid(), policy.User)
+	s.handle(g, http.MethodPost, "/shifts/:id/bids/award", handlers.AwardBid(s.Config.notifier), policy.Admin)
+	s.handle(g, http.MethodGet, "/shifts/:id/handover", handlers.GetHandover(), policy.User)
+	s.handle(g, http.MethodPut, "/shifts/:id/handover", handlers.WriteHandover(s.Config.notifier), policy.User)
+	s.handle(g, http.MethodGet, "/shifts/:id/handover/incoming", handlers.GetIncomingHandover(), policy.User)
+	s.handle(g, http.MethodPost, "/shifts/:id/handover/incoming/acknowledge", handlers.AcknowledgeHandover(), policy.User)
+	s.handle(g, http.MethodGet, "/shifts/:id/label", handlers.ShiftLabel(), policy.User)
+	s.handle(g, http.MethodGet, "/schedule", handlers.GetSchedule(), policy.User)
+	s.handle(g, http.MethodGet, "/roster/label", handlers.RosterLabel(), policy.Admin)
+	s.handle(g, http.MethodPost, "/validate/user", handlers.ValidateUser(), policy.User)
+	s.handle(g, http.MethodPost, "/validate/shift", handlers.ValidateShift(), policy.User)
+	s.handle(live, http.MethodGet, "/schedule/presence", handlers.WatchSchedule(s.Presence), policy.User)
+	s.handle(live, http.MethodGet, "/ws", handlers.WatchShifts(s.Events), policy.User)
+	s.handle(live, http.MethodGet, "/events/stream", handlers.StreamEvents(s.Events), policy.User)
+
+	// The event stream is also served at /events to requests asking for it, where event mode lists the event signups
+	// to the others through the middleware of the API group
+	var signups echo.HandlerFunc
+	if s.Config.eventMode {
+		signups = handlers.ListEvents()
+		for i := len(api) - 1; i >= 0; i-- {
+			signups = api[i](signups)
+		}
+	}
+
+	s.handle(live, http.MethodGet, "/events", handlers.StreamOr(handlers.StreamEvents(s.Events), signups), policy.User)
+	s.handle(g, http.MethodGet, "/users/batch", handlers.FetchUsers(), policy.User)
+	s.handle(g, http.MethodGet, "/users/directory", handlers.ListDirectory(), policy.User)
+	s.handle(g, http.MethodGet, "/search", handlers.Search(), policy.User)
+	s.handle(g, http.MethodGet, "/me", handlers.Me(handlers.GetUserByID()), policy.User)
+	s.handle(g, http.MethodGet, "/me/shifts", handlers.ListMyShifts(s.Config.reportWeeks), policy.User)
+	s.handle(g, http.MethodGet, "/users/:id", handlers.GetUserByID(), policy.User)
+	s.handle(g, http.MethodPut, "/users/:id", handlers.UpdateUser(), policy.User)
+	s.handle(g, http.MethodPatch, "/users/:id", handlers.PatchUser(), policy.User)
+	s.handle(g, http.MethodPost, "/users/:id/email", handlers.RequestEmailChange(s.Config.notifier), policy.User)
+	s.handle(g, http.MethodPut, "/users/:id/avatar", handlers.UploadAvatar(s.Config.blobStore, s.Config.avatarMaxSize), policy.User)
+	s.handle(g, http.MethodDelete, "/users/:id/avatar", handlers.DeleteAvatar(s.Config.blobStore), policy.User)
+	s.handle(g, http.MethodGet, "/users/:id/reports", handlers.ListDirectReports(), policy.User)
+	s.handle(g, http.MethodGet, "/users/:id/delegations", handlers.ListDelegations(), policy.User)
+	s.handle(g, http.MethodPost, "/users/:id/delegations", handlers.CreateDelegation(), policy.User)
+	s.handle(g, http.MethodDelete, "/users/:id/delegations/:did", handlers.DeleteDelegation(), policy.User)
+	s.handle(g, http.MethodGet, "/users/:id/activity", handlers.ListUserActivity(), policy.User)
+	s.handle(g, http.MethodGet, "/users/:id/certifications", handlers.ListCertifications(), policy.User)
+	s.handle(g, http.MethodPost, "/users/:id/certifications", handlers.CreateCertification(), policy.Admin)
+	s.handle(g, http.MethodPut, "/users/:id/certifications/:cid", handlers.UpdateCertification(), policy.Admin)
+	s.handle(g, http.MethodDelete, "/users/:id/certifications/:cid", handlers.DeleteCertification(), policy.Privileged)
+	s.handle(g, http.MethodGet, "/users/:id/pay-rates", handlers.ListPayRates(), policy.Admin)
+	s.handle(g, http.MethodPut, "/users/:id/pay-rates", handlers.SetPayRates(), policy.Admin)
+	s.handle(g, http.MethodGet, "/users/:id/leave", handlers.GetLeaveBalance(), policy.User)
+	s.handle(g, http.MethodPut, "/users/:id/leave", handlers.SetLeaveAccount(), policy.Admin)
+	s.handle(g, http.MethodGet, "/users/:id/timeoff", handlers.ListTimeOff(), policy.User)
+	s.handle(g, http.MethodPost, "/users/:id/timeoff", handlers.RequestTimeOff(s.Config.notifier), policy.User)
+	s.handle(g, http.MethodGet, "/timeoff/pending", handlers.ListPendingTimeOff(), policy.User)
+	s.handle(g, http.MethodPost, "/timeoff/:id/approve", handlers.DecideTimeOff(true, s.Config.notifier), policy.User)
+	s.handle(g, http.MethodPost, "/timeoff/:id/reject", handlers.DecideTimeOff(false, s.Config.notifier), policy.User)
+	s.handle(g, http.MethodGet, "/users/:id/calendar", handlers.GetCalendarFeed(), policy.User)
+	s.handle(g, http.MethodPost, "/users/:id/calendar", handlers.CreateCalendarFeed(), policy.User)
+	s.handle(g, http.MethodGet, "/users/:id/preferences", handlers.GetPreferences(), policy.User)
+	s.handle(g, http.MethodPut, "/users/:id/preferences", handlers.UpdatePreferences(), policy.User)
+
+	// Event signups are only exposed when event mode is enabled
+	if s.Config.eventMode {
+		s.handle(g, http.MethodPost, "/shifts/:id/signup", handlers.SignUp(), policy.User)
+		s.handle(g, http.MethodDelete, "/shifts/:id/signup", handlers.Withdraw(s.Shifts), policy.User)
+		s.handle(g, http.MethodGet, "/shifts/:id/signups", handlers.ListSignups(), policy.Admin)
+		s.handle(g, http.MethodPost, "/shifts/:id/waitlist", handlers.JoinWaitlist(), policy.User)
+		s.handle(g, http.MethodDelete, "/shifts/:id/waitlist", handlers.LeaveWaitlist(), policy.User)
+		s.handle(g, http.MethodGet, "/shifts/:id/waitlist", handlers.ListWaitlist(), policy.Admin)
+	}
 
 	// Admin-role accessible endpoints
-	g.GET("/users", handlers.ListUsers(), middleware.AdminAccessible)
-	g.POST("/users", handlers.CreateUser(), middleware.AdminAccessible)
-	g.DELETE("/users/:id", handlers.DeleteUser(), middleware.AdminAccessible)
+	s.handle(g, http.MethodGet, "/users", handlers.ListUsers(), policy.Admin)
+	s.handle(g, http.MethodPost, "/users", handlers.CreateUser(), policy.Admin)
+	s.handle(g, http.MethodPost, "/users/import", handlers.ImportUsers(s.Config.notifier), policy.Admin)
+	s.handle(g, http.MethodDelete, "/users/:id", handlers.DeactivateUser(), policy.Privileged)
+	s.handle(g, http.MethodPost, "/users/:id/reactivate", handlers.ReactivateUser(), policy.Privileged)
+	s.handle(g, http.MethodPost, "/schedules/publish", handlers.PublishSchedule(s.Config.notifier), policy.Admin)
+	s.handle(g, http.MethodPost, "/shifts/generate", handlers.GenerateShifts(), policy.Admin)
+	s.handle(g, http.MethodPost, "/shifts/import", handlers.ImportShifts(), policy.Admin)
+	s.handle(g, http.MethodPost, "/shifts/import/:format", handlers.MigrateShifts(), policy.Admin)
+	s.handle(g, http.MethodGet, "/teams", handlers.ListTeams(), policy.Admin)
+	s.handle(g, http.MethodPost, "/teams", handlers.CreateTeam(), policy.Admin)
+	s.handle(g, http.MethodPut, "/teams/:id", handlers.UpdateTeam(), policy.Admin)
+	s.handle(g, http.MethodDelete, "/teams/:id", handlers.DeleteTeam(), policy.Privileged)
+	s.handle(g, http.MethodPost, "/teams/:id/calendar", handlers.CreateTeamCalendarFeed(), policy.Admin)
+	s.handle(g, http.MethodGet, "/groups", handlers.ListGroups(), policy.Admin)
+	s.handle(g, http.MethodPost, "/groups", handlers.CreateGroup(), policy.Admin)
+	s.handle(g, http.MethodGet, "/groups/:id", handlers.GetGroup(), policy.Admin)
+	s.handle(g, http.MethodPut, "/groups/:id", handlers.UpdateGroup(), policy.Admin)
+	s.handle(g, http.MethodDelete, "/groups/:id", handlers.DeleteGroup(), policy.Privileged)
+	s.handle(g, http.MethodPut, "/groups/:id/members", handlers.SetGroupMembers(), policy.Admin)
+	s.handle(g, http.MethodPost, "/groups/:id/shifts", handlers.CreateGroupShifts(), policy.Admin)
+	s.handle(g, http.MethodGet, "/locations", handlers.ListLocations(), policy.Admin)
+	s.handle(g, http.MethodPost, "/locations", handlers.CreateLocation(), policy.Admin)
+	s.handle(g, http.MethodPut, "/locations/:id", handlers.UpdateLocation(), policy.Admin)
+	s.handle(g, http.MethodDelete, "/locations/:id", handlers.DeleteLocation(), policy.Privileged)
+	s.handle(g, http.MethodGet, "/holidays", handlers.ListHolidays(), policy.Admin)
+	s.handle(g, http.MethodPost, "/holidays", handlers.CreateHoliday(), policy.Admin)
+	s.handle(g, http.MethodPut, "/holidays/:id", handlers.UpdateHoliday(), policy.Admin)
+	s.handle(g, http.MethodDelete, "/holidays/:id", handlers.DeleteHoliday(), policy.Privileged)
+	s.handle(g, http.MethodGet, "/differentials", handlers.ListDifferentials(), policy.Admin)
+	s.handle(g, http.MethodPost, "/differentials", handlers.CreateDifferential(), policy.Admin)
+	s.handle(g, http.MethodDelete, "/differentials/:id", handlers.DeleteDifferential(), policy.Privileged)
+	s.handle(g, http.MethodGet, "/leave/policies", handlers.ListLeavePolicies(), policy.Admin)
+	s.handle(g, http.MethodPost, "/leave/policies", handlers.CreateLeavePolicy(), policy.Admin)
+	s.handle(g, http.MethodDelete, "/leave/policies/:id", handlers.DeleteLeavePolicy(), policy.Privileged)
+	s.handle(g, http.MethodGet, "/positions", handlers.ListPositions(), policy.Admin)
+	s.handle(g, http.MethodPost, "/positions", handlers.CreatePosition(), policy.Admin)
+	s.handle(g, http.MethodPut, "/positions/:id", handlers.UpdatePosition(), policy.Admin)
+	s.handle(g, http.MethodDelete, "/positions/:id", handlers.DeletePosition(), policy.Privileged)
+	s.handle(g, http.MethodGet, "/custom-fields", handlers.ListCustomFields(), policy.User)
+	s.handle(g, http.MethodPost, "/custom-fields", handlers.CreateCustomField(), policy.Admin)
+	s.handle(g, http.MethodPut, "/custom-fields/:name", handlers.UpdateCustomField(), policy.Admin)
+	s.handle(g, http.MethodDelete, "/custom-fields/:name", handlers.DeleteCustomField(), policy.Privileged)
+	s.handle(g, http.MethodGet, "/rotations", handlers.ListRotations(), policy.Admin)
+	s.handle(g, http.MethodPost, "/rotations", handlers.CreateRotation(), policy.Admin)
+	s.handle(g, http.MethodGet, "/rotations/:id", handlers.GetRotation(), policy.Admin)
+	s.handle(g, http.MethodDelete, "/rotations/:id", handlers.DeleteRotation(), policy.Privileged)
+	s.handle(g, http.MethodPut, "/rotations/:id/members", handlers.SetRotationMembers(), policy.Admin)
+	s.handle(g, http.MethodPost, "/rotations/:id/generate", handlers.GenerateRotationShifts(), policy.Admin)
+	s.handle(g, http.MethodGet, "/reports/hours", handlers.HoursReport(s.Config.reportWeeks), policy.Admin)
+	s.handle(g, http.MethodGet, "/admin/stats", handlers.AdminStats(s.Config.reportWeeks), policy.Admin)
+	s.handle(g, http.MethodGet, "/reports/churn", handlers.ChurnReport(s.Config.reportWeeks), policy.Admin)
+	s.handle(g, http.MethodGet, "/reports/attendance", handlers.AttendanceReport(), policy.User)
+	s.handle(g, http.MethodGet, "/reports/reconciliation", handlers.ReconciliationReport(), policy.User)
+	s.handle(g, http.MethodPost, "/reports/reconciliation", handlers.ReconcileDay(s.Config.reconcileVariance), policy.Admin)
+	s.handle(g, http.MethodGet, "/attendance", handlers.ListAttendance(), policy.User)
+	s.handle(g, http.MethodGet, "/timesheets", handlers.ListTimesheets(), policy.User)
+	s.handle(g, http.MethodPost, "/clock/in", handlers.ClockIn(), policy.User)
+	s.handle(g, http.MethodPost, "/clock/out", handlers.ClockOut(), policy.User)
+	s.handle(g, http.MethodGet, "/coverage", handlers.CoverageReport(), policy.Admin)
+	s.handle(g, http.MethodPost, "/coverage/simulate", handlers.SimulateCoverage(), policy.Admin)
+	s.handle(g, http.MethodGet, "/coverage/requirements", handlers.ListCoverageRequirements(), policy.Admin)
+	s.handle(g, http.MethodPost, "/coverage/requirements", handlers.CreateCoverageRequirement(), policy.Admin)
+	s.handle(g, http.MethodDelete, "/coverage/requirements/:id", handlers.DeleteCoverageRequirement(), policy.Privileged)
+	s.handle(g, http.MethodPost, "/broadcast", handlers.SendBroadcast(s.Config.broadcastChannels()), policy.Admin)
+	s.handle(g, http.MethodGet, "/broadcast/:id", handlers.GetBroadcast(), policy.Admin)
 }
 
-func (s *Server) Run() {
-	s.API.Logger.Fatal(s.API.Start(s.Config.serverURL()))
+// router is implemented by both echo.Echo and echo.Group
+type router interface {
+	Add(method, path string, handler echo.HandlerFunc, middleware ...echo.MiddlewareFunc) *echo.Route
+}
+
+// handle registers the handler behind the middleware enforcing the given policy
+// and records the policy so it can be enumerated and audited
+func (s *Server) handle(r router, method, path string, h echo.HandlerFunc, p policy.Policy) {
+	mw := []echo.MiddlewareFunc{middleware.Enforce(p)}
+
+	// Signatures are only checked when signing is configured, so record what is actually enforced
+	if p.Signed && s.Signer != nil {
+		mw = append(mw, s.Signer.Verify)
+	} else {
+		p.Signed = false
+	}
+
+	// Requests are localized once the user making them is known
+	mw = append(mw, middleware.Localize)
+
+	// Retries of a user's request with the same Idempotency-Key are answered with the response to the first
+	if method == http.MethodPost && !p.Public && s.Config.idempotency > 0 && !s.Config.readOnly {
+		mw = append(mw, middleware.Idempotency(s.Config.idempotency))
+	}
+
+	route := r.Add(method, path, h, mw...)
+	s.Policies.Declare(route.Method, route.Path, p)
+}
+
+// Run serves the API on the configured address and runs the background jobs until the context is cancelled,
+// then shuts the http server down gracefully. An error is returned if the server could not be started.
+func (s *Server) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.StartJobs(ctx)
+
+	// The gRPC listener is opened first, so a port in use fails the start before the http server is up
+	rpcErrs := make(chan error, 1)
+	if s.RPC != nil {
+		lis, err := net.Listen("tcp", s.Config.grpcURL())
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			rpcErrs <- s.RPC.Serve(lis)
+		}()
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.API.Start(s.Config.serverURL())
+	}()
+
+	select {
+	case err := <-errs:
+		s.stopRPC(context.Background())
+		return err
+	case err := <-rpcErrs:
+		s.API.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdown, done := context.WithTimeout(context.Background(), s.Config.writetimeout)
+	defer done()
+
+	s.stopRPC(shutdown)
+
+	err := s.API.Shutdown(shutdown)
+	if err != nil {
+		return err
+	}
+
+	// Pending digests would otherwise be lost
+	if s.digest != nil {
+		s.digest.Flush()
+	}
+
+	// Start returns once the server is closed
+	err = <-errs
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// stopRPC stops the gRPC server once its calls in progress finish, cutting them off when the context is done
+func (s *Server) stopRPC(ctx context.Context) {
+	if s.RPC == nil {
+		return
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.RPC.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.RPC.Stop()
+	}
+}
+
+// StartJobs launches the enabled background jobs, which run until the context is cancelled. Run starts them itself,
+// an application serving the Echo instance on its own calls this instead.
+func (s *Server) StartJobs(ctx context.Context) {
+	// Notifications held during an outage are retried once their notifier recovers
+	for _, queue := range s.queues {
+		go queue.Run(ctx)
+	}
+
+	if s.Config.analyticsInterval > 0 {
+		analytics := &jobs.AnalyticsExport{
+			DB:       s.DB,
+			Store:    s.Config.blobStore,
+			Interval: s.Config.analyticsInterval,
+			Log:      s.Log,
+		}
+
+		go analytics.Run(ctx)
+	}
+
+	// Every other job writes to the database
+	if s.Config.readOnly {
+		return
+	}
+
+	if s.Config.reminders {
+		reminders := &jobs.Reminders{
+			DB:       s.DB,
+			Notifier: s.Config.notifier,
+			Lead:     s.Config.reminderLead,
+			Log:      s.Log,
+		}
+
+		go reminders.Run(ctx)
+	}
+
+	// Shifts bid on under the seniority rule are awarded once bidding closes
+	awards := &jobs.BidAwards{
+		DB:       s.DB,
+		Notifier: s.Config.notifier,
+		Log:      s.Log,
+	}
+
+	go awards.Run(ctx)
+
+	// Shifts ending without their worker clocking in are flagged as no-shows
+	noShows := &jobs.NoShows{
+		DB:  s.DB,
+		Log: s.Log,
+	}
+
+	go noShows.Run(ctx)
+
+	// Responses kept to replay to retries are deleted once they expire
+	if s.Config.idempotency > 0 {
+		purge := &jobs.IdempotencyPurge{
+			DB:  s.DB,
+			Log: s.Log,
+		}
+
+		go purge.Run(ctx)
+	}
+
+	// Each day's shifts are reconciled with the time clocked for them overnight
+	reconciliation := &jobs.Reconciliation{
+		DB:       s.DB,
+		Variance: s.Config.reconcileVariance,
+		Location: s.Config.reconcileLocation,
+		Log:      s.Log,
+	}
+
+	go reconciliation.Run(ctx)
 }
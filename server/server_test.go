@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/api/policy"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestServer initializes a server on a database of its own, with the users adminuser and testuser
+func newTestServer(t *testing.T, opts ...ConfigOption) *Server {
+	t.Helper()
+
+	opts = append([]ConfigOption{
+		DatabaseDriver(Sqlite),
+		DatabaseName(filepath.Join(t.TempDir(), "shiftr")),
+		WithLogOutput(ioutil.Discard),
+	}, opts...)
+
+	srv := New()
+
+	err := srv.Initialize(NewConfig(opts...))
+	if err != nil {
+		t.Fatalf("could not initialize server: %s", err)
+	}
+
+	t.Cleanup(srv.Close)
+
+	for _, user := range []*models.User{
+		{Name: "adminuser", Password: "adminpass", Role: "admin"},
+		{Name: "testuser", Password: "testpass", Role: "user"},
+	} {
+		err = user.Create(srv.DB)
+		if err != nil {
+			t.Fatalf("could not create user %s: %s", user.Name, err)
+		}
+	}
+
+	return srv
+}
+
+// serve sends the request to the server, with the token as its bearer unless empty
+func serve(srv *Server, method, target, token, body string, header http.Header) *httptest.ResponseRecorder {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+
+	req := httptest.NewRequest(method, target, r)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.API.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// login returns a token of the user
+func login(t *testing.T, srv *Server, name, pass string) string {
+	t.Helper()
+
+	rec := serve(srv, http.MethodPost, "/login?user="+url.QueryEscape(name)+"&pass="+url.QueryEscape(pass), "", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("logging in as %s: got %d %s", name, rec.Code, rec.Body)
+	}
+
+	var res struct {
+		Token string `json:"token"`
+	}
+
+	err := json.Unmarshal(rec.Body.Bytes(), &res)
+	if err != nil || res.Token == "" {
+		t.Fatalf("logging in as %s: no token in %s", name, rec.Body)
+	}
+
+	return res.Token
+}
+
+func TestRoutesDeclarePolicies(t *testing.T) {
+	srv := New()
+
+	err := srv.Initialize(NewConfig(
+		DatabaseDriver(SqliteMem),
+		WithLogOutput(ioutil.Discard),
+	))
+	if err != nil {
+		t.Fatalf("could not initialize server: %s", err)
+	}
+
+	t.Cleanup(srv.Close)
+
+	policy.AssertDeclared(t, srv.API, srv.Policies)
+}
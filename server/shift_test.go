@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"github.com/btnmasher/shiftr/api/models"
+	"github.com/btnmasher/shiftr/notify"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// createShift creates a published shift of the user starting in days, in the database of the server
+func createShift(t *testing.T, srv *Server, name string, days int) *models.Shift {
+	t.Helper()
+
+	var uid string
+	if name != "" {
+		user, err := models.FindUserByName(srv.DB, name)
+		if err != nil {
+			t.Fatalf("could not find user %s: %s", name, err)
+		}
+
+		uid = user.ID
+	}
+
+	start := time.Now().AddDate(0, 0, days).Truncate(time.Hour)
+	shift := &models.Shift{UserID: uid, Start: start, End: start.Add(8 * time.Hour), Status: models.ShiftPublished}
+
+	err := shift.Create(srv.DB)
+	if err != nil {
+		t.Fatalf("could not create shift: %s", err)
+	}
+
+	return shift
+}
+
+// shiftPath returns the path of the shift in the API, by its external ID
+func shiftPath(t *testing.T, shift *models.Shift, suffix string) string {
+	t.Helper()
+
+	sid, _, err := shift.ExternalIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return "/api/v1/shifts/" + sid + suffix
+}
+
+func TestRestoreShift(t *testing.T) {
+	srv := newTestServer(t)
+	admin := login(t, srv, "adminuser", "adminpass")
+	user := login(t, srv, "testuser", "testpass")
+
+	cancel := func(token string, shift *models.Shift) {
+		t.Helper()
+
+		rec := serve(srv, http.MethodDelete, shiftPath(t, shift, ""), token, `{"reason":"sick"}`,
+			http.Header{"If-Match": {shift.ETag()}})
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("cancelling: got %d %s", rec.Code, rec.Body)
+		}
+	}
+
+	restore := func(token string, shift *models.Shift, etag string) int {
+		t.Helper()
+
+		header := http.Header{}
+		if etag != "" {
+			header.Set("If-Match", etag)
+		}
+
+		return serve(srv, http.MethodPost, shiftPath(t, shift, "/restore"), token, "", header).Code
+	}
+
+	// Users restore the shifts they cancelled, once they give the version cancelled
+	own := createShift(t, srv, "testuser", 2)
+	cancel(user, own)
+
+	if code := restore(user, own, ""); code != http.StatusPreconditionRequired {
+		t.Errorf("restoring without If-Match: got %d, want 428", code)
+	}
+
+	if code := restore(user, own, `"stale"`); code != http.StatusPreconditionFailed {
+		t.Errorf("restoring a stale version: got %d, want 412", code)
+	}
+
+	if code := restore(user, own, own.ETag()); code != http.StatusOK {
+		t.Errorf("restoring their own cancellation: got %d, want 200", code)
+	}
+
+	if code := restore(user, own, own.ETag()); code != http.StatusNotFound {
+		t.Errorf("restoring a shift no longer cancelled: got %d, want 404", code)
+	}
+
+	// Users may not restore what an admin cancelled
+	cancelled := createShift(t, srv, "testuser", 4)
+	cancel(admin, cancelled)
+
+	if code := restore(user, cancelled, cancelled.ETag()); code != http.StatusUnauthorized {
+		t.Errorf("restoring an admin's cancellation: got %d, want 401", code)
+	}
+
+	// Nor may anyone restore a shift over another of its worker since scheduled
+	createShift(t, srv, "testuser", 4)
+
+	if code := restore(admin, cancelled, cancelled.ETag()); code != http.StatusConflict {
+		t.Errorf("restoring over another shift: got %d, want 409", code)
+	}
+
+	restored, err := models.FindShiftByID(srv.DB, own.ID)
+	if err != nil {
+		t.Fatalf("restored shift not found: %s", err)
+	}
+
+	if restored.CancelReason != "" || restored.CancelledBy != "" {
+		t.Errorf("restored shift keeps its cancellation %q by %q", restored.CancelReason, restored.CancelledBy)
+	}
+
+	if _, err = models.FindShiftByID(srv.DB, cancelled.ID); err == nil {
+		t.Error("shift restored over another")
+	}
+}
+
+// notified collects the messages sent to users
+type notified struct {
+	mu       sync.Mutex
+	messages []notify.Message
+}
+
+func (n *notified) Notify(_ context.Context, msg notify.Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.messages = append(n.messages, msg)
+
+	return nil
+}
+
+// events returns the events of the messages sent to the user, waiting a while for any delivered asynchronously
+func (n *notified) events(uid string) []string {
+	var events []string
+
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		n.mu.Lock()
+		events = nil
+		for _, msg := range n.messages {
+			if msg.UserID == uid {
+				events = append(events, msg.Event)
+			}
+		}
+		n.mu.Unlock()
+
+		if len(events) > 0 || time.Now().After(deadline) {
+			return events
+		}
+	}
+}
+
+func TestRescheduleAwaitsApproval(t *testing.T) {
+	n := &notified{}
+	srv := newTestServer(t, ShiftApproval(true), WithNotifier(n))
+	user := login(t, srv, "testuser", "testpass")
+
+	admin, err := models.FindUserByName(srv.DB, "adminuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update := func(shift *models.Shift, body string) *models.Shift {
+		t.Helper()
+
+		rec := serve(srv, http.MethodPatch, shiftPath(t, shift, ""), user, body, http.Header{"If-Match": {shift.ETag()}})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("updating: got %d %s", rec.Code, rec.Body)
+		}
+
+		updated, err := models.FindShiftByID(srv.DB, shift.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return updated
+	}
+
+	// Changes leaving the time alone keep the shift published
+	shift := createShift(t, srv, "testuser", 2)
+
+	shift = update(shift, `{"color":"#1E90FF"}`)
+	if shift.Status != models.ShiftPublished {
+		t.Errorf("recolored shift: got status %s, want published", shift.Status)
+	}
+
+	if events := n.events(admin.ID); len(events) != 0 {
+		t.Errorf("approvers told of a recolored shift: %v", events)
+	}
+
+	// Moving it sends it back to the approvers
+	end := shift.End.Add(time.Hour).UTC().Format(time.RFC3339)
+
+	shift = update(shift, `{"end":"`+end+`"}`)
+	if shift.Status != models.ShiftPending {
+		t.Errorf("rescheduled shift: got status %s, want pending", shift.Status)
+	}
+
+	if events := n.events(admin.ID); len(events) != 1 || events[0] != notify.EventShiftApproval {
+		t.Errorf("approvers told %v of the rescheduled shift, want %s", events, notify.EventShiftApproval)
+	}
+}
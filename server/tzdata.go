@@ -0,0 +1,6 @@
+package server
+
+// The time zone database is embedded so that zone names given for schedules, rotations, holidays and
+// differentials resolve on hosts without one installed. A specific database may be pinned by setting
+// ZONEINFO to the path of a zoneinfo.zip, which is consulted before the host's and the embedded copy.
+import _ "time/tzdata"
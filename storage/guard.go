@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/btnmasher/shiftr/health"
+	"io"
+)
+
+// Guard is a BlobStore calling another behind a circuit breaker, so that while the store is down the uploads
+// and downloads needing it fail at once with health.ErrUnavailable instead of waiting on it. Objects which
+// do not exist are not failures of the store.
+type Guard struct {
+	Store   BlobStore
+	Breaker *health.Breaker
+}
+
+// Put stores the contents of r under the key unless the breaker is open
+func (g *Guard) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	return g.do(func() error {
+		return g.Store.Put(ctx, key, r, contentType)
+	})
+}
+
+// Get returns a reader for the object stored under the key unless the breaker is open
+func (g *Guard) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	var body io.ReadCloser
+
+	err := g.do(func() error {
+		var err error
+		body, err = g.Store.Get(ctx, key)
+		return err
+	})
+
+	return body, err
+}
+
+// List returns the objects with keys beginning with the prefix unless the breaker is open
+func (g *Guard) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+
+	err := g.do(func() error {
+		var err error
+		objects, err = g.Store.List(ctx, prefix)
+		return err
+	})
+
+	return objects, err
+}
+
+// Delete removes the object stored under the key unless the breaker is open
+func (g *Guard) Delete(ctx context.Context, key string) error {
+	return g.do(func() error {
+		return g.Store.Delete(ctx, key)
+	})
+}
+
+// do calls fn through the breaker, recording missing objects as successes since the store answered
+func (g *Guard) do(fn func() error) error {
+	err := g.Breaker.Allow()
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	if errors.Is(err, ErrNotFound) {
+		g.Breaker.Record(nil)
+	} else {
+		g.Breaker.Record(err)
+	}
+
+	return err
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LocalStore is a BlobStore which keeps objects as files beneath a directory on the local disk
+type LocalStore struct {
+	Dir string
+}
+
+// NewLocalStore returns a LocalStore rooted at the directory
+func NewLocalStore(dir string) *LocalStore {
+	return &LocalStore{Dir: dir}
+}
+
+// path resolves the key to a file path, rejecting keys which would escape the store directory
+func (s *LocalStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", errors.New("invalid object key")
+	}
+
+	return filepath.Join(s.Dir, filepath.FromSlash(clean)), nil
+}
+
+// Put stores the contents of r under the key, replacing any existing object
+func (s *LocalStore) Put(_ context.Context, key string, r io.Reader, _ string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(name), 0o755)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never observe a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+// Get returns a reader for the object stored under the key, the caller must close it
+func (s *LocalStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// List returns the objects with keys beginning with the prefix, ordered by key
+func (s *LocalStore) List(_ context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+
+	err := filepath.WalkDir(s.Dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.Dir, name)
+		if err != nil {
+			return err
+		}
+
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, Object{
+			Key:      key,
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+
+	return objects, nil
+}
+
+// Delete removes the object stored under the key
+func (s *LocalStore) Delete(_ context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+
+	return err
+}
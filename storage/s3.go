@@ -0,0 +1,274 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Store is a BlobStore which keeps objects in a bucket of Amazon S3 or a compatible service such as MinIO,
+// addressed path-style at the endpoint and authenticated with AWS Signature Version 4
+type S3Store struct {
+	Endpoint  string // e.g. https://s3.us-east-1.amazonaws.com
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client // http.DefaultClient when nil
+}
+
+// NewS3Store returns an S3Store for the bucket at the endpoint
+func NewS3Store(endpoint, region, bucket, accessKey, secretKey string) *S3Store {
+	return &S3Store{
+		Endpoint:  strings.TrimRight(endpoint, "/"),
+		Region:    region,
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+	}
+}
+
+// Put stores the contents of r under the key, replacing any existing object
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	// The payload is signed, and S3 requires its length up front, so it is read in full
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	req, err := s.request(ctx, http.MethodPut, key, nil, body)
+	if err != nil {
+		return err
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// Get returns a reader for the object stored under the key, the caller must close it
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// s3ListResult is the response body of a ListObjectsV2 request
+type s3ListResult struct {
+	Contents []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// List returns the objects with keys beginning with the prefix, ordered by key
+func (s *S3Store) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+
+	for {
+		req, err := s.request(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obj := range result.Contents {
+			objects = append(objects, Object{
+				Key:      obj.Key,
+				Size:     obj.Size,
+				Modified: obj.LastModified,
+			})
+		}
+
+		if !result.IsTruncated {
+			break
+		}
+
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+
+	return objects, nil
+}
+
+// Delete removes the object stored under the key. S3 does not report whether the object existed,
+// so unlike the other stores ErrNotFound is never returned.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	req, err := s.request(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// do sends the request, translating error responses into errors
+func (s *S3Store) do(req *http.Request) (*http.Response, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	return nil, fmt.Errorf("s3 %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, bytes.TrimSpace(msg))
+}
+
+// request returns a signed request for the object under the key, or the bucket itself when the key is empty
+func (s *S3Store) request(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Request, error) {
+	if strings.Contains(key, "..") {
+		return nil, errors.New("invalid object key")
+	}
+
+	path := "/" + s3Escape(s.Bucket, false)
+	if key != "" {
+		path += "/" + s3Escape(strings.TrimPrefix(key, "/"), true)
+	}
+
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	u.RawPath = path
+	u.Path, _ = url.PathUnescape(path)
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	s.sign(req, body, time.Now().UTC())
+
+	return req, nil
+}
+
+// sign adds the AWS Signature Version 4 authorization headers to the request
+func (s *S3Store) sign(req *http.Request, body []byte, now time.Time) {
+	stamp := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	sum := sha256.Sum256(body)
+	payload := hex.EncodeToString(sum[:])
+
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+
+	headers := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payload + "\n" +
+		"x-amz-date:" + stamp + "\n"
+	signed := "host;x-amz-content-sha256;x-amz-date"
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		headers,
+		signed,
+		payload,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes every byte other than the unreserved characters, and slashes when keepSlash is set
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~', ch == '/' && keepSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned when the requested object does not exist in the store
+var ErrNotFound = errors.New("object not found")
+
+// Object describes a stored blob
+type Object struct {
+	Key      string    `json:"key"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// BlobStore is implemented by any object storage backend capable of holding exported files and uploads
+type BlobStore interface {
+	// Put stores the contents of r under the key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+
+	// Get returns a reader for the object stored under the key, the caller must close it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// List returns the objects with keys beginning with the prefix, ordered by key
+	List(ctx context.Context, prefix string) ([]Object, error)
+
+	// Delete removes the object stored under the key
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidISOWeek = errors.New("week must be an ISO 8601 week such as 2024-W32")
+
+// ParseISOWeek returns midnight of the Monday starting the ISO 8601 week, such as 2024-W32, in loc
+func ParseISOWeek(week string, loc *time.Location) (time.Time, error) {
+	var year, num int
+
+	n, err := fmt.Sscanf(week, "%4d-W%2d", &year, &num)
+	if err != nil || n != 2 || len(week) != len("2006-W01") {
+		return time.Time{}, ErrInvalidISOWeek
+	}
+
+	// Week 1 is the week containing January 4th
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, loc)
+	monday := jan4.AddDate(0, 0, -((int(jan4.Weekday()) + 6) % 7))
+
+	start := monday.AddDate(0, 0, (num-1)*7)
+
+	// Reject week numbers the year does not have, which would land in the next year
+	if y, w := start.ISOWeek(); num < 1 || y != year || w != num {
+		return time.Time{}, ErrInvalidISOWeek
+	}
+
+	return start, nil
+}
+
+// FormatISOWeek returns the ISO 8601 week containing t, such as 2024-W32
+func FormatISOWeek(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week)
+}